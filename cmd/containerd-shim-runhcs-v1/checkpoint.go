@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Microsoft/hcsshim/internal/atomicfile"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/pkg/errors"
+)
+
+// taskCheckpointFile is the file name of the task manifest in a checkpoint
+// directory. It is stored next to the UtilityVM state and manifest.
+const taskCheckpointFile = "task.json"
+
+// taskCheckpoint describes the task that was running in a checkpointed
+// UtilityVM.
+type taskCheckpoint struct {
+	// ID is the id of the container in the UtilityVM.
+	ID string `json:"ID"`
+	// IsWCOW is `true` if the container is a Windows container.
+	IsWCOW bool `json:"IsWCOW,omitempty"`
+	// InitPid is the pid of the init process in the container.
+	InitPid int `json:"InitPid"`
+}
+
+// writeTaskCheckpoint atomically writes `tc` to the checkpoint directory
+// `path`.
+func writeTaskCheckpoint(path string, tc *taskCheckpoint) error {
+	b, err := json.Marshal(tc)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filepath.Join(path, taskCheckpointFile), b, 0600)
+}
+
+// readTaskCheckpoint reads the task manifest from the checkpoint directory
+// `path`.
+//
+// If the directory does not contain a task manifest returns
+// `errdefs.ErrNotFound`.
+func readTaskCheckpoint(path string) (*taskCheckpoint, error) {
+	b, err := ioutil.ReadFile(filepath.Join(path, taskCheckpointFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Wrapf(errdefs.ErrNotFound, "checkpoint: '%s' does not contain a task", path)
+		}
+		return nil, err
+	}
+	tc := &taskCheckpoint{}
+	if err := json.Unmarshal(b, tc); err != nil {
+		return nil, errors.Wrapf(errdefs.ErrFailedPrecondition, "checkpoint: '%s' contains an invalid task: %v", path, err)
+	}
+	if tc.ID == "" || tc.InitPid == 0 {
+		return nil, errors.Wrapf(errdefs.ErrFailedPrecondition, "checkpoint: '%s' contains an incomplete task", path)
+	}
+	return tc, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd/errdefs"
+)
+
+func Test_TaskCheckpoint_RoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taskcheckpoint")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	expected := &taskCheckpoint{
+		ID:      t.Name(),
+		IsWCOW:  true,
+		InitPid: 10,
+	}
+	if err := writeTaskCheckpoint(dir, expected); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	actual, err := readTaskCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if *actual != *expected {
+		t.Fatalf("expected: %+v, got: %+v", expected, actual)
+	}
+}
+
+func Test_TaskCheckpoint_NotFound_Error(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taskcheckpoint")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tc, err := readTaskCheckpoint(dir)
+
+	verifyExpectedError(t, tc, err, errdefs.ErrNotFound)
+}
+
+func Test_TaskCheckpoint_Incomplete_Error(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taskcheckpoint")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, taskCheckpointFile), []byte(`{"ID":"a"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tc, err := readTaskCheckpoint(dir)
+
+	verifyExpectedError(t, tc, err, errdefs.ErrFailedPrecondition)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/guid"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/Microsoft/hcsshim/internal/uvmfolder"
+	"github.com/sirupsen/logrus"
+)
+
+// templateSaveTimeout is the time after which a template directory without a
+// saved template is considered abandoned by the shim that was saving it.
+const templateSaveTimeout = 10 * time.Minute
+
+// templateRoot returns the directory that utility VM templates are saved in.
+func templateRoot() string {
+	return filepath.Join(os.Getenv("ProgramData"), "containerd-shim-runhcs-v1", "templates")
+}
+
+// templatePath returns the template directory of the utility VMs created with
+// `wopts` from the utility VM image in `uvmFolder`. Utility VMs created with
+// different options or images use different templates.
+func templatePath(wopts *uvm.OptionsWCOW, uvmFolder string) (string, error) {
+	o := *wopts.Options
+	o.ID = ""
+	o.Owner = ""
+	o.Backend = nil
+	b, err := json.Marshal(struct {
+		Options             uvm.Options
+		SCSIControllerCount uint32
+		UVMFolder           string
+	}{o, wopts.SCSIControllerCount, uvmFolder})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return filepath.Join(templateRoot(), hex.EncodeToString(sum[:8])), nil
+}
+
+// cloneHost creates the utility VM for the pod created with `wopts` as a clone
+// of the template for `wopts` with its scratch in `scratchFolder`. The template
+// is saved first if it does not exist yet.
+//
+// Returns `nil` if the utility VM cannot be cloned, in which case the caller
+// creates the utility VM.
+func cloneHost(ctx context.Context, wopts *uvm.OptionsWCOW, scratchFolder string) *uvm.UtilityVM {
+	log := logrus.WithField("uvm-id", wopts.ID)
+	if wopts.CrashDumpPath != "" {
+		// A clone resumes the guest of the template, which was not configured
+		// to write crash dumps.
+		log.Debug("cloneHost - crash dump collection is not supported by clones")
+		return nil
+	}
+	uvmFolder, err := uvmfolder.LocateUVMFolder(wopts.LayerFolders)
+	if err != nil {
+		log.WithError(err).Warning("cloneHost - failed to locate utility VM folder")
+		return nil
+	}
+	path, err := templatePath(wopts, uvmFolder)
+	if err != nil {
+		log.WithError(err).Warning("cloneHost - failed to get template path")
+		return nil
+	}
+	log = log.WithField("template", path)
+	if _, err := os.Stat(filepath.Join(path, uvm.CheckpointManifestFile)); os.IsNotExist(err) {
+		if err := saveTemplate(ctx, wopts, path); err != nil {
+			log.WithError(err).Warning("cloneHost - failed to save template")
+			return nil
+		}
+	}
+	host, err := uvm.CloneWCOW(ctx, &uvm.OptionsCloneWCOW{
+		ID:            wopts.ID,
+		Owner:         wopts.Owner,
+		TemplatePath:  path,
+		ScratchFolder: scratchFolder,
+	})
+	if err != nil {
+		log.WithError(err).Warning("cloneHost - failed to clone template")
+		return nil
+	}
+	log.Info("cloneHost - cloned utility VM from template")
+	return host
+}
+
+// saveTemplate boots a utility VM created with `wopts` and saves it as the
+// template at `path`. The template scratch is created in `path`.
+//
+// Returns an error if another shim is saving the template.
+func saveTemplate(ctx context.Context, wopts *uvm.OptionsWCOW, path string) (err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// Creating the directory claims the template for this shim.
+	if err := os.Mkdir(path, 0700); err != nil {
+		fi, serr := os.Stat(path)
+		if !os.IsExist(err) || serr != nil || time.Since(fi.ModTime()) < templateSaveTimeout {
+			return err
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if err := os.Mkdir(path, 0700); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(path)
+		}
+	}()
+
+	o := *wopts.Options
+	o.ID = fmt.Sprintf("template-%s@vm", guid.New())
+	topts := *wopts
+	topts.Options = &o
+	topts.LayerFolders = make([]string, len(wopts.LayerFolders))
+	copy(topts.LayerFolders, wopts.LayerFolders)
+	topts.LayerFolders[len(topts.LayerFolders)-1] = path
+
+	host, err := uvm.CreateWCOW(ctx, &topts)
+	if err != nil {
+		return err
+	}
+	// The template utility VM is terminated once saved.
+	defer host.Close()
+	if err := startHost(host); err != nil {
+		return err
+	}
+	return host.SaveAsTemplate(path)
+}
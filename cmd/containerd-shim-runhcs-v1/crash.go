@@ -0,0 +1,83 @@
+package main
+
+import (
+	"path/filepath"
+
+	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/crashdump"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/sirupsen/logrus"
+)
+
+// guestCrashEventTopic is the topic of the `runhcsopts.GuestCrash` event.
+const guestCrashEventTopic = "/tasks/guestcrash"
+
+// collectHostCrash collects the guest crash of `host`, the utility VM owned by
+// the task `tid`, if it exited abnormally. The crash is logged and published
+// as a `guestCrashEventTopic` event.
+//
+// This MUST be called once `host.Close` has returned, which collects the crash
+// before it removes the crash dump directory, and before the exit event of the
+// task is published so that upstream listeners see the crash first.
+func collectHostCrash(events publisher, tid string, host *uvm.UtilityVM) {
+	r, err := host.CollectCrash()
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"tid":           tid,
+			logrus.ErrorKey: err,
+		}).Error("collectHostCrash - failed to collect guest crash")
+	}
+	if r == nil {
+		return
+	}
+	logrus.WithFields(logrus.Fields{
+		"tid":          tid,
+		"uvm-id":       r.UVMID,
+		"reason":       r.Reason,
+		"dump-path":    r.DumpPath,
+		"console-tail": r.ConsoleTail,
+	}).Warning("collectHostCrash - utility VM crashed")
+	events(
+		guestCrashEventTopic,
+		&runhcsopts.GuestCrash{
+			ContainerID: tid,
+			UvmID:       r.UVMID,
+			Reason:      r.Reason,
+			DumpPath:    r.DumpPath,
+			ConsoleTail: r.ConsoleTail,
+			CrashedAt:   r.CrashedAt,
+		})
+}
+
+// pruneCrashDumps removes the oldest crash dumps in the crash dump root of the
+// utility VM created with `opts` until the root is within the retention limits
+// of `shimOpts`. It is called before the utility VM is created so that the
+// dumps of previous pods do not accumulate. Failures are logged.
+//
+// `opts` is the `*uvm.OptionsLCOW` or `*uvm.OptionsWCOW` returned by
+// `oci.SpecToUVMCreateOpts`.
+func pruneCrashDumps(opts interface{}, shimOpts *runhcsopts.Options) {
+	var crashDumpPath string
+	switch o := opts.(type) {
+	case *uvm.OptionsLCOW:
+		crashDumpPath = o.CrashDumpPath
+	case *uvm.OptionsWCOW:
+		crashDumpPath = o.CrashDumpPath
+	}
+	if crashDumpPath == "" || shimOpts == nil {
+		return
+	}
+	limits := crashdump.Limits{
+		MaxCount: int(shimOpts.CrashDumpMaxCount),
+		MaxBytes: int64(shimOpts.CrashDumpMaxSizeInMb) * 1024 * 1024,
+	}
+	root := filepath.Dir(crashDumpPath)
+	removed, err := crashdump.Prune(root, limits)
+	log := logrus.WithField("root", root)
+	if len(removed) > 0 {
+		log.WithField("removed", removed).Debug("pruneCrashDumps - removed crash dumps")
+	}
+	if err != nil {
+		log.WithError(err).Warning("pruneCrashDumps - failed to prune crash dumps")
+	}
+}
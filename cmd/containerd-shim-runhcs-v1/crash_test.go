@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+)
+
+func Test_pruneCrashDumps(t *testing.T) {
+	root, err := ioutil.TempDir("", "crash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	now := time.Now()
+	for i, name := range []string{"pod1@vm", "pod2@vm"} {
+		dir := filepath.Join(root, name)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".dmp"), []byte("dump"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mt := now.Add(time.Duration(i-2) * time.Minute)
+		if err := os.Chtimes(dir, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lopts := uvm.NewDefaultOptionsLCOW("pod3@vm", "")
+	lopts.CrashDumpPath = filepath.Join(root, "pod3@vm")
+	pruneCrashDumps(lopts, &runhcsopts.Options{CrashDumpMaxCount: 1})
+
+	if _, err := os.Stat(filepath.Join(root, "pod1@vm")); !os.IsNotExist(err) {
+		t.Fatalf("expected the oldest dump to be removed, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "pod2@vm")); err != nil {
+		t.Fatalf("expected the newest dump to be retained: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// platformCallTimeout is the longest `callWithContext` and
+// `createProcessWithContext` wait for a platform call when the callers context
+// has no deadline. The vendored ttrpc does not propagate the deadline of the
+// client to the server so requests from containerd never have one.
+var platformCallTimeout = 4 * time.Minute
+
+// withCallTimeout returns `ctx` if it has a deadline. Otherwise it returns a
+// child of `ctx` that times out after `platformCallTimeout`.
+func withCallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, platformCallTimeout)
+}
+
+// callWithContext calls `f` and returns its error. If `ctx` is done, or
+// `platformCallTimeout` passes when `ctx` has no deadline, before `f` returns
+// an error wrapping the context error is returned instead. In this case `f`
+// keeps running on its own goroutine and its result is discarded.
+//
+// This is used for platform calls that do not take a context so that a hung
+// compute system does not block the caller forever.
+func callWithContext(ctx context.Context, op string, f func() error) error {
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "%s did not complete", op)
+	}
+}
+
+// createProcessWithContext is `callWithContext` for a call to `f` that creates
+// a process. If `ctx` is done first the process, if it is created after all,
+// is killed and its handle closed.
+func createProcessWithContext(ctx context.Context, f func() (cow.Process, error)) (cow.Process, error) {
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+
+	type result struct {
+		p   cow.Process
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		p, err := f()
+		done <- result{p, err}
+	}()
+	select {
+	case r := <-done:
+		return r.p, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.err == nil {
+				r.p.Kill()
+				r.p.Close()
+			}
+		}()
+		return nil, errors.Wrap(ctx.Err(), "CreateProcess did not complete")
+	}
+}
+
+// releaseWithTimeout calls `release` and waits at most `processStopTimeout`
+// for it to return. If it has not returned by then it keeps running on its own
+// goroutine so that a hung compute system cannot block the caller forever.
+//
+// `release` MUST close the handles it is given even if terminating the compute
+// system or process fails.
+func releaseWithTimeout(log *logrus.Entry, op string, release func()) {
+	done := make(chan struct{})
+	go func() {
+		release()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(processStopTimeout):
+		log.WithField("op", op).Warn("timed out waiting for release, continuing in the background")
+	}
+}
@@ -33,6 +33,11 @@ The delete command will be executed in the container's bundle as its cwd.
 			return errors.New("bundle is required")
 		}
 
+		// Terminate everything the shim persisted and release its resources.
+		if err := cleanupState(newShimStateStore(bundleFlag), hcsRecoveryBackend{backend: hcs.Backend()}); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to cleanup persisted state for '%s': %v", idFlag, err)
+		}
+
 		// Attempt to find the hcssystem for this bundle and terminate it.
 		if sys, _ := hcs.OpenComputeSystem(idFlag); sys != nil {
 			if err := sys.Terminate(); err != nil {
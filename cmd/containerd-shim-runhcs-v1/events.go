@@ -2,37 +2,292 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"os/exec"
 	"sync"
+	"time"
 
+	"github.com/containerd/ttrpc"
 	"github.com/containerd/typeurl"
+	"github.com/gogo/protobuf/types"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
 type publisher func(topic string, event interface{})
 
 var _ = (publisher)(publishEvent)
+var _ = (publisher)((&eventPublisher{}).publish)
 
 var publishLock sync.Mutex
 
+// publishEvent publishes `event` by executing the `containerd publish` binary.
+// It is used as the fallback for the `eventPublisher` when the ttrpc
+// connection to containerd cannot be used.
 func publishEvent(topic string, event interface{}) {
-	publishLock.Lock()
-	defer publishLock.Unlock()
-
 	encoded, err := typeurl.MarshalAny(event)
 	if err != nil {
 		logrus.WithError(err).Error("publishEvent - Failed to encode event")
 		return
 	}
-	data, err := encoded.Marshal()
+	if err := execPublish(topic, encoded); err != nil {
+		logrus.WithError(err).Error("publishEvent - Failed to publish event")
+	}
+}
+
+// execPublish publishes the already encoded `event` by executing the
+// `containerd publish` binary.
+func execPublish(topic string, event *types.Any) error {
+	publishLock.Lock()
+	defer publishLock.Unlock()
+
+	data, err := event.Marshal()
 	if err != nil {
-		logrus.WithError(err).Error("publishEvent - Failed to marshal event")
-		return
+		return errors.Wrap(err, "failed to marshal event")
 	}
 	cmd := exec.Command(containerdBinaryFlag, "--address", addressFlag, "publish", "--topic", topic, "--namespace", namespaceFlag)
 	cmd.Stdin = bytes.NewReader(data)
-	err = cmd.Run()
+	return cmd.Run()
+}
+
+const (
+	// eventQueueSize is the maximum number of events that can be waiting to
+	// be delivered before `publish` blocks the caller.
+	eventQueueSize = 256
+	// eventMaxAttempts is the number of times delivery of a single event is
+	// attempted over ttrpc before falling back to the exec path.
+	eventMaxAttempts = 5
+	// eventInitialBackoff is the wait after the first failed attempt. It
+	// doubles on each retry up to `eventMaxBackoff`.
+	eventInitialBackoff = 50 * time.Millisecond
+	eventMaxBackoff     = 2 * time.Second
+	// eventForwardTimeout bounds a single ttrpc delivery attempt.
+	eventForwardTimeout = 5 * time.Second
+	// eventDialTimeout bounds connecting to containerd's event service.
+	eventDialTimeout = 2 * time.Second
+	// eventFlushTimeout bounds waiting for queued events on shutdown.
+	eventFlushTimeout = 10 * time.Second
+)
+
+// eventEnvelope is the wire format of
+// `containerd.services.events.v1.Envelope`.
+type eventEnvelope struct {
+	Timestamp *types.Timestamp `protobuf:"bytes,1,opt,name=timestamp" json:"timestamp,omitempty"`
+	Namespace string           `protobuf:"bytes,2,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Topic     string           `protobuf:"bytes,3,opt,name=topic,proto3" json:"topic,omitempty"`
+	Event     *types.Any       `protobuf:"bytes,4,opt,name=event" json:"event,omitempty"`
+}
+
+func (m *eventEnvelope) Reset()         { *m = eventEnvelope{} }
+func (m *eventEnvelope) String() string { return m.Topic }
+func (*eventEnvelope) ProtoMessage()    {}
+
+// forwardRequest is the wire format of
+// `containerd.services.events.v1.ForwardRequest`.
+type forwardRequest struct {
+	Envelope *eventEnvelope `protobuf:"bytes,1,opt,name=envelope" json:"envelope,omitempty"`
+}
+
+func (m *forwardRequest) Reset()         { *m = forwardRequest{} }
+func (m *forwardRequest) String() string { return m.Envelope.String() }
+func (*forwardRequest) ProtoMessage()    {}
+
+// eventForwarder delivers a single event envelope upstream.
+type eventForwarder func(ctx context.Context, env *eventEnvelope) error
+
+// ttrpcEventForwarder forwards events to containerd's ttrpc event service. It
+// holds a single connection that is dialed on first use and redialed after
+// any failure.
+type ttrpcEventForwarder struct {
+	dial func() (*ttrpc.Client, error)
+
+	// m protects `client`.
+	m      sync.Mutex
+	client *ttrpc.Client
+}
+
+func (tf *ttrpcEventForwarder) forward(ctx context.Context, env *eventEnvelope) error {
+	tf.m.Lock()
+	defer tf.m.Unlock()
+
+	if tf.client == nil {
+		c, err := tf.dial()
+		if err != nil {
+			return errors.Wrap(err, "failed to connect to event service")
+		}
+		tf.client = c
+	}
+	err := tf.client.Call(ctx, "containerd.services.events.ttrpc.v1.Events", "Forward", &forwardRequest{Envelope: env}, &types.Empty{})
 	if err != nil {
-		logrus.WithError(err).Error("publishEvent - Failed to publish event")
+		// Drop the connection so that the next attempt redials.
+		tf.client.Close()
+		tf.client = nil
+		return errors.Wrap(err, "failed to forward event")
+	}
+	return nil
+}
+
+func (tf *ttrpcEventForwarder) close() {
+	tf.m.Lock()
+	defer tf.m.Unlock()
+
+	if tf.client != nil {
+		tf.client.Close()
+		tf.client = nil
+	}
+}
+
+// eventPublisher delivers events upstream in the order they were published.
+//
+// Events are placed on a bounded queue and delivered by a single goroutine
+// which drains all queued events over one connection. A failed delivery is
+// retried with backoff and if the event still cannot be delivered it is sent
+// through `fallback` instead so that it is not lost.
+type eventPublisher struct {
+	namespace string
+	forward   eventForwarder
+	fallback  func(topic string, event *types.Any) error
+
+	queue chan *eventEnvelope
+	// done is closed when the delivery goroutine has exited.
+	done chan struct{}
+
+	// m protects `closed` and sends on `queue`.
+	m      sync.RWMutex
+	closed bool
+	// stop is closed when `close` times out to cut short any backoff in
+	// progress.
+	stop chan struct{}
+}
+
+// newEventPublisher creates an `eventPublisher` and starts its delivery
+// goroutine. The caller MUST call `close` to flush the queue.
+func newEventPublisher(namespace string, forward eventForwarder, fallback func(topic string, event *types.Any) error) *eventPublisher {
+	ep := &eventPublisher{
+		namespace: namespace,
+		forward:   forward,
+		fallback:  fallback,
+		queue:     make(chan *eventEnvelope, eventQueueSize),
+		done:      make(chan struct{}),
+		stop:      make(chan struct{}),
+	}
+	go ep.run()
+	return ep
+}
+
+// publish queues `event` for delivery. If the queue is full it blocks until
+// space is available. Events published after `close` are dropped.
+func (ep *eventPublisher) publish(topic string, event interface{}) {
+	encoded, err := typeurl.MarshalAny(event)
+	if err != nil {
+		logrus.WithError(err).Error("eventPublisher::publish - Failed to encode event")
+		shimMetrics.eventPublishFailures.Inc(topic, "encode")
+		return
+	}
+	env := &eventEnvelope{
+		Timestamp: types.TimestampNow(),
+		Namespace: ep.namespace,
+		Topic:     topic,
+		Event:     encoded,
+	}
+
+	ep.m.RLock()
+	defer ep.m.RUnlock()
+	if ep.closed {
+		logrus.WithField("topic", topic).Warn("eventPublisher::publish - publisher closed, dropping event")
+		shimMetrics.eventPublishFailures.Inc(topic, "closed")
+		return
+	}
+	select {
+	case ep.queue <- env:
+	default:
+		logrus.WithField("topic", topic).Warn("eventPublisher::publish - queue full, waiting")
+		ep.queue <- env
+	}
+}
+
+func (ep *eventPublisher) run() {
+	defer close(ep.done)
+	for env := range ep.queue {
+		ep.deliver(env)
+	}
+}
+
+// deliver sends `env` upstream retrying with backoff on failure. If `close`
+// has timed out waiting for the queue to drain no further retries are
+// attempted and the event goes straight to the fallback.
+func (ep *eventPublisher) deliver(env *eventEnvelope) {
+	backoff := eventInitialBackoff
+	var err error
+retry:
+	for attempt := 1; attempt <= eventMaxAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), eventForwardTimeout)
+		err = ep.forward(ctx, env)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt == eventMaxAttempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ep.stop:
+			break retry
+		}
+		backoff *= 2
+		if backoff > eventMaxBackoff {
+			backoff = eventMaxBackoff
+		}
+	}
+
+	log := logrus.WithFields(logrus.Fields{
+		"topic":         env.Topic,
+		logrus.ErrorKey: err,
+	})
+	log.Warn("eventPublisher::deliver - Failed to forward event, falling back")
+	shimMetrics.eventPublishFailures.Inc(env.Topic, "forward")
+	if ep.fallback == nil {
+		log.Error("eventPublisher::deliver - Dropping event")
+		shimMetrics.eventPublishFailures.Inc(env.Topic, "fallback")
+		return
+	}
+	if err := ep.fallback(env.Topic, env.Event); err != nil {
+		log.Data[logrus.ErrorKey] = err
+		log.Error("eventPublisher::deliver - Failed to publish event")
+		shimMetrics.eventPublishFailures.Inc(env.Topic, "fallback")
+	}
+}
+
+// close stops accepting new events and waits up to `timeout` for all queued
+// events to be delivered. It is safe to call `close` more than once.
+func (ep *eventPublisher) close(timeout time.Duration) error {
+	ep.m.Lock()
+	if !ep.closed {
+		ep.closed = true
+		close(ep.queue)
+	}
+	ep.m.Unlock()
+
+	select {
+	case <-ep.done:
+		return nil
+	case <-time.After(timeout):
+		// Stop retrying so the remaining events are sent via the fallback
+		// rather than waiting out their backoff.
+		ep.cancelRetries()
+		return errors.New("timed out flushing events")
+	}
+}
+
+// cancelRetries closes `stop` if it is not already closed.
+func (ep *eventPublisher) cancelRetries() {
+	ep.m.Lock()
+	defer ep.m.Unlock()
+
+	select {
+	case <-ep.stop:
+	default:
+		close(ep.stop)
 	}
 }
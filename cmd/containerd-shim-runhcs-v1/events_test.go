@@ -1,7 +1,168 @@
 package main
 
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	eventstypes "github.com/containerd/containerd/api/events"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+)
+
 var _ = (publisher)(fakePublisher)
 
 func fakePublisher(topic string, event interface{}) {
 	// Do nothing
 }
+
+// testForwarder records forwarded envelopes and fails the first `failures`
+// attempts.
+type testForwarder struct {
+	m        sync.Mutex
+	failures int
+	attempts int
+	topics   []string
+}
+
+func (tf *testForwarder) forward(ctx context.Context, env *eventEnvelope) error {
+	tf.m.Lock()
+	defer tf.m.Unlock()
+
+	tf.attempts++
+	if tf.failures > 0 {
+		tf.failures--
+		return errors.New("forward failure")
+	}
+	tf.topics = append(tf.topics, env.Topic)
+	return nil
+}
+
+func Test_eventPublisher_OrderedDelivery(t *testing.T) {
+	tf := &testForwarder{}
+	ep := newEventPublisher("test", tf.forward, nil)
+	topics := []string{"/tasks/create", "/tasks/start", "/tasks/exit", "/tasks/delete"}
+	for _, topic := range topics {
+		ep.publish(topic, &eventstypes.TaskStart{ContainerID: t.Name()})
+	}
+	if err := ep.close(5 * time.Second); err != nil {
+		t.Fatalf("should not have failed close, got: %v", err)
+	}
+	if len(tf.topics) != len(topics) {
+		t.Fatalf("expected %d events, got: %v", len(topics), tf.topics)
+	}
+	for i := range topics {
+		if tf.topics[i] != topics[i] {
+			t.Fatalf("expected event %d to be '%s', got: '%s'", i, topics[i], tf.topics[i])
+		}
+	}
+}
+
+func Test_eventPublisher_RetrySuccess(t *testing.T) {
+	tf := &testForwarder{failures: 2}
+	fallbacks := 0
+	ep := newEventPublisher("test", tf.forward, func(topic string, event *types.Any) error {
+		fallbacks++
+		return nil
+	})
+	ep.publish("/tasks/start", &eventstypes.TaskStart{ContainerID: t.Name()})
+	if err := ep.close(5 * time.Second); err != nil {
+		t.Fatalf("should not have failed close, got: %v", err)
+	}
+	if tf.attempts != 3 {
+		t.Fatalf("expected 3 attempts, got: %d", tf.attempts)
+	}
+	if len(tf.topics) != 1 {
+		t.Fatalf("expected 1 forwarded event, got: %v", tf.topics)
+	}
+	if fallbacks != 0 {
+		t.Fatalf("expected no fallback, got: %d", fallbacks)
+	}
+}
+
+func Test_eventPublisher_RetryExhausted_Fallback(t *testing.T) {
+	tf := &testForwarder{failures: eventMaxAttempts}
+	var fallbackTopics []string
+	ep := newEventPublisher("test", tf.forward, func(topic string, event *types.Any) error {
+		fallbackTopics = append(fallbackTopics, topic)
+		return nil
+	})
+	ep.publish("/tasks/exit", &eventstypes.TaskExit{ContainerID: t.Name()})
+	ep.publish("/tasks/delete", &eventstypes.TaskDelete{ContainerID: t.Name()})
+	if err := ep.close(10 * time.Second); err != nil {
+		t.Fatalf("should not have failed close, got: %v", err)
+	}
+	if tf.attempts != eventMaxAttempts+1 {
+		t.Fatalf("expected %d attempts, got: %d", eventMaxAttempts+1, tf.attempts)
+	}
+	if len(fallbackTopics) != 1 || fallbackTopics[0] != "/tasks/exit" {
+		t.Fatalf("expected '/tasks/exit' to fall back, got: %v", fallbackTopics)
+	}
+	if len(tf.topics) != 1 || tf.topics[0] != "/tasks/delete" {
+		t.Fatalf("expected '/tasks/delete' to be forwarded, got: %v", tf.topics)
+	}
+}
+
+func Test_eventPublisher_Close_Timeout_SkipsBackoff(t *testing.T) {
+	tf := &testForwarder{failures: 1000}
+	fallbacks := make(chan string, 10)
+	ep := newEventPublisher("test", tf.forward, func(topic string, event *types.Any) error {
+		fallbacks <- topic
+		return nil
+	})
+	ep.publish("/tasks/exit", &eventstypes.TaskExit{ContainerID: t.Name()})
+	if err := ep.close(time.Millisecond); err == nil {
+		t.Fatal("expected close to time out")
+	}
+	select {
+	case topic := <-fallbacks:
+		if topic != "/tasks/exit" {
+			t.Fatalf("expected '/tasks/exit' to fall back, got: '%s'", topic)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected event to fall back after close")
+	}
+}
+
+func Test_eventPublisher_PublishAfterClose_Dropped(t *testing.T) {
+	tf := &testForwarder{}
+	ep := newEventPublisher("test", tf.forward, nil)
+	if err := ep.close(5 * time.Second); err != nil {
+		t.Fatalf("should not have failed close, got: %v", err)
+	}
+	ep.publish("/tasks/start", &eventstypes.TaskStart{ContainerID: t.Name()})
+	if err := ep.close(5 * time.Second); err != nil {
+		t.Fatalf("should not have failed 2nd close, got: %v", err)
+	}
+	if tf.attempts != 0 {
+		t.Fatalf("expected no attempts, got: %d", tf.attempts)
+	}
+}
+
+func Test_forwardRequest_Marshal(t *testing.T) {
+	event, err := types.MarshalAny(&eventstypes.TaskStart{ContainerID: t.Name(), Pid: 10})
+	if err != nil {
+		t.Fatalf("failed to marshal event: %v", err)
+	}
+	req := &forwardRequest{
+		Envelope: &eventEnvelope{
+			Timestamp: types.TimestampNow(),
+			Namespace: "test",
+			Topic:     "/tasks/start",
+			Event:     event,
+		},
+	}
+	b, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+	actual := &forwardRequest{}
+	if err := proto.Unmarshal(b, actual); err != nil {
+		t.Fatalf("failed to unmarshal request: %v", err)
+	}
+	if !proto.Equal(req, actual) {
+		t.Fatalf("expected: %+v, got: %+v", req.Envelope, actual.Envelope)
+	}
+}
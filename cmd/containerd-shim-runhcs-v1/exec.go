@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/runtime/v2/task"
 	"github.com/pkg/errors"
@@ -13,6 +14,7 @@ type shimExecState string
 const (
 	shimExecStateCreated shimExecState = "created"
 	shimExecStateRunning shimExecState = "running"
+	shimExecStatePaused  shimExecState = "paused"
 	shimExecStateExited  shimExecState = "exited"
 )
 
@@ -46,6 +48,22 @@ type shimExec interface {
 	// If the exec process has already been started this exec MUST return
 	// `errdefs.ErrFailedPrecondition`.
 	Start(ctx context.Context) error
+	// Pause transitions this exec process to the `shimExecStatePaused` state.
+	// The exec does not suspend the process itself. It is the owning tasks
+	// responsibility to pause the container or UtilityVM hosting the process
+	// before calling `Pause`.
+	//
+	// If `State() != shimExecStateRunning` this exec MUST return
+	// `errdefs.ErrFailedPrecondition`.
+	Pause(ctx context.Context) error
+	// Resume transitions this exec process from the `shimExecStatePaused` state
+	// back to the `shimExecStateRunning` state. It is the owning tasks
+	// responsibility to resume the container or UtilityVM hosting the process
+	// before calling `Resume`.
+	//
+	// If `State() != shimExecStatePaused` this exec MUST return
+	// `errdefs.ErrFailedPrecondition`.
+	Resume(ctx context.Context) error
 	// Kill sends `signal` to this exec process.
 	//
 	// If `State() != shimExecStateRunning` this exec MUST return
@@ -73,14 +91,19 @@ type shimExec interface {
 	// process is already in the `State() == shimExecStateExited` state, `Wait`
 	// MUST return immediately with the original exit state.
 	Wait(ctx context.Context) *task.StateResponse
+	// ExitReason returns why this exec exited if the exit was abnormal. For
+	// example because its container or UtilityVM exited or ran out of memory.
+	//
+	// If `State() != shimExecStateExited` returns `cow.ExitReasonNone`.
+	ExitReason() cow.ExitReason
 	// ForceExit forcibly terminates the exec, sets the exit status to `status`,
-	// and unblocks all waiters.
+	// the exit reason to `reason`, and unblocks all waiters.
 	//
 	// This call is idempotent and safe to call even on an already exited exec
 	// in which case it does nothing.
 	//
 	// `ForceExit` is safe to call in any `State()`.
-	ForceExit(status int)
+	ForceExit(status int, reason cow.ExitReason)
 }
 
 func newExecInvalidStateError(tid, eid string, state shimExecState, op string) error {
@@ -7,9 +7,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/guestrequest"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/lcow"
+	"github.com/Microsoft/hcsshim/internal/logfields"
 	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
 	"github.com/Microsoft/hcsshim/internal/signals"
 	"github.com/Microsoft/hcsshim/internal/uvm"
@@ -34,6 +36,15 @@ const (
 	// process does not stop within `processStopTimeout` we will forcibly
 	// terminate the process without a signal.
 	processStopTimeout = time.Second * 5
+
+	// statusNoMemory is the NTSTATUS `STATUS_NO_MEMORY` a Windows process
+	// exits with when it fails to commit memory.
+	statusNoMemory = 0xC0000017
+
+	// execTimeoutExitStatus is the exit status of an exec that was killed
+	// because it ran longer than its timeout. It matches `timeout(1)` so that
+	// callers can tell a timed out exec from one that exited on its own.
+	execTimeoutExitStatus = 124
 )
 
 // newHcsExec creates an exec to track the lifetime of `spec` in `c` which is
@@ -45,19 +56,21 @@ func newHcsExec(
 	events publisher,
 	tid string,
 	host *uvm.UtilityVM,
-	c *hcs.System,
+	c cow.ComputeSystem,
 	id, bundle string,
 	isWCOW bool,
 	spec *specs.Process,
 	io upstreamIO) shimExec {
-	logrus.WithFields(logrus.Fields{
-		"tid": tid,
-		"eid": id,
-	}).Debug("newHcsExec")
+	log := logrus.WithFields(logrus.Fields{
+		logfields.TaskID: tid,
+		logfields.ExecID: id,
+	})
+	log.Debug("newHcsExec")
 
 	processCtx, processDoneCancel := context.WithCancel(context.Background())
 	he := &hcsExec{
 		events:            events,
+		log:               log,
 		tid:               tid,
 		host:              host,
 		c:                 c,
@@ -76,10 +89,36 @@ func newHcsExec(
 	return he
 }
 
+// newRestoredHcsExec creates an exec to track the lifetime of the process `pid`
+// that is already running in `c`. This is the case after `c` was restored from
+// a checkpoint or reopened by a restarted shim. The exec is in the
+// `shimExecCreated` state and the call to `Start()` opens the existing process
+// rather than starting `c` and creating the process.
+func newRestoredHcsExec(
+	ctx context.Context,
+	events publisher,
+	tid string,
+	host *uvm.UtilityVM,
+	c cow.ComputeSystem,
+	id, bundle string,
+	isWCOW bool,
+	spec *specs.Process,
+	io upstreamIO,
+	pid int) shimExec {
+	he := newHcsExec(ctx, events, tid, host, c, id, bundle, isWCOW, spec, io).(*hcsExec)
+	he.restoredPid = pid
+	return he
+}
+
 var _ = (shimExec)(&hcsExec{})
 
 type hcsExec struct {
 	events publisher
+	// log is the log entry with the task and exec id of this exec that all
+	// log entries of the exec derive from.
+	//
+	// This MUST be treated as read only in the lifetime of the exec.
+	log *logrus.Entry
 	// tid is the task id of the container hosting this process.
 	//
 	// This MUST be treated as read only in the lifetime of the exec.
@@ -92,7 +131,7 @@ type hcsExec struct {
 	// c is the hosting container for this exec.
 	//
 	// This MUST be treated as read only in the lifetime of the exec.
-	c *hcs.System
+	c cow.ComputeSystem
 	// id is the id of this process.
 	//
 	// This MUST be treated as read only in the lifetime of the exec.
@@ -118,7 +157,23 @@ type hcsExec struct {
 	// create time in order to be valid.
 	//
 	// This MUST be treated as read only in the lifetime of the exec.
-	io                upstreamIO
+	io upstreamIO
+	// restoredPid is the pid of the already running process this exec tracks
+	// when restored from a checkpoint. If not `0` the call to `Start()` opens
+	// the process rather than creating it.
+	//
+	// This MUST be treated as read only in the lifetime of the exec.
+	restoredPid int
+	// stateChanged if not `nil` is called on a new goroutine every time this
+	// exec is started or exits.
+	//
+	// This MUST only be set before the exec is started.
+	stateChanged func()
+	// timeout if not `0` is the amount of time the process may run after it
+	// is started before it is killed and exits with `execTimeoutExitStatus`.
+	//
+	// This MUST only be set before the exec is started.
+	timeout           time.Duration
 	ioWg              sync.WaitGroup
 	processCtx        context.Context
 	processDoneCancel context.CancelFunc
@@ -129,8 +184,9 @@ type hcsExec struct {
 	state          shimExecState
 	pid            int
 	exitStatus     uint32
+	exitReason     cow.ExitReason
 	exitedAt       time.Time
-	p              *hcs.Process
+	p              cow.Process
 	stdout, stderr io.Closer
 
 	// exited is a wait block which waits async for the process to exit.
@@ -164,6 +220,8 @@ func (he *hcsExec) Status() *task.StateResponse {
 		s = containerd_v1_types.StatusCreated
 	case shimExecStateRunning:
 		s = containerd_v1_types.StatusRunning
+	case shimExecStatePaused:
+		s = containerd_v1_types.StatusPaused
 	case shimExecStateExited:
 		s = containerd_v1_types.StatusStopped
 	}
@@ -184,10 +242,7 @@ func (he *hcsExec) Status() *task.StateResponse {
 }
 
 func (he *hcsExec) Start(ctx context.Context) (err error) {
-	logrus.WithFields(logrus.Fields{
-		"tid": he.tid,
-		"eid": he.id,
-	}).Debug("hcsExec::Start")
+	he.log.Debug("hcsExec::Start")
 
 	he.sl.Lock()
 	defer he.sl.Unlock()
@@ -199,23 +254,31 @@ func (he *hcsExec) Start(ctx context.Context) (err error) {
 			he.exitFromCreatedL(1)
 		}
 	}()
-	if he.id == he.tid {
+	if he.id == he.tid && he.restoredPid == 0 {
 		// This is the init exec. We need to start the container itself
-		err = he.c.Start()
+		err = callWithContext(ctx, "Start", he.c.Start)
 		if err != nil {
 			return err
 		}
 		defer func() {
 			if err != nil {
-				he.c.Terminate()
-				he.c.Close()
+				releaseWithTimeout(he.log, "terminate container", func() {
+					he.c.Terminate()
+					he.c.Close()
+				})
 			}
 		}()
 	}
 	var (
-		proc *hcs.Process
+		proc cow.Process
 	)
-	if he.isWCOW {
+	if he.restoredPid != 0 {
+		// The container was restored from a checkpoint with the process
+		// already running.
+		proc, err = createProcessWithContext(ctx, func() (cow.Process, error) {
+			return he.c.OpenProcess(he.restoredPid)
+		})
+	} else if he.isWCOW {
 		wpp := &hcsschema.ProcessParameters{
 			CommandLine:      he.spec.CommandLine,
 			User:             he.spec.User.Username,
@@ -245,7 +308,9 @@ func (he *hcsExec) Start(ctx context.Context) (err error) {
 				int32(he.spec.ConsoleSize.Width),
 			}
 		}
-		proc, err = he.c.CreateProcess(wpp)
+		proc, err = createProcessWithContext(ctx, func() (cow.Process, error) {
+			return he.c.CreateProcess(wpp)
+		})
 	} else {
 		lpp := &lcow.ProcessParameters{
 			ProcessParameters: hcsschema.ProcessParameters{
@@ -259,7 +324,9 @@ func (he *hcsExec) Start(ctx context.Context) (err error) {
 			// the spec if this is a true exec.
 			lpp.OCIProcess = he.spec
 		}
-		proc, err = he.c.CreateProcess(lpp)
+		proc, err = createProcessWithContext(ctx, func() (cow.Process, error) {
+			return he.c.CreateProcess(lpp)
+		})
 	}
 	if err != nil {
 		return err
@@ -267,8 +334,10 @@ func (he *hcsExec) Start(ctx context.Context) (err error) {
 	he.p = proc
 	defer func() {
 		if err != nil {
-			he.p.Kill()
-			he.p.Close()
+			releaseWithTimeout(he.log, "kill process", func() {
+				proc.Kill()
+				proc.Close()
+			})
 		}
 	}()
 
@@ -290,23 +359,29 @@ func (he *hcsExec) Start(ctx context.Context) (err error) {
 		}
 	}()
 
-	if he.io.StdinPath() != "" {
+	relayIn, relayOut, relayErr := he.io.StdinPath() != "", he.io.StdoutPath() != "", he.io.StderrPath() != ""
+	if he.restoredPid != 0 && (relayIn && in == nil || relayOut && out == nil || relayErr && serr == nil) {
+		// The platform only returns the pipes of a process to the caller that
+		// created it. An opened process may not have them so there is nothing
+		// to relay.
+		he.log.Warn("hcsExec::Start - platform returned no stdio for opened process")
+		relayIn, relayOut, relayErr = relayIn && in != nil, relayOut && out != nil, relayErr && serr != nil
+	}
+
+	if relayIn {
 		if in == nil {
 			return errors.New("hcsExec::Start - platform returned nil stdin pipe")
 		}
 		go func() {
 			io.Copy(in, he.io.Stdin())
-			logrus.WithFields(logrus.Fields{
-				"tid": he.tid,
-				"eid": he.id,
-			}).Debug("hcsExec::Start::Stdin - Copy completed")
+			he.log.Debug("hcsExec::Start::Stdin - Copy completed")
 			in.Close()
 			he.p.CloseStdin()
 			he.io.CloseStdin()
 		}()
 	}
 
-	if he.io.StdoutPath() != "" {
+	if relayOut {
 		if out == nil {
 			return errors.New("hcsExec::Start - platform returned nil stdout pipe")
 		}
@@ -314,10 +389,7 @@ func (he *hcsExec) Start(ctx context.Context) (err error) {
 		he.ioWg.Add(1)
 		go func() {
 			io.Copy(he.io.Stdout(), out)
-			logrus.WithFields(logrus.Fields{
-				"tid": he.tid,
-				"eid": he.id,
-			}).Debug("hcsExec::Start::Stdout - Copy completed")
+			he.log.Debug("hcsExec::Start::Stdout - Copy completed")
 			he.ioWg.Done()
 
 			// Close the stdout io handle if not closed.
@@ -330,7 +402,7 @@ func (he *hcsExec) Start(ctx context.Context) (err error) {
 		}()
 	}
 
-	if he.io.StderrPath() != "" {
+	if relayErr {
 		if serr == nil {
 			return errors.New("hcsExec::Start - platform returned nil stderr pipe")
 		}
@@ -338,10 +410,7 @@ func (he *hcsExec) Start(ctx context.Context) (err error) {
 		he.ioWg.Add(1)
 		go func() {
 			io.Copy(he.io.Stderr(), serr)
-			logrus.WithFields(logrus.Fields{
-				"tid": he.tid,
-				"eid": he.id,
-			}).Debug("hcsExec::Start::Stderr - Copy completed")
+			he.log.Debug("hcsExec::Start::Stderr - Copy completed")
 			he.ioWg.Done()
 
 			// Close the stderr io handle if not closed.
@@ -379,89 +448,147 @@ func (he *hcsExec) Start(ctx context.Context) (err error) {
 
 	// wait in the background for the exit.
 	go he.waitForExit()
+	if he.timeout > 0 {
+		go he.waitForTimeout()
+	}
+	he.notifyStateChanged()
+	return nil
+}
+
+// notifyStateChanged calls `he.stateChanged` on a new goroutine if set. It is
+// safe to call while holding `he.sl`.
+func (he *hcsExec) notifyStateChanged() {
+	if he.stateChanged != nil {
+		go he.stateChanged()
+	}
+}
+
+func (he *hcsExec) Pause(ctx context.Context) error {
+	he.log.Debug("hcsExec::Pause")
+
+	he.sl.Lock()
+	defer he.sl.Unlock()
+	if he.state != shimExecStateRunning {
+		return newExecInvalidStateError(he.tid, he.id, he.state, "pause")
+	}
+	he.state = shimExecStatePaused
+	return nil
+}
+
+func (he *hcsExec) Resume(ctx context.Context) error {
+	he.log.Debug("hcsExec::Resume")
+
+	he.sl.Lock()
+	defer he.sl.Unlock()
+	if he.state != shimExecStatePaused {
+		return newExecInvalidStateError(he.tid, he.id, he.state, "resume")
+	}
+	he.state = shimExecStateRunning
 	return nil
 }
 
 func (he *hcsExec) Kill(ctx context.Context, signal uint32) error {
-	logrus.WithFields(logrus.Fields{
-		"tid":    he.tid,
-		"eid":    he.id,
+	he.log.WithFields(logrus.Fields{
 		"signal": signal,
 	}).Debug("hcsExec::Kill")
 
 	he.sl.Lock()
-	defer he.sl.Unlock()
-	switch he.state {
-	case shimExecStateCreated:
-		he.exitFromCreatedL(1)
-		return nil
-	case shimExecStateRunning:
-		supported := false
-		if osversion.Get().Build >= osversion.RS5 {
-			supported = he.host == nil || he.host.SignalProcessSupported()
-		}
-		var options interface{}
-		var err error
-		if he.isWCOW {
-			var opt *guestrequest.SignalProcessOptionsWCOW
-			opt, err = signals.ValidateWCOW(int(signal), supported)
-			if opt != nil {
-				options = opt
-			}
-		} else {
-			var opt *guestrequest.SignalProcessOptionsLCOW
-			opt, err = signals.ValidateLCOW(int(signal), supported)
-			if opt != nil {
-				options = opt
-			}
+	// A paused exec is still a live process. The task resumes the compute
+	// system before it signals a paused exec.
+	if he.state != shimExecStateRunning && he.state != shimExecStatePaused {
+		defer he.sl.Unlock()
+		switch he.state {
+		case shimExecStateCreated:
+			he.exitFromCreatedL(1)
+			return nil
+		case shimExecStateExited:
+			return errors.Wrapf(errdefs.ErrNotFound, "exec: '%s' in task: '%s' not found", he.id, he.tid)
+		default:
+			return newExecInvalidStateError(he.tid, he.id, he.state, "kill")
 		}
-		if err != nil {
-			return errors.Wrapf(errdefs.ErrFailedPrecondition, "signal %d: %v", signal, err)
+	}
+	// Do not hold `he.sl` across the platform call. A hung compute system
+	// would otherwise block every other call on this exec, including the
+	// exit transition in `he.waitForExit`.
+	p := he.p
+	he.sl.Unlock()
+
+	supported := false
+	if osversion.Get().Build >= osversion.RS5 {
+		supported = he.host == nil || he.host.SignalProcessSupported()
+	}
+	var options interface{}
+	var err error
+	if he.isWCOW {
+		var opt *guestrequest.SignalProcessOptionsWCOW
+		opt, err = signals.ValidateWCOW(int(signal), supported)
+		if opt != nil {
+			options = opt
 		}
-		if supported && options != nil {
-			err = he.p.Signal(options)
-		} else {
-			// legacy path before signals support OR if WCOW with signals
-			// support needs to issue a terminate.
-			err = he.p.Kill()
+	} else {
+		var opt *guestrequest.SignalProcessOptionsLCOW
+		opt, err = signals.ValidateLCOW(int(signal), supported)
+		if opt != nil {
+			options = opt
 		}
-		if err != nil {
-			if hcs.IsNotExist(err) && signal == 0x9 || hcs.IsOperationInvalidState(err) {
-				// If we issued a SIGKILL (or terminate) and get ERROR_NOT_FOUND
-				// or ERROR_VMCOMPUTE_INVALID_STATE `he.waitForExit` is either:
-				//
-				// 1. About to transition the state when it is signaled by the
-				// HCS and everything is fine. This was just a simple race where
-				// the SIGKILL came in before the previous signal completed.
-				//
-				// OR
-				//
-				// 2. We are stuck in `he.waitForExit` and the notification is
-				// not going to be delivered. In this case we force the exit by
-				// closing `he.p` and unblocking all waiters.
-				go func() {
-					// Give the HCS 1 second to finish and deliver the notification.
-					time.Sleep(1 * time.Second)
-					// Force the close. This is safe to call if `he.waitForExit` already called it.
-					he.p.Close()
-				}()
-				return errors.Wrapf(errdefs.ErrNotFound, "exec: '%s' in task: '%s' not found", he.id, he.tid)
+	}
+	if err != nil {
+		return errors.Wrapf(errdefs.ErrFailedPrecondition, "signal %d: %v", signal, err)
+	}
+	// Bound the call here so that `ctx.Err()` below also reports the default
+	// timeout.
+	ctx, cancel := withCallTimeout(ctx)
+	defer cancel()
+	if supported && options != nil {
+		err = callWithContext(ctx, "Signal", func() error {
+			return p.Signal(options)
+		})
+	} else {
+		// legacy path before signals support OR if WCOW with signals
+		// support needs to issue a terminate.
+		err = callWithContext(ctx, "Kill", p.Kill)
+	}
+	if err != nil {
+		if ctx.Err() != nil && signal == 0x9 {
+			// The platform did not complete the SIGKILL (or terminate)
+			// before the callers deadline. Make sure the process exits
+			// anyways so that its resources are released.
+			he.sl.Lock()
+			if he.state == shimExecStateRunning || he.state == shimExecStatePaused {
+				he.killL()
 			}
-			// Unknown. Return the err from Signal/Kill
+			he.sl.Unlock()
 			return err
 		}
-		return nil
-	case shimExecStateExited:
-		return errors.Wrapf(errdefs.ErrNotFound, "exec: '%s' in task: '%s' not found", he.id, he.tid)
-	default:
-		return newExecInvalidStateError(he.tid, he.id, he.state, "kill")
+		if hcs.IsNotExist(err) && signal == 0x9 || hcs.IsOperationInvalidState(err) {
+			// If we issued a SIGKILL (or terminate) and get ERROR_NOT_FOUND
+			// or ERROR_VMCOMPUTE_INVALID_STATE `he.waitForExit` is either:
+			//
+			// 1. About to transition the state when it is signaled by the
+			// HCS and everything is fine. This was just a simple race where
+			// the SIGKILL came in before the previous signal completed.
+			//
+			// OR
+			//
+			// 2. We are stuck in `he.waitForExit` and the notification is
+			// not going to be delivered. In this case we force the exit by
+			// closing `p` and unblocking all waiters.
+			go func() {
+				// Give the HCS 1 second to finish and deliver the notification.
+				time.Sleep(1 * time.Second)
+				// Force the close. This is safe to call if `he.waitForExit` already called it.
+				p.Close()
+			}()
+			return errors.Wrapf(errdefs.ErrNotFound, "exec: '%s' in task: '%s' not found", he.id, he.tid)
+		}
+		// Unknown. Return the err from Signal/Kill
+		return err
 	}
+	return nil
 }
 
 func (he *hcsExec) ResizePty(ctx context.Context, width, height uint32) error {
-	logrus.WithFields(logrus.Fields{
-		"tid":    he.tid,
-		"eid":    he.id,
+	he.log.WithFields(logrus.Fields{
 		"width":  width,
 		"height": height,
 	}).Debug("hcsExec::ResizePty")
@@ -475,13 +602,13 @@ func (he *hcsExec) ResizePty(ctx context.Context, width, height uint32) error {
 		return errors.Wrapf(errdefs.ErrFailedPrecondition, "exec: '%s' in task: '%s' is not a tty", he.id, he.tid)
 	}
 
-	return he.p.ResizeConsole(uint16(width), uint16(height))
+	return callWithContext(ctx, "ResizeConsole", func() error {
+		return he.p.ResizeConsole(uint16(width), uint16(height))
+	})
 }
 
 func (he *hcsExec) CloseIO(ctx context.Context, stdin bool) error {
-	logrus.WithFields(logrus.Fields{
-		"tid":   he.tid,
-		"eid":   he.id,
+	he.log.WithFields(logrus.Fields{
 		"stdin": stdin,
 	}).Debug("hcsExec::CloseIO")
 
@@ -494,35 +621,73 @@ func (he *hcsExec) CloseIO(ctx context.Context, stdin bool) error {
 }
 
 func (he *hcsExec) Wait(ctx context.Context) *task.StateResponse {
-	logrus.WithFields(logrus.Fields{
-		"tid": he.tid,
-		"eid": he.id,
-	}).Debug("hcsExec::Wait")
+	he.log.Debug("hcsExec::Wait")
 
 	<-he.exited
 	return he.Status()
 }
 
-func (he *hcsExec) ForceExit(status int) {
+func (he *hcsExec) ExitReason() cow.ExitReason {
+	he.sl.Lock()
+	defer he.sl.Unlock()
+	if he.state != shimExecStateExited {
+		return cow.ExitReasonNone
+	}
+	return he.exitReason
+}
+
+func (he *hcsExec) ForceExit(status int, reason cow.ExitReason) {
 	he.sl.Lock()
 	defer he.sl.Unlock()
 	if he.state != shimExecStateExited {
 		// Avoid logging the force if we already exited gracefully
-		logrus.WithFields(logrus.Fields{
-			"tid":    he.tid,
-			"eid":    he.id,
+		he.log.WithFields(logrus.Fields{
 			"status": status,
+			"reason": reason,
 		}).Debug("hcsExec::ForceExit")
-		switch he.state {
-		case shimExecStateCreated:
-			he.exitFromCreatedL(status)
-		case shimExecStateRunning:
-			// Kill the process to unblock `he.waitForExit`
-			he.p.Kill()
+		he.forceExitL(status, reason)
+	}
+}
+
+// forceExitL forcibly exits this exec for `reason`. If the exec was never
+// started it transitions to the exited state with `status` immediately,
+// otherwise the process is killed and `he.waitForExit` completes the
+// transition. It is the callers responsibility to hold `he.sl`.
+func (he *hcsExec) forceExitL(status int, reason cow.ExitReason) {
+	switch he.state {
+	case shimExecStateCreated:
+		he.exitReason = reason
+		he.exitFromCreatedL(status)
+	case shimExecStateRunning, shimExecStatePaused:
+		// Record the reason before the process exits so that
+		// `he.waitForExit` does not replace it.
+		if he.exitReason == cow.ExitReasonNone {
+			he.exitReason = reason
 		}
+		// Kill the process to unblock `he.waitForExit`
+		he.killL()
 	}
 }
 
+// killL kills `he.p` without waiting for the platform. If the process has not
+// exited `processStopTimeout` after the kill `he.p` is closed which unblocks
+// `he.waitForExit` even if the platform never delivers the exit notification.
+// It is the callers responsibility to hold `he.sl`.
+func (he *hcsExec) killL() {
+	p := he.p
+	go p.Kill()
+	go func() {
+		t := time.NewTimer(processStopTimeout)
+		defer t.Stop()
+		select {
+		case <-he.processCtx.Done():
+		case <-t.C:
+			he.log.Warn("hcsExec::killL - process did not exit, forcing close")
+			p.Close()
+		}
+	}()
+}
+
 // exitFromCreatedL transitions the shim to the exited state from the created
 // state. It is the callers responsibility to hold `he.sl` for the durration of
 // this transition.
@@ -558,6 +723,7 @@ func (he *hcsExec) exitFromCreatedL(status int) {
 		he.exitedOnce.Do(func() {
 			close(he.exited)
 		})
+		he.notifyStateChanged()
 	}
 }
 
@@ -587,11 +753,7 @@ func (he *hcsExec) exitFromCreatedL(status int) {
 func (he *hcsExec) waitForExit() {
 	err := he.p.Wait()
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"tid":           he.tid,
-			"eid":           he.id,
-			logrus.ErrorKey: err,
-		}).Error("hcsExec::waitForExit - Failed process Wait")
+		he.log.WithError(err).Error("hcsExec::waitForExit - Failed process Wait")
 	}
 
 	// Issue the process cancellation to unblock the container wait as early as
@@ -600,17 +762,7 @@ func (he *hcsExec) waitForExit() {
 
 	code, err := he.p.ExitCode()
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"tid":           he.tid,
-			"eid":           he.id,
-			logrus.ErrorKey: err,
-		}).Error("hcsExec::waitForExit - Failed to get ExitCode")
-	} else {
-		logrus.WithFields(logrus.Fields{
-			"tid":      he.tid,
-			"eid":      he.id,
-			"exitCode": code,
-		}).Debug("hcsExec::waitForExit - Exited")
+		he.log.WithError(err).Error("hcsExec::waitForExit - Failed to get ExitCode")
 	}
 
 	// Close the process handle (we will never reference it again)
@@ -619,8 +771,29 @@ func (he *hcsExec) waitForExit() {
 	he.sl.Lock()
 	he.state = shimExecStateExited
 	he.exitStatus = uint32(code)
+	if he.exitReason == cow.ExitReasonTimedOut {
+		// The exit code is that of the kill. Report the timeout instead.
+		he.exitStatus = execTimeoutExitStatus
+	}
+	if he.exitReason == cow.ExitReasonNone && he.isWCOW && uint32(code) == statusNoMemory {
+		// A Windows process that cannot commit memory in its job object fails
+		// with `STATUS_NO_MEMORY`.
+		he.exitReason = cow.ExitReasonOutOfMemory
+	}
 	he.exitedAt = time.Now()
+	reason := he.exitReason
 	he.sl.Unlock()
+	if err == nil {
+		log := he.log.WithFields(logrus.Fields{
+			"exitCode": code,
+		})
+		if reason != cow.ExitReasonNone {
+			log.WithField("reason", reason).Warn("hcsExec::waitForExit - Exited abnormally")
+		} else {
+			log.Debug("hcsExec::waitForExit - Exited")
+		}
+	}
+	he.notifyStateChanged()
 
 	go func() {
 		// processCopyTimeout is the amount of time after process exit we allow the
@@ -634,10 +807,7 @@ func (he *hcsExec) waitForExit() {
 		he.sl.Lock()
 		defer he.sl.Unlock()
 		if he.stdout != nil || he.stderr != nil {
-			logrus.WithFields(logrus.Fields{
-				"tid": he.tid,
-				"eid": he.id,
-			}).Warn("hcsExec::waitForExit - timed out waiting for ioRelay to complete")
+			he.log.Warn("hcsExec::waitForExit - timed out waiting for ioRelay to complete")
 
 			if he.stdout != nil {
 				he.stdout.Close()
@@ -675,6 +845,28 @@ func (he *hcsExec) waitForExit() {
 	})
 }
 
+// waitForTimeout waits for `he.timeout` to elapse after the process was
+// started. If the process has not exited by then it is killed and the exec is
+// forcibly transitioned to the exited state with `execTimeoutExitStatus`.
+//
+// This MUST be called via a goroutine after a successful `Start`.
+func (he *hcsExec) waitForTimeout() {
+	t := time.NewTimer(he.timeout)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-he.processCtx.Done():
+		// Process exited first. Nothing to do.
+		return
+	}
+	he.sl.Lock()
+	defer he.sl.Unlock()
+	if he.state != shimExecStateExited {
+		he.log.WithField("timeout", he.timeout).Warn("hcsExec::waitForTimeout - timed out, killing process")
+		he.forceExitL(execTimeoutExitStatus, cow.ExitReasonTimedOut)
+	}
+}
+
 // waitForContainerExit waits for `he.c` to exit. Depending on the exec's state
 // will forcibly transition this exec to the exited state and unblock any
 // waiters.
@@ -689,14 +881,9 @@ func (he *hcsExec) waitForContainerExit() {
 	case <-cexit:
 		// Container exited first. We need to force the process into the exited
 		// state and cleanup any resources
+		reason := he.c.ExitReason()
 		he.sl.Lock()
-		switch he.state {
-		case shimExecStateCreated:
-			he.exitFromCreatedL(1)
-		case shimExecStateRunning:
-			// Kill the process to unblock `he.waitForExit`.
-			he.p.Kill()
-		}
+		he.forceExitL(1, reason)
 		he.sl.Unlock()
 	case <-he.processCtx.Done():
 		// Process exited first. This is the normal case do nothing because
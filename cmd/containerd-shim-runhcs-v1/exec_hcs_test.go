@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/cow/fake"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
+	eventstypes "github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/runtime"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	pkgerrors "github.com/pkg/errors"
+)
+
+// setupTestHcsExecWithFake creates an `hcsExec` with id `eid` for a process
+// isolated WCOW container backed by a fake compute system. If `eid` is not the
+// init exec the container is started.
+func setupTestHcsExecWithFake(t *testing.T, eid string, events publisher) (*fake.ComputeSystem, *hcsExec) {
+	c, err := (&fake.Backend{}).CreateComputeSystem(context.TODO(), t.Name(), nil)
+	if err != nil {
+		t.Fatalf("failed to create fake compute system: %v", err)
+	}
+	if eid != t.Name() {
+		if err := c.Start(); err != nil {
+			t.Fatalf("failed to start fake compute system: %v", err)
+		}
+	}
+	io, err := newNpipeIO(context.TODO(), t.Name(), eid, "", "", "", false)
+	if err != nil {
+		t.Fatalf("failed to create io: %v", err)
+	}
+	he := newHcsExec(
+		context.TODO(),
+		events,
+		t.Name(),
+		nil,
+		c,
+		eid,
+		"",
+		true,
+		&specs.Process{CommandLine: "cmd /c " + eid},
+		io).(*hcsExec)
+	return c.(*fake.ComputeSystem), he
+}
+
+func waitTestHcsExec(t *testing.T, he *hcsExec) {
+	done := make(chan struct{})
+	go func() {
+		he.Wait(context.TODO())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for exec to exit")
+	}
+}
+
+func Test_newHcsExec_Log_HasTaskAndExecID(t *testing.T) {
+	_, he := setupTestHcsExecWithFake(t, "2nd", fakePublisher)
+
+	if tid := he.log.Data[logfields.TaskID]; tid != t.Name() {
+		t.Fatalf("expected log task id: '%s', got: '%v'", t.Name(), tid)
+	}
+	if eid := he.log.Data[logfields.ExecID]; eid != "2nd" {
+		t.Fatalf("expected log exec id: '2nd', got: '%v'", eid)
+	}
+}
+
+func Test_hcsExec_Start_Init_StartsContainer(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, t.Name(), fakePublisher)
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if c.State() != fake.StateRunning {
+		t.Fatalf("expected container state: '%s', got: '%s'", fake.StateRunning, c.State())
+	}
+	if he.State() != shimExecStateRunning {
+		t.Fatalf("expected state: '%s', got: '%s'", shimExecStateRunning, he.State())
+	}
+	p := c.Process(he.Pid())
+	if p == nil {
+		t.Fatalf("expected process: %d to be created", he.Pid())
+	}
+	wpp, ok := p.Config().(*hcsschema.ProcessParameters)
+	if !ok || wpp.CommandLine != "cmd /c "+t.Name() {
+		t.Fatalf("expected WCOW process parameters for 'cmd /c %s', got: %+v", t.Name(), p.Config())
+	}
+}
+
+func Test_hcsExec_Start_StartError_Exited(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, t.Name(), fakePublisher)
+	expected := errors.New("start failed")
+	c.SetError("Start", expected)
+
+	if err := he.Start(context.TODO()); err != expected {
+		t.Fatalf("expected error: %v, got: %v", expected, err)
+	}
+	if he.State() != shimExecStateExited {
+		t.Fatalf("expected state: '%s', got: '%s'", shimExecStateExited, he.State())
+	}
+}
+
+func Test_hcsExec_Start_CreateProcessError_TerminatesContainer(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, t.Name(), fakePublisher)
+	expected := errors.New("create process failed")
+	c.SetError("CreateProcess", expected)
+
+	if err := he.Start(context.TODO()); err != expected {
+		t.Fatalf("expected error: %v, got: %v", expected, err)
+	}
+	if he.State() != shimExecStateExited {
+		t.Fatalf("expected state: '%s', got: '%s'", shimExecStateExited, he.State())
+	}
+	if !c.Exited() || !c.Closed() {
+		t.Fatal("expected container to be terminated and closed")
+	}
+}
+
+func Test_hcsExec_ProcessExit_Exited(t *testing.T) {
+	var topics []string
+	events := func(topic string, event interface{}) {
+		topics = append(topics, topic)
+		if topic == runtime.TaskExitEventTopic {
+			if e := event.(*eventstypes.TaskExit); e.ExitStatus != 3 {
+				t.Errorf("expected exit status: 3, got: %d", e.ExitStatus)
+			}
+		}
+	}
+	c, he := setupTestHcsExecWithFake(t, "exec", events)
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	p := c.Process(he.Pid())
+	p.Exit(3)
+	waitTestHcsExec(t, he)
+
+	status := he.Status()
+	if he.State() != shimExecStateExited || status.ExitStatus != 3 {
+		t.Fatalf("expected exited with status: 3, got: '%s', %d", he.State(), status.ExitStatus)
+	}
+	if !p.Closed() {
+		t.Fatal("expected process handle to be closed")
+	}
+	if len(topics) != 2 || topics[0] != runtime.TaskExecStartedEventTopic || topics[1] != runtime.TaskExitEventTopic {
+		t.Fatalf("expected topics: [%s %s], got: %v", runtime.TaskExecStartedEventTopic, runtime.TaskExitEventTopic, topics)
+	}
+}
+
+func Test_hcsExec_Kill_Running_Exited(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := he.Kill(context.TODO(), 0x9); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	waitTestHcsExec(t, he)
+
+	if !c.Process(he.Pid()).Exited() {
+		t.Fatal("expected process to be killed")
+	}
+	if he.State() != shimExecStateExited {
+		t.Fatalf("expected state: '%s', got: '%s'", shimExecStateExited, he.State())
+	}
+}
+
+func Test_hcsExec_ContainerExit_Running_Exited(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	c.Exit(errors.New("container crashed"))
+	waitTestHcsExec(t, he)
+
+	if he.State() != shimExecStateExited {
+		t.Fatalf("expected state: '%s', got: '%s'", shimExecStateExited, he.State())
+	}
+}
+
+func Test_hcsExec_ContainerExit_Created_Exited(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+
+	c.Exit(nil)
+	waitTestHcsExec(t, he)
+
+	status := he.Status()
+	if he.State() != shimExecStateExited || status.ExitStatus != 1 {
+		t.Fatalf("expected exited with status: 1, got: '%s', %d", he.State(), status.ExitStatus)
+	}
+}
+
+func Test_hcsExec_Restored_OpensProcess(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+	running, err := c.CreateProcess(&hcsschema.ProcessParameters{})
+	if err != nil {
+		t.Fatalf("failed to create fake process: %v", err)
+	}
+	he.restoredPid = running.Pid()
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if he.Pid() != running.Pid() {
+		t.Fatalf("expected pid: %d, got: %d", running.Pid(), he.Pid())
+	}
+	if len(c.Processes()) != 1 {
+		t.Fatalf("expected no new process, got: %d processes", len(c.Processes()))
+	}
+	c.Process(running.Pid()).Exit(0)
+	waitTestHcsExec(t, he)
+}
+
+func Test_hcsExec_ContainerExit_OutOfMemory_ExitReason(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if reason := he.ExitReason(); reason != cow.ExitReasonNone {
+		t.Fatalf("expected no exit reason while running, got: '%s'", reason)
+	}
+	c.ExitWithReason(cow.ExitReasonOutOfMemory, errors.New("out of memory"))
+	waitTestHcsExec(t, he)
+
+	if reason := he.ExitReason(); reason != cow.ExitReasonOutOfMemory {
+		t.Fatalf("expected exit reason: '%s', got: '%s'", cow.ExitReasonOutOfMemory, reason)
+	}
+}
+
+func Test_hcsExec_ProcessExit_StatusNoMemory_OutOfMemory(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	c.Process(he.Pid()).Exit(statusNoMemory)
+	waitTestHcsExec(t, he)
+
+	if reason := he.ExitReason(); reason != cow.ExitReasonOutOfMemory {
+		t.Fatalf("expected exit reason: '%s', got: '%s'", cow.ExitReasonOutOfMemory, reason)
+	}
+}
+
+func Test_hcsExec_ForceExit_Running_KeepsReason(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	he.ForceExit(1, cow.ExitReasonGuestCrash)
+	waitTestHcsExec(t, he)
+
+	if !c.Process(he.Pid()).Exited() {
+		t.Fatal("expected process to be killed")
+	}
+	if reason := he.ExitReason(); reason != cow.ExitReasonGuestCrash {
+		t.Fatalf("expected exit reason: '%s', got: '%s'", cow.ExitReasonGuestCrash, reason)
+	}
+}
+
+func Test_hcsExec_ForceExit_Created_Reason(t *testing.T) {
+	_, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+
+	he.ForceExit(1, cow.ExitReasonHostShutdown)
+
+	status := he.Status()
+	if he.State() != shimExecStateExited || status.ExitStatus != 1 {
+		t.Fatalf("expected exited with status: 1, got: '%s', %d", he.State(), status.ExitStatus)
+	}
+	if reason := he.ExitReason(); reason != cow.ExitReasonHostShutdown {
+		t.Fatalf("expected exit reason: '%s', got: '%s'", cow.ExitReasonHostShutdown, reason)
+	}
+}
+
+func Test_hcsExec_Start_Init_StartHung_DeadlineExceeded(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, t.Name(), fakePublisher)
+	unblock := c.Block("Start")
+	defer unblock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := he.Start(ctx); pkgerrors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("expected error: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if he.State() != shimExecStateExited {
+		t.Fatalf("expected state: '%s', got: '%s'", shimExecStateExited, he.State())
+	}
+}
+
+func Test_hcsExec_Start_CreateProcessHung_ReleasesLateProcess(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+	unblock := c.Block("CreateProcess")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := he.Start(ctx); pkgerrors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("expected error: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if he.State() != shimExecStateExited {
+		t.Fatalf("expected state: '%s', got: '%s'", shimExecStateExited, he.State())
+	}
+
+	// The platform completes the create after the caller gave up.
+	unblock()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		ps := c.Processes()
+		if len(ps) == 1 && ps[0].Exited() && ps[0].Closed() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected late process to be killed and closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_hcsExec_Kill_Hung_DeadlineExceeded_ForcesExit(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	p := c.Process(he.Pid())
+	unblock := p.Block("Kill")
+	defer unblock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := he.Kill(ctx, 0x9); pkgerrors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("expected error: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	waitTestHcsExec(t, he)
+
+	if he.State() != shimExecStateExited {
+		t.Fatalf("expected state: '%s', got: '%s'", shimExecStateExited, he.State())
+	}
+	if !p.Closed() {
+		t.Fatal("expected process handle to be closed")
+	}
+}
+
+func Test_hcsExec_Kill_Hung_NoDeadline_DefaultTimeout(t *testing.T) {
+	defer func(d time.Duration) { platformCallTimeout = d }(platformCallTimeout)
+	platformCallTimeout = 50 * time.Millisecond
+
+	c, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	unblock := c.Process(he.Pid()).Block("Kill")
+	defer unblock()
+
+	if err := he.Kill(context.Background(), 0x9); pkgerrors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("expected error: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	waitTestHcsExec(t, he)
+}
+
+func Test_hcsExec_Kill_Hung_DoesNotBlockExec(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	p := c.Process(he.Pid())
+	unblockSignal := p.Block("Signal")
+	unblockKill := p.Block("Kill")
+	killed := make(chan error, 1)
+	go func() {
+		killed <- he.Kill(context.Background(), 0x9)
+	}()
+	// Give Kill time to reach the hung platform call.
+	time.Sleep(50 * time.Millisecond)
+
+	state := make(chan shimExecState, 1)
+	go func() {
+		state <- he.State()
+	}()
+	select {
+	case s := <-state:
+		if s != shimExecStateRunning {
+			t.Fatalf("expected state: '%s', got: '%s'", shimExecStateRunning, s)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("exec is blocked by the hung Kill")
+	}
+
+	unblockSignal()
+	unblockKill()
+	if err := <-killed; err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+}
+
+func Test_hcsExec_Timeout_Running_KilledWithTimeoutStatus(t *testing.T) {
+	var exit *eventstypes.TaskExit
+	events := func(topic string, event interface{}) {
+		if topic == runtime.TaskExitEventTopic {
+			exit = event.(*eventstypes.TaskExit)
+		}
+	}
+	c, he := setupTestHcsExecWithFake(t, "exec", events)
+	he.timeout = 50 * time.Millisecond
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	waitTestHcsExec(t, he)
+
+	if !c.Process(he.Pid()).Exited() {
+		t.Fatal("expected process to be killed")
+	}
+	if status := he.Status(); status.ExitStatus != execTimeoutExitStatus {
+		t.Fatalf("expected exit status: %d, got: %d", execTimeoutExitStatus, status.ExitStatus)
+	}
+	if reason := he.ExitReason(); reason != cow.ExitReasonTimedOut {
+		t.Fatalf("expected exit reason: '%s', got: '%s'", cow.ExitReasonTimedOut, reason)
+	}
+	if exit == nil || exit.ExitStatus != execTimeoutExitStatus {
+		t.Fatalf("expected TaskExit with status: %d, got: %+v", execTimeoutExitStatus, exit)
+	}
+}
+
+func Test_hcsExec_Timeout_ExitedFirst_KeepsStatus(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, "exec", fakePublisher)
+	he.timeout = 50 * time.Millisecond
+
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	c.Process(he.Pid()).Exit(0)
+	waitTestHcsExec(t, he)
+	time.Sleep(100 * time.Millisecond)
+
+	if status := he.Status(); status.ExitStatus != 0 {
+		t.Fatalf("expected exit status: 0, got: %d", status.ExitStatus)
+	}
+	if reason := he.ExitReason(); reason != cow.ExitReasonNone {
+		t.Fatalf("expected no exit reason, got: '%s'", reason)
+	}
+}
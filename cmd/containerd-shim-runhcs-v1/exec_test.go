@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/containerd/containerd/runtime/v2/task"
 )
 
@@ -24,6 +25,7 @@ type testShimExec struct {
 	id     string
 	pid    int
 	status uint32
+	reason cow.ExitReason
 	at     time.Time
 
 	state shimExecState
@@ -58,6 +60,20 @@ func (tse *testShimExec) Kill(ctx context.Context, signal uint32) error {
 	tse.at = time.Now()
 	return nil
 }
+func (tse *testShimExec) Pause(ctx context.Context) error {
+	if tse.state != shimExecStateRunning {
+		return newExecInvalidStateError(tse.tid, tse.id, tse.state, "pause")
+	}
+	tse.state = shimExecStatePaused
+	return nil
+}
+func (tse *testShimExec) Resume(ctx context.Context) error {
+	if tse.state != shimExecStatePaused {
+		return newExecInvalidStateError(tse.tid, tse.id, tse.state, "resume")
+	}
+	tse.state = shimExecStateRunning
+	return nil
+}
 func (tse *testShimExec) ResizePty(ctx context.Context, width, height uint32) error {
 	return nil
 }
@@ -67,10 +83,14 @@ func (tse *testShimExec) CloseIO(ctx context.Context, stdin bool) error {
 func (tse *testShimExec) Wait(ctx context.Context) *task.StateResponse {
 	return tse.Status()
 }
-func (tse *testShimExec) ForceExit(status int) {
+func (tse *testShimExec) ExitReason() cow.ExitReason {
+	return tse.reason
+}
+func (tse *testShimExec) ForceExit(status int, reason cow.ExitReason) {
 	if tse.state != shimExecStateExited {
 		tse.state = shimExecStateExited
 		tse.status = 1
+		tse.reason = reason
 		tse.at = time.Now()
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Microsoft/hcsshim/internal/cow"
 	eventstypes "github.com/containerd/containerd/api/events"
 	containerd_v1_types "github.com/containerd/containerd/api/types/task"
 	"github.com/containerd/containerd/errdefs"
@@ -67,6 +68,7 @@ type wcowPodSandboxExec struct {
 	state      shimExecState
 	pid        int
 	exitStatus uint32
+	exitReason cow.ExitReason
 	exitedAt   time.Time
 
 	// exited is a wait block which waits async for the process to exit.
@@ -99,6 +101,8 @@ func (wpse *wcowPodSandboxExec) Status() *task.StateResponse {
 		s = containerd_v1_types.StatusCreated
 	case shimExecStateRunning:
 		s = containerd_v1_types.StatusRunning
+	case shimExecStatePaused:
+		s = containerd_v1_types.StatusPaused
 	case shimExecStateExited:
 		s = containerd_v1_types.StatusStopped
 	}
@@ -145,6 +149,36 @@ func (wpse *wcowPodSandboxExec) Start(ctx context.Context) error {
 	return nil
 }
 
+func (wpse *wcowPodSandboxExec) Pause(ctx context.Context) error {
+	logrus.WithFields(logrus.Fields{
+		"tid": wpse.tid,
+		"eid": wpse.tid, // Init exec ID is always same as Task ID
+	}).Debug("wcowPodSandboxExec::Pause")
+
+	wpse.sl.Lock()
+	defer wpse.sl.Unlock()
+	if wpse.state != shimExecStateRunning {
+		return newExecInvalidStateError(wpse.tid, wpse.tid, wpse.state, "pause")
+	}
+	wpse.state = shimExecStatePaused
+	return nil
+}
+
+func (wpse *wcowPodSandboxExec) Resume(ctx context.Context) error {
+	logrus.WithFields(logrus.Fields{
+		"tid": wpse.tid,
+		"eid": wpse.tid, // Init exec ID is always same as Task ID
+	}).Debug("wcowPodSandboxExec::Resume")
+
+	wpse.sl.Lock()
+	defer wpse.sl.Unlock()
+	if wpse.state != shimExecStatePaused {
+		return newExecInvalidStateError(wpse.tid, wpse.tid, wpse.state, "resume")
+	}
+	wpse.state = shimExecStateRunning
+	return nil
+}
+
 func (wpse *wcowPodSandboxExec) Kill(ctx context.Context, signal uint32) error {
 	logrus.WithFields(logrus.Fields{
 		"tid":    wpse.tid,
@@ -161,7 +195,7 @@ func (wpse *wcowPodSandboxExec) Kill(ctx context.Context, signal uint32) error {
 		wpse.exitedAt = time.Now()
 		close(wpse.exited)
 		return nil
-	case shimExecStateRunning:
+	case shimExecStateRunning, shimExecStatePaused:
 		// TODO: Should we verify that the signal would of killed the WCOW Process?
 		wpse.state = shimExecStateExited
 		wpse.exitStatus = 0
@@ -217,7 +251,13 @@ func (wpse *wcowPodSandboxExec) Wait(ctx context.Context) *task.StateResponse {
 	return wpse.Status()
 }
 
-func (wpse *wcowPodSandboxExec) ForceExit(status int) {
+func (wpse *wcowPodSandboxExec) ExitReason() cow.ExitReason {
+	wpse.sl.Lock()
+	defer wpse.sl.Unlock()
+	return wpse.exitReason
+}
+
+func (wpse *wcowPodSandboxExec) ForceExit(status int, reason cow.ExitReason) {
 	wpse.sl.Lock()
 	defer wpse.sl.Unlock()
 	if wpse.state != shimExecStateExited {
@@ -226,10 +266,12 @@ func (wpse *wcowPodSandboxExec) ForceExit(status int) {
 			"tid":    wpse.tid,
 			"eid":    wpse.tid,
 			"status": status,
+			"reason": reason,
 		}).Debug("hcsExec::ForceExit")
 
 		wpse.state = shimExecStateExited
 		wpse.exitStatus = 1
+		wpse.exitReason = reason
 		wpse.exitedAt = time.Now()
 
 		// NOTE: We do not support a non `init` exec for this "fake" init
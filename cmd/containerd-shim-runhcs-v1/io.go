@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"io"
+
+	"github.com/Microsoft/hcsshim/internal/logio"
 )
 
 // upstreamIO is an interface describing the IO to connect to above the shim.
@@ -35,3 +38,52 @@ type upstreamIO interface {
 	// return `""`.
 	Terminal() bool
 }
+
+var (
+	_ = (upstreamIO)(&logio.FileIO{})
+	_ = (upstreamIO)(&logio.BinaryIO{})
+)
+
+// newUpstreamIO creates connected upstream io for task/exec `tid,eid`
+// choosing the implementation based on the scheme of `stdout`.
+//
+// `binary://` launches a logging binary using the containerd logging protocol
+// and `file://` writes `stdout` and `stderr` to a file, see `logio`. In both
+// cases `stderr` MUST be empty or match `stdout` and is ignored if `terminal
+// == true`. Any other value is treated as the path to a named pipe.
+func newUpstreamIO(ctx context.Context, tid, eid string, stdin, stdout, stderr string, terminal bool) (_ upstreamIO, err error) {
+	u, ok, err := logio.ParseURI(stdout, stderr)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return newNpipeIO(ctx, tid, eid, stdin, stdout, stderr, terminal)
+	}
+	var sin io.ReadCloser
+	if stdin != "" {
+		sin, err = dialNpipe(stdin)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				sin.Close()
+			}
+		}()
+	}
+
+	switch u.Scheme {
+	case "binary":
+		bio, err := logio.NewBinary(ctx, tid, eid, namespaceFlag, u, stdin, sin, terminal, newNpipeLogListener(namespaceFlag, tid, eid))
+		if err != nil {
+			return nil, err
+		}
+		return bio, nil
+	default: // "file"
+		fio, err := logio.NewFile(ctx, tid, eid, u, stdin, sin, terminal)
+		if err != nil {
+			return nil, err
+		}
+		return fio, nil
+	}
+}
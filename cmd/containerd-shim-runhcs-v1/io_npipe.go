@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"net"
 	"sync"
 
 	winio "github.com/Microsoft/go-winio"
@@ -57,6 +59,21 @@ func newNpipeIO(ctx context.Context, tid, eid string, stdin, stdout, stderr stri
 	return nio, nil
 }
 
+// dialNpipe connects to the upstream named pipe at `path`.
+func dialNpipe(path string) (io.ReadCloser, error) {
+	return winio.DialPipe(path, nil)
+}
+
+// newNpipeLogListener returns a function that listens on a named pipe unique
+// to task/exec `tid,eid` in `ns` for each `stream` the logging binary will
+// connect to.
+func newNpipeLogListener(ns, tid, eid string) func(stream string) (net.Listener, error) {
+	return func(stream string) (net.Listener, error) {
+		const logPipeFmt = "\\\\.\\pipe\\containerd-shim-%s-%s-%s-%s"
+		return winio.ListenPipe(fmt.Sprintf(logPipeFmt, ns, tid, eid, stream), nil)
+	}
+}
+
 var _ = (upstreamIO)(&npipeio{})
 
 type npipeio struct {
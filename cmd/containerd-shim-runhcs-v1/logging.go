@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/logging"
+	"github.com/containerd/containerd/log"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// shimLogFile is the file in the log directory of a pod that the shim
+	// logs are written to.
+	shimLogFile = "shim.log"
+	// guestLogFile is the file in the log directory of a pod that the logs
+	// forwarded from the guest (GCS) are written to.
+	guestLogFile = "gcs.log"
+
+	defaultLogMaxSizeInMB = 10
+	defaultLogMaxFiles    = 5
+)
+
+// podLogDirectory returns the directory the logs of the pod `id` with bundle
+// `bundle` are written to.
+func podLogDirectory(opts *runhcsopts.Options, bundle, id string) string {
+	if opts.LogDirectory != "" {
+		return filepath.Join(opts.LogDirectory, id)
+	}
+	return filepath.Join(bundle, "logs")
+}
+
+// isGuestEntry returns `true` if `e` was forwarded from the guest.
+func isGuestEntry(e *logrus.Entry) bool {
+	_, ok := e.Data[logfields.VMTime]
+	return ok
+}
+
+// startFileLogging starts writing the shim and guest logs to the log directory
+// of the pod the first time it is called.
+//
+// A failure to start file logging is logged rather than failing the caller.
+func (s *service) startFileLogging(opts *runhcsopts.Options, bundle string) {
+	s.fileLoggingOnce.Do(func() {
+		dir := podLogDirectory(opts, bundle, s.tid)
+		if err := addFileLogging(logrus.StandardLogger(), dir, opts); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"path":          dir,
+				logrus.ErrorKey: err,
+			}).Error("containerd-shim: failed to start file logging")
+		}
+	})
+}
+
+// addFileLogging adds hooks to `logger` that write its entries to rotating
+// files in `dir` in the format and with the limits set in `opts`. Entries
+// forwarded from the guest are written to `guestLogFile` and all others to
+// `shimLogFile`.
+func addFileLogging(logger *logrus.Logger, dir string, opts *runhcsopts.Options) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	var formatter logrus.Formatter = &logrus.TextFormatter{
+		TimestampFormat: log.RFC3339NanoFixed,
+		FullTimestamp:   true,
+		DisableColors:   true,
+	}
+	if opts.LogFormat == runhcsopts.Options_JSON {
+		formatter = &logrus.JSONFormatter{
+			TimestampFormat: log.RFC3339NanoFixed,
+		}
+	}
+	maxSize := int64(defaultLogMaxSizeInMB)
+	if opts.LogMaxSizeInMb > 0 {
+		maxSize = int64(opts.LogMaxSizeInMb)
+	}
+	maxSize *= 1024 * 1024
+	maxFiles := defaultLogMaxFiles
+	if opts.LogMaxFiles > 0 {
+		maxFiles = int(opts.LogMaxFiles)
+	}
+
+	shimFile, err := logging.OpenRotatingFile(filepath.Join(dir, shimLogFile), maxSize, maxFiles)
+	if err != nil {
+		return err
+	}
+	guestFile, err := logging.OpenRotatingFile(filepath.Join(dir, guestLogFile), maxSize, maxFiles)
+	if err != nil {
+		shimFile.Close()
+		return err
+	}
+	logger.AddHook(logging.NewHook(shimFile, formatter, func(e *logrus.Entry) bool {
+		return !isGuestEntry(e)
+	}))
+	logger.AddHook(logging.NewHook(guestFile, formatter, isGuestEntry))
+	return nil
+}
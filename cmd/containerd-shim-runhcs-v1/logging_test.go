@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/sirupsen/logrus"
+)
+
+func Test_podLogDirectory(t *testing.T) {
+	if dir := podLogDirectory(&runhcsopts.Options{}, `C:\bundle`, "pod"); dir != `C:\bundle\logs` {
+		t.Fatalf("expected bundle log directory, got: '%s'", dir)
+	}
+	if dir := podLogDirectory(&runhcsopts.Options{LogDirectory: `C:\logs`}, `C:\bundle`, "pod"); dir != `C:\logs\pod` {
+		t.Fatalf("expected pod directory under log directory, got: '%s'", dir)
+	}
+}
+
+func Test_addFileLogging_JSON_SplitsShimAndGuest(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := logrus.New()
+	l.Out = ioutil.Discard
+	if err := addFileLogging(l, dir, &runhcsopts.Options{LogFormat: runhcsopts.Options_JSON}); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	l.WithField(logfields.TaskID, t.Name()).Info("from shim")
+	l.WithField(logfields.VMTime, "now").Info("from guest")
+
+	shim, err := ioutil.ReadFile(filepath.Join(dir, shimLogFile))
+	if err != nil {
+		t.Fatalf("failed to read shim log: %v", err)
+	}
+	if s := string(shim); !strings.Contains(s, `"msg":"from shim"`) || strings.Contains(s, "from guest") {
+		t.Fatalf("expected only the shim entry in JSON, got: '%s'", s)
+	}
+	guest, err := ioutil.ReadFile(filepath.Join(dir, guestLogFile))
+	if err != nil {
+		t.Fatalf("failed to read guest log: %v", err)
+	}
+	if s := string(guest); !strings.Contains(s, `"msg":"from guest"`) || strings.Contains(s, "from shim") {
+		t.Fatalf("expected only the guest entry in JSON, got: '%s'", s)
+	}
+}
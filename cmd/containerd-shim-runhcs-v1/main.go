@@ -10,6 +10,7 @@ import (
 	"github.com/Microsoft/go-winio/pkg/etw"
 	"github.com/Microsoft/go-winio/pkg/etwlogrus"
 	"github.com/Microsoft/go-winio/pkg/guid"
+	"github.com/Microsoft/hcsshim/internal/trace"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
@@ -83,6 +84,12 @@ func main() {
 
 	defer panicRecover()
 
+	// Export every span to the log so that it is written to ETW with the rest
+	// of the shim logs.
+	trace.RegisterExporter(trace.LogrusExporter{})
+	// Record the result and latency of every shim activity.
+	trace.RegisterExporter(shimMetrics)
+
 	provider.WriteEvent(
 		"ShimLaunched",
 		nil,
@@ -135,6 +142,7 @@ func main() {
 		startCommand,
 		deleteCommand,
 		serveCommand,
+		uvmPoolCommand,
 	}
 	app.Before = func(context *cli.Context) error {
 		if namespaceFlag = context.GlobalString("namespace"); namespaceFlag == "" {
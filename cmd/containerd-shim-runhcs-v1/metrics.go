@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/Microsoft/hcsshim/internal/metrics"
+	"github.com/Microsoft/hcsshim/internal/trace"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// metricsAddrFmt is the named pipe the metrics endpoint is served on if
+// `Options.MetricsAddress` is not set.
+const metricsAddrFmt = "\\\\.\\pipe\\containerd-shim-%s-%s-metrics"
+
+// shimMetrics are the metrics of this shim process. They are always recorded
+// but only served if a task is created with `Options.EnableMetrics`.
+var shimMetrics = newShimMetricSet(metrics.NewRegistry())
+
+// shimMetricSet are the metrics recorded by the shim.
+type shimMetricSet struct {
+	registry *metrics.Registry
+
+	// operations counts every shim activity by its result.
+	operations *metrics.Counter
+	// operationDuration is the latency of every shim activity.
+	operationDuration *metrics.Histogram
+	// uvmBootDuration is the time taken to start a utility VM.
+	uvmBootDuration *metrics.Histogram
+	// uvmResources is the number of devices of each type attached to each
+	// utility VM. It is updated on collection.
+	uvmResources *metrics.Gauge
+	// eventPublishFailures counts events that could not be published by the
+	// stage that failed.
+	eventPublishFailures *metrics.Counter
+}
+
+func newShimMetricSet(r *metrics.Registry) *shimMetricSet {
+	return &shimMetricSet{
+		registry: r,
+		operations: r.NewCounter(
+			"runhcs_shim_operations_total",
+			"Number of task and exec operations handled by the shim.",
+			"operation", "result"),
+		operationDuration: r.NewHistogram(
+			"runhcs_shim_operation_duration_seconds",
+			"Latency of task and exec operations handled by the shim.",
+			metrics.DefaultBuckets,
+			"operation"),
+		uvmBootDuration: r.NewHistogram(
+			"runhcs_uvm_boot_duration_seconds",
+			"Time taken to start a utility VM.",
+			metrics.ExponentialBuckets(0.25, 2, 10),
+			"os"),
+		uvmResources: r.NewGauge(
+			"runhcs_uvm_attached_resources",
+			"Number of devices attached to a utility VM by type.",
+			"uvm", "type"),
+		eventPublishFailures: r.NewCounter(
+			"runhcs_shim_event_publish_failures_total",
+			"Number of events that failed to publish by the stage that failed.",
+			"topic", "stage"),
+	}
+}
+
+var _ = (trace.Exporter)(&shimMetricSet{})
+
+// ExportSpan records the result and latency of the shim activity `sd`. Spans
+// that are not shim activities are ignored.
+func (sms *shimMetricSet) ExportSpan(sd *trace.SpanData) {
+	operation := strings.TrimPrefix(sd.Name, "shim::")
+	if operation == sd.Name {
+		return
+	}
+	result := "success"
+	if sd.Err != "" {
+		result = "error"
+	}
+	sms.operations.Inc(operation, result)
+	sms.operationDuration.Observe(sd.Duration().Seconds(), operation)
+}
+
+// recordHostResources sets the attached resources of every host in `hosts`.
+// Hosts that are no longer present are removed.
+func (sms *shimMetricSet) recordHostResources(hosts []*uvm.UtilityVM) {
+	sms.uvmResources.Reset()
+	for _, host := range hosts {
+		r := host.AttachedResources()
+		sms.uvmResources.Set(float64(r.VPMem), host.ID(), "vpmem")
+		sms.uvmResources.Set(float64(r.SCSI), host.ID(), "scsi")
+		sms.uvmResources.Set(float64(r.VSMB), host.ID(), "vsmb")
+		sms.uvmResources.Set(float64(r.Plan9), host.ID(), "plan9")
+	}
+}
+
+// startHost starts `host` and records the time it took to boot.
+func startHost(host *uvm.UtilityVM) error {
+	start := time.Now()
+	if err := host.Start(); err != nil {
+		return err
+	}
+	shimMetrics.uvmBootDuration.Observe(time.Since(start).Seconds(), host.OS())
+	return nil
+}
+
+// hosts returns the utility VMs of the task or pod served by this shim.
+func (s *service) hosts() []*uvm.UtilityVM {
+	var host *uvm.UtilityVM
+	switch v := s.taskOrPod.Load().(type) {
+	case *pod:
+		host = v.host
+	case *hcsTask:
+		host = v.host
+	}
+	if host == nil {
+		return nil
+	}
+	return []*uvm.UtilityVM{host}
+}
+
+// serveMetrics starts serving `shimMetrics` on `address` the first time it is
+// called. If `address` is empty the default named pipe for this shim is used.
+//
+// A failure to start the endpoint is logged rather than failing the caller.
+func (s *service) serveMetrics(address string) {
+	s.metricsOnce.Do(func() {
+		if address == "" {
+			address = fmt.Sprintf(metricsAddrFmt, namespaceFlag, s.tid)
+		}
+		log := logrus.WithField("address", address)
+		l, err := listenMetrics(address)
+		if err != nil {
+			log.WithError(err).Error("containerd-shim: failed to start metrics endpoint")
+			return
+		}
+		shimMetrics.registry.OnCollect(func() {
+			shimMetrics.recordHostResources(s.hosts())
+		})
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", shimMetrics.registry)
+		go func() {
+			if err := http.Serve(l, mux); err != nil {
+				log.WithError(err).Warn("containerd-shim: metrics endpoint stopped")
+			}
+		}()
+		log.Info("containerd-shim: serving metrics")
+	})
+}
+
+// listenMetrics listens on `address` which MUST be a named pipe or a loopback
+// 'host:port' so that metrics are only served locally.
+func listenMetrics(address string) (net.Listener, error) {
+	if strings.HasPrefix(address, `\\.\pipe\`) {
+		return winio.ListenPipe(address, nil)
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid metrics address '%s'", address)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, errors.Errorf("metrics address '%s' is not a named pipe or loopback address", address)
+	}
+	return net.Listen("tcp", address)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/metrics"
+	"github.com/Microsoft/hcsshim/internal/trace"
+	eventstypes "github.com/containerd/containerd/api/events"
+	"github.com/sirupsen/logrus"
+)
+
+func Test_shimMetricSet_ExportSpan_CountsActivities(t *testing.T) {
+	sms := newShimMetricSet(metrics.NewRegistry())
+	trace.RegisterExporter(sms)
+	defer trace.UnregisterExporter(sms)
+
+	for _, err := range []error{nil, nil, errors.New("failed")} {
+		af := logrus.Fields{"tid": t.Name()}
+		_, span := beginActivity(context.TODO(), "Start", af)
+		endActivity(span, "Start", af, err)
+	}
+	_, span := trace.StartSpan(context.TODO(), "uvm::AddSCSI")
+	span.End()
+
+	if v := sms.operations.Value("Start", "success"); v != 2 {
+		t.Fatalf("expected 2 successful Start operations, got: %v", v)
+	}
+	if v := sms.operations.Value("Start", "error"); v != 1 {
+		t.Fatalf("expected 1 failed Start operation, got: %v", v)
+	}
+	if c := sms.operationDuration.Count("Start"); c != 3 {
+		t.Fatalf("expected 3 Start latency observations, got: %d", c)
+	}
+	if c := sms.operationDuration.Count("uvm::AddSCSI"); c != 0 {
+		t.Fatalf("expected non shim spans to be ignored, got: %d", c)
+	}
+}
+
+func Test_eventPublisher_RetryExhausted_NoFallback_CountsFailures(t *testing.T) {
+	tf := &testForwarder{failures: eventMaxAttempts}
+	ep := newEventPublisher("test", tf.forward, nil)
+	topic := "/" + t.Name()
+	ep.publish(topic, &eventstypes.TaskExit{ContainerID: t.Name()})
+	if err := ep.close(10 * time.Second); err != nil {
+		t.Fatalf("should not have failed close, got: %v", err)
+	}
+	if v := shimMetrics.eventPublishFailures.Value(topic, "forward"); v != 1 {
+		t.Fatalf("expected 1 forward failure, got: %v", v)
+	}
+	if v := shimMetrics.eventPublishFailures.Value(topic, "fallback"); v != 1 {
+		t.Fatalf("expected 1 fallback failure, got: %v", v)
+	}
+}
+
+func Test_listenMetrics_NotLocal_Error(t *testing.T) {
+	for _, address := range []string{"10.0.0.1:9090", "example.com:9090", ":9090", "localhost"} {
+		if l, err := listenMetrics(address); err == nil {
+			l.Close()
+			t.Fatalf("expected error for address: '%s'", address)
+		}
+	}
+}
+
+func Test_listenMetrics_Loopback_Success(t *testing.T) {
+	l, err := listenMetrics("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	l.Close()
+}
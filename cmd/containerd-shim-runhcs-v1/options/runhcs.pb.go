@@ -10,6 +10,8 @@
 	It has these top-level messages:
 		Options
 		ProcessDetails
+		PodResources
+		GuestCrash
 */
 package options
 
@@ -88,6 +90,53 @@ func (Options_SandboxIsolation) EnumDescriptor() ([]byte, []int) {
 	return fileDescriptorRunhcs, []int{0, 1}
 }
 
+type Options_PreferredRootFSType int32
+
+const (
+	Options_UNSPECIFIED Options_PreferredRootFSType = 0
+	Options_INITRD      Options_PreferredRootFSType = 1
+	Options_VHD         Options_PreferredRootFSType = 2
+)
+
+var Options_PreferredRootFSType_name = map[int32]string{
+	0: "UNSPECIFIED",
+	1: "INITRD",
+	2: "VHD",
+}
+var Options_PreferredRootFSType_value = map[string]int32{
+	"UNSPECIFIED": 0,
+	"INITRD":      1,
+	"VHD":         2,
+}
+
+func (x Options_PreferredRootFSType) String() string {
+	return proto.EnumName(Options_PreferredRootFSType_name, int32(x))
+}
+func (Options_PreferredRootFSType) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptorRunhcs, []int{0, 2}
+}
+
+type Options_LogFormat int32
+
+const (
+	Options_TEXT Options_LogFormat = 0
+	Options_JSON Options_LogFormat = 1
+)
+
+var Options_LogFormat_name = map[int32]string{
+	0: "TEXT",
+	1: "JSON",
+}
+var Options_LogFormat_value = map[string]int32{
+	"TEXT": 0,
+	"JSON": 1,
+}
+
+func (x Options_LogFormat) String() string {
+	return proto.EnumName(Options_LogFormat_name, int32(x))
+}
+func (Options_LogFormat) EnumDescriptor() ([]byte, []int) { return fileDescriptorRunhcs, []int{0, 3} }
+
 // Options are the set of customizations that can be passed at Create time.
 type Options struct {
 	// enable debug tracing
@@ -110,6 +159,103 @@ type Options struct {
 	// boot_files_root_path is the path to the directory containing the LCOW
 	// kernel and root FS files.
 	BootFilesRootPath string `protobuf:"bytes,7,opt,name=boot_files_root_path,json=bootFilesRootPath,proto3" json:"boot_files_root_path,omitempty"`
+	// enable_metrics starts a local endpoint that serves the metrics of the
+	// shim and its utility VMs in the Prometheus text format.
+	EnableMetrics bool `protobuf:"varint,8,opt,name=enable_metrics,json=enableMetrics,proto3" json:"enable_metrics,omitempty"`
+	// metrics_address is the address of the metrics endpoint. It is either a
+	// named pipe path or a loopback 'host:port'. If omitted the endpoint is
+	// served on the named pipe
+	// '\\.\pipe\containerd-shim-<namespace>-<id>-metrics'.
+	MetricsAddress string `protobuf:"bytes,9,opt,name=metrics_address,json=metricsAddress,proto3" json:"metrics_address,omitempty"`
+	// vm_memory_size_in_mb is the memory size in MB of the utility VM.
+	VmMemorySizeInMb int32 `protobuf:"varint,10,opt,name=vm_memory_size_in_mb,json=vmMemorySizeInMb,proto3" json:"vm_memory_size_in_mb,omitempty"`
+	// vm_processor_count is the number of vCPUs of the utility VM.
+	VmProcessorCount int32 `protobuf:"varint,11,opt,name=vm_processor_count,json=vmProcessorCount,proto3" json:"vm_processor_count,omitempty"`
+	// vm_vpmem_count is the number of VPMem devices of an LCOW utility VM.
+	VmVpmemCount uint32 `protobuf:"varint,12,opt,name=vm_vpmem_count,json=vmVpmemCount,proto3" json:"vm_vpmem_count,omitempty"`
+	// vm_vpmem_size_bytes is the size of each VPMem device of an LCOW
+	// utility VM.
+	VmVpmemSizeBytes uint64 `protobuf:"varint,13,opt,name=vm_vpmem_size_bytes,json=vmVpmemSizeBytes,proto3" json:"vm_vpmem_size_bytes,omitempty"`
+	// vm_preferred_rootfs_type is the type of root file system an LCOW
+	// utility VM boots from.
+	VmPreferredRootfsType Options_PreferredRootFSType `protobuf:"varint,14,opt,name=vm_preferred_rootfs_type,json=vmPreferredRootfsType,proto3,enum=containerd.runhcs.v1.Options_PreferredRootFSType" json:"vm_preferred_rootfs_type,omitempty"`
+	// vm_kernel_boot_options are additional kernel boot options of an LCOW
+	// utility VM.
+	VmKernelBootOptions string `protobuf:"bytes,15,opt,name=vm_kernel_boot_options,json=vmKernelBootOptions,proto3" json:"vm_kernel_boot_options,omitempty"`
+	// vm_disable_overcommit backs the memory of the utility VM with physical
+	// memory rather than allowing it to be overcommitted.
+	VmDisableOvercommit bool `protobuf:"varint,16,opt,name=vm_disable_overcommit,json=vmDisableOvercommit,proto3" json:"vm_disable_overcommit,omitempty"`
+	// vm_enable_deferred_commit enables deferred commit of the overcommitted
+	// memory of the utility VM.
+	VmEnableDeferredCommit bool `protobuf:"varint,17,opt,name=vm_enable_deferred_commit,json=vmEnableDeferredCommit,proto3" json:"vm_enable_deferred_commit,omitempty"`
+	// vm_storage_qos_iops_maximum is the maximum storage IOPS of the utility
+	// VM.
+	VmStorageQosIopsMaximum int32 `protobuf:"varint,18,opt,name=vm_storage_qos_iops_maximum,json=vmStorageQosIopsMaximum,proto3" json:"vm_storage_qos_iops_maximum,omitempty"`
+	// vm_storage_qos_bandwidth_maximum is the maximum storage bandwidth in
+	// bytes per second of the utility VM.
+	VmStorageQosBandwidthMaximum int32 `protobuf:"varint,19,opt,name=vm_storage_qos_bandwidth_maximum,json=vmStorageQosBandwidthMaximum,proto3" json:"vm_storage_qos_bandwidth_maximum,omitempty"`
+	// log_directory is the directory under which the logs of each pod are
+	// written when debug_type is FILE. The logs of a pod are written to a
+	// subdirectory named after the pod id. If omitted the logs are written to
+	// the 'logs' directory in the bundle of the pod. The 'file:' output
+	// handlers of the LCOW output handler annotation write in this directory
+	// and are not allowed if it is omitted.
+	LogDirectory string `protobuf:"bytes,20,opt,name=log_directory,json=logDirectory,proto3" json:"log_directory,omitempty"`
+	// log_format is the format of the log files.
+	LogFormat Options_LogFormat `protobuf:"varint,21,opt,name=log_format,json=logFormat,proto3,enum=containerd.runhcs.v1.Options_LogFormat" json:"log_format,omitempty"`
+	// log_max_size_in_mb is the size a log file is rotated at. Defaults to
+	// 10 MB.
+	LogMaxSizeInMb int32 `protobuf:"varint,22,opt,name=log_max_size_in_mb,json=logMaxSizeInMb,proto3" json:"log_max_size_in_mb,omitempty"`
+	// log_max_files is the number of rotated log files retained in addition to
+	// the current file. Defaults to 5.
+	LogMaxFiles int32 `protobuf:"varint,23,opt,name=log_max_files,json=logMaxFiles,proto3" json:"log_max_files,omitempty"`
+	// vm_scsi_controller_count is the number of SCSI controllers of the
+	// utility VM, up to 4. Defaults to 1. WCOW utility VMs support only 1.
+	VmScsiControllerCount uint32 `protobuf:"varint,24,opt,name=vm_scsi_controller_count,json=vmScsiControllerCount,proto3" json:"vm_scsi_controller_count,omitempty"`
+	// uvm_pool_size is the number of pre-booted utility VMs kept for each
+	// profile of hypervisor isolated Linux pods. A profile is the memory size,
+	// processor count and boot files of the utility VM. If 0 pods do not use
+	// pooled utility VMs.
+	UvmPoolSize uint32 `protobuf:"varint,25,opt,name=uvm_pool_size,json=uvmPoolSize,proto3" json:"uvm_pool_size,omitempty"`
+	// uvm_pool_max_vms is the maximum number of pooled utility VMs across all
+	// profiles. If 0 there is no limit.
+	UvmPoolMaxVms uint32 `protobuf:"varint,26,opt,name=uvm_pool_max_vms,json=uvmPoolMaxVms,proto3" json:"uvm_pool_max_vms,omitempty"`
+	// uvm_pool_idle_timeout_in_seconds is the time after which the pooled
+	// utility VMs of a profile that no pod was created for are closed.
+	// Defaults to 600.
+	UvmPoolIdleTimeoutInSeconds uint32 `protobuf:"varint,27,opt,name=uvm_pool_idle_timeout_in_seconds,json=uvmPoolIdleTimeoutInSeconds,proto3" json:"uvm_pool_idle_timeout_in_seconds,omitempty"`
+	// crash_dump_root is the host directory that the guest crash dumps of
+	// hypervisor isolated pods are collected in, in a directory per pod. If
+	// empty guest crashes are not collected.
+	CrashDumpRoot string `protobuf:"bytes,28,opt,name=crash_dump_root,json=crashDumpRoot,proto3" json:"crash_dump_root,omitempty"`
+	// crash_dump_max_count is the maximum number of pod crash dump
+	// directories retained in crash_dump_root. If 0 there is no limit.
+	CrashDumpMaxCount uint32 `protobuf:"varint,29,opt,name=crash_dump_max_count,json=crashDumpMaxCount,proto3" json:"crash_dump_max_count,omitempty"`
+	// crash_dump_max_size_in_mb is the maximum total size of the crash dumps
+	// retained in crash_dump_root. If 0 there is no limit.
+	CrashDumpMaxSizeInMb uint64 `protobuf:"varint,30,opt,name=crash_dump_max_size_in_mb,json=crashDumpMaxSizeInMb,proto3" json:"crash_dump_max_size_in_mb,omitempty"`
+	// vm_console_log_directory is the directory that the serial console
+	// output of LCOW utility VMs is captured to, in a file per utility VM. If
+	// empty the console is not captured.
+	VmConsoleLogDirectory string `protobuf:"bytes,31,opt,name=vm_console_log_directory,json=vmConsoleLogDirectory,proto3" json:"vm_console_log_directory,omitempty"`
+	// vm_console_log_max_size_in_mb is the size a console log file is rotated
+	// at. Defaults to 1.
+	VmConsoleLogMaxSizeInMb uint32 `protobuf:"varint,32,opt,name=vm_console_log_max_size_in_mb,json=vmConsoleLogMaxSizeInMb,proto3" json:"vm_console_log_max_size_in_mb,omitempty"`
+	// vm_console_log_max_files is the number of rotated console log files
+	// retained in addition to the current file. Defaults to 1.
+	VmConsoleLogMaxFiles uint32 `protobuf:"varint,33,opt,name=vm_console_log_max_files,json=vmConsoleLogMaxFiles,proto3" json:"vm_console_log_max_files,omitempty"`
+	// vm_memory_maximum_in_mb is the memory size in MB that the utility VM of
+	// a pod is grown to as containers that request memory are added. If not
+	// set the memory is never grown.
+	VmMemoryMaximumInMb int32 `protobuf:"varint,34,opt,name=vm_memory_maximum_in_mb,json=vmMemoryMaximumInMb,proto3" json:"vm_memory_maximum_in_mb,omitempty"`
+	// vm_processor_maximum is ignored. The vCPUs of a running utility VM
+	// cannot be hot added, so the vCPUs of a pod are never grown.
+	VmProcessorMaximum int32 `protobuf:"varint,35,opt,name=vm_processor_maximum,json=vmProcessorMaximum,proto3" json:"vm_processor_maximum,omitempty"`
+	// vm_hvsocket_services is a JSON object of the additional HvSocket
+	// services registered on the utility VM by service ID, with their bind and
+	// connect security descriptors. For example:
+	// `{"00000400-facb-11e6-bd58-64006a7986d3":{"BindSecurityDescriptor":"D:P(A;;FA;;;SY)"}}`
+	VmHvsocketServices string `protobuf:"bytes,36,opt,name=vm_hvsocket_services,json=vmHvsocketServices,proto3" json:"vm_hvsocket_services,omitempty"`
 }
 
 func (m *Options) Reset()                    { *m = Options{} }
@@ -134,11 +280,56 @@ func (m *ProcessDetails) Reset()                    { *m = ProcessDetails{} }
 func (*ProcessDetails) ProtoMessage()               {}
 func (*ProcessDetails) Descriptor() ([]byte, []int) { return fileDescriptorRunhcs, []int{1} }
 
+// PodResources contains the processor and memory accounting of a hypervisor
+// isolated pod. This is the additional info returned in the Stats query of the
+// pod sandbox task.
+type PodResources struct {
+	// processor_capacity_millis is the processor capacity of the UVM in
+	// thousandths of a processor.
+	ProcessorCapacityMillis uint64 `protobuf:"varint,1,opt,name=processor_capacity_millis,json=processorCapacityMillis,proto3" json:"processor_capacity_millis,omitempty"`
+	// processor_requested_millis is the sum of the processor requests of the
+	// workload containers in thousandths of a processor.
+	ProcessorRequestedMillis uint64 `protobuf:"varint,2,opt,name=processor_requested_millis,json=processorRequestedMillis,proto3" json:"processor_requested_millis,omitempty"`
+	// memory_capacity_in_mb is the memory capacity of the UVM.
+	MemoryCapacityInMb uint64 `protobuf:"varint,3,opt,name=memory_capacity_in_mb,json=memoryCapacityInMb,proto3" json:"memory_capacity_in_mb,omitempty"`
+	// memory_requested_in_mb is the sum of the memory requests of the workload
+	// containers.
+	MemoryRequestedInMb uint64 `protobuf:"varint,4,opt,name=memory_requested_in_mb,json=memoryRequestedInMb,proto3" json:"memory_requested_in_mb,omitempty"`
+}
+
+func (m *PodResources) Reset()                    { *m = PodResources{} }
+func (*PodResources) ProtoMessage()               {}
+func (*PodResources) Descriptor() ([]byte, []int) { return fileDescriptorRunhcs, []int{2} }
+
+// GuestCrash is the event published on the /tasks/guestcrash topic when the
+// utility VM of a hypervisor isolated pod exits abnormally. It is published
+// before the exit event of the pod.
+type GuestCrash struct {
+	ContainerID string `protobuf:"bytes,1,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	UvmID       string `protobuf:"bytes,2,opt,name=uvm_id,json=uvmId,proto3" json:"uvm_id,omitempty"`
+	// reason is why the utility VM exited.
+	Reason string `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	// dump_path is the host path of the crash dump. It is empty if the guest
+	// did not leave a dump.
+	DumpPath string `protobuf:"bytes,4,opt,name=dump_path,json=dumpPath,proto3" json:"dump_path,omitempty"`
+	// console_tail is the end of the guest console output before the crash.
+	ConsoleTail string    `protobuf:"bytes,5,opt,name=console_tail,json=consoleTail,proto3" json:"console_tail,omitempty"`
+	CrashedAt   time.Time `protobuf:"bytes,6,opt,name=crashed_at,json=crashedAt,stdtime" json:"crashed_at"`
+}
+
+func (m *GuestCrash) Reset()                    { *m = GuestCrash{} }
+func (*GuestCrash) ProtoMessage()               {}
+func (*GuestCrash) Descriptor() ([]byte, []int) { return fileDescriptorRunhcs, []int{3} }
+
 func init() {
 	proto.RegisterType((*Options)(nil), "containerd.runhcs.v1.Options")
 	proto.RegisterType((*ProcessDetails)(nil), "containerd.runhcs.v1.ProcessDetails")
+	proto.RegisterType((*PodResources)(nil), "containerd.runhcs.v1.PodResources")
+	proto.RegisterType((*GuestCrash)(nil), "containerd.runhcs.v1.GuestCrash")
 	proto.RegisterEnum("containerd.runhcs.v1.Options_DebugType", Options_DebugType_name, Options_DebugType_value)
 	proto.RegisterEnum("containerd.runhcs.v1.Options_SandboxIsolation", Options_SandboxIsolation_name, Options_SandboxIsolation_value)
+	proto.RegisterEnum("containerd.runhcs.v1.Options_PreferredRootFSType", Options_PreferredRootFSType_name, Options_PreferredRootFSType_value)
+	proto.RegisterEnum("containerd.runhcs.v1.Options_LogFormat", Options_LogFormat_name, Options_LogFormat_value)
 }
 func (m *Options) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
@@ -199,6 +390,214 @@ func (m *Options) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintRunhcs(dAtA, i, uint64(len(m.BootFilesRootPath)))
 		i += copy(dAtA[i:], m.BootFilesRootPath)
 	}
+	if m.EnableMetrics {
+		dAtA[i] = 0x40
+		i++
+		if m.EnableMetrics {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.MetricsAddress) > 0 {
+		dAtA[i] = 0x4a
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(len(m.MetricsAddress)))
+		i += copy(dAtA[i:], m.MetricsAddress)
+	}
+	if m.VmMemorySizeInMb != 0 {
+		dAtA[i] = 0x50
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.VmMemorySizeInMb))
+	}
+	if m.VmProcessorCount != 0 {
+		dAtA[i] = 0x58
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.VmProcessorCount))
+	}
+	if m.VmVpmemCount != 0 {
+		dAtA[i] = 0x60
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.VmVpmemCount))
+	}
+	if m.VmVpmemSizeBytes != 0 {
+		dAtA[i] = 0x68
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.VmVpmemSizeBytes))
+	}
+	if m.VmPreferredRootfsType != 0 {
+		dAtA[i] = 0x70
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.VmPreferredRootfsType))
+	}
+	if len(m.VmKernelBootOptions) > 0 {
+		dAtA[i] = 0x7a
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(len(m.VmKernelBootOptions)))
+		i += copy(dAtA[i:], m.VmKernelBootOptions)
+	}
+	if m.VmDisableOvercommit {
+		dAtA[i] = 0x80
+		i++
+		dAtA[i] = 0x1
+		i++
+		if m.VmDisableOvercommit {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.VmEnableDeferredCommit {
+		dAtA[i] = 0x88
+		i++
+		dAtA[i] = 0x1
+		i++
+		if m.VmEnableDeferredCommit {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if m.VmStorageQosIopsMaximum != 0 {
+		dAtA[i] = 0x90
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.VmStorageQosIopsMaximum))
+	}
+	if m.VmStorageQosBandwidthMaximum != 0 {
+		dAtA[i] = 0x98
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.VmStorageQosBandwidthMaximum))
+	}
+	if len(m.LogDirectory) > 0 {
+		dAtA[i] = 0xa2
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(len(m.LogDirectory)))
+		i += copy(dAtA[i:], m.LogDirectory)
+	}
+	if m.LogFormat != 0 {
+		dAtA[i] = 0xa8
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.LogFormat))
+	}
+	if m.LogMaxSizeInMb != 0 {
+		dAtA[i] = 0xb0
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.LogMaxSizeInMb))
+	}
+	if m.LogMaxFiles != 0 {
+		dAtA[i] = 0xb8
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.LogMaxFiles))
+	}
+	if m.VmScsiControllerCount != 0 {
+		dAtA[i] = 0xc0
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.VmScsiControllerCount))
+	}
+	if m.UvmPoolSize != 0 {
+		dAtA[i] = 0xc8
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.UvmPoolSize))
+	}
+	if m.UvmPoolMaxVms != 0 {
+		dAtA[i] = 0xd0
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.UvmPoolMaxVms))
+	}
+	if m.UvmPoolIdleTimeoutInSeconds != 0 {
+		dAtA[i] = 0xd8
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.UvmPoolIdleTimeoutInSeconds))
+	}
+	if len(m.CrashDumpRoot) > 0 {
+		dAtA[i] = 0xe2
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(len(m.CrashDumpRoot)))
+		i += copy(dAtA[i:], m.CrashDumpRoot)
+	}
+	if m.CrashDumpMaxCount != 0 {
+		dAtA[i] = 0xe8
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.CrashDumpMaxCount))
+	}
+	if m.CrashDumpMaxSizeInMb != 0 {
+		dAtA[i] = 0xf0
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.CrashDumpMaxSizeInMb))
+	}
+	if len(m.VmConsoleLogDirectory) > 0 {
+		dAtA[i] = 0xfa
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(len(m.VmConsoleLogDirectory)))
+		i += copy(dAtA[i:], m.VmConsoleLogDirectory)
+	}
+	if m.VmConsoleLogMaxSizeInMb != 0 {
+		dAtA[i] = 0x80
+		i++
+		dAtA[i] = 0x2
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.VmConsoleLogMaxSizeInMb))
+	}
+	if m.VmConsoleLogMaxFiles != 0 {
+		dAtA[i] = 0x88
+		i++
+		dAtA[i] = 0x2
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.VmConsoleLogMaxFiles))
+	}
+	if m.VmMemoryMaximumInMb != 0 {
+		dAtA[i] = 0x90
+		i++
+		dAtA[i] = 0x2
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.VmMemoryMaximumInMb))
+	}
+	if m.VmProcessorMaximum != 0 {
+		dAtA[i] = 0x98
+		i++
+		dAtA[i] = 0x2
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.VmProcessorMaximum))
+	}
+	if len(m.VmHvsocketServices) > 0 {
+		dAtA[i] = 0xa2
+		i++
+		dAtA[i] = 0x2
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(len(m.VmHvsocketServices)))
+		i += copy(dAtA[i:], m.VmHvsocketServices)
+	}
 	return i, nil
 }
 
@@ -270,6 +669,100 @@ func (m *ProcessDetails) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *PodResources) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PodResources) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.ProcessorCapacityMillis != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.ProcessorCapacityMillis))
+	}
+	if m.ProcessorRequestedMillis != 0 {
+		dAtA[i] = 0x10
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.ProcessorRequestedMillis))
+	}
+	if m.MemoryCapacityInMb != 0 {
+		dAtA[i] = 0x18
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.MemoryCapacityInMb))
+	}
+	if m.MemoryRequestedInMb != 0 {
+		dAtA[i] = 0x20
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(m.MemoryRequestedInMb))
+	}
+	return i, nil
+}
+
+func (m *GuestCrash) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *GuestCrash) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.ContainerID) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(len(m.ContainerID)))
+		i += copy(dAtA[i:], m.ContainerID)
+	}
+	if len(m.UvmID) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(len(m.UvmID)))
+		i += copy(dAtA[i:], m.UvmID)
+	}
+	if len(m.Reason) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(len(m.Reason)))
+		i += copy(dAtA[i:], m.Reason)
+	}
+	if len(m.DumpPath) > 0 {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(len(m.DumpPath)))
+		i += copy(dAtA[i:], m.DumpPath)
+	}
+	if len(m.ConsoleTail) > 0 {
+		dAtA[i] = 0x2a
+		i++
+		i = encodeVarintRunhcs(dAtA, i, uint64(len(m.ConsoleTail)))
+		i += copy(dAtA[i:], m.ConsoleTail)
+	}
+	dAtA[i] = 0x32
+	i++
+	i = encodeVarintRunhcs(dAtA, i, uint64(types.SizeOfStdTime(m.CrashedAt)))
+	n2, err := types.StdTimeMarshalTo(m.CrashedAt, dAtA[i:])
+	if err != nil {
+		return 0, err
+	}
+	i += n2
+	return i, nil
+}
+
 func encodeVarintRunhcs(dAtA []byte, offset int, v uint64) int {
 	for v >= 1<<7 {
 		dAtA[offset] = uint8(v&0x7f | 0x80)
@@ -307,6 +800,99 @@ func (m *Options) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovRunhcs(uint64(l))
 	}
+	if m.EnableMetrics {
+		n += 2
+	}
+	l = len(m.MetricsAddress)
+	if l > 0 {
+		n += 1 + l + sovRunhcs(uint64(l))
+	}
+	if m.VmMemorySizeInMb != 0 {
+		n += 1 + sovRunhcs(uint64(m.VmMemorySizeInMb))
+	}
+	if m.VmProcessorCount != 0 {
+		n += 1 + sovRunhcs(uint64(m.VmProcessorCount))
+	}
+	if m.VmVpmemCount != 0 {
+		n += 1 + sovRunhcs(uint64(m.VmVpmemCount))
+	}
+	if m.VmVpmemSizeBytes != 0 {
+		n += 1 + sovRunhcs(uint64(m.VmVpmemSizeBytes))
+	}
+	if m.VmPreferredRootfsType != 0 {
+		n += 1 + sovRunhcs(uint64(m.VmPreferredRootfsType))
+	}
+	l = len(m.VmKernelBootOptions)
+	if l > 0 {
+		n += 1 + l + sovRunhcs(uint64(l))
+	}
+	if m.VmDisableOvercommit {
+		n += 3
+	}
+	if m.VmEnableDeferredCommit {
+		n += 3
+	}
+	if m.VmStorageQosIopsMaximum != 0 {
+		n += 2 + sovRunhcs(uint64(m.VmStorageQosIopsMaximum))
+	}
+	if m.VmStorageQosBandwidthMaximum != 0 {
+		n += 2 + sovRunhcs(uint64(m.VmStorageQosBandwidthMaximum))
+	}
+	l = len(m.LogDirectory)
+	if l > 0 {
+		n += 2 + l + sovRunhcs(uint64(l))
+	}
+	if m.LogFormat != 0 {
+		n += 2 + sovRunhcs(uint64(m.LogFormat))
+	}
+	if m.LogMaxSizeInMb != 0 {
+		n += 2 + sovRunhcs(uint64(m.LogMaxSizeInMb))
+	}
+	if m.LogMaxFiles != 0 {
+		n += 2 + sovRunhcs(uint64(m.LogMaxFiles))
+	}
+	if m.VmScsiControllerCount != 0 {
+		n += 2 + sovRunhcs(uint64(m.VmScsiControllerCount))
+	}
+	if m.UvmPoolSize != 0 {
+		n += 2 + sovRunhcs(uint64(m.UvmPoolSize))
+	}
+	if m.UvmPoolMaxVms != 0 {
+		n += 2 + sovRunhcs(uint64(m.UvmPoolMaxVms))
+	}
+	if m.UvmPoolIdleTimeoutInSeconds != 0 {
+		n += 2 + sovRunhcs(uint64(m.UvmPoolIdleTimeoutInSeconds))
+	}
+	l = len(m.CrashDumpRoot)
+	if l > 0 {
+		n += 2 + l + sovRunhcs(uint64(l))
+	}
+	if m.CrashDumpMaxCount != 0 {
+		n += 2 + sovRunhcs(uint64(m.CrashDumpMaxCount))
+	}
+	if m.CrashDumpMaxSizeInMb != 0 {
+		n += 2 + sovRunhcs(uint64(m.CrashDumpMaxSizeInMb))
+	}
+	l = len(m.VmConsoleLogDirectory)
+	if l > 0 {
+		n += 2 + l + sovRunhcs(uint64(l))
+	}
+	if m.VmConsoleLogMaxSizeInMb != 0 {
+		n += 2 + sovRunhcs(uint64(m.VmConsoleLogMaxSizeInMb))
+	}
+	if m.VmConsoleLogMaxFiles != 0 {
+		n += 2 + sovRunhcs(uint64(m.VmConsoleLogMaxFiles))
+	}
+	if m.VmMemoryMaximumInMb != 0 {
+		n += 2 + sovRunhcs(uint64(m.VmMemoryMaximumInMb))
+	}
+	if m.VmProcessorMaximum != 0 {
+		n += 2 + sovRunhcs(uint64(m.VmProcessorMaximum))
+	}
+	l = len(m.VmHvsocketServices)
+	if l > 0 {
+		n += 2 + l + sovRunhcs(uint64(l))
+	}
 	return n
 }
 
@@ -344,6 +930,52 @@ func (m *ProcessDetails) Size() (n int) {
 	return n
 }
 
+func (m *PodResources) Size() (n int) {
+	var l int
+	_ = l
+	if m.ProcessorCapacityMillis != 0 {
+		n += 1 + sovRunhcs(uint64(m.ProcessorCapacityMillis))
+	}
+	if m.ProcessorRequestedMillis != 0 {
+		n += 1 + sovRunhcs(uint64(m.ProcessorRequestedMillis))
+	}
+	if m.MemoryCapacityInMb != 0 {
+		n += 1 + sovRunhcs(uint64(m.MemoryCapacityInMb))
+	}
+	if m.MemoryRequestedInMb != 0 {
+		n += 1 + sovRunhcs(uint64(m.MemoryRequestedInMb))
+	}
+	return n
+}
+
+func (m *GuestCrash) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.ContainerID)
+	if l > 0 {
+		n += 1 + l + sovRunhcs(uint64(l))
+	}
+	l = len(m.UvmID)
+	if l > 0 {
+		n += 1 + l + sovRunhcs(uint64(l))
+	}
+	l = len(m.Reason)
+	if l > 0 {
+		n += 1 + l + sovRunhcs(uint64(l))
+	}
+	l = len(m.DumpPath)
+	if l > 0 {
+		n += 1 + l + sovRunhcs(uint64(l))
+	}
+	l = len(m.ConsoleTail)
+	if l > 0 {
+		n += 1 + l + sovRunhcs(uint64(l))
+	}
+	l = types.SizeOfStdTime(m.CrashedAt)
+	n += 1 + l + sovRunhcs(uint64(l))
+	return n
+}
+
 func sovRunhcs(x uint64) (n int) {
 	for {
 		n++
@@ -369,6 +1001,35 @@ func (this *Options) String() string {
 		`SandboxPlatform:` + fmt.Sprintf("%v", this.SandboxPlatform) + `,`,
 		`SandboxIsolation:` + fmt.Sprintf("%v", this.SandboxIsolation) + `,`,
 		`BootFilesRootPath:` + fmt.Sprintf("%v", this.BootFilesRootPath) + `,`,
+		`EnableMetrics:` + fmt.Sprintf("%v", this.EnableMetrics) + `,`,
+		`MetricsAddress:` + fmt.Sprintf("%v", this.MetricsAddress) + `,`,
+		`VmMemorySizeInMb:` + fmt.Sprintf("%v", this.VmMemorySizeInMb) + `,`,
+		`VmProcessorCount:` + fmt.Sprintf("%v", this.VmProcessorCount) + `,`,
+		`VmVpmemCount:` + fmt.Sprintf("%v", this.VmVpmemCount) + `,`,
+		`VmVpmemSizeBytes:` + fmt.Sprintf("%v", this.VmVpmemSizeBytes) + `,`,
+		`VmPreferredRootfsType:` + fmt.Sprintf("%v", this.VmPreferredRootfsType) + `,`,
+		`VmKernelBootOptions:` + fmt.Sprintf("%v", this.VmKernelBootOptions) + `,`,
+		`VmDisableOvercommit:` + fmt.Sprintf("%v", this.VmDisableOvercommit) + `,`,
+		`VmEnableDeferredCommit:` + fmt.Sprintf("%v", this.VmEnableDeferredCommit) + `,`,
+		`VmStorageQosIopsMaximum:` + fmt.Sprintf("%v", this.VmStorageQosIopsMaximum) + `,`,
+		`VmStorageQosBandwidthMaximum:` + fmt.Sprintf("%v", this.VmStorageQosBandwidthMaximum) + `,`,
+		`LogDirectory:` + fmt.Sprintf("%v", this.LogDirectory) + `,`,
+		`LogFormat:` + fmt.Sprintf("%v", this.LogFormat) + `,`,
+		`LogMaxSizeInMb:` + fmt.Sprintf("%v", this.LogMaxSizeInMb) + `,`,
+		`LogMaxFiles:` + fmt.Sprintf("%v", this.LogMaxFiles) + `,`,
+		`VmScsiControllerCount:` + fmt.Sprintf("%v", this.VmScsiControllerCount) + `,`,
+		`UvmPoolSize:` + fmt.Sprintf("%v", this.UvmPoolSize) + `,`,
+		`UvmPoolMaxVms:` + fmt.Sprintf("%v", this.UvmPoolMaxVms) + `,`,
+		`UvmPoolIdleTimeoutInSeconds:` + fmt.Sprintf("%v", this.UvmPoolIdleTimeoutInSeconds) + `,`,
+		`CrashDumpRoot:` + fmt.Sprintf("%v", this.CrashDumpRoot) + `,`,
+		`CrashDumpMaxCount:` + fmt.Sprintf("%v", this.CrashDumpMaxCount) + `,`,
+		`CrashDumpMaxSizeInMb:` + fmt.Sprintf("%v", this.CrashDumpMaxSizeInMb) + `,`,
+		`VmConsoleLogDirectory:` + fmt.Sprintf("%v", this.VmConsoleLogDirectory) + `,`,
+		`VmConsoleLogMaxSizeInMb:` + fmt.Sprintf("%v", this.VmConsoleLogMaxSizeInMb) + `,`,
+		`VmConsoleLogMaxFiles:` + fmt.Sprintf("%v", this.VmConsoleLogMaxFiles) + `,`,
+		`VmMemoryMaximumInMb:` + fmt.Sprintf("%v", this.VmMemoryMaximumInMb) + `,`,
+		`VmProcessorMaximum:` + fmt.Sprintf("%v", this.VmProcessorMaximum) + `,`,
+		`VmHvsocketServices:` + fmt.Sprintf("%v", this.VmHvsocketServices) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -391,12 +1052,40 @@ func (this *ProcessDetails) String() string {
 	}, "")
 	return s
 }
-func valueToStringRunhcs(v interface{}) string {
-	rv := reflect.ValueOf(v)
-	if rv.IsNil() {
+func (this *PodResources) String() string {
+	if this == nil {
 		return "nil"
 	}
-	pv := reflect.Indirect(rv).Interface()
+	s := strings.Join([]string{`&PodResources{`,
+		`ProcessorCapacityMillis:` + fmt.Sprintf("%v", this.ProcessorCapacityMillis) + `,`,
+		`ProcessorRequestedMillis:` + fmt.Sprintf("%v", this.ProcessorRequestedMillis) + `,`,
+		`MemoryCapacityInMb:` + fmt.Sprintf("%v", this.MemoryCapacityInMb) + `,`,
+		`MemoryRequestedInMb:` + fmt.Sprintf("%v", this.MemoryRequestedInMb) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *GuestCrash) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&GuestCrash{`,
+		`ContainerID:` + fmt.Sprintf("%v", this.ContainerID) + `,`,
+		`UvmID:` + fmt.Sprintf("%v", this.UvmID) + `,`,
+		`Reason:` + fmt.Sprintf("%v", this.Reason) + `,`,
+		`DumpPath:` + fmt.Sprintf("%v", this.DumpPath) + `,`,
+		`ConsoleTail:` + fmt.Sprintf("%v", this.ConsoleTail) + `,`,
+		`CrashedAt:` + strings.Replace(strings.Replace(this.CrashedAt.String(), "Timestamp", "google_protobuf1.Timestamp", 1), `&`, ``, 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringRunhcs(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
 	return fmt.Sprintf("*%v", pv)
 }
 func (m *Options) Unmarshal(dAtA []byte) error {
@@ -602,59 +1291,29 @@ func (m *Options) Unmarshal(dAtA []byte) error {
 			}
 			m.BootFilesRootPath = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRunhcs(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthRunhcs
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *ProcessDetails) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRunhcs
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EnableMetrics", wireType)
 			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: ProcessDetails: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ProcessDetails: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+			m.EnableMetrics = bool(v != 0)
+		case 9:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ImageName", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field MetricsAddress", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -679,13 +1338,13 @@ func (m *ProcessDetails) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ImageName = string(dAtA[iNdEx:postIndex])
+			m.MetricsAddress = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field CreatedAt", wireType)
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VmMemorySizeInMb", wireType)
 			}
-			var msglen int
+			m.VmMemorySizeInMb = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRunhcs
@@ -695,27 +1354,54 @@ func (m *ProcessDetails) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				m.VmMemorySizeInMb |= (int32(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
-				return ErrInvalidLengthRunhcs
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VmProcessorCount", wireType)
 			}
-			postIndex := iNdEx + msglen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
+			m.VmProcessorCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.VmProcessorCount |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			if err := types.StdTimeUnmarshal(&m.CreatedAt, dAtA[iNdEx:postIndex]); err != nil {
-				return err
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VmVpmemCount", wireType)
 			}
-			iNdEx = postIndex
-		case 3:
+			m.VmVpmemCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.VmVpmemCount |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 13:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field KernelTime_100Ns", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field VmVpmemSizeBytes", wireType)
 			}
-			m.KernelTime_100Ns = 0
+			m.VmVpmemSizeBytes = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRunhcs
@@ -725,16 +1411,16 @@ func (m *ProcessDetails) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.KernelTime_100Ns |= (uint64(b) & 0x7F) << shift
+				m.VmVpmemSizeBytes |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 4:
+		case 14:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MemoryCommitBytes", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field VmPreferredRootfsType", wireType)
 			}
-			m.MemoryCommitBytes = 0
+			m.VmPreferredRootfsType = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRunhcs
@@ -744,16 +1430,45 @@ func (m *ProcessDetails) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.MemoryCommitBytes |= (uint64(b) & 0x7F) << shift
+				m.VmPreferredRootfsType |= (Options_PreferredRootFSType(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 5:
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VmKernelBootOptions", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.VmKernelBootOptions = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 16:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MemoryWorkingSetPrivateBytes", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field VmDisableOvercommit", wireType)
 			}
-			m.MemoryWorkingSetPrivateBytes = 0
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRunhcs
@@ -763,16 +1478,17 @@ func (m *ProcessDetails) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.MemoryWorkingSetPrivateBytes |= (uint64(b) & 0x7F) << shift
+				v |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 6:
+			m.VmDisableOvercommit = bool(v != 0)
+		case 17:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MemoryWorkingSetSharedBytes", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field VmEnableDeferredCommit", wireType)
 			}
-			m.MemoryWorkingSetSharedBytes = 0
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRunhcs
@@ -782,16 +1498,17 @@ func (m *ProcessDetails) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.MemoryWorkingSetSharedBytes |= (uint64(b) & 0x7F) << shift
+				v |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 7:
+			m.VmEnableDeferredCommit = bool(v != 0)
+		case 18:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ProcessID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field VmStorageQosIopsMaximum", wireType)
 			}
-			m.ProcessID = 0
+			m.VmStorageQosIopsMaximum = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRunhcs
@@ -801,16 +1518,16 @@ func (m *ProcessDetails) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.ProcessID |= (uint32(b) & 0x7F) << shift
+				m.VmStorageQosIopsMaximum |= (int32(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 8:
+		case 19:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field UserTime_100Ns", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field VmStorageQosBandwidthMaximum", wireType)
 			}
-			m.UserTime_100Ns = 0
+			m.VmStorageQosBandwidthMaximum = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRunhcs
@@ -820,14 +1537,14 @@ func (m *ProcessDetails) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.UserTime_100Ns |= (uint64(b) & 0x7F) << shift
+				m.VmStorageQosBandwidthMaximum |= (int32(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 9:
+		case 20:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ExecID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field LogDirectory", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -852,7 +1569,944 @@ func (m *ProcessDetails) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ExecID = string(dAtA[iNdEx:postIndex])
+			m.LogDirectory = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 21:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LogFormat", wireType)
+			}
+			m.LogFormat = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LogFormat |= (Options_LogFormat(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 22:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LogMaxSizeInMb", wireType)
+			}
+			m.LogMaxSizeInMb = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LogMaxSizeInMb |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 23:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LogMaxFiles", wireType)
+			}
+			m.LogMaxFiles = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LogMaxFiles |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 24:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VmScsiControllerCount", wireType)
+			}
+			m.VmScsiControllerCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.VmScsiControllerCount |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 25:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UvmPoolSize", wireType)
+			}
+			m.UvmPoolSize = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.UvmPoolSize |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 26:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UvmPoolMaxVms", wireType)
+			}
+			m.UvmPoolMaxVms = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.UvmPoolMaxVms |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 27:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UvmPoolIdleTimeoutInSeconds", wireType)
+			}
+			m.UvmPoolIdleTimeoutInSeconds = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.UvmPoolIdleTimeoutInSeconds |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 28:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CrashDumpRoot", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.CrashDumpRoot = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 29:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CrashDumpMaxCount", wireType)
+			}
+			m.CrashDumpMaxCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CrashDumpMaxCount |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 30:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CrashDumpMaxSizeInMb", wireType)
+			}
+			m.CrashDumpMaxSizeInMb = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.CrashDumpMaxSizeInMb |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 31:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VmConsoleLogDirectory", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.VmConsoleLogDirectory = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 32:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VmConsoleLogMaxSizeInMb", wireType)
+			}
+			m.VmConsoleLogMaxSizeInMb = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.VmConsoleLogMaxSizeInMb |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 33:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VmConsoleLogMaxFiles", wireType)
+			}
+			m.VmConsoleLogMaxFiles = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.VmConsoleLogMaxFiles |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 34:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VmMemoryMaximumInMb", wireType)
+			}
+			m.VmMemoryMaximumInMb = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.VmMemoryMaximumInMb |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 35:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VmProcessorMaximum", wireType)
+			}
+			m.VmProcessorMaximum = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.VmProcessorMaximum |= (int32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 36:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field VmHvsocketServices", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.VmHvsocketServices = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRunhcs(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ProcessDetails) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRunhcs
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ProcessDetails: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ProcessDetails: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ImageName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ImageName = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CreatedAt", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := types.StdTimeUnmarshal(&m.CreatedAt, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field KernelTime_100Ns", wireType)
+			}
+			m.KernelTime_100Ns = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.KernelTime_100Ns |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MemoryCommitBytes", wireType)
+			}
+			m.MemoryCommitBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MemoryCommitBytes |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MemoryWorkingSetPrivateBytes", wireType)
+			}
+			m.MemoryWorkingSetPrivateBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MemoryWorkingSetPrivateBytes |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MemoryWorkingSetSharedBytes", wireType)
+			}
+			m.MemoryWorkingSetSharedBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MemoryWorkingSetSharedBytes |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProcessID", wireType)
+			}
+			m.ProcessID = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ProcessID |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UserTime_100Ns", wireType)
+			}
+			m.UserTime_100Ns = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.UserTime_100Ns |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ExecID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ExecID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRunhcs(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *PodResources) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRunhcs
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: PodResources: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: PodResources: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProcessorCapacityMillis", wireType)
+			}
+			m.ProcessorCapacityMillis = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ProcessorCapacityMillis |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProcessorRequestedMillis", wireType)
+			}
+			m.ProcessorRequestedMillis = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.ProcessorRequestedMillis |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MemoryCapacityInMb", wireType)
+			}
+			m.MemoryCapacityInMb = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MemoryCapacityInMb |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MemoryRequestedInMb", wireType)
+			}
+			m.MemoryRequestedInMb = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MemoryRequestedInMb |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRunhcs(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *GuestCrash) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRunhcs
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: GuestCrash: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: GuestCrash: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ContainerID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ContainerID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field UvmID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.UvmID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Reason = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DumpPath", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DumpPath = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ConsoleTail", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ConsoleTail = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field CrashedAt", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRunhcs
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRunhcs
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := types.StdTimeUnmarshal(&m.CrashedAt, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -985,49 +2639,109 @@ func init() {
 }
 
 var fileDescriptorRunhcs = []byte{
-	// 704 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x54, 0x4d, 0x6f, 0xda, 0x48,
-	0x18, 0xc6, 0xe1, 0xd3, 0x6f, 0x96, 0xc4, 0x99, 0xe5, 0x80, 0xb2, 0xbb, 0x80, 0xc8, 0x21, 0x89,
-	0x76, 0x63, 0x43, 0xf6, 0xd8, 0x53, 0x09, 0xa0, 0xba, 0x6a, 0x83, 0x65, 0xa2, 0xa6, 0x1f, 0x07,
-	0xcb, 0xd8, 0x83, 0xb1, 0x82, 0x3d, 0xd6, 0xcc, 0x90, 0x86, 0x5b, 0x7f, 0x42, 0x7f, 0x55, 0x95,
-	0x63, 0x8f, 0x95, 0x2a, 0xa5, 0x0d, 0xbf, 0xa4, 0x9a, 0xb1, 0x49, 0xd4, 0x28, 0xea, 0xa5, 0x27,
-	0xc6, 0xcf, 0xf3, 0xbc, 0xcf, 0xfb, 0x29, 0x60, 0x14, 0x84, 0x7c, 0xb6, 0x98, 0xe8, 0x1e, 0x89,
-	0x8c, 0x97, 0xa1, 0x47, 0x09, 0x23, 0x53, 0x6e, 0xcc, 0x3c, 0xc6, 0x66, 0x61, 0x64, 0x78, 0x91,
-	0x6f, 0x78, 0x24, 0xe6, 0x6e, 0x18, 0x63, 0xea, 0x1f, 0x09, 0xec, 0x88, 0x2e, 0xe2, 0x99, 0xc7,
-	0x8e, 0x2e, 0xbb, 0x06, 0x49, 0x78, 0x48, 0x62, 0x66, 0xa4, 0x88, 0x9e, 0x50, 0xc2, 0x09, 0xaa,
-	0xdd, 0xeb, 0xf5, 0x8c, 0xb8, 0xec, 0xee, 0xd6, 0x02, 0x12, 0x10, 0x29, 0x30, 0xc4, 0x2b, 0xd5,
-	0xee, 0x36, 0x03, 0x42, 0x82, 0x39, 0x36, 0xe4, 0xd7, 0x64, 0x31, 0x35, 0x78, 0x18, 0x61, 0xc6,
-	0xdd, 0x28, 0x49, 0x05, 0xed, 0x4f, 0x79, 0x28, 0x8f, 0xd2, 0x2c, 0xa8, 0x06, 0x45, 0x1f, 0x4f,
-	0x16, 0x41, 0x5d, 0x69, 0x29, 0x07, 0x15, 0x3b, 0xfd, 0x40, 0x43, 0x00, 0xf9, 0x70, 0xf8, 0x32,
-	0xc1, 0xf5, 0x8d, 0x96, 0x72, 0xb0, 0x75, 0xbc, 0xaf, 0x3f, 0x56, 0x83, 0x9e, 0x19, 0xe9, 0x7d,
-	0xa1, 0x3f, 0x5b, 0x26, 0xd8, 0x56, 0xfd, 0xf5, 0x13, 0xed, 0x41, 0x95, 0xe2, 0x20, 0x64, 0x9c,
-	0x2e, 0x1d, 0x4a, 0x08, 0xaf, 0xe7, 0x5b, 0xca, 0x81, 0x6a, 0xff, 0xb1, 0x06, 0x6d, 0x42, 0xb8,
-	0x10, 0x31, 0x37, 0xf6, 0x27, 0xe4, 0xca, 0x09, 0x23, 0x37, 0xc0, 0xf5, 0x42, 0x2a, 0xca, 0x40,
-	0x53, 0x60, 0xe8, 0x10, 0xb4, 0xb5, 0x28, 0x99, 0xbb, 0x7c, 0x4a, 0x68, 0x54, 0x2f, 0x4a, 0xdd,
-	0x76, 0x86, 0x5b, 0x19, 0x8c, 0xde, 0xc1, 0xce, 0x9d, 0x1f, 0x23, 0x73, 0x57, 0xd4, 0x57, 0x2f,
-	0xc9, 0x1e, 0xf4, 0x5f, 0xf7, 0x30, 0xce, 0x32, 0xae, 0xa3, 0xec, 0x75, 0xce, 0x3b, 0x04, 0x19,
-	0x50, 0x9b, 0x10, 0xc2, 0x9d, 0x69, 0x38, 0xc7, 0x4c, 0xf6, 0xe4, 0x24, 0x2e, 0x9f, 0xd5, 0xcb,
-	0xb2, 0x96, 0x1d, 0xc1, 0x0d, 0x05, 0x25, 0x3a, 0xb3, 0x5c, 0x3e, 0x6b, 0x1f, 0x82, 0x7a, 0x37,
-	0x1a, 0xa4, 0x42, 0xf1, 0xd4, 0x32, 0xad, 0x81, 0x96, 0x43, 0x15, 0x28, 0x0c, 0xcd, 0x17, 0x03,
-	0x4d, 0x41, 0x65, 0xc8, 0x0f, 0xce, 0xce, 0xb5, 0x8d, 0xb6, 0x01, 0xda, 0xc3, 0x0a, 0xd0, 0x26,
-	0x94, 0x2d, 0x7b, 0x74, 0x32, 0x18, 0x8f, 0xb5, 0x1c, 0xda, 0x02, 0x78, 0xf6, 0xc6, 0x1a, 0xd8,
-	0xaf, 0xcc, 0xf1, 0xc8, 0xd6, 0x94, 0xf6, 0xd7, 0x3c, 0x6c, 0x59, 0x94, 0x78, 0x98, 0xb1, 0x3e,
-	0xe6, 0x6e, 0x38, 0x67, 0xe8, 0x1f, 0x00, 0x39, 0x44, 0x27, 0x76, 0x23, 0x2c, 0x97, 0xaa, 0xda,
-	0xaa, 0x44, 0x4e, 0xdd, 0x08, 0xa3, 0x13, 0x00, 0x8f, 0x62, 0x97, 0x63, 0xdf, 0x71, 0xb9, 0x5c,
-	0xec, 0xe6, 0xf1, 0xae, 0x9e, 0x1e, 0x8c, 0xbe, 0x3e, 0x18, 0xfd, 0x6c, 0x7d, 0x30, 0xbd, 0xca,
-	0xf5, 0x4d, 0x33, 0xf7, 0xf1, 0x5b, 0x53, 0xb1, 0xd5, 0x2c, 0xee, 0x29, 0x47, 0xff, 0x02, 0xba,
-	0xc0, 0x34, 0xc6, 0x73, 0x47, 0x5c, 0x96, 0xd3, 0xed, 0x74, 0x9c, 0x98, 0xc9, 0xd5, 0x16, 0xec,
-	0xed, 0x94, 0x11, 0x0e, 0xdd, 0x4e, 0xe7, 0x94, 0x21, 0x1d, 0xfe, 0x8c, 0x70, 0x44, 0xe8, 0xd2,
-	0xf1, 0x48, 0x14, 0x85, 0xdc, 0x99, 0x2c, 0x39, 0x66, 0x72, 0xc7, 0x05, 0x7b, 0x27, 0xa5, 0x4e,
-	0x24, 0xd3, 0x13, 0x04, 0x1a, 0x42, 0x2b, 0xd3, 0xbf, 0x27, 0xf4, 0x22, 0x8c, 0x03, 0x87, 0x61,
-	0xee, 0x24, 0x34, 0xbc, 0x74, 0x39, 0xce, 0x82, 0x8b, 0x32, 0xf8, 0xef, 0x54, 0x77, 0x9e, 0xca,
-	0xc6, 0x98, 0x5b, 0xa9, 0x28, 0xf5, 0xe9, 0x43, 0xf3, 0x11, 0x1f, 0x36, 0x73, 0x29, 0xf6, 0x33,
-	0x9b, 0x92, 0xb4, 0xf9, 0xeb, 0xa1, 0xcd, 0x58, 0x6a, 0x52, 0x97, 0xff, 0x00, 0x92, 0x74, 0xc0,
-	0x4e, 0xe8, 0xcb, 0x25, 0x57, 0x7b, 0xd5, 0xd5, 0x4d, 0x53, 0xcd, 0xc6, 0x6e, 0xf6, 0x6d, 0x35,
-	0x13, 0x98, 0x3e, 0xda, 0x07, 0x6d, 0xc1, 0x30, 0xfd, 0x69, 0x2c, 0x15, 0x99, 0xa4, 0x2a, 0xf0,
-	0xfb, 0xa1, 0xec, 0x41, 0x19, 0x5f, 0x61, 0x4f, 0x78, 0xaa, 0x62, 0x45, 0x3d, 0x58, 0xdd, 0x34,
-	0x4b, 0x83, 0x2b, 0xec, 0x99, 0x7d, 0xbb, 0x24, 0x28, 0xd3, 0xef, 0xf9, 0xd7, 0xb7, 0x8d, 0xdc,
-	0x97, 0xdb, 0x46, 0xee, 0xc3, 0xaa, 0xa1, 0x5c, 0xaf, 0x1a, 0xca, 0xe7, 0x55, 0x43, 0xf9, 0xbe,
-	0x6a, 0x28, 0x6f, 0x9f, 0xff, 0xfe, 0xdf, 0xcb, 0x93, 0xec, 0xf7, 0x75, 0x6e, 0x52, 0x92, 0x7b,
-	0xff, 0xff, 0x47, 0x00, 0x00, 0x00, 0xff, 0xff, 0xa3, 0x9a, 0x54, 0x17, 0xb5, 0x04, 0x00, 0x00,
+	// 1655 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x57, 0xdd, 0x52, 0x1b, 0xc9,
+	0x15, 0x46, 0x36, 0x7f, 0x3a, 0x20, 0x10, 0x8d, 0x80, 0x31, 0xd8, 0x48, 0x2b, 0x6f, 0x62, 0x36,
+	0x59, 0x4b, 0x60, 0xa7, 0xb2, 0x95, 0xec, 0x56, 0xaa, 0x8c, 0x24, 0xe2, 0xd9, 0x58, 0xa0, 0x8c,
+	0x58, 0xef, 0x26, 0xb9, 0xe8, 0x1a, 0xcd, 0x34, 0xd2, 0xc4, 0xd3, 0xd3, 0xda, 0xe9, 0xd6, 0x2c,
+	0xec, 0x55, 0x1e, 0x21, 0x0f, 0x91, 0x87, 0xf1, 0x65, 0x2e, 0x53, 0x95, 0x2a, 0x92, 0xe5, 0x09,
+	0xf2, 0x06, 0x49, 0xf5, 0xe9, 0x1e, 0x21, 0x53, 0x2e, 0x57, 0x52, 0xb9, 0x42, 0x73, 0xce, 0xf7,
+	0x9d, 0x3e, 0x7d, 0xfe, 0xfa, 0x00, 0x67, 0xc3, 0x48, 0x8d, 0x26, 0x83, 0x46, 0x20, 0x78, 0xb3,
+	0x1b, 0x05, 0xa9, 0x90, 0xe2, 0x42, 0x35, 0x47, 0x81, 0x94, 0xa3, 0x88, 0x37, 0x03, 0x1e, 0x36,
+	0x03, 0x91, 0x28, 0x3f, 0x4a, 0x58, 0x1a, 0x3e, 0xd5, 0xb2, 0xa7, 0xe9, 0x24, 0x19, 0x05, 0xf2,
+	0x69, 0x76, 0xd4, 0x14, 0x63, 0x15, 0x89, 0x44, 0x36, 0x8d, 0xa4, 0x31, 0x4e, 0x85, 0x12, 0xa4,
+	0x72, 0x8b, 0x6f, 0x58, 0x45, 0x76, 0xb4, 0x5b, 0x19, 0x8a, 0xa1, 0x40, 0x40, 0x53, 0xff, 0x32,
+	0xd8, 0xdd, 0xea, 0x50, 0x88, 0x61, 0xcc, 0x9a, 0xf8, 0x35, 0x98, 0x5c, 0x34, 0x55, 0xc4, 0x99,
+	0x54, 0x3e, 0x1f, 0x1b, 0x40, 0xfd, 0x2f, 0x65, 0x58, 0x3a, 0x33, 0xa7, 0x90, 0x0a, 0x2c, 0x84,
+	0x6c, 0x30, 0x19, 0x3a, 0x85, 0x5a, 0xe1, 0x60, 0xd9, 0x33, 0x1f, 0xe4, 0x04, 0x00, 0x7f, 0x50,
+	0x75, 0x35, 0x66, 0xce, 0xbd, 0x5a, 0xe1, 0x60, 0xed, 0xd9, 0x93, 0xc6, 0xfb, 0x7c, 0x68, 0x58,
+	0x43, 0x8d, 0xb6, 0xc6, 0x9f, 0x5f, 0x8d, 0x99, 0x57, 0x0c, 0xf3, 0x9f, 0xe4, 0x31, 0x94, 0x52,
+	0x36, 0x8c, 0xa4, 0x4a, 0xaf, 0x68, 0x2a, 0x84, 0x72, 0xee, 0xd7, 0x0a, 0x07, 0x45, 0x6f, 0x35,
+	0x17, 0x7a, 0x42, 0x28, 0x0d, 0x92, 0x7e, 0x12, 0x0e, 0xc4, 0x25, 0x8d, 0xb8, 0x3f, 0x64, 0xce,
+	0xbc, 0x01, 0x59, 0xa1, 0xab, 0x65, 0xe4, 0x13, 0x28, 0xe7, 0xa0, 0x71, 0xec, 0xab, 0x0b, 0x91,
+	0x72, 0x67, 0x01, 0x71, 0xeb, 0x56, 0xde, 0xb3, 0x62, 0xf2, 0x07, 0xd8, 0x98, 0xda, 0x93, 0x22,
+	0xf6, 0xb5, 0x7f, 0xce, 0x22, 0xde, 0xa1, 0xf1, 0xe1, 0x3b, 0xf4, 0xed, 0x89, 0x39, 0xcb, 0x2b,
+	0xcb, 0x3b, 0x12, 0xd2, 0x84, 0xca, 0x40, 0x08, 0x45, 0x2f, 0xa2, 0x98, 0x49, 0xbc, 0x13, 0x1d,
+	0xfb, 0x6a, 0xe4, 0x2c, 0xa1, 0x2f, 0x1b, 0x5a, 0x77, 0xa2, 0x55, 0xfa, 0x66, 0x3d, 0x5f, 0x8d,
+	0xc8, 0x8f, 0x60, 0x8d, 0x25, 0xfe, 0x20, 0x66, 0x94, 0x33, 0x95, 0x46, 0x81, 0x74, 0x96, 0x31,
+	0xd2, 0x25, 0x23, 0xed, 0x1a, 0x21, 0x79, 0x02, 0xeb, 0x56, 0x4f, 0xfd, 0x30, 0x4c, 0x99, 0x94,
+	0x4e, 0x11, 0x4d, 0xae, 0x59, 0xf1, 0x0b, 0x23, 0x25, 0x0d, 0xa8, 0x64, 0x9c, 0x72, 0xc6, 0x45,
+	0x7a, 0x45, 0x65, 0xf4, 0x3d, 0xa3, 0x51, 0x42, 0xf9, 0xc0, 0x81, 0x5a, 0xe1, 0x60, 0xc1, 0x2b,
+	0x67, 0xbc, 0x8b, 0xaa, 0x7e, 0xf4, 0x3d, 0x73, 0x93, 0xee, 0x80, 0x7c, 0x0a, 0x24, 0xe3, 0x74,
+	0x9c, 0x8a, 0x80, 0x49, 0x29, 0x52, 0x1a, 0x88, 0x49, 0xa2, 0x9c, 0x95, 0x1c, 0xdd, 0xcb, 0x15,
+	0x2d, 0x2d, 0x27, 0x1f, 0xc3, 0x5a, 0xc6, 0x69, 0x36, 0xe6, 0x8c, 0x5b, 0xe4, 0x6a, 0xad, 0x70,
+	0x50, 0xf2, 0x56, 0x33, 0xfe, 0x5a, 0x0b, 0x0d, 0xea, 0x29, 0x6c, 0x4e, 0x51, 0xe8, 0xc2, 0xe0,
+	0x4a, 0x31, 0xe9, 0x94, 0x6a, 0x85, 0x83, 0x79, 0xaf, 0x6c, 0xa1, 0xda, 0x83, 0x63, 0x2d, 0x27,
+	0x7f, 0x04, 0x07, 0x5d, 0x60, 0x17, 0x2c, 0x4d, 0x59, 0x88, 0x51, 0xbb, 0x90, 0xa6, 0xb6, 0xd6,
+	0x30, 0x2f, 0x47, 0x1f, 0xce, 0x4b, 0x2f, 0xa7, 0xea, 0xa8, 0x9e, 0xf4, 0xb1, 0xca, 0xb6, 0x32,
+	0xfe, 0x8e, 0xf8, 0x42, 0x6a, 0x31, 0x79, 0x0e, 0xdb, 0x19, 0xa7, 0x6f, 0x58, 0x9a, 0xb0, 0x98,
+	0x62, 0xa6, 0x6c, 0x3f, 0x39, 0xeb, 0x18, 0xce, 0xcd, 0x8c, 0xff, 0x06, 0x95, 0xc7, 0x42, 0xa8,
+	0xbc, 0x09, 0x9e, 0xc1, 0x56, 0xc6, 0x69, 0x18, 0x49, 0xcc, 0x93, 0xc8, 0x58, 0x1a, 0x08, 0xce,
+	0x23, 0xe5, 0x94, 0x31, 0x55, 0x9b, 0x19, 0x6f, 0x1b, 0xdd, 0xd9, 0x54, 0x45, 0x7e, 0x01, 0x0f,
+	0x32, 0x4e, 0x6d, 0x6a, 0xc3, 0xfc, 0x6a, 0x96, 0xb7, 0x81, 0xbc, 0xed, 0x8c, 0x77, 0x50, 0xdf,
+	0xb6, 0xea, 0x96, 0xa1, 0x7e, 0x01, 0x7b, 0x19, 0xa7, 0x52, 0x89, 0xd4, 0x1f, 0x32, 0xfa, 0xad,
+	0x90, 0x34, 0x12, 0x63, 0x49, 0xb9, 0x7f, 0x19, 0xf1, 0x09, 0x77, 0x08, 0xe6, 0x66, 0x27, 0xe3,
+	0x7d, 0x83, 0xf8, 0xad, 0x90, 0xae, 0x18, 0xcb, 0xae, 0x51, 0x93, 0x13, 0xa8, 0xdd, 0x61, 0x0f,
+	0xfc, 0x24, 0xfc, 0x2e, 0x0a, 0xd5, 0x68, 0x6a, 0x62, 0x13, 0x4d, 0x3c, 0x9c, 0x35, 0x71, 0x9c,
+	0x83, 0x72, 0x3b, 0x8f, 0xa1, 0x14, 0x8b, 0x21, 0x0d, 0xa3, 0x94, 0x05, 0x4a, 0xa4, 0x57, 0x4e,
+	0xc5, 0xb4, 0x5d, 0x2c, 0x86, 0xed, 0x5c, 0xa6, 0x07, 0x81, 0x06, 0xe9, 0xbe, 0xf2, 0x95, 0xb3,
+	0xf5, 0xdf, 0x0c, 0x82, 0x57, 0x62, 0x78, 0x82, 0x70, 0xaf, 0x18, 0xe7, 0x3f, 0xc9, 0x4f, 0x80,
+	0x68, 0x3b, 0xdc, 0xbf, 0x9c, 0xad, 0xd9, 0x6d, 0x74, 0x73, 0x2d, 0x16, 0xc3, 0xae, 0x7f, 0x39,
+	0xad, 0xd8, 0x3a, 0x94, 0x72, 0x2c, 0x76, 0x99, 0xb3, 0x83, 0xb0, 0x15, 0x03, 0xc3, 0xee, 0x22,
+	0x9f, 0x61, 0x49, 0xc9, 0x40, 0x46, 0x54, 0x3b, 0x93, 0x8a, 0x38, 0x66, 0x79, 0x6d, 0x3b, 0x58,
+	0xb1, 0x5b, 0x19, 0xef, 0x07, 0x32, 0x6a, 0x4d, 0xb5, 0xa6, 0x74, 0xeb, 0x50, 0x9a, 0xe8, 0x62,
+	0x14, 0x22, 0x46, 0x4f, 0x9c, 0x07, 0x88, 0x5e, 0x99, 0x64, 0xbc, 0x27, 0x44, 0xac, 0x9d, 0x20,
+	0x4f, 0xa0, 0x3c, 0xc5, 0x68, 0x2f, 0x32, 0x2e, 0x9d, 0x5d, 0x84, 0x95, 0x2c, 0xac, 0xeb, 0x5f,
+	0xbe, 0xe6, 0x92, 0x74, 0xa0, 0x36, 0x05, 0x46, 0x61, 0xcc, 0xa8, 0x9e, 0xb4, 0x62, 0xa2, 0xf4,
+	0xf5, 0x24, 0x0b, 0x44, 0x12, 0x4a, 0x67, 0x0f, 0x89, 0x7b, 0x96, 0xe8, 0x86, 0x31, 0x3b, 0x37,
+	0x20, 0x37, 0xe9, 0x1b, 0x08, 0xf9, 0x31, 0xac, 0x07, 0xa9, 0x2f, 0x47, 0x34, 0x9c, 0xf0, 0xb1,
+	0x99, 0x93, 0x0f, 0x31, 0x17, 0x25, 0x14, 0xb7, 0x27, 0x7c, 0x8c, 0x83, 0xb2, 0x09, 0x95, 0x19,
+	0x9c, 0xf6, 0xcc, 0x5c, 0xf8, 0x11, 0x1e, 0xb1, 0x31, 0x05, 0x77, 0xfd, 0x4b, 0x73, 0xd9, 0xcf,
+	0xe0, 0xc1, 0x1d, 0xc2, 0x4c, 0xf0, 0xf7, 0xb1, 0x5b, 0x2b, 0xb3, 0xac, 0x69, 0x0a, 0x4c, 0x78,
+	0x03, 0x91, 0x48, 0x11, 0x33, 0xfa, 0x6e, 0x99, 0x54, 0xd1, 0xb5, 0xad, 0x8c, 0xb7, 0x8c, 0xfa,
+	0xd5, 0x6c, 0xbd, 0xfc, 0x0a, 0x1e, 0xdd, 0x21, 0xde, 0x39, 0xb5, 0x86, 0xbe, 0xee, 0xcc, 0xb2,
+	0x67, 0x0f, 0xfe, 0x39, 0x38, 0xef, 0xe1, 0x9b, 0x32, 0xf8, 0x08, 0xa9, 0x95, 0x3b, 0x54, 0x53,
+	0x0f, 0x3f, 0x83, 0x9d, 0xdb, 0xa9, 0x68, 0xbb, 0xc0, 0x9e, 0x58, 0xc7, 0xea, 0xd9, 0xcc, 0x07,
+	0xa3, 0x2d, 0x7f, 0x3c, 0xed, 0x10, 0x2a, 0xef, 0xcc, 0xc6, 0xbc, 0x7d, 0x1e, 0x23, 0x85, 0xcc,
+	0x4c, 0xc7, 0xbc, 0x69, 0x0c, 0x63, 0x94, 0x49, 0x11, 0xbc, 0x61, 0x8a, 0x4a, 0x96, 0x66, 0x51,
+	0xc0, 0xa4, 0xf3, 0x31, 0x06, 0x85, 0x64, 0xfc, 0xa5, 0x55, 0xf5, 0xad, 0xa6, 0xfe, 0x09, 0x14,
+	0xa7, 0x4f, 0x23, 0x29, 0xc2, 0xc2, 0x69, 0xcf, 0xed, 0x75, 0xca, 0x73, 0x64, 0x19, 0xe6, 0x4f,
+	0xdc, 0x57, 0x9d, 0x72, 0x81, 0x2c, 0xc1, 0xfd, 0xce, 0xf9, 0xd7, 0xe5, 0x7b, 0xf5, 0x26, 0x94,
+	0xef, 0xbe, 0x40, 0x64, 0x05, 0x96, 0x7a, 0xde, 0x59, 0xab, 0xd3, 0xef, 0x97, 0xe7, 0xc8, 0x1a,
+	0xc0, 0xcb, 0xdf, 0xf5, 0x3a, 0xde, 0x6b, 0xb7, 0x7f, 0xe6, 0x95, 0x0b, 0xf5, 0xcf, 0x61, 0xf3,
+	0x3d, 0xa3, 0x91, 0xac, 0xc3, 0xca, 0x57, 0xa7, 0xfd, 0x5e, 0xa7, 0xe5, 0x9e, 0xb8, 0x9d, 0x76,
+	0x79, 0x8e, 0x00, 0x2c, 0xba, 0xa7, 0xee, 0xb9, 0xd7, 0x36, 0xa7, 0xbd, 0x7e, 0xd9, 0x2e, 0xdf,
+	0xab, 0x57, 0xa1, 0x38, 0x6d, 0x55, 0xed, 0xcd, 0x79, 0xe7, 0x9b, 0x73, 0xe3, 0xd7, 0x97, 0xfd,
+	0xb3, 0xd3, 0x72, 0xa1, 0xfe, 0xf7, 0xfb, 0xb0, 0x66, 0x03, 0xd0, 0x66, 0xca, 0x8f, 0x62, 0x49,
+	0x1e, 0x01, 0xe0, 0x13, 0x4d, 0x13, 0x9f, 0x33, 0x5c, 0x19, 0x8a, 0x5e, 0x11, 0x25, 0xa7, 0x3e,
+	0x67, 0xa4, 0x05, 0x10, 0xa4, 0xcc, 0x57, 0x2c, 0xa4, 0xbe, 0xc2, 0xb5, 0x61, 0xe5, 0xd9, 0x6e,
+	0xc3, 0xac, 0x23, 0x8d, 0x7c, 0x1d, 0x69, 0x9c, 0xe7, 0xeb, 0xc8, 0xf1, 0xf2, 0xdb, 0xeb, 0xea,
+	0xdc, 0x9f, 0xff, 0x51, 0x2d, 0x78, 0x45, 0xcb, 0x7b, 0xa1, 0xc8, 0x4f, 0x81, 0xd8, 0xf1, 0xad,
+	0xbb, 0x89, 0x1e, 0x1d, 0x1e, 0xd2, 0x44, 0xe2, 0xe2, 0x30, 0xef, 0xad, 0x1b, 0x8d, 0xb6, 0x70,
+	0x74, 0x78, 0x78, 0xaa, 0x5f, 0xc3, 0x4d, 0x9b, 0x74, 0x33, 0x79, 0xed, 0x4b, 0x34, 0x8f, 0xe8,
+	0x0d, 0xa3, 0x32, 0x53, 0xd7, 0x3c, 0x45, 0x27, 0x50, 0xb3, 0xf8, 0xef, 0x44, 0xfa, 0x26, 0x4a,
+	0x86, 0x54, 0x32, 0x45, 0xc7, 0x69, 0x94, 0xf9, 0x2a, 0x7f, 0xc6, 0x16, 0x90, 0xfc, 0xd0, 0xe0,
+	0xbe, 0x36, 0xb0, 0x3e, 0x53, 0x3d, 0x03, 0x32, 0x76, 0xda, 0x50, 0x7d, 0x8f, 0x1d, 0x39, 0xf2,
+	0xf5, 0x23, 0x60, 0xcc, 0x2c, 0xa2, 0x99, 0xbd, 0xbb, 0x66, 0xfa, 0x88, 0x31, 0x56, 0x3e, 0x05,
+	0xb0, 0xc5, 0x47, 0xa3, 0x10, 0x57, 0x88, 0xd2, 0x71, 0xe9, 0xe6, 0xba, 0x5a, 0xb4, 0x61, 0x77,
+	0xdb, 0x5e, 0xd1, 0x02, 0xdc, 0x10, 0xc7, 0x92, 0x64, 0xe9, 0x3b, 0x61, 0x59, 0xc6, 0x43, 0x4a,
+	0x5a, 0x7e, 0x1b, 0x94, 0xc7, 0xb0, 0xc4, 0x2e, 0x59, 0xa0, 0x6d, 0xe2, 0x0e, 0x71, 0x0c, 0x37,
+	0xd7, 0xd5, 0xc5, 0xce, 0x25, 0x0b, 0xdc, 0xb6, 0xb7, 0xa8, 0x55, 0x6e, 0x58, 0xff, 0x57, 0x01,
+	0x56, 0x7b, 0x22, 0xf4, 0x98, 0x14, 0x93, 0x34, 0x60, 0x92, 0xfc, 0x12, 0x1e, 0xcc, 0x6c, 0x09,
+	0xfe, 0xd8, 0x0f, 0x22, 0x75, 0x45, 0x79, 0x14, 0xc7, 0x91, 0xc4, 0x54, 0xcf, 0x7b, 0x3b, 0x53,
+	0x40, 0xcb, 0xea, 0xbb, 0xa8, 0x26, 0x5f, 0xc0, 0xee, 0x2d, 0x37, 0x65, 0xdf, 0x4e, 0x98, 0xd4,
+	0x45, 0x60, 0xc9, 0xf7, 0x90, 0xec, 0x4c, 0x11, 0x5e, 0x0e, 0xb0, 0xec, 0x23, 0xd8, 0xca, 0x93,
+	0x98, 0x1f, 0x6b, 0x5a, 0xd7, 0x24, 0x9d, 0xd8, 0x34, 0x5a, 0x1d, 0x76, 0xee, 0x73, 0xd8, 0xb6,
+	0x94, 0xdb, 0xd3, 0x0c, 0xc7, 0xa4, 0xde, 0x56, 0xc5, 0xf4, 0x24, 0x4d, 0xaa, 0xff, 0xbb, 0x00,
+	0xf0, 0x6b, 0xfd, 0xdd, 0xd2, 0x33, 0x8f, 0x3c, 0x83, 0xd5, 0xe9, 0x43, 0xa6, 0x63, 0x85, 0xe5,
+	0x7c, 0xbc, 0x7e, 0x73, 0x5d, 0x5d, 0x69, 0xe5, 0x72, 0xb7, 0xed, 0xad, 0x4c, 0x41, 0x6e, 0x48,
+	0x6a, 0xb0, 0xa8, 0x27, 0x7e, 0x14, 0xe2, 0xa5, 0x8a, 0xc7, 0xc5, 0x9b, 0xeb, 0xea, 0xc2, 0x57,
+	0x19, 0x77, 0xdb, 0xde, 0xc2, 0x24, 0xe3, 0x6e, 0x48, 0xb6, 0x61, 0x31, 0x65, 0xbe, 0x14, 0x89,
+	0xdd, 0x75, 0xed, 0x17, 0xd9, 0x83, 0x22, 0x4e, 0x61, 0xdc, 0x16, 0xcd, 0x86, 0xbb, 0xac, 0x05,
+	0xb8, 0x24, 0x7e, 0x84, 0xae, 0xe0, 0xcc, 0xd3, 0x8d, 0x66, 0x37, 0xdb, 0x15, 0x2b, 0x3b, 0xf7,
+	0xa3, 0xd8, 0xf4, 0x96, 0x2f, 0x47, 0xa6, 0xb7, 0x16, 0xff, 0xb7, 0xde, 0x42, 0xde, 0x0b, 0x75,
+	0x1c, 0xbe, 0xfd, 0x61, 0x7f, 0xee, 0x6f, 0x3f, 0xec, 0xcf, 0xfd, 0xe9, 0x66, 0xbf, 0xf0, 0xf6,
+	0x66, 0xbf, 0xf0, 0xd7, 0x9b, 0xfd, 0xc2, 0x3f, 0x6f, 0xf6, 0x0b, 0xbf, 0xff, 0xf2, 0xff, 0xff,
+	0x8f, 0xe5, 0x73, 0xfb, 0xf7, 0x9b, 0xb9, 0xc1, 0x22, 0x3a, 0xf4, 0xfc, 0x3f, 0x03, 0x00, 0x67,
+	0xe1, 0x4a, 0xa4, 0x08, 0x0d, 0x00, 0x00,
 }
@@ -7,8 +7,11 @@ import (
 	"path/filepath"
 	"sync"
 
+	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/hcsoci"
 	"github.com/Microsoft/hcsshim/internal/oci"
+	"github.com/Microsoft/hcsshim/internal/podresources"
 	"github.com/Microsoft/hcsshim/internal/uvm"
 	"github.com/Microsoft/hcsshim/osversion"
 	eventstypes "github.com/containerd/containerd/api/events"
@@ -51,9 +54,32 @@ type shimPod interface {
 	// the `shimExecStateRunning, shimExecStateExited` states. If the exec is
 	// not in this state this pod MUST return `errdefs.ErrFailedPrecondition`.
 	KillTask(ctx context.Context, tid, eid string, signal uint32, all bool) error
+	// PauseTask pauses the task that matches `tid`.
+	//
+	// If `tid` is not found, this pod MUST return `errdefs.ErrNotFound`.
+	//
+	// If this pod is hypervisor isolated only `tid==ID()` is valid. Pausing the
+	// sandbox task pauses the UtilityVM and transitions all workload tasks in
+	// the pod to paused. For any other `tid` this pod MUST return
+	// `errdefs.ErrFailedPrecondition`.
+	PauseTask(ctx context.Context, tid string) error
+	// ResumeTask resumes the paused task that matches `tid`.
+	//
+	// If `tid` is not found, this pod MUST return `errdefs.ErrNotFound`.
+	//
+	// If this pod is hypervisor isolated only `tid==ID()` is valid. Resuming
+	// the sandbox task resumes the UtilityVM and transitions all paused
+	// workload tasks in the pod back to running. For any other `tid` this pod
+	// MUST return `errdefs.ErrFailedPrecondition`.
+	ResumeTask(ctx context.Context, tid string) error
+	// Resources returns the processor and memory accounting of the workload
+	// tasks in this pod against the capacity of its UtilityVM.
+	//
+	// If this pod is not hypervisor isolated returns `nil`.
+	Resources() *runhcsopts.PodResources
 }
 
-func createPod(ctx context.Context, events publisher, req *task.CreateTaskRequest, s *specs.Spec) (_ shimPod, err error) {
+func createPod(ctx context.Context, events publisher, state *shimStateStore, backend cow.Backend, req *task.CreateTaskRequest, s *specs.Spec, shimOpts *runhcsopts.Options) (_ shimPod, err error) {
 	logrus.WithFields(logrus.Fields{
 		"tid": req.ID,
 	}).Debug("createPod")
@@ -89,14 +115,25 @@ func createPod(ctx context.Context, events publisher, req *task.CreateTaskReques
 	var parent *uvm.UtilityVM
 	if oci.IsIsolated(s) {
 		// Create the UVM parent
-		opts, err := oci.SpecToUVMCreateOpts(s, fmt.Sprintf("%s@vm", req.ID), owner)
+		opts, err := oci.SpecToUVMCreateOpts(s, fmt.Sprintf("%s@vm", req.ID), owner, shimOpts)
 		if err != nil {
 			return nil, err
 		}
+		pruneCrashDumps(opts, shimOpts)
+		// pooled is true if `parent` was taken already running from the
+		// utility VM pool.
+		pooled := false
 		switch opts.(type) {
 		case *uvm.OptionsLCOW:
 			lopts := (opts).(*uvm.OptionsLCOW)
-			parent, err = uvm.CreateLCOW(lopts)
+			// The output of a pooled utility VM is handled by the pool.
+			if oci.IsDefaultOutputHandling(s.Annotations) {
+				if parent = takePooledHost(lopts, shimOpts); parent != nil {
+					pooled = true
+					break
+				}
+			}
+			parent, err = uvm.CreateLCOW(ctx, lopts)
 			if err != nil {
 				return nil, err
 			}
@@ -118,16 +155,23 @@ func createPod(ctx context.Context, events publisher, req *task.CreateTaskReques
 			layers[layersLen-1] = vmPath
 			wopts.LayerFolders = layers
 
-			parent, err = uvm.CreateWCOW(wopts)
+			if oci.ParseAnnotationsClone(s.Annotations) {
+				parent = cloneHost(ctx, wopts, vmPath)
+			}
+			if parent == nil {
+				parent, err = uvm.CreateWCOW(ctx, wopts)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if !pooled {
+			err = startHost(parent)
 			if err != nil {
+				parent.Close()
 				return nil, err
 			}
 		}
-		err = parent.Start()
-		if err != nil {
-			parent.Close()
-			return nil, err
-		}
 	} else if !isWCOW {
 		return nil, errors.Wrap(errdefs.ErrFailedPrecondition, "oci spec does not contain WCOW or LCOW spec")
 	}
@@ -139,9 +183,13 @@ func createPod(ctx context.Context, events publisher, req *task.CreateTaskReques
 	}()
 
 	p := pod{
-		events: events,
-		id:     req.ID,
-		host:   parent,
+		events:    events,
+		state:     state,
+		backend:   backend,
+		id:        req.ID,
+		bundle:    req.Bundle,
+		host:      parent,
+		resources: newPodAccountant(parent),
 	}
 	// TOOD: JTERRY75 - There is a bug in the compartment activation for Windows
 	// Process isolated that requires us to create the real pause container to
@@ -201,13 +249,14 @@ func createPod(ctx context.Context, events publisher, req *task.CreateTaskReques
 		}
 		// LCOW (and WCOW Process Isolated for the time being) requires a real
 		// task for the sandbox.
-		lt, err := newHcsTask(ctx, events, parent, true, req, s)
+		lt, err := newHcsTask(ctx, events, state, backend, parent, true, req, s)
 		if err != nil {
 			return nil, err
 		}
 		p.sandboxTask = lt
 	}
 
+	p.persist()
 	return &p, nil
 }
 
@@ -215,10 +264,23 @@ var _ = (shimPod)(&pod{})
 
 type pod struct {
 	events publisher
+	// state is where the state of this pod is persisted. If `nil` the state
+	// is not persisted.
+	//
+	// It MUST be treated as read only in the lifetime of the pod.
+	state *shimStateStore
+	// backend creates the compute systems of the tasks in the pod.
+	//
+	// It MUST be treated as read only in the lifetime of the pod.
+	backend cow.Backend
 	// id is the id of the sandbox task when the pod is created.
 	//
 	// It MUST be treated as read only in the lifetime of the pod.
 	id string
+	// bundle is the bundle of the sandbox task.
+	//
+	// It MUST be treated as read only in the lifetime of the pod.
+	bundle string
 	// sandboxTask is the task that represents the sandbox.
 	//
 	// Note: The invariant `id==sandboxTask.ID()` MUST be true.
@@ -230,6 +292,16 @@ type pod struct {
 	//
 	// It MUST be treated as read only in the lifetime of the pod.
 	host *uvm.UtilityVM
+	// resources accounts for the processor and memory requested by the
+	// workload tasks against the capacity of `host`. If `host==nil` this is
+	// `nil` and nothing is accounted for.
+	//
+	// It MUST be treated as read only in the lifetime of the pod.
+	resources *podresources.Accountant
+	// rl is the reserve lock. It serializes the reservations that grow
+	// `host` so that concurrent creates do not grow it twice for the same
+	// shortfall.
+	rl sync.Mutex
 
 	// wcl is the worload create mutex. All calls to CreateTask must hold this
 	// lock while the ID reservation takes place. Once the ID is held it is safe
@@ -258,10 +330,10 @@ func (p *pod) CreateTask(ctx context.Context, req *task.CreateTaskRequest, s *sp
 
 	p.wcl.Lock()
 	_, loaded := p.workloadTasks.LoadOrStore(req.ID, nil)
+	p.wcl.Unlock()
 	if loaded {
 		return nil, errors.Wrapf(errdefs.ErrAlreadyExists, "task with id: '%s' already exists id pod: '%s'", req.ID, p.id)
 	}
-	p.wcl.Unlock()
 	var err error
 	defer func() {
 		if err != nil {
@@ -290,12 +362,27 @@ func (p *pod) CreateTask(ctx context.Context, req *task.CreateTaskRequest, s *sp
 			sid)
 	}
 
-	st, err := newHcsTask(ctx, p.events, p.host, false, req, s)
+	if p.resources != nil {
+		err = p.reserve(req.ID, s)
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrFailedPrecondition, "task with id: '%s' does not fit in pod: '%s': %v", req.ID, p.id, err)
+		}
+		defer func() {
+			if err != nil {
+				p.resources.Release(req.ID)
+			}
+		}()
+	}
+
+	st, err := newHcsTask(ctx, p.events, p.state, p.backend, p.host, false, req, s)
 	if err != nil {
 		return nil, err
 	}
 
 	p.workloadTasks.Store(req.ID, st)
+	p.releaseOnExit(st)
+	// Adding the task changed the resources in the host.
+	p.persist()
 	return st, nil
 }
 
@@ -336,7 +423,7 @@ func (p *pod) KillTask(ctx context.Context, tid, eid string, signal uint32, all
 			})
 
 			// iterate all
-			return false
+			return true
 		})
 	}
 	eg.Go(func() error {
@@ -344,3 +431,253 @@ func (p *pod) KillTask(ctx context.Context, tid, eid string, signal uint32, all
 	})
 	return eg.Wait()
 }
+
+func (p *pod) PauseTask(ctx context.Context, tid string) error {
+	logrus.WithFields(logrus.Fields{
+		"pod-id": p.id,
+		"tid":    tid,
+	}).Debug("pod::PauseTask")
+
+	t, err := p.GetTask(tid)
+	if err != nil {
+		return err
+	}
+	if p.host == nil {
+		// Process isolated. Every task is its own container.
+		return t.Pause(ctx)
+	}
+	if tid != p.id {
+		return errors.Wrapf(errdefs.ErrFailedPrecondition, "task: '%s' in hypervisor isolated pod: '%s' cannot be paused individually", tid, p.id)
+	}
+	// The sandbox task owns the UVM. Pausing it pauses every container in the
+	// pod so all that is left is to transition the workload tasks.
+	if err := t.Pause(ctx); err != nil {
+		return err
+	}
+	p.workloadTasks.Range(func(key, value interface{}) bool {
+		// A task that is still being created has no value yet.
+		wt, ok := value.(shimTask)
+		if !ok {
+			return true
+		}
+		if err := wt.Pause(ctx); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"pod-id":        p.id,
+				"tid":           wt.ID(),
+				logrus.ErrorKey: err,
+			}).Warn("pod::PauseTask - failed to transition workload task")
+		}
+
+		// iterate all
+		return true
+	})
+	return nil
+}
+
+func (p *pod) ResumeTask(ctx context.Context, tid string) error {
+	logrus.WithFields(logrus.Fields{
+		"pod-id": p.id,
+		"tid":    tid,
+	}).Debug("pod::ResumeTask")
+
+	t, err := p.GetTask(tid)
+	if err != nil {
+		return err
+	}
+	if p.host == nil {
+		return t.Resume(ctx)
+	}
+	if tid != p.id {
+		return errors.Wrapf(errdefs.ErrFailedPrecondition, "task: '%s' in hypervisor isolated pod: '%s' cannot be resumed individually", tid, p.id)
+	}
+	// Resume the UVM before transitioning the workload tasks so that no
+	// workload reports running while the UVM is still paused.
+	if err := t.Resume(ctx); err != nil {
+		return err
+	}
+	p.workloadTasks.Range(func(key, value interface{}) bool {
+		wt, ok := value.(shimTask)
+		if !ok {
+			return true
+		}
+		if e, _ := wt.GetExec(""); e.State() == shimExecStatePaused {
+			if err := wt.Resume(ctx); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"pod-id":        p.id,
+					"tid":           wt.ID(),
+					logrus.ErrorKey: err,
+				}).Warn("pod::ResumeTask - failed to transition workload task")
+			}
+		}
+
+		// iterate all
+		return true
+	})
+	return nil
+}
+
+func (p *pod) Resources() *runhcsopts.PodResources {
+	if p.resources == nil {
+		return nil
+	}
+	capacity, requested := p.resources.Capacity(), p.resources.Requested()
+	return &runhcsopts.PodResources{
+		ProcessorCapacityMillis:  capacity.ProcessorMillis,
+		ProcessorRequestedMillis: requested.ProcessorMillis,
+		MemoryCapacityInMb:       capacity.MemoryInMB,
+		MemoryRequestedInMb:      requested.MemoryInMB,
+	}
+}
+
+// reserve reserves the processor and memory requested by the container `s`
+// for the task `tid`. If the request does not fit in the pod, the memory of
+// `host` is grown to fit it within its maximum first.
+func (p *pod) reserve(tid string, s *specs.Spec) error {
+	p.rl.Lock()
+	defer p.rl.Unlock()
+
+	capacity := p.resources.Capacity()
+	r := containerRequest(s, capacity.ProcessorMillis/1000)
+	err := p.resources.Reserve(tid, r)
+	ierr, ok := err.(*podresources.InsufficientError)
+	if !ok || p.host == nil {
+		return err
+	}
+	if gerr := growHost(p.host, ierr.Required(capacity)); gerr != nil {
+		logrus.WithFields(logrus.Fields{
+			"pod-id":        p.id,
+			"tid":           tid,
+			logrus.ErrorKey: gerr,
+		}).Warning("pod::reserve - failed to grow host")
+		// The host may have been grown partially.
+		p.resources.Grow(hostCapacity(p.host))
+		return err
+	}
+	p.resources.Grow(hostCapacity(p.host))
+	return p.resources.Reserve(tid, r)
+}
+
+// hostResizer is the subset of `*uvm.UtilityVM` used to grow the host of a
+// pod.
+type hostResizer interface {
+	ProcessorCount() int32
+	MemorySizeInMB() int32
+	MemoryMaximumInMB() int32
+	UpdateMemory(sizeInMB int32) error
+}
+
+// growHost grows the memory of `host` to at least `required`. The vCPUs of a
+// running utility VM cannot be hot added, see `uvm.UpdateProcessors`, so
+// nothing is grown if `required` has more processors than `host` or more
+// memory than its maximum.
+func growHost(host hostResizer, required podresources.Resources) error {
+	count := int32((required.ProcessorMillis + 999) / 1000)
+	if count > host.ProcessorCount() {
+		return errors.Wrapf(uvm.ErrProcessorHotAddNotSupported, "pod requires %d processors over the %d of the host", count, host.ProcessorCount())
+	}
+	sizeInMB := int32(required.MemoryInMB)
+	if sizeInMB <= host.MemorySizeInMB() {
+		return nil
+	} else if sizeInMB > host.MemoryMaximumInMB() {
+		return errors.Errorf("pod requires %d MB of memory over the host maximum of %d MB", sizeInMB, host.MemoryMaximumInMB())
+	}
+	return host.UpdateMemory(sizeInMB)
+}
+
+// hostCapacity returns the processor and memory capacity of `host`.
+func hostCapacity(host hostResizer) podresources.Resources {
+	return podresources.Resources{
+		ProcessorMillis: uint64(host.ProcessorCount()) * 1000,
+		MemoryInMB:      uint64(host.MemorySizeInMB()),
+	}
+}
+
+// newPodAccountant returns an accountant for the capacity of `host`. If
+// `host==nil` returns `nil`.
+func newPodAccountant(host *uvm.UtilityVM) *podresources.Accountant {
+	if host == nil {
+		return nil
+	}
+	return podresources.NewAccountant(hostCapacity(host))
+}
+
+// containerRequest returns the processor and memory requested by the container
+// `s` in a UtilityVM with `processorCount` processors. A container without a
+// request for either requests `0` of it.
+func containerRequest(s *specs.Spec, processorCount uint64) podresources.Resources {
+	var r podresources.Resources
+	if count := oci.ParseAnnotationsCPUCount(s, oci.AnnotationContainerProcessorCount, 0); count > 0 {
+		r.ProcessorMillis = uint64(count) * 1000
+	} else if limit := oci.ParseAnnotationsCPULimit(s, oci.AnnotationContainerProcessorLimit, 0); limit > 0 {
+		// The limit is in hundredths of a percent of all processors.
+		r.ProcessorMillis = uint64(limit) * processorCount / 10
+	} else if s.Linux != nil &&
+		s.Linux.Resources != nil &&
+		s.Linux.Resources.CPU != nil &&
+		s.Linux.Resources.CPU.Quota != nil &&
+		*s.Linux.Resources.CPU.Quota > 0 &&
+		s.Linux.Resources.CPU.Period != nil &&
+		*s.Linux.Resources.CPU.Period > 0 {
+		r.ProcessorMillis = uint64(*s.Linux.Resources.CPU.Quota) * 1000 / *s.Linux.Resources.CPU.Period
+	}
+	if m := oci.ParseAnnotationsMemory(s, oci.AnnotationContainerMemorySizeInMB, 0); m > 0 {
+		r.MemoryInMB = uint64(m)
+	} else if s.Linux != nil &&
+		s.Linux.Resources != nil &&
+		s.Linux.Resources.Memory != nil &&
+		s.Linux.Resources.Memory.Limit != nil &&
+		*s.Linux.Resources.Memory.Limit > 0 {
+		r.MemoryInMB = uint64(*s.Linux.Resources.Memory.Limit) / 1024 / 1024
+	}
+	return r
+}
+
+// releaseOnExit releases the resources reserved for the workload task `t`
+// once it has exited and its container resources are released.
+func (p *pod) releaseOnExit(t shimTask) {
+	if p.resources == nil {
+		return
+	}
+	go func() {
+		t.Wait(context.Background())
+		p.resources.Release(t.ID())
+		p.persist()
+	}()
+}
+
+// persist writes the current state of this pod to `p.state`. The state of each
+// task in the pod is persisted by the task itself. A failure is logged but
+// otherwise ignored as the pod itself is unaffected.
+//
+// This call is a noop if `p.state == nil`.
+func (p *pod) persist() {
+	if p.state == nil {
+		return
+	}
+
+	_, isWcowPodSandbox := p.sandboxTask.(*wcowPodSandboxTask)
+	ps := &podState{
+		ID:               p.id,
+		SandboxBundle:    p.bundle,
+		SandboxIsHcsTask: !isWcowPodSandbox,
+	}
+	if p.resources != nil {
+		ps.Requests = p.resources.Requests()
+	}
+	if p.host != nil {
+		m, err := p.host.Manifest()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"pod-id":        p.id,
+				logrus.ErrorKey: err,
+			}).Warn("pod::persist - failed to get host manifest")
+		}
+		ps.Host = m
+	}
+	if err := p.state.writePod(ps); err != nil {
+		logrus.WithFields(logrus.Fields{
+			"pod-id":        p.id,
+			logrus.ErrorKey: err,
+		}).Warn("pod::persist - failed to write pod state")
+	}
+}
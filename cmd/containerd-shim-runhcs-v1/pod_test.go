@@ -7,15 +7,21 @@ import (
 	"sync"
 	"testing"
 
+	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/oci"
+	"github.com/Microsoft/hcsshim/internal/podresources"
+	"github.com/Microsoft/hcsshim/internal/uvm"
 	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/runtime/v2/task"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
 )
 
 var _ = (shimPod)(&testShimPod{})
 
 type testShimPod struct {
-	id string
+	id        string
+	resources *runhcsopts.PodResources
 
 	tasks sync.Map
 }
@@ -44,6 +50,26 @@ func (tsp *testShimPod) KillTask(ctx context.Context, tid, eid string, signal ui
 	return s.KillExec(ctx, eid, signal, all)
 }
 
+func (tsp *testShimPod) PauseTask(ctx context.Context, tid string) error {
+	s, err := tsp.GetTask(tid)
+	if err != nil {
+		return err
+	}
+	return s.Pause(ctx)
+}
+
+func (tsp *testShimPod) ResumeTask(ctx context.Context, tid string) error {
+	s, err := tsp.GetTask(tid)
+	if err != nil {
+		return err
+	}
+	return s.Resume(ctx)
+}
+
+func (tsp *testShimPod) Resources() *runhcsopts.PodResources {
+	return tsp.resources
+}
+
 // Pod tests
 
 func setupTestPodWithFakes(t *testing.T) (*pod, *testShimTask) {
@@ -205,3 +231,200 @@ func Test_pod_KillTask_WorkloadID_2ndExecID_All_Error(t *testing.T) {
 		verifyExpectedError(t, nil, err, errdefs.ErrFailedPrecondition)
 	}
 }
+
+func Test_pod_PauseTask_UnknownTaskID_Error(t *testing.T) {
+	p, _ := setupTestPodWithFakes(t)
+	err := p.PauseTask(context.TODO(), "thisshouldnotmatch")
+
+	verifyExpectedError(t, nil, err, errdefs.ErrNotFound)
+}
+
+func Test_pod_PauseTask_SandboxID_Success(t *testing.T) {
+	p, st := setupTestPodWithFakes(t)
+	st.exec.Start(context.TODO())
+
+	err := p.PauseTask(context.TODO(), t.Name())
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if st.exec.State() != shimExecStatePaused {
+		t.Fatalf("sandbox init exec should be paused, got: %v", st.exec.State())
+	}
+}
+
+func Test_pod_PauseTask_WorkloadID_Success(t *testing.T) {
+	p, _ := setupTestPodWithFakes(t)
+	t1 := setupTestTaskInPod(t, p)
+	t1.exec.Start(context.TODO())
+
+	err := p.PauseTask(context.TODO(), t1.ID())
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if t1.exec.State() != shimExecStatePaused {
+		t.Fatalf("workload init exec should be paused, got: %v", t1.exec.State())
+	}
+}
+
+func Test_pod_ResumeTask_SandboxID_NotPaused_Error(t *testing.T) {
+	p, st := setupTestPodWithFakes(t)
+	st.exec.Start(context.TODO())
+
+	err := p.ResumeTask(context.TODO(), t.Name())
+
+	verifyExpectedError(t, nil, err, errdefs.ErrFailedPrecondition)
+}
+
+func Test_pod_CreateTask_ExceedsCapacity_Error(t *testing.T) {
+	p, st := setupTestPodWithFakes(t)
+	st.exec.Start(context.TODO())
+	p.resources = podresources.NewAccountant(podresources.Resources{ProcessorMillis: 2000, MemoryInMB: 1024})
+	if err := p.resources.Reserve("other", podresources.Resources{MemoryInMB: 1000}); err != nil {
+		t.Fatalf("failed to reserve: %v", err)
+	}
+	s := &specs.Spec{
+		Annotations: map[string]string{
+			oci.KubernetesContainerTypeAnnotation: string(oci.KubernetesContainerTypeContainer),
+			oci.KubernetesSandboxIDAnnotation:     p.id,
+			oci.AnnotationContainerMemorySizeInMB: "100",
+		},
+	}
+	tid := strconv.Itoa(rand.Int())
+
+	st2, err := p.CreateTask(context.TODO(), &task.CreateTaskRequest{ID: tid}, s)
+
+	verifyExpectedError(t, st2, err, errdefs.ErrFailedPrecondition)
+	if _, err := p.GetTask(tid); !errdefs.IsNotFound(err) {
+		t.Fatalf("expected task id to be released, got: %v", err)
+	}
+	if r := p.Resources(); r.MemoryRequestedInMb != 1000 || r.MemoryCapacityInMb != 1024 || r.ProcessorCapacityMillis != 2000 {
+		t.Fatalf("expected only the existing request to be accounted, got: %+v", r)
+	}
+}
+
+func Test_containerRequest(t *testing.T) {
+	limit := int64(512 * 1024 * 1024)
+	quota, period := int64(50000), uint64(100000)
+	tests := []struct {
+		name     string
+		s        *specs.Spec
+		expected podresources.Resources
+	}{
+		{
+			name:     "None",
+			s:        &specs.Spec{},
+			expected: podresources.Resources{},
+		},
+		{
+			name: "WCOW_CountAndMemory",
+			s: &specs.Spec{Annotations: map[string]string{
+				oci.AnnotationContainerProcessorCount: "2",
+				oci.AnnotationContainerMemorySizeInMB: "256",
+			}},
+			expected: podresources.Resources{ProcessorMillis: 2000, MemoryInMB: 256},
+		},
+		{
+			name: "WCOW_Limit",
+			s: &specs.Spec{Annotations: map[string]string{
+				oci.AnnotationContainerProcessorLimit: "5000",
+			}},
+			expected: podresources.Resources{ProcessorMillis: 1000},
+		},
+		{
+			name: "LCOW_QuotaAndMemory",
+			s: &specs.Spec{Linux: &specs.Linux{Resources: &specs.LinuxResources{
+				CPU:    &specs.LinuxCPU{Quota: &quota, Period: &period},
+				Memory: &specs.LinuxMemory{Limit: &limit},
+			}}},
+			expected: podresources.Resources{ProcessorMillis: 500, MemoryInMB: 512},
+		},
+	}
+	for _, test := range tests {
+		if r := containerRequest(test.s, 2); r != test.expected {
+			t.Errorf("%s: expected: %+v, got: %+v", test.name, test.expected, r)
+		}
+	}
+}
+
+type testHostResizer struct {
+	processorCount                    int32
+	memorySizeInMB, memoryMaximumInMB int32
+	updates                           []string
+}
+
+func (h *testHostResizer) ProcessorCount() int32    { return h.processorCount }
+func (h *testHostResizer) MemorySizeInMB() int32    { return h.memorySizeInMB }
+func (h *testHostResizer) MemoryMaximumInMB() int32 { return h.memoryMaximumInMB }
+
+func (h *testHostResizer) UpdateMemory(sizeInMB int32) error {
+	h.updates = append(h.updates, "memory="+strconv.Itoa(int(sizeInMB)))
+	h.memorySizeInMB = sizeInMB
+	return nil
+}
+
+func newTestHostResizer() *testHostResizer {
+	return &testHostResizer{
+		processorCount:    2,
+		memorySizeInMB:    1024,
+		memoryMaximumInMB: 4096,
+	}
+}
+
+func Test_growHost(t *testing.T) {
+	h := newTestHostResizer()
+	a := podresources.NewAccountant(hostCapacity(h))
+	a.Reserve("a", podresources.Resources{ProcessorMillis: 1500, MemoryInMB: 1000})
+	r := podresources.Resources{ProcessorMillis: 500, MemoryInMB: 1000}
+	ierr, ok := a.Reserve("b", r).(*podresources.InsufficientError)
+	if !ok {
+		t.Fatal("expected InsufficientError")
+	}
+
+	if err := growHost(h, ierr.Required(a.Capacity())); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if len(h.updates) != 1 || h.updates[0] != "memory=2000" {
+		t.Fatalf("expected growth to 2000 MB, got: %v", h.updates)
+	}
+	a.Grow(hostCapacity(h))
+	if err := a.Reserve("b", r); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+}
+
+func Test_growHost_OnlyShortfall(t *testing.T) {
+	h := newTestHostResizer()
+
+	if err := growHost(h, podresources.Resources{ProcessorMillis: 2000, MemoryInMB: 2048}); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if len(h.updates) != 1 || h.updates[0] != "memory=2048" {
+		t.Fatalf("expected only memory growth, got: %v", h.updates)
+	}
+}
+
+func Test_growHost_OverMaximum_NotGrown(t *testing.T) {
+	tests := map[string]podresources.Resources{
+		"processors": {ProcessorMillis: 2001, MemoryInMB: 2048},
+		"memory":     {ProcessorMillis: 2000, MemoryInMB: 4097},
+	}
+	for name, required := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := newTestHostResizer()
+			if err := growHost(h, required); err == nil {
+				t.Fatal("expected an error")
+			}
+			if len(h.updates) != 0 {
+				t.Fatalf("expected the host not to be grown, got: %v", h.updates)
+			}
+		})
+	}
+}
+
+func Test_growHost_Processors_NotSupported(t *testing.T) {
+	h := newTestHostResizer()
+	err := growHost(h, podresources.Resources{ProcessorMillis: 3000, MemoryInMB: 1024})
+	if errors.Cause(err) != uvm.ErrProcessorHotAddNotSupported {
+		t.Fatalf("expected %v, got: %v", uvm.ErrProcessorHotAddNotSupported, err)
+	}
+}
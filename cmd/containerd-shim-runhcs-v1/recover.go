@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/hcsoci"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/containerd/containerd/runtime"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// recoveryBackend opens and cleans up the platform resources recorded in the
+// persisted shim state.
+type recoveryBackend interface {
+	// openHost opens the running UtilityVM described by `m`.
+	openHost(m *uvm.CheckpointManifest) (*uvm.UtilityVM, error)
+	// openTask reopens the running task described by `ts` in `host` and all
+	// of its execs. The returned task persists its state to `state`.
+	//
+	// If `host == nil` the task is process isolated.
+	openTask(ctx context.Context, events publisher, state *shimStateStore, host *uvm.UtilityVM, ts *taskState) (shimTask, error)
+	// cleanupTask terminates the container described by `ts` if it is still
+	// running and releases its resources. If `host == nil` only the resources
+	// on the host are released.
+	cleanupTask(ts *taskState, host *uvm.UtilityVM)
+	// cleanupHost terminates the UtilityVM `id` if it is still running. If
+	// `host != nil` it is the already opened UtilityVM `id`.
+	cleanupHost(id string, host *uvm.UtilityVM)
+}
+
+// recoveryPublisher wraps `events` and drops all events except
+// `runtime.TaskExitEventTopic` until `done` is called. Reopening tasks and
+// execs that containerd already knows about MUST NOT event them again but any
+// exit that happened while the shim was not running MUST still be evented.
+func recoveryPublisher(events publisher) (_ publisher, done func()) {
+	var recovering int32 = 1
+	return func(topic string, event interface{}) {
+			if atomic.LoadInt32(&recovering) == 1 && topic != runtime.TaskExitEventTopic {
+				return
+			}
+			events(topic, event)
+		}, func() {
+			atomic.StoreInt32(&recovering, 0)
+		}
+}
+
+// recoverState recovers the task or pod whose state was persisted to `state`
+// by a previous instance of this shim.
+//
+// If nothing was persisted returns `nil, nil`. Otherwise returns either the
+// recovered `shimTask` or `shimPod`. A recovered pod creates any new tasks
+// with `computeBackend`.
+//
+// Any task that cannot be reopened is cleaned up deterministically and its
+// state is removed. If the standalone task or the pod host cannot be reopened
+// everything is cleaned up and an error is returned.
+func recoverState(ctx context.Context, events publisher, state *shimStateStore, computeBackend cow.Backend, backend recoveryBackend) (_ interface{}, err error) {
+	ps, tasks, err := state.read()
+	if err != nil {
+		return nil, err
+	}
+	if ps == nil && len(tasks) == 0 {
+		return nil, nil
+	}
+	logrus.WithFields(logrus.Fields{
+		"pod":   ps != nil,
+		"tasks": len(tasks),
+	}).Info("recoverState - reattaching to persisted state")
+
+	events, done := recoveryPublisher(events)
+	defer done()
+
+	cleanupAll := func(host *uvm.UtilityVM, hostID string) {
+		for _, ts := range tasks {
+			backend.cleanupTask(ts, host)
+		}
+		if hostID != "" {
+			backend.cleanupHost(hostID, host)
+		}
+		if rerr := state.remove(); rerr != nil {
+			logrus.WithError(rerr).Warn("recoverState - failed to remove state")
+		}
+	}
+
+	if ps == nil {
+		// Standalone task.
+		if len(tasks) != 1 {
+			cleanupAll(nil, "")
+			return nil, errors.Errorf("expected state for a single task, got %d", len(tasks))
+		}
+		ts := tasks[0]
+		if !isRecoverable(ts) {
+			cleanupAll(nil, ts.HostID)
+			return nil, errors.Errorf("task: '%s' is not running", ts.ID)
+		}
+		var host *uvm.UtilityVM
+		if ts.HostID != "" {
+			if ts.Host == nil {
+				cleanupAll(nil, ts.HostID)
+				return nil, errors.Errorf("task: '%s' has no host manifest", ts.ID)
+			}
+			host, err = backend.openHost(ts.Host)
+			if err != nil {
+				cleanupAll(nil, ts.HostID)
+				return nil, errors.Wrapf(err, "failed to open host for task: '%s'", ts.ID)
+			}
+		}
+		t, err := backend.openTask(ctx, events, state, host, ts)
+		if err != nil {
+			cleanupAll(host, ts.HostID)
+			return nil, errors.Wrapf(err, "failed to open task: '%s'", ts.ID)
+		}
+		return t, nil
+	}
+
+	// Pod.
+	var host *uvm.UtilityVM
+	if ps.Host != nil {
+		host, err = backend.openHost(ps.Host)
+		if err != nil {
+			cleanupAll(nil, ps.Host.ID)
+			return nil, errors.Wrapf(err, "failed to open host for pod: '%s'", ps.ID)
+		}
+	}
+	p := &pod{
+		events:    events,
+		state:     state,
+		backend:   computeBackend,
+		id:        ps.ID,
+		bundle:    ps.SandboxBundle,
+		host:      host,
+		resources: newPodAccountant(host),
+	}
+	if ps.SandboxIsHcsTask {
+		var sts *taskState
+		for _, ts := range tasks {
+			if ts.ID == ps.ID {
+				sts = ts
+			}
+		}
+		if sts == nil || !isRecoverable(sts) {
+			cleanupAll(host, hostID(ps.Host))
+			return nil, errors.Errorf("sandbox task for pod: '%s' is not running", ps.ID)
+		}
+		st, err := backend.openTask(ctx, events, state, host, sts)
+		if err != nil {
+			cleanupAll(host, hostID(ps.Host))
+			return nil, errors.Wrapf(err, "failed to open sandbox task for pod: '%s'", ps.ID)
+		}
+		p.sandboxTask = st
+	} else {
+		if host == nil {
+			cleanupAll(nil, "")
+			return nil, errors.Errorf("fake sandbox task for pod: '%s' requires a host", ps.ID)
+		}
+		// The fake sandbox task has no container. A running host means the
+		// sandbox was running.
+		st := newWcowPodSandboxTask(ctx, events, ps.ID, ps.SandboxBundle, host)
+		init, _ := st.GetExec("")
+		if err := init.Start(ctx); err != nil {
+			cleanupAll(host, hostID(ps.Host))
+			return nil, err
+		}
+		p.sandboxTask = st
+	}
+	// The sandbox is running. Reopen each workload task independently.
+	for _, ts := range tasks {
+		if ts.ID == ps.ID {
+			continue
+		}
+		if !isRecoverable(ts) {
+			backend.cleanupTask(ts, host)
+			state.removeTask(ts.ID)
+			continue
+		}
+		t, err := backend.openTask(ctx, events, state, host, ts)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"pod-id":        ps.ID,
+				"tid":           ts.ID,
+				logrus.ErrorKey: err,
+			}).Warn("recoverState - failed to reopen workload task")
+			backend.cleanupTask(ts, host)
+			state.removeTask(ts.ID)
+			continue
+		}
+		if r, ok := ps.Requests[ts.ID]; ok && p.resources != nil {
+			if err := p.resources.Reserve(ts.ID, r); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"pod-id":        ps.ID,
+					"tid":           ts.ID,
+					logrus.ErrorKey: err,
+				}).Warn("recoverState - failed to reserve workload task resources")
+			}
+		}
+		p.workloadTasks.Store(ts.ID, t)
+		p.releaseOnExit(t)
+	}
+	p.persist()
+	return p, nil
+}
+
+// cleanupState terminates everything described by the state persisted to
+// `state` and removes the state. It is used when there is no shim left to
+// reattach to the state.
+func cleanupState(state *shimStateStore, backend recoveryBackend) error {
+	ps, tasks, err := state.read()
+	if err != nil {
+		return err
+	}
+	for _, ts := range tasks {
+		backend.cleanupTask(ts, nil)
+		if ps == nil && ts.HostID != "" {
+			backend.cleanupHost(ts.HostID, nil)
+		}
+	}
+	if ps != nil && ps.Host != nil {
+		backend.cleanupHost(ps.Host.ID, nil)
+	}
+	return state.remove()
+}
+
+// recover reattaches `s` to the task or pod persisted to `s.state` by a
+// previous instance of this shim. It is not an error if nothing was persisted.
+func (s *service) recover(ctx context.Context, backend recoveryBackend) error {
+	if s.state == nil {
+		return nil
+	}
+	ps, tasks, err := s.state.read()
+	if err != nil || ps == nil && len(tasks) == 0 {
+		return err
+	}
+	if ps != nil != s.isSandbox ||
+		ps != nil && ps.ID != s.tid ||
+		ps == nil && len(tasks) == 1 && tasks[0].ID != s.tid {
+		return errors.Errorf("persisted state does not match shim for: '%s'", s.tid)
+	}
+	v, err := recoverState(ctx, s.events, s.state, s.backend, backend)
+	if err != nil || v == nil {
+		return err
+	}
+	s.taskOrPod.Store(v)
+	return nil
+}
+
+// isRecoverable returns `true` if the init exec of `ts` was running or paused
+// when it was persisted. A task that was never started or has already exited
+// has nothing running to reattach to.
+func isRecoverable(ts *taskState) bool {
+	return ts.Init.State == shimExecStateRunning || ts.Init.State == shimExecStatePaused
+}
+
+func hostID(m *uvm.CheckpointManifest) string {
+	if m == nil {
+		return ""
+	}
+	return m.ID
+}
+
+var _ = (recoveryBackend)(&hcsRecoveryBackend{})
+
+// hcsRecoveryBackend is the `recoveryBackend` that reopens and cleans up the
+// compute systems through `backend`. The UtilityVMs are always reopened
+// through HCS.
+type hcsRecoveryBackend struct {
+	backend cow.Backend
+}
+
+func (hrb hcsRecoveryBackend) openHost(m *uvm.CheckpointManifest) (*uvm.UtilityVM, error) {
+	return uvm.Open(m)
+}
+
+func (hrb hcsRecoveryBackend) openTask(ctx context.Context, events publisher, state *shimStateStore, host *uvm.UtilityVM, ts *taskState) (_ shimTask, err error) {
+	logrus.WithFields(logrus.Fields{
+		"tid": ts.ID,
+	}).Debug("hcsRecoveryBackend::openTask")
+
+	system, err := hrb.backend.OpenComputeSystem(ts.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			system.Close()
+		}
+	}()
+
+	io, err := newUpstreamIO(ctx, ts.ID, ts.ID, ts.Init.Stdin, ts.Init.Stdout, ts.Init.Stderr, ts.Init.Terminal)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := &hcsoci.Resources{}
+	if ts.Resources != nil {
+		resources = hcsoci.NewResourcesFromState(ts.Resources)
+	}
+	ht := &hcsTask{
+		events:   events,
+		log:      logrus.WithField(logfields.TaskID, ts.ID),
+		state:    state,
+		id:       ts.ID,
+		isWCOW:   ts.IsWCOW,
+		c:        system,
+		cr:       resources,
+		ownsHost: ts.OwnsHost,
+		host:     host,
+		closed:   make(chan struct{}),
+	}
+	ht.init = newRestoredHcsExec(
+		ctx,
+		events,
+		ts.ID,
+		host,
+		system,
+		ts.ID,
+		ts.Bundle,
+		ts.IsWCOW,
+		&specs.Process{Terminal: ts.Init.Terminal},
+		io,
+		ts.Init.Pid)
+	ht.init.(*hcsExec).stateChanged = ht.persist
+	if err := ht.init.Start(ctx); err != nil {
+		return nil, err
+	}
+	if ts.Init.State == shimExecStatePaused {
+		ht.init.Pause(ctx)
+	}
+	for _, es := range ts.Execs {
+		if es.State != shimExecStateRunning && es.State != shimExecStatePaused {
+			// An exec that was never started cannot be started as its
+			// process spec is not persisted. An exec that already exited has
+			// nothing to reattach to.
+			logrus.WithFields(logrus.Fields{
+				"tid":   ts.ID,
+				"eid":   es.ID,
+				"state": es.State,
+			}).Warn("hcsRecoveryBackend::openTask - dropping exec that is not running")
+			continue
+		}
+		eio, err := newUpstreamIO(ctx, ts.ID, es.ID, es.Stdin, es.Stdout, es.Stderr, es.Terminal)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"tid":           ts.ID,
+				"eid":           es.ID,
+				logrus.ErrorKey: err,
+			}).Warn("hcsRecoveryBackend::openTask - failed to reconnect exec io")
+			continue
+		}
+		he := newRestoredHcsExec(
+			ctx,
+			events,
+			ts.ID,
+			host,
+			system,
+			es.ID,
+			ts.Bundle,
+			ts.IsWCOW,
+			&specs.Process{Terminal: es.Terminal},
+			eio,
+			es.Pid)
+		he.(*hcsExec).stateChanged = ht.persist
+		if err := he.Start(ctx); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"tid":           ts.ID,
+				"eid":           es.ID,
+				logrus.ErrorKey: err,
+			}).Warn("hcsRecoveryBackend::openTask - failed to reopen exec")
+			continue
+		}
+		if es.State == shimExecStatePaused {
+			he.Pause(ctx)
+		}
+		ht.execs.Store(es.ID, he)
+	}
+
+	ht.start()
+	ht.persist()
+	return ht, nil
+}
+
+func (hrb hcsRecoveryBackend) cleanupTask(ts *taskState, host *uvm.UtilityVM) {
+	log := logrus.WithFields(logrus.Fields{
+		"tid": ts.ID,
+	})
+	log.Debug("hcsRecoveryBackend::cleanupTask")
+
+	if system, err := hrb.backend.OpenComputeSystem(ts.ID); err == nil {
+		if err := system.Terminate(); hcs.IsPending(err) {
+			system.Wait()
+		} else if err != nil && !hcs.IsAlreadyStopped(err) {
+			log.WithError(err).Warn("hcsRecoveryBackend::cleanupTask - failed to terminate container")
+		}
+		system.Close()
+	} else if !hcs.IsNotExist(err) {
+		log.WithError(err).Warn("hcsRecoveryBackend::cleanupTask - failed to open container")
+	}
+	if ts.Resources != nil {
+		r := hcsoci.NewResourcesFromState(ts.Resources)
+		var err error
+		if host == nil && ts.HostID != "" {
+			// The task ran in a host that is not open. Everything inside the
+			// host goes away with it so only release what is on the host.
+			err = hcsoci.ReleaseHostResources(r)
+		} else {
+			err = hcsoci.ReleaseResources(r, host, true)
+		}
+		if err != nil {
+			log.WithError(err).Warn("hcsRecoveryBackend::cleanupTask - failed to release container resources")
+		}
+	}
+}
+
+func (hrb hcsRecoveryBackend) cleanupHost(id string, host *uvm.UtilityVM) {
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: id,
+	})
+	log.Debug("hcsRecoveryBackend::cleanupHost")
+
+	if host != nil {
+		if err := host.Close(); err != nil {
+			log.WithError(err).Warn("hcsRecoveryBackend::cleanupHost - failed to close host")
+		}
+		return
+	}
+	system, err := hrb.backend.OpenComputeSystem(id)
+	if err != nil {
+		if !hcs.IsNotExist(err) {
+			log.WithError(err).Warn("hcsRecoveryBackend::cleanupHost - failed to open host")
+		}
+		return
+	}
+	if err := system.Terminate(); hcs.IsPending(err) {
+		system.Wait()
+	} else if err != nil && !hcs.IsAlreadyStopped(err) {
+		log.WithError(err).Warn("hcsRecoveryBackend::cleanupHost - failed to terminate host")
+	}
+	system.Close()
+}
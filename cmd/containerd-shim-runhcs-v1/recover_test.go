@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/cow/fake"
+	"github.com/Microsoft/hcsshim/internal/hcsoci"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+	eventstypes "github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/runtime"
+)
+
+var _ = (recoveryBackend)(&testRecoveryBackend{})
+
+// testRecoveryBackend is a fake HCS. Only the compute systems in `running` can
+// be opened.
+type testRecoveryBackend struct {
+	running map[string]bool
+
+	cleanedTasks []string
+	cleanedHosts []string
+}
+
+func (trb *testRecoveryBackend) openHost(m *uvm.CheckpointManifest) (*uvm.UtilityVM, error) {
+	if !trb.running[m.ID] {
+		return nil, errors.New("host not running")
+	}
+	return &uvm.UtilityVM{}, nil
+}
+
+func (trb *testRecoveryBackend) openTask(ctx context.Context, events publisher, state *shimStateStore, host *uvm.UtilityVM, ts *taskState) (shimTask, error) {
+	if !trb.running[ts.ID] {
+		return nil, errors.New("task not running")
+	}
+	t := &testShimTask{
+		id:    ts.ID,
+		exec:  newTestShimExec(ts.ID, ts.ID, ts.Init.Pid),
+		execs: make(map[string]*testShimExec),
+	}
+	t.exec.state = ts.Init.State
+	for _, es := range ts.Execs {
+		e := newTestShimExec(ts.ID, es.ID, es.Pid)
+		e.state = es.State
+		t.execs[es.ID] = e
+	}
+	return t, nil
+}
+
+func (trb *testRecoveryBackend) cleanupTask(ts *taskState, host *uvm.UtilityVM) {
+	trb.cleanedTasks = append(trb.cleanedTasks, ts.ID)
+}
+
+func (trb *testRecoveryBackend) cleanupHost(id string, host *uvm.UtilityVM) {
+	trb.cleanedHosts = append(trb.cleanedHosts, id)
+}
+
+func newTestTaskState(id string, state shimExecState) *taskState {
+	return &taskState{
+		ID:     id,
+		Bundle: "C:\\" + id,
+		Init: execState{
+			ID:    id,
+			Pid:   10,
+			State: state,
+		},
+	}
+}
+
+func newTestHostManifest(id string) *uvm.CheckpointManifest {
+	return &uvm.CheckpointManifest{
+		ID:              id,
+		OperatingSystem: "linux",
+		Document:        json.RawMessage(`{}`),
+	}
+}
+
+func writeTestState(t *testing.T, ss *shimStateStore, ps *podState, tasks ...*taskState) {
+	if ps != nil {
+		if err := ss.writePod(ps); err != nil {
+			t.Fatalf("failed to write pod state: %v", err)
+		}
+	}
+	for _, ts := range tasks {
+		if err := ss.writeTask(ts); err != nil {
+			t.Fatalf("failed to write task state: %v", err)
+		}
+	}
+}
+
+func verifyStateRemoved(t *testing.T, ss *shimStateStore) {
+	ps, tasks, err := ss.read()
+	if err != nil {
+		t.Fatalf("failed to read state: %v", err)
+	}
+	if ps != nil || len(tasks) != 0 {
+		t.Fatalf("expected state to be removed, got pod: %+v, tasks: %+v", ps, tasks)
+	}
+}
+
+func verifyCleaned(t *testing.T, expected, actual []string) {
+	sort.Strings(actual)
+	if len(expected) == 0 && len(actual) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("expected cleaned: %v, got: %v", expected, actual)
+	}
+}
+
+func Test_recoverState_NotPersisted(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	trb := &testRecoveryBackend{}
+	v, err := recoverState(context.TODO(), fakePublisher, ss, nil, trb)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if v != nil {
+		t.Fatalf("expected nothing recovered, got: %v", v)
+	}
+}
+
+func Test_recoverState_StandaloneTask_Success(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	ts := newTestTaskState(t.Name(), shimExecStateRunning)
+	ts.Execs = []execState{{ID: "exec", Pid: 20, State: shimExecStateRunning}}
+	writeTestState(t, ss, nil, ts)
+
+	trb := &testRecoveryBackend{running: map[string]bool{t.Name(): true}}
+	v, err := recoverState(context.TODO(), fakePublisher, ss, nil, trb)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	st, ok := v.(shimTask)
+	if !ok {
+		t.Fatalf("expected shimTask, got: %T", v)
+	}
+	if st.ID() != t.Name() {
+		t.Fatalf("expected task id: '%s', got: '%s'", t.Name(), st.ID())
+	}
+	e, err := st.GetExec("exec")
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if e.State() != shimExecStateRunning {
+		t.Fatalf("expected exec state: '%s', got: '%s'", shimExecStateRunning, e.State())
+	}
+	verifyCleaned(t, nil, trb.cleanedTasks)
+	verifyCleaned(t, nil, trb.cleanedHosts)
+}
+
+func Test_recoverState_StandaloneTask_Exited_Cleanup(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	writeTestState(t, ss, nil, newTestTaskState(t.Name(), shimExecStateExited))
+
+	trb := &testRecoveryBackend{running: map[string]bool{t.Name(): true}}
+	v, err := recoverState(context.TODO(), fakePublisher, ss, nil, trb)
+	if err == nil {
+		t.Fatalf("expected error, got: %v", v)
+	}
+	verifyCleaned(t, []string{t.Name()}, trb.cleanedTasks)
+	verifyCleaned(t, nil, trb.cleanedHosts)
+	verifyStateRemoved(t, ss)
+}
+
+func Test_recoverState_StandaloneTask_NotRunning_Cleanup(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	writeTestState(t, ss, nil, newTestTaskState(t.Name(), shimExecStateRunning))
+
+	trb := &testRecoveryBackend{}
+	v, err := recoverState(context.TODO(), fakePublisher, ss, nil, trb)
+	if err == nil {
+		t.Fatalf("expected error, got: %v", v)
+	}
+	verifyCleaned(t, []string{t.Name()}, trb.cleanedTasks)
+	verifyCleaned(t, nil, trb.cleanedHosts)
+	verifyStateRemoved(t, ss)
+}
+
+func Test_recoverState_StandaloneTask_HostNotRunning_Cleanup(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	ts := newTestTaskState(t.Name(), shimExecStateRunning)
+	ts.HostID = t.Name() + "@vm"
+	ts.OwnsHost = true
+	ts.Host = newTestHostManifest(ts.HostID)
+	writeTestState(t, ss, nil, ts)
+
+	trb := &testRecoveryBackend{running: map[string]bool{t.Name(): true}}
+	v, err := recoverState(context.TODO(), fakePublisher, ss, nil, trb)
+	if err == nil {
+		t.Fatalf("expected error, got: %v", v)
+	}
+	verifyCleaned(t, []string{t.Name()}, trb.cleanedTasks)
+	verifyCleaned(t, []string{ts.HostID}, trb.cleanedHosts)
+	verifyStateRemoved(t, ss)
+}
+
+func Test_recoverState_Pod_Success(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	ps := &podState{
+		ID:               t.Name(),
+		SandboxBundle:    "C:\\" + t.Name(),
+		SandboxIsHcsTask: true,
+	}
+	writeTestState(
+		t,
+		ss,
+		ps,
+		newTestTaskState(t.Name(), shimExecStateRunning),
+		newTestTaskState("paused", shimExecStatePaused),
+		newTestTaskState("exited", shimExecStateExited),
+		newTestTaskState("gone", shimExecStateRunning))
+
+	trb := &testRecoveryBackend{
+		running: map[string]bool{
+			t.Name(): true,
+			"paused": true,
+			"exited": true,
+		},
+	}
+	v, err := recoverState(context.TODO(), fakePublisher, ss, nil, trb)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	p, ok := v.(shimPod)
+	if !ok {
+		t.Fatalf("expected shimPod, got: %T", v)
+	}
+	if p.ID() != t.Name() {
+		t.Fatalf("expected pod id: '%s', got: '%s'", t.Name(), p.ID())
+	}
+	if _, err := p.GetTask(t.Name()); err != nil {
+		t.Fatalf("should not have failed to get sandbox task with error: %v", err)
+	}
+	wt, err := p.GetTask("paused")
+	if err != nil {
+		t.Fatalf("should not have failed to get workload task with error: %v", err)
+	}
+	e, _ := wt.GetExec("")
+	if e.State() != shimExecStatePaused {
+		t.Fatalf("expected workload state: '%s', got: '%s'", shimExecStatePaused, e.State())
+	}
+	for _, tid := range []string{"exited", "gone"} {
+		if _, err := p.GetTask(tid); err == nil {
+			t.Fatalf("expected task: '%s' not to be recovered", tid)
+		}
+	}
+	verifyCleaned(t, []string{"exited", "gone"}, trb.cleanedTasks)
+	verifyCleaned(t, nil, trb.cleanedHosts)
+
+	// Only the state of the cleaned up tasks is removed.
+	actualPod, tasks, err := ss.read()
+	if err != nil {
+		t.Fatalf("failed to read state: %v", err)
+	}
+	if !reflect.DeepEqual(actualPod, ps) {
+		t.Fatalf("expected pod: %+v, got: %+v", ps, actualPod)
+	}
+	if len(tasks) != 2 || tasks[0].ID != "paused" || tasks[1].ID != t.Name() {
+		t.Fatalf("expected state for tasks: [paused %s], got: %+v", t.Name(), tasks)
+	}
+}
+
+func Test_recoverState_Pod_SandboxNotRunning_Cleanup(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	ps := &podState{
+		ID:               t.Name(),
+		Host:             newTestHostManifest(t.Name() + "@vm"),
+		SandboxBundle:    "C:\\" + t.Name(),
+		SandboxIsHcsTask: true,
+	}
+	writeTestState(
+		t,
+		ss,
+		ps,
+		newTestTaskState(t.Name(), shimExecStateRunning),
+		newTestTaskState("workload", shimExecStateRunning))
+
+	trb := &testRecoveryBackend{
+		running: map[string]bool{
+			ps.Host.ID: true,
+			"workload": true,
+		},
+	}
+	v, err := recoverState(context.TODO(), fakePublisher, ss, nil, trb)
+	if err == nil {
+		t.Fatalf("expected error, got: %v", v)
+	}
+	verifyCleaned(t, []string{t.Name(), "workload"}, trb.cleanedTasks)
+	verifyCleaned(t, []string{ps.Host.ID}, trb.cleanedHosts)
+	verifyStateRemoved(t, ss)
+}
+
+func Test_cleanupState(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	ps := &podState{
+		ID:   t.Name(),
+		Host: newTestHostManifest(t.Name() + "@vm"),
+	}
+	writeTestState(t, ss, ps, newTestTaskState("workload", shimExecStateRunning))
+
+	trb := &testRecoveryBackend{}
+	if err := cleanupState(ss, trb); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	verifyCleaned(t, []string{"workload"}, trb.cleanedTasks)
+	verifyCleaned(t, []string{ps.Host.ID}, trb.cleanedHosts)
+	verifyStateRemoved(t, ss)
+}
+
+func Test_cleanupState_HostedTask_NoHost(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	// A Xenon task whose host is gone. Its mounts in the host must not be
+	// released against a `nil` host.
+	ts := newTestTaskState(t.Name(), shimExecStateRunning)
+	ts.HostID = t.Name() + "@vm"
+	ts.OwnsHost = true
+	ts.Host = newTestHostManifest(ts.HostID)
+	ts.Resources = &hcsoci.ResourcesState{
+		ContainerRootInUVM: "/run/gcs/c/0",
+		Layers:             []string{"C:\\layer", "C:\\scratch"},
+		VSMBMounts:         []string{"C:\\vsmb"},
+		Plan9Mounts:        []*uvm.Plan9Share{{}},
+		SCSIMounts:         []string{"C:\\scsi.vhdx"},
+	}
+	writeTestState(t, ss, nil, ts)
+
+	backend := &fake.Backend{}
+	system, err := backend.CreateComputeSystem(context.Background(), ts.ID, nil)
+	if err != nil {
+		t.Fatalf("failed to create fake compute system: %v", err)
+	}
+	if err := cleanupState(ss, hcsRecoveryBackend{backend: backend}); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if !system.(*fake.ComputeSystem).Exited() {
+		t.Fatal("expected the task compute system to be terminated")
+	}
+	verifyStateRemoved(t, ss)
+}
+
+func Test_recoveryPublisher_OnlyExitUntilDone(t *testing.T) {
+	var topics []string
+	events, done := recoveryPublisher(func(topic string, event interface{}) {
+		topics = append(topics, topic)
+	})
+
+	events(runtime.TaskStartEventTopic, &eventstypes.TaskStart{})
+	events(runtime.TaskExitEventTopic, &eventstypes.TaskExit{})
+	done()
+	events(runtime.TaskStartEventTopic, &eventstypes.TaskStart{})
+
+	expected := []string{runtime.TaskExitEventTopic, runtime.TaskStartEventTopic}
+	if !reflect.DeepEqual(topics, expected) {
+		t.Fatalf("expected topics: %v, got: %v", expected, topics)
+	}
+}
+
+func Test_service_recover_Mismatch_Error(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	writeTestState(t, ss, nil, newTestTaskState(t.Name(), shimExecStateRunning))
+
+	s := service{
+		events:    fakePublisher,
+		state:     ss,
+		tid:       t.Name(),
+		isSandbox: true,
+	}
+	trb := &testRecoveryBackend{running: map[string]bool{t.Name(): true}}
+	if err := s.recover(context.TODO(), trb); err == nil {
+		t.Fatal("expected error")
+	}
+	if s.taskOrPod.Load() != nil {
+		t.Fatalf("expected nothing recovered, got: %v", s.taskOrPod.Load())
+	}
+	// The state is left alone.
+	_, tasks, _ := ss.read()
+	if len(tasks) != 1 {
+		t.Fatalf("expected state to be kept, got: %+v", tasks)
+	}
+}
+
+func Test_service_recover_StandaloneTask_Success(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	writeTestState(t, ss, nil, newTestTaskState(t.Name(), shimExecStateRunning))
+
+	s := service{
+		events: fakePublisher,
+		state:  ss,
+		tid:    t.Name(),
+	}
+	trb := &testRecoveryBackend{running: map[string]bool{t.Name(): true}}
+	if err := s.recover(context.TODO(), trb); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	st, err := s.getTask(t.Name())
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if st.ID() != t.Name() {
+		t.Fatalf("expected task id: '%s', got: '%s'", t.Name(), st.ID())
+	}
+}
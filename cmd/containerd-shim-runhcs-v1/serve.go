@@ -11,6 +11,9 @@ import (
 	"unsafe"
 
 	"github.com/Microsoft/go-winio"
+	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/logging"
 	"github.com/containerd/containerd/log"
 	"github.com/containerd/containerd/runtime/v2/task"
 	"github.com/containerd/ttrpc"
@@ -72,6 +75,19 @@ var serveCommand = cli.Command{
 			FullTimestamp:   true,
 		})
 
+		// Tag every log entry with the id of the pod or task served by this
+		// shim. The hook fires first so that every other hook sees the field.
+		idField := logfields.TaskID
+		if ctx.Bool("is-sandbox") {
+			idField = logfields.PodID
+		}
+		logging.AddHookFirst(logrus.StandardLogger(), logging.NewFieldsHook(logrus.Fields{
+			idField: idFlag,
+		}))
+
+		// Setup the event for toggling debug logging
+		setupLogLevelEvent()
+
 		// Setup the log listener
 		//
 		// TODO: JTERRY75 we need this to be the reconnect log listener or
@@ -100,11 +116,47 @@ var serveCommand = cli.Command{
 			logrus.SetOutput(a)
 		}()
 
+		// Setup the event publisher. Events are forwarded over a persistent
+		// ttrpc connection to containerd and fall back to exec'ing the
+		// publish binary if that fails.
+		forwarder := &ttrpcEventForwarder{
+			dial: func() (*ttrpc.Client, error) {
+				timeout := eventDialTimeout
+				conn, err := winio.DialPipe(addressFlag+".ttrpc", &timeout)
+				if err != nil {
+					return nil, err
+				}
+				return ttrpc.NewClient(conn), nil
+			},
+		}
+		defer forwarder.close()
+		events := newEventPublisher(namespaceFlag, forwarder.forward, execPublish)
+		flushEvents := func() {
+			if err := events.close(eventFlushTimeout); err != nil {
+				logrus.WithError(err).Warn("containerd-shim: failed to flush events")
+			}
+		}
+		defer flushEvents()
+
+		// The shim is started with the bundle as its cwd. Persist the state of
+		// the task or pod there and reattach to any state left by a previous
+		// instance of this shim that exited unexpectedly.
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
 		// Setup the ttrpc server
 		svc := &service{
-			events:    publishEvent,
-			tid:       idFlag,
-			isSandbox: ctx.Bool("is-sandbox"),
+			events:      events.publish,
+			flushEvents: flushEvents,
+			state:       newShimStateStore(cwd),
+			backend:     hcs.Backend(),
+			tid:         idFlag,
+			isSandbox:   ctx.Bool("is-sandbox"),
+		}
+		if err := svc.recover(context.Background(), hcsRecoveryBackend{backend: svc.backend}); err != nil {
+			logrus.WithError(err).Warn("containerd-shim: failed to recover persisted state")
 		}
 		s, err := ttrpc.NewServer()
 		if err != nil {
@@ -195,6 +247,31 @@ func setupDebuggerEvent() {
 	return
 }
 
+// setupLogLevelEvent listens for an event which when signalled toggles the log
+// level between debug and the level it was at before it was last set to
+// debug.
+func setupLogLevelEvent() {
+	event := "Global\\loglevel-" + fmt.Sprint(os.Getpid())
+	handle, err := createEvent(event)
+	if err != nil {
+		return
+	}
+	go func() {
+		previous := logrus.InfoLevel
+		for {
+			windows.WaitForSingleObject(handle, windows.INFINITE)
+			if level := logrus.GetLevel(); level == logrus.DebugLevel {
+				logrus.SetLevel(previous)
+			} else {
+				previous = level
+				logrus.SetLevel(logrus.DebugLevel)
+			}
+			logrus.WithField("level", logrus.GetLevel()).Warn("containerd-shim: log level changed")
+		}
+	}()
+	return
+}
+
 // setupDumpStacks listens for an event which when signalled dumps the
 // stacks from this process to the log output.
 func setupDumpStacks() {
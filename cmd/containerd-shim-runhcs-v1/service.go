@@ -2,20 +2,39 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"sync/atomic"
 
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/trace"
 	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/runtime/v2/task"
 	google_protobuf1 "github.com/gogo/protobuf/types"
 	"github.com/sirupsen/logrus"
 )
 
-func beginActivity(activity string, fields logrus.Fields) {
+// beginActivity logs the start of `activity` and starts its span as a child of
+// the span in `ctx` (if any). `fields` are added to the span as attributes.
+//
+// The caller MUST pass the returned span to `endActivity` and SHOULD use the
+// returned context for the duration of the activity so that nested operations
+// are traced as part of it.
+func beginActivity(ctx context.Context, activity string, fields logrus.Fields) (context.Context, *trace.Span) {
 	logrus.WithFields(fields).Info(activity)
+
+	ctx, span := trace.StartSpan(ctx, "shim::"+activity)
+	for k, v := range fields {
+		span.AddAttributes(trace.StringAttribute(k, fmt.Sprint(v)))
+	}
+	return ctx, span
 }
 
-func endActivity(activity string, fields logrus.Fields, err error) {
+// endActivity logs the result of `activity` and ends `span`.
+func endActivity(span *trace.Span, activity string, fields logrus.Fields, err error) {
+	span.SetError(err)
+	span.End()
+
 	if err != nil {
 		fields["result"] = "Error"
 		fields[logrus.ErrorKey] = err
@@ -35,6 +54,15 @@ var _ = (task.TaskService)(&service{})
 
 type service struct {
 	events publisher
+	// flushEvents if not `nil` is called on `Shutdown` to deliver any events
+	// still queued by `events` before the shim exits.
+	flushEvents func()
+	// state if not `nil` is where the state of the task or pod served by this
+	// shim is persisted so that a restarted shim can reattach to it.
+	state *shimStateStore
+	// backend creates and opens the compute systems of the tasks served by
+	// this shim.
+	backend cow.Backend
 	// tid is the original task id to be served. This can either be a single
 	// task or represent the POD sandbox task id. The first call to Create MUST
 	// match this id or the shim is considered to be invalid.
@@ -59,6 +87,13 @@ type service struct {
 	// taken when creating tasks in a POD sandbox as they can happen
 	// concurrently.
 	cl sync.Mutex
+
+	// metricsOnce ensures the metrics endpoint is only started by the first
+	// call to `Create` with `Options.EnableMetrics`.
+	metricsOnce sync.Once
+	// fileLoggingOnce ensures file logging is only started by the first call
+	// to `Create` with `Options.DebugType` set to `FILE`.
+	fileLoggingOnce sync.Once
 }
 
 func (s *service) State(ctx context.Context, req *task.StateRequest) (_ *task.StateResponse, err error) {
@@ -68,8 +103,8 @@ func (s *service) State(ctx context.Context, req *task.StateRequest) (_ *task.St
 		"tid": req.ID,
 		"eid": req.ExecID,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.stateInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -78,7 +113,7 @@ func (s *service) State(ctx context.Context, req *task.StateRequest) (_ *task.St
 func (s *service) Create(ctx context.Context, req *task.CreateTaskRequest) (_ *task.CreateTaskResponse, err error) {
 	defer panicRecover()
 	const activity = "Create"
-	beginActivity(activity, logrus.Fields{
+	ctx, span := beginActivity(ctx, activity, logrus.Fields{
 		"tid":              req.ID,
 		"bundle":           req.Bundle,
 		"rootfs":           req.Rootfs,
@@ -90,7 +125,7 @@ func (s *service) Create(ctx context.Context, req *task.CreateTaskRequest) (_ *t
 		"parentcheckpoint": req.ParentCheckpoint,
 	})
 	defer func() {
-		endActivity(activity, logrus.Fields{
+		endActivity(span, activity, logrus.Fields{
 			"tid": req.ID,
 		}, err)
 	}()
@@ -106,8 +141,8 @@ func (s *service) Start(ctx context.Context, req *task.StartRequest) (_ *task.St
 		"tid": req.ID,
 		"eid": req.ExecID,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.startInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -120,8 +155,8 @@ func (s *service) Delete(ctx context.Context, req *task.DeleteRequest) (_ *task.
 		"tid": req.ID,
 		"eid": req.ExecID,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.deleteInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -133,8 +168,8 @@ func (s *service) Pids(ctx context.Context, req *task.PidsRequest) (_ *task.Pids
 	af := logrus.Fields{
 		"tid": req.ID,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.pidsInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -146,8 +181,8 @@ func (s *service) Pause(ctx context.Context, req *task.PauseRequest) (_ *google_
 	af := logrus.Fields{
 		"tid": req.ID,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.pauseInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -159,8 +194,8 @@ func (s *service) Resume(ctx context.Context, req *task.ResumeRequest) (_ *googl
 	af := logrus.Fields{
 		"tid": req.ID,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.resumeInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -173,8 +208,8 @@ func (s *service) Checkpoint(ctx context.Context, req *task.CheckpointTaskReques
 		"tid":  req.ID,
 		"path": req.Path,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.checkpointInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -189,8 +224,8 @@ func (s *service) Kill(ctx context.Context, req *task.KillRequest) (_ *google_pr
 		"signal": req.Signal,
 		"all":    req.All,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.killInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -207,8 +242,8 @@ func (s *service) Exec(ctx context.Context, req *task.ExecProcessRequest) (_ *go
 		"stdout":   req.Stdout,
 		"stderr":   req.Stderr,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.execInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -223,8 +258,8 @@ func (s *service) ResizePty(ctx context.Context, req *task.ResizePtyRequest) (_
 		"width":  req.Width,
 		"height": req.Height,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.resizePtyInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -238,8 +273,8 @@ func (s *service) CloseIO(ctx context.Context, req *task.CloseIORequest) (_ *goo
 		"eid":   req.ExecID,
 		"stdin": req.Stdin,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.closeIOInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -251,8 +286,8 @@ func (s *service) Update(ctx context.Context, req *task.UpdateTaskRequest) (_ *g
 	af := logrus.Fields{
 		"tid": req.ID,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.updateInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -265,8 +300,8 @@ func (s *service) Wait(ctx context.Context, req *task.WaitRequest) (_ *task.Wait
 		"tid": req.ID,
 		"eid": req.ExecID,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.waitInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -278,8 +313,8 @@ func (s *service) Stats(ctx context.Context, req *task.StatsRequest) (_ *task.St
 	af := logrus.Fields{
 		"tid": req.ID,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.statsInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -291,8 +326,8 @@ func (s *service) Connect(ctx context.Context, req *task.ConnectRequest) (_ *tas
 	af := logrus.Fields{
 		"tid": req.ID,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.connectInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -305,8 +340,8 @@ func (s *service) Shutdown(ctx context.Context, req *task.ShutdownRequest) (_ *g
 		"tid": req.ID,
 		"now": req.Now,
 	}
-	beginActivity(activity, af)
-	defer func() { endActivity(activity, af, err) }()
+	ctx, span := beginActivity(ctx, activity, af)
+	defer func() { endActivity(span, activity, af, err) }()
 
 	r, e := s.shutdownInternal(ctx, req)
 	return r, errdefs.ToGRPC(e)
@@ -8,7 +8,6 @@ import (
 	"strings"
 
 	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
-	"github.com/Microsoft/hcsshim/internal/oci"
 	containerd_v1_types "github.com/containerd/containerd/api/types/task"
 	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/mount"
@@ -83,6 +82,12 @@ func (s *service) createInternal(ctx context.Context, req *task.CreateTaskReques
 	if shimOpts != nil && shimOpts.Debug {
 		logrus.SetLevel(logrus.DebugLevel)
 	}
+	if shimOpts != nil && shimOpts.DebugType == runhcsopts.Options_FILE {
+		s.startFileLogging(shimOpts, req.Bundle)
+	}
+	if shimOpts != nil && shimOpts.EnableMetrics {
+		s.serveMetrics(shimOpts.MetricsAddress)
+	}
 
 	var spec specs.Spec
 	f, err := os.Open(filepath.Join(req.Bundle, "config.json"))
@@ -95,8 +100,6 @@ func (s *service) createInternal(ctx context.Context, req *task.CreateTaskReques
 	}
 	f.Close()
 
-	spec = oci.UpdateSpecFromOptions(spec, shimOpts)
-
 	if len(req.Rootfs) == 0 {
 		// If no mounts are passed via the snapshotter its the callers full
 		// responsibility to manage the storage. Just move on without affecting
@@ -152,6 +155,10 @@ func (s *service) createInternal(ctx context.Context, req *task.CreateTaskReques
 		return nil, errors.Wrap(errdefs.ErrFailedPrecondition, "if using terminal, stderr must be empty")
 	}
 
+	if s.isSandbox && req.Checkpoint != "" {
+		return nil, errors.Wrapf(errdefs.ErrNotImplemented, "restore of task: '%s' in a POD is not supported", req.ID)
+	}
+
 	resp := &task.CreateTaskResponse{}
 	s.cl.Lock()
 	if s.isSandbox {
@@ -167,7 +174,7 @@ func (s *service) createInternal(ctx context.Context, req *task.CreateTaskReques
 			resp.Pid = uint32(e.Pid())
 			return resp, nil
 		}
-		pod, err = createPod(ctx, s.events, req, &spec)
+		pod, err = createPod(ctx, s.events, s.state, s.backend, req, &spec, shimOpts)
 		if err != nil {
 			s.cl.Unlock()
 			return nil, err
@@ -177,7 +184,7 @@ func (s *service) createInternal(ctx context.Context, req *task.CreateTaskReques
 		resp.Pid = uint32(e.Pid())
 		s.taskOrPod.Store(pod)
 	} else {
-		t, err := newHcsStandaloneTask(ctx, s.events, req, &spec)
+		t, err := newHcsStandaloneTask(ctx, s.events, s.state, s.backend, req, &spec, shimOpts)
 		if err != nil {
 			s.cl.Unlock()
 			return nil, err
@@ -199,6 +206,9 @@ func (s *service) startInternal(ctx context.Context, req *task.StartRequest) (*t
 	if err != nil {
 		return nil, err
 	}
+	if err := s.verifyNotPaused(t); err != nil {
+		return nil, errors.Wrapf(err, "exec: '%s' in task: '%s' cannot be started", req.ExecID, req.ID)
+	}
 	err = e.Start(ctx)
 	if err != nil {
 		return nil, err
@@ -253,32 +263,88 @@ func (s *service) pidsInternal(ctx context.Context, req *task.PidsRequest) (*tas
 	}, nil
 }
 
+// verifyNotPaused returns `errdefs.ErrFailedPrecondition` if task `t` or, when
+// running as a sandbox, the pod sandbox task hosting it is paused.
+func (s *service) verifyNotPaused(t shimTask) error {
+	if e, _ := t.GetExec(""); e.State() == shimExecStatePaused {
+		return errors.Wrapf(errdefs.ErrFailedPrecondition, "task: '%s' is paused", t.ID())
+	}
+	if s.isSandbox && t.ID() != s.tid {
+		st, err := s.getTask(s.tid)
+		if err != nil {
+			return err
+		}
+		if e, _ := st.GetExec(""); e.State() == shimExecStatePaused {
+			return errors.Wrapf(errdefs.ErrFailedPrecondition, "pod sandbox task: '%s' is paused", s.tid)
+		}
+	}
+	return nil
+}
+
 func (s *service) pauseInternal(ctx context.Context, req *task.PauseRequest) (*google_protobuf1.Empty, error) {
-	/*
-		s.events <- cdevent{
-			topic: runtime.TaskPausedEventTopic,
-			event: &eventstypes.TaskPaused{
-				req.ID,
-			},
+	if s.isSandbox {
+		pod, err := s.getPod()
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrNotFound, "%v: task with id: '%s' not found", err, req.ID)
 		}
-	*/
-	return nil, errdefs.ErrNotImplemented
+		err = pod.PauseTask(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		return empty, nil
+	}
+	t, err := s.getTask(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = t.Pause(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return empty, nil
 }
 
 func (s *service) resumeInternal(ctx context.Context, req *task.ResumeRequest) (*google_protobuf1.Empty, error) {
-	/*
-		s.events <- cdevent{
-			topic: runtime.TaskResumedEventTopic,
-			event: &eventstypes.TaskResumed{
-				req.ID,
-			},
+	if s.isSandbox {
+		pod, err := s.getPod()
+		if err != nil {
+			return nil, errors.Wrapf(errdefs.ErrNotFound, "%v: task with id: '%s' not found", err, req.ID)
 		}
-	*/
-	return nil, errdefs.ErrNotImplemented
+		err = pod.ResumeTask(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		return empty, nil
+	}
+	t, err := s.getTask(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = t.Resume(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return empty, nil
 }
 
 func (s *service) checkpointInternal(ctx context.Context, req *task.CheckpointTaskRequest) (*google_protobuf1.Empty, error) {
-	return nil, errdefs.ErrNotImplemented
+	if s.isSandbox {
+		// The UVM is shared by every task in the POD which cannot be restored
+		// individually.
+		return nil, errors.Wrapf(errdefs.ErrNotImplemented, "checkpoint of task: '%s' in a POD is not supported", req.ID)
+	}
+	if req.Path == "" {
+		return nil, errors.Wrap(errdefs.ErrFailedPrecondition, "checkpoint path must be set")
+	}
+	t, err := s.getTask(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = t.Checkpoint(ctx, req.Path)
+	if err != nil {
+		return nil, err
+	}
+	return empty, nil
 }
 
 func (s *service) killInternal(ctx context.Context, req *task.KillRequest) (*google_protobuf1.Empty, error) {
@@ -318,6 +384,9 @@ func (s *service) execInternal(ctx context.Context, req *task.ExecProcessRequest
 	if err := json.Unmarshal(req.Spec.Value, &spec); err != nil {
 		return nil, errors.Wrap(err, "request.Spec was not oci process")
 	}
+	if err := s.verifyNotPaused(t); err != nil {
+		return nil, errors.Wrapf(err, "exec: '%s' in task: '%s' cannot be created", req.ExecID, req.ID)
+	}
 	err = t.CreateExec(ctx, req, &spec)
 	if err != nil {
 		return nil, err
@@ -383,6 +452,19 @@ func (s *service) waitInternal(ctx context.Context, req *task.WaitRequest) (*tas
 }
 
 func (s *service) statsInternal(ctx context.Context, req *task.StatsRequest) (*task.StatsResponse, error) {
+	if s.isSandbox {
+		// The only stats available are the resource accounting of a
+		// hypervisor isolated pod which is returned for the sandbox task.
+		if p, err := s.getPod(); err == nil && p.ID() == req.ID {
+			if r := p.Resources(); r != nil {
+				a, err := typeurl.MarshalAny(r)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to marshal PodResources for pod: %s", req.ID)
+				}
+				return &task.StatsResponse{Stats: a}, nil
+			}
+		}
+	}
 	return nil, errdefs.ErrNotImplemented
 }
 
@@ -402,6 +484,19 @@ func (s *service) shutdownInternal(ctx context.Context, req *task.ShutdownReques
 		return empty, nil
 	}
 
+	// Deliver any pending events such as the final `TaskExit` and
+	// `TaskDelete` before the shim exits.
+	if s.flushEvents != nil {
+		s.flushEvents()
+	}
+
+	// The shim is exiting. There is nothing left to reattach to.
+	if s.state != nil {
+		if err := s.state.remove(); err != nil {
+			logrus.WithError(err).Warn("failed to remove shim state")
+		}
+	}
+
 	if req.Now {
 		os.Exit(0)
 	}
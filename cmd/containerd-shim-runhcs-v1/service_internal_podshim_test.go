@@ -10,6 +10,7 @@ import (
 	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/runtime/v2/task"
 	"github.com/containerd/typeurl"
+	"github.com/gogo/protobuf/types"
 )
 
 func setupPodServiceWithFakes(t *testing.T) (*service, *testShimTask, *testShimTask, *testShimExec) {
@@ -381,7 +382,7 @@ func Test_PodShim_pidsInternal_2ndTaskID_Success(t *testing.T) {
 	}
 }
 
-func Test_PodShim_pauseInternal_Error(t *testing.T) {
+func Test_PodShim_pauseInternal_NoTask_Error(t *testing.T) {
 	s := service{
 		tid:       t.Name(),
 		isSandbox: true,
@@ -389,10 +390,44 @@ func Test_PodShim_pauseInternal_Error(t *testing.T) {
 
 	resp, err := s.pauseInternal(context.TODO(), &task.PauseRequest{ID: t.Name()})
 
-	verifyExpectedError(t, resp, err, errdefs.ErrNotImplemented)
+	verifyExpectedError(t, resp, err, errdefs.ErrNotFound)
+}
+
+func Test_PodShim_pauseInternal_InitTaskID_NotRunning_Error(t *testing.T) {
+	s, t1, _, _ := setupPodServiceWithFakes(t)
+
+	resp, err := s.pauseInternal(context.TODO(), &task.PauseRequest{ID: t1.ID()})
+
+	verifyExpectedError(t, resp, err, errdefs.ErrFailedPrecondition)
 }
 
-func Test_PodShim_resumeInternal_Error(t *testing.T) {
+func Test_PodShim_pauseInternal_InitTaskID_Success(t *testing.T) {
+	s, t1, _, _ := setupPodServiceWithFakes(t)
+	t1.exec.Start(context.TODO())
+
+	resp, err := s.pauseInternal(context.TODO(), &task.PauseRequest{ID: t1.ID()})
+	if err != nil {
+		t.Fatalf("should not have failed with error got: %v", err)
+	}
+	if resp == nil {
+		t.Fatal("should have returned PauseResponse")
+	}
+	if t1.exec.State() != shimExecStatePaused {
+		t.Fatalf("init exec should be paused, got: %v", t1.exec.State())
+	}
+}
+
+func Test_PodShim_startInternal_SandboxPaused_Error(t *testing.T) {
+	s, t1, t2, _ := setupPodServiceWithFakes(t)
+	t1.exec.Start(context.TODO())
+	t1.exec.Pause(context.TODO())
+
+	resp, err := s.startInternal(context.TODO(), &task.StartRequest{ID: t2.ID()})
+
+	verifyExpectedError(t, resp, err, errdefs.ErrFailedPrecondition)
+}
+
+func Test_PodShim_resumeInternal_NoTask_Error(t *testing.T) {
 	s := service{
 		tid:       t.Name(),
 		isSandbox: true,
@@ -400,7 +435,33 @@ func Test_PodShim_resumeInternal_Error(t *testing.T) {
 
 	resp, err := s.resumeInternal(context.TODO(), &task.ResumeRequest{ID: t.Name()})
 
-	verifyExpectedError(t, resp, err, errdefs.ErrNotImplemented)
+	verifyExpectedError(t, resp, err, errdefs.ErrNotFound)
+}
+
+func Test_PodShim_resumeInternal_InitTaskID_NotPaused_Error(t *testing.T) {
+	s, t1, _, _ := setupPodServiceWithFakes(t)
+	t1.exec.Start(context.TODO())
+
+	resp, err := s.resumeInternal(context.TODO(), &task.ResumeRequest{ID: t1.ID()})
+
+	verifyExpectedError(t, resp, err, errdefs.ErrFailedPrecondition)
+}
+
+func Test_PodShim_resumeInternal_InitTaskID_Success(t *testing.T) {
+	s, t1, _, _ := setupPodServiceWithFakes(t)
+	t1.exec.Start(context.TODO())
+	t1.exec.Pause(context.TODO())
+
+	resp, err := s.resumeInternal(context.TODO(), &task.ResumeRequest{ID: t1.ID()})
+	if err != nil {
+		t.Fatalf("should not have failed with error got: %v", err)
+	}
+	if resp == nil {
+		t.Fatal("should have returned ResumeResponse")
+	}
+	if t1.exec.State() != shimExecStateRunning {
+		t.Fatalf("init exec should be running, got: %v", t1.exec.State())
+	}
 }
 
 func Test_PodShim_checkpointInternal_Error(t *testing.T) {
@@ -466,6 +527,20 @@ func Test_PodShim_killInternal_2ndTaskID_2ndExecID_Success(t *testing.T) {
 	}
 }
 
+func Test_PodShim_execInternal_SandboxPaused_Error(t *testing.T) {
+	s, t1, t2, _ := setupPodServiceWithFakes(t)
+	t1.exec.Start(context.TODO())
+	t1.exec.Pause(context.TODO())
+
+	resp, err := s.execInternal(context.TODO(), &task.ExecProcessRequest{
+		ID:     t2.ID(),
+		ExecID: "exec",
+		Spec:   &types.Any{Value: []byte(`{"args":["cmd"]}`)},
+	})
+
+	verifyExpectedError(t, resp, err, errdefs.ErrFailedPrecondition)
+}
+
 // TODO: Test_PodShim_execInternal_*
 
 func Test_PodShim_resizePtyInternal_NoTask_Error(t *testing.T) {
@@ -651,3 +726,27 @@ func Test_PodShim_statsInternal_Error(t *testing.T) {
 
 	verifyExpectedError(t, resp, err, errdefs.ErrNotImplemented)
 }
+
+func Test_PodShim_statsInternal_PodResources_Success(t *testing.T) {
+	s, t1, _, _ := setupPodServiceWithFakes(t)
+	p, _ := s.getPod()
+	expected := &options.PodResources{
+		ProcessorCapacityMillis:  2000,
+		ProcessorRequestedMillis: 500,
+		MemoryCapacityInMb:       1024,
+		MemoryRequestedInMb:      256,
+	}
+	p.(*testShimPod).resources = expected
+
+	resp, err := s.statsInternal(context.TODO(), &task.StatsRequest{ID: t1.ID()})
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	v, err := typeurl.UnmarshalAny(resp.Stats)
+	if err != nil {
+		t.Fatalf("failed to unmarshal stats: %v", err)
+	}
+	if r, ok := v.(*options.PodResources); !ok || *r != *expected {
+		t.Fatalf("expected stats: %+v, got: %+v", expected, v)
+	}
+}
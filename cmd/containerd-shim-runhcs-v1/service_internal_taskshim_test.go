@@ -10,6 +10,7 @@ import (
 	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/runtime/v2/task"
 	"github.com/containerd/typeurl"
+	"github.com/gogo/protobuf/types"
 )
 
 func setupTaskServiceWithFakes(t *testing.T) (*service, *testShimTask, *testShimExec) {
@@ -301,7 +302,7 @@ func Test_TaskShim_pidsInternal_InitTaskID_Success(t *testing.T) {
 	}
 }
 
-func Test_TaskShim_pauseInternal_Error(t *testing.T) {
+func Test_TaskShim_pauseInternal_NoTask_Error(t *testing.T) {
 	s := service{
 		tid:       t.Name(),
 		isSandbox: true,
@@ -309,10 +310,46 @@ func Test_TaskShim_pauseInternal_Error(t *testing.T) {
 
 	resp, err := s.pauseInternal(context.TODO(), &task.PauseRequest{ID: t.Name()})
 
-	verifyExpectedError(t, resp, err, errdefs.ErrNotImplemented)
+	verifyExpectedError(t, resp, err, errdefs.ErrNotFound)
+}
+
+func Test_TaskShim_pauseInternal_InitTaskID_NotRunning_Error(t *testing.T) {
+	s, t1, _ := setupTaskServiceWithFakes(t)
+
+	resp, err := s.pauseInternal(context.TODO(), &task.PauseRequest{ID: t1.ID()})
+
+	verifyExpectedError(t, resp, err, errdefs.ErrFailedPrecondition)
+}
+
+func Test_TaskShim_pauseInternal_InitTaskID_Success(t *testing.T) {
+	s, t1, _ := setupTaskServiceWithFakes(t)
+	t1.exec.Start(context.TODO())
+
+	resp, err := s.pauseInternal(context.TODO(), &task.PauseRequest{ID: t1.ID()})
+	if err != nil {
+		t.Fatalf("should not have failed with error got: %v", err)
+	}
+	if resp == nil {
+		t.Fatal("should have returned PauseResponse")
+	}
+	if t1.exec.State() != shimExecStatePaused {
+		t.Fatalf("init exec should be paused, got: %v", t1.exec.State())
+	}
+}
+
+func Test_TaskShim_startInternal_Paused_Error(t *testing.T) {
+	s, t1, _ := setupTaskServiceWithFakes(t)
+	t1.exec.Start(context.TODO())
+	t1.exec.Pause(context.TODO())
+
+	for eid := range t1.execs {
+		resp, err := s.startInternal(context.TODO(), &task.StartRequest{ID: t1.ID(), ExecID: eid})
+
+		verifyExpectedError(t, resp, err, errdefs.ErrFailedPrecondition)
+	}
 }
 
-func Test_TaskShim_resumeInternal_Error(t *testing.T) {
+func Test_TaskShim_resumeInternal_NoTask_Error(t *testing.T) {
 	s := service{
 		tid:       t.Name(),
 		isSandbox: true,
@@ -320,7 +357,33 @@ func Test_TaskShim_resumeInternal_Error(t *testing.T) {
 
 	resp, err := s.resumeInternal(context.TODO(), &task.ResumeRequest{ID: t.Name()})
 
-	verifyExpectedError(t, resp, err, errdefs.ErrNotImplemented)
+	verifyExpectedError(t, resp, err, errdefs.ErrNotFound)
+}
+
+func Test_TaskShim_resumeInternal_InitTaskID_NotPaused_Error(t *testing.T) {
+	s, t1, _ := setupTaskServiceWithFakes(t)
+	t1.exec.Start(context.TODO())
+
+	resp, err := s.resumeInternal(context.TODO(), &task.ResumeRequest{ID: t1.ID()})
+
+	verifyExpectedError(t, resp, err, errdefs.ErrFailedPrecondition)
+}
+
+func Test_TaskShim_resumeInternal_InitTaskID_Success(t *testing.T) {
+	s, t1, _ := setupTaskServiceWithFakes(t)
+	t1.exec.Start(context.TODO())
+	t1.exec.Pause(context.TODO())
+
+	resp, err := s.resumeInternal(context.TODO(), &task.ResumeRequest{ID: t1.ID()})
+	if err != nil {
+		t.Fatalf("should not have failed with error got: %v", err)
+	}
+	if resp == nil {
+		t.Fatal("should have returned ResumeResponse")
+	}
+	if t1.exec.State() != shimExecStateRunning {
+		t.Fatalf("init exec should be running, got: %v", t1.exec.State())
+	}
 }
 
 func Test_TaskShim_checkpointInternal_Error(t *testing.T) {
@@ -334,6 +397,47 @@ func Test_TaskShim_checkpointInternal_Error(t *testing.T) {
 	verifyExpectedError(t, resp, err, errdefs.ErrNotImplemented)
 }
 
+func Test_TaskShim_checkpointInternal_NoTask_Error(t *testing.T) {
+	s := service{
+		tid:       t.Name(),
+		isSandbox: false,
+	}
+
+	resp, err := s.checkpointInternal(context.TODO(), &task.CheckpointTaskRequest{ID: t.Name(), Path: t.Name()})
+
+	verifyExpectedError(t, resp, err, errdefs.ErrNotFound)
+}
+
+func Test_TaskShim_checkpointInternal_NoPath_Error(t *testing.T) {
+	s, t1, _ := setupTaskServiceWithFakes(t)
+	t1.exec.Start(context.TODO())
+
+	resp, err := s.checkpointInternal(context.TODO(), &task.CheckpointTaskRequest{ID: t1.ID()})
+
+	verifyExpectedError(t, resp, err, errdefs.ErrFailedPrecondition)
+}
+
+func Test_TaskShim_checkpointInternal_NotRunning_Error(t *testing.T) {
+	s, t1, _ := setupTaskServiceWithFakes(t)
+
+	resp, err := s.checkpointInternal(context.TODO(), &task.CheckpointTaskRequest{ID: t1.ID(), Path: t.Name()})
+
+	verifyExpectedError(t, resp, err, errdefs.ErrFailedPrecondition)
+}
+
+func Test_TaskShim_checkpointInternal_Success(t *testing.T) {
+	s, t1, _ := setupTaskServiceWithFakes(t)
+	t1.exec.Start(context.TODO())
+
+	resp, err := s.checkpointInternal(context.TODO(), &task.CheckpointTaskRequest{ID: t1.ID(), Path: t.Name()})
+	if err != nil {
+		t.Fatalf("should not have failed with error got: %v", err)
+	}
+	if resp == nil {
+		t.Fatal("should have returned CheckpointResponse")
+	}
+}
+
 func Test_TaskShim_killInternal_NoTask_Error(t *testing.T) {
 	s := service{
 		tid:       t.Name(),
@@ -386,6 +490,20 @@ func Test_TaskShim_killInternal_InitTaskID_2ndExecID_Success(t *testing.T) {
 	}
 }
 
+func Test_TaskShim_execInternal_Paused_Error(t *testing.T) {
+	s, t1, _ := setupTaskServiceWithFakes(t)
+	t1.exec.Start(context.TODO())
+	t1.exec.Pause(context.TODO())
+
+	resp, err := s.execInternal(context.TODO(), &task.ExecProcessRequest{
+		ID:     t1.ID(),
+		ExecID: "exec",
+		Spec:   &types.Any{Value: []byte(`{"args":["cmd"]}`)},
+	})
+
+	verifyExpectedError(t, resp, err, errdefs.ErrFailedPrecondition)
+}
+
 // TODO: Test_TaskShim_execInternal_*
 
 func Test_TaskShim_resizePtyInternal_NoTask_Error(t *testing.T) {
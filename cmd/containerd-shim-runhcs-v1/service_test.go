@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/trace"
+	"github.com/sirupsen/logrus"
+)
+
+func Test_beginActivity_endActivity_ExportsSpan(t *testing.T) {
+	e := &trace.MemoryExporter{}
+	trace.RegisterExporter(e)
+	defer trace.UnregisterExporter(e)
+
+	af := logrus.Fields{
+		"tid": t.Name(),
+		"eid": "",
+	}
+	ctx, span := beginActivity(context.TODO(), "Start", af)
+	_, child := trace.StartSpan(ctx, "child")
+	child.End()
+	expected := errors.New("start failed")
+	endActivity(span, "Start", af, expected)
+
+	sd := e.Span("shim::Start")
+	if sd == nil {
+		t.Fatalf("expected span 'shim::Start', got: %+v", e.Spans())
+	}
+	if sd.Attributes["tid"] != t.Name() {
+		t.Fatalf("expected attribute tid: '%s', got: '%v'", t.Name(), sd.Attributes["tid"])
+	}
+	if sd.Err != expected.Error() {
+		t.Fatalf("expected error: '%v', got: '%s'", expected, sd.Err)
+	}
+	if cd := e.Span("child"); cd == nil || cd.ParentSpanID != sd.SpanID {
+		t.Fatalf("expected child of 'shim::Start', got: %+v", cd)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/atomicfile"
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/hcsoci"
+	"github.com/Microsoft/hcsshim/internal/podresources"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// shimStateDir is the directory in the bundle of the task or pod sandbox
+	// served by this shim where the state of every task is persisted.
+	shimStateDir = "runhcs-state"
+	// podStateFile is the file name of the pod state in `shimStateDir`.
+	podStateFile = "pod.json"
+	// taskStateFilePrefix is the prefix of the file name of each task state in
+	// `shimStateDir`. The file name is `task-<tid>.json`.
+	taskStateFilePrefix = "task-"
+)
+
+// execState is the persisted state of a single exec.
+type execState struct {
+	ID         string         `json:"ID"`
+	Pid        int            `json:"Pid,omitempty"`
+	State      shimExecState  `json:"State"`
+	Stdin      string         `json:"Stdin,omitempty"`
+	Stdout     string         `json:"Stdout,omitempty"`
+	Stderr     string         `json:"Stderr,omitempty"`
+	Terminal   bool           `json:"Terminal,omitempty"`
+	ExitStatus uint32         `json:"ExitStatus"`
+	ExitReason cow.ExitReason `json:"ExitReason,omitempty"`
+	ExitedAt   time.Time      `json:"ExitedAt,omitempty"`
+}
+
+// taskState is the persisted state of a task and all of its execs.
+type taskState struct {
+	// ID is the id of the task and its compute system.
+	ID     string `json:"ID"`
+	Bundle string `json:"Bundle"`
+	IsWCOW bool   `json:"IsWCOW,omitempty"`
+	// HostID is the id of the UtilityVM hosting the task if hypervisor
+	// isolated.
+	HostID string `json:"HostID,omitempty"`
+	// OwnsHost is `true` if the task owns its host. In that case `Host`
+	// describes the host.
+	OwnsHost  bool                    `json:"OwnsHost,omitempty"`
+	Host      *uvm.CheckpointManifest `json:"Host,omitempty"`
+	Resources *hcsoci.ResourcesState  `json:"Resources,omitempty"`
+	// Init is the state of the init exec.
+	Init execState `json:"Init"`
+	// Execs are the states of all additional execs ordered by id.
+	Execs []execState `json:"Execs,omitempty"`
+}
+
+// podState is the persisted state of a pod. The tasks in the pod are
+// persisted as `taskState`s.
+type podState struct {
+	// ID is the id of the pod sandbox task.
+	ID string `json:"ID"`
+	// Host describes the UtilityVM hosting the pod if hypervisor isolated.
+	Host *uvm.CheckpointManifest `json:"Host,omitempty"`
+	// SandboxBundle and SandboxIsHcsTask describe the sandbox task. If
+	// `SandboxIsHcsTask == false` the sandbox is a WCOW pod sandbox task with
+	// no container.
+	SandboxBundle    string `json:"SandboxBundle"`
+	SandboxIsHcsTask bool   `json:"SandboxIsHcsTask,omitempty"`
+	// Requests are the resources reserved for each workload task in `Host`.
+	Requests map[string]podresources.Resources `json:"Requests,omitempty"`
+}
+
+// shimStateStore persists the state of a pod and its tasks to a directory.
+// Every write is atomic so that a crash at any point leaves either the
+// previous or the new state on disk.
+type shimStateStore struct {
+	dir string
+}
+
+func newShimStateStore(bundle string) *shimStateStore {
+	return &shimStateStore{dir: filepath.Join(bundle, shimStateDir)}
+}
+
+func (ss *shimStateStore) taskPath(tid string) string {
+	return filepath.Join(ss.dir, taskStateFilePrefix+tid+".json")
+}
+
+// writeTask atomically persists `ts`.
+func (ss *shimStateStore) writeTask(ts *taskState) error {
+	return ss.write(ss.taskPath(ts.ID), ts)
+}
+
+// removeTask removes the persisted state of task `tid`. It is not an error if
+// no state exists.
+func (ss *shimStateStore) removeTask(tid string) error {
+	if err := os.Remove(ss.taskPath(tid)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// writePod atomically persists `ps`.
+func (ss *shimStateStore) writePod(ps *podState) error {
+	return ss.write(filepath.Join(ss.dir, podStateFile), ps)
+}
+
+// read returns the persisted pod state if any and all persisted task states
+// ordered by id. If nothing has been persisted returns `nil, nil, nil`.
+func (ss *shimStateStore) read() (*podState, []*taskState, error) {
+	fis, err := ioutil.ReadDir(ss.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	var (
+		ps    *podState
+		tasks []*taskState
+	)
+	for _, fi := range fis {
+		name := fi.Name()
+		switch {
+		case name == podStateFile:
+			ps = &podState{}
+			if err := readJSON(filepath.Join(ss.dir, name), ps); err != nil {
+				return nil, nil, err
+			}
+		case strings.HasPrefix(name, taskStateFilePrefix) && strings.HasSuffix(name, ".json"):
+			ts := &taskState{}
+			if err := readJSON(filepath.Join(ss.dir, name), ts); err != nil {
+				return nil, nil, err
+			}
+			tasks = append(tasks, ts)
+		}
+		// Else: ignore any partial writes left over from a crash.
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return ps, tasks, nil
+}
+
+// remove removes all persisted state.
+func (ss *shimStateStore) remove() error {
+	return os.RemoveAll(ss.dir)
+}
+
+func (ss *shimStateStore) write(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(ss.dir, 0700); err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, b, 0600)
+}
+
+func readJSON(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return errors.Wrapf(err, "failed to unmarshal state: '%s'", path)
+	}
+	return nil
+}
+
+// newExecState returns the persisted state of `e`.
+func newExecState(e shimExec) execState {
+	s := e.Status()
+	return execState{
+		ID:         e.ID(),
+		Pid:        e.Pid(),
+		State:      e.State(),
+		Stdin:      s.Stdin,
+		Stdout:     s.Stdout,
+		Stderr:     s.Stderr,
+		Terminal:   s.Terminal,
+		ExitStatus: s.ExitStatus,
+		ExitReason: e.ExitReason(),
+		ExitedAt:   s.ExitedAt,
+	}
+}
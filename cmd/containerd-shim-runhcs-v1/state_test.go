@@ -0,0 +1,180 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/hcsoci"
+)
+
+func setupTestShimStateStore(t *testing.T) (*shimStateStore, func()) {
+	dir, err := ioutil.TempDir("", "shimstate")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	return newShimStateStore(dir), func() { os.RemoveAll(dir) }
+}
+
+func Test_shimStateStore_Read_NotPersisted(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	ps, tasks, err := ss.read()
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if ps != nil || len(tasks) != 0 {
+		t.Fatalf("expected no state, got pod: %+v, tasks: %+v", ps, tasks)
+	}
+}
+
+func Test_shimStateStore_RoundTrip(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	expectedPod := &podState{
+		ID:               t.Name(),
+		SandboxBundle:    "C:\\bundle",
+		SandboxIsHcsTask: true,
+	}
+	expectedTasks := []*taskState{
+		{
+			ID:     "a",
+			Bundle: "C:\\bundle-a",
+			IsWCOW: true,
+			Init: execState{
+				ID:     "a",
+				Pid:    10,
+				State:  shimExecStateRunning,
+				Stdout: "\\\\.\\pipe\\a-stdout",
+			},
+			Execs: []execState{
+				{
+					ID:         "a-exec",
+					Pid:        20,
+					State:      shimExecStateExited,
+					ExitStatus: 1,
+				},
+			},
+		},
+		{
+			ID:     "b",
+			Bundle: "C:\\bundle-b",
+			Resources: &hcsoci.ResourcesState{
+				ContainerRootInUVM: "/run/gcs/c/b",
+				Layers:             []string{"C:\\layer"},
+			},
+			Init: execState{
+				ID:    "b",
+				State: shimExecStateCreated,
+			},
+		},
+	}
+	if err := ss.writePod(expectedPod); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	// Write out of order to verify the tasks are read ordered by id.
+	for i := len(expectedTasks) - 1; i >= 0; i-- {
+		if err := ss.writeTask(expectedTasks[i]); err != nil {
+			t.Fatalf("should not have failed with error: %v", err)
+		}
+	}
+
+	ps, tasks, err := ss.read()
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if !reflect.DeepEqual(ps, expectedPod) {
+		t.Fatalf("expected pod: %+v, got: %+v", expectedPod, ps)
+	}
+	if !reflect.DeepEqual(tasks, expectedTasks) {
+		t.Fatalf("expected tasks: %+v, got: %+v", expectedTasks, tasks)
+	}
+}
+
+func Test_shimStateStore_Read_IgnoresPartialWrite(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	ts := &taskState{
+		ID:   t.Name(),
+		Init: execState{ID: t.Name(), State: shimExecStateRunning},
+	}
+	if err := ss.writeTask(ts); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	// Simulate a crash in the middle of the next write.
+	if err := ioutil.WriteFile(ss.taskPath(t.Name())+".tmp", []byte(`{"ID":`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ps, tasks, err := ss.read()
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if ps != nil {
+		t.Fatalf("expected no pod state, got: %+v", ps)
+	}
+	if len(tasks) != 1 || !reflect.DeepEqual(tasks[0], ts) {
+		t.Fatalf("expected tasks: [%+v], got: %+v", ts, tasks)
+	}
+}
+
+func Test_shimStateStore_Read_Corrupt_Error(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	if err := os.MkdirAll(ss.dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(ss.dir, podStateFile), []byte(`{"ID":`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ps, tasks, err := ss.read()
+	if err == nil {
+		t.Fatalf("expected error, got pod: %+v, tasks: %+v", ps, tasks)
+	}
+}
+
+func Test_shimStateStore_RemoveTask(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	if err := ss.writeTask(&taskState{ID: t.Name()}); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := ss.removeTask(t.Name()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	// Removing twice is not an error.
+	if err := ss.removeTask(t.Name()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+
+	_, tasks, err := ss.read()
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if len(tasks) != 0 {
+		t.Fatalf("expected no tasks, got: %+v", tasks)
+	}
+}
+
+func Test_shimStateStore_Remove(t *testing.T) {
+	ss, cleanup := setupTestShimStateStore(t)
+	defer cleanup()
+
+	if err := ss.writePod(&podState{ID: t.Name()}); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := ss.remove(); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if _, err := os.Stat(ss.dir); !os.IsNotExist(err) {
+		t.Fatalf("expected state dir to be removed, got: %v", err)
+	}
+}
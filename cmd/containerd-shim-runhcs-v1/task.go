@@ -58,6 +58,36 @@ type shimTask interface {
 	// additional exec's tracked by this task must also be in the
 	// `shimExecStateExited` state.
 	DeleteExec(ctx context.Context, eid string) (int, uint32, time.Time, error)
+	// Pause pauses the container backing this task and transitions the init
+	// exec and all running additional exec's to the `shimExecStatePaused`
+	// state.
+	//
+	// If this task owns a hosting UtilityVM the entire UtilityVM is paused. If
+	// this task is hosted in a UtilityVM that it does not own it is the
+	// callers responsibility to pause the UtilityVM and this call only
+	// transitions the exec's.
+	//
+	// If the init exec is not in the `shimExecStateRunning` state this task
+	// MUST return `errdefs.ErrFailedPrecondition`.
+	Pause(ctx context.Context) error
+	// Resume resumes a previously paused task and transitions the init exec
+	// and all paused additional exec's back to the `shimExecStateRunning`
+	// state.
+	//
+	// If the init exec is not in the `shimExecStatePaused` state this task MUST
+	// return `errdefs.ErrFailedPrecondition`.
+	Resume(ctx context.Context) error
+	// Checkpoint saves the state of the UtilityVM this task owns and a
+	// manifest of all of its resources to the directory `path`. The task is
+	// left in the state it was in before the checkpoint.
+	//
+	// If this task does not own a UtilityVM this task MUST return
+	// `errdefs.ErrNotImplemented`.
+	//
+	// If the init exec is not in the `shimExecStateRunning` or
+	// `shimExecStatePaused` state this task MUST return
+	// `errdefs.ErrFailedPrecondition`.
+	Checkpoint(ctx context.Context, path string) error
 	// Pids returns all process pid's in this `shimTask` including ones not
 	// created by the caller via a `CreateExec`.
 	Pids(ctx context.Context) ([]options.ProcessDetails, error)
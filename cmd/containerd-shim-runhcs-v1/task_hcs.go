@@ -2,15 +2,19 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/hcsoci"
+	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/oci"
 	"github.com/Microsoft/hcsshim/internal/schema1"
 	"github.com/Microsoft/hcsshim/internal/uvm"
@@ -25,7 +29,7 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-func newHcsStandaloneTask(ctx context.Context, events publisher, req *task.CreateTaskRequest, s *specs.Spec) (shimTask, error) {
+func newHcsStandaloneTask(ctx context.Context, events publisher, state *shimStateStore, backend cow.Backend, req *task.CreateTaskRequest, s *specs.Spec, shimOpts *options.Options) (shimTask, error) {
 	logrus.WithFields(logrus.Fields{
 		"tid": req.ID,
 	}).Debug("newHcsStandloneTask")
@@ -45,16 +49,32 @@ func newHcsStandaloneTask(ctx context.Context, events publisher, req *task.Creat
 	owner := filepath.Base(os.Args[0])
 
 	var parent *uvm.UtilityVM
-	if osversion.Get().Build >= osversion.RS5 && oci.IsIsolated(s) {
+	if req.Checkpoint != "" {
+		if osversion.Get().Build < osversion.RS5 || !oci.IsIsolated(s) {
+			return nil, errors.Wrap(errdefs.ErrFailedPrecondition, "restore from checkpoint requires a hypervisor isolated task")
+		}
+		// Restore the UVM parent. All containers in it resume on `Start`.
+		parent, err = uvm.RestoreFromCheckpoint(ctx, req.Checkpoint, fmt.Sprintf("%s@vm", req.ID))
+		if err != nil {
+			return nil, err
+		}
+		err = startHost(parent)
+		if err != nil {
+			parent.Close()
+			return nil, err
+		}
+	} else if osversion.Get().Build >= osversion.RS5 && oci.IsIsolated(s) {
 		// Create the UVM parent
-		opts, err := oci.SpecToUVMCreateOpts(s, fmt.Sprintf("%s@vm", req.ID), owner)
+		opts, err := oci.SpecToUVMCreateOpts(s, fmt.Sprintf("%s@vm", req.ID), owner, shimOpts)
 		if err != nil {
 			return nil, err
 		}
+		pruneCrashDumps(opts, shimOpts)
 		switch opts.(type) {
 		case *uvm.OptionsLCOW:
 			lopts := (opts).(*uvm.OptionsLCOW)
-			parent, err = uvm.CreateLCOW(lopts)
+			lopts.Backend = backend
+			parent, err = uvm.CreateLCOW(ctx, lopts)
 			if err != nil {
 				return nil, err
 			}
@@ -75,13 +95,14 @@ func newHcsStandaloneTask(ctx context.Context, events publisher, req *task.Creat
 			}
 			layers[layersLen-1] = vmPath
 			wopts.LayerFolders = layers
+			wopts.Backend = backend
 
-			parent, err = uvm.CreateWCOW(wopts)
+			parent, err = uvm.CreateWCOW(ctx, wopts)
 			if err != nil {
 				return nil, err
 			}
 		}
-		err = parent.Start()
+		err = startHost(parent)
 		if err != nil {
 			parent.Close()
 		}
@@ -89,7 +110,7 @@ func newHcsStandaloneTask(ctx context.Context, events publisher, req *task.Creat
 		return nil, errors.Wrap(errdefs.ErrFailedPrecondition, "oci spec does not contain WCOW or LCOW spec")
 	}
 
-	shim, err := newHcsTask(ctx, events, parent, true, req, s)
+	shim, err := newHcsTask(ctx, events, state, backend, parent, true, req, s)
 	if err != nil {
 		if parent != nil {
 			parent.Close()
@@ -103,9 +124,20 @@ func newHcsStandaloneTask(ctx context.Context, events publisher, req *task.Creat
 // the `shimExecCreated` state and returns the task that tracks its lifetime.
 //
 // If `parent == nil` the container is created on the host.
+//
+// If `state != nil` the state of the task and all of its execs is persisted to
+// `state` on every change so that the task can be recovered by a restarted
+// shim.
+//
+// If `req.Checkpoint` is set `parent` MUST have been restored from that
+// checkpoint. Rather than creating the container it is opened in `parent` and
+// the call to `Start()` on the init exec opens the already running init
+// process.
 func newHcsTask(
 	ctx context.Context,
 	events publisher,
+	state *shimStateStore,
+	backend cow.Backend,
 	parent *uvm.UtilityVM,
 	ownsParent bool,
 	req *task.CreateTaskRequest,
@@ -117,7 +149,7 @@ func newHcsTask(
 
 	owner := filepath.Base(os.Args[0])
 
-	io, err := newNpipeIO(ctx, req.ID, req.ID, req.Stdin, req.Stdout, req.Stderr, req.Terminal)
+	io, err := newUpstreamIO(ctx, req.ID, req.ID, req.Stdin, req.Stdout, req.Stderr, req.Terminal)
 	if err != nil {
 		return nil, err
 	}
@@ -127,20 +159,42 @@ func newHcsTask(
 		s.Windows.Network != nil {
 		netNS = s.Windows.Network.NetworkNamespace
 	}
-	opts := hcsoci.CreateOptions{
-		ID:               req.ID,
-		Owner:            owner,
-		Spec:             s,
-		HostingSystem:    parent,
-		NetworkNamespace: netNS,
-	}
-	system, resources, err := hcsoci.CreateContainer(&opts)
-	if err != nil {
-		return nil, err
+	var (
+		system    cow.ComputeSystem
+		resources *hcsoci.Resources
+		tc        *taskCheckpoint
+	)
+	if req.Checkpoint != "" {
+		tc, err = readTaskCheckpoint(req.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		system, err = backend.OpenComputeSystem(tc.ID)
+		if err != nil {
+			return nil, err
+		}
+		// All resources of the container are in `parent` which is released
+		// as a whole when this task exits.
+		resources = &hcsoci.Resources{}
+	} else {
+		opts := hcsoci.CreateOptions{
+			ID:               req.ID,
+			Owner:            owner,
+			Spec:             s,
+			HostingSystem:    parent,
+			NetworkNamespace: netNS,
+			Backend:          backend,
+		}
+		system, resources, err = hcsoci.CreateContainer(ctx, &opts)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	ht := &hcsTask{
 		events:   events,
+		log:      logrus.WithField(logfields.TaskID, req.ID),
+		state:    state,
 		id:       req.ID,
 		isWCOW:   oci.IsWCOW(s),
 		c:        system,
@@ -149,32 +203,40 @@ func newHcsTask(
 		host:     parent,
 		closed:   make(chan struct{}),
 	}
-	ht.init = newHcsExec(
-		ctx,
-		events,
-		req.ID,
-		parent,
-		system,
-		req.ID,
-		req.Bundle,
-		ht.isWCOW,
-		s.Process,
-		io)
-
-	if parent != nil {
-		// We have a parent UVM. Listen for its exit and forcibly close this
-		// task. This is not expected but in the event of a UVM crash we need to
-		// handle this case.
-		go ht.waitForHostExit()
+	if tc != nil {
+		ht.init = newRestoredHcsExec(
+			ctx,
+			events,
+			req.ID,
+			parent,
+			system,
+			req.ID,
+			req.Bundle,
+			ht.isWCOW,
+			s.Process,
+			io,
+			tc.InitPid)
+	} else {
+		ht.init = newHcsExec(
+			ctx,
+			events,
+			req.ID,
+			parent,
+			system,
+			req.ID,
+			req.Bundle,
+			ht.isWCOW,
+			s.Process,
+			io)
 	}
-	// In the normal case the `Signal` call from the caller killed this task's
-	// init process.
-	go func() {
-		// Wait for our init process to exit.
-		ht.init.Wait(context.Background())
-		// Release all container resources for this task.
-		ht.close()
-	}()
+
+	ht.init.(*hcsExec).stateChanged = ht.persist
+	if tc == nil {
+		ht.init.(*hcsExec).timeout = oci.ParseAnnotationsExecTimeout(s.Annotations)
+	}
+	ht.start()
+
+	ht.persist()
 
 	// Publish the created event
 	ht.events(
@@ -189,12 +251,32 @@ func newHcsTask(
 				Stderr:   req.Stderr,
 				Terminal: req.Terminal,
 			},
-			Checkpoint: "",
+			Checkpoint: req.Checkpoint,
 			Pid:        uint32(ht.init.Pid()),
 		})
 	return ht, nil
 }
 
+// start starts the background goroutines that track the lifetime of the task.
+func (ht *hcsTask) start() {
+	if ht.host != nil {
+		// We have a parent UVM. Listen for its exit and forcibly close this
+		// task. This is not expected but in the event of a UVM crash we need to
+		// handle this case.
+		go ht.waitForHostExit()
+	}
+	// In the normal case the `Signal` call from the caller killed this task's
+	// init process.
+	go func() {
+		// Wait for our init process to exit.
+		ht.init.Wait(context.Background())
+		// Release all container resources for this task.
+		ht.close()
+		// Persist the final exit status with the released resources.
+		ht.persist()
+	}()
+}
+
 var _ = (shimTask)(&hcsTask{})
 
 // hcsTask is a generic task that represents a WCOW Container (process or
@@ -204,6 +286,19 @@ var _ = (shimTask)(&hcsTask{})
 // task/exec is stopped the UVM itself will be stopped as well.
 type hcsTask struct {
 	events publisher
+	// log is the log entry with the id of this task that all log entries of
+	// the task derive from.
+	//
+	// It MUST be treated as read only in the lifetime of the task.
+	log *logrus.Entry
+	// state is where the state of this task is persisted. If `nil` the state
+	// is not persisted.
+	//
+	// It MUST be treated as read only in the lifetime of the task.
+	state *shimStateStore
+	// pl is the persist lock. It MUST be held while writing `state` and while
+	// releasing `cr`.
+	pl sync.Mutex
 	// id is the id of this task when it is created.
 	//
 	// It MUST be treated as read only in the liftetime of the task.
@@ -216,7 +311,7 @@ type hcsTask struct {
 	//
 	// It MUST be treated as read only in the lifetime of this task EXCEPT after
 	// a Kill to the init task in which it must be shutdown.
-	c *hcs.System
+	c cow.ComputeSystem
 	// cr is the container resources this task is holding.
 	//
 	// It MUST be treated as read only in the lifetime of this task EXCEPT after
@@ -241,7 +336,8 @@ type hcsTask struct {
 	host *uvm.UtilityVM
 
 	// ecl is the exec create lock for all non-init execs and MUST be held
-	// durring create to prevent ID duplication.
+	// durring create to prevent ID duplication. It is also held durring
+	// `Pause` and `Resume` so that no exec is created mid transition.
 	ecl   sync.Mutex
 	execs sync.Map
 
@@ -257,9 +353,8 @@ func (ht *hcsTask) ID() string {
 }
 
 func (ht *hcsTask) CreateExec(ctx context.Context, req *task.ExecProcessRequest, spec *specs.Process) error {
-	logrus.WithFields(logrus.Fields{
-		"tid": ht.id,
-		"eid": req.ExecID,
+	ht.log.WithFields(logrus.Fields{
+		logfields.ExecID: req.ExecID,
 	}).Debug("hcsTask::CreateExec")
 
 	ht.ecl.Lock()
@@ -275,12 +370,15 @@ func (ht *hcsTask) CreateExec(ctx context.Context, req *task.ExecProcessRequest,
 		return errors.Wrapf(errdefs.ErrFailedPrecondition, "exec: '' in task: '%s' must be running to create additional execs", ht.id)
 	}
 
-	io, err := newNpipeIO(ctx, ht.id, req.ExecID, req.Stdin, req.Stdout, req.Stderr, req.Terminal)
+	io, err := newUpstreamIO(ctx, ht.id, req.ExecID, req.Stdin, req.Stdout, req.Stderr, req.Terminal)
 	if err != nil {
 		return err
 	}
 	he := newHcsExec(ctx, ht.events, ht.id, ht.host, ht.c, req.ExecID, ht.init.Status().Bundle, ht.isWCOW, spec, io)
+	he.(*hcsExec).stateChanged = ht.persist
+	he.(*hcsExec).timeout = oci.ParseAnnotationsExecTimeout(execAnnotations(req))
 	ht.execs.Store(req.ExecID, he)
+	ht.persist()
 
 	// Publish the created event
 	ht.events(
@@ -293,6 +391,22 @@ func (ht *hcsTask) CreateExec(ctx context.Context, req *task.ExecProcessRequest,
 	return nil
 }
 
+// execAnnotations returns the annotations of the exec requested by `req`. The
+// OCI process has no annotations of its own so they are read from the
+// `annotations` member of the process spec document.
+func execAnnotations(req *task.ExecProcessRequest) map[string]string {
+	if req.Spec == nil {
+		return nil
+	}
+	var p struct {
+		Annotations map[string]string `json:"annotations,omitempty"`
+	}
+	if err := json.Unmarshal(req.Spec.Value, &p); err != nil {
+		return nil
+	}
+	return p.Annotations
+}
+
 func (ht *hcsTask) GetExec(eid string) (shimExec, error) {
 	if eid == "" {
 		return ht.init, nil
@@ -305,11 +419,10 @@ func (ht *hcsTask) GetExec(eid string) (shimExec, error) {
 }
 
 func (ht *hcsTask) KillExec(ctx context.Context, eid string, signal uint32, all bool) error {
-	logrus.WithFields(logrus.Fields{
-		"tid":    ht.id,
-		"eid":    eid,
-		"signal": signal,
-		"all":    all,
+	ht.log.WithFields(logrus.Fields{
+		logfields.ExecID: eid,
+		"signal":         signal,
+		"all":            all,
 	}).Debug("hcsTask::KillExec")
 
 	e, err := ht.GetExec(eid)
@@ -319,6 +432,13 @@ func (ht *hcsTask) KillExec(ctx context.Context, eid string, signal uint32, all
 	if all && eid != "" {
 		return errors.Wrapf(errdefs.ErrFailedPrecondition, "cannot signal all for non-empty exec: '%s'", eid)
 	}
+	if ht.init.State() == shimExecStatePaused {
+		// The platform cannot deliver a signal to a process in a paused
+		// compute system. Resume the task first so the signal is delivered.
+		if err := ht.Resume(ctx); err != nil && ht.init.State() == shimExecStatePaused {
+			return errors.Wrap(err, "failed to resume paused task for kill")
+		}
+	}
 	eg := errgroup.Group{}
 	if all {
 		// We are in a kill all on the init task. Signal everything.
@@ -329,7 +449,7 @@ func (ht *hcsTask) KillExec(ctx context.Context, eid string, signal uint32, all
 			})
 
 			// iterate all
-			return false
+			return true
 		})
 	} else if eid == "" {
 		// We are in a kill of the init task. Verify all exec's are in the
@@ -340,10 +460,10 @@ func (ht *hcsTask) KillExec(ctx context.Context, eid string, signal uint32, all
 			if ex.State() != shimExecStateExited {
 				invalid = true
 				// we have an invalid state. Stop iteration.
-				return true
+				return false
 			}
 			// iterate next valid
-			return false
+			return true
 		})
 		if invalid {
 			return errors.Wrap(errdefs.ErrFailedPrecondition, "cannot signal init exec with un-exited additional exec's")
@@ -370,9 +490,8 @@ func (ht *hcsTask) KillExec(ctx context.Context, eid string, signal uint32, all
 }
 
 func (ht *hcsTask) DeleteExec(ctx context.Context, eid string) (int, uint32, time.Time, error) {
-	logrus.WithFields(logrus.Fields{
-		"tid": ht.id,
-		"eid": eid,
+	ht.log.WithFields(logrus.Fields{
+		logfields.ExecID: eid,
 	}).Debug("hcsTask::DeleteExec")
 
 	e, err := ht.GetExec(eid)
@@ -387,14 +506,14 @@ func (ht *hcsTask) DeleteExec(ctx context.Context, eid string) (int, uint32, tim
 			switch state := ex.State(); state {
 			case shimExecStateCreated:
 				// we have a created additional exec. Forcibly exit it.
-				ex.ForceExit(0)
-			case shimExecStateRunning:
+				ex.ForceExit(0, cow.ExitReasonNone)
+			case shimExecStateRunning, shimExecStatePaused:
 				invalid = true
 				// we have a running additional exec. Stop iteration.
-				return true
+				return false
 			}
 			// iterate next valid
-			return false
+			return true
 		})
 		if invalid {
 			return 0, 0, time.Time{}, errors.Wrap(errdefs.ErrFailedPrecondition, "cannot delete init exec with un-exited additional exec's")
@@ -402,13 +521,19 @@ func (ht *hcsTask) DeleteExec(ctx context.Context, eid string) (int, uint32, tim
 	}
 	switch state := e.State(); state {
 	case shimExecStateCreated:
-		e.ForceExit(0)
-	case shimExecStateRunning:
+		e.ForceExit(0, cow.ExitReasonNone)
+	case shimExecStateRunning, shimExecStatePaused:
 		return 0, 0, time.Time{}, newExecInvalidStateError(ht.id, eid, state, "delete")
 	}
 	status := e.Status()
 	if eid != "" {
 		ht.execs.Delete(eid)
+		ht.persist()
+	} else if ht.state != nil {
+		// The task is gone. Nothing is left to recover.
+		if err := ht.state.removeTask(ht.id); err != nil {
+			ht.log.WithError(err).Warn("hcsTask::DeleteExec - failed to remove task state")
+		}
 	}
 
 	// Publish the deleted event
@@ -425,10 +550,170 @@ func (ht *hcsTask) DeleteExec(ctx context.Context, eid string) (int, uint32, tim
 	return int(status.Pid), status.ExitStatus, status.ExitedAt, nil
 }
 
+func (ht *hcsTask) Pause(ctx context.Context) error {
+	ht.log.WithFields(logrus.Fields{
+		"ownsHost": ht.ownsHost,
+	}).Debug("hcsTask::Pause")
+
+	// Hold the exec create lock for the transition so that no additional
+	// exec's can be created while the container is being paused.
+	ht.ecl.Lock()
+	defer ht.ecl.Unlock()
+
+	if state := ht.init.State(); state != shimExecStateRunning {
+		return newExecInvalidStateError(ht.id, "", state, "pause")
+	}
+	if ht.host == nil {
+		// Process isolated. Pause the container itself.
+		if err := callWithContext(ctx, "Pause", ht.c.Pause); err != nil {
+			return err
+		}
+	} else if ht.ownsHost {
+		// We own the UVM. Pause the UVM which pauses every container in it.
+		if err := callWithContext(ctx, "Pause", ht.host.Pause); err != nil {
+			return err
+		}
+	}
+	// Else: the owner of the UVM has already paused it. Just transition.
+
+	ht.execs.Range(func(key, value interface{}) bool {
+		ex := value.(shimExec)
+		if ex.State() == shimExecStateRunning {
+			if err := ex.Pause(ctx); err != nil {
+				// The exec exited while we were pausing. Nothing to do.
+				ht.log.WithFields(logrus.Fields{
+					logfields.ExecID: ex.ID(),
+					logrus.ErrorKey:  err,
+				}).Warn("hcsTask::Pause - failed to transition exec")
+			}
+		}
+
+		// iterate all
+		return true
+	})
+	if err := ht.init.Pause(ctx); err != nil {
+		return err
+	}
+	ht.persist()
+
+	// Publish the paused event
+	ht.events(
+		runtime.TaskPausedEventTopic,
+		&eventstypes.TaskPaused{
+			ContainerID: ht.id,
+		})
+	return nil
+}
+
+func (ht *hcsTask) Resume(ctx context.Context) error {
+	ht.log.WithFields(logrus.Fields{
+		"ownsHost": ht.ownsHost,
+	}).Debug("hcsTask::Resume")
+
+	ht.ecl.Lock()
+	defer ht.ecl.Unlock()
+
+	if state := ht.init.State(); state != shimExecStatePaused {
+		return newExecInvalidStateError(ht.id, "", state, "resume")
+	}
+	if ht.host == nil {
+		if err := callWithContext(ctx, "Resume", ht.c.Resume); err != nil {
+			return err
+		}
+	} else if ht.ownsHost {
+		if err := callWithContext(ctx, "Resume", ht.host.Resume); err != nil {
+			return err
+		}
+	}
+
+	ht.execs.Range(func(key, value interface{}) bool {
+		ex := value.(shimExec)
+		if ex.State() == shimExecStatePaused {
+			if err := ex.Resume(ctx); err != nil {
+				ht.log.WithFields(logrus.Fields{
+					logfields.ExecID: ex.ID(),
+					logrus.ErrorKey:  err,
+				}).Warn("hcsTask::Resume - failed to transition exec")
+			}
+		}
+
+		// iterate all
+		return true
+	})
+	if err := ht.init.Resume(ctx); err != nil {
+		return err
+	}
+	ht.persist()
+
+	// Publish the resumed event
+	ht.events(
+		runtime.TaskResumedEventTopic,
+		&eventstypes.TaskResumed{
+			ContainerID: ht.id,
+		})
+	return nil
+}
+
+func (ht *hcsTask) Checkpoint(ctx context.Context, path string) (err error) {
+	ht.log.WithFields(logrus.Fields{
+		"path": path,
+	}).Debug("hcsTask::Checkpoint")
+
+	if ht.host == nil || !ht.ownsHost {
+		return errors.Wrapf(errdefs.ErrNotImplemented, "task: '%s' does not own a UVM to checkpoint", ht.id)
+	}
+
+	// Hold the exec create lock so that the state cannot change while the UVM
+	// is being saved.
+	ht.ecl.Lock()
+	defer ht.ecl.Unlock()
+
+	state := ht.init.State()
+	if state != shimExecStateRunning && state != shimExecStatePaused {
+		return newExecInvalidStateError(ht.id, "", state, "checkpoint")
+	}
+	if state == shimExecStateRunning {
+		// The UVM must be paused to be saved. Leave it as we found it.
+		if err := callWithContext(ctx, "Pause", ht.host.Pause); err != nil {
+			return err
+		}
+		defer func() {
+			// Resume even if `ctx` is already done so that a timed out save
+			// does not leave the UVM paused.
+			if rerr := callWithContext(context.Background(), "Resume", ht.host.Resume); rerr != nil {
+				ht.log.WithError(rerr).Error("hcsTask::Checkpoint - failed to resume host")
+				if err == nil {
+					err = rerr
+				}
+			}
+		}()
+	}
+	if err := callWithContext(ctx, "SaveCheckpoint", func() error {
+		return ht.host.SaveCheckpoint(path)
+	}); err != nil {
+		return err
+	}
+	tc := &taskCheckpoint{
+		ID:      ht.c.ID(),
+		IsWCOW:  ht.isWCOW,
+		InitPid: ht.init.Pid(),
+	}
+	if err := writeTaskCheckpoint(path, tc); err != nil {
+		return err
+	}
+
+	// Publish the checkpointed event
+	ht.events(
+		runtime.TaskCheckpointedEventTopic,
+		&eventstypes.TaskCheckpointed{
+			ContainerID: ht.id,
+			Checkpoint:  path,
+		})
+	return nil
+}
+
 func (ht *hcsTask) Pids(ctx context.Context) ([]options.ProcessDetails, error) {
-	logrus.WithFields(logrus.Fields{
-		"tid": ht.id,
-	}).Debug("hcsTask::Pids")
+	ht.log.Debug("hcsTask::Pids")
 
 	// Map all user created exec's to pid/exec-id
 	pidMap := make(map[int]string)
@@ -437,12 +722,16 @@ func (ht *hcsTask) Pids(ctx context.Context) ([]options.ProcessDetails, error) {
 		pidMap[ex.Pid()] = ex.ID()
 
 		// Iterate all
-		return false
+		return true
 	})
 	pidMap[ht.init.Pid()] = ht.init.ID()
 
 	// Get the guest pids
-	props, err := ht.c.Properties(schema1.PropertyTypeProcessList)
+	var props *schema1.ContainerProperties
+	err := callWithContext(ctx, "Properties", func() (err error) {
+		props, err = ht.c.Properties(schema1.PropertyTypeProcessList)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -479,26 +768,23 @@ func (ht *hcsTask) Wait(ctx context.Context) *task.StateResponse {
 // Note: For Windows process isolated containers there is no host virtual
 // machine so this should not be called.
 func (ht *hcsTask) waitForHostExit() {
-	log := logrus.WithFields(logrus.Fields{
-		"tid": ht.id,
-	})
 	err := ht.host.Wait()
 	if err != nil {
-		log.Data[logrus.ErrorKey] = err
-		log.Error("hcsTask::waitForHostExit - Failed to wait for host virtual machine exit")
-		delete(log.Data, logrus.ErrorKey)
+		ht.log.WithError(err).Error("hcsTask::waitForHostExit - Failed to wait for host virtual machine exit")
 	} else {
-		log.Debug("hcsTask::waitForHostExit - Host virtual machine exited")
+		ht.log.Debug("hcsTask::waitForHostExit - Host virtual machine exited")
 	}
 
+	// The reason the host exited is the reason every exec in it exited.
+	reason := ht.host.ExitReason()
 	ht.execs.Range(func(key, value interface{}) bool {
 		ex := value.(shimExec)
-		ex.ForceExit(1)
+		ex.ForceExit(1, reason)
 
 		// iterate all
-		return false
+		return true
 	})
-	ht.init.ForceExit(1)
+	ht.init.ForceExit(1, reason)
 	ht.closeHost()
 }
 
@@ -508,9 +794,7 @@ func (ht *hcsTask) waitForHostExit() {
 // NOTE: For Windows process isolated containers `ht.ownsHost==true && ht.host
 // == nil`.
 func (ht *hcsTask) close() {
-	logrus.WithFields(logrus.Fields{
-		"tid": ht.id,
-	}).Debug("hcsTask::close")
+	ht.log.Debug("hcsTask::close")
 
 	ht.closeOnce.Do(func() {
 		// ht.c should never be nil for a real task but in testing we stub
@@ -523,53 +807,37 @@ func (ht *hcsTask) close() {
 				if hcs.IsAlreadyClosed(err) || hcs.IsNotExist(err) || hcs.IsAlreadyStopped(err) {
 					// This is the state we want. Do nothing.
 				} else if !hcs.IsPending(err) {
-					logrus.WithFields(logrus.Fields{
-						"tid":           ht.id,
-						logrus.ErrorKey: err,
-					}).Error("hcsTask::close - failed to shutdown container")
+					ht.log.WithError(err).Error("hcsTask::close - failed to shutdown container")
 				} else {
 					const shutdownTimeout = time.Minute * 5
 					if err := ht.c.WaitTimeout(shutdownTimeout); err != nil {
-						logrus.WithFields(logrus.Fields{
-							"tid":           ht.id,
-							logrus.ErrorKey: err,
-						}).Error("hcsTask::close - failed to wait for container shutdown")
+						ht.log.WithError(err).Error("hcsTask::close - failed to wait for container shutdown")
 					}
 				}
 				if err := ht.c.Terminate(); err != nil {
 					if hcs.IsAlreadyClosed(err) || hcs.IsNotExist(err) || hcs.IsAlreadyStopped(err) {
 						// This is the state we want. Do nothing.
 					} else if !hcs.IsPending(err) {
-						logrus.WithFields(logrus.Fields{
-							"tid":           ht.id,
-							logrus.ErrorKey: err,
-						}).Error("hcsTask::close - failed to terminate container")
+						ht.log.WithError(err).Error("hcsTask::close - failed to terminate container")
 					} else {
 						const terminateTimeout = time.Second * 30
 						if err := ht.c.WaitTimeout(terminateTimeout); err != nil {
-							logrus.WithFields(logrus.Fields{
-								"tid":           ht.id,
-								logrus.ErrorKey: err,
-							}).Error("hcsTask::close - failed to wait for container terminate")
+							ht.log.WithError(err).Error("hcsTask::close - failed to wait for container terminate")
 						}
 					}
 				}
 			}
 
 			// Release any resources associated with the container.
+			ht.pl.Lock()
 			if err := hcsoci.ReleaseResources(ht.cr, ht.host, true); err != nil {
-				logrus.WithFields(logrus.Fields{
-					"tid":           ht.id,
-					logrus.ErrorKey: err,
-				}).Error("hcsTask::close - failed to release container resources")
+				ht.log.WithError(err).Error("hcsTask::close - failed to release container resources")
 			}
+			ht.pl.Unlock()
 
 			// Close the container handle invalidating all future access.
 			if err := ht.c.Close(); err != nil && !hcs.IsAlreadyClosed(err) {
-				logrus.WithFields(logrus.Fields{
-					"tid":           ht.id,
-					logrus.ErrorKey: err,
-				}).Error("hcsTask::close - failed to close container")
+				ht.log.WithError(err).Error("hcsTask::close - failed to close container")
 			}
 		}
 		ht.closeHost()
@@ -587,14 +855,26 @@ func (ht *hcsTask) closeHost() {
 	ht.closeHostOnce.Do(func() {
 		if ht.ownsHost && ht.host != nil {
 			if err := ht.host.Close(); err != nil {
-				logrus.WithFields(logrus.Fields{
-					"tid":           ht.id,
-					logrus.ErrorKey: err,
-				}).Error("hcsTask::closeHost - failed host vm shutdown")
+				ht.log.WithError(err).Error("hcsTask::closeHost - failed host vm shutdown")
 			}
+			collectHostCrash(ht.events, ht.id, ht.host)
 		}
 		// Send the `init` exec exit notification always.
 		exit := ht.init.Status()
+		if reason := ht.init.ExitReason(); reason != cow.ExitReasonNone {
+			ht.log.WithFields(logrus.Fields{
+				"reason": reason,
+			}).Warn("hcsTask::closeHost - task exited abnormally")
+			if reason == cow.ExitReasonOutOfMemory {
+				// Upstream listeners learn that a task was OOM killed from
+				// this event preceding the exit.
+				ht.events(
+					runtime.TaskOOMEventTopic,
+					&eventstypes.TaskOOM{
+						ContainerID: ht.id,
+					})
+			}
+		}
 		ht.events(
 			runtime.TaskExitEventTopic,
 			&eventstypes.TaskExit{
@@ -607,3 +887,48 @@ func (ht *hcsTask) closeHost() {
 		close(ht.closed)
 	})
 }
+
+// persist writes the current state of this task and all of its execs to
+// `ht.state`. A failure is logged but otherwise ignored as the task itself is
+// unaffected.
+//
+// This call is a noop if `ht.state == nil`.
+func (ht *hcsTask) persist() {
+	if ht.state == nil {
+		return
+	}
+
+	ht.pl.Lock()
+	defer ht.pl.Unlock()
+
+	ts := &taskState{
+		ID:       ht.id,
+		Bundle:   ht.init.Status().Bundle,
+		IsWCOW:   ht.isWCOW,
+		OwnsHost: ht.ownsHost,
+		Init:     newExecState(ht.init),
+	}
+	if ht.host != nil {
+		ts.HostID = ht.host.ID()
+		if ht.ownsHost {
+			m, err := ht.host.Manifest()
+			if err != nil {
+				ht.log.WithError(err).Warn("hcsTask::persist - failed to get host manifest")
+			}
+			ts.Host = m
+		}
+	}
+	if ht.cr != nil {
+		ts.Resources = ht.cr.State()
+	}
+	ht.execs.Range(func(key, value interface{}) bool {
+		ts.Execs = append(ts.Execs, newExecState(value.(shimExec)))
+
+		// iterate all
+		return true
+	})
+	sort.Slice(ts.Execs, func(i, j int) bool { return ts.Execs[i].ID < ts.Execs[j].ID })
+	if err := ht.state.writeTask(ts); err != nil {
+		ht.log.WithError(err).Warn("hcsTask::persist - failed to write task state")
+	}
+}
@@ -2,18 +2,29 @@ package main
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"strconv"
 	"testing"
 	"time"
 
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/cow/fake"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
 	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/runtime"
+	"github.com/containerd/containerd/runtime/v2/task"
+	"github.com/gogo/protobuf/types"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 func setupTestHcsTask(t *testing.T) (*hcsTask, *testShimExec, *testShimExec) {
 	initExec := newTestShimExec(t.Name(), t.Name(), int(rand.Int31()))
 	lt := &hcsTask{
 		events: fakePublisher,
+		log:    logrus.WithField(logfields.TaskID, t.Name()),
 		id:     t.Name(),
 		init:   initExec,
 		closed: make(chan struct{}),
@@ -95,6 +106,38 @@ func Test_hcsTask_KillExec_InitExecID_All_Success(t *testing.T) {
 	}
 }
 
+func Test_hcsTask_KillExec_InitExecID_All_MultipleExecs_Success(t *testing.T) {
+	lt, init, second := setupTestHcsTask(t)
+	thirdExecID := strconv.Itoa(rand.Int())
+	third := newTestShimExec(t.Name(), thirdExecID, int(rand.Int31()))
+	lt.execs.Store(thirdExecID, third)
+
+	err := lt.KillExec(context.TODO(), "", 0xf, true)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if init.state != shimExecStateExited {
+		t.Fatalf("init should be in exited state got: %v", init.state)
+	}
+	if second.state != shimExecStateExited {
+		t.Fatalf("2nd exec should be in exited state got: %v", second.state)
+	}
+	if third.state != shimExecStateExited {
+		t.Fatalf("3rd exec should be in exited state got: %v", third.state)
+	}
+}
+
+func Test_hcsTask_KillExec_InitExecID_Unexited3rdExec_Error(t *testing.T) {
+	lt, _, second := setupTestHcsTask(t)
+	second.Kill(context.TODO(), 0xf)
+	thirdExecID := strconv.Itoa(rand.Int())
+	lt.execs.Store(thirdExecID, newTestShimExec(t.Name(), thirdExecID, int(rand.Int31())))
+
+	err := lt.KillExec(context.TODO(), "", 0xf, false)
+
+	verifyExpectedError(t, nil, err, errdefs.ErrFailedPrecondition)
+}
+
 func Test_hcsTask_KillExec_2ndExecID_Success(t *testing.T) {
 	lt, _, second := setupTestHcsTask(t)
 
@@ -286,3 +329,212 @@ func Test_hcsTask_DeleteExec_2ndExecID_ExitedState_Success(t *testing.T) {
 	}
 	verifyDeleteSuccessValues(t, pid, status, at, second)
 }
+
+func Test_hcsTask_Checkpoint_NoHost_Error(t *testing.T) {
+	lt, i, _ := setupTestHcsTask(t)
+	i.Start(context.TODO())
+
+	err := lt.Checkpoint(context.TODO(), t.Name())
+
+	verifyExpectedError(t, nil, err, errdefs.ErrNotImplemented)
+}
+
+// setupTestHcsTaskWithFake creates a process isolated `hcsTask` backed by a
+// started fake compute system.
+func setupTestHcsTaskWithFake(t *testing.T) (*hcsTask, *fake.ComputeSystem, *testShimExec) {
+	lt, i, _ := setupTestHcsTask(t)
+	c, err := (&fake.Backend{}).CreateComputeSystem(context.TODO(), t.Name(), nil)
+	if err != nil {
+		t.Fatalf("failed to create fake compute system: %v", err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatalf("failed to start fake compute system: %v", err)
+	}
+	lt.c = c
+	return lt, c.(*fake.ComputeSystem), i
+}
+
+func Test_hcsTask_Pause_ProcessIsolated_PausesContainer(t *testing.T) {
+	lt, c, i := setupTestHcsTaskWithFake(t)
+	i.Start(context.TODO())
+
+	if err := lt.Pause(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if c.State() != fake.StatePaused {
+		t.Fatalf("expected container state: '%s', got: '%s'", fake.StatePaused, c.State())
+	}
+	if err := lt.Resume(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if c.State() != fake.StateRunning {
+		t.Fatalf("expected container state: '%s', got: '%s'", fake.StateRunning, c.State())
+	}
+	if i.State() != shimExecStateRunning {
+		t.Fatalf("expected init state: '%s', got: '%s'", shimExecStateRunning, i.State())
+	}
+}
+
+func Test_hcsTask_Pause_MultipleExecs_TransitionsAll(t *testing.T) {
+	lt, _, i := setupTestHcsTaskWithFake(t)
+	i.Start(context.TODO())
+	thirdExecID := strconv.Itoa(rand.Int())
+	lt.execs.Store(thirdExecID, newTestShimExec(t.Name(), thirdExecID, int(rand.Int31())))
+	var execs []*testShimExec
+	lt.execs.Range(func(key, value interface{}) bool {
+		ex := value.(*testShimExec)
+		ex.Start(context.TODO())
+		execs = append(execs, ex)
+
+		// iterate all
+		return true
+	})
+	if len(execs) != 2 {
+		t.Fatalf("expected 2 execs, got: %d", len(execs))
+	}
+
+	if err := lt.Pause(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	for _, ex := range execs {
+		if ex.State() != shimExecStatePaused {
+			t.Fatalf("expected exec: '%s' state: '%s', got: '%s'", ex.ID(), shimExecStatePaused, ex.State())
+		}
+	}
+	if err := lt.Resume(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	for _, ex := range execs {
+		if ex.State() != shimExecStateRunning {
+			t.Fatalf("expected exec: '%s' state: '%s', got: '%s'", ex.ID(), shimExecStateRunning, ex.State())
+		}
+	}
+}
+
+func Test_hcsTask_Pause_ContainerError_InitNotPaused(t *testing.T) {
+	lt, c, i := setupTestHcsTaskWithFake(t)
+	i.Start(context.TODO())
+	expected := errors.New("pause failed")
+	c.SetError("Pause", expected)
+
+	if err := lt.Pause(context.TODO()); err != expected {
+		t.Fatalf("expected error: %v, got: %v", expected, err)
+	}
+	if i.State() != shimExecStateRunning {
+		t.Fatalf("expected init state: '%s', got: '%s'", shimExecStateRunning, i.State())
+	}
+}
+
+func Test_hcsTask_Pause_ContainerHung_DeadlineExceeded(t *testing.T) {
+	lt, c, i := setupTestHcsTaskWithFake(t)
+	i.Start(context.TODO())
+	unblock := c.Block("Pause")
+	defer unblock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := lt.Pause(ctx); pkgerrors.Cause(err) != context.DeadlineExceeded {
+		t.Fatalf("expected error: %v, got: %v", context.DeadlineExceeded, err)
+	}
+	if i.State() != shimExecStateRunning {
+		t.Fatalf("expected init state: '%s', got: '%s'", shimExecStateRunning, i.State())
+	}
+}
+
+func Test_hcsTask_KillExec_Paused_Exited(t *testing.T) {
+	c, he := setupTestHcsExecWithFake(t, t.Name(), fakePublisher)
+	lt := &hcsTask{
+		events: fakePublisher,
+		log:    logrus.WithField(logfields.TaskID, t.Name()),
+		id:     t.Name(),
+		init:   he,
+		c:      c,
+		closed: make(chan struct{}),
+	}
+	if err := he.Start(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := lt.Pause(context.TODO()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+
+	if err := lt.KillExec(context.TODO(), "", 0x9, false); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	waitTestHcsExec(t, he)
+
+	if !c.Process(he.Pid()).Exited() {
+		t.Fatal("expected process to be killed")
+	}
+	if he.State() != shimExecStateExited {
+		t.Fatalf("expected state: '%s', got: '%s'", shimExecStateExited, he.State())
+	}
+}
+
+func Test_execAnnotations(t *testing.T) {
+	req := &task.ExecProcessRequest{
+		Spec: &types.Any{Value: []byte(`{"args":["cmd"],"annotations":{"a":"b"}}`)},
+	}
+	if a := execAnnotations(req); a["a"] != "b" {
+		t.Fatalf("expected annotation 'a': 'b', got: %v", a)
+	}
+	if a := execAnnotations(&task.ExecProcessRequest{}); a != nil {
+		t.Fatalf("expected no annotations without a spec, got: %v", a)
+	}
+}
+
+func Test_hcsTask_Pids_MapsExecIDs(t *testing.T) {
+	lt, c, _ := setupTestHcsTaskWithFake(t)
+	p, err := c.CreateProcess(&hcsschema.ProcessParameters{CommandLine: "init"})
+	if err != nil {
+		t.Fatalf("failed to create fake process: %v", err)
+	}
+	c.CreateProcess(&hcsschema.ProcessParameters{CommandLine: "other"})
+	lt.init = newTestShimExec(t.Name(), t.Name(), p.Pid())
+
+	pids, err := lt.Pids(context.TODO())
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if len(pids) != 2 {
+		t.Fatalf("expected 2 processes, got: %+v", pids)
+	}
+	if pids[0].ExecID != t.Name() || pids[0].ImageName != "init" {
+		t.Fatalf("expected process %d to be init exec, got: %+v", p.Pid(), pids[0])
+	}
+	if pids[1].ExecID != "" || pids[1].ImageName != "other" {
+		t.Fatalf("expected process to have no exec, got: %+v", pids[1])
+	}
+}
+
+func Test_hcsTask_closeHost_InitOutOfMemory_PublishesTaskOOM(t *testing.T) {
+	lt, i, _ := setupTestHcsTask(t)
+	var topics []string
+	lt.events = func(topic string, event interface{}) {
+		topics = append(topics, topic)
+	}
+	i.Start(context.TODO())
+	i.ForceExit(1, cow.ExitReasonOutOfMemory)
+
+	lt.closeHost()
+
+	if len(topics) != 2 || topics[0] != runtime.TaskOOMEventTopic || topics[1] != runtime.TaskExitEventTopic {
+		t.Fatalf("expected topics: [%s %s], got: %v", runtime.TaskOOMEventTopic, runtime.TaskExitEventTopic, topics)
+	}
+}
+
+func Test_hcsTask_closeHost_InitExited_NoTaskOOM(t *testing.T) {
+	lt, i, _ := setupTestHcsTask(t)
+	var topics []string
+	lt.events = func(topic string, event interface{}) {
+		topics = append(topics, topic)
+	}
+	i.Start(context.TODO())
+	i.ForceExit(1, cow.ExitReasonTerminated)
+
+	lt.closeHost()
+
+	if len(topics) != 1 || topics[0] != runtime.TaskExitEventTopic {
+		t.Fatalf("expected topics: [%s], got: %v", runtime.TaskExitEventTopic, topics)
+	}
+}
@@ -46,6 +46,37 @@ func (tst *testShimTask) KillExec(ctx context.Context, eid string, signal uint32
 	return e.Kill(ctx, signal)
 }
 
+func (tst *testShimTask) Pause(ctx context.Context) error {
+	if err := tst.exec.Pause(ctx); err != nil {
+		return err
+	}
+	for _, e := range tst.execs {
+		if e.State() == shimExecStateRunning {
+			e.Pause(ctx)
+		}
+	}
+	return nil
+}
+
+func (tst *testShimTask) Resume(ctx context.Context) error {
+	if err := tst.exec.Resume(ctx); err != nil {
+		return err
+	}
+	for _, e := range tst.execs {
+		if e.State() == shimExecStatePaused {
+			e.Resume(ctx)
+		}
+	}
+	return nil
+}
+
+func (tst *testShimTask) Checkpoint(ctx context.Context, path string) error {
+	if state := tst.exec.State(); state != shimExecStateRunning && state != shimExecStatePaused {
+		return newExecInvalidStateError(tst.id, "", state, "checkpoint")
+	}
+	return nil
+}
+
 func (tst *testShimTask) DeleteExec(ctx context.Context, eid string) (int, uint32, time.Time, error) {
 	e, err := tst.GetExec(eid)
 	if err != nil {
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/uvm"
 	eventstypes "github.com/containerd/containerd/api/events"
@@ -52,7 +53,7 @@ func newWcowPodSandboxTask(ctx context.Context, events publisher, id, bundle str
 			// The UVM came down. Force transition the init task (if it wasn't
 			// already) to unblock any waiters since the platform wont send any
 			// events for this fake process.
-			wpst.init.ForceExit(1)
+			wpst.init.ForceExit(1, parent.ExitReason())
 
 			// Close the host and event the exit.
 			wpst.close()
@@ -98,6 +99,10 @@ type wcowPodSandboxTask struct {
 	// host is the hosting VM for this task if hypervisor isolated. If
 	// `host==nil` this is an Argon task so no UVM cleanup is required.
 	host *uvm.UtilityVM
+	// pl is the pause lock. It MUST be held across `Pause` and `Resume` so
+	// that the state of init cannot change between the check and the
+	// transition.
+	pl sync.Mutex
 
 	closed    chan struct{}
 	closeOnce sync.Once
@@ -158,8 +163,8 @@ func (wpst *wcowPodSandboxTask) DeleteExec(ctx context.Context, eid string) (int
 	}
 	switch state := e.State(); state {
 	case shimExecStateCreated:
-		e.ForceExit(0)
-	case shimExecStateRunning:
+		e.ForceExit(0, cow.ExitReasonNone)
+	case shimExecStateRunning, shimExecStatePaused:
 		return 0, 0, time.Time{}, newExecInvalidStateError(wpst.id, eid, state, "delete")
 	}
 	status := e.Status()
@@ -178,6 +183,72 @@ func (wpst *wcowPodSandboxTask) DeleteExec(ctx context.Context, eid string) (int
 	return int(status.Pid), status.ExitStatus, status.ExitedAt, nil
 }
 
+func (wpst *wcowPodSandboxTask) Pause(ctx context.Context) error {
+	logrus.WithFields(logrus.Fields{
+		"tid": wpst.id,
+	}).Debug("wcowPodSandboxTask::Pause")
+
+	wpst.pl.Lock()
+	defer wpst.pl.Unlock()
+
+	if state := wpst.init.State(); state != shimExecStateRunning {
+		return newExecInvalidStateError(wpst.id, "", state, "pause")
+	}
+	if wpst.host != nil {
+		// This task always owns the UVM. Pausing it pauses all workload
+		// containers in the POD.
+		if err := callWithContext(ctx, "Pause", wpst.host.Pause); err != nil {
+			return err
+		}
+	}
+	if err := wpst.init.Pause(ctx); err != nil {
+		return err
+	}
+
+	// Publish the paused event
+	wpst.events(
+		runtime.TaskPausedEventTopic,
+		&eventstypes.TaskPaused{
+			ContainerID: wpst.id,
+		})
+	return nil
+}
+
+func (wpst *wcowPodSandboxTask) Resume(ctx context.Context) error {
+	logrus.WithFields(logrus.Fields{
+		"tid": wpst.id,
+	}).Debug("wcowPodSandboxTask::Resume")
+
+	wpst.pl.Lock()
+	defer wpst.pl.Unlock()
+
+	if state := wpst.init.State(); state != shimExecStatePaused {
+		return newExecInvalidStateError(wpst.id, "", state, "resume")
+	}
+	if wpst.host != nil {
+		if err := callWithContext(ctx, "Resume", wpst.host.Resume); err != nil {
+			return err
+		}
+	}
+	if err := wpst.init.Resume(ctx); err != nil {
+		return err
+	}
+
+	// Publish the resumed event
+	wpst.events(
+		runtime.TaskResumedEventTopic,
+		&eventstypes.TaskResumed{
+			ContainerID: wpst.id,
+		})
+	return nil
+}
+
+func (wpst *wcowPodSandboxTask) Checkpoint(ctx context.Context, path string) error {
+	// The UVM is shared by every task in the POD which cannot be restored
+	// individually.
+	return errors.Wrapf(errdefs.ErrNotImplemented, "checkpoint of POD sandbox task: '%s' is not supported", wpst.id)
+}
+
 func (wpst *wcowPodSandboxTask) Pids(ctx context.Context) ([]options.ProcessDetails, error) {
 	logrus.WithFields(logrus.Fields{
 		"tid": wpst.id,
@@ -215,9 +286,23 @@ func (wpst *wcowPodSandboxTask) close() {
 					logrus.ErrorKey: err,
 				}).Error("wcowPodSandboxTask::close - failed host vm shutdown")
 			}
+			collectHostCrash(wpst.events, wpst.id, wpst.host)
 		}
 		// Send the `init` exec exit notification always.
 		exit := wpst.init.Status()
+		if reason := wpst.init.ExitReason(); reason != cow.ExitReasonNone {
+			logrus.WithFields(logrus.Fields{
+				"tid":    wpst.id,
+				"reason": reason,
+			}).Warn("wcowPodSandboxTask::close - task exited abnormally")
+			if reason == cow.ExitReasonOutOfMemory {
+				wpst.events(
+					runtime.TaskOOMEventTopic,
+					&eventstypes.TaskOOM{
+						ContainerID: wpst.id,
+					})
+			}
+		}
 		wpst.events(
 			runtime.TaskExitEventTopic,
 			&eventstypes.TaskExit{
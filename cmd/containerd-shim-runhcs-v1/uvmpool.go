@@ -0,0 +1,559 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	winio "github.com/Microsoft/go-winio"
+	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/gcsoutput"
+	"github.com/Microsoft/hcsshim/internal/guid"
+	"github.com/Microsoft/hcsshim/internal/oci"
+	"github.com/Microsoft/hcsshim/internal/schema1"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/Microsoft/hcsshim/internal/uvmpool"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	// uvmPoolAddrFmt is the pipe that the utility VM pool of a namespace and
+	// set of runtime options is served on.
+	uvmPoolAddrFmt = "\\\\.\\pipe\\ProtectedPrefix\\Administrators\\containerd-shim-runhcs-v1-uvmpool-%s-%s"
+	// uvmPoolOutputAddrFmt is the pipe that the pool relays the output of the
+	// utility VM with the given id on once a pod shim took it.
+	uvmPoolOutputAddrFmt = "\\\\.\\pipe\\ProtectedPrefix\\Administrators\\containerd-shim-runhcs-v1-uvmpool-output-%s"
+	// uvmPoolDialTimeout is the time a pod shim waits to connect to the pool.
+	uvmPoolDialTimeout = 5 * time.Second
+	// defaultUVMPoolIdleTimeout is the default `UvmPoolIdleTimeoutInSeconds`.
+	defaultUVMPoolIdleTimeout = 10 * time.Minute
+)
+
+// uvmPoolLaunch is the configuration written to the stdin of the `uvmpool`
+// command.
+type uvmPoolLaunch struct {
+	// Options are the runtime options of the pod shim that launched the pool.
+	// Every pooled utility VM is created with them.
+	Options *runhcsopts.Options
+	// Profile is the profile of the pod that launched the pool. It is filled
+	// as soon as the pool is started.
+	Profile uvmpool.Profile
+}
+
+// uvmPoolRequest is sent by a pod shim to take a utility VM from the pool.
+type uvmPoolRequest struct {
+	Profile uvmpool.Profile
+}
+
+// uvmPoolResponse is the response of the pool to a `uvmPoolRequest`.
+type uvmPoolResponse struct {
+	// Host is the manifest of the utility VM taken from the pool. If `nil` no
+	// utility VM was ready for the profile.
+	Host *uvm.CheckpointManifest `json:",omitempty"`
+	// OutputPipe is the pipe the pool relays the output forwarded from the
+	// guest of `Host` on. If empty the output is not forwarded.
+	OutputPipe string `json:",omitempty"`
+	Error      string `json:",omitempty"`
+}
+
+// uvmPoolAck is sent by a pod shim once it has opened the utility VM in a
+// `uvmPoolResponse`. The pool only releases the utility VM once acknowledged,
+// otherwise it is terminated.
+type uvmPoolAck struct {
+	Opened bool
+}
+
+// uvmPoolAddress returns the pipe of the utility VM pool for the pods of this
+// namespace with the runtime options `shimOpts`. Pods with different runtime
+// options use different pools so that the pooled utility VMs always match
+// their options.
+func uvmPoolAddress(shimOpts *runhcsopts.Options) (string, error) {
+	b, err := json.Marshal(shimOpts)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return fmt.Sprintf(uvmPoolAddrFmt, namespaceFlag, hex.EncodeToString(sum[:8])), nil
+}
+
+// lcowProfile returns the pool profile of the utility VM created with `lopts`.
+func lcowProfile(lopts *uvm.OptionsLCOW) uvmpool.Profile {
+	return uvmpool.Profile{
+		OS:             "linux",
+		MemorySizeInMB: lopts.MemorySizeInMB,
+		ProcessorCount: lopts.ProcessorCount,
+		BootFilesPath:  lopts.BootFilesPath,
+	}
+}
+
+// pooledOptionsLCOW returns the options that the pool creates the utility VMs
+// of profile `p` with. Every option that is not part of the profile is the
+// default of the runtime options `shimOpts`.
+func pooledOptionsLCOW(id, owner string, p uvmpool.Profile, shimOpts *runhcsopts.Options) (*uvm.OptionsLCOW, error) {
+	opts, err := oci.SpecToUVMCreateOpts(&specs.Spec{Linux: &specs.Linux{}}, id, owner, shimOpts)
+	if err != nil {
+		return nil, err
+	}
+	lopts := opts.(*uvm.OptionsLCOW)
+	lopts.MemorySizeInMB = p.MemorySizeInMB
+	lopts.ProcessorCount = p.ProcessorCount
+	lopts.BootFilesPath = p.BootFilesPath
+	// Pooled utility VMs are not created for a pod so there is no directory
+	// to collect their crashes or console in. Pods that collect either are
+	// not pooled.
+	lopts.CrashDumpPath = ""
+	lopts.ConsoleLogFile = ""
+	return lopts, nil
+}
+
+// isPoolableLCOW returns `true` if a pooled utility VM of the profile of
+// `lopts` is identical to one created with `lopts`. A pod that sets any option
+// that is not part of the profile through annotations cannot use the pool.
+func isPoolableLCOW(lopts *uvm.OptionsLCOW, shimOpts *runhcsopts.Options) (bool, error) {
+	popts, err := pooledOptionsLCOW(lopts.ID, lopts.Owner, lcowProfile(lopts), shimOpts)
+	if err != nil {
+		return false, err
+	}
+	a, err := json.Marshal(lopts)
+	if err != nil {
+		return false, err
+	}
+	b, err := json.Marshal(popts)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(a, b), nil
+}
+
+// takePooledHost returns a running utility VM for the pod created with
+// `lopts` from the pool of this namespace. Returns `nil` if pooling is not
+// enabled in `shimOpts`, the pod cannot use the pool or no utility VM is ready,
+// in which case the caller creates the utility VM.
+//
+// If no pool is running for the runtime options of the pod one is launched so
+// that later pods can use it.
+func takePooledHost(lopts *uvm.OptionsLCOW, shimOpts *runhcsopts.Options) *uvm.UtilityVM {
+	if shimOpts == nil || shimOpts.UvmPoolSize == 0 {
+		return nil
+	}
+	log := logrus.WithFields(logrus.Fields{
+		"profile": lcowProfile(lopts).String(),
+	})
+	if ok, err := isPoolableLCOW(lopts, shimOpts); err != nil || !ok {
+		log.WithError(err).Debug("takePooledHost - pod options do not match the pool")
+		return nil
+	}
+	address, err := uvmPoolAddress(shimOpts)
+	if err != nil {
+		log.WithError(err).Warning("takePooledHost - failed to get pool address")
+		return nil
+	}
+	host, err := requestPooledHost(address, lcowProfile(lopts), lopts.OutputHandler)
+	if err != nil {
+		if perr, ok := err.(*os.PathError); ok && perr.Err == syscall.ERROR_FILE_NOT_FOUND {
+			if err := launchUVMPool(address, lcowProfile(lopts), shimOpts); err != nil {
+				log.WithError(err).Warning("takePooledHost - failed to launch pool")
+			}
+			return nil
+		}
+		log.WithError(err).Warning("takePooledHost - failed to take utility VM from pool")
+		return nil
+	}
+	if host == nil {
+		log.Debug("takePooledHost - no utility VM is ready")
+		return nil
+	}
+	log.WithField("uvm-id", host.ID()).Info("takePooledHost - using pooled utility VM")
+	return host
+}
+
+// requestPooledHost takes a utility VM of profile `p` from the pool at
+// `address`. Returns `nil` if no utility VM is ready. The output forwarded from
+// the guest of the utility VM is handled with `handler`.
+func requestPooledHost(address string, p uvmpool.Profile, handler uvm.OutputHandler) (_ *uvm.UtilityVM, err error) {
+	timeout := uvmPoolDialTimeout
+	c, err := winio.DialPipe(address, &timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	enc := json.NewEncoder(c)
+	if err := enc.Encode(&uvmPoolRequest{Profile: p}); err != nil {
+		return nil, err
+	}
+	var resp uvmPoolResponse
+	if err := json.NewDecoder(c).Decode(&resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	if resp.Host == nil {
+		return nil, nil
+	}
+	// If the utility VM is not acknowledged the pool terminates it.
+	host, err := uvm.Open(resp.Host)
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(&uvmPoolAck{Opened: true}); err != nil {
+		host.Close()
+		return nil, err
+	}
+	if resp.OutputPipe != "" {
+		oc, err := winio.DialPipe(resp.OutputPipe, &timeout)
+		if err != nil {
+			// The utility VM is ours now. Only its output is lost.
+			logrus.WithFields(logrus.Fields{
+				"uvm-id":        host.ID(),
+				logrus.ErrorKey: err,
+			}).Warning("requestPooledHost - failed to connect to the output of the utility VM")
+		} else {
+			host.ForwardOutput(oc, handler)
+		}
+	}
+	return host, nil
+}
+
+// launchUVMPool launches the `uvmpool` command serving `address` and waits
+// until it is served.
+func launchUVMPool(address string, p uvmpool.Profile, shimOpts *runhcsopts.Options) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	config, err := json.Marshal(&uvmPoolLaunch{Options: shimOpts, Profile: p})
+	if err != nil {
+		return err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	defer w.Close()
+
+	cmd := &exec.Cmd{
+		Path: self,
+		Args: []string{
+			self,
+			"--namespace", namespaceFlag,
+			"--address", addressFlag,
+			"--publish-binary", containerdBinaryFlag,
+			"--id", "uvmpool",
+			"uvmpool",
+			"--socket", address,
+		},
+		Env:    os.Environ(),
+		Dir:    filepath.Dir(self),
+		Stdin:  bytes.NewReader(config),
+		Stdout: w,
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	w.Close()
+	// The pool closes stdout once it is served, or exits on failure.
+	msg, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(msg) > 0 {
+		return errors.Errorf("utility VM pool failed to start: %s", msg)
+	}
+	return cmd.Process.Release()
+}
+
+var uvmPoolCommand = cli.Command{
+	Name:           "uvmpool",
+	Hidden:         true,
+	SkipArgReorder: true,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "socket",
+			Usage: "the pipe address to serve the pool on",
+		},
+	},
+	Action: func(ctx *cli.Context) error {
+		// The pool is launched by the first pod shim that finds no pool for
+		// its namespace and runtime options. It reads a `uvmPoolLaunch` from
+		// stdin and closes stdout once it serves `socket`. On failure the
+		// error is written to stdout instead. The pool exits once every
+		// profile has been idle for `UvmPoolIdleTimeoutInSeconds`.
+		//
+		// The pool has no console. It logs to ETW like every shim and to the
+		// log directory of the runtime options if one is set.
+		logrus.SetOutput(ioutil.Discard)
+		cli.ErrWriter = os.Stdout
+
+		var launch uvmPoolLaunch
+		if err := json.NewDecoder(os.Stdin).Decode(&launch); err != nil {
+			return err
+		}
+		os.Stdin.Close()
+		if launch.Options == nil {
+			launch.Options = &runhcsopts.Options{}
+		}
+		if launch.Options.LogDirectory != "" {
+			dir := filepath.Join(launch.Options.LogDirectory, filepath.Base(ctx.String("socket")))
+			if err := addFileLogging(logrus.StandardLogger(), dir, launch.Options); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"path":          dir,
+					logrus.ErrorKey: err,
+				}).Error("uvmpool - failed to start file logging")
+			}
+		}
+
+		idleTimeout := defaultUVMPoolIdleTimeout
+		if launch.Options.UvmPoolIdleTimeoutInSeconds != 0 {
+			idleTimeout = time.Duration(launch.Options.UvmPoolIdleTimeoutInSeconds) * time.Second
+		}
+		size := int(launch.Options.UvmPoolSize)
+		pool, err := uvmpool.New(&hostFactory{
+			owner:    filepath.Base(os.Args[0]),
+			shimOpts: launch.Options,
+		}, uvmpool.Config{
+			Profiles:    map[uvmpool.Profile]int{launch.Profile: size},
+			Size:        size,
+			MaxVMs:      int(launch.Options.UvmPoolMaxVms),
+			IdleTimeout: idleTimeout,
+		})
+		if err != nil {
+			return err
+		}
+
+		l, err := winio.ListenPipe(ctx.String("socket"), nil)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+
+		pool.Start()
+		defer pool.Close()
+
+		// Alert the launching shim that the pool is served.
+		os.Stdout.Close()
+
+		// conns tracks the connections being served and the output relays of
+		// the utility VMs handed to pod shims.
+		var conns sync.WaitGroup
+		accepting := make(chan struct{})
+		go func() {
+			defer close(accepting)
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					if err == winio.ErrPipeListenerClosed {
+						return
+					}
+					logrus.WithError(err).Error("uvmpool - failed to accept connection")
+					continue
+				}
+				conns.Add(1)
+				go func() {
+					defer conns.Done()
+					serveUVMPoolConn(pool, c, &conns)
+				}()
+			}
+		}()
+
+		t := time.NewTicker(uvmpool.DefaultCheckInterval)
+		defer t.Stop()
+		for range t.C {
+			if pool.Idle() {
+				break
+			}
+		}
+		logrus.Info("uvmpool - exiting idle pool")
+		// Stop serving so that a new pool can be launched for later pods but
+		// keep relaying the output of the utility VMs handed to pod shims
+		// until they exit.
+		l.Close()
+		<-accepting
+		pool.Close()
+		conns.Wait()
+		return nil
+	},
+}
+
+// serveUVMPoolConn serves a `uvmPoolRequest` on `c`. The utility VM taken
+// from `pool` is released to the pod shim once it acknowledges that it opened
+// it, otherwise it is closed. The relay of the output of a released utility VM
+// is added to `relays`.
+func serveUVMPoolConn(pool *uvmpool.Pool, c net.Conn, relays *sync.WaitGroup) {
+	defer c.Close()
+
+	dec := json.NewDecoder(c)
+	var req uvmPoolRequest
+	if err := dec.Decode(&req); err != nil {
+		logrus.WithError(err).Warning("uvmpool - failed to read request")
+		return
+	}
+	log := logrus.WithField("profile", req.Profile.String())
+
+	var (
+		resp uvmPoolResponse
+		host *pooledHost
+	)
+	vm, err := pool.Get(context.Background(), req.Profile)
+	if err == nil {
+		host = vm.(*pooledHost)
+		resp.Host, err = host.Manifest()
+		if err != nil {
+			host.Close()
+			host = nil
+		}
+	}
+	if err != nil && err != uvmpool.ErrEmpty {
+		resp.Error = err.Error()
+	}
+	// The pipe is served before the response so that the pod shim can
+	// connect as soon as it reads it.
+	var ol net.Listener
+	if host != nil && host.relay != nil {
+		pipe := fmt.Sprintf(uvmPoolOutputAddrFmt, host.ID())
+		if ol, err = winio.ListenPipe(pipe, nil); err != nil {
+			log.WithError(err).WithField("uvm-id", host.ID()).Warning("uvmpool - failed to serve utility VM output")
+			ol = nil
+		} else {
+			resp.OutputPipe = pipe
+		}
+	}
+	if err := json.NewEncoder(c).Encode(&resp); err != nil {
+		log.WithError(err).Warning("uvmpool - failed to write response")
+		if host != nil {
+			host.Close()
+		}
+		if ol != nil {
+			ol.Close()
+		}
+		return
+	}
+	if host == nil {
+		return
+	}
+
+	var ack uvmPoolAck
+	if err := dec.Decode(&ack); err != nil || !ack.Opened {
+		log.WithError(err).WithField("uvm-id", host.ID()).Warning("uvmpool - utility VM was not opened by the pod")
+		host.Close()
+		if ol != nil {
+			ol.Close()
+		}
+		return
+	}
+	if err := host.Release(); err != nil {
+		log.WithError(err).Warning("uvmpool - failed to release utility VM")
+	}
+	if host.relay != nil {
+		relays.Add(1)
+		go func() {
+			defer relays.Done()
+			handOffOutput(host, ol, log.WithField("uvm-id", host.ID()))
+		}()
+	}
+}
+
+// handOffOutput passes the output of `host` to the pod shim that took it once
+// the shim connects to `l` and returns once the output ended. If `l` is `nil`
+// or the shim does not connect within `uvmPoolDialTimeout` the pool keeps
+// logging the output itself.
+func handOffOutput(host *pooledHost, l net.Listener, log *logrus.Entry) {
+	if l != nil {
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			c, err := l.Accept()
+			if err != nil {
+				if err != winio.ErrPipeListenerClosed {
+					log.WithError(err).Warning("uvmpool - failed to accept output connection")
+				}
+				c = nil
+			}
+			accepted <- c
+		}()
+		select {
+		case c := <-accepted:
+			if c != nil {
+				host.relay.Switch(func(r io.Reader) {
+					io.Copy(c, r)
+					c.Close()
+				})
+			}
+		case <-time.After(uvmPoolDialTimeout):
+			log.Warning("uvmpool - pod shim did not connect to the utility VM output")
+		}
+		l.Close()
+	}
+	<-host.relay.Done()
+}
+
+var _ = (uvmpool.VM)(&pooledHost{})
+
+// pooledHost is a utility VM in the pool.
+type pooledHost struct {
+	*uvm.UtilityVM
+	// relay passes on the output forwarded from the guest. The pool logs it
+	// until the utility VM is handed to a pod shim. `nil` if the output is not
+	// forwarded.
+	relay *gcsoutput.Relay
+}
+
+// Check verifies that the utility VM still has a connection to its guest.
+func (h *pooledHost) Check(ctx context.Context) error {
+	props, err := h.ComputeSystem().Properties(schema1.PropertyTypeGuestConnection)
+	if err != nil {
+		return err
+	}
+	if props.GuestConnectionInfo.ProtocolVersion == 0 {
+		return errors.New("utility VM has no guest connection")
+	}
+	return nil
+}
+
+var _ = (uvmpool.Factory)(&hostFactory{})
+
+// hostFactory creates and boots the utility VMs of the pool.
+type hostFactory struct {
+	owner    string
+	shimOpts *runhcsopts.Options
+}
+
+func (f *hostFactory) Create(ctx context.Context, p uvmpool.Profile) (uvmpool.VM, error) {
+	if p.OS != "linux" {
+		return nil, errors.Errorf("utility VM pooling is not supported for '%s'", p.OS)
+	}
+	lopts, err := pooledOptionsLCOW(fmt.Sprintf("uvmpool-%s@vm", guid.New()), f.owner, p, f.shimOpts)
+	if err != nil {
+		return nil, err
+	}
+	var relay *gcsoutput.Relay
+	if lopts.ForwardStdout || lopts.ForwardStderr {
+		relay = gcsoutput.NewRelay(gcsoutput.Handler(lopts.OutputHandler))
+		lopts.OutputHandler = uvm.OutputHandler(relay.Handle)
+	}
+	host, err := uvm.CreateLCOW(ctx, lopts)
+	if err != nil {
+		return nil, err
+	}
+	if err := startHost(host); err != nil {
+		host.Close()
+		return nil, err
+	}
+	return &pooledHost{UtilityVM: host, relay: relay}, nil
+}
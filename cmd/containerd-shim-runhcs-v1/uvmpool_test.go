@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/oci"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func lcowPoolTestOptions(t *testing.T, annotations map[string]string, shimOpts *runhcsopts.Options) *uvm.OptionsLCOW {
+	s := &specs.Spec{
+		Linux:       &specs.Linux{},
+		Annotations: annotations,
+	}
+	opts, err := oci.SpecToUVMCreateOpts(s, t.Name()+"@vm", "test", shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with: %v", err)
+	}
+	return opts.(*uvm.OptionsLCOW)
+}
+
+func Test_IsPoolableLCOW_Default(t *testing.T) {
+	shimOpts := &runhcsopts.Options{UvmPoolSize: 1}
+	ok, err := isPoolableLCOW(lcowPoolTestOptions(t, nil, shimOpts), shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with: %v", err)
+	}
+	if !ok {
+		t.Fatal("default options should be poolable")
+	}
+}
+
+func Test_IsPoolableLCOW_ProfileAnnotations(t *testing.T) {
+	shimOpts := &runhcsopts.Options{UvmPoolSize: 1}
+	lopts := lcowPoolTestOptions(t, map[string]string{
+		"io.microsoft.virtualmachine.computetopology.memory.sizeinmb": "2048",
+		"io.microsoft.virtualmachine.computetopology.processor.count": "1",
+		"io.microsoft.virtualmachine.lcow.bootfilesrootpath":          "C:\\boot",
+	}, shimOpts)
+	ok, err := isPoolableLCOW(lopts, shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with: %v", err)
+	}
+	if !ok {
+		t.Fatal("options that only differ in the profile should be poolable")
+	}
+	p := lcowProfile(lopts)
+	if p.OS != "linux" || p.MemorySizeInMB != 2048 || p.ProcessorCount != 1 || p.BootFilesPath != "C:\\boot" {
+		t.Fatalf("unexpected profile: %s", p)
+	}
+}
+
+func Test_IsPoolableLCOW_OtherAnnotations(t *testing.T) {
+	shimOpts := &runhcsopts.Options{UvmPoolSize: 1}
+	lopts := lcowPoolTestOptions(t, map[string]string{
+		"io.microsoft.virtualmachine.devices.virtualpmem.maximumcount": "7",
+	}, shimOpts)
+	ok, err := isPoolableLCOW(lopts, shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with: %v", err)
+	}
+	if ok {
+		t.Fatal("options that differ outside of the profile should not be poolable")
+	}
+}
+
+func Test_UVMPoolAddress_DiffersByOptions(t *testing.T) {
+	a, err := uvmPoolAddress(&runhcsopts.Options{UvmPoolSize: 1})
+	if err != nil {
+		t.Fatalf("should not have failed with: %v", err)
+	}
+	b, err := uvmPoolAddress(&runhcsopts.Options{UvmPoolSize: 1, VmProcessorCount: 4})
+	if err != nil {
+		t.Fatalf("should not have failed with: %v", err)
+	}
+	if a == b {
+		t.Fatalf("expected different addresses, got: %s", a)
+	}
+}
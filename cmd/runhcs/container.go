@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 
 	winio "github.com/Microsoft/go-winio"
 	"github.com/Microsoft/hcsshim/internal/cni"
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/guid"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/hcsoci"
@@ -79,7 +81,7 @@ const (
 type container struct {
 	persistedState
 	ShimPid   int
-	hc        *hcs.System
+	hc        cow.ComputeSystem
 	resources *hcsoci.Resources
 }
 
@@ -387,7 +389,7 @@ func createContainer(cfg *containerConfig) (_ *container, err error) {
 
 	// Start a VM if necessary.
 	if newvm {
-		opts, err := oci.SpecToUVMCreateOpts(cfg.Spec, vmID(c.ID), cfg.Owner)
+		opts, err := oci.SpecToUVMCreateOpts(cfg.Spec, vmID(c.ID), cfg.Owner, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -430,7 +432,7 @@ func createContainer(cfg *containerConfig) (_ *container, err error) {
 		if err != nil {
 			return nil, err
 		}
-		c.hc, err = hcs.OpenComputeSystem(cfg.ID)
+		c.hc, err = hcs.Backend().OpenComputeSystem(cfg.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -533,7 +535,7 @@ func createContainerInHost(c *container, vm *uvm.UtilityVM) (err error) {
 		logfields.ContainerID: c.ID,
 		logfields.UVMID:       vmid,
 	}).Info("creating container in UVM")
-	hc, resources, err := hcsoci.CreateContainer(opts)
+	hc, resources, err := hcsoci.CreateContainer(context.Background(), opts)
 	if err != nil {
 		return err
 	}
@@ -644,7 +646,7 @@ func getContainer(id string, notStopped bool) (*container, error) {
 		return nil, errContainerStopped
 	}
 
-	hc, err := hcs.OpenComputeSystem(c.ID)
+	hc, err := hcs.Backend().OpenComputeSystem(c.ID)
 	if err == nil {
 		c.hc = hc
 	} else if !hcs.IsNotExist(err) {
@@ -1,6 +1,8 @@
 package main
 
 import (
+	gocontext "context"
+
 	"github.com/Microsoft/hcsshim/internal/appargs"
 	"github.com/Microsoft/hcsshim/internal/lcow"
 	"github.com/Microsoft/hcsshim/internal/uvm"
@@ -36,7 +38,7 @@ var createScratchCommand = cli.Command{
 		opts.MemorySizeInMB = 256
 		opts.VPMemDeviceCount = 1
 
-		convertUVM, err := uvm.CreateLCOW(opts)
+		convertUVM, err := uvm.CreateLCOW(gocontext.Background(), opts)
 		if err != nil {
 			return errors.Wrapf(err, "failed to create '%s'", opts.ID)
 		}
@@ -45,7 +47,7 @@ var createScratchCommand = cli.Command{
 			return errors.Wrapf(err, "failed to start '%s'", opts.ID)
 		}
 
-		if err := lcow.CreateScratch(convertUVM, dest, lcow.DefaultScratchSizeGB, "", ""); err != nil {
+		if err := lcow.CreateScratch(gocontext.Background(), convertUVM, dest, lcow.DefaultScratchSizeGB, "", ""); err != nil {
 			return errors.Wrapf(err, "failed to create ext4vhdx for '%s'", opts.ID)
 		}
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	gocontext "context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -74,9 +75,9 @@ var vmshimCommand = cli.Command{
 
 		var vm *uvm.UtilityVM
 		if isLCOW {
-			vm, err = uvm.CreateLCOW(opts.(*uvm.OptionsLCOW))
+			vm, err = uvm.CreateLCOW(gocontext.Background(), opts.(*uvm.OptionsLCOW))
 		} else {
-			vm, err = uvm.CreateWCOW(opts.(*uvm.OptionsWCOW))
+			vm, err = uvm.CreateWCOW(gocontext.Background(), opts.(*uvm.OptionsWCOW))
 		}
 		if err != nil {
 			return err
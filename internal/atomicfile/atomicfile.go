@@ -0,0 +1,24 @@
+// Package atomicfile writes files so that readers never see a partial write.
+//
+// The package has no platform dependencies.
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// WriteFile writes `b` to a temporary file next to `path` with the permissions
+// `perm` and renames it over `path`. A reader of `path` sees either the old or
+// the new contents, never a partial write.
+func WriteFile(path string, b []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
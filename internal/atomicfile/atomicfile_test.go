@@ -0,0 +1,45 @@
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_WriteFile_Replaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "file")
+	for _, contents := range []string{"old", "new"} {
+		if err := WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatalf("should not have failed with error: %v", err)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read file: %v", err)
+		}
+		if string(b) != contents {
+			t.Fatalf("expected contents: '%s', got: '%s'", contents, b)
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("expected temporary file to be removed, got: %v", err)
+	}
+}
+
+func Test_WriteFile_MissingDirectory_Error(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := WriteFile(filepath.Join(dir, "missing", "file"), []byte("b"), 0600); err == nil {
+		t.Fatal("expected error for a missing directory")
+	}
+}
@@ -0,0 +1,118 @@
+// Package cow ("container or VM") defines the compute system and process
+// operations used to manage containers and utility VMs independent of the
+// platform that implements them.
+//
+// The HCS implementation is returned by `hcs.Backend()`. An in-memory
+// implementation for tests is in `internal/cow/fake`.
+package cow
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/schema1"
+)
+
+// ExitReason describes why a compute system or process exited.
+type ExitReason string
+
+const (
+	// ExitReasonNone means the exit was requested or the compute system shut
+	// down cleanly.
+	ExitReasonNone ExitReason = ""
+	// ExitReasonOutOfMemory means the exit was caused by memory exhaustion.
+	ExitReasonOutOfMemory ExitReason = "OutOfMemory"
+	// ExitReasonGuestCrash means the guest OS of a utility VM crashed.
+	ExitReasonGuestCrash ExitReason = "GuestCrash"
+	// ExitReasonTerminated means the compute system was forcibly terminated.
+	ExitReasonTerminated ExitReason = "Terminated"
+	// ExitReasonHostShutdown means the compute system exited because the host
+	// is shutting down.
+	ExitReasonHostShutdown ExitReason = "HostShutdown"
+	// ExitReasonUnexpected means the compute system exited unexpectedly for
+	// any other reason.
+	ExitReasonUnexpected ExitReason = "UnexpectedExit"
+	// ExitReasonTimedOut means the process was killed because it ran longer
+	// than the timeout it was started with.
+	ExitReasonTimedOut ExitReason = "TimedOut"
+)
+
+// Process is a process running in a compute system.
+type Process interface {
+	// Pid returns the process ID of the process within the compute system.
+	Pid() int
+	// Signal signals the process with `options`.
+	Signal(options interface{}) error
+	// Kill signals the process to terminate but does not wait for it to
+	// finish terminating.
+	Kill() error
+	// Wait waits for the process to exit.
+	Wait() error
+	// WaitTimeout waits for the process to exit or for `timeout` to elapse.
+	WaitTimeout(timeout time.Duration) error
+	// ResizeConsole resizes the console of the process.
+	ResizeConsole(width, height uint16) error
+	// ExitCode returns the exit code of the process. The process must have
+	// already exited.
+	ExitCode() (int, error)
+	// Stdio returns the stdin, stdout and stderr pipes of the process. A pipe
+	// is `nil` if it was not requested when the process was created. Each pipe
+	// can only be returned once.
+	Stdio() (io.WriteCloser, io.ReadCloser, io.ReadCloser, error)
+	// CloseStdin closes the write side of the stdin pipe so that the process
+	// is notified on the read side that there is no more data in stdin.
+	CloseStdin() error
+	// Close releases the resources associated with the process. It does not
+	// terminate the process.
+	Close() error
+}
+
+// ComputeSystem is a container or utility VM.
+type ComputeSystem interface {
+	// ID returns the identifier of the compute system.
+	ID() string
+	// Start starts the compute system.
+	Start() error
+	// Shutdown requests the compute system to shut down cleanly.
+	Shutdown() error
+	// Terminate requests the compute system to terminate.
+	Terminate() error
+	// Wait waits for the compute system to exit.
+	Wait() error
+	// WaitExpectedError waits for the compute system to exit. If the compute
+	// system exits with `expected` returns `nil`.
+	WaitExpectedError(expected error) error
+	// WaitTimeout waits for the compute system to exit or for `timeout` to
+	// elapse.
+	WaitTimeout(timeout time.Duration) error
+	// ExitReason returns why the compute system exited. It is only valid once
+	// a wait has returned because the compute system exited.
+	ExitReason() ExitReason
+	// Properties returns the requested properties of the compute system.
+	Properties(types ...schema1.PropertyType) (*schema1.ContainerProperties, error)
+	// Pause pauses the execution of the compute system.
+	Pause() error
+	// Resume resumes the execution of a paused compute system.
+	Resume() error
+	// Save saves the state of the compute system as described by `options`.
+	Save(options interface{}) error
+	// Modify modifies the compute system with the request `config`.
+	Modify(config interface{}) error
+	// CreateProcess creates a process in the compute system described by `c`.
+	CreateProcess(c interface{}) (Process, error)
+	// OpenProcess opens the existing process `pid` in the compute system.
+	OpenProcess(pid int) (Process, error)
+	// Close releases the resources associated with the compute system. It does
+	// not terminate the compute system.
+	Close() error
+}
+
+// Backend creates and opens compute systems.
+type Backend interface {
+	// CreateComputeSystem creates the compute system `id` described by
+	// `document`. The compute system is not started.
+	CreateComputeSystem(ctx context.Context, id string, document interface{}) (ComputeSystem, error)
+	// OpenComputeSystem opens the existing compute system `id`.
+	OpenComputeSystem(id string) (ComputeSystem, error)
+}
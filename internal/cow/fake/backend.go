@@ -0,0 +1,105 @@
+// Package fake is an in-memory implementation of `cow.Backend` for tests. It
+// records the documents used to create and modify each compute system and
+// simulates the lifecycle of compute systems and the processes in them.
+//
+// Any operation can be made to fail with `SetError` or to hang with `Block` on
+// the compute system or process.
+package fake
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+)
+
+var (
+	// ErrNotFound is returned when opening a compute system or process that
+	// does not exist.
+	ErrNotFound = errors.New("fake: not found")
+	// ErrAlreadyExists is returned when creating a compute system with the id
+	// of an existing compute system.
+	ErrAlreadyExists = errors.New("fake: already exists")
+	// ErrInvalidState is returned when an operation is not valid in the
+	// current state of the compute system or process.
+	ErrInvalidState = errors.New("fake: invalid state")
+	// ErrTimeout is returned by `WaitTimeout` when the timeout elapses.
+	ErrTimeout = errors.New("fake: timeout")
+	// ErrClosed is returned by `Process.Wait` when the process handle is
+	// closed before the process exits.
+	ErrClosed = errors.New("fake: closed")
+)
+
+var _ = (cow.Backend)(&Backend{})
+
+// Backend is an in-memory `cow.Backend`. The zero value is ready to use.
+type Backend struct {
+	m       sync.Mutex
+	systems map[string]*ComputeSystem
+	// createErr if not `nil` is returned by all calls to
+	// `CreateComputeSystem`.
+	createErr error
+}
+
+// SetCreateError makes all future calls to `CreateComputeSystem` fail with
+// `err`. Pass `nil` to clear.
+func (b *Backend) SetCreateError(err error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.createErr = err
+}
+
+// CreateComputeSystem creates the compute system `id` in the created state and
+// records `document`.
+func (b *Backend) CreateComputeSystem(ctx context.Context, id string, document interface{}) (cow.ComputeSystem, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if b.createErr != nil {
+		return nil, b.createErr
+	}
+	if cs, ok := b.systems[id]; ok && !cs.Exited() {
+		return nil, ErrAlreadyExists
+	}
+	if b.systems == nil {
+		b.systems = make(map[string]*ComputeSystem)
+	}
+	cs := newComputeSystem(id, document)
+	b.systems[id] = cs
+	return cs, nil
+}
+
+// OpenComputeSystem opens the compute system `id`. Exited compute systems
+// cannot be opened.
+func (b *Backend) OpenComputeSystem(id string) (cow.ComputeSystem, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	cs, ok := b.systems[id]
+	if !ok || cs.Exited() {
+		return nil, ErrNotFound
+	}
+	return cs, nil
+}
+
+// ComputeSystem returns the compute system `id` or `nil` if it was never
+// created.
+func (b *Backend) ComputeSystem(id string) *ComputeSystem {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.systems[id]
+}
+
+// ComputeSystems returns the ids of all compute systems that have been
+// created including those that have exited.
+func (b *Backend) ComputeSystems() []string {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	var ids []string
+	for id := range b.systems {
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,340 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/schema1"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
+)
+
+func createStartedSystem(t *testing.T, b *Backend) *ComputeSystem {
+	cs, err := b.CreateComputeSystem(context.Background(), t.Name(), t.Name()+"-document")
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := cs.Start(); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	return cs.(*ComputeSystem)
+}
+
+func Test_Backend_CreateComputeSystem_RecordsDocument(t *testing.T) {
+	b := &Backend{}
+	cs := createStartedSystem(t, b)
+
+	if doc := b.ComputeSystem(t.Name()).Document(); doc != t.Name()+"-document" {
+		t.Fatalf("expected document: '%s-document', got: '%v'", t.Name(), doc)
+	}
+	if cs.State() != StateRunning {
+		t.Fatalf("expected state: '%s', got: '%s'", StateRunning, cs.State())
+	}
+}
+
+func Test_Backend_CreateComputeSystem_Duplicate_Error(t *testing.T) {
+	b := &Backend{}
+	createStartedSystem(t, b)
+
+	if _, err := b.CreateComputeSystem(context.Background(), t.Name(), nil); err != ErrAlreadyExists {
+		t.Fatalf("expected error: %v, got: %v", ErrAlreadyExists, err)
+	}
+}
+
+func Test_Backend_CreateComputeSystem_Injected_Error(t *testing.T) {
+	b := &Backend{}
+	expected := errors.New("injected")
+	b.SetCreateError(expected)
+
+	if _, err := b.CreateComputeSystem(context.Background(), t.Name(), nil); err != expected {
+		t.Fatalf("expected error: %v, got: %v", expected, err)
+	}
+}
+
+func Test_Backend_OpenComputeSystem(t *testing.T) {
+	b := &Backend{}
+	if _, err := b.OpenComputeSystem(t.Name()); err != ErrNotFound {
+		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
+	}
+	cs := createStartedSystem(t, b)
+	if _, err := b.OpenComputeSystem(t.Name()); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	cs.Terminate()
+	if _, err := b.OpenComputeSystem(t.Name()); err != ErrNotFound {
+		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
+	}
+}
+
+func Test_ComputeSystem_PauseResume(t *testing.T) {
+	cs := createStartedSystem(t, &Backend{})
+
+	if err := cs.Resume(); err != ErrInvalidState {
+		t.Fatalf("expected error: %v, got: %v", ErrInvalidState, err)
+	}
+	if err := cs.Save(nil); err != ErrInvalidState {
+		t.Fatalf("expected error: %v, got: %v", ErrInvalidState, err)
+	}
+	if err := cs.Pause(); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := cs.Save("options"); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := cs.Resume(); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if saves := cs.Saves(); len(saves) != 1 || saves[0] != "options" {
+		t.Fatalf("expected saves: [options], got: %v", saves)
+	}
+}
+
+func Test_ComputeSystem_Modify_Records(t *testing.T) {
+	cs := createStartedSystem(t, &Backend{})
+
+	if err := cs.Modify("a"); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := cs.Modify("b"); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if mods := cs.Modifications(); len(mods) != 2 || mods[0] != "a" || mods[1] != "b" {
+		t.Fatalf("expected modifications: [a b], got: %v", mods)
+	}
+}
+
+func Test_ComputeSystem_SetError(t *testing.T) {
+	cs := createStartedSystem(t, &Backend{})
+
+	expected := errors.New("injected")
+	cs.SetError("Pause", expected)
+	if err := cs.Pause(); err != expected {
+		t.Fatalf("expected error: %v, got: %v", expected, err)
+	}
+	cs.SetError("Pause", nil)
+	if err := cs.Pause(); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+}
+
+func Test_ComputeSystem_Block(t *testing.T) {
+	cs := createStartedSystem(t, &Backend{})
+
+	unblock := cs.Block("Pause")
+	done := make(chan error, 1)
+	go func() {
+		done <- cs.Pause()
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("expected Pause to block, got: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	unblock()
+	if err := <-done; err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+}
+
+func Test_ComputeSystem_Terminate_KillsProcesses(t *testing.T) {
+	cs := createStartedSystem(t, &Backend{})
+
+	p, err := cs.CreateProcess(&hcsschema.ProcessParameters{CommandLine: "cmd"})
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := cs.WaitTimeout(time.Millisecond); err != ErrTimeout {
+		t.Fatalf("expected error: %v, got: %v", ErrTimeout, err)
+	}
+	if err := cs.Terminate(); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := cs.Wait(); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := p.Wait(); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if code, err := p.ExitCode(); err != nil || code != killedExitCode {
+		t.Fatalf("expected exit code: %d, got: %d, %v", killedExitCode, code, err)
+	}
+}
+
+func Test_ComputeSystem_Exit_Error(t *testing.T) {
+	cs := createStartedSystem(t, &Backend{})
+
+	expected := errors.New("crashed")
+	cs.Exit(expected)
+	if err := cs.Wait(); err != expected {
+		t.Fatalf("expected error: %v, got: %v", expected, err)
+	}
+	if err := cs.WaitExpectedError(expected); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+}
+
+func Test_ComputeSystem_CreateProcess_NotStarted_Error(t *testing.T) {
+	cs, _ := (&Backend{}).CreateComputeSystem(context.Background(), t.Name(), nil)
+
+	if _, err := cs.CreateProcess(&hcsschema.ProcessParameters{}); err != ErrInvalidState {
+		t.Fatalf("expected error: %v, got: %v", ErrInvalidState, err)
+	}
+}
+
+func Test_ComputeSystem_Properties_ProcessList(t *testing.T) {
+	cs := createStartedSystem(t, &Backend{})
+
+	p1, _ := cs.CreateProcess(&hcsschema.ProcessParameters{CommandLine: "first"})
+	cs.CreateProcess(&hcsschema.ProcessParameters{CommandLine: "second"})
+	p1.Kill()
+
+	props, err := cs.Properties(schema1.PropertyTypeProcessList)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if len(props.ProcessList) != 1 ||
+		props.ProcessList[0].ProcessId != 2 ||
+		props.ProcessList[0].ImageName != "second" {
+		t.Fatalf("expected only process 2 'second', got: %+v", props.ProcessList)
+	}
+}
+
+func Test_Process_Stdio(t *testing.T) {
+	cs := createStartedSystem(t, &Backend{})
+
+	cp, err := cs.CreateProcess(&hcsschema.ProcessParameters{
+		CreateStdInPipe:  true,
+		CreateStdOutPipe: true,
+	})
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	p := cs.Process(cp.Pid())
+	in, out, serr, err := cp.Stdio()
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if in == nil || out == nil || serr != nil {
+		t.Fatalf("expected stdin and stdout only, got: %v, %v, %v", in, out, serr)
+	}
+	if _, _, _, err := cp.Stdio(); err == nil {
+		t.Fatal("expected error on second call to Stdio")
+	}
+
+	go func() {
+		in.Write([]byte("ping"))
+		cp.CloseStdin()
+	}()
+	b, err := ioutil.ReadAll(p.Stdin())
+	if err != nil || string(b) != "ping" {
+		t.Fatalf("expected stdin: 'ping', got: '%s', %v", b, err)
+	}
+	if !p.StdinClosed() {
+		t.Fatal("expected stdin to be closed")
+	}
+
+	go func() {
+		p.Stdout().Write([]byte("pong"))
+		p.Exit(3)
+	}()
+	b, err = ioutil.ReadAll(out)
+	if err != nil || string(b) != "pong" {
+		t.Fatalf("expected stdout: 'pong', got: '%s', %v", b, err)
+	}
+	cp.Wait()
+	if code, err := cp.ExitCode(); err != nil || code != 3 {
+		t.Fatalf("expected exit code: 3, got: %d, %v", code, err)
+	}
+}
+
+func Test_Process_ExitCode_Running_Error(t *testing.T) {
+	cs := createStartedSystem(t, &Backend{})
+	p, _ := cs.CreateProcess(&hcsschema.ProcessParameters{})
+
+	if _, err := p.ExitCode(); err != ErrInvalidState {
+		t.Fatalf("expected error: %v, got: %v", ErrInvalidState, err)
+	}
+	if err := p.WaitTimeout(time.Millisecond); err != ErrTimeout {
+		t.Fatalf("expected error: %v, got: %v", ErrTimeout, err)
+	}
+}
+
+func Test_Process_Close_UnblocksWait(t *testing.T) {
+	cs := createStartedSystem(t, &Backend{})
+	p, _ := cs.CreateProcess(&hcsschema.ProcessParameters{})
+	p.(*Process).Block("Kill")
+
+	go p.Kill()
+	p.Close()
+	if err := p.Wait(); err != ErrClosed {
+		t.Fatalf("expected error: %v, got: %v", ErrClosed, err)
+	}
+	if p.(*Process).Exited() {
+		t.Fatal("expected blocked Kill to not exit the process")
+	}
+}
+
+func Test_Process_Signal_Records(t *testing.T) {
+	cs := createStartedSystem(t, &Backend{})
+	cp, _ := cs.CreateProcess(&hcsschema.ProcessParameters{})
+	p := cs.Process(cp.Pid())
+
+	if err := cp.Signal("sigterm"); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if s := p.Signals(); len(s) != 1 || s[0] != "sigterm" {
+		t.Fatalf("expected signals: [sigterm], got: %v", s)
+	}
+	if p.Exited() {
+		t.Fatal("signal should not exit the process")
+	}
+	expected := errors.New("injected")
+	p.SetError("Signal", expected)
+	if err := cp.Signal("sigkill"); err != expected {
+		t.Fatalf("expected error: %v, got: %v", expected, err)
+	}
+}
+
+func Test_ComputeSystem_OpenProcess_NoStdio(t *testing.T) {
+	cs := createStartedSystem(t, &Backend{})
+	cp, _ := cs.CreateProcess(&hcsschema.ProcessParameters{CreateStdOutPipe: true})
+
+	op, err := cs.OpenProcess(cp.Pid())
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	in, out, serr, err := op.Stdio()
+	if err != nil || in != nil || out != nil || serr != nil {
+		t.Fatalf("expected no stdio for opened process, got: %v, %v, %v, %v", in, out, serr, err)
+	}
+	cp.Kill()
+	if err := op.Wait(); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if _, err := cs.OpenProcess(cp.Pid()); err != ErrNotFound {
+		t.Fatalf("expected error: %v, got: %v", ErrNotFound, err)
+	}
+}
+
+func Test_ComputeSystem_ExitReason(t *testing.T) {
+	b := &Backend{}
+	cs := createStartedSystem(t, b)
+	if r := cs.ExitReason(); r != cow.ExitReasonNone {
+		t.Fatalf("expected no exit reason while running, got: '%s'", r)
+	}
+	cs.ExitWithReason(cow.ExitReasonOutOfMemory, errors.New("oom"))
+	cs.Wait()
+	if r := cs.ExitReason(); r != cow.ExitReasonOutOfMemory {
+		t.Fatalf("expected exit reason: '%s', got: '%s'", cow.ExitReasonOutOfMemory, r)
+	}
+
+	cs2, _ := b.CreateComputeSystem(context.Background(), t.Name()+"-2", nil)
+	cs2.Start()
+	cs2.Terminate()
+	if r := cs2.ExitReason(); r != cow.ExitReasonTerminated {
+		t.Fatalf("expected exit reason: '%s', got: '%s'", cow.ExitReasonTerminated, r)
+	}
+}
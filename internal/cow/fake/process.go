@@ -0,0 +1,368 @@
+package fake
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+)
+
+var _ = (cow.Process)(&Process{})
+
+// Process is an in-memory `cow.Process`. It runs until `Exit` or `Kill` is
+// called or its compute system exits.
+type Process struct {
+	pid    int
+	config interface{}
+	image  string
+
+	stdinR  *io.PipeReader
+	stdinW  *io.PipeWriter
+	stdoutR *io.PipeReader
+	stdoutW *io.PipeWriter
+	stderrR *io.PipeReader
+	stderrW *io.PipeWriter
+
+	m           sync.Mutex
+	errs        map[string]error
+	blocks      map[string]chan struct{}
+	signals     []interface{}
+	consoleSize [2]uint16
+	stdioTaken  bool
+	stdinClosed bool
+	closed      bool
+
+	// closedCh is closed by `Close` to unblock `Wait` like the platform does
+	// when the process handle is closed.
+	closedCh  chan struct{}
+	closeOnce sync.Once
+
+	exited   chan struct{}
+	exitCode int
+	exitOnce sync.Once
+}
+
+func newProcess(pid int, config interface{}, pipes processPipes) *Process {
+	p := &Process{
+		pid:      pid,
+		config:   config,
+		closedCh: make(chan struct{}),
+		exited:   make(chan struct{}),
+	}
+	var cmd struct {
+		CommandLine string
+		CommandArgs []string
+	}
+	if b, err := json.Marshal(config); err == nil {
+		json.Unmarshal(b, &cmd)
+	}
+	p.image = cmd.CommandLine
+	if p.image == "" && len(cmd.CommandArgs) > 0 {
+		p.image = cmd.CommandArgs[0]
+	}
+	if pipes.CreateStdInPipe {
+		p.stdinR, p.stdinW = io.Pipe()
+	}
+	if pipes.CreateStdOutPipe {
+		p.stdoutR, p.stdoutW = io.Pipe()
+	}
+	if pipes.CreateStdErrPipe {
+		p.stderrR, p.stderrW = io.Pipe()
+	}
+	return p
+}
+
+// Config returns the parameters the process was created with.
+func (p *Process) Config() interface{} {
+	return p.config
+}
+
+// Stdin returns the process side of the stdin pipe or `nil` if no stdin pipe
+// was created.
+func (p *Process) Stdin() io.Reader {
+	if p.stdinR == nil {
+		return nil
+	}
+	return p.stdinR
+}
+
+// Stdout returns the process side of the stdout pipe or `nil` if no stdout
+// pipe was created. Writes block until the caller of `Stdio` reads them.
+func (p *Process) Stdout() io.Writer {
+	if p.stdoutW == nil {
+		return nil
+	}
+	return p.stdoutW
+}
+
+// Stderr returns the process side of the stderr pipe or `nil` if no stderr
+// pipe was created. Writes block until the caller of `Stdio` reads them.
+func (p *Process) Stderr() io.Writer {
+	if p.stderrW == nil {
+		return nil
+	}
+	return p.stderrW
+}
+
+// Signals returns the options of every call to `Signal` in order.
+func (p *Process) Signals() []interface{} {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return append([]interface{}(nil), p.signals...)
+}
+
+// ConsoleSize returns the width and height of the last call to
+// `ResizeConsole`.
+func (p *Process) ConsoleSize() (width, height uint16) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.consoleSize[0], p.consoleSize[1]
+}
+
+// StdinClosed returns `true` if `CloseStdin` has been called.
+func (p *Process) StdinClosed() bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.stdinClosed
+}
+
+// Closed returns `true` if `Close` has been called.
+func (p *Process) Closed() bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.closed
+}
+
+// Exited returns `true` if the process has exited.
+func (p *Process) Exited() bool {
+	select {
+	case <-p.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetError makes all future calls to the operation `op` fail with `err`. `op`
+// is the name of the method on `cow.Process`, for example "Signal". Pass `nil`
+// to clear.
+func (p *Process) SetError(op string, err error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.errs == nil {
+		p.errs = make(map[string]error)
+	}
+	p.errs[op] = err
+}
+
+// Block makes all future calls to the operation `op` block until the returned
+// function is called. This simulates a hung platform call. `op` is the name of
+// the method on `cow.Process`, for example "Kill".
+func (p *Process) Block(op string) (unblock func()) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.blocks == nil {
+		p.blocks = make(map[string]chan struct{})
+	}
+	ch := make(chan struct{})
+	p.blocks[op] = ch
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.m.Lock()
+			if p.blocks[op] == ch {
+				delete(p.blocks, op)
+			}
+			p.m.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Exit simulates the process exiting on its own with `code`. The stdout and
+// stderr pipes are closed so that any reader sees EOF.
+func (p *Process) Exit(code int) {
+	p.exit(code)
+}
+
+func (p *Process) exit(code int) {
+	p.exitOnce.Do(func() {
+		p.exitCode = code
+		if p.stdinR != nil {
+			p.stdinR.CloseWithError(io.ErrClosedPipe)
+		}
+		if p.stdoutW != nil {
+			p.stdoutW.Close()
+		}
+		if p.stderrW != nil {
+			p.stderrW.Close()
+		}
+		close(p.exited)
+	})
+}
+
+// err blocks while the operation `op` is blocked by `Block` and then returns
+// the error set for `op` by `SetError`.
+func (p *Process) err(op string) error {
+	p.m.Lock()
+	ch := p.blocks[op]
+	p.m.Unlock()
+	if ch != nil {
+		<-ch
+	}
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.errs[op]
+}
+
+// imageName returns the name reported for the process in the process list.
+func (p *Process) imageName() string {
+	return p.image
+}
+
+// open returns a handle to `p` as returned by `OpenProcess`.
+func (p *Process) open() cow.Process {
+	return &openedProcess{p}
+}
+
+func (p *Process) Pid() int {
+	return p.pid
+}
+
+// Signal records `options`. The process does not exit.
+func (p *Process) Signal(options interface{}) error {
+	if err := p.err("Signal"); err != nil {
+		return err
+	}
+	if p.Exited() {
+		return ErrInvalidState
+	}
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.signals = append(p.signals, options)
+	return nil
+}
+
+// Kill exits the process with exit code 1.
+func (p *Process) Kill() error {
+	if err := p.err("Kill"); err != nil {
+		return err
+	}
+	p.exit(killedExitCode)
+	return nil
+}
+
+// Wait waits for the process to exit. Like the platform it returns
+// `ErrClosed` if the process handle is closed first.
+func (p *Process) Wait() error {
+	if err := p.err("Wait"); err != nil {
+		return err
+	}
+	select {
+	case <-p.exited:
+		return nil
+	case <-p.closedCh:
+		if p.Exited() {
+			return nil
+		}
+		return ErrClosed
+	}
+}
+
+func (p *Process) WaitTimeout(timeout time.Duration) error {
+	if err := p.err("WaitTimeout"); err != nil {
+		return err
+	}
+	select {
+	case <-p.exited:
+		return nil
+	case <-time.After(timeout):
+		return ErrTimeout
+	}
+}
+
+func (p *Process) ResizeConsole(width, height uint16) error {
+	if err := p.err("ResizeConsole"); err != nil {
+		return err
+	}
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.consoleSize = [2]uint16{width, height}
+	return nil
+}
+
+func (p *Process) ExitCode() (int, error) {
+	if err := p.err("ExitCode"); err != nil {
+		return -1, err
+	}
+	if !p.Exited() {
+		return -1, ErrInvalidState
+	}
+	return p.exitCode, nil
+}
+
+// Stdio returns the caller side of the pipes requested when the process was
+// created. It can only be called once.
+func (p *Process) Stdio() (io.WriteCloser, io.ReadCloser, io.ReadCloser, error) {
+	if err := p.err("Stdio"); err != nil {
+		return nil, nil, nil, err
+	}
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.stdioTaken {
+		return nil, nil, nil, errors.New("fake: stdio already taken")
+	}
+	p.stdioTaken = true
+
+	var (
+		in       io.WriteCloser
+		out, err io.ReadCloser
+	)
+	if p.stdinW != nil {
+		in = p.stdinW
+	}
+	if p.stdoutR != nil {
+		out = p.stdoutR
+	}
+	if p.stderrR != nil {
+		err = p.stderrR
+	}
+	return in, out, err, nil
+}
+
+func (p *Process) CloseStdin() error {
+	if err := p.err("CloseStdin"); err != nil {
+		return err
+	}
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.stdinClosed = true
+	if p.stdinW != nil {
+		p.stdinW.Close()
+	}
+	return nil
+}
+
+func (p *Process) Close() error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.closed = true
+	p.closeOnce.Do(func() {
+		close(p.closedCh)
+	})
+	return nil
+}
+
+// openedProcess is a `Process` opened by `OpenProcess`.
+type openedProcess struct {
+	*Process
+}
+
+// Stdio returns no pipes. The platform only returns the pipes of a process to
+// the caller that created it.
+func (op *openedProcess) Stdio() (io.WriteCloser, io.ReadCloser, io.ReadCloser, error) {
+	return nil, nil, nil, nil
+}
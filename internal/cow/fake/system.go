@@ -0,0 +1,420 @@
+package fake
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/schema1"
+)
+
+// State is the state of a fake compute system.
+type State string
+
+const (
+	// StateCreated is the state after `CreateComputeSystem`.
+	StateCreated State = "Created"
+	// StateRunning is the state after `Start` or `Resume`.
+	StateRunning State = "Running"
+	// StatePaused is the state after `Pause`.
+	StatePaused State = "Paused"
+	// StateExited is the state after `Shutdown`, `Terminate` or `Exit`.
+	StateExited State = "Exited"
+)
+
+// killedExitCode is the exit code of every process that is killed or is
+// running when its compute system exits.
+const killedExitCode = 1
+
+var _ = (cow.ComputeSystem)(&ComputeSystem{})
+
+// ComputeSystem is an in-memory `cow.ComputeSystem`.
+type ComputeSystem struct {
+	id       string
+	document interface{}
+
+	m             sync.Mutex
+	state         State
+	errs          map[string]error
+	blocks        map[string]chan struct{}
+	modifications []interface{}
+	saves         []interface{}
+	processes     map[int]*Process
+	nextPid       int
+	closed        bool
+
+	exited     chan struct{}
+	exitErr    error
+	exitReason cow.ExitReason
+	exitOnce   sync.Once
+}
+
+func newComputeSystem(id string, document interface{}) *ComputeSystem {
+	return &ComputeSystem{
+		id:        id,
+		document:  document,
+		state:     StateCreated,
+		processes: make(map[int]*Process),
+		nextPid:   1,
+		exited:    make(chan struct{}),
+	}
+}
+
+// Document returns the document the compute system was created with.
+func (cs *ComputeSystem) Document() interface{} {
+	return cs.document
+}
+
+// State returns the current state of the compute system.
+func (cs *ComputeSystem) State() State {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	return cs.state
+}
+
+// Exited returns `true` if the compute system has exited.
+func (cs *ComputeSystem) Exited() bool {
+	return cs.State() == StateExited
+}
+
+// Closed returns `true` if `Close` has been called.
+func (cs *ComputeSystem) Closed() bool {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	return cs.closed
+}
+
+// Modifications returns every request passed to `Modify` in order.
+func (cs *ComputeSystem) Modifications() []interface{} {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	return append([]interface{}(nil), cs.modifications...)
+}
+
+// Saves returns the options of every call to `Save` in order.
+func (cs *ComputeSystem) Saves() []interface{} {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	return append([]interface{}(nil), cs.saves...)
+}
+
+// Process returns the process `pid` or `nil` if no such process was created.
+func (cs *ComputeSystem) Process(pid int) *Process {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	return cs.processes[pid]
+}
+
+// Processes returns all processes that have been created in the compute system
+// ordered by pid.
+func (cs *ComputeSystem) Processes() []*Process {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+
+	var ps []*Process
+	for pid := 1; pid < cs.nextPid; pid++ {
+		if p, ok := cs.processes[pid]; ok {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// SetError makes all future calls to the operation `op` fail with `err`. `op`
+// is the name of the method on `cow.ComputeSystem`, for example "Start". Pass
+// `nil` to clear.
+func (cs *ComputeSystem) SetError(op string, err error) {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if cs.errs == nil {
+		cs.errs = make(map[string]error)
+	}
+	cs.errs[op] = err
+}
+
+// Block makes all future calls to the operation `op` block until the returned
+// function is called. This simulates a hung platform call. `op` is the name of
+// the method on `cow.ComputeSystem`, for example "Start".
+func (cs *ComputeSystem) Block(op string) (unblock func()) {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if cs.blocks == nil {
+		cs.blocks = make(map[string]chan struct{})
+	}
+	ch := make(chan struct{})
+	cs.blocks[op] = ch
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cs.m.Lock()
+			if cs.blocks[op] == ch {
+				delete(cs.blocks, op)
+			}
+			cs.m.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// blocked blocks while the operation `op` is blocked by `Block`.
+func (cs *ComputeSystem) blocked(op string) {
+	cs.m.Lock()
+	ch := cs.blocks[op]
+	cs.m.Unlock()
+	if ch != nil {
+		<-ch
+	}
+}
+
+// Exit simulates the compute system exiting on its own with `err`. All
+// processes still running are killed. The exit reason is
+// `cow.ExitReasonUnexpected` if `err != nil`.
+func (cs *ComputeSystem) Exit(err error) {
+	reason := cow.ExitReasonNone
+	if err != nil {
+		reason = cow.ExitReasonUnexpected
+	}
+	cs.ExitWithReason(reason, err)
+}
+
+// ExitWithReason simulates the compute system exiting on its own for `reason`
+// with `err`. All processes still running are killed.
+func (cs *ComputeSystem) ExitWithReason(reason cow.ExitReason, err error) {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	cs.exitL(reason, err)
+}
+
+// exitL transitions the compute system to `StateExited`. The caller MUST hold
+// `cs.m`.
+func (cs *ComputeSystem) exitL(reason cow.ExitReason, err error) {
+	if cs.state == StateExited {
+		return
+	}
+	cs.state = StateExited
+	for _, p := range cs.processes {
+		p.exit(killedExitCode)
+	}
+	cs.exitOnce.Do(func() {
+		cs.exitErr = err
+		cs.exitReason = reason
+		close(cs.exited)
+	})
+}
+
+func (cs *ComputeSystem) ID() string {
+	return cs.id
+}
+
+func (cs *ComputeSystem) Start() error {
+	cs.blocked("Start")
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if err := cs.errs["Start"]; err != nil {
+		return err
+	}
+	if cs.state != StateCreated {
+		return ErrInvalidState
+	}
+	cs.state = StateRunning
+	return nil
+}
+
+func (cs *ComputeSystem) Shutdown() error {
+	cs.blocked("Shutdown")
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if err := cs.errs["Shutdown"]; err != nil {
+		return err
+	}
+	if cs.state == StateExited {
+		return ErrInvalidState
+	}
+	cs.exitL(cow.ExitReasonNone, nil)
+	return nil
+}
+
+func (cs *ComputeSystem) Terminate() error {
+	cs.blocked("Terminate")
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if err := cs.errs["Terminate"]; err != nil {
+		return err
+	}
+	cs.exitL(cow.ExitReasonTerminated, nil)
+	return nil
+}
+
+func (cs *ComputeSystem) Wait() error {
+	<-cs.exited
+	return cs.exitErr
+}
+
+func (cs *ComputeSystem) WaitExpectedError(expected error) error {
+	if err := cs.Wait(); err != expected {
+		return err
+	}
+	return nil
+}
+
+func (cs *ComputeSystem) WaitTimeout(timeout time.Duration) error {
+	select {
+	case <-cs.exited:
+		return cs.exitErr
+	case <-time.After(timeout):
+		return ErrTimeout
+	}
+}
+
+// ExitReason returns the reason passed to `ExitWithReason`,
+// `cow.ExitReasonTerminated` after `Terminate` and `cow.ExitReasonNone` after
+// `Shutdown`.
+func (cs *ComputeSystem) ExitReason() cow.ExitReason {
+	select {
+	case <-cs.exited:
+		return cs.exitReason
+	default:
+		return cow.ExitReasonNone
+	}
+}
+
+// Properties returns the id, state and for `schema1.PropertyTypeProcessList`
+// the running processes of the compute system.
+func (cs *ComputeSystem) Properties(types ...schema1.PropertyType) (*schema1.ContainerProperties, error) {
+	cs.blocked("Properties")
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if err := cs.errs["Properties"]; err != nil {
+		return nil, err
+	}
+	props := &schema1.ContainerProperties{
+		ID:      cs.id,
+		State:   string(cs.state),
+		Stopped: cs.state == StateExited,
+	}
+	for _, t := range types {
+		if t != schema1.PropertyTypeProcessList {
+			continue
+		}
+		for pid := 1; pid < cs.nextPid; pid++ {
+			if p, ok := cs.processes[pid]; ok && !p.Exited() {
+				props.ProcessList = append(props.ProcessList, schema1.ProcessListItem{
+					ProcessId: uint32(pid),
+					ImageName: p.imageName(),
+				})
+			}
+		}
+	}
+	return props, nil
+}
+
+func (cs *ComputeSystem) Pause() error {
+	cs.blocked("Pause")
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if err := cs.errs["Pause"]; err != nil {
+		return err
+	}
+	if cs.state != StateRunning {
+		return ErrInvalidState
+	}
+	cs.state = StatePaused
+	return nil
+}
+
+func (cs *ComputeSystem) Resume() error {
+	cs.blocked("Resume")
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if err := cs.errs["Resume"]; err != nil {
+		return err
+	}
+	if cs.state != StatePaused {
+		return ErrInvalidState
+	}
+	cs.state = StateRunning
+	return nil
+}
+
+func (cs *ComputeSystem) Save(options interface{}) error {
+	cs.blocked("Save")
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if err := cs.errs["Save"]; err != nil {
+		return err
+	}
+	if cs.state != StatePaused {
+		return ErrInvalidState
+	}
+	cs.saves = append(cs.saves, options)
+	return nil
+}
+
+func (cs *ComputeSystem) Modify(config interface{}) error {
+	cs.blocked("Modify")
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if err := cs.errs["Modify"]; err != nil {
+		return err
+	}
+	if cs.state == StateExited {
+		return ErrInvalidState
+	}
+	cs.modifications = append(cs.modifications, config)
+	return nil
+}
+
+// processPipes is the subset of the process parameters that decides which
+// stdio pipes are created. Both the WCOW and LCOW process parameters serialize
+// to it.
+type processPipes struct {
+	CreateStdInPipe  bool
+	CreateStdOutPipe bool
+	CreateStdErrPipe bool
+}
+
+// CreateProcess creates a running process described by `c`. The stdio pipes
+// are created according to the `CreateStd*Pipe` fields of `c`.
+func (cs *ComputeSystem) CreateProcess(c interface{}) (cow.Process, error) {
+	cs.blocked("CreateProcess")
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if err := cs.errs["CreateProcess"]; err != nil {
+		return nil, err
+	}
+	if cs.state != StateRunning {
+		return nil, ErrInvalidState
+	}
+	var pipes processPipes
+	if b, err := json.Marshal(c); err == nil {
+		json.Unmarshal(b, &pipes)
+	}
+	p := newProcess(cs.nextPid, c, pipes)
+	cs.processes[p.pid] = p
+	cs.nextPid++
+	return p, nil
+}
+
+// OpenProcess opens the running process `pid`. Like the platform the stdio of
+// an opened process is not available.
+func (cs *ComputeSystem) OpenProcess(pid int) (cow.Process, error) {
+	cs.blocked("OpenProcess")
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	if err := cs.errs["OpenProcess"]; err != nil {
+		return nil, err
+	}
+	p, ok := cs.processes[pid]
+	if !ok || p.Exited() {
+		return nil, ErrNotFound
+	}
+	return p.open(), nil
+}
+
+func (cs *ComputeSystem) Close() error {
+	cs.m.Lock()
+	defer cs.m.Unlock()
+	cs.closed = true
+	return nil
+}
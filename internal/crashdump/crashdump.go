@@ -0,0 +1,177 @@
+// Package crashdump collects the evidence left behind by a utility VM guest
+// that crashed and limits how much of it is retained on the host.
+//
+// The package has no platform dependencies.
+package crashdump
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultConsoleTailSize is the default number of bytes of guest console
+// output kept by a `TailBuffer`.
+const DefaultConsoleTailSize = 16 * 1024
+
+// Report describes the crash of a utility VM.
+type Report struct {
+	// UVMID is the ID of the utility VM that crashed.
+	UVMID string
+	// Reason is why the utility VM exited.
+	Reason string
+	// DumpPath is the host path of the crash dump. It is empty if the guest
+	// did not leave a dump.
+	DumpPath string
+	// ConsoleTail is the end of the guest console output before the crash.
+	ConsoleTail string
+	// CrashedAt is the time the crash was collected.
+	CrashedAt time.Time
+}
+
+// TailBuffer is an `io.Writer` that keeps only the last bytes written to it.
+// It is safe for concurrent use.
+type TailBuffer struct {
+	m    sync.Mutex
+	size int
+	buf  []byte
+	// truncated is true once bytes were discarded.
+	truncated bool
+}
+
+// NewTailBuffer returns a `TailBuffer` that keeps the last `size` bytes.
+func NewTailBuffer(size int) *TailBuffer {
+	return &TailBuffer{size: size}
+}
+
+// Write appends `p` to the buffer and discards the oldest bytes beyond the
+// buffer size. It never fails.
+func (t *TailBuffer) Write(p []byte) (int, error) {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	n := len(p)
+	if n >= t.size {
+		t.truncated = t.truncated || n > t.size || len(t.buf) > 0
+		t.buf = append(t.buf[:0], p[n-t.size:]...)
+		return n, nil
+	}
+	if over := len(t.buf) + n - t.size; over > 0 {
+		t.truncated = true
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+	}
+	t.buf = append(t.buf, p...)
+	return n, nil
+}
+
+// Bytes returns a copy of the bytes in the buffer.
+func (t *TailBuffer) Bytes() []byte {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return append([]byte(nil), t.buf...)
+}
+
+// String returns the buffer starting at its first complete line. If the
+// buffer has not discarded anything it is returned as is.
+func (t *TailBuffer) String() string {
+	t.m.Lock()
+	defer t.m.Unlock()
+	b := t.buf
+	if t.truncated {
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			b = b[i+1:]
+		}
+	}
+	return string(b)
+}
+
+// Limits are the retention limits of a directory of crash dumps.
+type Limits struct {
+	// MaxCount is the maximum number of entries retained. If `0` there is no
+	// limit.
+	MaxCount int
+	// MaxBytes is the maximum total size of the retained entries. If `0`
+	// there is no limit.
+	MaxBytes int64
+}
+
+type entry struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// Prune removes the oldest entries of `dir` until the entries that remain are
+// within `limits`. An entry is a file or a directory, in which case its size
+// is the total size of the files in it. Directories without files are not
+// entries, they belong to utility VMs that have not crashed. Returns the paths
+// that were removed.
+//
+// If `dir` does not exist there is nothing to prune.
+func Prune(dir string, limits Limits) ([]string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	entries := make([]entry, 0, len(fis))
+	var total int64
+	for _, fi := range fis {
+		e := entry{
+			path:    filepath.Join(dir, fi.Name()),
+			modTime: fi.ModTime(),
+			size:    fi.Size(),
+		}
+		if fi.IsDir() {
+			var files int
+			e.size, files = dirSize(e.path)
+			if files == 0 {
+				continue
+			}
+		}
+		total += e.size
+		entries = append(entries, e)
+	}
+	// Newest first.
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].modTime.Equal(entries[j].modTime) {
+			return entries[i].path > entries[j].path
+		}
+		return entries[i].modTime.After(entries[j].modTime)
+	})
+
+	var removed []string
+	for len(entries) > 0 {
+		count := len(entries)
+		if (limits.MaxCount <= 0 || count <= limits.MaxCount) && (limits.MaxBytes <= 0 || total <= limits.MaxBytes) {
+			break
+		}
+		oldest := entries[count-1]
+		if err := os.RemoveAll(oldest.path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, oldest.path)
+		total -= oldest.size
+		entries = entries[:count-1]
+	}
+	return removed, nil
+}
+
+// dirSize returns the total size and the number of the files in `dir`. Files
+// that cannot be read are not counted.
+func dirSize(dir string) (size int64, files int) {
+	filepath.Walk(dir, func(_ string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			size += fi.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files
+}
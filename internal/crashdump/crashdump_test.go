@@ -0,0 +1,191 @@
+package crashdump
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_TailBuffer_KeepsAll(t *testing.T) {
+	tb := NewTailBuffer(16)
+	tb.Write([]byte("line1\n"))
+	tb.Write([]byte("line2\n"))
+	if s := tb.String(); s != "line1\nline2\n" {
+		t.Fatalf("unexpected tail: %q", s)
+	}
+}
+
+func Test_TailBuffer_KeepsTail(t *testing.T) {
+	tb := NewTailBuffer(10)
+	tb.Write([]byte("first\n"))
+	tb.Write([]byte("second\n"))
+	if b := string(tb.Bytes()); b != "st\nsecond\n" {
+		t.Fatalf("unexpected bytes: %q", b)
+	}
+	// The partial first line is dropped.
+	if s := tb.String(); s != "second\n" {
+		t.Fatalf("unexpected tail: %q", s)
+	}
+}
+
+func Test_TailBuffer_LargeWrite(t *testing.T) {
+	tb := NewTailBuffer(4)
+	n, err := tb.Write([]byte("0123456789"))
+	if n != 10 || err != nil {
+		t.Fatalf("unexpected write result: %d %v", n, err)
+	}
+	if b := string(tb.Bytes()); b != "6789" {
+		t.Fatalf("unexpected bytes: %q", b)
+	}
+}
+
+func Test_TailBuffer_ExactSize_NotTruncated(t *testing.T) {
+	tb := NewTailBuffer(6)
+	tb.Write([]byte("ab\ncd\n"))
+	if s := tb.String(); s != "ab\ncd\n" {
+		t.Fatalf("unexpected tail: %q", s)
+	}
+}
+
+func Test_TailBuffer_Concurrent(t *testing.T) {
+	tb := NewTailBuffer(100)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				tb.Write([]byte("0123456789"))
+			}
+		}()
+	}
+	wg.Wait()
+	if len(tb.Bytes()) != 100 {
+		t.Fatalf("expected 100 bytes, got: %d", len(tb.Bytes()))
+	}
+}
+
+func newPruneTestDir(t *testing.T, sizes ...int) string {
+	dir, err := ioutil.TempDir("", "crashdump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Entry `i` is older than entry `i+1`.
+	now := time.Now()
+	for i, size := range sizes {
+		path := filepath.Join(dir, string('a'+rune(i)))
+		if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mt := now.Add(time.Duration(i-len(sizes)) * time.Minute)
+		if err := os.Chtimes(path, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func Test_Prune_MaxCount(t *testing.T) {
+	dir := newPruneTestDir(t, 1, 1, 1, 1)
+	defer os.RemoveAll(dir)
+
+	removed, err := Prune(dir, Limits{MaxCount: 2})
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	expected := []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Fatalf("expected %v removed, got: %v", expected, removed)
+	}
+	for _, name := range []string{"c", "d"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected %s to be retained: %v", name, err)
+		}
+	}
+}
+
+func Test_Prune_MaxBytes(t *testing.T) {
+	dir := newPruneTestDir(t, 100, 50, 50)
+	defer os.RemoveAll(dir)
+
+	removed, err := Prune(dir, Limits{MaxBytes: 100})
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != filepath.Join(dir, "a") {
+		t.Fatalf("expected the oldest entry removed, got: %v", removed)
+	}
+}
+
+func Test_Prune_Directories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crashdump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	for i, name := range []string{"pod1", "pod2"} {
+		sub := filepath.Join(dir, name)
+		if err := os.Mkdir(sub, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(sub, "uvm.dmp"), make([]byte, 80), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mt := now.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(sub, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := Prune(dir, Limits{MaxBytes: 100})
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != filepath.Join(dir, "pod1") {
+		t.Fatalf("expected the oldest directory removed, got: %v", removed)
+	}
+}
+
+func Test_Prune_EmptyDirectories_Retained(t *testing.T) {
+	dir := newPruneTestDir(t, 1)
+	defer os.RemoveAll(dir)
+	// The directory of a running utility VM is older than the dump.
+	running := filepath.Join(dir, "0")
+	if err := os.Mkdir(running, 0755); err != nil {
+		t.Fatal(err)
+	}
+	mt := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(running, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := Prune(dir, Limits{MaxCount: 1})
+	if err != nil || len(removed) != 0 {
+		t.Fatalf("expected nothing removed, got: %v %v", removed, err)
+	}
+	if _, err := os.Stat(running); err != nil {
+		t.Fatalf("expected the empty directory to be retained: %v", err)
+	}
+}
+
+func Test_Prune_NoLimits(t *testing.T) {
+	dir := newPruneTestDir(t, 1, 1)
+	defer os.RemoveAll(dir)
+
+	removed, err := Prune(dir, Limits{})
+	if err != nil || len(removed) != 0 {
+		t.Fatalf("expected nothing removed, got: %v %v", removed, err)
+	}
+}
+
+func Test_Prune_NotExist(t *testing.T) {
+	removed, err := Prune(filepath.Join(os.TempDir(), "crashdump-does-not-exist"), Limits{MaxCount: 1})
+	if err != nil || len(removed) != 0 {
+		t.Fatalf("expected nothing removed, got: %v %v", removed, err)
+	}
+}
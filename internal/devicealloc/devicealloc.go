@@ -0,0 +1,437 @@
+// Package devicealloc tracks the device slots of a utility VM. A slot is a
+// location on a controller, such as a SCSI controller and LUN or a VPMem device
+// number, and is held by a ref-counted key, usually the host path of the
+// device.
+//
+// The package has no platform dependencies so that allocation can be tested
+// on any platform.
+package devicealloc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrNoAvailableSlot is returned when every slot of every controller is
+	// allocated.
+	ErrNoAvailableSlot = errors.New("no available slot")
+	// ErrNotAllocated is returned when a key has no slot.
+	ErrNotAllocated = errors.New("not allocated")
+	// ErrAlreadyAllocated is returned when a key or location is already
+	// allocated.
+	ErrAlreadyAllocated = errors.New("already allocated")
+)
+
+// Location is a slot on a controller.
+type Location struct {
+	Controller int `json:"Controller"`
+	Slot       int `json:"Slot"`
+}
+
+func (l Location) String() string {
+	return fmt.Sprintf("%d:%d", l.Controller, l.Slot)
+}
+
+// Entry is an allocated slot.
+type Entry struct {
+	// Key is the unique key holding the slot.
+	Key string
+	Location
+	// RefCount is the number of references to the slot. It is never `0`.
+	RefCount uint32
+	// Value is the caller data of the slot. It MUST marshal to JSON and MUST
+	// NOT be modified in place, use `Allocator.SetValue` instead.
+	Value interface{}
+}
+
+// Allocator allocates the slots of a fixed number of controllers. It is safe
+// for concurrent use.
+type Allocator struct {
+	m           sync.Mutex
+	controllers int
+	slots       int
+	newValue    func() interface{}
+	byKey       map[string]*Entry
+	byLocation  map[Location]*Entry
+}
+
+// New creates an `Allocator` of `controllers` controllers with `slots` slots
+// each. If `slots` is `0` the number of slots per controller is unlimited.
+//
+// `newValue` returns a pointer to decode the value of an entry into when
+// unmarshaling from JSON. If it is nil values are not decoded.
+func New(controllers, slots int, newValue func() interface{}) *Allocator {
+	return &Allocator{
+		controllers: controllers,
+		slots:       slots,
+		newValue:    newValue,
+		byKey:       make(map[string]*Entry),
+		byLocation:  make(map[Location]*Entry),
+	}
+}
+
+// Controllers returns the number of controllers of `a`.
+func (a *Allocator) Controllers() int {
+	a.m.Lock()
+	defer a.m.Unlock()
+	return a.controllers
+}
+
+// Slots returns the number of slots per controller of `a`, `0` if unlimited.
+func (a *Allocator) Slots() int {
+	a.m.Lock()
+	defer a.m.Unlock()
+	return a.slots
+}
+
+// Len returns the number of allocated slots.
+func (a *Allocator) Len() int {
+	a.m.Lock()
+	defer a.m.Unlock()
+	return len(a.byKey)
+}
+
+// Get returns the entry of `key`.
+func (a *Allocator) Get(key string) (Entry, bool) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	if e, ok := a.byKey[key]; ok {
+		return *e, true
+	}
+	return Entry{}, false
+}
+
+// At returns the entry allocated at `l`.
+func (a *Allocator) At(l Location) (Entry, bool) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	if e, ok := a.byLocation[l]; ok {
+		return *e, true
+	}
+	return Entry{}, false
+}
+
+// Entries returns all entries ordered by location.
+func (a *Allocator) Entries() []Entry {
+	a.m.Lock()
+	defer a.m.Unlock()
+	return a.entriesL()
+}
+
+func (a *Allocator) entriesL() []Entry {
+	entries := make([]Entry, 0, len(a.byKey))
+	for _, e := range a.byKey {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Controller != entries[j].Controller {
+			return entries[i].Controller < entries[j].Controller
+		}
+		return entries[i].Slot < entries[j].Slot
+	})
+	return entries
+}
+
+// Insert adds `e` at its location. This is used for devices that are
+// attached at a well known location, for example when the utility VM is
+// created.
+func (a *Allocator) Insert(e Entry) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+	return a.insertL(e)
+}
+
+func (a *Allocator) insertL(e Entry) error {
+	if e.Key == "" {
+		return errors.New("entry has no key")
+	}
+	if !a.validL(e.Location) {
+		return fmt.Errorf("location %s of '%s' is out of range", e.Location, e.Key)
+	}
+	if e.RefCount == 0 {
+		return fmt.Errorf("entry '%s' has no references", e.Key)
+	}
+	if _, ok := a.byKey[e.Key]; ok {
+		return ErrAlreadyAllocated
+	}
+	if _, ok := a.byLocation[e.Location]; ok {
+		return ErrAlreadyAllocated
+	}
+	a.addL(e)
+	return nil
+}
+
+func (a *Allocator) validL(l Location) bool {
+	return l.Controller >= 0 && l.Controller < a.controllers &&
+		l.Slot >= 0 && (a.slots == 0 || l.Slot < a.slots)
+}
+
+func (a *Allocator) addL(e Entry) {
+	pe := &e
+	a.byKey[e.Key] = pe
+	a.byLocation[e.Location] = pe
+}
+
+// Allocate allocates the first free slot to `key` with a reference count of
+// `1`. If `key` already has a slot `ErrAlreadyAllocated` is returned.
+func (a *Allocator) Allocate(key string, value interface{}) (Entry, error) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	return a.allocateL(key, value)
+}
+
+func (a *Allocator) allocateL(key string, value interface{}) (Entry, error) {
+	if _, ok := a.byKey[key]; ok {
+		return Entry{}, ErrAlreadyAllocated
+	}
+	l, ok := a.freeL()
+	if !ok {
+		return Entry{}, ErrNoAvailableSlot
+	}
+	e := Entry{Key: key, Location: l, RefCount: 1, Value: value}
+	a.addL(e)
+	return e, nil
+}
+
+// freeL returns the lowest free location.
+func (a *Allocator) freeL() (Location, bool) {
+	for c := 0; c < a.controllers; c++ {
+		for s := 0; a.slots == 0 || s < a.slots; s++ {
+			l := Location{Controller: c, Slot: s}
+			if _, ok := a.byLocation[l]; !ok {
+				return l, true
+			}
+		}
+	}
+	return Location{}, false
+}
+
+// Acquire adds a reference to the slot of `key`, allocating the first free slot
+// with `value` if `key` has none. `added` is true if a slot was allocated.
+func (a *Allocator) Acquire(key string, value interface{}) (_ Entry, added bool, _ error) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	return a.acquireL(key, value)
+}
+
+func (a *Allocator) acquireL(key string, value interface{}) (Entry, bool, error) {
+	if e, ok := a.byKey[key]; ok {
+		e.RefCount++
+		return *e, false, nil
+	}
+	e, err := a.allocateL(key, value)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	return e, true, nil
+}
+
+// SetValue replaces the value of the slot of `key`. This is used for values
+// that depend on the location of the slot.
+func (a *Allocator) SetValue(key string, value interface{}) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+	e, ok := a.byKey[key]
+	if !ok {
+		return ErrNotAllocated
+	}
+	e.Value = value
+	return nil
+}
+
+// Release removes a reference to the slot of `key`. If it is the last
+// reference the slot stays allocated and `last` is true, the caller MUST call
+// `Free` once the device is removed.
+func (a *Allocator) Release(key string) (_ Entry, last bool, _ error) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	e, ok := a.byKey[key]
+	if !ok {
+		return Entry{}, false, ErrNotAllocated
+	}
+	if e.RefCount == 1 {
+		return *e, true, nil
+	}
+	e.RefCount--
+	return *e, false, nil
+}
+
+// Free frees the slot of `key` regardless of its reference count.
+func (a *Allocator) Free(key string) (Entry, error) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	e, ok := a.byKey[key]
+	if !ok {
+		return Entry{}, ErrNotAllocated
+	}
+	a.removeL(e)
+	return *e, nil
+}
+
+func (a *Allocator) removeL(e *Entry) {
+	delete(a.byKey, e.Key)
+	delete(a.byLocation, e.Location)
+}
+
+// undoL removes a reference to the slot of `key`, freeing it on the last
+// reference.
+func (a *Allocator) undoL(key string) {
+	e, ok := a.byKey[key]
+	if !ok {
+		return
+	}
+	if e.RefCount > 1 {
+		e.RefCount--
+		return
+	}
+	a.removeL(e)
+}
+
+// Reservation is a set of allocations that are undone together unless they
+// are committed. It is used by operations that attach more than one device so
+// that a failure part way through does not leak slots.
+//
+// A typical use is:
+//
+//	r := a.Reserve()
+//	defer r.Rollback()
+//	... r.Allocate / r.Acquire and attach the devices ...
+//	r.Commit()
+type Reservation struct {
+	a    *Allocator
+	keys []string
+	done bool
+}
+
+// Reserve starts a new `Reservation` on `a`.
+func (a *Allocator) Reserve() *Reservation {
+	return &Reservation{a: a}
+}
+
+// Allocate is `Allocator.Allocate` that is undone by `Rollback`.
+func (r *Reservation) Allocate(key string, value interface{}) (Entry, error) {
+	if r.done {
+		return Entry{}, errors.New("reservation is already complete")
+	}
+	r.a.m.Lock()
+	defer r.a.m.Unlock()
+	e, err := r.a.allocateL(key, value)
+	if err != nil {
+		return Entry{}, err
+	}
+	r.keys = append(r.keys, key)
+	return e, nil
+}
+
+// Acquire is `Allocator.Acquire` that is undone by `Rollback`.
+func (r *Reservation) Acquire(key string, value interface{}) (_ Entry, added bool, _ error) {
+	if r.done {
+		return Entry{}, false, errors.New("reservation is already complete")
+	}
+	r.a.m.Lock()
+	defer r.a.m.Unlock()
+	e, added, err := r.a.acquireL(key, value)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	r.keys = append(r.keys, key)
+	return e, added, nil
+}
+
+// Commit keeps all allocations of `r`.
+func (r *Reservation) Commit() {
+	r.done = true
+	r.keys = nil
+}
+
+// Rollback undoes all allocations of `r` in reverse order. It is a no-op if
+// `r` is already committed or rolled back.
+func (r *Reservation) Rollback() {
+	if r.done {
+		return
+	}
+	r.done = true
+	r.a.m.Lock()
+	defer r.a.m.Unlock()
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		r.a.undoL(r.keys[i])
+	}
+	r.keys = nil
+}
+
+type allocatorJSON struct {
+	Controllers int         `json:"Controllers"`
+	Slots       int         `json:"Slots"`
+	Entries     []entryJSON `json:"Entries,omitempty"`
+}
+
+type entryJSON struct {
+	Key string `json:"Key"`
+	Location
+	RefCount uint32          `json:"RefCount"`
+	Value    json.RawMessage `json:"Value,omitempty"`
+}
+
+// MarshalJSON implements `json.Marshaler`.
+func (a *Allocator) MarshalJSON() ([]byte, error) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	aj := allocatorJSON{
+		Controllers: a.controllers,
+		Slots:       a.slots,
+	}
+	for _, e := range a.entriesL() {
+		ej := entryJSON{Key: e.Key, Location: e.Location, RefCount: e.RefCount}
+		if e.Value != nil {
+			b, err := json.Marshal(e.Value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal value of '%s': %s", e.Key, err)
+			}
+			ej.Value = b
+		}
+		aj.Entries = append(aj.Entries, ej)
+	}
+	return json.Marshal(aj)
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`. It replaces the controllers,
+// slots and entries of `a`. If the data is invalid `a` is not modified.
+func (a *Allocator) UnmarshalJSON(b []byte) error {
+	var aj allocatorJSON
+	if err := json.Unmarshal(b, &aj); err != nil {
+		return err
+	}
+	if aj.Controllers < 0 || aj.Slots < 0 {
+		return fmt.Errorf("invalid allocator size %d:%d", aj.Controllers, aj.Slots)
+	}
+
+	a.m.Lock()
+	defer a.m.Unlock()
+	n := &Allocator{
+		controllers: aj.Controllers,
+		slots:       aj.Slots,
+		byKey:       make(map[string]*Entry),
+		byLocation:  make(map[Location]*Entry),
+	}
+	for _, ej := range aj.Entries {
+		e := Entry{Key: ej.Key, Location: ej.Location, RefCount: ej.RefCount}
+		if a.newValue != nil && len(ej.Value) > 0 {
+			e.Value = a.newValue()
+			if err := json.Unmarshal(ej.Value, e.Value); err != nil {
+				return fmt.Errorf("failed to unmarshal value of '%s': %s", ej.Key, err)
+			}
+		}
+		if err := n.insertL(e); err != nil {
+			return err
+		}
+	}
+	a.controllers = n.controllers
+	a.slots = n.slots
+	a.byKey = n.byKey
+	a.byLocation = n.byLocation
+	return nil
+}
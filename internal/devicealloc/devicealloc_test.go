@@ -0,0 +1,404 @@
+package devicealloc
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type testValue struct {
+	Path string `json:"Path"`
+}
+
+func newTestValue() interface{} { return &testValue{} }
+
+func Test_Allocate_FillsControllersInOrder(t *testing.T) {
+	a := New(2, 3, nil)
+	for i := 0; i < 6; i++ {
+		e, err := a.Allocate(fmt.Sprintf("k%d", i), nil)
+		if err != nil {
+			t.Fatalf("allocation %d should not have failed, got: %v", i, err)
+		}
+		expected := Location{Controller: i / 3, Slot: i % 3}
+		if e.Location != expected {
+			t.Fatalf("allocation %d expected location %s, got: %s", i, expected, e.Location)
+		}
+		if e.RefCount != 1 {
+			t.Fatalf("allocation %d expected RefCount 1, got: %d", i, e.RefCount)
+		}
+	}
+	if _, err := a.Allocate("full", nil); err != ErrNoAvailableSlot {
+		t.Fatalf("expected ErrNoAvailableSlot, got: %v", err)
+	}
+	if a.Len() != 6 {
+		t.Fatalf("expected 6 entries, got: %d", a.Len())
+	}
+}
+
+func Test_Allocate_NoControllers_Error(t *testing.T) {
+	a := New(0, 64, nil)
+	if _, err := a.Allocate("k", nil); err != ErrNoAvailableSlot {
+		t.Fatalf("expected ErrNoAvailableSlot, got: %v", err)
+	}
+}
+
+func Test_Allocate_Unlimited(t *testing.T) {
+	a := New(1, 0, nil)
+	for i := 0; i < 1000; i++ {
+		e, err := a.Allocate(fmt.Sprintf("k%d", i), nil)
+		if err != nil {
+			t.Fatalf("allocation %d should not have failed, got: %v", i, err)
+		}
+		if e.Slot != i {
+			t.Fatalf("allocation %d expected slot %d, got: %d", i, i, e.Slot)
+		}
+	}
+}
+
+func Test_Allocate_Duplicate_Error(t *testing.T) {
+	a := New(1, 4, nil)
+	if _, err := a.Allocate("k", nil); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if _, err := a.Allocate("k", nil); err != ErrAlreadyAllocated {
+		t.Fatalf("expected ErrAlreadyAllocated, got: %v", err)
+	}
+	if e, _ := a.Get("k"); e.RefCount != 1 {
+		t.Fatalf("expected RefCount 1, got: %d", e.RefCount)
+	}
+}
+
+func Test_Allocate_ReusesLowestFreeSlot(t *testing.T) {
+	a := New(2, 2, nil)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if _, err := a.Allocate(k, nil); err != nil {
+			t.Fatalf("should not have failed, got: %v", err)
+		}
+	}
+	for _, k := range []string{"d", "b"} {
+		if _, err := a.Free(k); err != nil {
+			t.Fatalf("should not have failed, got: %v", err)
+		}
+	}
+	e, err := a.Allocate("e", nil)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if e.Location != (Location{0, 1}) {
+		t.Fatalf("expected location 0:1, got: %s", e.Location)
+	}
+	e, err = a.Allocate("f", nil)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if e.Location != (Location{1, 1}) {
+		t.Fatalf("expected location 1:1, got: %s", e.Location)
+	}
+}
+
+func Test_Acquire_RefCounts(t *testing.T) {
+	a := New(1, 4, nil)
+	v := &testValue{Path: "first"}
+	e, added, err := a.Acquire("k", v)
+	if err != nil || !added || e.RefCount != 1 || e.Value != v {
+		t.Fatalf("unexpected first acquire: %+v, %v, %v", e, added, err)
+	}
+	e, added, err = a.Acquire("k", &testValue{Path: "second"})
+	if err != nil || added || e.RefCount != 2 || e.Value != v {
+		t.Fatalf("unexpected second acquire: %+v, %v, %v", e, added, err)
+	}
+
+	e, last, err := a.Release("k")
+	if err != nil || last || e.RefCount != 1 {
+		t.Fatalf("unexpected first release: %+v, %v, %v", e, last, err)
+	}
+	e, last, err = a.Release("k")
+	if err != nil || !last || e.RefCount != 1 {
+		t.Fatalf("unexpected last release: %+v, %v, %v", e, last, err)
+	}
+	// The slot stays allocated until freed.
+	if _, ok := a.Get("k"); !ok {
+		t.Fatal("slot should still be allocated after the last release")
+	}
+	if _, err := a.Free("k"); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if _, ok := a.Get("k"); ok {
+		t.Fatal("slot should be freed")
+	}
+	if _, ok := a.At(Location{}); ok {
+		t.Fatal("location should be freed")
+	}
+}
+
+func Test_SetValue(t *testing.T) {
+	a := New(1, 4, nil)
+	e, err := a.Allocate("k", &testValue{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &testValue{Path: fmt.Sprintf("/p%d", e.Slot)}
+	if err := a.SetValue("k", v); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if e, _ := a.Get("k"); e.Value != v {
+		t.Fatalf("unexpected value: %+v", e.Value)
+	}
+	if err := a.SetValue("missing", v); err != ErrNotAllocated {
+		t.Fatalf("expected ErrNotAllocated, got: %v", err)
+	}
+}
+
+func Test_Release_NotAllocated_Error(t *testing.T) {
+	a := New(1, 4, nil)
+	if _, _, err := a.Release("k"); err != ErrNotAllocated {
+		t.Fatalf("expected ErrNotAllocated, got: %v", err)
+	}
+	if _, err := a.Free("k"); err != ErrNotAllocated {
+		t.Fatalf("expected ErrNotAllocated, got: %v", err)
+	}
+}
+
+func Test_Insert(t *testing.T) {
+	a := New(2, 4, nil)
+	if err := a.Insert(Entry{Key: "k", Location: Location{1, 3}, RefCount: 2}); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	e, ok := a.At(Location{1, 3})
+	if !ok || e.Key != "k" || e.RefCount != 2 {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+
+	tests := []struct {
+		name string
+		e    Entry
+	}{
+		{"NoKey", Entry{Location: Location{0, 0}, RefCount: 1}},
+		{"NoRefCount", Entry{Key: "n", Location: Location{0, 0}}},
+		{"ControllerOutOfRange", Entry{Key: "n", Location: Location{2, 0}, RefCount: 1}},
+		{"NegativeController", Entry{Key: "n", Location: Location{-1, 0}, RefCount: 1}},
+		{"SlotOutOfRange", Entry{Key: "n", Location: Location{0, 4}, RefCount: 1}},
+		{"NegativeSlot", Entry{Key: "n", Location: Location{0, -1}, RefCount: 1}},
+		{"DuplicateKey", Entry{Key: "k", Location: Location{0, 0}, RefCount: 1}},
+		{"DuplicateLocation", Entry{Key: "n", Location: Location{1, 3}, RefCount: 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := a.Insert(test.e); err == nil {
+				t.Fatal("should have failed")
+			}
+		})
+	}
+	if a.Len() != 1 {
+		t.Fatalf("expected 1 entry, got: %d", a.Len())
+	}
+}
+
+func Test_Entries_Sorted(t *testing.T) {
+	a := New(2, 2, nil)
+	for _, e := range []Entry{
+		{Key: "d", Location: Location{1, 1}, RefCount: 1},
+		{Key: "a", Location: Location{0, 0}, RefCount: 1},
+		{Key: "c", Location: Location{1, 0}, RefCount: 1},
+		{Key: "b", Location: Location{0, 1}, RefCount: 1},
+	} {
+		if err := a.Insert(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var keys []string
+	for _, e := range a.Entries() {
+		keys = append(keys, e.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b", "c", "d"}) {
+		t.Fatalf("unexpected order: %v", keys)
+	}
+}
+
+func Test_Reservation_Rollback(t *testing.T) {
+	a := New(1, 4, nil)
+	if _, err := a.Allocate("existing", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r := a.Reserve()
+	if _, err := r.Allocate("new1", nil); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if _, added, err := r.Acquire("existing", nil); err != nil || added {
+		t.Fatalf("unexpected acquire: %v, %v", added, err)
+	}
+	if _, added, err := r.Acquire("new2", nil); err != nil || !added {
+		t.Fatalf("unexpected acquire: %v, %v", added, err)
+	}
+	if a.Len() != 3 {
+		t.Fatalf("expected 3 entries during the reservation, got: %d", a.Len())
+	}
+	r.Rollback()
+
+	entries := a.Entries()
+	if len(entries) != 1 || entries[0].Key != "existing" || entries[0].RefCount != 1 {
+		t.Fatalf("unexpected entries after rollback: %+v", entries)
+	}
+	// A second rollback is a no-op.
+	r.Rollback()
+	if a.Len() != 1 {
+		t.Fatalf("expected 1 entry, got: %d", a.Len())
+	}
+	if _, err := r.Allocate("late", nil); err == nil {
+		t.Fatal("allocate on a completed reservation should have failed")
+	}
+}
+
+func Test_Reservation_Rollback_FailedAllocationNotUndone(t *testing.T) {
+	a := New(1, 1, nil)
+	r := a.Reserve()
+	if _, err := r.Allocate("k", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Allocate("k", nil); err != ErrAlreadyAllocated {
+		t.Fatalf("expected ErrAlreadyAllocated, got: %v", err)
+	}
+	if _, _, err := r.Acquire("full", nil); err != ErrNoAvailableSlot {
+		t.Fatalf("expected ErrNoAvailableSlot, got: %v", err)
+	}
+	r.Rollback()
+	if a.Len() != 0 {
+		t.Fatalf("expected no entries, got: %+v", a.Entries())
+	}
+}
+
+func Test_Reservation_Commit(t *testing.T) {
+	a := New(1, 4, nil)
+	r := a.Reserve()
+	if _, err := r.Allocate("a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := r.Acquire("a", nil); err != nil {
+		t.Fatal(err)
+	}
+	r.Commit()
+	r.Rollback()
+	e, ok := a.Get("a")
+	if !ok || e.RefCount != 2 {
+		t.Fatalf("unexpected entry after commit: %+v", e)
+	}
+}
+
+func Test_Reservation_Rollback_Concurrent(t *testing.T) {
+	a := New(4, 64, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := a.Reserve()
+			defer r.Rollback()
+			for j := 0; j < 8; j++ {
+				if _, err := r.Allocate(fmt.Sprintf("%d-%d", i, j), nil); err != nil {
+					t.Errorf("should not have failed, got: %v", err)
+					return
+				}
+			}
+			if _, _, err := r.Acquire("shared", nil); err != nil {
+				t.Errorf("should not have failed, got: %v", err)
+				return
+			}
+			if i%2 == 0 {
+				r.Commit()
+			}
+		}(i)
+	}
+	wg.Wait()
+	if a.Len() != 16*8+1 {
+		t.Fatalf("expected %d entries, got: %d", 16*8+1, a.Len())
+	}
+	if e, _ := a.Get("shared"); e.RefCount != 16 {
+		t.Fatalf("expected shared RefCount 16, got: %d", e.RefCount)
+	}
+}
+
+func Test_JSON_RoundTrip(t *testing.T) {
+	a := New(4, 64, newTestValue)
+	if _, err := a.Allocate("a", &testValue{Path: "/a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Insert(Entry{Key: "b", Location: Location{2, 7}, RefCount: 3, Value: &testValue{Path: "/b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Allocate("c", nil); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	r := New(0, 0, newTestValue)
+	if err := json.Unmarshal(b, r); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if r.Controllers() != 4 || r.Slots() != 64 {
+		t.Fatalf("unexpected size %d:%d", r.Controllers(), r.Slots())
+	}
+	if !reflect.DeepEqual(r.Entries(), a.Entries()) {
+		t.Fatalf("restored entries do not match:\n%+v\n%+v", r.Entries(), a.Entries())
+	}
+	// The restored allocator continues to allocate around the restored slots.
+	e, err := r.Allocate("d", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Location != (Location{0, 2}) {
+		t.Fatalf("expected location 0:2, got: %s", e.Location)
+	}
+}
+
+func Test_JSON_NoValueType_ValuesDropped(t *testing.T) {
+	a := New(1, 1, nil)
+	if _, err := a.Allocate("a", &testValue{Path: "/a"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(0, 0, nil)
+	if err := json.Unmarshal(b, r); err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := r.Get("a"); !ok || e.Value != nil {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+}
+
+func Test_JSON_Invalid_Unmodified(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"NotJSON", `{`},
+		{"NegativeSize", `{"Controllers":-1,"Slots":1}`},
+		{"OutOfRange", `{"Controllers":1,"Slots":1,"Entries":[{"Key":"a","Controller":0,"Slot":1,"RefCount":1}]}`},
+		{"NoRefCount", `{"Controllers":1,"Slots":1,"Entries":[{"Key":"a","Controller":0,"Slot":0}]}`},
+		{"DuplicateKey", `{"Controllers":1,"Slots":2,"Entries":[{"Key":"a","Controller":0,"Slot":0,"RefCount":1},{"Key":"a","Controller":0,"Slot":1,"RefCount":1}]}`},
+		{"DuplicateLocation", `{"Controllers":1,"Slots":2,"Entries":[{"Key":"a","Controller":0,"Slot":0,"RefCount":1},{"Key":"b","Controller":0,"Slot":0,"RefCount":1}]}`},
+		{"BadValue", `{"Controllers":1,"Slots":1,"Entries":[{"Key":"a","Controller":0,"Slot":0,"RefCount":1,"Value":1}]}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := New(1, 4, newTestValue)
+			if _, err := a.Allocate("existing", nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.data), a); err == nil {
+				t.Fatal("should have failed")
+			}
+			if a.Controllers() != 1 || a.Slots() != 4 || a.Len() != 1 {
+				t.Fatalf("allocator was modified: %+v", a.Entries())
+			}
+		})
+	}
+}
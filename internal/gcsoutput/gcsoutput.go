@@ -0,0 +1,305 @@
+// Package gcsoutput implements handlers for the output that a Linux utility VM
+// forwards to the host, which is usually the log of the GCS.
+//
+// Handlers are selected by name with `Parse`. The package has no platform
+// dependencies so that the handlers can be tested with canned guest output.
+package gcsoutput
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/sirupsen/logrus"
+)
+
+// maxLineSize is the length at which a line of output that has no newline yet
+// is handled as a line of its own.
+const maxLineSize = 64 * 1024
+
+// Handler processes the output of a utility VM until it ends. It has the
+// signature of `uvm.OutputHandler`.
+type Handler func(r io.Reader)
+
+// Config is the configuration of the handlers that log the output.
+type Config struct {
+	// VMID is the ID of the utility VM that is added to every entry.
+	VMID string
+	// Logger is the logger entries are logged to. Defaults to the standard
+	// logger.
+	Logger *logrus.Logger
+	// Levels remaps the level of the guest entries. A level that is not in
+	// `Levels` is kept. Entries are never logged above the error level.
+	Levels map[logrus.Level]logrus.Level
+	// DropFields are the fields removed from the guest entries.
+	DropFields []string
+	// IsClosed returns `true` if a read error means that the guest closed the
+	// output. These errors are not logged. May be `nil`.
+	IsClosed func(err error) bool
+	// FileRoot is the host directory the paths of the `file:` handlers of
+	// `Parse` are relative to. A path may not leave `FileRoot`. If empty the
+	// `file:` handler is not allowed.
+	FileRoot string
+}
+
+func (c *Config) logger() *logrus.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return logrus.StandardLogger()
+}
+
+type entryStandard struct {
+	Time    time.Time    `json:"time"`
+	Level   logrus.Level `json:"level"`
+	Message string       `json:"msg"`
+}
+
+// entry is a logrus entry encoded by the JSON formatter of the guest.
+type entry struct {
+	entryStandard
+	Fields map[string]interface{}
+}
+
+// FUTURE-jstarks: Change the GCS log format to include type information (e.g.
+// by using a different encoding such as protobuf).
+func (e *entry) UnmarshalJSON(b []byte) error {
+	// Default the log level to info.
+	e.Level = logrus.InfoLevel
+	if err := json.Unmarshal(b, &e.entryStandard); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &e.Fields); err != nil {
+		return err
+	}
+	// Clear special fields.
+	delete(e.Fields, "time")
+	delete(e.Fields, "level")
+	delete(e.Fields, "msg")
+	// Normalize floats to integers.
+	for k, v := range e.Fields {
+		if d, ok := v.(float64); ok && float64(int64(d)) == d {
+			e.Fields[k] = int64(d)
+		}
+	}
+	return nil
+}
+
+// JSON returns a `Handler` that parses the output as newline delimited logrus
+// JSON entries and logs them. A line that is not a JSON entry, such as a
+// kernel message or a panic trace, is logged as text at the error level and
+// parsing resumes at the next line.
+func JSON(c Config) Handler {
+	return func(r io.Reader) {
+		br := bufio.NewReaderSize(r, maxLineSize)
+		for {
+			line, err := br.ReadSlice('\n')
+			if err == bufio.ErrBufferFull {
+				err = nil
+			}
+			c.logLine(line)
+			if err != nil {
+				if err != io.EOF && (c.IsClosed == nil || !c.IsClosed(err)) {
+					c.logger().WithFields(logrus.Fields{
+						logfields.UVMID: c.VMID,
+						logrus.ErrorKey: err,
+					}).Error("gcs log read")
+				}
+				return
+			}
+		}
+	}
+}
+
+// logLine logs a line of output as a guest entry if it is one and otherwise
+// as text.
+func (c *Config) logLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	if line[0] == '{' {
+		var e entry
+		if err := json.Unmarshal(line, &e); err == nil {
+			fields := logrus.Fields(e.Fields)
+			if fields == nil {
+				fields = make(logrus.Fields)
+			}
+			for _, f := range c.DropFields {
+				delete(fields, f)
+			}
+			fields[logfields.UVMID] = c.VMID
+			fields[logfields.VMTime] = e.Time
+			level := e.Level
+			if l, ok := c.Levels[level]; ok {
+				level = l
+			}
+			// Do not allow fatal or panic level errors to propagate.
+			if level < logrus.ErrorLevel {
+				level = logrus.ErrorLevel
+			}
+			c.logger().WithFields(fields).Log(level, e.Message)
+			return
+		}
+	}
+	c.logger().WithFields(logrus.Fields{
+		logfields.UVMID: c.VMID,
+		"stderr":        string(line),
+	}).Error("gcs output")
+}
+
+// Raw returns a `Handler` that copies the output unmodified to `w`.
+func Raw(w io.Writer) Handler {
+	return func(r io.Reader) {
+		io.Copy(w, r)
+	}
+}
+
+// RawFile returns a `Handler` that appends the output unmodified to the file
+// at `path`. The file is opened when the output starts. If it cannot be opened
+// the output is discarded.
+func RawFile(path string) Handler {
+	return func(r io.Reader) {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"path":          path,
+				logrus.ErrorKey: err,
+			}).Error("failed to open gcs output file")
+			return
+		}
+		defer f.Close()
+		io.Copy(f, r)
+	}
+}
+
+// Tee returns a `Handler` that passes the output to every handler in `hs`. The
+// output is read at the pace of the slowest handler. The output a handler does
+// not read before it returns is discarded.
+func Tee(hs ...Handler) Handler {
+	return func(r io.Reader) {
+		var wg sync.WaitGroup
+		ws := make([]io.Writer, len(hs))
+		pws := make([]*io.PipeWriter, len(hs))
+		for i, h := range hs {
+			pr, pw := io.Pipe()
+			ws[i], pws[i] = pw, pw
+			wg.Add(1)
+			go func(h Handler) {
+				defer wg.Done()
+				h(pr)
+				io.Copy(ioutil.Discard, pr)
+			}(h)
+		}
+		_, err := io.Copy(io.MultiWriter(ws...), r)
+		for _, pw := range pws {
+			pw.CloseWithError(err)
+		}
+		wg.Wait()
+	}
+}
+
+// Parse returns the handler described by `spec`. `spec` is a comma separated
+// list of handlers that are combined with `Tee`:
+//
+//	json         - `JSON` with `c`. This is the default if `spec` is empty.
+//	stdout       - `Raw` to standard output.
+//	file:<path>  - `RawFile` to `path` in `c.FileRoot`.
+func Parse(spec string, c Config) (Handler, error) {
+	if spec == "" {
+		return JSON(c), nil
+	}
+	var hs []Handler
+	for _, s := range strings.Split(spec, ",") {
+		name, arg := s, ""
+		if i := strings.Index(s, ":"); i >= 0 {
+			name, arg = s[:i], s[i+1:]
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "json":
+			hs = append(hs, JSON(c))
+		case "stdout":
+			hs = append(hs, Raw(os.Stdout))
+		case "file":
+			path, err := c.filePath(arg)
+			if err != nil {
+				return nil, fmt.Errorf("output handler '%s': %s", s, err)
+			}
+			hs = append(hs, RawFile(path))
+		default:
+			return nil, fmt.Errorf("unknown output handler '%s'", s)
+		}
+	}
+	if len(hs) == 1 {
+		return hs[0], nil
+	}
+	return Tee(hs...), nil
+}
+
+// filePath returns the host path of the `file:` handler path `p`. The spec may
+// come from an untrusted source, such as a pod annotation, so `p` is confined
+// to `c.FileRoot`.
+func (c *Config) filePath(p string) (string, error) {
+	if c.FileRoot == "" {
+		return "", errors.New("file output is not enabled")
+	}
+	if p == "" {
+		return "", errors.New("a path is required")
+	}
+	if filepath.IsAbs(p) || filepath.VolumeName(p) != "" {
+		return "", fmt.Errorf("path '%s' must be relative", p)
+	}
+	path := filepath.Join(c.FileRoot, p)
+	rel, err := filepath.Rel(c.FileRoot, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path '%s' leaves the output directory", p)
+	}
+	return path, nil
+}
+
+// ParseLevels parses a comma separated list of `from=to` level remappings for
+// `Config.Levels`, such as "debug=info,warning=error".
+func ParseLevels(s string) (map[logrus.Level]logrus.Level, error) {
+	levels := make(map[logrus.Level]logrus.Level)
+	if s == "" {
+		return levels, nil
+	}
+	for _, m := range strings.Split(s, ",") {
+		parts := strings.SplitN(m, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("level mapping '%s' is not of the form from=to", m)
+		}
+		from, err := logrus.ParseLevel(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, err
+		}
+		to, err := logrus.ParseLevel(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+		levels[from] = to
+	}
+	return levels, nil
+}
+
+// ParseFields parses a comma separated list of field names for
+// `Config.DropFields`.
+func ParseFields(s string) []string {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,222 @@
+package gcsoutput
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/sirupsen/logrus"
+)
+
+// capture is a `logrus.Hook` that records every entry.
+type capture struct {
+	m       sync.Mutex
+	entries []*logrus.Entry
+}
+
+func (c *capture) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (c *capture) Fire(e *logrus.Entry) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.entries = append(c.entries, e)
+	return nil
+}
+
+func newTestConfig() (Config, *capture) {
+	l := logrus.New()
+	l.Out = ioutil.Discard
+	l.Level = logrus.TraceLevel
+	c := &capture{}
+	l.AddHook(c)
+	return Config{VMID: "uvm", Logger: l}, c
+}
+
+// guestOutput is a canned GCS log with a kernel message and a panic trace in
+// the middle of it.
+const guestOutput = `{"level":"info","msg":"starting","time":"2020-01-02T03:04:05Z","pid":1}
+[    1.234] random: crng init done
+{"level":"debug","msg":"request","time":"2020-01-02T03:04:06Z","secret":"x","cid":"c1"}
+panic: runtime error: invalid memory address
+goroutine 1 [running]:
+{"level":"fatal","msg":"exiting","time":"2020-01-02T03:04:07Z"}
+{"level":"info","msg":"trunc`
+
+func Test_JSON_TextFallback_Resynchronizes(t *testing.T) {
+	c, entries := newTestConfig()
+	JSON(c)(strings.NewReader(guestOutput))
+
+	type expected struct {
+		level logrus.Level
+		msg   string
+		text  string
+	}
+	exp := []expected{
+		{logrus.InfoLevel, "starting", ""},
+		{logrus.ErrorLevel, "gcs output", "[    1.234] random: crng init done"},
+		{logrus.DebugLevel, "request", ""},
+		{logrus.ErrorLevel, "gcs output", "panic: runtime error: invalid memory address"},
+		{logrus.ErrorLevel, "gcs output", "goroutine 1 [running]:"},
+		{logrus.ErrorLevel, "exiting", ""},
+		{logrus.ErrorLevel, "gcs output", `{"level":"info","msg":"trunc`},
+	}
+	if len(entries.entries) != len(exp) {
+		t.Fatalf("expected %d entries, got: %d", len(exp), len(entries.entries))
+	}
+	for i, e := range entries.entries {
+		if e.Level != exp[i].level || e.Message != exp[i].msg {
+			t.Errorf("entry %d: expected %s '%s', got: %s '%s'", i, exp[i].level, exp[i].msg, e.Level, e.Message)
+		}
+		if e.Data[logfields.UVMID] != "uvm" {
+			t.Errorf("entry %d: expected the utility VM ID, got: %v", i, e.Data)
+		}
+		if exp[i].text != "" && e.Data["stderr"] != exp[i].text {
+			t.Errorf("entry %d: expected text '%s', got: %v", i, exp[i].text, e.Data["stderr"])
+		}
+	}
+	if pid := entries.entries[0].Data["pid"]; pid != int64(1) {
+		t.Fatalf("expected the pid field as an integer, got: %#v", pid)
+	}
+}
+
+func Test_JSON_LevelsAndDropFields(t *testing.T) {
+	c, entries := newTestConfig()
+	c.Levels = map[logrus.Level]logrus.Level{
+		logrus.DebugLevel: logrus.InfoLevel,
+		logrus.InfoLevel:  logrus.PanicLevel,
+	}
+	c.DropFields = []string{"secret"}
+	JSON(c)(strings.NewReader(guestOutput))
+
+	if e := entries.entries[0]; e.Level != logrus.ErrorLevel {
+		t.Fatalf("expected remapping above error to be clamped, got: %s", e.Level)
+	}
+	e := entries.entries[2]
+	if e.Level != logrus.InfoLevel {
+		t.Fatalf("expected debug to be remapped to info, got: %s", e.Level)
+	}
+	if _, ok := e.Data["secret"]; ok {
+		t.Fatalf("expected the field to be dropped, got: %v", e.Data)
+	}
+	if e.Data["cid"] != "c1" {
+		t.Fatalf("expected other fields to be kept, got: %v", e.Data)
+	}
+}
+
+func Test_JSON_LongLine_Split(t *testing.T) {
+	c, entries := newTestConfig()
+	JSON(c)(strings.NewReader(strings.Repeat("a", maxLineSize+10) + "\n"))
+	if len(entries.entries) != 2 {
+		t.Fatalf("expected the line to be split in 2 entries, got: %d", len(entries.entries))
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func Test_JSON_ReadError(t *testing.T) {
+	closed := errors.New("closed")
+	failed := errors.New("failed")
+	c, entries := newTestConfig()
+	c.IsClosed = func(err error) bool { return err == closed }
+
+	JSON(c)(&errReader{closed})
+	if len(entries.entries) != 0 {
+		t.Fatalf("expected a closed error not to be logged, got: %d entries", len(entries.entries))
+	}
+	JSON(c)(&errReader{failed})
+	if len(entries.entries) != 1 || entries.entries[0].Data[logrus.ErrorKey] != failed {
+		t.Fatalf("expected the read error to be logged, got: %d entries", len(entries.entries))
+	}
+}
+
+func Test_RawFile_Appends(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gcs.log")
+
+	RawFile(path)(strings.NewReader("one\n"))
+	RawFile(path)(strings.NewReader("two\n"))
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "one\ntwo\n" {
+		t.Fatalf("expected the output appended, got: '%s'", b)
+	}
+}
+
+func Test_Tee_AllHandlersSeeOutput(t *testing.T) {
+	var a, b bytes.Buffer
+	c, entries := newTestConfig()
+	// A handler that returns without reading does not block the others.
+	quit := Handler(func(io.Reader) {})
+	Tee(Raw(&a), quit, JSON(c), Raw(&b))(strings.NewReader(guestOutput))
+
+	if a.String() != guestOutput || b.String() != guestOutput {
+		t.Fatalf("expected the raw handlers to see all output, got: '%s' '%s'", a.String(), b.String())
+	}
+	if len(entries.entries) != 7 {
+		t.Fatalf("expected 7 entries, got: %d", len(entries.entries))
+	}
+}
+
+func Test_Parse(t *testing.T) {
+	c, _ := newTestConfig()
+	c.FileRoot = "logs"
+	for _, spec := range []string{"", "json", "JSON", "stdout", "file:gcs.log", "file:vm/gcs.log", "json,stdout"} {
+		if h, err := Parse(spec, c); err != nil || h == nil {
+			t.Errorf("expected '%s' to parse, got: %v", spec, err)
+		}
+	}
+	for _, spec := range []string{"xml", "file", "file:", "json,", "file:../gcs.log", "file:vm/../../gcs.log", "file:" + filepath.Join(string(filepath.Separator), "gcs.log")} {
+		if _, err := Parse(spec, c); err == nil {
+			t.Errorf("expected '%s' to fail", spec)
+		}
+	}
+}
+
+func Test_Parse_File_NoRoot(t *testing.T) {
+	c, _ := newTestConfig()
+	if _, err := Parse("file:gcs.log", c); err == nil {
+		t.Fatal("expected file output without a root to fail")
+	}
+}
+
+func Test_ParseLevels(t *testing.T) {
+	levels, err := ParseLevels("debug=info, warning = error")
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if len(levels) != 2 || levels[logrus.DebugLevel] != logrus.InfoLevel || levels[logrus.WarnLevel] != logrus.ErrorLevel {
+		t.Fatalf("unexpected levels: %v", levels)
+	}
+	for _, s := range []string{"debug", "debug=loud", "x=info"} {
+		if _, err := ParseLevels(s); err == nil {
+			t.Errorf("expected '%s' to fail", s)
+		}
+	}
+}
+
+func Test_ParseFields(t *testing.T) {
+	if f := ParseFields(" a,,b "); len(f) != 2 || f[0] != "a" || f[1] != "b" {
+		t.Fatalf("unexpected fields: %v", f)
+	}
+}
@@ -0,0 +1,97 @@
+package gcsoutput
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// Relay passes the output of a utility VM to a handler that can be replaced
+// while the output is running. The guest connects its output only once, so a
+// process that hands a running utility VM to another process uses a `Relay` to
+// pass the rest of the output on instead of dropping it.
+//
+// The output is passed on line by line so that a line never straddles two
+// handlers.
+type Relay struct {
+	m     sync.Mutex
+	pw    *io.PipeWriter
+	hd    chan struct{}
+	ended bool
+	done  chan struct{}
+}
+
+// NewRelay returns a `Relay` that passes the output to `h` until `Switch` is
+// called.
+func NewRelay(h Handler) *Relay {
+	r := &Relay{done: make(chan struct{})}
+	r.pw, r.hd = startHandler(h)
+	return r
+}
+
+// startHandler runs `h` on a new goroutine reading from the returned pipe. The
+// returned channel is closed once `h` returned. Output `h` does not read is
+// discarded so that the relay never blocks on a handler that gave up.
+func startHandler(h Handler) (*io.PipeWriter, chan struct{}) {
+	pr, pw := io.Pipe()
+	hd := make(chan struct{})
+	go func() {
+		defer close(hd)
+		h(pr)
+		io.Copy(ioutil.Discard, pr)
+	}()
+	return pw, hd
+}
+
+// Handle is the `Handler` of the utility VM output. It returns once the output
+// ended and the current handler returned.
+func (r *Relay) Handle(out io.Reader) {
+	defer close(r.done)
+
+	br := bufio.NewReaderSize(out, maxLineSize)
+	var err error
+	for err == nil {
+		var line []byte
+		line, err = br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			err = nil
+		}
+		if len(line) > 0 {
+			r.m.Lock()
+			r.pw.Write(line)
+			r.m.Unlock()
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	r.m.Lock()
+	r.ended = true
+	r.pw.CloseWithError(err)
+	hd := r.hd
+	r.m.Unlock()
+	<-hd
+}
+
+// Switch passes the output from the next line on to `h`. The previous handler
+// sees the end of the output and `Switch` waits for it to return. If the
+// output already ended `h` is not called.
+func (r *Relay) Switch(h Handler) {
+	r.m.Lock()
+	if r.ended {
+		r.m.Unlock()
+		return
+	}
+	r.pw.Close()
+	hd := r.hd
+	r.pw, r.hd = startHandler(h)
+	r.m.Unlock()
+	<-hd
+}
+
+// Done returns a channel that is closed once the output ended and the current
+// handler returned.
+func (r *Relay) Done() <-chan struct{} {
+	return r.done
+}
@@ -0,0 +1,90 @@
+package gcsoutput
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a `bytes.Buffer` that is safe for concurrent use.
+type syncBuffer struct {
+	m sync.Mutex
+	b bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.m.Lock()
+	defer sb.m.Unlock()
+	return sb.b.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.m.Lock()
+	defer sb.m.Unlock()
+	return sb.b.String()
+}
+
+func waitRelayDone(t *testing.T, r *Relay) {
+	select {
+	case <-r.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for relay to end")
+	}
+}
+
+func Test_Relay_Switch_PassesLaterLines(t *testing.T) {
+	var first, second syncBuffer
+	r := NewRelay(Raw(&first))
+	pr, pw := io.Pipe()
+	go r.Handle(pr)
+
+	pw.Write([]byte("one\n"))
+	deadline := time.Now().Add(10 * time.Second)
+	for first.String() != "one\n" {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected first handler output: 'one\\n', got: '%s'", first.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// A partial line is held back until it is complete so that it is passed
+	// on as a whole to the handler that is current then.
+	pw.Write([]byte("tw"))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		pw.Write([]byte("o\n"))
+		pw.Close()
+	}()
+	r.Switch(Raw(&second))
+	waitRelayDone(t, r)
+
+	if first.String() != "one\n" {
+		t.Fatalf("expected first handler output: 'one\\n', got: '%s'", first.String())
+	}
+	if second.String() != "two\n" {
+		t.Fatalf("expected second handler output: 'two\\n', got: '%s'", second.String())
+	}
+}
+
+func Test_Relay_Switch_AfterEnd_NotCalled(t *testing.T) {
+	var first syncBuffer
+	r := NewRelay(Raw(&first))
+	r.Handle(strings.NewReader("one\n"))
+
+	called := false
+	r.Switch(func(io.Reader) { called = true })
+	if called {
+		t.Fatal("expected handler not to be called after the output ended")
+	}
+	if first.String() != "one\n" {
+		t.Fatalf("expected first handler output: 'one\\n', got: '%s'", first.String())
+	}
+}
+
+func Test_Relay_HandlerReturnsEarly_DoesNotBlock(t *testing.T) {
+	r := NewRelay(func(io.Reader) {})
+	go r.Handle(strings.NewReader(strings.Repeat("line\n", 10000)))
+	waitRelayDone(t, r)
+}
@@ -45,8 +45,17 @@ type LCOWMappedDirectory struct {
 
 // Read-only layers over VPMem
 type LCOWMappedVPMemDevice struct {
-	DeviceNumber uint32 `json:"DeviceNumber,omitempty"`
-	MountPath    string `json:"MountPath,omitempty"` // /tmp/pN
+	DeviceNumber uint32                `json:"DeviceNumber,omitempty"`
+	MountPath    string                `json:"MountPath,omitempty"` // /tmp/pN
+	MappingInfo  *LCOWVPMemMappingInfo `json:"MappingInfo,omitempty"`
+}
+
+// LCOWVPMemMappingInfo describes a layer mapped at an offset of a VPMem device
+// shared by several layers. The guest exposes it with a device-mapper linear
+// target before mounting it.
+type LCOWVPMemMappingInfo struct {
+	DeviceOffsetInBytes uint64 `json:"DeviceOffsetInBytes,omitempty"`
+	DeviceSizeInBytes   uint64 `json:"DeviceSizeInBytes,omitempty"`
 }
 
 type LCOWNetworkAdapter struct {
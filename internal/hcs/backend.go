@@ -0,0 +1,55 @@
+package hcs
+
+import (
+	"context"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+)
+
+// Backend returns the `cow.Backend` that creates and opens compute systems
+// through HCS.
+func Backend() cow.Backend {
+	return hcsBackend{}
+}
+
+type hcsBackend struct{}
+
+func (hcsBackend) CreateComputeSystem(ctx context.Context, id string, document interface{}) (cow.ComputeSystem, error) {
+	system, err := CreateComputeSystem(ctx, id, document)
+	if err != nil {
+		return nil, err
+	}
+	return computeSystem{system}, nil
+}
+
+func (hcsBackend) OpenComputeSystem(id string) (cow.ComputeSystem, error) {
+	system, err := OpenComputeSystem(id)
+	if err != nil {
+		return nil, err
+	}
+	return computeSystem{system}, nil
+}
+
+var _ = (cow.Process)(&Process{})
+
+// computeSystem adapts `*System` to `cow.ComputeSystem` by returning the
+// processes it creates and opens as `cow.Process`.
+type computeSystem struct {
+	*System
+}
+
+func (cs computeSystem) CreateProcess(c interface{}) (cow.Process, error) {
+	p, err := cs.System.CreateProcess(c)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (cs computeSystem) OpenProcess(pid int) (cow.Process, error) {
+	p, err := cs.System.OpenProcess(pid)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sync"
 	"syscall"
+	"unsafe"
 
 	"github.com/Microsoft/hcsshim/internal/interop"
 	"github.com/Microsoft/hcsshim/internal/logfields"
@@ -92,6 +93,14 @@ type notifcationWatcherContext struct {
 
 	systemID  string
 	processID int
+
+	// m protects `exitData` and `crashed` which are recorded from system
+	// notifications for use once the system has exited.
+	m sync.Mutex
+	// exitData is the data of the `hcsNotificationSystemExited` notification.
+	exitData string
+	// crashed is `true` if the guest reported a crash.
+	crashed bool
 }
 
 type notificationChannels map[hcsNotification]notificationChannel
@@ -150,7 +159,24 @@ func notificationWatcher(notificationType hcsNotification, callbackNumber uintpt
 	// This means that as it grows we don't have issues associated with new
 	// notification types the code didn't know about.
 	switch notificationType {
-	case hcsNotificationSystemExited, hcsNotificationSystemCreateCompleted, hcsNotificationSystemStartCompleted, hcsNotificationSystemPauseCompleted, hcsNotificationSystemResumeCompleted:
+	case hcsNotificationSystemCrashInitiated, hcsNotificationSystemCrashReport:
+		// There is no waiter for a crash. Record it to explain the exit that
+		// follows.
+		context.m.Lock()
+		context.crashed = true
+		context.m.Unlock()
+		return 0
+	case hcsNotificationSystemExited:
+		if notificationData != nil {
+			// The data is owned by HCS and only valid for the duration of
+			// the callback. Copy it but do not free it.
+			data := syscall.UTF16ToString((*[1 << 29]uint16)(unsafe.Pointer(notificationData))[:])
+			log.WithField(logfields.JSON, data).Debug("System exit data")
+			context.m.Lock()
+			context.exitData = data
+			context.m.Unlock()
+		}
+	case hcsNotificationSystemCreateCompleted, hcsNotificationSystemStartCompleted, hcsNotificationSystemPauseCompleted, hcsNotificationSystemResumeCompleted:
 	case hcsNotificationProcessExited:
 	default:
 		return 0
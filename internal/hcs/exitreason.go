@@ -0,0 +1,74 @@
+package hcs
+
+import (
+	"encoding/json"
+	"syscall"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/interop"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
+	"github.com/sirupsen/logrus"
+)
+
+// Win32 errors in the exit status of a compute system that identify the reason
+// it exited.
+var (
+	errNotEnoughMemory    = syscall.Errno(8)    // ERROR_NOT_ENOUGH_MEMORY
+	errOutOfMemory        = syscall.Errno(14)   // ERROR_OUTOFMEMORY
+	errShutdownInProgress = syscall.Errno(1115) // ERROR_SHUTDOWN_IN_PROGRESS
+	errCommitmentLimit    = syscall.Errno(1455) // ERROR_COMMITMENT_LIMIT
+)
+
+// systemExitData is the data of the `hcsNotificationSystemExited`
+// notification. HCS may include the error events that led to the exit.
+type systemExitData struct {
+	hcsschema.SystemExitStatus
+	ErrorEvents []ErrorEvent `json:"ErrorEvents,omitempty"`
+}
+
+// systemExitReason classifies the exit of a compute system from `status`, the
+// status of the exit notification, `data`, the exit notification data (if
+// any), and `crashed`, whether the guest reported a crash before it exited.
+//
+// Returns the reason and any error events included in `data`.
+func systemExitReason(status error, data string, crashed bool) (cow.ExitReason, []ErrorEvent) {
+	if status == ErrHandleClose {
+		// The handle was closed before the system exited. There is no exit to
+		// explain.
+		return cow.ExitReasonNone, nil
+	}
+
+	var exit systemExitData
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &exit); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"data":          data,
+				logrus.ErrorKey: err,
+			}).Warning("Could not unmarshal system exit data")
+		} else if exit.Status != 0 {
+			status = interop.Win32FromHresult(uintptr(uint32(exit.Status)))
+		}
+	}
+
+	switch status {
+	case errNotEnoughMemory, errOutOfMemory, errCommitmentLimit:
+		return cow.ExitReasonOutOfMemory, exit.ErrorEvents
+	case errShutdownInProgress:
+		return cow.ExitReasonHostShutdown, exit.ErrorEvents
+	}
+	if crashed {
+		return cow.ExitReasonGuestCrash, exit.ErrorEvents
+	}
+	switch exit.ExitType {
+	case "GracefulExit":
+		return cow.ExitReasonNone, exit.ErrorEvents
+	case "ForcedExit":
+		return cow.ExitReasonTerminated, exit.ErrorEvents
+	case "UnexpectedExit":
+		return cow.ExitReasonUnexpected, exit.ErrorEvents
+	}
+	if status != nil {
+		return cow.ExitReasonUnexpected, exit.ErrorEvents
+	}
+	return cow.ExitReasonNone, exit.ErrorEvents
+}
@@ -1,6 +1,7 @@
 package hcs
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"sync"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/Microsoft/hcsshim/internal/interop"
 	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/trace"
 	"github.com/sirupsen/logrus"
 )
 
@@ -110,6 +112,15 @@ func (process *Process) logOperationEnd(operation string, err error) {
 		err)
 }
 
+// startSpan starts a span for the operation `op` on the process. The Process
+// methods do not take a context so the span has no parent.
+func (process *Process) startSpan(op string) *trace.Span {
+	_, span := trace.StartSpan(context.Background(), "hcs::Process::"+op,
+		trace.StringAttribute(logfields.ContainerID, process.SystemID()),
+		trace.Int64Attribute(logfields.ProcessID, int64(process.processID)))
+	return span
+}
+
 // Signal signals the process with `options`.
 //
 // For LCOW `guestrequest.SignalProcessOptionsLCOW`.
@@ -122,6 +133,8 @@ func (process *Process) Signal(options interface{}) (err error) {
 	operation := "hcsshim::Process::Signal"
 	process.logOperationBegin(operation)
 	defer func() { process.logOperationEnd(operation, err) }()
+	span := process.startSpan("Signal")
+	defer func() { span.SetError(err); span.End() }()
 
 	if process.handle == 0 {
 		return makeProcessError(process, operation, ErrAlreadyClosed, nil)
@@ -154,6 +167,8 @@ func (process *Process) Kill() (err error) {
 	operation := "hcsshim::Process::Kill"
 	process.logOperationBegin(operation)
 	defer func() { process.logOperationEnd(operation, err) }()
+	span := process.startSpan("Kill")
+	defer func() { span.SetError(err); span.End() }()
 
 	if process.handle == 0 {
 		return makeProcessError(process, operation, ErrAlreadyClosed, nil)
@@ -224,6 +239,8 @@ func (process *Process) ResizeConsole(width, height uint16) (err error) {
 	operation := "hcsshim::Process::ResizeConsole"
 	process.logOperationBegin(operation)
 	defer func() { process.logOperationEnd(operation, err) }()
+	span := process.startSpan("ResizeConsole")
+	defer func() { span.SetError(err); span.End() }()
 
 	if process.handle == 0 {
 		return makeProcessError(process, operation, ErrAlreadyClosed, nil)
@@ -261,6 +278,8 @@ func (process *Process) Properties() (_ *ProcessStatus, err error) {
 	operation := "hcsshim::Process::Properties"
 	process.logOperationBegin(operation)
 	defer func() { process.logOperationEnd(operation, err) }()
+	span := process.startSpan("Properties")
+	defer func() { span.SetError(err); span.End() }()
 
 	if process.handle == 0 {
 		return nil, makeProcessError(process, operation, ErrAlreadyClosed, nil)
@@ -373,6 +392,8 @@ func (process *Process) CloseStdin() (err error) {
 	operation := "hcsshim::Process::CloseStdin"
 	process.logOperationBegin(operation)
 	defer func() { process.logOperationEnd(operation, err) }()
+	span := process.startSpan("CloseStdin")
+	defer func() { span.SetError(err); span.End() }()
 
 	if process.handle == 0 {
 		return makeProcessError(process, operation, ErrAlreadyClosed, nil)
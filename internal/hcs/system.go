@@ -1,6 +1,7 @@
 package hcs
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"strconv"
@@ -8,10 +9,12 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/interop"
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/schema1"
 	"github.com/Microsoft/hcsshim/internal/timeout"
+	"github.com/Microsoft/hcsshim/internal/trace"
 	"github.com/sirupsen/logrus"
 )
 
@@ -47,6 +50,9 @@ type System struct {
 	closedWaitOnce sync.Once
 	waitBlock      chan struct{}
 	waitError      error
+	// waitEvents are the error events HCS reported with the exit.
+	waitEvents []ErrorEvent
+	exitReason cow.ExitReason
 }
 
 func newSystem(id string) *System {
@@ -79,10 +85,20 @@ func (computeSystem *System) logOperationEnd(operation string, err error) {
 		err)
 }
 
+// startSpan starts a span for the operation `op` on the compute system. The
+// System methods do not take a context so the span has no parent.
+func (computeSystem *System) startSpan(op string) *trace.Span {
+	_, span := trace.StartSpan(context.Background(), "hcs::ComputeSystem::"+op, trace.StringAttribute(logfields.ContainerID, computeSystem.id))
+	return span
+}
+
 // CreateComputeSystem creates a new compute system with the given configuration but does not start it.
-func CreateComputeSystem(id string, hcsDocumentInterface interface{}) (_ *System, err error) {
+func CreateComputeSystem(ctx context.Context, id string, hcsDocumentInterface interface{}) (_ *System, err error) {
 	operation := "hcsshim::CreateComputeSystem"
 
+	_, span := trace.StartSpan(ctx, "hcs::CreateComputeSystem", trace.StringAttribute(logfields.ContainerID, id))
+	defer func() { span.SetError(err); span.End() }()
+
 	computeSystem := newSystem(id)
 	computeSystem.logOperationBegin(operation)
 	defer func() { computeSystem.logOperationEnd(operation, err) }()
@@ -231,6 +247,8 @@ func (computeSystem *System) Start() (err error) {
 	operation := "hcsshim::ComputeSystem::Start"
 	computeSystem.logOperationBegin(operation)
 	defer func() { computeSystem.logOperationEnd(operation, err) }()
+	span := computeSystem.startSpan("Start")
+	defer func() { span.SetError(err); span.End() }()
 
 	if computeSystem.handle == 0 {
 		return makeSystemError(computeSystem, "Start", "", ErrAlreadyClosed, nil)
@@ -287,12 +305,15 @@ func (computeSystem *System) Shutdown() (err error) {
 
 	operation := "hcsshim::ComputeSystem::Shutdown"
 	computeSystem.logOperationBegin(operation)
+	span := computeSystem.startSpan("Shutdown")
 	defer func() {
 		if IsAlreadyClosed(err) || IsAlreadyStopped(err) || IsPending(err) {
 			computeSystem.logOperationEnd(operation, nil)
 		} else {
+			span.SetError(err)
 			computeSystem.logOperationEnd(operation, err)
 		}
+		span.End()
 	}()
 
 	if computeSystem.handle == 0 {
@@ -319,12 +340,15 @@ func (computeSystem *System) Terminate() (err error) {
 
 	operation := "hcsshim::ComputeSystem::Terminate"
 	computeSystem.logOperationBegin(operation)
+	span := computeSystem.startSpan("Terminate")
 	defer func() {
 		if IsAlreadyClosed(err) || IsAlreadyStopped(err) || IsPending(err) {
 			computeSystem.logOperationEnd(operation, nil)
 		} else {
+			span.SetError(err)
 			computeSystem.logOperationEnd(operation, err)
 		}
+		span.End()
 	}()
 
 	if computeSystem.handle == 0 {
@@ -351,6 +375,25 @@ func (computeSystem *System) Terminate() (err error) {
 // `WaitExpectedError`, and `WaitTimeout` are safe to call multiple times.
 func (computeSystem *System) waitBackground() {
 	computeSystem.waitError = waitForNotification(computeSystem.callbackNumber, hcsNotificationSystemExited, nil)
+
+	var (
+		data    string
+		crashed bool
+	)
+	callbackMapLock.RLock()
+	context := callbackMap[computeSystem.callbackNumber]
+	callbackMapLock.RUnlock()
+	if context != nil {
+		context.m.Lock()
+		data, crashed = context.exitData, context.crashed
+		context.m.Unlock()
+	}
+	computeSystem.exitReason, computeSystem.waitEvents = systemExitReason(computeSystem.waitError, data, crashed)
+	if computeSystem.exitReason != cow.ExitReasonNone {
+		logrus.WithFields(computeSystem.logctx).
+			WithField("reason", computeSystem.exitReason).
+			Warning("hcsshim::ComputeSystem - System exited abnormally")
+	}
 	computeSystem.closedWaitOnce.Do(func() {
 		close(computeSystem.waitBlock)
 	})
@@ -365,12 +408,24 @@ func (computeSystem *System) Wait() (err error) {
 
 	<-computeSystem.waitBlock
 	if computeSystem.waitError != nil {
-		return makeSystemError(computeSystem, "Wait", "", computeSystem.waitError, nil)
+		return makeSystemError(computeSystem, "Wait", "", computeSystem.waitError, computeSystem.waitEvents)
 	}
 
 	return nil
 }
 
+// ExitReason returns why the compute system exited. It is only valid once
+// `Wait`, `WaitExpectedError` or `WaitTimeout` has returned because the
+// compute system exited.
+func (computeSystem *System) ExitReason() cow.ExitReason {
+	select {
+	case <-computeSystem.waitBlock:
+		return computeSystem.exitReason
+	default:
+		return cow.ExitReasonNone
+	}
+}
+
 // WaitExpectedError synchronously waits for the compute system to shutdown or
 // terminate and returns the error (if any) as long as it does not match
 // `expected`. If the compute system has already exited returns the previous
@@ -382,7 +437,7 @@ func (computeSystem *System) WaitExpectedError(expected error) (err error) {
 
 	<-computeSystem.waitBlock
 	if computeSystem.waitError != nil && getInnerError(computeSystem.waitError) != expected {
-		return makeSystemError(computeSystem, "WaitExpectedError", "", computeSystem.waitError, nil)
+		return makeSystemError(computeSystem, "WaitExpectedError", "", computeSystem.waitError, computeSystem.waitEvents)
 	}
 	return nil
 }
@@ -398,7 +453,7 @@ func (computeSystem *System) WaitTimeout(timeout time.Duration) (err error) {
 	select {
 	case <-computeSystem.waitBlock:
 		if computeSystem.waitError != nil {
-			return makeSystemError(computeSystem, "WaitTimeout", "", computeSystem.waitError, nil)
+			return makeSystemError(computeSystem, "WaitTimeout", "", computeSystem.waitError, computeSystem.waitEvents)
 		}
 		return nil
 	case <-time.After(timeout):
@@ -413,6 +468,8 @@ func (computeSystem *System) Properties(types ...schema1.PropertyType) (_ *schem
 	operation := "hcsshim::ComputeSystem::Properties"
 	computeSystem.logOperationBegin(operation)
 	defer func() { computeSystem.logOperationEnd(operation, err) }()
+	span := computeSystem.startSpan("Properties")
+	defer func() { span.SetError(err); span.End() }()
 
 	queryBytes, err := json.Marshal(schema1.PropertyQuery{PropertyTypes: types})
 	if err != nil {
@@ -453,6 +510,8 @@ func (computeSystem *System) Pause() (err error) {
 	operation := "hcsshim::ComputeSystem::Pause"
 	computeSystem.logOperationBegin(operation)
 	defer func() { computeSystem.logOperationEnd(operation, err) }()
+	span := computeSystem.startSpan("Pause")
+	defer func() { span.SetError(err); span.End() }()
 
 	if computeSystem.handle == 0 {
 		return makeSystemError(computeSystem, "Pause", "", ErrAlreadyClosed, nil)
@@ -478,6 +537,8 @@ func (computeSystem *System) Resume() (err error) {
 	operation := "hcsshim::ComputeSystem::Resume"
 	computeSystem.logOperationBegin(operation)
 	defer func() { computeSystem.logOperationEnd(operation, err) }()
+	span := computeSystem.startSpan("Resume")
+	defer func() { span.SetError(err); span.End() }()
 
 	if computeSystem.handle == 0 {
 		return makeSystemError(computeSystem, "Resume", "", ErrAlreadyClosed, nil)
@@ -495,6 +556,44 @@ func (computeSystem *System) Resume() (err error) {
 	return nil
 }
 
+// Save synchronously saves the state of the computeSystem as described by
+// `options`. The computeSystem MUST be paused before it can be saved.
+func (computeSystem *System) Save(options interface{}) (err error) {
+	computeSystem.handleLock.RLock()
+	defer computeSystem.handleLock.RUnlock()
+
+	operation := "hcsshim::ComputeSystem::Save"
+	computeSystem.logOperationBegin(operation)
+	defer func() { computeSystem.logOperationEnd(operation, err) }()
+	span := computeSystem.startSpan("Save")
+	defer func() { span.SetError(err); span.End() }()
+
+	if computeSystem.handle == 0 {
+		return makeSystemError(computeSystem, "Save", "", ErrAlreadyClosed, nil)
+	}
+
+	optionsb, err := json.Marshal(options)
+	if err != nil {
+		return makeSystemError(computeSystem, "Save", "", err, nil)
+	}
+	optionsStr := string(optionsb)
+
+	logrus.WithFields(computeSystem.logctx).
+		WithField(logfields.JSON, optionsStr).
+		Debug("HCS ComputeSystem Save Document")
+
+	var resultp *uint16
+	syscallWatcher(computeSystem.logctx, func() {
+		err = hcsSaveComputeSystem(computeSystem.handle, optionsStr, &resultp)
+	})
+	events, err := processAsyncHcsResult(err, resultp, computeSystem.callbackNumber, hcsNotificationSystemSaveCompleted, &timeout.SystemSave)
+	if err != nil {
+		return makeSystemError(computeSystem, "Save", optionsStr, err, events)
+	}
+
+	return nil
+}
+
 // CreateProcess launches a new process within the computeSystem.
 func (computeSystem *System) CreateProcess(c interface{}) (_ *Process, err error) {
 	computeSystem.handleLock.RLock()
@@ -503,6 +602,8 @@ func (computeSystem *System) CreateProcess(c interface{}) (_ *Process, err error
 	operation := "hcsshim::ComputeSystem::CreateProcess"
 	computeSystem.logOperationBegin(operation)
 	defer func() { computeSystem.logOperationEnd(operation, err) }()
+	span := computeSystem.startSpan("CreateProcess")
+	defer func() { span.SetError(err); span.End() }()
 
 	var (
 		processInfo   hcsProcessInformation
@@ -564,6 +665,8 @@ func (computeSystem *System) OpenProcess(pid int) (_ *Process, err error) {
 	operation := "hcsshim::ComputeSystem::OpenProcess"
 	computeSystem.logOperationBegin(operation)
 	defer func() { computeSystem.logOperationEnd(operation, err) }()
+	span := computeSystem.startSpan("OpenProcess")
+	defer func() { span.SetError(err); span.End() }()
 
 	var (
 		processHandle hcsProcess
@@ -690,6 +793,8 @@ func (computeSystem *System) Modify(config interface{}) (err error) {
 	operation := "hcsshim::ComputeSystem::Modify"
 	computeSystem.logOperationBegin(operation)
 	defer func() { computeSystem.logOperationEnd(operation, err) }()
+	span := computeSystem.startSpan("Modify")
+	defer func() { span.SetError(err); span.End() }()
 
 	if computeSystem.handle == 0 {
 		return makeSystemError(computeSystem, "Modify", "", ErrAlreadyClosed, nil)
@@ -3,17 +3,21 @@
 package hcsoci
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
 
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/guid"
 	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/oci"
 	"github.com/Microsoft/hcsshim/internal/schema2"
 	"github.com/Microsoft/hcsshim/internal/schemaversion"
+	"github.com/Microsoft/hcsshim/internal/trace"
 	"github.com/Microsoft/hcsshim/internal/uvm"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
@@ -33,6 +37,7 @@ type CreateOptions struct {
 	SchemaVersion    *hcsschema.Version // Requested Schema Version. Defaults to v2 for RS5, v1 for RS1..RS4
 	HostingSystem    *uvm.UtilityVM     // Utility or service VM in which the container is to be created.
 	NetworkNamespace string             // Host network namespace to use (overrides anything in the spec)
+	Backend          cow.Backend        // Creates the compute system of the container. Defaults to HCS.
 
 	// This is an advanced debugging parameter. It allows for diagnosibility by leaving a containers
 	// resources allocated in case of a failure. Thus you would be able to use tools such as hcsdiag
@@ -59,9 +64,12 @@ type createOptionsInternal struct {
 // case of an error. This provides support for the debugging option not to
 // release the resources on failure, so that the client can make the necessary
 // call to release resources that have been allocated as part of calling this function.
-func CreateContainer(createOptions *CreateOptions) (_ *hcs.System, _ *Resources, err error) {
+func CreateContainer(ctx context.Context, createOptions *CreateOptions) (_ cow.ComputeSystem, _ *Resources, err error) {
 	logrus.Debugf("hcsshim::CreateContainer options: %+v", createOptions)
 
+	ctx, span := trace.StartSpan(ctx, "hcsoci::CreateContainer")
+	defer func() { span.SetError(err); span.End() }()
+
 	coi := &createOptionsInternal{
 		CreateOptions: createOptions,
 		actualID:      createOptions.ID,
@@ -75,6 +83,10 @@ func CreateContainer(createOptions *CreateOptions) (_ *hcs.System, _ *Resources,
 	if coi.actualOwner == "" {
 		coi.actualOwner = filepath.Base(os.Args[0])
 	}
+	span.AddAttributes(trace.StringAttribute(logfields.ContainerID, coi.actualID))
+	if coi.HostingSystem != nil {
+		span.AddAttributes(trace.StringAttribute(logfields.UVMID, coi.HostingSystem.ID()))
+	}
 
 	if coi.Spec == nil {
 		return nil, nil, fmt.Errorf("Spec must be supplied")
@@ -155,7 +167,7 @@ func CreateContainer(createOptions *CreateOptions) (_ *hcs.System, _ *Resources,
 			return nil, resources, errors.New("LCOW v1 not supported")
 		}
 		logrus.Debugf("hcsshim::CreateContainer allocateLinuxResources")
-		err = allocateLinuxResources(coi, resources)
+		err = allocateLinuxResources(ctx, coi, resources)
 		if err != nil {
 			logrus.Debugf("failed to allocateLinuxResources %s", err)
 			return nil, resources, err
@@ -166,7 +178,7 @@ func CreateContainer(createOptions *CreateOptions) (_ *hcs.System, _ *Resources,
 			return nil, resources, err
 		}
 	} else {
-		err = allocateWindowsResources(coi, resources)
+		err = allocateWindowsResources(ctx, coi, resources)
 		if err != nil {
 			logrus.Debugf("failed to allocateWindowsResources %s", err)
 			return nil, resources, err
@@ -180,7 +192,11 @@ func CreateContainer(createOptions *CreateOptions) (_ *hcs.System, _ *Resources,
 	}
 
 	logrus.Debugf("hcsshim::CreateContainer creating compute system")
-	system, err := hcs.CreateComputeSystem(coi.actualID, hcsDocument)
+	backend := coi.Backend
+	if backend == nil {
+		backend = hcs.Backend()
+	}
+	system, err := backend.CreateComputeSystem(ctx, coi.actualID, hcsDocument)
 	if err != nil {
 		logrus.Debugf("failed to CreateComputeSystem %s", err)
 		return nil, resources, err
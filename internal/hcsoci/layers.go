@@ -3,15 +3,18 @@
 package hcsoci
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
 
 	"github.com/Microsoft/hcsshim/internal/guestrequest"
+	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/ospath"
 	"github.com/Microsoft/hcsshim/internal/requesttype"
 	"github.com/Microsoft/hcsshim/internal/schema2"
+	"github.com/Microsoft/hcsshim/internal/trace"
 	"github.com/Microsoft/hcsshim/internal/uvm"
 	"github.com/Microsoft/hcsshim/internal/wclayer"
 	"github.com/pkg/errors"
@@ -35,9 +38,16 @@ const scratchPath = "scratch"
 //                    inside the utility VM which is a GUID mapping of the scratch folder. Each
 //                    of the layers are the VSMB locations where the read-only layers are mounted.
 //
-func MountContainerLayers(layerFolders []string, guestRoot string, uvm *uvm.UtilityVM) (interface{}, error) {
+func MountContainerLayers(ctx context.Context, layerFolders []string, guestRoot string, uvm *uvm.UtilityVM) (_ interface{}, err error) {
 	logrus.Debugln("hcsshim::mountContainerLayers", layerFolders)
 
+	ctx, span := trace.StartSpan(ctx, "hcsoci::MountContainerLayers",
+		trace.Int64Attribute("layers", int64(len(layerFolders))))
+	defer func() { span.SetError(err); span.End() }()
+	if uvm != nil {
+		span.AddAttributes(trace.StringAttribute(logfields.UVMID, uvm.ID()))
+	}
+
 	if uvm == nil {
 		if len(layerFolders) < 2 {
 			return nil, fmt.Errorf("need at least two layers - base and scratch")
@@ -91,7 +101,7 @@ func MountContainerLayers(layerFolders []string, guestRoot string, uvm *uvm.Util
 				CacheIo:             true,
 				ShareRead:           true,
 			}
-			err = uvm.AddVSMB(layerPath, "", options)
+			err = uvm.AddVSMB(ctx, layerPath, "", options)
 			if err == nil {
 				wcowLayersAdded = append(wcowLayersAdded, layerPath)
 			}
@@ -107,7 +117,7 @@ func MountContainerLayers(layerFolders []string, guestRoot string, uvm *uvm.Util
 					controller int
 					lun        int32
 				)
-				controller, lun, err = uvm.AddSCSILayer(hostPath)
+				controller, lun, err = uvm.AddSCSILayer(ctx, hostPath)
 				if err == nil {
 					lcowlayersAdded = append(lcowlayersAdded,
 						lcowLayerEntry{
@@ -117,7 +127,10 @@ func MountContainerLayers(layerFolders []string, guestRoot string, uvm *uvm.Util
 						})
 				}
 			} else {
-				_, uvmPath, err = uvm.AddVPMEM(hostPath, true) // UVM path is calculated. Will be /tmp/vN/
+				// Packed with other layers into a shared VPMem device where
+				// supported. UVM path is calculated. Will be /tmp/pN or
+				// /tmp/pN-<offset>.
+				uvmPath, err = uvm.AddVPMEMLayer(ctx, hostPath)
 				if err == nil {
 					lcowlayersAdded = append(lcowlayersAdded,
 						lcowLayerEntry{
@@ -139,7 +152,7 @@ func MountContainerLayers(layerFolders []string, guestRoot string, uvm *uvm.Util
 
 	// BUGBUG Rename guestRoot better.
 	containerScratchPathInUVM := ospath.Join(uvm.OS(), guestRoot, scratchPath)
-	_, _, err := uvm.AddSCSI(hostPath, containerScratchPathInUVM, false)
+	_, _, err = uvm.AddSCSI(ctx, hostPath, containerScratchPathInUVM, false)
 	if err != nil {
 		cleanupOnMountFailure(uvm, wcowLayersAdded, lcowlayersAdded, attachedSCSIHostPath)
 		return nil, err
@@ -312,12 +325,12 @@ func UnmountContainerLayers(layerFolders []string, guestRoot string, uvm *uvm.Ut
 	if uvm.OS() == "linux" && len(layerFolders) > 1 && (op&UnmountOperationVPMEM) == UnmountOperationVPMEM {
 		for _, layerPath := range layerFolders[:len(layerFolders)-1] {
 			hostPath := filepath.Join(layerPath, "layer.vhd")
-			if fi, err := os.Stat(hostPath); err != nil {
+			if fi, err := os.Stat(hostPath); err == nil {
 				var e error
 				if uint64(fi.Size()) > uvm.PMemMaxSizeBytes() {
 					e = uvm.RemoveSCSI(hostPath)
 				} else {
-					e = uvm.RemoveVPMEM(hostPath)
+					e = uvm.RemoveVPMEMLayer(hostPath)
 				}
 				if e != nil {
 					logrus.Debugln(e)
@@ -347,7 +360,7 @@ func cleanupOnMountFailure(uvm *uvm.UtilityVM, wcowLayers []string, lcowLayers [
 			if err := uvm.RemoveSCSI(ll.hostPath); err != nil {
 				logrus.Warnf("Possibly leaked SCSI on error removal path: %s", err)
 			}
-		} else if err := uvm.RemoveVPMEM(ll.hostPath); err != nil {
+		} else if err := uvm.RemoveVPMEMLayer(ll.hostPath); err != nil {
 			logrus.Warnf("Possibly leaked vpmemdevice on error removal path: %s", err)
 		}
 	}
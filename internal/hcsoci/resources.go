@@ -35,8 +35,9 @@ type Resources struct {
 	// (bind-)mounts into a WCOW v2 Xenon.
 	vsmbMounts []string
 
-	// plan9Mounts is an array of all the host paths which have been added to
-	// an LCOW utility VM
+	// plan9Mounts is an array of the references to the Plan9 shares which
+	// have been added to an LCOW utility VM. Shares are ref-counted by the
+	// utility VM and only removed with their last reference.
 	plan9Mounts []*uvm.Plan9Share
 
 	// netNS is the network namespace
@@ -56,6 +57,53 @@ type Resources struct {
 	scsiMounts []string
 }
 
+// ResourcesState is the serializable form of Resources. It allows the resources
+// held by a container to be persisted and released by a process other than the
+// one that created the container.
+type ResourcesState struct {
+	ContainerRootInUVM string            `json:"ContainerRootInUVM,omitempty"`
+	Layers             []string          `json:"Layers,omitempty"`
+	VSMBMounts         []string          `json:"VSMBMounts,omitempty"`
+	Plan9Mounts        []*uvm.Plan9Share `json:"Plan9Mounts,omitempty"`
+	NetNS              string            `json:"NetNS,omitempty"`
+	NetworkEndpoints   []string          `json:"NetworkEndpoints,omitempty"`
+	CreatedNetNS       bool              `json:"CreatedNetNS,omitempty"`
+	AddedNetNSToVM     bool              `json:"AddedNetNSToVM,omitempty"`
+	SCSIMounts         []string          `json:"SCSIMounts,omitempty"`
+}
+
+// State returns the serializable form of `r`.
+func (r *Resources) State() *ResourcesState {
+	return &ResourcesState{
+		ContainerRootInUVM: r.containerRootInUVM,
+		Layers:             append([]string(nil), r.layers...),
+		VSMBMounts:         append([]string(nil), r.vsmbMounts...),
+		Plan9Mounts:        append([]*uvm.Plan9Share(nil), r.plan9Mounts...),
+		NetNS:              r.netNS,
+		NetworkEndpoints:   append([]string(nil), r.networkEndpoints...),
+		CreatedNetNS:       r.createdNetNS,
+		AddedNetNSToVM:     r.addedNetNSToVM,
+		SCSIMounts:         append([]string(nil), r.scsiMounts...),
+	}
+}
+
+// NewResourcesFromState returns the Resources described by `s`. The result can
+// be passed to ReleaseResources along with the utility VM the resources were
+// added to.
+func NewResourcesFromState(s *ResourcesState) *Resources {
+	return &Resources{
+		containerRootInUVM: s.ContainerRootInUVM,
+		layers:             append([]string(nil), s.Layers...),
+		vsmbMounts:         append([]string(nil), s.VSMBMounts...),
+		plan9Mounts:        append([]*uvm.Plan9Share(nil), s.Plan9Mounts...),
+		netNS:              s.NetNS,
+		networkEndpoints:   append([]string(nil), s.NetworkEndpoints...),
+		createdNetNS:       s.CreatedNetNS,
+		addedNetNSToVM:     s.AddedNetNSToVM,
+		scsiMounts:         append([]string(nil), s.SCSIMounts...),
+	}
+}
+
 // TODO: Method on the resources?
 func ReleaseResources(r *Resources, vm *uvm.UtilityVM, all bool) error {
 	if vm != nil && r.addedNetNSToVM {
@@ -65,24 +113,8 @@ func ReleaseResources(r *Resources, vm *uvm.UtilityVM, all bool) error {
 		r.addedNetNSToVM = false
 	}
 
-	if r.createdNetNS {
-		for len(r.networkEndpoints) != 0 {
-			endpoint := r.networkEndpoints[len(r.networkEndpoints)-1]
-			err := hns.RemoveNamespaceEndpoint(r.netNS, endpoint)
-			if err != nil {
-				if !os.IsNotExist(err) {
-					return err
-				}
-				logrus.Warnf("removing endpoint %s from namespace %s: does not exist", endpoint, r.NetNS())
-			}
-			r.networkEndpoints = r.networkEndpoints[:len(r.networkEndpoints)-1]
-		}
-		r.networkEndpoints = nil
-		err := hns.RemoveNamespace(r.netNS)
-		if err != nil && !os.IsNotExist(err) {
-			return err
-		}
-		r.createdNetNS = false
+	if err := releaseNetNS(r); err != nil {
+		return err
 	}
 
 	if len(r.layers) != 0 {
@@ -97,7 +129,9 @@ func ReleaseResources(r *Resources, vm *uvm.UtilityVM, all bool) error {
 		r.layers = nil
 	}
 
-	if all {
+	// The mounts below only exist in a utility VM. Without one there is
+	// nothing to remove them from.
+	if all && vm != nil {
 		for len(r.vsmbMounts) != 0 {
 			mount := r.vsmbMounts[len(r.vsmbMounts)-1]
 			if err := vm.RemoveVSMB(mount); err != nil {
@@ -124,3 +158,37 @@ func ReleaseResources(r *Resources, vm *uvm.UtilityVM, all bool) error {
 
 	return nil
 }
+
+// ReleaseHostResources releases only the resources of `r` that live on the
+// host. It is used for a container in a utility VM when the utility VM is not
+// open, for example when cleaning up after a shim that is gone. The resources
+// inside the utility VM are released when the utility VM is terminated.
+func ReleaseHostResources(r *Resources) error {
+	return releaseNetNS(r)
+}
+
+// releaseNetNS removes the network namespace and its endpoints if they were
+// created for the container.
+func releaseNetNS(r *Resources) error {
+	if !r.createdNetNS {
+		return nil
+	}
+	for len(r.networkEndpoints) != 0 {
+		endpoint := r.networkEndpoints[len(r.networkEndpoints)-1]
+		err := hns.RemoveNamespaceEndpoint(r.netNS, endpoint)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			logrus.Warnf("removing endpoint %s from namespace %s: does not exist", endpoint, r.NetNS())
+		}
+		r.networkEndpoints = r.networkEndpoints[:len(r.networkEndpoints)-1]
+	}
+	r.networkEndpoints = nil
+	err := hns.RemoveNamespace(r.netNS)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	r.createdNetNS = false
+	return nil
+}
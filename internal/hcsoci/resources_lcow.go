@@ -5,6 +5,7 @@ package hcsoci
 // Contains functions relating to a LCOW container, as opposed to a utility VM
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -20,13 +21,13 @@ import (
 const rootfsPath = "rootfs"
 const mountPathPrefix = "m"
 
-func allocateLinuxResources(coi *createOptionsInternal, resources *Resources) error {
+func allocateLinuxResources(ctx context.Context, coi *createOptionsInternal, resources *Resources) error {
 	if coi.Spec.Root == nil {
 		coi.Spec.Root = &specs.Root{}
 	}
 	if coi.Spec.Windows != nil && len(coi.Spec.Windows.LayerFolders) > 0 {
 		logrus.Debugln("hcsshim::allocateLinuxResources mounting storage")
-		mcl, err := MountContainerLayers(coi.Spec.Windows.LayerFolders, resources.containerRootInUVM, coi.HostingSystem)
+		mcl, err := MountContainerLayers(ctx, coi.Spec.Windows.LayerFolders, resources.containerRootInUVM, coi.HostingSystem)
 		if err != nil {
 			return fmt.Errorf("failed to mount container storage: %s", err)
 		}
@@ -41,12 +42,14 @@ func allocateLinuxResources(coi *createOptionsInternal, resources *Resources) er
 		// TODO: We need a test for this. Ask @jstarks how you can even lay this out on Windows.
 		hostPath := coi.Spec.Root.Path
 		uvmPathForContainersFileSystem := path.Join(resources.containerRootInUVM, rootfsPath)
-		share, err := coi.HostingSystem.AddPlan9(hostPath, uvmPathForContainersFileSystem, coi.Spec.Root.Readonly, false, nil)
+		// The root filesystem is written by the container so it is never
+		// shared with another container, even of the same host directory.
+		share, err := coi.HostingSystem.AddPlan9Exclusive(hostPath, uvmPathForContainersFileSystem, coi.Spec.Root.Readonly)
 		if err != nil {
 			return fmt.Errorf("adding plan9 root: %s", err)
 		}
-		coi.Spec.Root.Path = uvmPathForContainersFileSystem
 		resources.plan9Mounts = append(resources.plan9Mounts, share)
+		coi.Spec.Root.Path = share.UVMPath()
 	} else {
 		return errors.New("must provide either Windows.LayerFolders or Root.Path")
 	}
@@ -79,7 +82,7 @@ func allocateLinuxResources(coi *createOptionsInternal, resources *Resources) er
 
 			if mount.Type == "physical-disk" {
 				logrus.Debugf("hcsshim::allocateLinuxResources Hot-adding SCSI physical disk for OCI mount %+v", mount)
-				_, _, err := coi.HostingSystem.AddSCSIPhysicalDisk(hostPath, uvmPathForShare, readOnly)
+				_, _, err := coi.HostingSystem.AddSCSIPhysicalDisk(ctx, hostPath, uvmPathForShare, readOnly)
 				if err != nil {
 					return fmt.Errorf("adding SCSI physical disk mount %+v: %s", mount, err)
 				}
@@ -87,7 +90,7 @@ func allocateLinuxResources(coi *createOptionsInternal, resources *Resources) er
 				coi.Spec.Mounts[i].Type = "none"
 			} else if mount.Type == "virtual-disk" {
 				logrus.Debugf("hcsshim::allocateLinuxResources Hot-adding SCSI virtual disk for OCI mount %+v", mount)
-				_, _, err := coi.HostingSystem.AddSCSI(hostPath, uvmPathForShare, readOnly)
+				_, _, err := coi.HostingSystem.AddSCSI(ctx, hostPath, uvmPathForShare, readOnly)
 				if err != nil {
 					return fmt.Errorf("adding SCSI virtual disk mount %+v: %s", mount, err)
 				}
@@ -107,14 +110,20 @@ func allocateLinuxResources(coi *createOptionsInternal, resources *Resources) er
 					hostPath, fileName = path.Split(hostPath)
 					allowedNames = append(allowedNames, fileName)
 					restrictAccess = true
-					uvmPathForFile = path.Join(uvmPathForShare, fileName)
 				}
 				logrus.Debugf("hcsshim::allocateLinuxResources Hot-adding Plan9 for OCI mount %+v", mount)
-				share, err := coi.HostingSystem.AddPlan9(hostPath, uvmPathForShare, readOnly, restrictAccess, allowedNames)
+				// Plan9 shares are shared by every container in the utility
+				// VM that maps the same directory, so the share is mounted at
+				// a path of the utility VM and bound into the container.
+				share, err := coi.HostingSystem.AddPlan9(hostPath, "", readOnly, restrictAccess, allowedNames)
 				if err != nil {
 					return fmt.Errorf("adding plan9 mount %+v: %s", mount, err)
 				}
 				resources.plan9Mounts = append(resources.plan9Mounts, share)
+				uvmPathForFile = share.UVMPath()
+				if restrictAccess {
+					uvmPathForFile = path.Join(uvmPathForFile, allowedNames[0])
+				}
 			}
 			coi.Spec.Mounts[i].Source = uvmPathForFile
 		}
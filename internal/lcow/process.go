@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Microsoft/hcsshim/internal/copywithtimeout"
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/schema2"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
@@ -24,7 +25,7 @@ type ByteCounts struct {
 // ProcessOptions are the set of options which are passed to CreateProcessEx() to
 // create a utility vm.
 type ProcessOptions struct {
-	HCSSystem         *hcs.System
+	HCSSystem         cow.ComputeSystem
 	Process           *specs.Process
 	Stdin             io.Reader     // Optional reader for sending on to the processes stdin stream
 	Stdout            io.Writer     // Optional writer for returning the processes stdout stream
@@ -53,7 +54,7 @@ type ProcessOptions struct {
 //
 // It is the responsibility of the caller to call Close() on the process returned.
 
-func CreateProcess(opts *ProcessOptions) (*hcs.Process, *ByteCounts, error) {
+func CreateProcess(opts *ProcessOptions) (cow.Process, *ByteCounts, error) {
 
 	var environment = make(map[string]string)
 	copiedByteCounts := &ByteCounts{}
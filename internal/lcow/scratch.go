@@ -2,6 +2,7 @@ package lcow
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -20,7 +21,7 @@ import (
 // size, a copy of that is made to the target. If the size is non-default, or the cache file
 // does not exist, it uses a utility VM to create target. It is the responsibility of the
 // caller to synchronise simultaneous attempts to create the cache file.
-func CreateScratch(lcowUVM *uvm.UtilityVM, destFile string, sizeGB uint32, cacheFile string, vmID string) error {
+func CreateScratch(ctx context.Context, lcowUVM *uvm.UtilityVM, destFile string, sizeGB uint32, cacheFile string, vmID string) error {
 
 	if lcowUVM == nil {
 		return fmt.Errorf("no uvm")
@@ -53,7 +54,7 @@ func CreateScratch(lcowUVM *uvm.UtilityVM, destFile string, sizeGB uint32, cache
 		return fmt.Errorf("failed to create VHDx %s: %s", destFile, err)
 	}
 
-	controller, lun, err := lcowUVM.AddSCSI(destFile, "", false) // No destination as not formatted
+	controller, lun, err := lcowUVM.AddSCSI(ctx, destFile, "", false) // No destination as not formatted
 	if err != nil {
 		return err
 	}
@@ -6,6 +6,9 @@ const (
 	ContainerID = "cid"
 	UVMID       = "uvm-id"
 	ProcessID   = "pid"
+	PodID       = "pod-id"
+	TaskID      = "tid"
+	ExecID      = "eid"
 
 	// Common Misc
 
@@ -29,4 +32,10 @@ const (
 	// runhcs
 
 	VMShimOperation = "vmshim-op"
+
+	// Guest
+
+	// VMTime is the time a log entry forwarded from the guest was logged in
+	// the guest. Only entries forwarded from the guest have it.
+	VMTime = "vm.time"
 )
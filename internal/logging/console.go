@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// maxConsoleLineSize is the length at which a console line that has no newline
+// yet is written as a line of its own.
+const maxConsoleLineSize = 4096
+
+// ConsoleWriter is an `io.WriteCloser` that writes the output of a serial
+// console to another writer a line at a time. Each line is prefixed with the
+// host time it was completed at and carriage returns are removed.
+//
+// Writing whole lines keeps a `RotatingFile` from splitting a line across
+// files.
+type ConsoleWriter struct {
+	w   io.Writer
+	now func() time.Time
+
+	m   sync.Mutex
+	buf []byte
+}
+
+// NewConsoleWriter creates a `ConsoleWriter` that writes the lines of console
+// output to `w`. If `w` is an `io.Closer` it is closed by `Close`.
+func NewConsoleWriter(w io.Writer) *ConsoleWriter {
+	return &ConsoleWriter{
+		w:   w,
+		now: time.Now,
+	}
+}
+
+// Write buffers `p` and writes every line it completes.
+func (cw *ConsoleWriter) Write(p []byte) (int, error) {
+	cw.m.Lock()
+	defer cw.m.Unlock()
+
+	for _, b := range p {
+		switch b {
+		case '\r':
+			continue
+		case '\n':
+			if err := cw.flushL(); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		cw.buf = append(cw.buf, b)
+		if len(cw.buf) >= maxConsoleLineSize {
+			if err := cw.flushL(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// flushL writes the buffered line. The caller MUST hold `cw.m`.
+func (cw *ConsoleWriter) flushL() error {
+	line := make([]byte, 0, len(time.RFC3339Nano)+len(cw.buf)+2)
+	line = append(line, cw.now().UTC().Format(time.RFC3339Nano)...)
+	line = append(line, ' ')
+	line = append(line, cw.buf...)
+	line = append(line, '\n')
+	cw.buf = cw.buf[:0]
+	_, err := cw.w.Write(line)
+	return err
+}
+
+// Close writes the incomplete last line, if any, and closes the underlying
+// writer if it is an `io.Closer`.
+func (cw *ConsoleWriter) Close() error {
+	cw.m.Lock()
+	defer cw.m.Unlock()
+
+	var err error
+	if len(cw.buf) > 0 {
+		err = cw.flushL()
+	}
+	if c, ok := cw.w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Hook is a `logrus.Hook` that writes every entry accepted by its filter to a
+// writer in the format of its formatter.
+type Hook struct {
+	w         io.Writer
+	formatter logrus.Formatter
+	filter    func(*logrus.Entry) bool
+}
+
+var _ = (logrus.Hook)(&Hook{})
+
+// NewHook creates a `Hook` that writes entries formatted with `formatter` to
+// `w`. If `filter` is not `nil` only entries it returns `true` for are
+// written.
+func NewHook(w io.Writer, formatter logrus.Formatter, filter func(*logrus.Entry) bool) *Hook {
+	return &Hook{
+		w:         w,
+		formatter: formatter,
+		filter:    filter,
+	}
+}
+
+// Levels returns all levels. Which entries are logged is controlled by the
+// level of the logger.
+func (h *Hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire writes `e` if it is accepted by the filter.
+func (h *Hook) Fire(e *logrus.Entry) error {
+	if h.filter != nil && !h.filter(e) {
+		return nil
+	}
+	b, err := h.formatter.Format(e)
+	if err != nil {
+		return err
+	}
+	_, err = h.w.Write(b)
+	return err
+}
+
+// FieldsHook is a `logrus.Hook` that adds a fixed set of fields to every
+// entry that does not already have them.
+type FieldsHook struct {
+	fields logrus.Fields
+}
+
+var _ = (logrus.Hook)(&FieldsHook{})
+
+// NewFieldsHook creates a `FieldsHook` that adds `fields`.
+func NewFieldsHook(fields logrus.Fields) *FieldsHook {
+	return &FieldsHook{fields: fields}
+}
+
+// Levels returns all levels.
+func (h *FieldsHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire adds the fields of `h` that are missing from `e`.
+func (h *FieldsHook) Fire(e *logrus.Entry) error {
+	for k, v := range h.fields {
+		if _, ok := e.Data[k]; !ok {
+			e.Data[k] = v
+		}
+	}
+	return nil
+}
+
+// AddHookFirst adds `hook` to `logger` so that it fires before every hook that
+// was already added. This allows `hook` to modify entries, for example to add
+// fields, before they are written by the other hooks.
+//
+// It MUST not be called concurrently with anything else that adds hooks to
+// `logger`.
+func AddHookFirst(logger *logrus.Logger, hook logrus.Hook) {
+	hooks := make(logrus.LevelHooks)
+	hooks.Add(hook)
+	for level, lh := range logger.Hooks {
+		hooks[level] = append(hooks[level], lh...)
+	}
+	logger.ReplaceHooks(hooks)
+}
@@ -0,0 +1,231 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func readFile(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read '%s': %v", path, err)
+	}
+	return string(b)
+}
+
+func Test_RotatingFile_Rotate_RetainsMaxFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.log")
+
+	rf, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	defer rf.Close()
+	for _, s := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		if _, err := rf.Write([]byte(s)); err != nil {
+			t.Fatalf("should not have failed with error: %v", err)
+		}
+	}
+
+	if s := readFile(t, path); s != "dddddd\n" {
+		t.Fatalf("expected current file: 'dddddd', got: '%s'", s)
+	}
+	if s := readFile(t, path+".1"); s != "cccccc\n" {
+		t.Fatalf("expected first rotated file: 'cccccc', got: '%s'", s)
+	}
+	if s := readFile(t, path+".2"); s != "bbbbbb\n" {
+		t.Fatalf("expected second rotated file: 'bbbbbb', got: '%s'", s)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected only 2 rotated files, got: %v", err)
+	}
+}
+
+func Test_RotatingFile_Open_AppendsExisting(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.log")
+	if err := ioutil.WriteFile(path, []byte("existing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rf, err := OpenRotatingFile(path, 12, 1)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	defer rf.Close()
+	rf.Write([]byte("new\n"))
+
+	if s := readFile(t, path); s != "new\n" {
+		t.Fatalf("expected existing size to count toward rotation, got: '%s'", s)
+	}
+	if s := readFile(t, path+".1"); s != "existing\n" {
+		t.Fatalf("expected rotated file: 'existing', got: '%s'", s)
+	}
+}
+
+func Test_RotatingFile_NoMaxFiles_Truncates(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.log")
+
+	rf, err := OpenRotatingFile(path, 5, 0)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	defer rf.Close()
+	rf.Write([]byte("1234\n"))
+	rf.Write([]byte("5678\n"))
+
+	if s := readFile(t, path); s != "5678\n" {
+		t.Fatalf("expected '5678', got: '%s'", s)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Fatalf("expected no rotated files, got: %v", err)
+	}
+}
+
+func Test_Hook_Filter_Format(t *testing.T) {
+	var b bytes.Buffer
+	l := logrus.New()
+	l.Out = ioutil.Discard
+	l.AddHook(NewHook(&b, &logrus.JSONFormatter{}, func(e *logrus.Entry) bool {
+		_, ok := e.Data["guest"]
+		return ok
+	}))
+
+	l.Info("host")
+	l.WithField("guest", true).Info("from guest")
+
+	if s := b.String(); strings.Contains(s, `"msg":"host"`) || !strings.Contains(s, `"msg":"from guest"`) {
+		t.Fatalf("expected only the guest entry, got: '%s'", s)
+	}
+}
+
+func Test_AddHookFirst_FieldsSeenByOtherHooks(t *testing.T) {
+	var b bytes.Buffer
+	l := logrus.New()
+	l.Out = ioutil.Discard
+	l.AddHook(NewHook(&b, &logrus.JSONFormatter{}, nil))
+	AddHookFirst(l, NewFieldsHook(logrus.Fields{"pod-id": "pod", "tid": "default"}))
+
+	l.WithField("tid", "task").Info("msg")
+
+	s := b.String()
+	if !strings.Contains(s, `"pod-id":"pod"`) {
+		t.Fatalf("expected pod-id to be added, got: '%s'", s)
+	}
+	if !strings.Contains(s, `"tid":"task"`) {
+		t.Fatalf("expected existing tid to be kept, got: '%s'", s)
+	}
+}
+
+var consoleTestTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newTestConsoleWriter(w io.Writer) *ConsoleWriter {
+	cw := NewConsoleWriter(w)
+	cw.now = func() time.Time { return consoleTestTime }
+	return cw
+}
+
+func Test_ConsoleWriter_Lines(t *testing.T) {
+	var b bytes.Buffer
+	cw := newTestConsoleWriter(&b)
+	for _, s := range []string{"[    0.0] Linux ver", "sion\r\n[    0.1] Command", " line\r\n", "login:"} {
+		if _, err := cw.Write([]byte(s)); err != nil {
+			t.Fatalf("should not have failed with error: %v", err)
+		}
+	}
+	ts := consoleTestTime.Format(time.RFC3339Nano)
+	expected := ts + " [    0.0] Linux version\n" + ts + " [    0.1] Command line\n"
+	if b.String() != expected {
+		t.Fatalf("expected '%s', got: '%s'", expected, b.String())
+	}
+	if err := cw.Close(); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	expected += ts + " login:\n"
+	if b.String() != expected {
+		t.Fatalf("expected the last line written on close '%s', got: '%s'", expected, b.String())
+	}
+}
+
+func Test_ConsoleWriter_LongLine_Split(t *testing.T) {
+	var b bytes.Buffer
+	cw := newTestConsoleWriter(&b)
+	cw.Write(bytes.Repeat([]byte("a"), maxConsoleLineSize+1))
+	cw.Close()
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[1], " a") {
+		t.Fatalf("expected the line to be split at %d bytes, got: %d lines", maxConsoleLineSize, len(lines))
+	}
+}
+
+func Test_ConsoleWriter_Pipe_RotatesWholeLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "console.log")
+
+	ts := consoleTestTime.Format(time.RFC3339Nano)
+	lineSize := int64(len(ts) + len(" line 0\n"))
+	rf, err := OpenRotatingFile(path, 3*lineSize, 10)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	cw := newTestConsoleWriter(rf)
+
+	// The guest writes to one end of the pipe in chunks that split lines.
+	guest, host := net.Pipe()
+	go func() {
+		for i := 0; i < 7; i++ {
+			guest.Write([]byte(fmt.Sprintf("line %d\r", i)))
+			guest.Write([]byte("\n"))
+		}
+		guest.Close()
+	}()
+	if _, err := io.Copy(cw, host); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := cw.Close(); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+
+	files := []string{path + ".2", path + ".1", path}
+	var all string
+	for _, p := range files {
+		s := readFile(t, p)
+		if !strings.HasSuffix(s, "\n") || int64(len(s))%lineSize != 0 {
+			t.Fatalf("expected whole lines in '%s', got: '%s'", p, s)
+		}
+		all += s
+	}
+	for i := 0; i < 7; i++ {
+		if !strings.Contains(all, fmt.Sprintf("%s line %d\n", ts, i)) {
+			t.Fatalf("expected line %d to be captured, got: '%s'", i, all)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package logging implements log destinations for logrus that are not provided
+// by logrus itself.
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an `io.WriteCloser` that writes to a file and rotates it
+// once it reaches a maximum size.
+//
+// When `path` is rotated it is renamed to `path.1`, `path.1` is renamed to
+// `path.2` and so on. Only `maxFiles` rotated files are retained, older files
+// are removed.
+type RotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	m    sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenRotatingFile opens or creates the file at `path` for appending. The file
+// is rotated before a write that would make it larger than `maxSize` bytes.
+// If `maxSize <= 0` the file is never rotated.
+func OpenRotatingFile(path string, maxSize int64, maxFiles int) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+	if err := rf.open(os.O_APPEND); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// open opens `rf.path` with `flag` in addition to create and write only. The
+// caller MUST hold `rf.m` or be the constructor.
+func (rf *RotatingFile) open(flag int) error {
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|flag, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f = f
+	rf.size = fi.Size()
+	return nil
+}
+
+// rotatedPath returns the path of the `n`th most recently rotated file.
+func (rf *RotatingFile) rotatedPath(n int) string {
+	return fmt.Sprintf("%s.%d", rf.path, n)
+}
+
+// rotate closes the current file, shifts the rotated files and opens a new
+// empty file. The caller MUST hold `rf.m`.
+func (rf *RotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	rf.f = nil
+	if rf.maxFiles <= 0 {
+		if err := os.Remove(rf.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		if err := os.Remove(rf.rotatedPath(rf.maxFiles)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for i := rf.maxFiles - 1; i > 0; i-- {
+			if err := os.Rename(rf.rotatedPath(i), rf.rotatedPath(i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(rf.path, rf.rotatedPath(1)); err != nil {
+			return err
+		}
+	}
+	return rf.open(os.O_TRUNC)
+}
+
+// Write writes `p` to the file rotating it first if `p` would make the file
+// larger than its maximum size. `p` is never split across files.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.m.Lock()
+	defer rf.m.Unlock()
+
+	if rf.f == nil {
+		// A previous rotation failed to reopen the file. Try again.
+		if err := rf.open(os.O_APPEND); err != nil {
+			return 0, err
+		}
+	}
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Close closes the file. Writes after `Close` reopen the file.
+func (rf *RotatingFile) Close() error {
+	rf.m.Lock()
+	defer rf.m.Unlock()
+
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
@@ -0,0 +1,302 @@
+package logio
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// binaryIOReadyTimeout is the time the logging binary has to connect to
+	// its streams and signal that it is ready.
+	binaryIOReadyTimeout = 10 * time.Second
+	// binaryIOExitTimeout is the time the logging binary has to exit after
+	// its streams are closed before it is killed.
+	binaryIOExitTimeout = 10 * time.Second
+)
+
+// NewBinary creates the io for task/exec `tid,eid` in the containerd namespace
+// `ns` that is consumed by the logging binary at the path of `u` using the
+// containerd logging protocol. `sin` is the already connected `stdin` opened
+// from `stdin` if any.
+//
+// The binary is started with the query parameters of `u` as arguments and the
+// following environment:
+//
+// CONTAINER_ID - The id of the task.
+//
+// CONTAINER_NAMESPACE - The containerd namespace of the task.
+//
+// CONTAINER_STDOUT - The address to connect to for `stdout`.
+//
+// CONTAINER_STDERR - The address to connect to for `stderr`. Not set if
+// `terminal == true`.
+//
+// CONTAINER_WAIT - The address to connect to and then close once the binary
+// is ready to consume the streams.
+//
+// Each address is created by calling `listen` with the stream name.
+func NewBinary(ctx context.Context, tid, eid, ns string, u *url.URL, stdin string, sin io.ReadCloser, terminal bool, listen func(stream string) (net.Listener, error)) (_ *BinaryIO, err error) {
+	logrus.WithFields(logrus.Fields{
+		"tid":      tid,
+		"eid":      eid,
+		"stdin":    stdin,
+		"binary":   Path(u),
+		"terminal": terminal,
+	}).Debug("binaryio::New")
+
+	bio := &BinaryIO{
+		tid:      tid,
+		eid:      eid,
+		stdin:    stdin,
+		stdout:   u.String(),
+		terminal: terminal,
+		sin:      sin,
+	}
+	if !terminal {
+		bio.stderr = bio.stdout
+	}
+
+	streams := []string{"stdout", "stderr", "wait"}
+	if terminal {
+		streams = []string{"stdout", "wait"}
+	}
+	listeners := make(map[string]net.Listener)
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	for _, s := range streams {
+		l, err := listen(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to listen for log binary %s", s)
+		}
+		listeners[s] = l
+	}
+
+	cmd := newBinaryCmd(u, tid, ns)
+	cmd.Env = append(cmd.Env, "CONTAINER_STDOUT="+listeners["stdout"].Addr().String())
+	if !terminal {
+		cmd.Env = append(cmd.Env, "CONTAINER_STDERR="+listeners["stderr"].Addr().String())
+	}
+	cmd.Env = append(cmd.Env, "CONTAINER_WAIT="+listeners["wait"].Addr().String())
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrapf(err, "failed to start log binary '%s'", cmd.Path)
+	}
+	bio.cmd = cmd
+	bio.exited = make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(bio.exited)
+	}()
+	defer func() {
+		if err != nil {
+			bio.Close()
+		}
+	}()
+
+	conns, err := acceptBinaryStreams(listeners, bio.exited)
+	if err != nil {
+		return nil, err
+	}
+	bio.sout = conns["stdout"]
+	bio.serr = conns["stderr"]
+
+	// The binary signals it is ready by closing the wait connection.
+	wait := conns["wait"]
+	wait.SetReadDeadline(time.Now().Add(binaryIOReadyTimeout))
+	_, err = io.Copy(ioutil.Discard, wait)
+	wait.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed waiting for log binary to be ready")
+	}
+	return bio, nil
+}
+
+// newBinaryCmd creates the command for the logging binary at the path of `u`
+// for task `tid` in namespace `ns`. Each query parameter of `u` is passed as
+// an argument followed by its first value if not empty, in key order.
+func newBinaryCmd(u *url.URL, tid, ns string) *exec.Cmd {
+	q := u.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var args []string
+	for _, k := range keys {
+		args = append(args, k)
+		if v := q.Get(k); v != "" {
+			args = append(args, v)
+		}
+	}
+	cmd := exec.Command(Path(u), args...)
+	cmd.Env = append(os.Environ(),
+		"CONTAINER_ID="+tid,
+		"CONTAINER_NAMESPACE="+ns,
+	)
+	return cmd
+}
+
+// acceptBinaryStreams accepts a single connection on each of `listeners`. It
+// fails if all connections are not made within `binaryIOReadyTimeout` or if
+// `exited` is closed first.
+func acceptBinaryStreams(listeners map[string]net.Listener, exited <-chan struct{}) (map[string]net.Conn, error) {
+	type result struct {
+		stream string
+		c      net.Conn
+		err    error
+	}
+	results := make(chan result, len(listeners))
+	for s, l := range listeners {
+		go func(s string, l net.Listener) {
+			c, err := l.Accept()
+			results <- result{s, c, err}
+		}(s, l)
+	}
+
+	conns := make(map[string]net.Conn)
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+		for _, c := range conns {
+			c.Close()
+		}
+	}
+	timer := time.NewTimer(binaryIOReadyTimeout)
+	defer timer.Stop()
+	for len(conns) < len(listeners) {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				closeAll()
+				return nil, errors.Wrapf(r.err, "failed to accept log binary %s", r.stream)
+			}
+			conns[r.stream] = r.c
+		case <-exited:
+			closeAll()
+			return nil, errors.New("log binary exited before connecting")
+		case <-timer.C:
+			closeAll()
+			return nil, errors.New("timed out waiting for log binary to connect")
+		}
+	}
+	return conns, nil
+}
+
+// BinaryIO is the io of a task/exec that is consumed by a logging binary.
+type BinaryIO struct {
+	// tid, eid are the task and exec id's associated with this binary io.
+	tid, eid string
+	// stdin, stdout, stderr are the original paths used to open the io.
+	//
+	// They MUST be treated as readonly in the lifetime of the binary io.
+	stdin, stdout, stderr string
+	// terminal is the original setting passed in on open.
+	//
+	// This MUST be treated as readonly in the lifetime of the binary io.
+	terminal bool
+
+	// cmd is the running logging binary and `exited` is closed once it has
+	// exited.
+	cmd    *exec.Cmd
+	exited chan struct{}
+
+	// sin is the upstream `stdin` connection.
+	//
+	// `sin` MUST be treated as readonly in the lifetime of the binary io after
+	// the return from `NewBinary`.
+	sin       io.ReadCloser
+	sinCloser sync.Once
+
+	// sout and serr are the connections to the logging binary.
+	//
+	// `sout` and `serr` MUST be treated as readonly in the lifetime of the
+	// binary io after the return from `NewBinary`.
+	sout, serr   net.Conn
+	outErrCloser sync.Once
+}
+
+// Close closes the streams to the logging binary and waits for it to exit,
+// killing it if it does not exit within `binaryIOExitTimeout`.
+func (bio *BinaryIO) Close() {
+	logrus.WithFields(logrus.Fields{
+		"tid": bio.tid,
+		"eid": bio.eid,
+	}).Debug("binaryio::Close")
+
+	bio.CloseStdin()
+	bio.outErrCloser.Do(func() {
+		if bio.sout != nil {
+			bio.sout.Close()
+		}
+		if bio.serr != nil {
+			bio.serr.Close()
+		}
+		if bio.cmd == nil {
+			return
+		}
+		select {
+		case <-bio.exited:
+		case <-time.After(binaryIOExitTimeout):
+			logrus.WithFields(logrus.Fields{
+				"tid": bio.tid,
+				"eid": bio.eid,
+			}).Warn("binaryio::Close - log binary did not exit, killing")
+			bio.cmd.Process.Kill()
+			<-bio.exited
+		}
+	})
+}
+
+func (bio *BinaryIO) CloseStdin() {
+	bio.sinCloser.Do(func() {
+		if bio.sin != nil {
+			bio.sin.Close()
+		}
+	})
+}
+
+func (bio *BinaryIO) Stdin() io.Reader {
+	return bio.sin
+}
+
+func (bio *BinaryIO) StdinPath() string {
+	return bio.stdin
+}
+
+func (bio *BinaryIO) Stdout() io.Writer {
+	return bio.sout
+}
+
+func (bio *BinaryIO) StdoutPath() string {
+	return bio.stdout
+}
+
+func (bio *BinaryIO) Stderr() io.Writer {
+	if bio.serr == nil {
+		return nil
+	}
+	return bio.serr
+}
+
+func (bio *BinaryIO) StderrPath() string {
+	return bio.stderr
+}
+
+func (bio *BinaryIO) Terminal() bool {
+	return bio.terminal
+}
@@ -0,0 +1,114 @@
+package logio
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_newBinaryCmd(t *testing.T) {
+	u, err := url.Parse("binary:///C:/bin/logger.exe?--level=debug&--verbose&--id=abc")
+	if err != nil {
+		t.Fatalf("failed to parse uri: %v", err)
+	}
+	cmd := newBinaryCmd(u, t.Name(), "k8s.io")
+	expected := []string{"C:/bin/logger.exe", "--id", "abc", "--level", "debug", "--verbose"}
+	if strings.Join(cmd.Args, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected args: %v, got: %v", expected, cmd.Args)
+	}
+	var id, ns bool
+	for _, e := range cmd.Env {
+		id = id || e == "CONTAINER_ID="+t.Name()
+		ns = ns || e == "CONTAINER_NAMESPACE=k8s.io"
+	}
+	if !id || !ns {
+		t.Fatalf("expected CONTAINER_ID and CONTAINER_NAMESPACE in env")
+	}
+}
+
+// Test_BinaryIO_HelperProcess is not a real test. It is run as the logging
+// binary by the binary io tests and copies `stdout` to `BINARYIO_TEST_OUT`.
+func Test_BinaryIO_HelperProcess(t *testing.T) {
+	out := os.Getenv("BINARYIO_TEST_OUT")
+	if out == "" {
+		return
+	}
+	defer os.Exit(0)
+
+	sout, err := net.Dial("tcp", os.Getenv("CONTAINER_STDOUT"))
+	if err != nil {
+		os.Exit(1)
+	}
+	wait, err := net.Dial("tcp", os.Getenv("CONTAINER_WAIT"))
+	if err != nil {
+		os.Exit(1)
+	}
+	wait.Close()
+	f, err := os.Create(out)
+	if err != nil {
+		os.Exit(1)
+	}
+	io.Copy(f, sout)
+	f.Close()
+}
+
+func tcpLogListener(stream string) (net.Listener, error) {
+	return net.Listen("tcp", "127.0.0.1:0")
+}
+
+// helperURI returns the uri of the test binary running `run` as the logging
+// binary.
+func helperURI(run string) *url.URL {
+	u := &url.URL{
+		Scheme:   "binary",
+		Path:     filepath.ToSlash(os.Args[0]),
+		RawQuery: "-test.run=" + run,
+	}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+	return u
+}
+
+func Test_NewBinary_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out.log")
+	os.Setenv("BINARYIO_TEST_OUT", out)
+	defer os.Unsetenv("BINARYIO_TEST_OUT")
+
+	bio, err := NewBinary(context.TODO(), t.Name(), t.Name(), "default", helperURI("Test_BinaryIO_HelperProcess"), "", nil, true, tcpLogListener)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if !bio.Terminal() || bio.Stderr() != nil {
+		t.Fatal("expected terminal io without stderr")
+	}
+	io.WriteString(bio.Stdout(), "hello")
+	bio.Close()
+
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("unexpected output: '%s'", b)
+	}
+}
+
+func Test_NewBinary_BinaryExits_Error(t *testing.T) {
+	_, err := NewBinary(context.TODO(), t.Name(), t.Name(), "default", helperURI("^$"), "", nil, false, tcpLogListener)
+	if err == nil {
+		t.Fatal("expected error when the log binary exits before connecting")
+	}
+}
@@ -0,0 +1,140 @@
+package logio
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NewFile creates the io for task/exec `tid,eid` that appends `stdout` and,
+// unless `terminal`, `stderr` to the file at the path of `u`. `sin` is the
+// already connected `stdin` opened from `stdin` if any.
+func NewFile(ctx context.Context, tid, eid string, u *url.URL, stdin string, sin io.ReadCloser, terminal bool) (_ *FileIO, err error) {
+	path := Path(u)
+	logrus.WithFields(logrus.Fields{
+		"tid":      tid,
+		"eid":      eid,
+		"stdin":    stdin,
+		"path":     path,
+		"terminal": terminal,
+	}).Debug("fileio::New")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fio := &FileIO{
+		tid:      tid,
+		eid:      eid,
+		stdin:    stdin,
+		stdout:   u.String(),
+		terminal: terminal,
+		sin:      sin,
+		f:        f,
+	}
+	if !terminal {
+		fio.stderr = fio.stdout
+	}
+	return fio, nil
+}
+
+// FileIO is the io of a task/exec that is written to a log file.
+type FileIO struct {
+	// tid, eid are the task and exec id's associated with this file io.
+	tid, eid string
+	// stdin, stdout, stderr are the original paths used to open the io.
+	//
+	// They MUST be treated as readonly in the lifetime of the file io.
+	stdin, stdout, stderr string
+	// terminal is the original setting passed in on open.
+	//
+	// This MUST be treated as readonly in the lifetime of the file io.
+	terminal bool
+
+	// sin is the upstream `stdin` connection.
+	//
+	// `sin` MUST be treated as readonly in the lifetime of the file io after
+	// the return from `NewFile`.
+	sin       io.ReadCloser
+	sinCloser sync.Once
+
+	// wl serializes writes to `f` from `stdout` and `stderr`.
+	wl sync.Mutex
+	// f is the log file shared by `stdout` and `stderr`.
+	f       *os.File
+	fCloser sync.Once
+}
+
+func (fio *FileIO) Close() {
+	logrus.WithFields(logrus.Fields{
+		"tid": fio.tid,
+		"eid": fio.eid,
+	}).Debug("fileio::Close")
+
+	fio.CloseStdin()
+	fio.fCloser.Do(func() {
+		fio.wl.Lock()
+		defer fio.wl.Unlock()
+
+		fio.f.Close()
+	})
+}
+
+func (fio *FileIO) CloseStdin() {
+	fio.sinCloser.Do(func() {
+		if fio.sin != nil {
+			fio.sin.Close()
+		}
+	})
+}
+
+func (fio *FileIO) Stdin() io.Reader {
+	return fio.sin
+}
+
+func (fio *FileIO) StdinPath() string {
+	return fio.stdin
+}
+
+func (fio *FileIO) Stdout() io.Writer {
+	return fileioWriter{fio}
+}
+
+func (fio *FileIO) StdoutPath() string {
+	return fio.stdout
+}
+
+func (fio *FileIO) Stderr() io.Writer {
+	if fio.terminal {
+		return nil
+	}
+	return fileioWriter{fio}
+}
+
+func (fio *FileIO) StderrPath() string {
+	return fio.stderr
+}
+
+func (fio *FileIO) Terminal() bool {
+	return fio.terminal
+}
+
+// fileioWriter writes to the shared log file of a `FileIO`.
+type fileioWriter struct {
+	fio *FileIO
+}
+
+func (w fileioWriter) Write(p []byte) (int, error) {
+	w.fio.wl.Lock()
+	defer w.fio.wl.Unlock()
+
+	return w.fio.f.Write(p)
+}
@@ -0,0 +1,61 @@
+package logio
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_NewFile_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	u := &url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, "logs", "task.log"))}
+	fio, err := NewFile(context.TODO(), t.Name(), t.Name(), u, "", nil, false)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if fio.StdoutPath() != u.String() || fio.StderrPath() != u.String() {
+		t.Fatalf("expected stdout and stderr path: '%s', got: '%s', '%s'", u, fio.StdoutPath(), fio.StderrPath())
+	}
+	if fio.Stdin() != nil {
+		t.Fatal("expected nil stdin")
+	}
+	io.WriteString(fio.Stdout(), "out\n")
+	io.WriteString(fio.Stderr(), "err\n")
+	fio.Close()
+	fio.Close()
+
+	b, err := ioutil.ReadFile(Path(u))
+	if err != nil {
+		t.Fatalf("failed to read log: %v", err)
+	}
+	if string(b) != "out\nerr\n" {
+		t.Fatalf("unexpected log contents: '%s'", b)
+	}
+}
+
+func Test_NewFile_Terminal_NoStderr(t *testing.T) {
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	u := &url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, "task.log"))}
+	fio, err := NewFile(context.TODO(), t.Name(), t.Name(), u, "", nil, true)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	defer fio.Close()
+	if !fio.Terminal() || fio.Stderr() != nil || fio.StderrPath() != "" {
+		t.Fatal("expected terminal io without stderr")
+	}
+}
@@ -0,0 +1,44 @@
+// Package logio connects the stdio of a task to a `binary://` or `file://` log
+// uri instead of a named pipe.
+//
+// The package has no platform dependencies.
+package logio
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/pkg/errors"
+)
+
+// ParseURI parses `stdout` as a `binary://` or `file://` log uri. If `stdout`
+// is not a log uri returns `ok == false`. `stderr` MUST be empty or match
+// `stdout` when it is a log uri.
+func ParseURI(stdout, stderr string) (_ *url.URL, ok bool, err error) {
+	if !strings.HasPrefix(stdout, "binary://") && !strings.HasPrefix(stdout, "file://") {
+		return nil, false, nil
+	}
+	u, err := url.Parse(stdout)
+	if err != nil {
+		return nil, false, errors.Wrapf(errdefs.ErrInvalidArgument, "failed to parse log uri: '%s': %v", stdout, err)
+	}
+	if Path(u) == "" {
+		return nil, false, errors.Wrapf(errdefs.ErrInvalidArgument, "log uri: '%s' must include a path", stdout)
+	}
+	if stderr != "" && stderr != stdout {
+		return nil, false, errors.Wrapf(errdefs.ErrInvalidArgument, "stderr: '%s' must match stdout: '%s' when using a log uri", stderr, stdout)
+	}
+	return u, true, nil
+}
+
+// Path returns the local path of `u`. A Windows drive letter may be given
+// either as the host `binary://C:/path` or as the first path element
+// `binary:///C:/path`.
+func Path(u *url.URL) string {
+	p := u.Host + u.Path
+	if len(p) >= 3 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return p
+}
@@ -0,0 +1,52 @@
+package logio
+
+import (
+	"testing"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/pkg/errors"
+)
+
+func Test_ParseURI_NotURI(t *testing.T) {
+	for _, p := range []string{"", `\\.\pipe\stdout`, "fifo:///tmp/stdout"} {
+		_, ok, err := ParseURI(p, "")
+		if err != nil {
+			t.Fatalf("'%s' should not have failed, got: %v", p, err)
+		}
+		if ok {
+			t.Fatalf("'%s' should not be a log uri", p)
+		}
+	}
+}
+
+func Test_ParseURI_NoPath_Error(t *testing.T) {
+	_, _, err := ParseURI("file://", "")
+	if errors.Cause(err) != errdefs.ErrInvalidArgument {
+		t.Fatalf("expected error: %v, got: %v", errdefs.ErrInvalidArgument, err)
+	}
+}
+
+func Test_ParseURI_StderrMismatch_Error(t *testing.T) {
+	_, _, err := ParseURI("file:///C:/a.log", "file:///C:/b.log")
+	if errors.Cause(err) != errdefs.ErrInvalidArgument {
+		t.Fatalf("expected error: %v, got: %v", errdefs.ErrInvalidArgument, err)
+	}
+}
+
+func Test_Path(t *testing.T) {
+	tests := map[string]string{
+		"file:///C:/logs/task.log":    "C:/logs/task.log",
+		"file://C:/logs/task.log":     "C:/logs/task.log",
+		"binary:///C:/bin/logger.exe": "C:/bin/logger.exe",
+		"file:///var/log/task.log":    "/var/log/task.log",
+	}
+	for uri, expected := range tests {
+		u, ok, err := ParseURI(uri, uri)
+		if err != nil || !ok {
+			t.Fatalf("'%s' should be a log uri, got: %v", uri, err)
+		}
+		if actual := Path(u); actual != expected {
+			t.Fatalf("'%s' expected path: '%s', got: '%s'", uri, expected, actual)
+		}
+	}
+}
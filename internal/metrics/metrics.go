@@ -0,0 +1,378 @@
+// Package metrics implements counters, gauges and histograms that are written
+// in the Prometheus text exposition format.
+//
+// Every metric is registered with a `Registry` under a unique name and a fixed
+// set of label names. A value is kept for each distinct set of label values
+// the metric is updated with.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram bucket upper bounds, in seconds, suitable
+// for the latency of most operations.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ExponentialBuckets returns `count` bucket upper bounds where the first is
+// `start` and each subsequent bound is `factor` times the previous.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	b := make([]float64, count)
+	for i := range b {
+		b[i] = start
+		start *= factor
+	}
+	return b
+}
+
+// metric is implemented by every type of metric in a `Registry`.
+type metric interface {
+	desc() *desc
+	// write writes the samples of the metric in the text exposition format.
+	write(w *bufio.Writer)
+}
+
+// Registry is a set of metrics that are written together.
+type Registry struct {
+	m       sync.Mutex
+	names   map[string]struct{}
+	metrics []metric
+	hooks   []func()
+}
+
+// NewRegistry creates an empty `Registry`.
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]struct{}),
+	}
+}
+
+func (r *Registry) register(m metric) {
+	d := m.desc()
+	r.m.Lock()
+	defer r.m.Unlock()
+	if _, ok := r.names[d.name]; ok {
+		panic(fmt.Sprintf("metrics: duplicate metric name '%s'", d.name))
+	}
+	r.names[d.name] = struct{}{}
+	r.metrics = append(r.metrics, m)
+}
+
+// OnCollect adds `f` to the functions called before the metrics are written.
+// It is used to update gauges whose value is only known by querying their
+// source.
+func (r *Registry) OnCollect(f func()) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.hooks = append(r.hooks, f)
+}
+
+// NewCounter registers a counter named `name` with the label names `labels`.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{d: newDesc(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// NewGauge registers a gauge named `name` with the label names `labels`.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{d: newDesc(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// NewHistogram registers a histogram named `name` with the label names
+// `labels`. `buckets` are the upper bounds of the buckets in increasing order.
+// The `+Inf` bucket is always added.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: buckets of '%s' are not in increasing order", name))
+	}
+	h := &Histogram{
+		d:       newDesc(name, help, "histogram", labels),
+		buckets: append([]float64(nil), buckets...),
+	}
+	r.register(h)
+	return h
+}
+
+// WriteText calls the `OnCollect` functions and then writes every metric in
+// the order it was registered in the Prometheus text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.m.Lock()
+	hooks := append([]func(){}, r.hooks...)
+	metrics := append([]metric{}, r.metrics...)
+	r.m.Unlock()
+
+	for _, f := range hooks {
+		f()
+	}
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		d := m.desc()
+		fmt.Fprintf(bw, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", d.name, d.typ)
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP writes the metrics in `r` as the response to any request.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteText(w)
+}
+
+// desc describes a metric and holds its series.
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	m      sync.Mutex
+	series map[string]*series
+}
+
+// series is the value of a metric for a single set of label values.
+type series struct {
+	labelValues []string
+	value       float64
+	// bucketCounts and count are only used by histograms.
+	bucketCounts []uint64
+	count        uint64
+}
+
+func newDesc(name, help, typ string, labels []string) *desc {
+	return &desc{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: append([]string(nil), labels...),
+		series: make(map[string]*series),
+	}
+}
+
+func (d *desc) desc() *desc {
+	return d
+}
+
+// get returns the series for `labelValues` creating it if required. The caller
+// MUST hold `d.m`.
+func (d *desc) get(labelValues []string) *series {
+	if len(labelValues) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: '%s' expects %d label values, got %d", d.name, len(d.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := d.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		d.series[key] = s
+	}
+	return s
+}
+
+// sorted returns the series ordered by their label values. The caller MUST
+// hold `d.m`.
+func (d *desc) sorted() []*series {
+	keys := make([]string, 0, len(d.series))
+	for k := range d.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	s := make([]*series, len(keys))
+	for i, k := range keys {
+		s[i] = d.series[k]
+	}
+	return s
+}
+
+// value returns the value of the series for `labelValues` or `0` if it has
+// never been updated.
+func (d *desc) value(labelValues []string) float64 {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if s, ok := d.series[strings.Join(labelValues, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// formatLabels formats `labelValues` as a label set. `extra` is a label name
+// and value pair appended to the set if not empty.
+func (d *desc) formatLabels(labelValues []string, extra ...string) string {
+	if len(labelValues) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, v := range labelValues {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", d.labels[i], escapeLabelValue(v))
+	}
+	if len(extra) == 2 {
+		if len(labelValues) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extra[0], escapeLabelValue(extra[1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func (d *desc) writeValues(w *bufio.Writer) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	for _, s := range d.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", d.name, d.formatLabels(s.labelValues), formatFloat(s.value))
+	}
+}
+
+// Counter is a metric whose value only increases.
+type Counter struct {
+	d *desc
+}
+
+func (c *Counter) desc() *desc { return c.d }
+
+func (c *Counter) write(w *bufio.Writer) { c.d.writeValues(w) }
+
+// Inc adds 1 to the counter for `labelValues`.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds `v` to the counter for `labelValues`. `v` MUST not be negative.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter '%s' cannot decrease", c.d.name))
+	}
+	c.d.m.Lock()
+	defer c.d.m.Unlock()
+	c.d.get(labelValues).value += v
+}
+
+// Value returns the value of the counter for `labelValues`.
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.d.value(labelValues)
+}
+
+// Gauge is a metric whose value can be set to anything.
+type Gauge struct {
+	d *desc
+}
+
+func (g *Gauge) desc() *desc { return g.d }
+
+func (g *Gauge) write(w *bufio.Writer) { g.d.writeValues(w) }
+
+// Set sets the gauge for `labelValues` to `v`.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.d.m.Lock()
+	defer g.d.m.Unlock()
+	g.d.get(labelValues).value = v
+}
+
+// Add adds `v` to the gauge for `labelValues`.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.d.m.Lock()
+	defer g.d.m.Unlock()
+	g.d.get(labelValues).value += v
+}
+
+// Value returns the value of the gauge for `labelValues`.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.d.value(labelValues)
+}
+
+// Reset removes the values for all label values so that only those set
+// afterwards are written.
+func (g *Gauge) Reset() {
+	g.d.m.Lock()
+	defer g.d.m.Unlock()
+	g.d.series = make(map[string]*series)
+}
+
+// Histogram is a metric that counts observations in buckets.
+type Histogram struct {
+	d       *desc
+	buckets []float64
+}
+
+func (h *Histogram) desc() *desc { return h.d }
+
+// Observe adds the observation `v` to the histogram for `labelValues`.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.d.m.Lock()
+	defer h.d.m.Unlock()
+	s := h.d.get(labelValues)
+	if s.bucketCounts == nil {
+		s.bucketCounts = make([]uint64, len(h.buckets))
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.bucketCounts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+// Count returns the number of observations in the histogram for
+// `labelValues`.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.d.m.Lock()
+	defer h.d.m.Unlock()
+	if s, ok := h.d.series[strings.Join(labelValues, "\xff")]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.d.m.Lock()
+	defer h.d.m.Unlock()
+	for _, s := range h.d.sorted() {
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.d.name, h.d.formatLabels(s.labelValues, "le", formatFloat(b)), s.bucketCounts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.d.name, h.d.formatLabels(s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.d.name, h.d.formatLabels(s.labelValues), formatFloat(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.d.name, h.d.formatLabels(s.labelValues), s.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func writeText(t *testing.T, r *Registry) string {
+	var b bytes.Buffer
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	return b.String()
+}
+
+func Test_Counter_WriteText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_total", "A test counter.", "op", "result")
+	c.Inc("start", "success")
+	c.Add(2, "start", "success")
+	c.Inc("kill", "error")
+
+	expected := `# HELP test_total A test counter.
+# TYPE test_total counter
+test_total{op="kill",result="error"} 1
+test_total{op="start",result="success"} 3
+`
+	if actual := writeText(t, r); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+	if v := c.Value("start", "success"); v != 3 {
+		t.Fatalf("expected value: 3, got: %v", v)
+	}
+}
+
+func Test_Counter_Add_Negative_Panics(t *testing.T) {
+	c := NewRegistry().NewCounter("test_total", "")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	c.Add(-1)
+}
+
+func Test_Counter_WrongLabelCount_Panics(t *testing.T) {
+	c := NewRegistry().NewCounter("test_total", "", "op")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	c.Inc()
+}
+
+func Test_Registry_DuplicateName_Panics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test", "")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	r.NewGauge("test", "")
+}
+
+func Test_Gauge_Reset_OnCollect(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGauge("test_devices", "Help with \\ and\nnewline.", "uvm")
+	g.Set(5, "stale")
+	r.OnCollect(func() {
+		g.Reset()
+		g.Set(1, `vm"1`)
+		g.Add(2, `vm"1`)
+	})
+
+	expected := `# HELP test_devices Help with \\ and\nnewline.
+# TYPE test_devices gauge
+test_devices{uvm="vm\"1"} 3
+`
+	if actual := writeText(t, r); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func Test_Histogram_WriteText(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("test_seconds", "A test histogram.", []float64{1, 2.5})
+	h.Observe(0.5)
+	h.Observe(2)
+	h.Observe(10)
+
+	expected := `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="1"} 1
+test_seconds_bucket{le="2.5"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 12.5
+test_seconds_count 3
+`
+	if actual := writeText(t, r); actual != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+	if c := h.Count(); c != 3 {
+		t.Fatalf("expected count: 3, got: %d", c)
+	}
+}
+
+func Test_Histogram_Labels_WriteText(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("test_seconds", "", []float64{1}, "os")
+	h.Observe(3, "linux")
+
+	actual := writeText(t, r)
+	for _, line := range []string{
+		`test_seconds_bucket{os="linux",le="1"} 0`,
+		`test_seconds_bucket{os="linux",le="+Inf"} 1`,
+		`test_seconds_sum{os="linux"} 3`,
+		`test_seconds_count{os="linux"} 1`,
+	} {
+		if !strings.Contains(actual, line+"\n") {
+			t.Fatalf("expected line: '%s', got:\n%s", line, actual)
+		}
+	}
+}
+
+func Test_Registry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "").Inc()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("expected text exposition content type, got: '%s'", ct)
+	}
+	if !strings.Contains(w.Body.String(), "test_total 1\n") {
+		t.Fatalf("expected 'test_total 1', got:\n%s", w.Body.String())
+	}
+}
+
+func Test_ExponentialBuckets(t *testing.T) {
+	b := ExponentialBuckets(0.5, 2, 4)
+	if len(b) != 4 || b[0] != 0.5 || b[3] != 4 {
+		t.Fatalf("expected [0.5 1 2 4], got: %v", b)
+	}
+}
@@ -0,0 +1,15 @@
+package oci
+
+import "time"
+
+// AnnotationExecTimeoutInSeconds sets the number of seconds a process may run
+// before it is killed. It is read from the annotations of the container spec
+// for the init process and from the `annotations` member of the process spec
+// for an exec.
+const AnnotationExecTimeoutInSeconds = "io.microsoft.exec.timeoutinseconds"
+
+// ParseAnnotationsExecTimeout searches `a` for the exec timeout annotation. If
+// not found or `0` returns `0` which means the process has no timeout.
+func ParseAnnotationsExecTimeout(a map[string]string) time.Duration {
+	return time.Duration(parseAnnotationsUint32(a, AnnotationExecTimeoutInSeconds, 0)) * time.Second
+}
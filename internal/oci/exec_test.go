@@ -0,0 +1,18 @@
+package oci
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ParseAnnotationsExecTimeout(t *testing.T) {
+	if d := ParseAnnotationsExecTimeout(nil); d != 0 {
+		t.Fatalf("expected no timeout, got: %v", d)
+	}
+	if d := ParseAnnotationsExecTimeout(map[string]string{AnnotationExecTimeoutInSeconds: "30"}); d != 30*time.Second {
+		t.Fatalf("expected timeout: 30s, got: %v", d)
+	}
+	if d := ParseAnnotationsExecTimeout(map[string]string{AnnotationExecTimeoutInSeconds: "-1"}); d != 0 {
+		t.Fatalf("expected invalid value to be ignored, got: %v", d)
+	}
+}
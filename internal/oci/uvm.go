@@ -1,12 +1,17 @@
 package oci
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/gcsoutput"
 	"github.com/Microsoft/hcsshim/internal/logfields"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
 	"github.com/Microsoft/hcsshim/internal/uvm"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
@@ -106,15 +111,97 @@ const (
 	//
 	// Note: Unlike Windows process isolated container QoS Count/Limt/Weight on
 	// the UVM are not mutually exclusive and can be set together.
-	annotationProcessorWeight            = "io.microsoft.virtualmachine.computetopology.processor.weight"
+	annotationProcessorWeight = "io.microsoft.virtualmachine.computetopology.processor.weight"
+	// annotationEnableLargePages backs the memory of the utility VM with large
+	// pages. Requires physically backed memory.
+	annotationEnableLargePages = "io.microsoft.virtualmachine.computetopology.memory.enablelargepages"
+	// annotationNumaNodeCount sets the number of virtual NUMA nodes of the
+	// utility VM.
+	annotationNumaNodeCount = "io.microsoft.virtualmachine.computetopology.numa.nodecount"
+	// annotationNumaPreferredPhysicalNodes is a comma separated list of the
+	// host NUMA nodes that the virtual NUMA nodes are preferably placed on.
+	annotationNumaPreferredPhysicalNodes = "io.microsoft.virtualmachine.computetopology.numa.preferredphysicalnodes"
+	// annotationCPUGroupID restricts the vCPUs of the utility VM to the
+	// processors of an existing host CPU group.
+	annotationCPUGroupID = "io.microsoft.virtualmachine.computetopology.processor.cpugroupid"
+	// annotationExposeVirtualizationExtensions exposes the virtualization
+	// extensions of the host processors to the utility VM.
+	annotationExposeVirtualizationExtensions = "io.microsoft.virtualmachine.computetopology.processor.exposevirtualizationextensions"
+	// annotationMemoryMaximumInMB is the memory size in MB that the utility VM
+	// of the pod is grown to as containers that request memory are added. It
+	// overrides the runtime option.
+	annotationMemoryMaximumInMB          = "io.microsoft.virtualmachine.computetopology.memory.maximuminmb"
 	annotationVPMemCount                 = "io.microsoft.virtualmachine.devices.virtualpmem.maximumcount"
 	annotationVPMemSize                  = "io.microsoft.virtualmachine.devices.virtualpmem.maximumsizebytes"
+	annotationVPMemNoMultiMapping        = "io.microsoft.virtualmachine.devices.virtualpmem.nomultimapping"
+	annotationSCSIControllerCount        = "io.microsoft.virtualmachine.devices.scsi.controllercount"
 	annotationPreferredRootFSType        = "io.microsoft.virtualmachine.lcow.preferredrootfstype"
 	annotationBootFilesRootPath          = "io.microsoft.virtualmachine.lcow.bootfilesrootpath"
+	annotationKernelBootOptions          = "io.microsoft.virtualmachine.lcow.kernelbootoptions"
 	annotationStorageQoSBandwidthMaximum = "io.microsoft.virtualmachine.storageqos.bandwidthmaximum"
 	annotationStorageQoSIopsMaximum      = "io.microsoft.virtualmachine.storageqos.iopsmaximum"
+	// annotationClone creates the Windows utility VM of a pod as a clone of a
+	// saved template utility VM with the same settings instead of booting it.
+	// The template is saved the first time it is needed.
+	annotationClone = "io.microsoft.virtualmachine.wcow.clone"
+	// annotationOutputHandler selects the handlers of the output forwarded
+	// from the Linux utility VM of a pod, see `gcsoutput.Parse`. The `file:`
+	// handlers write in the log directory of the runtime options and are not
+	// allowed without one.
+	annotationOutputHandler = "io.microsoft.virtualmachine.lcow.outputhandler"
+	// annotationOutputLevels remaps the levels of the guest log entries, see
+	// `gcsoutput.ParseLevels`.
+	annotationOutputLevels = "io.microsoft.virtualmachine.lcow.outputhandler.levels"
+	// annotationOutputDropFields is a comma separated list of the fields that
+	// are removed from the guest log entries.
+	annotationOutputDropFields = "io.microsoft.virtualmachine.lcow.outputhandler.dropfields"
 )
 
+// ParseAnnotationsClone searches `a` for the clone annotation. If not found
+// returns `false`.
+func ParseAnnotationsClone(a map[string]string) bool {
+	return parseAnnotationsBool(a, annotationClone, false)
+}
+
+// crashDumpPath returns the directory that the guest crashes of the utility VM
+// `id` are collected in. Returns "" if crash collection is not enabled by the
+// runtime options `opts`.
+//
+// The host directories are only taken from the runtime options as a pod spec
+// may not choose where the host writes.
+func crashDumpPath(id string, opts *runhcsopts.Options) string {
+	if opts == nil || opts.CrashDumpRoot == "" {
+		return ""
+	}
+	return filepath.Join(opts.CrashDumpRoot, id)
+}
+
+// hvSocketServices returns the additional HvSocket services registered on the
+// utility VM. Returns `nil` if the runtime options `opts` do not configure any.
+//
+// The services are only taken from the runtime options as a pod spec may not
+// choose which services the host exposes or their security descriptors.
+func hvSocketServices(opts *runhcsopts.Options) (map[string]hcsschema.HvSocketServiceConfig, error) {
+	if opts == nil || opts.VmHvsocketServices == "" {
+		return nil, nil
+	}
+	var services map[string]hcsschema.HvSocketServiceConfig
+	if err := json.Unmarshal([]byte(opts.VmHvsocketServices), &services); err != nil {
+		return nil, fmt.Errorf("failed to parse vm_hvsocket_services: %s", err)
+	}
+	return services, nil
+}
+
+// consoleLogFile returns the file that the serial console of the Linux utility
+// VM `id` is captured to. Returns "" if console capture is not enabled by the
+// runtime options `opts`.
+func consoleLogFile(id string, opts *runhcsopts.Options) string {
+	if opts == nil || opts.VmConsoleLogDirectory == "" {
+		return ""
+	}
+	return filepath.Join(opts.VmConsoleLogDirectory, id+"-console.log")
+}
+
 // parseAnnotationsBool searches `a` for `key` and if found verifies that the
 // value is `true` or `false` in any case. If `key` is not found returns `def`.
 func parseAnnotationsBool(a map[string]string, key string, def bool) bool {
@@ -239,6 +326,46 @@ func ParseAnnotationsMemory(s *specs.Spec, annotation string, def int32) int32 {
 	return def
 }
 
+// IsDefaultOutputHandling returns `true` if the annotations `a` do not change
+// how the output forwarded from the Linux utility VM of a pod is handled.
+func IsDefaultOutputHandling(a map[string]string) bool {
+	for _, key := range []string{annotationOutputHandler, annotationOutputLevels, annotationOutputDropFields} {
+		if _, ok := a[key]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parseAnnotationsOutputHandler searches `a` for the output handler
+// annotations and returns the output handler they select for the Linux
+// utility VM `id`. `fileRoot` is the directory the `file:` handlers write in.
+// If none are found or they cannot be parsed returns `def`.
+func parseAnnotationsOutputHandler(a map[string]string, id, fileRoot string, def uvm.OutputHandler) uvm.OutputHandler {
+	if IsDefaultOutputHandling(a) {
+		return def
+	}
+	levels, err := gcsoutput.ParseLevels(a[annotationOutputLevels])
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			logfields.OCIAnnotation: annotationOutputLevels,
+			logfields.Value:         a[annotationOutputLevels],
+			logrus.ErrorKey:         err,
+		}).Warning("annotation could not be parsed")
+		return def
+	}
+	h, err := uvm.NewOutputHandler(id, a[annotationOutputHandler], levels, gcsoutput.ParseFields(a[annotationOutputDropFields]), fileRoot)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			logfields.OCIAnnotation: annotationOutputHandler,
+			logfields.Value:         a[annotationOutputHandler],
+			logrus.ErrorKey:         err,
+		}).Warning("annotation could not be parsed")
+		return def
+	}
+	return h
+}
+
 // parseAnnotationsPreferredRootFSType searches `a` for `key` and verifies that the
 // value is in the set of allowed values. If `key` is not found returns `def`.
 func parseAnnotationsPreferredRootFSType(a map[string]string, key string, def uvm.PreferredRootFSType) uvm.PreferredRootFSType {
@@ -274,6 +401,30 @@ func parseAnnotationsUint32(a map[string]string, key string, def uint32) uint32
 	return def
 }
 
+// parseAnnotationsUint32List searches `a` for `key` and if found verifies that
+// the value is a comma separated list of 32 bit unsigned integers. If `key` is
+// not found returns `def`.
+func parseAnnotationsUint32List(a map[string]string, key string, def []uint32) []uint32 {
+	if v, ok := a[key]; ok {
+		var list []uint32
+		for _, f := range strings.Split(v, ",") {
+			u, err := strconv.ParseUint(strings.TrimSpace(f), 10, 32)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					logfields.OCIAnnotation: key,
+					logfields.Value:         v,
+					logfields.ExpectedType:  logfields.Uint32,
+					logrus.ErrorKey:         err,
+				}).Warning("annotation could not be parsed")
+				return def
+			}
+			list = append(list, uint32(u))
+		}
+		return list
+	}
+	return def
+}
+
 // parseAnnotationsUint64 searches `a` for `key` and if found verifies that the
 // value is a 64 bit unsigned integer. If `key` is not found returns `def`.
 func parseAnnotationsUint64(a map[string]string, key string, def uint64) uint64 {
@@ -300,22 +451,111 @@ func parseAnnotationsString(a map[string]string, key string, def string) string
 	return def
 }
 
+// applyOptions replaces the built-in defaults in `uopts` with the utility VM
+// defaults set in the runtime options `opts`. Fields that are not set in
+// `opts` are left unchanged.
+func applyOptions(uopts *uvm.Options, opts *runhcsopts.Options) {
+	if opts == nil {
+		return
+	}
+	if opts.VmMemorySizeInMb != 0 {
+		uopts.MemorySizeInMB = opts.VmMemorySizeInMb
+	}
+	if opts.VmProcessorCount != 0 {
+		uopts.ProcessorCount = opts.VmProcessorCount
+	}
+	if opts.VmMemoryMaximumInMb != 0 {
+		uopts.MemoryMaximumInMB = opts.VmMemoryMaximumInMb
+	}
+	if opts.VmDisableOvercommit {
+		uopts.AllowOvercommit = false
+	}
+	if opts.VmEnableDeferredCommit {
+		uopts.EnableDeferredCommit = true
+	}
+	if opts.VmStorageQosIopsMaximum != 0 {
+		uopts.StorageQoSIopsMaximum = opts.VmStorageQosIopsMaximum
+	}
+	if opts.VmStorageQosBandwidthMaximum != 0 {
+		uopts.StorageQoSBandwidthMaximum = opts.VmStorageQosBandwidthMaximum
+	}
+}
+
+// applyOptionsLCOW is `applyOptions` for the LCOW specific defaults.
+func applyOptionsLCOW(lopts *uvm.OptionsLCOW, opts *runhcsopts.Options) {
+	applyOptions(lopts.Options, opts)
+	if opts == nil {
+		return
+	}
+	if opts.VmVpmemCount != 0 {
+		lopts.VPMemDeviceCount = opts.VmVpmemCount
+	}
+	if opts.VmVpmemSizeBytes != 0 {
+		lopts.VPMemSizeBytes = opts.VmVpmemSizeBytes
+	}
+	if opts.VmScsiControllerCount != 0 {
+		lopts.SCSIControllerCount = opts.VmScsiControllerCount
+	}
+	switch opts.VmPreferredRootfsType {
+	case runhcsopts.Options_INITRD:
+		lopts.PreferredRootFSType = uvm.PreferredRootFSTypeInitRd
+	case runhcsopts.Options_VHD:
+		lopts.PreferredRootFSType = uvm.PreferredRootFSTypeVHD
+	}
+	if opts.VmKernelBootOptions != "" {
+		lopts.KernelBootOptions = opts.VmKernelBootOptions
+	}
+	if opts.BootFilesRootPath != "" {
+		lopts.BootFilesPath = opts.BootFilesRootPath
+	}
+	if opts.VmConsoleLogMaxSizeInMb != 0 {
+		lopts.ConsoleLogMaxSize = int64(opts.VmConsoleLogMaxSizeInMb) * 1024 * 1024
+	}
+	if opts.VmConsoleLogMaxFiles != 0 {
+		lopts.ConsoleLogMaxFiles = int(opts.VmConsoleLogMaxFiles)
+	}
+}
+
+// applyOptionsWCOW is `applyOptions` for the WCOW specific defaults.
+func applyOptionsWCOW(wopts *uvm.OptionsWCOW, opts *runhcsopts.Options) {
+	applyOptions(wopts.Options, opts)
+	if opts == nil {
+		return
+	}
+	if opts.VmScsiControllerCount != 0 {
+		wopts.SCSIControllerCount = opts.VmScsiControllerCount
+	}
+}
+
 // SpecToUVMCreateOpts parses `s` and returns either `*uvm.OptionsLCOW` or
 // `*uvm.OptionsWCOW`.
-func SpecToUVMCreateOpts(s *specs.Spec, id, owner string) (interface{}, error) {
+//
+// Each setting is taken from the first of: the annotation on `s` (or the
+// equivalent Windows resources section of `s`), the runtime options `opts`
+// and finally the built-in default. `opts` may be `nil`.
+func SpecToUVMCreateOpts(s *specs.Spec, id, owner string, opts *runhcsopts.Options) (interface{}, error) {
 	if !IsIsolated(s) {
 		return nil, errors.New("cannot create UVM opts for non-isolated spec")
 	}
 	if IsLCOW(s) {
 		lopts := uvm.NewDefaultOptionsLCOW(id, owner)
+		applyOptionsLCOW(lopts, opts)
 		lopts.MemorySizeInMB = ParseAnnotationsMemory(s, annotationMemorySizeInMB, lopts.MemorySizeInMB)
 		lopts.AllowOvercommit = parseAnnotationsBool(s.Annotations, annotationAllowOvercommit, lopts.AllowOvercommit)
 		lopts.EnableDeferredCommit = parseAnnotationsBool(s.Annotations, annotationEnableDeferredCommit, lopts.EnableDeferredCommit)
 		lopts.ProcessorCount = ParseAnnotationsCPUCount(s, annotationProcessorCount, lopts.ProcessorCount)
 		lopts.ProcessorLimit = ParseAnnotationsCPULimit(s, annotationProcessorLimit, lopts.ProcessorLimit)
 		lopts.ProcessorWeight = ParseAnnotationsCPUWeight(s, annotationProcessorWeight, lopts.ProcessorWeight)
+		lopts.EnableLargePages = parseAnnotationsBool(s.Annotations, annotationEnableLargePages, lopts.EnableLargePages)
+		lopts.NumaNodeCount = parseAnnotationsUint32(s.Annotations, annotationNumaNodeCount, lopts.NumaNodeCount)
+		lopts.NumaPreferredPhysicalNodes = parseAnnotationsUint32List(s.Annotations, annotationNumaPreferredPhysicalNodes, lopts.NumaPreferredPhysicalNodes)
+		lopts.CPUGroupID = parseAnnotationsString(s.Annotations, annotationCPUGroupID, lopts.CPUGroupID)
+		lopts.ExposeVirtualizationExtensions = parseAnnotationsBool(s.Annotations, annotationExposeVirtualizationExtensions, lopts.ExposeVirtualizationExtensions)
+		lopts.MemoryMaximumInMB = int32(parseAnnotationsUint32(s.Annotations, annotationMemoryMaximumInMB, uint32(lopts.MemoryMaximumInMB)))
 		lopts.VPMemDeviceCount = parseAnnotationsUint32(s.Annotations, annotationVPMemCount, lopts.VPMemDeviceCount)
 		lopts.VPMemSizeBytes = parseAnnotationsUint64(s.Annotations, annotationVPMemSize, lopts.VPMemSizeBytes)
+		lopts.VPMemNoMultiMapping = parseAnnotationsBool(s.Annotations, annotationVPMemNoMultiMapping, lopts.VPMemNoMultiMapping)
+		lopts.SCSIControllerCount = parseAnnotationsUint32(s.Annotations, annotationSCSIControllerCount, lopts.SCSIControllerCount)
 		lopts.StorageQoSBandwidthMaximum = ParseAnnotationsStorageBps(s, annotationStorageQoSBandwidthMaximum, lopts.StorageQoSBandwidthMaximum)
 		lopts.StorageQoSIopsMaximum = ParseAnnotationsStorageIops(s, annotationStorageQoSIopsMaximum, lopts.StorageQoSIopsMaximum)
 		lopts.PreferredRootFSType = parseAnnotationsPreferredRootFSType(s.Annotations, annotationPreferredRootFSType, lopts.PreferredRootFSType)
@@ -325,29 +565,46 @@ func SpecToUVMCreateOpts(s *specs.Spec, id, owner string) (interface{}, error) {
 		case uvm.PreferredRootFSTypeVHD:
 			lopts.RootFSFile = uvm.VhdFile
 		}
+		lopts.KernelBootOptions = parseAnnotationsString(s.Annotations, annotationKernelBootOptions, lopts.KernelBootOptions)
 		lopts.BootFilesPath = parseAnnotationsString(s.Annotations, annotationBootFilesRootPath, lopts.BootFilesPath)
+		services, err := hvSocketServices(opts)
+		if err != nil {
+			return nil, err
+		}
+		lopts.HvSocketServices = services
+		lopts.CrashDumpPath = crashDumpPath(id, opts)
+		lopts.ConsoleLogFile = consoleLogFile(id, opts)
+		outputRoot := ""
+		if opts != nil {
+			outputRoot = opts.LogDirectory
+		}
+		lopts.OutputHandler = parseAnnotationsOutputHandler(s.Annotations, id, outputRoot, lopts.OutputHandler)
 		return lopts, nil
 	} else if IsWCOW(s) {
 		wopts := uvm.NewDefaultOptionsWCOW(id, owner)
+		applyOptionsWCOW(wopts, opts)
 		wopts.MemorySizeInMB = ParseAnnotationsMemory(s, annotationMemorySizeInMB, wopts.MemorySizeInMB)
 		wopts.AllowOvercommit = parseAnnotationsBool(s.Annotations, annotationAllowOvercommit, wopts.AllowOvercommit)
 		wopts.EnableDeferredCommit = parseAnnotationsBool(s.Annotations, annotationEnableDeferredCommit, wopts.EnableDeferredCommit)
 		wopts.ProcessorCount = ParseAnnotationsCPUCount(s, annotationProcessorCount, wopts.ProcessorCount)
 		wopts.ProcessorLimit = ParseAnnotationsCPULimit(s, annotationProcessorLimit, wopts.ProcessorLimit)
 		wopts.ProcessorWeight = ParseAnnotationsCPUWeight(s, annotationProcessorWeight, wopts.ProcessorWeight)
+		wopts.EnableLargePages = parseAnnotationsBool(s.Annotations, annotationEnableLargePages, wopts.EnableLargePages)
+		wopts.NumaNodeCount = parseAnnotationsUint32(s.Annotations, annotationNumaNodeCount, wopts.NumaNodeCount)
+		wopts.NumaPreferredPhysicalNodes = parseAnnotationsUint32List(s.Annotations, annotationNumaPreferredPhysicalNodes, wopts.NumaPreferredPhysicalNodes)
+		wopts.CPUGroupID = parseAnnotationsString(s.Annotations, annotationCPUGroupID, wopts.CPUGroupID)
+		wopts.ExposeVirtualizationExtensions = parseAnnotationsBool(s.Annotations, annotationExposeVirtualizationExtensions, wopts.ExposeVirtualizationExtensions)
+		wopts.MemoryMaximumInMB = int32(parseAnnotationsUint32(s.Annotations, annotationMemoryMaximumInMB, uint32(wopts.MemoryMaximumInMB)))
 		wopts.StorageQoSBandwidthMaximum = ParseAnnotationsStorageBps(s, annotationStorageQoSBandwidthMaximum, wopts.StorageQoSBandwidthMaximum)
 		wopts.StorageQoSIopsMaximum = ParseAnnotationsStorageIops(s, annotationStorageQoSIopsMaximum, wopts.StorageQoSIopsMaximum)
+		wopts.SCSIControllerCount = parseAnnotationsUint32(s.Annotations, annotationSCSIControllerCount, wopts.SCSIControllerCount)
+		services, err := hvSocketServices(opts)
+		if err != nil {
+			return nil, err
+		}
+		wopts.HvSocketServices = services
+		wopts.CrashDumpPath = crashDumpPath(id, opts)
 		return wopts, nil
 	}
 	return nil, errors.New("cannot create UVM opts spec is not LCOW or WCOW")
 }
-
-// UpdateSpecFromOptions sets extra annotations on the OCI spec based on the
-// `opts` struct.
-func UpdateSpecFromOptions(s specs.Spec, opts *runhcsopts.Options) specs.Spec {
-	if opts != nil && opts.BootFilesRootPath != "" {
-		s.Annotations[annotationBootFilesRootPath] = opts.BootFilesRootPath
-	}
-
-	return s
-}
@@ -0,0 +1,374 @@
+package oci
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	runhcsopts "github.com/Microsoft/hcsshim/cmd/containerd-shim-runhcs-v1/options"
+	"github.com/Microsoft/hcsshim/internal/uvm"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func lcowSpec(annotations map[string]string) *specs.Spec {
+	return &specs.Spec{
+		Annotations: annotations,
+		Linux:       &specs.Linux{},
+		Windows: &specs.Windows{
+			HyperV: &specs.WindowsHyperV{},
+		},
+	}
+}
+
+func wcowSpec(annotations map[string]string) *specs.Spec {
+	return &specs.Spec{
+		Annotations: annotations,
+		Windows: &specs.Windows{
+			HyperV: &specs.WindowsHyperV{},
+		},
+	}
+}
+
+func Test_SpecToUVMCreateOpts_LCOW_NoOptions_Defaults(t *testing.T) {
+	opts, err := SpecToUVMCreateOpts(lcowSpec(nil), t.Name(), "", nil)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	lopts := opts.(*uvm.OptionsLCOW)
+	def := uvm.NewDefaultOptionsLCOW(t.Name(), "")
+	if lopts.MemorySizeInMB != def.MemorySizeInMB ||
+		lopts.ProcessorCount != def.ProcessorCount ||
+		lopts.VPMemDeviceCount != def.VPMemDeviceCount ||
+		lopts.AllowOvercommit != def.AllowOvercommit {
+		t.Fatalf("expected built-in defaults, got: %+v", lopts)
+	}
+}
+
+func Test_SpecToUVMCreateOpts_LCOW_Options_OverrideDefaults(t *testing.T) {
+	shimOpts := &runhcsopts.Options{
+		VmMemorySizeInMb:             2048,
+		VmProcessorCount:             4,
+		VmVpmemCount:                 16,
+		VmVpmemSizeBytes:             1024,
+		VmPreferredRootfsType:        runhcsopts.Options_VHD,
+		VmKernelBootOptions:          "debug",
+		VmDisableOvercommit:          true,
+		VmEnableDeferredCommit:       true,
+		VmStorageQosIopsMaximum:      100,
+		VmStorageQosBandwidthMaximum: 200,
+		VmScsiControllerCount:        2,
+		BootFilesRootPath:            `C:\boot`,
+	}
+	opts, err := SpecToUVMCreateOpts(lcowSpec(nil), t.Name(), "", shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	lopts := opts.(*uvm.OptionsLCOW)
+	if lopts.MemorySizeInMB != 2048 {
+		t.Fatalf("expected memory: 2048, got: %d", lopts.MemorySizeInMB)
+	}
+	if lopts.ProcessorCount != 4 {
+		t.Fatalf("expected processor count: 4, got: %d", lopts.ProcessorCount)
+	}
+	if lopts.VPMemDeviceCount != 16 || lopts.VPMemSizeBytes != 1024 {
+		t.Fatalf("expected vpmem: 16 x 1024, got: %d x %d", lopts.VPMemDeviceCount, lopts.VPMemSizeBytes)
+	}
+	if lopts.PreferredRootFSType != uvm.PreferredRootFSTypeVHD || lopts.RootFSFile != uvm.VhdFile {
+		t.Fatalf("expected vhd root fs, got: %v, '%s'", lopts.PreferredRootFSType, lopts.RootFSFile)
+	}
+	if lopts.KernelBootOptions != "debug" {
+		t.Fatalf("expected kernel boot options: 'debug', got: '%s'", lopts.KernelBootOptions)
+	}
+	if lopts.AllowOvercommit || !lopts.EnableDeferredCommit {
+		t.Fatalf("expected no overcommit with deferred commit, got: %v, %v", lopts.AllowOvercommit, lopts.EnableDeferredCommit)
+	}
+	if lopts.StorageQoSIopsMaximum != 100 || lopts.StorageQoSBandwidthMaximum != 200 {
+		t.Fatalf("expected storage qos: 100, 200, got: %d, %d", lopts.StorageQoSIopsMaximum, lopts.StorageQoSBandwidthMaximum)
+	}
+	if lopts.SCSIControllerCount != 2 {
+		t.Fatalf("expected SCSI controller count: 2, got: %d", lopts.SCSIControllerCount)
+	}
+	if lopts.BootFilesPath != `C:\boot` {
+		t.Fatalf("expected boot files path: 'C:\\boot', got: '%s'", lopts.BootFilesPath)
+	}
+}
+
+func Test_SpecToUVMCreateOpts_LCOW_Annotations_OverrideOptions(t *testing.T) {
+	shimOpts := &runhcsopts.Options{
+		VmMemorySizeInMb:      2048,
+		VmProcessorCount:      4,
+		VmVpmemCount:          16,
+		VmPreferredRootfsType: runhcsopts.Options_VHD,
+		VmKernelBootOptions:   "debug",
+		VmDisableOvercommit:   true,
+		VmScsiControllerCount: 2,
+		BootFilesRootPath:     `C:\boot`,
+	}
+	s := lcowSpec(map[string]string{
+		annotationSCSIControllerCount: "4",
+		annotationMemorySizeInMB:      "1024",
+		annotationProcessorCount:      "1",
+		annotationVPMemCount:          "2",
+		annotationPreferredRootFSType: "initrd",
+		annotationKernelBootOptions:   "quiet",
+		annotationAllowOvercommit:     "true",
+		annotationBootFilesRootPath:   `D:\boot`,
+		annotationVPMemNoMultiMapping: "true",
+	})
+	opts, err := SpecToUVMCreateOpts(s, t.Name(), "", shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	lopts := opts.(*uvm.OptionsLCOW)
+	if lopts.MemorySizeInMB != 1024 || lopts.ProcessorCount != 1 || lopts.VPMemDeviceCount != 2 {
+		t.Fatalf("expected annotation sizing: 1024, 1, 2, got: %d, %d, %d", lopts.MemorySizeInMB, lopts.ProcessorCount, lopts.VPMemDeviceCount)
+	}
+	if lopts.PreferredRootFSType != uvm.PreferredRootFSTypeInitRd || lopts.RootFSFile != uvm.InitrdFile {
+		t.Fatalf("expected initrd root fs, got: %v, '%s'", lopts.PreferredRootFSType, lopts.RootFSFile)
+	}
+	if lopts.KernelBootOptions != "quiet" {
+		t.Fatalf("expected kernel boot options: 'quiet', got: '%s'", lopts.KernelBootOptions)
+	}
+	if !lopts.AllowOvercommit {
+		t.Fatal("expected annotation to allow overcommit")
+	}
+	if lopts.SCSIControllerCount != 4 {
+		t.Fatalf("expected SCSI controller count from annotation: 4, got: %d", lopts.SCSIControllerCount)
+	}
+	if lopts.BootFilesPath != `D:\boot` {
+		t.Fatalf("expected boot files path: 'D:\\boot', got: '%s'", lopts.BootFilesPath)
+	}
+	if !lopts.VPMemNoMultiMapping {
+		t.Fatal("expected annotation to disable VPMem multi-mapping")
+	}
+}
+
+func Test_SpecToUVMCreateOpts_WCOW_Precedence(t *testing.T) {
+	shimOpts := &runhcsopts.Options{
+		VmMemorySizeInMb:      2048,
+		VmProcessorCount:      4,
+		VmScsiControllerCount: 2,
+	}
+	s := wcowSpec(map[string]string{
+		annotationProcessorCount:      "1",
+		annotationSCSIControllerCount: "1",
+	})
+	opts, err := SpecToUVMCreateOpts(s, t.Name(), "", shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	wopts := opts.(*uvm.OptionsWCOW)
+	if wopts.MemorySizeInMB != 2048 {
+		t.Fatalf("expected memory from options: 2048, got: %d", wopts.MemorySizeInMB)
+	}
+	if wopts.ProcessorCount != 1 {
+		t.Fatalf("expected processor count from annotation: 1, got: %d", wopts.ProcessorCount)
+	}
+	if wopts.SCSIControllerCount != 1 {
+		t.Fatalf("expected SCSI controller count from annotation: 1, got: %d", wopts.SCSIControllerCount)
+	}
+}
+
+func Test_ParseAnnotationsClone(t *testing.T) {
+	if ParseAnnotationsClone(nil) {
+		t.Fatal("expected clone to default to false")
+	}
+	if !ParseAnnotationsClone(map[string]string{annotationClone: "True"}) {
+		t.Fatal("expected clone to be true")
+	}
+	if ParseAnnotationsClone(map[string]string{annotationClone: "yes"}) {
+		t.Fatal("expected an invalid value to default to false")
+	}
+}
+
+func Test_SpecToUVMCreateOpts_CrashDumpPath(t *testing.T) {
+	opts, err := SpecToUVMCreateOpts(lcowSpec(nil), "pod@vm", "", nil)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if p := opts.(*uvm.OptionsLCOW).CrashDumpPath; p != "" {
+		t.Fatalf("expected crash collection to be disabled, got: %s", p)
+	}
+
+	shimOpts := &runhcsopts.Options{CrashDumpRoot: `C:\dumps`}
+	opts, err = SpecToUVMCreateOpts(wcowSpec(nil), "pod@vm", "", shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if p := opts.(*uvm.OptionsWCOW).CrashDumpPath; p != filepath.Join(`C:\dumps`, "pod@vm") {
+		t.Fatalf("expected crash dump path from options, got: %s", p)
+	}
+
+	// A pod may not choose the host directory.
+	s := lcowSpec(map[string]string{"io.microsoft.virtualmachine.crashdumproot": `D:\dumps`})
+	opts, err = SpecToUVMCreateOpts(s, "pod@vm", "", shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if p := opts.(*uvm.OptionsLCOW).CrashDumpPath; p != filepath.Join(`C:\dumps`, "pod@vm") {
+		t.Fatalf("expected crash dump path from options, got: %s", p)
+	}
+}
+
+func Test_SpecToUVMCreateOpts_ConsoleLog(t *testing.T) {
+	opts, err := SpecToUVMCreateOpts(lcowSpec(nil), "pod@vm", "", nil)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	lopts := opts.(*uvm.OptionsLCOW)
+	if lopts.ConsoleLogFile != "" {
+		t.Fatalf("expected console capture to be disabled, got: %s", lopts.ConsoleLogFile)
+	}
+
+	shimOpts := &runhcsopts.Options{
+		VmConsoleLogDirectory:   `C:\logs`,
+		VmConsoleLogMaxSizeInMb: 2,
+		VmConsoleLogMaxFiles:    3,
+	}
+	// A pod may not choose the host directory.
+	s := lcowSpec(map[string]string{"io.microsoft.virtualmachine.lcow.consolelogdirectory": `D:\logs`})
+	opts, err = SpecToUVMCreateOpts(s, "pod@vm", "", shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	lopts = opts.(*uvm.OptionsLCOW)
+	if lopts.ConsoleLogFile != filepath.Join(`C:\logs`, "pod@vm-console.log") {
+		t.Fatalf("expected console log from options, got: %s", lopts.ConsoleLogFile)
+	}
+	if lopts.ConsoleLogMaxSize != 2*1024*1024 || lopts.ConsoleLogMaxFiles != 3 {
+		t.Fatalf("expected console log limits from options, got: %d %d", lopts.ConsoleLogMaxSize, lopts.ConsoleLogMaxFiles)
+	}
+}
+
+func Test_SpecToUVMCreateOpts_Maximums(t *testing.T) {
+	shimOpts := &runhcsopts.Options{
+		VmMemoryMaximumInMb: 4096,
+	}
+	opts, err := SpecToUVMCreateOpts(wcowSpec(nil), t.Name(), "", shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	wopts := opts.(*uvm.OptionsWCOW)
+	if wopts.MemoryMaximumInMB != 4096 {
+		t.Fatalf("expected maximum from options: 4096, got: %d", wopts.MemoryMaximumInMB)
+	}
+
+	s := lcowSpec(map[string]string{
+		annotationMemoryMaximumInMB: "8192",
+	})
+	opts, err = SpecToUVMCreateOpts(s, t.Name(), "", shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	lopts := opts.(*uvm.OptionsLCOW)
+	if lopts.MemoryMaximumInMB != 8192 {
+		t.Fatalf("expected maximum from annotations: 8192, got: %d", lopts.MemoryMaximumInMB)
+	}
+}
+
+func Test_SpecToUVMCreateOpts_Topology(t *testing.T) {
+	s := lcowSpec(map[string]string{
+		annotationAllowOvercommit:                "false",
+		annotationEnableLargePages:               "true",
+		annotationNumaNodeCount:                  "2",
+		annotationNumaPreferredPhysicalNodes:     "0, 1",
+		annotationCPUGroupID:                     "group",
+		annotationExposeVirtualizationExtensions: "true",
+	})
+	opts, err := SpecToUVMCreateOpts(s, t.Name(), "", nil)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	lopts := opts.(*uvm.OptionsLCOW)
+	if lopts.AllowOvercommit || !lopts.EnableLargePages || lopts.NumaNodeCount != 2 ||
+		len(lopts.NumaPreferredPhysicalNodes) != 2 || lopts.NumaPreferredPhysicalNodes[1] != 1 ||
+		lopts.CPUGroupID != "group" || !lopts.ExposeVirtualizationExtensions {
+		t.Fatalf("unexpected topology options: %+v", lopts.Options)
+	}
+
+	s = wcowSpec(map[string]string{annotationNumaPreferredPhysicalNodes: "0,x"})
+	opts, err = SpecToUVMCreateOpts(s, t.Name(), "", nil)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if nodes := opts.(*uvm.OptionsWCOW).NumaPreferredPhysicalNodes; nodes != nil {
+		t.Fatalf("expected no preferred nodes for an invalid annotation, got: %v", nodes)
+	}
+}
+
+func Test_SpecToUVMCreateOpts_HvSocketServices(t *testing.T) {
+	const serviceID = "00000400-facb-11e6-bd58-64006a7986d3"
+	shimOpts := &runhcsopts.Options{
+		VmHvsocketServices: `{"` + serviceID + `":{"BindSecurityDescriptor":"D:P(A;;FA;;;SY)","AllowWildcardBinds":true}}`,
+	}
+	opts, err := SpecToUVMCreateOpts(lcowSpec(nil), t.Name(), "", shimOpts)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	services := opts.(*uvm.OptionsLCOW).HvSocketServices
+	if len(services) != 1 ||
+		services[serviceID].BindSecurityDescriptor != "D:P(A;;FA;;;SY)" ||
+		!services[serviceID].AllowWildcardBinds {
+		t.Fatalf("unexpected services: %+v", services)
+	}
+
+	// A pod may not register services of its own.
+	s := wcowSpec(map[string]string{
+		"io.microsoft.virtualmachine.hvsocket.services": `{"` + serviceID + `":{"BindSecurityDescriptor":"D:P(A;;GA;;;WD)"}}`,
+	})
+	opts, err = SpecToUVMCreateOpts(s, t.Name(), "", nil)
+	if err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if services := opts.(*uvm.OptionsWCOW).HvSocketServices; services != nil {
+		t.Fatalf("expected no services from annotations, got: %+v", services)
+	}
+
+	shimOpts.VmHvsocketServices = "[]"
+	if _, err := SpecToUVMCreateOpts(wcowSpec(nil), t.Name(), "", shimOpts); err == nil {
+		t.Fatal("expected invalid services to fail")
+	}
+}
+
+func Test_SpecToUVMCreateOpts_OutputHandler(t *testing.T) {
+	if !IsDefaultOutputHandling(nil) {
+		t.Fatal("expected default output handling without annotations")
+	}
+	root, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	tests := map[string]struct {
+		annotations map[string]string
+		fileRoot    string
+		isDefault   bool
+	}{
+		"handler":        {map[string]string{annotationOutputHandler: "json,stdout"}, "", false},
+		"levels":         {map[string]string{annotationOutputLevels: "debug=info"}, "", false},
+		"dropfields":     {map[string]string{annotationOutputDropFields: "a,b"}, "", false},
+		"invalidhandler": {map[string]string{annotationOutputHandler: "xml"}, "", true},
+		"invalidlevels":  {map[string]string{annotationOutputLevels: "debug"}, "", true},
+		"file":           {map[string]string{annotationOutputHandler: "file:gcs.log"}, root, false},
+		"filenoroot":     {map[string]string{annotationOutputHandler: "file:gcs.log"}, "", true},
+		"fileabsolute":   {map[string]string{annotationOutputHandler: `file:C:\gcs.log`}, root, true},
+		"fileoutside":    {map[string]string{annotationOutputHandler: `file:..\gcs.log`}, root, true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if IsDefaultOutputHandling(test.annotations) {
+				t.Fatal("expected the annotations to change the output handling")
+			}
+			called := false
+			def := uvm.OutputHandler(func(io.Reader) { called = true })
+			h := parseAnnotationsOutputHandler(test.annotations, "pod@vm", test.fileRoot, def)
+			h(strings.NewReader(""))
+			if called != test.isDefault {
+				t.Fatalf("expected default handler: %v, got: %v", test.isDefault, called)
+			}
+		})
+	}
+}
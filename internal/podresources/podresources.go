@@ -0,0 +1,168 @@
+// Package podresources accounts for the processor and memory requested by the
+// containers of a pod against the capacity of the utility VM hosting them.
+package podresources
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Resources is an amount of processor and memory.
+type Resources struct {
+	// ProcessorMillis is the processor amount in thousandths of a processor.
+	ProcessorMillis uint64
+	// MemoryInMB is the memory amount in MB.
+	MemoryInMB uint64
+}
+
+// Add returns the sum of `r` and `o`.
+func (r Resources) Add(o Resources) Resources {
+	return Resources{
+		ProcessorMillis: r.ProcessorMillis + o.ProcessorMillis,
+		MemoryInMB:      r.MemoryInMB + o.MemoryInMB,
+	}
+}
+
+// InsufficientError is returned by `Accountant.Reserve` when the request does
+// not fit in the remaining capacity.
+type InsufficientError struct {
+	// ID is the id of the request.
+	ID string
+	// Request is the amount that was requested.
+	Request Resources
+	// Available is the amount of capacity that was left.
+	Available Resources
+}
+
+func (e *InsufficientError) Error() string {
+	return fmt.Sprintf(
+		"request for '%s' of %d processor millis and %d MB exceeds the available %d processor millis and %d MB",
+		e.ID,
+		e.Request.ProcessorMillis,
+		e.Request.MemoryInMB,
+		e.Available.ProcessorMillis,
+		e.Available.MemoryInMB)
+}
+
+// Required returns the smallest capacity, grown from `capacity`, that the
+// request of `e` fits in. `capacity` is the capacity the request was made
+// against. A field that is not accounted for is unchanged.
+func (e *InsufficientError) Required(capacity Resources) Resources {
+	r := capacity
+	if capacity.ProcessorMillis != 0 && e.Request.ProcessorMillis > e.Available.ProcessorMillis {
+		r.ProcessorMillis += e.Request.ProcessorMillis - e.Available.ProcessorMillis
+	}
+	if capacity.MemoryInMB != 0 && e.Request.MemoryInMB > e.Available.MemoryInMB {
+		r.MemoryInMB += e.Request.MemoryInMB - e.Available.MemoryInMB
+	}
+	return r
+}
+
+// Accountant tracks the resources requested by id against a capacity that can
+// only grow. It is safe for concurrent use.
+type Accountant struct {
+	m        sync.Mutex
+	capacity Resources
+	requests map[string]Resources
+}
+
+// NewAccountant creates an `Accountant` for `capacity`. A `0` field in
+// `capacity` is not accounted for, any amount of it fits.
+func NewAccountant(capacity Resources) *Accountant {
+	return &Accountant{
+		capacity: capacity,
+		requests: make(map[string]Resources),
+	}
+}
+
+// Capacity returns the capacity of `a`.
+func (a *Accountant) Capacity() Resources {
+	a.m.Lock()
+	defer a.m.Unlock()
+	return a.capacity
+}
+
+// Grow raises the capacity of `a` to `capacity`. A field of `capacity` that is
+// less than the current capacity, or that is not accounted for, is ignored.
+func (a *Accountant) Grow(capacity Resources) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	if a.capacity.ProcessorMillis != 0 && capacity.ProcessorMillis > a.capacity.ProcessorMillis {
+		a.capacity.ProcessorMillis = capacity.ProcessorMillis
+	}
+	if a.capacity.MemoryInMB != 0 && capacity.MemoryInMB > a.capacity.MemoryInMB {
+		a.capacity.MemoryInMB = capacity.MemoryInMB
+	}
+}
+
+// Requested returns the sum of all reserved requests.
+func (a *Accountant) Requested() Resources {
+	a.m.Lock()
+	defer a.m.Unlock()
+	return a.requestedL()
+}
+
+func (a *Accountant) requestedL() Resources {
+	var total Resources
+	for _, r := range a.requests {
+		total = total.Add(r)
+	}
+	return total
+}
+
+// Requests returns a copy of all reserved requests by id.
+func (a *Accountant) Requests() map[string]Resources {
+	a.m.Lock()
+	defer a.m.Unlock()
+	requests := make(map[string]Resources, len(a.requests))
+	for id, r := range a.requests {
+		requests[id] = r
+	}
+	return requests
+}
+
+// Reserve reserves `r` for `id`. If `id` already has a reservation it is
+// replaced. If the sum of all reservations would exceed the capacity of `a`
+// nothing is reserved and an `*InsufficientError` is returned.
+func (a *Accountant) Reserve(id string, r Resources) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	others := a.requestedL()
+	if old, ok := a.requests[id]; ok {
+		others.ProcessorMillis -= old.ProcessorMillis
+		others.MemoryInMB -= old.MemoryInMB
+	}
+	total := others.Add(r)
+	if (a.capacity.ProcessorMillis != 0 && total.ProcessorMillis > a.capacity.ProcessorMillis) ||
+		(a.capacity.MemoryInMB != 0 && total.MemoryInMB > a.capacity.MemoryInMB) {
+		return &InsufficientError{
+			ID:        id,
+			Request:   r,
+			Available: available(a.capacity, others),
+		}
+	}
+	a.requests[id] = r
+	return nil
+}
+
+// Release releases the reservation of `id`. It is safe to call if `id` has no
+// reservation.
+func (a *Accountant) Release(id string) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	delete(a.requests, id)
+}
+
+// available returns the part of `capacity` that is not in `used`. A field that
+// is not accounted for is reported as `0`.
+func available(capacity, used Resources) Resources {
+	var r Resources
+	if capacity.ProcessorMillis > used.ProcessorMillis {
+		r.ProcessorMillis = capacity.ProcessorMillis - used.ProcessorMillis
+	}
+	if capacity.MemoryInMB > used.MemoryInMB {
+		r.MemoryInMB = capacity.MemoryInMB - used.MemoryInMB
+	}
+	return r
+}
@@ -0,0 +1,109 @@
+package podresources
+
+import "testing"
+
+func Test_Accountant_Reserve_WithinCapacity(t *testing.T) {
+	a := NewAccountant(Resources{ProcessorMillis: 2000, MemoryInMB: 1024})
+
+	if err := a.Reserve("a", Resources{ProcessorMillis: 1500, MemoryInMB: 512}); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if err := a.Reserve("b", Resources{ProcessorMillis: 500, MemoryInMB: 512}); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if r := a.Requested(); r != (Resources{ProcessorMillis: 2000, MemoryInMB: 1024}) {
+		t.Fatalf("expected all capacity requested, got: %+v", r)
+	}
+}
+
+func Test_Accountant_Reserve_ExceedsCapacity_Error(t *testing.T) {
+	a := NewAccountant(Resources{ProcessorMillis: 2000, MemoryInMB: 1024})
+	a.Reserve("a", Resources{MemoryInMB: 1000})
+
+	err := a.Reserve("b", Resources{MemoryInMB: 100})
+	ierr, ok := err.(*InsufficientError)
+	if !ok {
+		t.Fatalf("expected InsufficientError, got: %v", err)
+	}
+	if ierr.Available != (Resources{ProcessorMillis: 2000, MemoryInMB: 24}) {
+		t.Fatalf("expected available: 2000 millis and 24 MB, got: %+v", ierr.Available)
+	}
+	if _, ok := a.Requests()["b"]; ok {
+		t.Fatal("expected nothing to be reserved for 'b'")
+	}
+}
+
+func Test_Accountant_Reserve_ReplacesExisting(t *testing.T) {
+	a := NewAccountant(Resources{MemoryInMB: 1024})
+	a.Reserve("a", Resources{MemoryInMB: 1000})
+
+	if err := a.Reserve("a", Resources{MemoryInMB: 1024}); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+	if r := a.Requested(); r.MemoryInMB != 1024 {
+		t.Fatalf("expected requested: 1024 MB, got: %d", r.MemoryInMB)
+	}
+}
+
+func Test_Accountant_ZeroCapacity_NotAccounted(t *testing.T) {
+	a := NewAccountant(Resources{MemoryInMB: 1024})
+
+	if err := a.Reserve("a", Resources{ProcessorMillis: 64000}); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+}
+
+func Test_Accountant_Release(t *testing.T) {
+	a := NewAccountant(Resources{MemoryInMB: 1024})
+	a.Reserve("a", Resources{MemoryInMB: 1024})
+	a.Release("a")
+	a.Release("unknown")
+
+	if err := a.Reserve("b", Resources{MemoryInMB: 1024}); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+}
+
+func Test_InsufficientError_Required(t *testing.T) {
+	capacity := Resources{ProcessorMillis: 2000, MemoryInMB: 1024}
+	a := NewAccountant(capacity)
+	a.Reserve("a", Resources{ProcessorMillis: 1500, MemoryInMB: 1000})
+
+	r := Resources{ProcessorMillis: 1000, MemoryInMB: 10}
+	err := a.Reserve("b", r)
+	ierr, ok := err.(*InsufficientError)
+	if !ok {
+		t.Fatalf("expected InsufficientError, got: %v", err)
+	}
+	required := ierr.Required(capacity)
+	if required != (Resources{ProcessorMillis: 2500, MemoryInMB: 1024}) {
+		t.Fatalf("expected required: 2500 millis and 1024 MB, got: %+v", required)
+	}
+	a.Grow(required)
+	if err := a.Reserve("b", r); err != nil {
+		t.Fatalf("should not have failed with error: %v", err)
+	}
+}
+
+func Test_InsufficientError_Required_NotAccounted(t *testing.T) {
+	capacity := Resources{MemoryInMB: 1024}
+	ierr := &InsufficientError{
+		Request:   Resources{ProcessorMillis: 1000, MemoryInMB: 2048},
+		Available: Resources{MemoryInMB: 1024},
+	}
+	if required := ierr.Required(capacity); required != (Resources{MemoryInMB: 2048}) {
+		t.Fatalf("expected required: 2048 MB, got: %+v", required)
+	}
+}
+
+func Test_Accountant_Grow_NeverShrinks(t *testing.T) {
+	a := NewAccountant(Resources{MemoryInMB: 1024})
+	a.Grow(Resources{ProcessorMillis: 2000, MemoryInMB: 512})
+	if c := a.Capacity(); c != (Resources{MemoryInMB: 1024}) {
+		t.Fatalf("expected capacity: 1024 MB, got: %+v", c)
+	}
+	a.Grow(Resources{MemoryInMB: 2048})
+	if c := a.Capacity(); c.MemoryInMB != 2048 {
+		t.Fatalf("expected capacity: 2048 MB, got: %d", c.MemoryInMB)
+	}
+}
@@ -0,0 +1,340 @@
+// Package regionalloc allocates ref-counted regions of the address space of a
+// set of equally sized devices. It is used to pack several read-only layers
+// into one VPMem device.
+//
+// A mapped region cannot be moved while it is in use by the guest so
+// fragmentation is limited by the allocation rules instead:
+//
+//   - Region sizes are rounded up to the alignment of the pool.
+//   - A region is placed at the start of the smallest free extent it fits in
+//     across all devices, preferring the lowest device and offset on ties.
+//     This keeps large extents available for large regions.
+//   - A freed region is merged with the free extents adjacent to it.
+//   - A device is only reported empty once its last region is freed, at which
+//     point the caller may remove it and reuse the device.
+//
+// The package has no platform dependencies so that allocation can be tested
+// on any platform.
+package regionalloc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrNoSpace is returned when no device has a free extent large enough for
+	// a region.
+	ErrNoSpace = errors.New("no device has enough free space")
+	// ErrNotAllocated is returned when a key has no region.
+	ErrNotAllocated = errors.New("not allocated")
+	// ErrAlreadyAllocated is returned when a key already has a region.
+	ErrAlreadyAllocated = errors.New("already allocated")
+	// ErrDeviceNotFound is returned when a device is not part of the pool.
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrDeviceInUse is returned when removing a device that has regions.
+	ErrDeviceInUse = errors.New("device has allocated regions")
+)
+
+// Region is a range of the address space of a device.
+type Region struct {
+	Offset uint64 `json:"Offset"`
+	Size   uint64 `json:"Size"`
+}
+
+// End returns the offset just past the end of `r`.
+func (r Region) End() uint64 {
+	return r.Offset + r.Size
+}
+
+func (r Region) String() string {
+	return fmt.Sprintf("[%d, %d)", r.Offset, r.End())
+}
+
+// Mapping is an allocated region.
+type Mapping struct {
+	// Key is the unique key holding the region.
+	Key string
+	// Device is the device the region is on.
+	Device uint32
+	Region
+	// RefCount is the number of references to the region. It is never `0`.
+	RefCount uint32
+	// Value is the caller data of the region.
+	Value interface{}
+}
+
+type device struct {
+	// free is the free extents of the device ordered by offset.
+	free []Region
+	// used is the number of regions on the device.
+	used int
+}
+
+// Pool allocates regions on a set of devices of `DeviceSize` bytes. It is safe
+// for concurrent use.
+type Pool struct {
+	m          sync.Mutex
+	deviceSize uint64
+	alignment  uint64
+	devices    map[uint32]*device
+	byKey      map[string]*Mapping
+}
+
+// NewPool creates a `Pool` of devices of `deviceSize` bytes. Region offsets and
+// sizes are multiples of `alignment`, which MUST be a power of two.
+func NewPool(deviceSize, alignment uint64) *Pool {
+	if alignment == 0 || alignment&(alignment-1) != 0 {
+		panic(fmt.Sprintf("regionalloc: alignment %d is not a power of two", alignment))
+	}
+	return &Pool{
+		deviceSize: deviceSize &^ (alignment - 1),
+		alignment:  alignment,
+		devices:    make(map[uint32]*device),
+		byKey:      make(map[string]*Mapping),
+	}
+}
+
+// DeviceSize returns the usable size of each device of `p`.
+func (p *Pool) DeviceSize() uint64 {
+	return p.deviceSize
+}
+
+// AddDevice adds the empty device `n` to `p`. It is a no-op if `n` is already
+// part of `p`.
+func (p *Pool) AddDevice(n uint32) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if _, ok := p.devices[n]; ok {
+		return
+	}
+	d := &device{}
+	if p.deviceSize > 0 {
+		d.free = []Region{{Offset: 0, Size: p.deviceSize}}
+	}
+	p.devices[n] = d
+}
+
+// RemoveDevice removes the device `n` from `p`. The device MUST be empty.
+func (p *Pool) RemoveDevice(n uint32) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	d, ok := p.devices[n]
+	if !ok {
+		return ErrDeviceNotFound
+	}
+	if d.used > 0 {
+		return ErrDeviceInUse
+	}
+	delete(p.devices, n)
+	return nil
+}
+
+// Devices returns the devices of `p` in ascending order.
+func (p *Pool) Devices() []uint32 {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.devicesL()
+}
+
+func (p *Pool) devicesL() []uint32 {
+	devices := make([]uint32, 0, len(p.devices))
+	for n := range p.devices {
+		devices = append(devices, n)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i] < devices[j] })
+	return devices
+}
+
+// Empty returns true if the device `n` is part of `p` and has no regions.
+func (p *Pool) Empty(n uint32) bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+	d, ok := p.devices[n]
+	return ok && d.used == 0
+}
+
+// FreeBytes returns the total size of the free extents of the device `n`.
+func (p *Pool) FreeBytes(n uint32) uint64 {
+	p.m.Lock()
+	defer p.m.Unlock()
+	var total uint64
+	if d, ok := p.devices[n]; ok {
+		for _, r := range d.free {
+			total += r.Size
+		}
+	}
+	return total
+}
+
+// Get returns the mapping of `key`.
+func (p *Pool) Get(key string) (Mapping, bool) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if m, ok := p.byKey[key]; ok {
+		return *m, true
+	}
+	return Mapping{}, false
+}
+
+// Mappings returns all mappings ordered by device and offset.
+func (p *Pool) Mappings() []Mapping {
+	p.m.Lock()
+	defer p.m.Unlock()
+	mappings := make([]Mapping, 0, len(p.byKey))
+	for _, m := range p.byKey {
+		mappings = append(mappings, *m)
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		if mappings[i].Device != mappings[j].Device {
+			return mappings[i].Device < mappings[j].Device
+		}
+		return mappings[i].Offset < mappings[j].Offset
+	})
+	return mappings
+}
+
+func (p *Pool) align(size uint64) uint64 {
+	return (size + p.alignment - 1) &^ (p.alignment - 1)
+}
+
+// Acquire adds a reference to the region of `key`. If `key` has no region one
+// of at least `size` bytes is allocated with `value` and `added` is true. If no
+// device has a free extent large enough `ErrNoSpace` is returned.
+func (p *Pool) Acquire(key string, size uint64, value interface{}) (_ Mapping, added bool, _ error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if m, ok := p.byKey[key]; ok {
+		m.RefCount++
+		return *m, false, nil
+	}
+	if size == 0 {
+		return Mapping{}, false, errors.New("region size must not be 0")
+	}
+	size = p.align(size)
+	if size > p.deviceSize {
+		return Mapping{}, false, ErrNoSpace
+	}
+
+	// Best fit: the smallest free extent that is large enough.
+	var (
+		found  bool
+		bestN  uint32
+		bestI  int
+		bestSz uint64
+	)
+	for _, n := range p.devicesL() {
+		for i, r := range p.devices[n].free {
+			if r.Size >= size && (!found || r.Size < bestSz) {
+				found, bestN, bestI, bestSz = true, n, i, r.Size
+			}
+		}
+	}
+	if !found {
+		return Mapping{}, false, ErrNoSpace
+	}
+	d := p.devices[bestN]
+	r := Region{Offset: d.free[bestI].Offset, Size: size}
+	d.take(bestI, r)
+	m := &Mapping{Key: key, Device: bestN, Region: r, RefCount: 1, Value: value}
+	d.used++
+	p.byKey[key] = m
+	return *m, true, nil
+}
+
+// take removes `r` from the free extent at `i`, which MUST contain it.
+func (d *device) take(i int, r Region) {
+	e := d.free[i]
+	var split []Region
+	if r.Offset > e.Offset {
+		split = append(split, Region{Offset: e.Offset, Size: r.Offset - e.Offset})
+	}
+	if r.End() < e.End() {
+		split = append(split, Region{Offset: r.End(), Size: e.End() - r.End()})
+	}
+	d.free = append(d.free[:i], append(split, d.free[i+1:]...)...)
+}
+
+// release returns `r` to the free extents, merging it with its neighbors.
+func (d *device) release(r Region) {
+	i := sort.Search(len(d.free), func(i int) bool { return d.free[i].Offset > r.Offset })
+	d.free = append(d.free, Region{})
+	copy(d.free[i+1:], d.free[i:])
+	d.free[i] = r
+	// Merge with the next extent, then with the previous one.
+	if i+1 < len(d.free) && d.free[i].End() == d.free[i+1].Offset {
+		d.free[i].Size += d.free[i+1].Size
+		d.free = append(d.free[:i+1], d.free[i+2:]...)
+	}
+	if i > 0 && d.free[i-1].End() == d.free[i].Offset {
+		d.free[i-1].Size += d.free[i].Size
+		d.free = append(d.free[:i], d.free[i+1:]...)
+	}
+}
+
+// Insert adds `m` at its device and region. This is used to restore the
+// mappings of a device. The region MUST be aligned and free.
+func (p *Pool) Insert(m Mapping) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if m.Key == "" {
+		return errors.New("mapping has no key")
+	}
+	if m.RefCount == 0 {
+		return fmt.Errorf("mapping '%s' has no references", m.Key)
+	}
+	if _, ok := p.byKey[m.Key]; ok {
+		return ErrAlreadyAllocated
+	}
+	d, ok := p.devices[m.Device]
+	if !ok {
+		return ErrDeviceNotFound
+	}
+	if m.Size == 0 || m.Offset%p.alignment != 0 || m.Size%p.alignment != 0 {
+		return fmt.Errorf("region %s of '%s' is not aligned to %d", m.Region, m.Key, p.alignment)
+	}
+	for i, e := range d.free {
+		if e.Offset <= m.Offset && m.End() <= e.End() {
+			d.take(i, m.Region)
+			d.used++
+			pm := m
+			p.byKey[m.Key] = &pm
+			return nil
+		}
+	}
+	return fmt.Errorf("region %s of '%s' on device %d is not free", m.Region, m.Key, m.Device)
+}
+
+// Release removes a reference to the region of `key`. If it is the last
+// reference the region stays allocated and `last` is true, the caller MUST
+// call `Unmap` once the region is no longer in use.
+func (p *Pool) Release(key string) (_ Mapping, last bool, _ error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	m, ok := p.byKey[key]
+	if !ok {
+		return Mapping{}, false, ErrNotAllocated
+	}
+	if m.RefCount == 1 {
+		return *m, true, nil
+	}
+	m.RefCount--
+	return *m, false, nil
+}
+
+// Unmap frees the region of `key` regardless of its reference count.
+func (p *Pool) Unmap(key string) (Mapping, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	m, ok := p.byKey[key]
+	if !ok {
+		return Mapping{}, ErrNotAllocated
+	}
+	d := p.devices[m.Device]
+	d.release(m.Region)
+	d.used--
+	delete(p.byKey, key)
+	return *m, nil
+}
@@ -0,0 +1,350 @@
+package regionalloc
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const (
+	testAlignment  = 4096
+	testDeviceSize = 16 * testAlignment
+)
+
+func newTestPool(devices ...uint32) *Pool {
+	p := NewPool(testDeviceSize, testAlignment)
+	for _, n := range devices {
+		p.AddDevice(n)
+	}
+	return p
+}
+
+func mustAcquire(t *testing.T, p *Pool, key string, size uint64) Mapping {
+	t.Helper()
+	m, added, err := p.Acquire(key, size, nil)
+	if err != nil {
+		t.Fatalf("acquire of '%s' should not have failed, got: %v", key, err)
+	}
+	if !added {
+		t.Fatalf("acquire of '%s' expected a new region", key)
+	}
+	return m
+}
+
+func freeExtents(p *Pool, n uint32) []Region {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return append([]Region(nil), p.devices[n].free...)
+}
+
+func Test_NewPool_InvalidAlignment_Panics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected NewPool to panic")
+		}
+	}()
+	NewPool(testDeviceSize, 3000)
+}
+
+func Test_NewPool_TruncatesDeviceSize(t *testing.T) {
+	p := NewPool(testDeviceSize+100, testAlignment)
+	if p.DeviceSize() != testDeviceSize {
+		t.Fatalf("expected device size %d, got: %d", testDeviceSize, p.DeviceSize())
+	}
+}
+
+func Test_Acquire_AlignsSize(t *testing.T) {
+	p := newTestPool(0)
+	m := mustAcquire(t, p, "a", 1)
+	if m.Offset != 0 || m.Size != testAlignment {
+		t.Fatalf("expected region [0, %d), got: %s", testAlignment, m.Region)
+	}
+	m = mustAcquire(t, p, "b", testAlignment+1)
+	if m.Offset != testAlignment || m.Size != 2*testAlignment {
+		t.Fatalf("expected region [%d, %d), got: %s", testAlignment, 3*testAlignment, m.Region)
+	}
+}
+
+func Test_Acquire_ZeroSize_Error(t *testing.T) {
+	p := newTestPool(0)
+	if _, _, err := p.Acquire("a", 0, nil); err == nil {
+		t.Fatal("expected an error for a zero size region")
+	}
+}
+
+func Test_Acquire_TooLarge_NoSpace(t *testing.T) {
+	p := newTestPool(0)
+	if _, _, err := p.Acquire("a", testDeviceSize+1, nil); err != ErrNoSpace {
+		t.Fatalf("expected ErrNoSpace, got: %v", err)
+	}
+}
+
+func Test_Acquire_NoDevices_NoSpace(t *testing.T) {
+	p := newTestPool()
+	if _, _, err := p.Acquire("a", 1, nil); err != ErrNoSpace {
+		t.Fatalf("expected ErrNoSpace, got: %v", err)
+	}
+}
+
+func Test_Acquire_FillsDevice(t *testing.T) {
+	p := newTestPool(0)
+	mustAcquire(t, p, "a", testDeviceSize/2)
+	mustAcquire(t, p, "b", testDeviceSize/2)
+	if _, _, err := p.Acquire("c", 1, nil); err != ErrNoSpace {
+		t.Fatalf("expected ErrNoSpace, got: %v", err)
+	}
+	if p.FreeBytes(0) != 0 {
+		t.Fatalf("expected no free bytes, got: %d", p.FreeBytes(0))
+	}
+}
+
+func Test_Acquire_Existing_AddsReference(t *testing.T) {
+	p := newTestPool(0)
+	first := mustAcquire(t, p, "a", testAlignment)
+	m, added, err := p.Acquire("a", 8*testAlignment, nil)
+	if err != nil {
+		t.Fatalf("acquire should not have failed, got: %v", err)
+	}
+	if added {
+		t.Fatal("expected the existing region to be returned")
+	}
+	if m.Region != first.Region || m.RefCount != 2 {
+		t.Fatalf("expected region %s with RefCount 2, got: %s with %d", first.Region, m.Region, m.RefCount)
+	}
+}
+
+func Test_Acquire_BestFit(t *testing.T) {
+	p := newTestPool(0)
+	// Layout: a(2) b(1) c(4) d(1) free(8).
+	mustAcquire(t, p, "a", 2*testAlignment)
+	mustAcquire(t, p, "b", testAlignment)
+	mustAcquire(t, p, "c", 4*testAlignment)
+	mustAcquire(t, p, "d", testAlignment)
+	// Free holes of 2 at offset 0 and 4 at offset 3.
+	for _, k := range []string{"a", "c"} {
+		if _, err := p.Unmap(k); err != nil {
+			t.Fatalf("unmap of '%s' should not have failed, got: %v", k, err)
+		}
+	}
+	// A region of 3 fits best in the hole of 4 rather than the trailing 8.
+	m := mustAcquire(t, p, "e", 3*testAlignment)
+	if m.Offset != 3*testAlignment {
+		t.Fatalf("expected offset %d, got: %d", 3*testAlignment, m.Offset)
+	}
+	// A region of 2 fits exactly in the first hole.
+	m = mustAcquire(t, p, "f", 2*testAlignment)
+	if m.Offset != 0 {
+		t.Fatalf("expected offset 0, got: %d", m.Offset)
+	}
+}
+
+func Test_Acquire_BestFit_AcrossDevices(t *testing.T) {
+	p := newTestPool(0, 1)
+	mustAcquire(t, p, "a", testDeviceSize-2*testAlignment)
+	// Device 1 is empty, device 0 has 2 pages free.
+	m := mustAcquire(t, p, "b", testAlignment)
+	if m.Device != 0 {
+		t.Fatalf("expected the fuller device 0, got: %d", m.Device)
+	}
+	m = mustAcquire(t, p, "c", 4*testAlignment)
+	if m.Device != 1 || m.Offset != 0 {
+		t.Fatalf("expected device 1 at offset 0, got: %d at %d", m.Device, m.Offset)
+	}
+}
+
+func Test_Acquire_Tie_PrefersLowestDevice(t *testing.T) {
+	p := newTestPool(3, 1, 2)
+	m := mustAcquire(t, p, "a", testAlignment)
+	if m.Device != 1 {
+		t.Fatalf("expected device 1, got: %d", m.Device)
+	}
+}
+
+func Test_Unmap_CoalescesNeighbors(t *testing.T) {
+	p := newTestPool(0)
+	for _, k := range []string{"a", "b", "c"} {
+		mustAcquire(t, p, k, testAlignment)
+	}
+	for _, k := range []string{"a", "c", "b"} {
+		if _, err := p.Unmap(k); err != nil {
+			t.Fatalf("unmap of '%s' should not have failed, got: %v", k, err)
+		}
+	}
+	expected := []Region{{Offset: 0, Size: testDeviceSize}}
+	if got := freeExtents(p, 0); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected free extents %v, got: %v", expected, got)
+	}
+	if !p.Empty(0) {
+		t.Fatal("expected device 0 to be empty")
+	}
+}
+
+func Test_Unmap_KeepsExtentsOrdered(t *testing.T) {
+	p := newTestPool(0)
+	for i := 0; i < 5; i++ {
+		mustAcquire(t, p, fmt.Sprintf("k%d", i), testAlignment)
+	}
+	for _, k := range []string{"k3", "k1"} {
+		if _, err := p.Unmap(k); err != nil {
+			t.Fatalf("unmap of '%s' should not have failed, got: %v", k, err)
+		}
+	}
+	expected := []Region{
+		{Offset: testAlignment, Size: testAlignment},
+		{Offset: 3 * testAlignment, Size: testAlignment},
+		{Offset: 5 * testAlignment, Size: testDeviceSize - 5*testAlignment},
+	}
+	if got := freeExtents(p, 0); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected free extents %v, got: %v", expected, got)
+	}
+}
+
+func Test_Unmap_NotAllocated_Error(t *testing.T) {
+	p := newTestPool(0)
+	if _, err := p.Unmap("a"); err != ErrNotAllocated {
+		t.Fatalf("expected ErrNotAllocated, got: %v", err)
+	}
+}
+
+func Test_Release_LastDoesNotFree(t *testing.T) {
+	p := newTestPool(0)
+	mustAcquire(t, p, "a", testAlignment)
+	if _, _, err := p.Acquire("a", testAlignment, nil); err != nil {
+		t.Fatalf("acquire should not have failed, got: %v", err)
+	}
+	m, last, err := p.Release("a")
+	if err != nil || last || m.RefCount != 1 {
+		t.Fatalf("expected RefCount 1 and not last, got: %d, %v, %v", m.RefCount, last, err)
+	}
+	m, last, err = p.Release("a")
+	if err != nil || !last || m.RefCount != 1 {
+		t.Fatalf("expected last reference, got: %d, %v, %v", m.RefCount, last, err)
+	}
+	if _, ok := p.Get("a"); !ok {
+		t.Fatal("expected the region to stay allocated until unmapped")
+	}
+	if p.Empty(0) {
+		t.Fatal("expected device 0 to not be empty")
+	}
+}
+
+func Test_Release_NotAllocated_Error(t *testing.T) {
+	p := newTestPool(0)
+	if _, _, err := p.Release("a"); err != ErrNotAllocated {
+		t.Fatalf("expected ErrNotAllocated, got: %v", err)
+	}
+}
+
+func Test_RemoveDevice(t *testing.T) {
+	p := newTestPool(0, 1)
+	mustAcquire(t, p, "a", testAlignment)
+	if err := p.RemoveDevice(0); err != ErrDeviceInUse {
+		t.Fatalf("expected ErrDeviceInUse, got: %v", err)
+	}
+	if err := p.RemoveDevice(2); err != ErrDeviceNotFound {
+		t.Fatalf("expected ErrDeviceNotFound, got: %v", err)
+	}
+	if err := p.RemoveDevice(1); err != nil {
+		t.Fatalf("remove of an empty device should not have failed, got: %v", err)
+	}
+	if got := p.Devices(); !reflect.DeepEqual(got, []uint32{0}) {
+		t.Fatalf("expected devices [0], got: %v", got)
+	}
+	if p.Empty(1) {
+		t.Fatal("expected a removed device to not be reported empty")
+	}
+}
+
+func Test_AddDevice_Existing_NoOp(t *testing.T) {
+	p := newTestPool(0)
+	mustAcquire(t, p, "a", testAlignment)
+	p.AddDevice(0)
+	if _, ok := p.Get("a"); !ok || p.FreeBytes(0) != testDeviceSize-testAlignment {
+		t.Fatal("expected AddDevice of an existing device to keep its regions")
+	}
+}
+
+func Test_Insert(t *testing.T) {
+	p := newTestPool(0)
+	m := Mapping{Key: "a", Device: 0, Region: Region{Offset: 4 * testAlignment, Size: testAlignment}, RefCount: 3, Value: "v"}
+	if err := p.Insert(m); err != nil {
+		t.Fatalf("insert should not have failed, got: %v", err)
+	}
+	got, ok := p.Get("a")
+	if !ok || !reflect.DeepEqual(got, m) {
+		t.Fatalf("expected mapping %+v, got: %+v", m, got)
+	}
+	expected := []Region{
+		{Offset: 0, Size: 4 * testAlignment},
+		{Offset: 5 * testAlignment, Size: testDeviceSize - 5*testAlignment},
+	}
+	if got := freeExtents(p, 0); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected free extents %v, got: %v", expected, got)
+	}
+}
+
+func Test_Insert_Invalid_Error(t *testing.T) {
+	p := newTestPool(0)
+	mustAcquire(t, p, "used", testAlignment)
+	tests := []struct {
+		name string
+		m    Mapping
+	}{
+		{"no key", Mapping{Region: Region{Offset: testAlignment, Size: testAlignment}, RefCount: 1}},
+		{"no references", Mapping{Key: "a", Region: Region{Offset: testAlignment, Size: testAlignment}}},
+		{"duplicate", Mapping{Key: "used", Region: Region{Offset: testAlignment, Size: testAlignment}, RefCount: 1}},
+		{"unknown device", Mapping{Key: "a", Device: 1, Region: Region{Offset: 0, Size: testAlignment}, RefCount: 1}},
+		{"unaligned", Mapping{Key: "a", Region: Region{Offset: testAlignment + 1, Size: testAlignment}, RefCount: 1}},
+		{"empty", Mapping{Key: "a", Region: Region{Offset: testAlignment, Size: 0}, RefCount: 1}},
+		{"overlapping", Mapping{Key: "a", Region: Region{Offset: 0, Size: 2 * testAlignment}, RefCount: 1}},
+		{"out of range", Mapping{Key: "a", Region: Region{Offset: testDeviceSize, Size: testAlignment}, RefCount: 1}},
+	}
+	for _, test := range tests {
+		if err := p.Insert(test.m); err == nil {
+			t.Errorf("%s: expected insert to fail", test.name)
+		}
+	}
+	if len(p.Mappings()) != 1 || p.FreeBytes(0) != testDeviceSize-testAlignment {
+		t.Fatal("expected failed inserts to leave the pool unmodified")
+	}
+}
+
+func Test_Mappings_Ordered(t *testing.T) {
+	p := newTestPool(0, 1)
+	mustAcquire(t, p, "a", testDeviceSize-testAlignment)
+	mustAcquire(t, p, "b", 2*testAlignment)
+	mustAcquire(t, p, "c", testAlignment)
+	var keys []string
+	for _, m := range p.Mappings() {
+		keys = append(keys, m.Key)
+	}
+	if expected := []string{"a", "c", "b"}; !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected mappings %v, got: %v", expected, keys)
+	}
+}
+
+func Test_Concurrent_AcquireUnmap(t *testing.T) {
+	p := NewPool(1024*testAlignment, testAlignment)
+	p.AddDevice(0)
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("k%d", i)
+			if _, _, err := p.Acquire(key, uint64(i%4+1)*testAlignment, nil); err != nil {
+				t.Errorf("acquire of '%s' should not have failed, got: %v", key, err)
+				return
+			}
+			if _, err := p.Unmap(key); err != nil {
+				t.Errorf("unmap of '%s' should not have failed, got: %v", key, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	expected := []Region{{Offset: 0, Size: 1024 * testAlignment}}
+	if got := freeExtents(p, 0); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected free extents %v, got: %v", expected, got)
+	}
+}
@@ -22,4 +22,7 @@ type Memory2 struct {
 
 	// EnableDeferredCommit is private in the schema. If regenerated need to add back.
 	EnableDeferredCommit bool `json:"EnableDeferredCommit,omitempty"`
+
+	//  The page size that backs the memory of the VM, either `Small` or `Large`. Large pages require the memory to be physically backed.
+	BackingPageSize string `json:"BackingPageSize,omitempty"`
 }
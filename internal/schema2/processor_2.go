@@ -18,4 +18,7 @@ type Processor2 struct {
 	Weight int32 `json:"Weight,omitempty"`
 
 	ExposeVirtualizationExtensions bool `json:"ExposeVirtualizationExtensions,omitempty"`
+
+	//  The CPU group of the host that the processors of the VM are restricted to.
+	CpuGroup *CpuGroup `json:"CpuGroup,omitempty"`
 }
@@ -16,4 +16,8 @@ type VirtualPMemDevice struct {
 	ReadOnly bool `json:"ReadOnly,omitempty"`
 
 	ImageFormat string `json:"ImageFormat,omitempty"`
+
+	SizeBytes uint64 `json:"SizeBytes,omitempty"`
+
+	Mappings map[string]VirtualPMemMapping `json:"Mappings,omitempty"`
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -8,6 +9,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/gcsoutput"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/lcow"
 	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
@@ -34,7 +37,10 @@ const (
 	forwardStderrArgName        = "fwd-stderr"
 	debugArgName                = "debug"
 	outputHandlingArgName       = "output-handling"
+	outputLevelsArgName         = "output-levels"
+	outputDropFieldsArgName     = "output-drop-fields"
 	consolePipeArgName          = "console-pipe"
+	consoleLogArgName           = "console-log"
 	gcsArgName                  = "gcs"
 )
 
@@ -124,12 +130,24 @@ func main() {
 				},
 				cli.StringFlag{
 					Name:  outputHandlingArgName,
-					Usage: "Controls how output from UVM is handled. Use 'stdout' to print all output to stdout",
+					Usage: "Controls how output from UVM is handled. A comma separated list of 'json' (default), 'stdout' and 'file:<path>' relative to the working directory",
+				},
+				cli.StringFlag{
+					Name:  outputLevelsArgName,
+					Usage: "Comma separated list of from=to remappings of the levels of UVM log entries, e.g. debug=info",
+				},
+				cli.StringFlag{
+					Name:  outputDropFieldsArgName,
+					Usage: "Comma separated list of fields removed from UVM log entries",
 				},
 				cli.StringFlag{
 					Name:  consolePipeArgName,
 					Usage: "Named pipe for serial console output (which will be enabled)",
 				},
+				cli.StringFlag{
+					Name:  consoleLogArgName,
+					Usage: "File to capture serial console output to. Ignored if --" + consolePipeArgName + " is set",
+				},
 			},
 			Action: func(c *cli.Context) error {
 				if c.GlobalBool("debug") {
@@ -207,18 +225,28 @@ func main() {
 							if c.IsSet(forwardStderrArgName) {
 								options.ForwardStderr = c.Bool(forwardStderrArgName)
 							}
-							if c.IsSet(outputHandlingArgName) {
-								switch strings.ToLower(c.String(outputHandlingArgName)) {
-								case "stdout":
-									options.OutputHandler = uvm.OutputHandler(func(r io.Reader) { io.Copy(os.Stdout, r) })
-								default:
-									logrus.Fatalf("Unrecognized value '%s' for option %s", c.String(outputHandlingArgName), outputHandlingArgName)
-								}
+						}
+						if c.IsSet(outputHandlingArgName) || c.IsSet(outputLevelsArgName) || c.IsSet(outputDropFieldsArgName) {
+							levels, err := gcsoutput.ParseLevels(c.String(outputLevelsArgName))
+							if err != nil {
+								logrus.Fatalf("Unrecognized value '%s' for option %s: %s", c.String(outputLevelsArgName), outputLevelsArgName, err)
+							}
+							wd, err := os.Getwd()
+							if err != nil {
+								logrus.Fatalf("Failed to get working directory: %s", err)
 							}
+							handler, err := uvm.NewOutputHandler(id, c.String(outputHandlingArgName), levels, gcsoutput.ParseFields(c.String(outputDropFieldsArgName)), wd)
+							if err != nil {
+								logrus.Fatalf("Unrecognized value '%s' for option %s: %s", c.String(outputHandlingArgName), outputHandlingArgName, err)
+							}
+							options.OutputHandler = handler
 						}
 						if c.IsSet(consolePipeArgName) {
 							options.ConsolePipe = c.String(consolePipeArgName)
 						}
+						if c.IsSet(consoleLogArgName) {
+							options.ConsoleLogFile = c.String(consoleLogArgName)
+						}
 
 						if err := run(options, c); err != nil {
 							logrus.WithField("uvm-id", id).Error(err)
@@ -256,7 +284,7 @@ func main() {
 }
 
 func run(options *uvm.OptionsLCOW, c *cli.Context) error {
-	uvm, err := uvm.CreateLCOW(options)
+	uvm, err := uvm.CreateLCOW(context.Background(), options)
 	if err != nil {
 		return err
 	}
@@ -287,7 +315,7 @@ func run(options *uvm.OptionsLCOW, c *cli.Context) error {
 	return nil
 }
 
-func execViaGcs(cs *hcs.System, c *cli.Context) error {
+func execViaGcs(cs cow.ComputeSystem, c *cli.Context) error {
 	var copyOut, copyErr bool
 	if c.String(outputHandlingArgName) == "stdout" {
 		copyOut = c.Bool(forwardStdoutArgName)
@@ -0,0 +1,117 @@
+package trace
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Exporter receives every span when it ends.
+type Exporter interface {
+	// ExportSpan exports `sd`. It is called synchronously when the span ends
+	// and MUST not modify `sd`.
+	ExportSpan(sd *SpanData)
+}
+
+var (
+	exportersMu sync.Mutex
+	// exportersList is replaced rather than modified so that it can be
+	// iterated without holding `exportersMu`.
+	exportersList []Exporter
+)
+
+// RegisterExporter adds `e` to the exporters every span is exported to.
+func RegisterExporter(e Exporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	exportersList = append(append([]Exporter(nil), exportersList...), e)
+}
+
+// UnregisterExporter removes `e` from the exporters every span is exported to.
+func UnregisterExporter(e Exporter) {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	var l []Exporter
+	for _, x := range exportersList {
+		if x != e {
+			l = append(l, x)
+		}
+	}
+	exportersList = l
+}
+
+func exporters() []Exporter {
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	return exportersList
+}
+
+// MemoryExporter keeps every exported span in memory. It is intended for
+// tests.
+type MemoryExporter struct {
+	m     sync.Mutex
+	spans []*SpanData
+}
+
+var _ = (Exporter)(&MemoryExporter{})
+
+// ExportSpan records `sd`.
+func (me *MemoryExporter) ExportSpan(sd *SpanData) {
+	me.m.Lock()
+	defer me.m.Unlock()
+	me.spans = append(me.spans, sd)
+}
+
+// Spans returns all recorded spans in the order they ended.
+func (me *MemoryExporter) Spans() []*SpanData {
+	me.m.Lock()
+	defer me.m.Unlock()
+	return append([]*SpanData(nil), me.spans...)
+}
+
+// Span returns the last recorded span named `name` or `nil` if there is none.
+func (me *MemoryExporter) Span(name string) *SpanData {
+	me.m.Lock()
+	defer me.m.Unlock()
+	for i := len(me.spans) - 1; i >= 0; i-- {
+		if me.spans[i].Name == name {
+			return me.spans[i]
+		}
+	}
+	return nil
+}
+
+// Reset discards all recorded spans.
+func (me *MemoryExporter) Reset() {
+	me.m.Lock()
+	defer me.m.Unlock()
+	me.spans = nil
+}
+
+// LogrusExporter writes every span as a debug log entry.
+type LogrusExporter struct{}
+
+var _ = (Exporter)(LogrusExporter{})
+
+// ExportSpan logs `sd`.
+func (LogrusExporter) ExportSpan(sd *SpanData) {
+	fields := logrus.Fields{
+		"name":       sd.Name,
+		"traceID":    sd.TraceID.String(),
+		"spanID":     sd.SpanID.String(),
+		"startTime":  sd.StartTime,
+		"durationMs": sd.Duration().Seconds() * 1000,
+	}
+	if sd.ParentSpanID != (SpanID{}) {
+		fields["parentSpanID"] = sd.ParentSpanID.String()
+	}
+	for k, v := range sd.Attributes {
+		fields[k] = v
+	}
+	entry := logrus.WithFields(fields)
+	if sd.Err != "" {
+		entry.WithField(logrus.ErrorKey, sd.Err).Debug("Span")
+	} else {
+		entry.Debug("Span")
+	}
+}
@@ -0,0 +1,171 @@
+// Package trace records spans for long running operations in the style of
+// OpenCensus. A span is started with `StartSpan` which returns a context
+// carrying the span so that the spans of nested operations are recorded as its
+// children. When a span ends it is exported to every registered `Exporter`.
+//
+// With no registered exporter spans are still propagated but are discarded
+// when they end.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// TraceID identifies all spans of one trace.
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext identifies a span.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// SpanData is the record of an ended span passed to each `Exporter`.
+type SpanData struct {
+	SpanContext
+	// ParentSpanID is the id of the parent span or the zero value if this is
+	// the root span of the trace.
+	ParentSpanID SpanID
+	Name         string
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]interface{}
+	// Err is the message of the error the operation failed with or empty if
+	// the operation succeeded.
+	Err string
+}
+
+// Duration returns the duration of the span.
+func (sd *SpanData) Duration() time.Duration {
+	return sd.EndTime.Sub(sd.StartTime)
+}
+
+// Attribute is a key value pair describing a span.
+type Attribute struct {
+	key   string
+	value interface{}
+}
+
+// StringAttribute returns a string attribute.
+func StringAttribute(key, value string) Attribute {
+	return Attribute{key: key, value: value}
+}
+
+// Int64Attribute returns an integer attribute.
+func Int64Attribute(key string, value int64) Attribute {
+	return Attribute{key: key, value: value}
+}
+
+// BoolAttribute returns a boolean attribute.
+func BoolAttribute(key string, value bool) Attribute {
+	return Attribute{key: key, value: value}
+}
+
+// Span is an operation that is being traced. All methods are safe to call on
+// a `nil` span and concurrently.
+type Span struct {
+	m     sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+type spanContextKey struct{}
+
+// StartSpan starts the span `name` as a child of the span in `ctx` (if any)
+// and returns a copy of `ctx` carrying the new span. The caller MUST call
+// `End` once the operation completes.
+func StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	s := &Span{
+		data: SpanData{
+			Name:       name,
+			StartTime:  time.Now(),
+			Attributes: make(map[string]interface{}),
+		},
+	}
+	if parent := FromContext(ctx); parent != nil {
+		s.data.TraceID = parent.data.TraceID
+		s.data.ParentSpanID = parent.data.SpanID
+	} else {
+		rand.Read(s.data.TraceID[:])
+	}
+	rand.Read(s.data.SpanID[:])
+	s.AddAttributes(attrs...)
+	return context.WithValue(ctx, spanContextKey{}, s), s
+}
+
+// FromContext returns the span in `ctx` or `nil` if there is none.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanContextKey{}).(*Span)
+	return s
+}
+
+// SpanContext returns the identity of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// AddAttributes adds `attrs` to the span replacing any attribute with the same
+// key.
+func (s *Span) AddAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	for _, a := range attrs {
+		s.data.Attributes[a.key] = a.value
+	}
+}
+
+// SetError records that the operation failed with `err`. It does nothing if
+// `err == nil`.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.data.Err = err.Error()
+}
+
+// End ends the span and exports it. Only the first call has any effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.m.Lock()
+	if s.ended {
+		s.m.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	sd := s.data
+	sd.Attributes = make(map[string]interface{}, len(s.data.Attributes))
+	for k, v := range s.data.Attributes {
+		sd.Attributes[k] = v
+	}
+	s.m.Unlock()
+
+	for _, e := range exporters() {
+		e.ExportSpan(&sd)
+	}
+}
@@ -0,0 +1,107 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func setupTestExporter(t *testing.T) *MemoryExporter {
+	e := &MemoryExporter{}
+	RegisterExporter(e)
+	return e
+}
+
+func Test_StartSpan_Child_SameTrace(t *testing.T) {
+	e := setupTestExporter(t)
+	defer UnregisterExporter(e)
+
+	ctx, parent := StartSpan(context.Background(), "parent")
+	_, child := StartSpan(ctx, "child", StringAttribute("id", t.Name()))
+	child.End()
+	parent.End()
+
+	spans := e.Spans()
+	if len(spans) != 2 || spans[0].Name != "child" || spans[1].Name != "parent" {
+		t.Fatalf("expected spans [child parent], got: %+v", spans)
+	}
+	if spans[0].TraceID != spans[1].TraceID {
+		t.Fatalf("expected child trace: %s, got: %s", spans[1].TraceID, spans[0].TraceID)
+	}
+	if spans[0].ParentSpanID != spans[1].SpanID {
+		t.Fatalf("expected child parent: %s, got: %s", spans[1].SpanID, spans[0].ParentSpanID)
+	}
+	if spans[1].ParentSpanID != (SpanID{}) {
+		t.Fatalf("expected root span to have no parent, got: %s", spans[1].ParentSpanID)
+	}
+	if v := spans[0].Attributes["id"]; v != t.Name() {
+		t.Fatalf("expected attribute id: '%s', got: '%v'", t.Name(), v)
+	}
+}
+
+func Test_StartSpan_NoParent_NewTrace(t *testing.T) {
+	e := setupTestExporter(t)
+	defer UnregisterExporter(e)
+
+	_, s1 := StartSpan(context.Background(), "one")
+	_, s2 := StartSpan(context.Background(), "two")
+	s1.End()
+	s2.End()
+
+	if e.Span("one").TraceID == e.Span("two").TraceID {
+		t.Fatal("expected unrelated spans to have different traces")
+	}
+}
+
+func Test_Span_SetError_End_Once(t *testing.T) {
+	e := setupTestExporter(t)
+	defer UnregisterExporter(e)
+
+	_, s := StartSpan(context.Background(), t.Name())
+	s.SetError(nil)
+	s.SetError(errors.New("failed"))
+	s.AddAttributes(Int64Attribute("count", 2), BoolAttribute("ok", false))
+	s.End()
+	s.End()
+	s.AddAttributes(StringAttribute("late", "ignored"))
+
+	spans := e.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got: %d", len(spans))
+	}
+	sd := spans[0]
+	if sd.Err != "failed" {
+		t.Fatalf("expected error: 'failed', got: '%s'", sd.Err)
+	}
+	if sd.Attributes["count"] != int64(2) || sd.Attributes["ok"] != false {
+		t.Fatalf("expected attributes count=2 ok=false, got: %v", sd.Attributes)
+	}
+	if _, ok := sd.Attributes["late"]; ok {
+		t.Fatal("expected attribute added after End to not be exported")
+	}
+	if sd.Duration() < 0 {
+		t.Fatalf("expected non negative duration, got: %v", sd.Duration())
+	}
+}
+
+func Test_Span_Nil_NoPanic(t *testing.T) {
+	var s *Span
+	s.AddAttributes(StringAttribute("a", "b"))
+	s.SetError(errors.New("failed"))
+	s.End()
+	if s.SpanContext() != (SpanContext{}) {
+		t.Fatal("expected zero span context for nil span")
+	}
+}
+
+func Test_UnregisterExporter_NotExported(t *testing.T) {
+	e := setupTestExporter(t)
+	UnregisterExporter(e)
+
+	_, s := StartSpan(context.Background(), t.Name())
+	s.End()
+
+	if len(e.Spans()) != 0 {
+		t.Fatalf("expected no spans, got: %+v", e.Spans())
+	}
+}
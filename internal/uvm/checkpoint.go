@@ -0,0 +1,658 @@
+package uvm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/Microsoft/hcsshim/internal/atomicfile"
+	"github.com/Microsoft/hcsshim/internal/devicealloc"
+	"github.com/Microsoft/hcsshim/internal/guid"
+	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/hns"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/regionalloc"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
+	"github.com/Microsoft/hcsshim/internal/trace"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// CheckpointStateFile is the file name of the saved utility VM state in a
+	// checkpoint directory.
+	CheckpointStateFile = "uvm.vmrs"
+	// CheckpointManifestFile is the file name of the utility VM resource
+	// manifest in a checkpoint directory.
+	CheckpointManifestFile = "uvm.json"
+)
+
+// CheckpointVPMem describes a VPMem device attached to a utility VM at the
+// time of a checkpoint.
+//
+// A device shared by several layers has `Packed` set and a generated
+// `HostPath`. Its layers are described by `CheckpointVPMemMapping`.
+type CheckpointVPMem struct {
+	DeviceNumber uint32 `json:"DeviceNumber"`
+	HostPath     string `json:"HostPath"`
+	UVMPath      string `json:"UVMPath,omitempty"`
+	RefCount     uint32 `json:"RefCount"`
+	Packed       bool   `json:"Packed,omitempty"`
+}
+
+// CheckpointVPMemMapping describes a layer mapped into a shared VPMem device
+// at the time of a checkpoint.
+type CheckpointVPMemMapping struct {
+	DeviceNumber uint32 `json:"DeviceNumber"`
+	Offset       uint64 `json:"Offset"`
+	Size         uint64 `json:"Size"`
+	HostPath     string `json:"HostPath"`
+	UVMPath      string `json:"UVMPath,omitempty"`
+	RefCount     uint32 `json:"RefCount"`
+}
+
+// CheckpointSCSI describes a SCSI attachment of a utility VM at the time of a
+// checkpoint.
+type CheckpointSCSI struct {
+	Controller     int    `json:"Controller"`
+	LUN            int32  `json:"LUN"`
+	HostPath       string `json:"HostPath"`
+	UVMPath        string `json:"UVMPath,omitempty"`
+	IsLayer        bool   `json:"IsLayer,omitempty"`
+	RefCount       uint32 `json:"RefCount"`
+	AttachmentType string `json:"AttachmentType,omitempty"`
+	ReadOnly       bool   `json:"ReadOnly,omitempty"`
+}
+
+// CheckpointVSMB describes a VSMB share of a utility VM at the time of a
+// checkpoint.
+type CheckpointVSMB struct {
+	HostPath string                            `json:"HostPath"`
+	Name     string                            `json:"Name"`
+	RefCount uint32                            `json:"RefCount"`
+	Options  *hcsschema.VirtualSmbShareOptions `json:"Options,omitempty"`
+}
+
+// CheckpointPlan9 describes a Plan9 share of a Linux utility VM at the time of
+// a checkpoint.
+type CheckpointPlan9 struct {
+	hcsschema.Plan9Share
+	UVMPath   string `json:"UVMPath,omitempty"`
+	RefCount  uint32 `json:"RefCount,omitempty"`
+	Exclusive bool   `json:"Exclusive,omitempty"`
+}
+
+// CheckpointNIC describes a network adapter in a network namespace of a
+// utility VM at the time of a checkpoint.
+type CheckpointNIC struct {
+	ID         string `json:"ID"`
+	EndpointID string `json:"EndpointID"`
+	MacAddress string `json:"MacAddress,omitempty"`
+}
+
+// CheckpointNamespace describes a network namespace of a utility VM at the time
+// of a checkpoint.
+type CheckpointNamespace struct {
+	ID   string          `json:"ID"`
+	NICs []CheckpointNIC `json:"NICs,omitempty"`
+}
+
+// CheckpointManifest describes a saved utility VM and every resource that was
+// attached to it at the time of the save. It is stored as
+// `CheckpointManifestFile` next to the saved state.
+type CheckpointManifest struct {
+	ID              string `json:"ID"`
+	Owner           string `json:"Owner"`
+	OperatingSystem string `json:"OperatingSystem"`
+	// Document is the HCS document the utility VM was created with. It does
+	// not include any of the resources that were added after create.
+	Document json.RawMessage `json:"Document"`
+
+	ProcessorCount      int32  `json:"ProcessorCount"`
+	MemorySizeInMB      int32  `json:"MemorySizeInMB,omitempty"`
+	AllowOvercommit     bool   `json:"AllowOvercommit,omitempty"`
+	MemoryMaximumInMB   int32  `json:"MemoryMaximumInMB,omitempty"`
+	ProcessorLimit      int32  `json:"ProcessorLimit,omitempty"`
+	ProcessorWeight     int32  `json:"ProcessorWeight,omitempty"`
+	SCSIControllerCount uint32 `json:"SCSIControllerCount"`
+	VPMemMaxCount       uint32 `json:"VPMemMaxCount,omitempty"`
+	VPMemMaxSizeBytes   uint64 `json:"VPMemMaxSizeBytes,omitempty"`
+	VPMemMultiMapping   bool   `json:"VPMemMultiMapping,omitempty"`
+	// ForwardOutput is `true` if the utility VM forwards guest output to the
+	// host over HvSocket.
+	ForwardOutput bool `json:"ForwardOutput,omitempty"`
+
+	ContainerCounter uint64 `json:"ContainerCounter"`
+	VSMBCounter      uint64 `json:"VSMBCounter,omitempty"`
+	Plan9Counter     uint64 `json:"Plan9Counter,omitempty"`
+
+	VPMem         []CheckpointVPMem        `json:"VPMem,omitempty"`
+	VPMemMappings []CheckpointVPMemMapping `json:"VPMemMappings,omitempty"`
+	SCSI          []CheckpointSCSI         `json:"SCSI,omitempty"`
+	VSMB          []CheckpointVSMB         `json:"VSMB,omitempty"`
+	Plan9         []CheckpointPlan9        `json:"Plan9,omitempty"`
+	Namespaces    []CheckpointNamespace    `json:"Namespaces,omitempty"`
+}
+
+// checkpointSystem is the subset of compute system operations used to save a
+// utility VM. It is implemented by `cow.ComputeSystem`.
+type checkpointSystem interface {
+	Save(options interface{}) error
+}
+
+// SaveCheckpoint saves the state of the utility VM to `CheckpointStateFile` and
+// a manifest of all attached resources to `CheckpointManifestFile` in the
+// directory `path`. The directory is created if it does not exist.
+//
+// The utility VM MUST be paused before it can be saved.
+func (uvm *UtilityVM) SaveCheckpoint(path string) (err error) {
+	op := "uvm::SaveCheckpoint"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+		"path":          path,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	return uvm.saveCheckpoint(uvm.hcsSystem, path)
+}
+
+func (uvm *UtilityVM) saveCheckpoint(cs checkpointSystem, path string) error {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return err
+	}
+	m, err := uvm.checkpointManifest()
+	if err != nil {
+		return err
+	}
+	statePath := filepath.Join(path, CheckpointStateFile)
+	options := &hcsschema.SaveOptions{
+		SaveType:          "ToFile",
+		SaveStateFilePath: statePath,
+	}
+	if err := cs.Save(options); err != nil {
+		return err
+	}
+	if err := writeCheckpointManifest(filepath.Join(path, CheckpointManifestFile), m); err != nil {
+		os.Remove(statePath)
+		return err
+	}
+	return nil
+}
+
+// checkpointManifest returns a manifest of the utility VM and all of its
+// currently attached resources.
+func (uvm *UtilityVM) checkpointManifest() (*CheckpointManifest, error) {
+	if len(uvm.createDocument) == 0 {
+		return nil, fmt.Errorf("utility VM %s has no create document to checkpoint", uvm.id)
+	}
+
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+
+	m := &CheckpointManifest{
+		ID:                  uvm.id,
+		Owner:               uvm.owner,
+		OperatingSystem:     uvm.operatingSystem,
+		Document:            json.RawMessage(uvm.createDocument),
+		ProcessorCount:      uvm.processorCount,
+		MemorySizeInMB:      uvm.memorySizeInMB,
+		AllowOvercommit:     uvm.allowOvercommit,
+		MemoryMaximumInMB:   uvm.memoryMaximumInMB,
+		ProcessorLimit:      uvm.processorLimit,
+		ProcessorWeight:     uvm.processorWeight,
+		SCSIControllerCount: uvm.scsiControllerCount,
+		VPMemMaxCount:       uvm.vpmemMaxCount,
+		VPMemMaxSizeBytes:   uvm.vpmemMaxSizeBytes,
+		VPMemMultiMapping:   uvm.vpmemMultiMapping,
+		ForwardOutput:       uvm.outputHandler != nil,
+		ContainerCounter:    uvm.containerCounter,
+		VSMBCounter:         uvm.vsmbCounter,
+		Plan9Counter:        uvm.plan9Counter,
+	}
+	for _, e := range uvm.vpmemDevices.Entries() {
+		vi := e.Value.(*vpmemInfo)
+		m.VPMem = append(m.VPMem, CheckpointVPMem{
+			DeviceNumber: uint32(e.Slot),
+			HostPath:     e.Key,
+			UVMPath:      vi.UVMPath,
+			RefCount:     e.RefCount,
+			Packed:       vi.Packed,
+		})
+	}
+	for _, vm := range uvm.vpmemMappings.Mappings() {
+		m.VPMemMappings = append(m.VPMemMappings, CheckpointVPMemMapping{
+			DeviceNumber: vm.Device,
+			Offset:       vm.Offset,
+			Size:         vm.Size,
+			HostPath:     vm.Key,
+			UVMPath:      vm.Value.(*vpmemInfo).UVMPath,
+			RefCount:     vm.RefCount,
+		})
+	}
+	for _, e := range uvm.scsiLocations.Entries() {
+		si := e.Value.(*scsiInfo)
+		m.SCSI = append(m.SCSI, CheckpointSCSI{
+			Controller:     e.Controller,
+			LUN:            int32(e.Slot),
+			HostPath:       e.Key,
+			UVMPath:        si.UVMPath,
+			IsLayer:        si.IsLayer,
+			RefCount:       e.RefCount,
+			AttachmentType: si.AttachmentType,
+			ReadOnly:       si.ReadOnly,
+		})
+	}
+	for _, e := range uvm.vsmbShares.Entries() {
+		share := e.Value.(*vsmbShare)
+		m.VSMB = append(m.VSMB, CheckpointVSMB{
+			HostPath: e.Key,
+			Name:     share.Name,
+			RefCount: e.RefCount,
+			Options:  share.Options,
+		})
+	}
+	sort.Slice(m.VSMB, func(i, j int) bool { return m.VSMB[i].Name < m.VSMB[j].Name })
+	for _, e := range uvm.plan9Shares.Entries() {
+		pi := e.Value.(*plan9Info)
+		m.Plan9 = append(m.Plan9, CheckpointPlan9{
+			Plan9Share: pi.Settings,
+			UVMPath:    pi.UVMPath,
+			RefCount:   e.RefCount,
+			Exclusive:  pi.Exclusive,
+		})
+	}
+	sort.Slice(m.Plan9, func(i, j int) bool { return m.Plan9[i].Name < m.Plan9[j].Name })
+	for id, ns := range uvm.namespaces {
+		cns := CheckpointNamespace{ID: id}
+		for endpointID, nic := range ns.nics {
+			cns.NICs = append(cns.NICs, CheckpointNIC{
+				ID:         nic.ID.String(),
+				EndpointID: endpointID,
+				MacAddress: nic.Endpoint.MacAddress,
+			})
+		}
+		sort.Slice(cns.NICs, func(i, j int) bool { return cns.NICs[i].ID < cns.NICs[j].ID })
+		m.Namespaces = append(m.Namespaces, cns)
+	}
+	sort.Slice(m.Namespaces, func(i, j int) bool { return m.Namespaces[i].ID < m.Namespaces[j].ID })
+	return m, nil
+}
+
+// writeCheckpointManifest atomically writes `m` to `path`.
+func writeCheckpointManifest(path string, m *CheckpointManifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, b, 0600)
+}
+
+// ReadCheckpointManifest reads the utility VM manifest from the checkpoint
+// directory `path`.
+func ReadCheckpointManifest(path string) (*CheckpointManifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(path, CheckpointManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	m := &CheckpointManifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checkpoint manifest in '%s': %s", path, err)
+	}
+	if m.OperatingSystem != "windows" && m.OperatingSystem != "linux" {
+		return nil, fmt.Errorf("checkpoint manifest in '%s' has unsupported operating system '%s'", path, m.OperatingSystem)
+	}
+	if len(m.Document) == 0 {
+		return nil, fmt.Errorf("checkpoint manifest in '%s' has no create document", path)
+	}
+	return m, nil
+}
+
+// restoreDocument returns the HCS document that restores the utility VM
+// described by `m` from the saved state at `statePath`.
+//
+// HCS requires the restored VM to have the same devices as when it was saved
+// so every resource added after create is added back to the create document.
+func (m *CheckpointManifest) restoreDocument(statePath string) (*hcsschema.ComputeSystem, error) {
+	doc := &hcsschema.ComputeSystem{}
+	if err := json.Unmarshal(m.Document, doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checkpoint create document: %s", err)
+	}
+	if doc.VirtualMachine == nil {
+		return nil, fmt.Errorf("checkpoint create document is not a virtual machine")
+	}
+	doc.Owner = m.Owner
+	doc.VirtualMachine.RestoreState = &hcsschema.RestoreState{
+		SaveStateFilePath: statePath,
+	}
+	if doc.VirtualMachine.Devices == nil {
+		doc.VirtualMachine.Devices = &hcsschema.Devices{}
+	}
+	devices := doc.VirtualMachine.Devices
+
+	for _, si := range m.SCSI {
+		if devices.Scsi == nil {
+			devices.Scsi = make(map[string]hcsschema.Scsi)
+		}
+		controller := strconv.Itoa(si.Controller)
+		c, ok := devices.Scsi[controller]
+		if !ok || c.Attachments == nil {
+			c.Attachments = make(map[string]hcsschema.Attachment)
+		}
+		lun := strconv.Itoa(int(si.LUN))
+		if _, ok := c.Attachments[lun]; !ok {
+			c.Attachments[lun] = hcsschema.Attachment{
+				Path:     si.HostPath,
+				Type_:    si.AttachmentType,
+				ReadOnly: si.ReadOnly,
+			}
+		}
+		devices.Scsi[controller] = c
+	}
+
+	if len(m.VPMem) > 0 {
+		if devices.VirtualPMem == nil {
+			return nil, fmt.Errorf("checkpoint has VPMem devices but create document has no VPMem controller")
+		}
+		if devices.VirtualPMem.Devices == nil {
+			devices.VirtualPMem.Devices = make(map[string]hcsschema.VirtualPMemDevice)
+		}
+		for _, vi := range m.VPMem {
+			deviceNumber := strconv.FormatUint(uint64(vi.DeviceNumber), 10)
+			if _, ok := devices.VirtualPMem.Devices[deviceNumber]; ok {
+				continue
+			}
+			if vi.Packed {
+				devices.VirtualPMem.Devices[deviceNumber] = hcsschema.VirtualPMemDevice{
+					ReadOnly:    true,
+					ImageFormat: "Vhd1",
+					SizeBytes:   m.VPMemMaxSizeBytes,
+					Mappings:    make(map[string]hcsschema.VirtualPMemMapping),
+				}
+			} else {
+				devices.VirtualPMem.Devices[deviceNumber] = hcsschema.VirtualPMemDevice{
+					HostPath:    vi.HostPath,
+					ReadOnly:    true,
+					ImageFormat: "Vhd1",
+				}
+			}
+		}
+		for _, vm := range m.VPMemMappings {
+			deviceNumber := strconv.FormatUint(uint64(vm.DeviceNumber), 10)
+			d, ok := devices.VirtualPMem.Devices[deviceNumber]
+			if !ok || d.Mappings == nil {
+				return nil, fmt.Errorf("checkpoint VPMem mapping '%s' is not on a shared VPMem device", vm.HostPath)
+			}
+			d.Mappings[strconv.FormatUint(vm.Offset, 10)] = hcsschema.VirtualPMemMapping{
+				HostPath:    vm.HostPath,
+				ImageFormat: "Vhd1",
+			}
+		}
+	}
+
+	if len(m.VSMB) > 0 {
+		if devices.VirtualSmb == nil {
+			devices.VirtualSmb = &hcsschema.VirtualSmb{}
+		}
+		existing := make(map[string]bool)
+		for _, s := range devices.VirtualSmb.Shares {
+			existing[s.Name] = true
+		}
+		for _, vs := range m.VSMB {
+			if !existing[vs.Name] {
+				devices.VirtualSmb.Shares = append(devices.VirtualSmb.Shares, hcsschema.VirtualSmbShare{
+					Name:    vs.Name,
+					Path:    vs.HostPath,
+					Options: vs.Options,
+				})
+			}
+		}
+	}
+
+	if len(m.Plan9) > 0 {
+		if devices.Plan9 == nil {
+			devices.Plan9 = &hcsschema.Plan9{}
+		}
+		for _, share := range m.Plan9 {
+			devices.Plan9.Shares = append(devices.Plan9.Shares, share.Plan9Share)
+		}
+	}
+
+	for _, ns := range m.Namespaces {
+		for _, nic := range ns.NICs {
+			if devices.NetworkAdapters == nil {
+				devices.NetworkAdapters = make(map[string]hcsschema.NetworkAdapter)
+			}
+			devices.NetworkAdapters[nic.ID] = hcsschema.NetworkAdapter{
+				EndpointId: nic.EndpointID,
+				MacAddress: nic.MacAddress,
+			}
+		}
+	}
+	return doc, nil
+}
+
+// newUtilityVMFromManifest returns a utility VM whose resource tracking
+// matches `m`. The returned utility VM has no compute system.
+func newUtilityVMFromManifest(m *CheckpointManifest) (*UtilityVM, error) {
+	uvm := &UtilityVM{
+		id:                  m.ID,
+		owner:               m.Owner,
+		operatingSystem:     m.OperatingSystem,
+		processorCount:      m.ProcessorCount,
+		memorySizeInMB:      m.MemorySizeInMB,
+		allowOvercommit:     m.AllowOvercommit,
+		memoryMaximumInMB:   m.MemoryMaximumInMB,
+		processorLimit:      m.ProcessorLimit,
+		processorWeight:     m.ProcessorWeight,
+		containerCounter:    m.ContainerCounter,
+		vsmbCounter:         m.VSMBCounter,
+		plan9Counter:        m.Plan9Counter,
+		vpmemMaxCount:       m.VPMemMaxCount,
+		vpmemMaxSizeBytes:   m.VPMemMaxSizeBytes,
+		vpmemMultiMapping:   m.VPMemMultiMapping,
+		scsiControllerCount: m.SCSIControllerCount,
+		createDocument:      []byte(m.Document),
+	}
+	uvm.initDevices()
+	for _, vi := range m.VPMem {
+		if err := uvm.vpmemDevices.Insert(devicealloc.Entry{
+			Key:      vi.HostPath,
+			Location: devicealloc.Location{Slot: int(vi.DeviceNumber)},
+			RefCount: restoredRefCount(vi.RefCount),
+			Value:    &vpmemInfo{UVMPath: vi.UVMPath, Packed: vi.Packed},
+		}); err != nil {
+			return nil, fmt.Errorf("failed to restore VPMem device '%s': %s", vi.HostPath, err)
+		}
+		if vi.Packed {
+			uvm.vpmemMappings.AddDevice(vi.DeviceNumber)
+		}
+	}
+	for _, vm := range m.VPMemMappings {
+		if err := uvm.vpmemMappings.Insert(regionalloc.Mapping{
+			Key:      vm.HostPath,
+			Device:   vm.DeviceNumber,
+			Region:   regionalloc.Region{Offset: vm.Offset, Size: vm.Size},
+			RefCount: restoredRefCount(vm.RefCount),
+			Value:    &vpmemInfo{UVMPath: vm.UVMPath},
+		}); err != nil {
+			return nil, fmt.Errorf("failed to restore VPMem mapping '%s': %s", vm.HostPath, err)
+		}
+	}
+	for _, si := range m.SCSI {
+		if err := uvm.scsiLocations.Insert(devicealloc.Entry{
+			Key:      si.HostPath,
+			Location: devicealloc.Location{Controller: si.Controller, Slot: int(si.LUN)},
+			RefCount: restoredRefCount(si.RefCount),
+			Value: &scsiInfo{
+				UVMPath:        si.UVMPath,
+				IsLayer:        si.IsLayer,
+				AttachmentType: si.AttachmentType,
+				ReadOnly:       si.ReadOnly,
+			},
+		}); err != nil {
+			return nil, fmt.Errorf("failed to restore SCSI attachment '%s': %s", si.HostPath, err)
+		}
+	}
+	for _, vs := range m.VSMB {
+		if err := uvm.vsmbShares.Insert(devicealloc.Entry{
+			Key:      vs.HostPath,
+			Location: devicealloc.Location{Slot: uvm.vsmbShares.Len()},
+			RefCount: restoredRefCount(vs.RefCount),
+			Value:    &vsmbShare{Name: vs.Name, Options: vs.Options},
+		}); err != nil {
+			return nil, fmt.Errorf("failed to restore VSMB share '%s': %s", vs.HostPath, err)
+		}
+	}
+	for _, share := range m.Plan9 {
+		key := plan9SettingsKey(share.Plan9Share)
+		if share.Exclusive {
+			key = plan9ExclusiveKey(share.Name)
+		} else if _, ok := uvm.plan9Shares.Get(key); ok {
+			// Manifests written before Plan9 shares were ref-counted can have
+			// several shares of the same key. Each is kept so that it is
+			// still removed by name.
+			key += share.Name
+		}
+		if err := uvm.plan9Shares.Insert(devicealloc.Entry{
+			Key:      key,
+			Location: devicealloc.Location{Slot: uvm.plan9Shares.Len()},
+			RefCount: restoredRefCount(share.RefCount),
+			Value:    &plan9Info{Settings: share.Plan9Share, UVMPath: share.UVMPath, Exclusive: share.Exclusive},
+		}); err != nil {
+			return nil, fmt.Errorf("failed to restore Plan9 share '%s': %s", share.Name, err)
+		}
+	}
+	for _, ns := range m.Namespaces {
+		if uvm.namespaces == nil {
+			uvm.namespaces = make(map[string]*namespaceInfo)
+		}
+		nsi := &namespaceInfo{nics: make(map[string]*nicInfo)}
+		for _, nic := range ns.NICs {
+			nsi.nics[nic.EndpointID] = &nicInfo{
+				ID: guid.FromString(nic.ID),
+				Endpoint: &hns.HNSEndpoint{
+					Id:         nic.EndpointID,
+					MacAddress: nic.MacAddress,
+				},
+			}
+		}
+		uvm.namespaces[ns.ID] = nsi
+	}
+	return uvm, nil
+}
+
+// restoredRefCount returns the reference count of a restored device.
+// Manifests written before SCSI attachments other than layers were
+// ref-counted record them with a count of `0`.
+func restoredRefCount(refCount uint32) uint32 {
+	if refCount == 0 {
+		return 1
+	}
+	return refCount
+}
+
+// validate verifies that `m` can be restored.
+func (m *CheckpointManifest) validate() error {
+	if m.SCSIControllerCount > maxSCSIControllers {
+		return fmt.Errorf("checkpoint SCSI controller count %d is invalid", m.SCSIControllerCount)
+	}
+	for _, si := range m.SCSI {
+		if si.Controller < 0 || si.Controller >= int(m.SCSIControllerCount) || si.LUN < 0 || si.LUN >= scsiLUNsPerController {
+			return fmt.Errorf("checkpoint SCSI attachment '%s' at %d:%d is out of range", si.HostPath, si.Controller, si.LUN)
+		}
+	}
+	for _, vi := range m.VPMem {
+		if vi.DeviceNumber >= m.VPMemMaxCount {
+			return fmt.Errorf("checkpoint VPMem device '%s' at %d is out of range", vi.HostPath, vi.DeviceNumber)
+		}
+	}
+	if len(m.VPMemMappings) > 0 && !m.VPMemMultiMapping {
+		return fmt.Errorf("checkpoint has VPMem mappings but multi-mapping is not enabled")
+	}
+	if len(m.VSMB) > 0 && m.OperatingSystem != "windows" {
+		return fmt.Errorf("checkpoint has VSMB shares for a %s utility VM", m.OperatingSystem)
+	}
+	if len(m.Plan9) > 0 && m.OperatingSystem != "linux" {
+		return fmt.Errorf("checkpoint has Plan9 shares for a %s utility VM", m.OperatingSystem)
+	}
+	return nil
+}
+
+// RestoreFromCheckpoint creates a utility VM from the checkpoint directory
+// `path` previously written by `SaveCheckpoint`. The caller MUST call `Start`
+// to resume the utility VM from its saved state.
+//
+// If `id` is empty the ID of the saved utility VM is used.
+func RestoreFromCheckpoint(ctx context.Context, path, id string) (_ *UtilityVM, err error) {
+	op := "uvm::RestoreFromCheckpoint"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: id,
+		"path":          path,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	ctx, span := trace.StartSpan(ctx, op,
+		trace.StringAttribute(logfields.UVMID, id),
+		trace.StringAttribute("path", path))
+	defer func() { span.SetError(err); span.End() }()
+
+	m, err := ReadCheckpointManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	if id != "" {
+		m.ID = id
+	}
+	doc, err := m.restoreDocument(filepath.Join(path, CheckpointStateFile))
+	if err != nil {
+		return nil, err
+	}
+
+	uvm, err := newUtilityVMFromManifest(m)
+	if err != nil {
+		return nil, err
+	}
+	hcsSystem, err := hcs.Backend().CreateComputeSystem(ctx, uvm.id, doc)
+	if err != nil {
+		return nil, err
+	}
+	uvm.hcsSystem = hcsSystem
+	defer func() {
+		if err != nil {
+			uvm.Close()
+		}
+	}()
+
+	if m.ForwardOutput {
+		uvm.outputHandler = parseLogrus(uvm.id)
+		uvm.outputProcessingDone = make(chan struct{})
+		uvm.outputListener, err = uvm.listenVsock(linuxLogVsockPort)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return uvm, nil
+}
@@ -0,0 +1,445 @@
+package uvm
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/devicealloc"
+	"github.com/Microsoft/hcsshim/internal/guid"
+	"github.com/Microsoft/hcsshim/internal/hns"
+	"github.com/Microsoft/hcsshim/internal/regionalloc"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
+)
+
+type fakeCheckpointSystem struct {
+	options interface{}
+	err     error
+}
+
+func (f *fakeCheckpointSystem) Save(options interface{}) error {
+	f.options = options
+	if f.err != nil {
+		return f.err
+	}
+	// Simulate the platform writing the saved state.
+	so := options.(*hcsschema.SaveOptions)
+	return ioutil.WriteFile(so.SaveStateFilePath, []byte("state"), 0600)
+}
+
+func newCheckpointTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "uvmcheckpoint")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	return dir
+}
+
+// newCheckpointTestLCOW returns an LCOW utility VM with one of every resource
+// that is tracked in a checkpoint.
+func newCheckpointTestLCOW(t *testing.T) *UtilityVM {
+	doc := &hcsschema.ComputeSystem{
+		Owner: "test",
+		VirtualMachine: &hcsschema.VirtualMachine{
+			Devices: &hcsschema.Devices{
+				Scsi: map[string]hcsschema.Scsi{
+					"0": {Attachments: make(map[string]hcsschema.Attachment)},
+				},
+				VirtualPMem: &hcsschema.VirtualPMemController{
+					MaximumCount:     4,
+					MaximumSizeBytes: DefaultVPMemSizeBytes,
+				},
+				Plan9: &hcsschema.Plan9{},
+			},
+		},
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal document: %v", err)
+	}
+	uvm := &UtilityVM{
+		id:                  t.Name(),
+		owner:               "test",
+		operatingSystem:     "linux",
+		processorCount:      2,
+		scsiControllerCount: 1,
+		vpmemMaxCount:       4,
+		vpmemMaxSizeBytes:   DefaultVPMemSizeBytes,
+		containerCounter:    3,
+		plan9Counter:        1,
+		createDocument:      b,
+		outputHandler:       func(r io.Reader) {},
+	}
+	uvm.initDevices()
+	if err := uvm.vpmemDevices.Insert(devicealloc.Entry{
+		Key:      `c:\layers\1\layer.vhd`,
+		Location: devicealloc.Location{Slot: 1},
+		RefCount: 2,
+		Value:    &vpmemInfo{UVMPath: "/tmp/p1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := uvm.scsiLocations.Insert(devicealloc.Entry{
+		Key:      `c:\scratch\sandbox.vhdx`,
+		RefCount: 1,
+		Value:    &scsiInfo{UVMPath: "/tmp/scratch", AttachmentType: "VirtualDisk"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := uvm.plan9Shares.Insert(devicealloc.Entry{
+		Key:      plan9Key(`c:\data`, false, false, nil),
+		RefCount: 2,
+		Value: &plan9Info{
+			Settings: hcsschema.Plan9Share{Name: "0", AccessName: "0", Path: `c:\data`, Port: plan9Port, Flags: plan9ShareFlagsLinuxMetadata},
+			UVMPath:  "/tmp/plan9/0",
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	nicID := guid.New()
+	uvm.namespaces = map[string]*namespaceInfo{
+		"ns1": {
+			nics: map[string]*nicInfo{
+				"ep1": {
+					ID:       nicID,
+					Endpoint: &hns.HNSEndpoint{Id: "ep1", MacAddress: "00-11-22-33-44-55"},
+				},
+			},
+		},
+	}
+	return uvm
+}
+
+func Test_SaveCheckpoint_WritesStateAndManifest(t *testing.T) {
+	dir := newCheckpointTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+
+	uvm := newCheckpointTestLCOW(t)
+	cs := &fakeCheckpointSystem{}
+	if err := uvm.saveCheckpoint(cs, path); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+
+	so, ok := cs.options.(*hcsschema.SaveOptions)
+	if !ok {
+		t.Fatalf("expected *hcsschema.SaveOptions, got: %T", cs.options)
+	}
+	if so.SaveType != "ToFile" {
+		t.Fatalf("expected SaveType 'ToFile', got: '%s'", so.SaveType)
+	}
+	if so.SaveStateFilePath != filepath.Join(path, CheckpointStateFile) {
+		t.Fatalf("unexpected SaveStateFilePath: '%s'", so.SaveStateFilePath)
+	}
+
+	m, err := ReadCheckpointManifest(path)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	if m.ID != uvm.id || m.OperatingSystem != "linux" || m.ProcessorCount != 2 || !m.ForwardOutput {
+		t.Fatalf("unexpected manifest header: %+v", m)
+	}
+	if len(m.VPMem) != 1 || m.VPMem[0].DeviceNumber != 1 || m.VPMem[0].RefCount != 2 {
+		t.Fatalf("unexpected VPMem: %+v", m.VPMem)
+	}
+	if len(m.SCSI) != 1 || m.SCSI[0].HostPath != `c:\scratch\sandbox.vhdx` || m.SCSI[0].AttachmentType != "VirtualDisk" {
+		t.Fatalf("unexpected SCSI: %+v", m.SCSI)
+	}
+	if len(m.Plan9) != 1 || m.Plan9[0].Path != `c:\data` {
+		t.Fatalf("unexpected Plan9: %+v", m.Plan9)
+	}
+	if len(m.Namespaces) != 1 || len(m.Namespaces[0].NICs) != 1 || m.Namespaces[0].NICs[0].EndpointID != "ep1" {
+		t.Fatalf("unexpected Namespaces: %+v", m.Namespaces)
+	}
+}
+
+func Test_SaveCheckpoint_SaveFailure_NoManifest(t *testing.T) {
+	dir := newCheckpointTestDir(t)
+	defer os.RemoveAll(dir)
+
+	uvm := newCheckpointTestLCOW(t)
+	expected := errors.New("save failed")
+	err := uvm.saveCheckpoint(&fakeCheckpointSystem{err: expected}, dir)
+	if err != expected {
+		t.Fatalf("expected: %v, got: %v", expected, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, CheckpointManifestFile)); !os.IsNotExist(err) {
+		t.Fatalf("manifest should not exist, got: %v", err)
+	}
+}
+
+func Test_SaveCheckpoint_NoCreateDocument_Error(t *testing.T) {
+	dir := newCheckpointTestDir(t)
+	defer os.RemoveAll(dir)
+
+	uvm := &UtilityVM{id: t.Name(), operatingSystem: "linux"}
+	cs := &fakeCheckpointSystem{}
+	if err := uvm.saveCheckpoint(cs, dir); err == nil {
+		t.Fatal("should have failed without a create document")
+	}
+	if cs.options != nil {
+		t.Fatal("should not have saved the compute system")
+	}
+}
+
+func Test_ReadCheckpointManifest_Invalid_Error(t *testing.T) {
+	dir := newCheckpointTestDir(t)
+	defer os.RemoveAll(dir)
+
+	if _, err := ReadCheckpointManifest(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist error, got: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, CheckpointManifestFile), []byte(`{"OperatingSystem":"plan9"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadCheckpointManifest(dir); err == nil {
+		t.Fatal("should have failed with unsupported operating system")
+	}
+}
+
+func Test_CheckpointManifest_restoreDocument(t *testing.T) {
+	uvm := newCheckpointTestLCOW(t)
+	m, err := uvm.checkpointManifest()
+	if err != nil {
+		t.Fatalf("failed to create manifest: %v", err)
+	}
+
+	doc, err := m.restoreDocument(`c:\checkpoint\uvm.vmrs`)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	vm := doc.VirtualMachine
+	if vm.RestoreState == nil || vm.RestoreState.SaveStateFilePath != `c:\checkpoint\uvm.vmrs` {
+		t.Fatalf("unexpected RestoreState: %+v", vm.RestoreState)
+	}
+	expectedAttachment := hcsschema.Attachment{Path: `c:\scratch\sandbox.vhdx`, Type_: "VirtualDisk"}
+	if a := vm.Devices.Scsi["0"].Attachments["0"]; a != expectedAttachment {
+		t.Fatalf("unexpected SCSI attachment: %+v", a)
+	}
+	if d, ok := vm.Devices.VirtualPMem.Devices["1"]; !ok || d.HostPath != `c:\layers\1\layer.vhd` || !d.ReadOnly {
+		t.Fatalf("unexpected VPMem devices: %+v", vm.Devices.VirtualPMem.Devices)
+	}
+	if len(vm.Devices.Plan9.Shares) != 1 || vm.Devices.Plan9.Shares[0].Name != "0" {
+		t.Fatalf("unexpected Plan9 shares: %+v", vm.Devices.Plan9.Shares)
+	}
+	nic := m.Namespaces[0].NICs[0]
+	if na := vm.Devices.NetworkAdapters[nic.ID]; na.EndpointId != "ep1" || na.MacAddress != "00-11-22-33-44-55" {
+		t.Fatalf("unexpected network adapters: %+v", vm.Devices.NetworkAdapters)
+	}
+}
+
+func Test_CheckpointManifest_restoreDocument_KeepsCreateDevices(t *testing.T) {
+	doc := &hcsschema.ComputeSystem{
+		VirtualMachine: &hcsschema.VirtualMachine{
+			Devices: &hcsschema.Devices{
+				Scsi: map[string]hcsschema.Scsi{
+					"0": {
+						Attachments: map[string]hcsschema.Attachment{
+							"0": {Path: `c:\uvm\sandbox.vhdx`, Type_: "VirtualDisk"},
+						},
+					},
+				},
+				VirtualSmb: &hcsschema.VirtualSmb{
+					Shares: []hcsschema.VirtualSmbShare{{Name: "os", Path: `c:\uvm\Files`}},
+				},
+			},
+		},
+	}
+	b, _ := json.Marshal(doc)
+	m := &CheckpointManifest{
+		OperatingSystem:     "windows",
+		Document:            b,
+		SCSIControllerCount: 1,
+		SCSI: []CheckpointSCSI{
+			{Controller: 0, LUN: 0, HostPath: `c:\uvm\sandbox.vhdx`},
+			{Controller: 0, LUN: 1, HostPath: `c:\scratch.vhdx`, AttachmentType: "VirtualDisk"},
+		},
+		VSMB: []CheckpointVSMB{
+			{HostPath: `c:\layers\1`, Name: "s1", RefCount: 1},
+		},
+	}
+
+	rdoc, err := m.restoreDocument("uvm.vmrs")
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	attachments := rdoc.VirtualMachine.Devices.Scsi["0"].Attachments
+	if len(attachments) != 2 || attachments["0"].Type_ != "VirtualDisk" || attachments["1"].Path != `c:\scratch.vhdx` {
+		t.Fatalf("unexpected SCSI attachments: %+v", attachments)
+	}
+	shares := rdoc.VirtualMachine.Devices.VirtualSmb.Shares
+	if len(shares) != 2 || shares[0].Name != "os" || shares[1].Name != "s1" {
+		t.Fatalf("unexpected VSMB shares: %+v", shares)
+	}
+}
+
+func Test_Checkpoint_PackedVPMem_RoundTrip(t *testing.T) {
+	saved := newCheckpointTestLCOW(t)
+	saved.vpmemMultiMapping = true
+	if err := saved.vpmemDevices.Insert(devicealloc.Entry{
+		Key:      vpmemPackedKeyPrefix + "test",
+		Location: devicealloc.Location{Slot: 2},
+		RefCount: 1,
+		Value:    &vpmemInfo{Packed: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	saved.vpmemMappings.AddDevice(2)
+	if err := saved.vpmemMappings.Insert(regionalloc.Mapping{
+		Key:      `c:\layers\2\layer.vhd`,
+		Device:   2,
+		Region:   regionalloc.Region{Offset: 8192, Size: 4096},
+		RefCount: 3,
+		Value:    &vpmemInfo{UVMPath: "/tmp/p2-8192"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := saved.checkpointManifest()
+	if err != nil {
+		t.Fatalf("failed to create manifest: %v", err)
+	}
+	if err := m.validate(); err != nil {
+		t.Fatalf("manifest should be valid, got: %v", err)
+	}
+
+	doc, err := m.restoreDocument("uvm.vmrs")
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	d := doc.VirtualMachine.Devices.VirtualPMem.Devices["2"]
+	if d.HostPath != "" || d.SizeBytes != DefaultVPMemSizeBytes {
+		t.Fatalf("unexpected shared VPMem device: %+v", d)
+	}
+	expectedMapping := hcsschema.VirtualPMemMapping{HostPath: `c:\layers\2\layer.vhd`, ImageFormat: "Vhd1"}
+	if len(d.Mappings) != 1 || d.Mappings["8192"] != expectedMapping {
+		t.Fatalf("unexpected VPMem mappings: %+v", d.Mappings)
+	}
+
+	restored, err := newUtilityVMFromManifest(m)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if !restored.vpmemMultiMapping {
+		t.Fatal("expected multi-mapping to be restored")
+	}
+	if !reflect.DeepEqual(restored.vpmemDevices.Entries(), saved.vpmemDevices.Entries()) {
+		t.Fatalf("restored VPMem devices do not match: %+v", restored.vpmemDevices.Entries())
+	}
+	if !reflect.DeepEqual(restored.vpmemMappings.Mappings(), saved.vpmemMappings.Mappings()) {
+		t.Fatalf("restored VPMem mappings do not match: %+v", restored.vpmemMappings.Mappings())
+	}
+}
+
+func Test_CheckpointManifest_restoreDocument_MappingNotPacked_Error(t *testing.T) {
+	uvm := newCheckpointTestLCOW(t)
+	m, err := uvm.checkpointManifest()
+	if err != nil {
+		t.Fatalf("failed to create manifest: %v", err)
+	}
+	m.VPMemMultiMapping = true
+	m.VPMemMappings = []CheckpointVPMemMapping{{DeviceNumber: 1, Size: 4096, HostPath: "a", RefCount: 1}}
+	if _, err := m.restoreDocument("uvm.vmrs"); err == nil {
+		t.Fatal("should have failed with a mapping on a device that is not shared")
+	}
+}
+
+func Test_newUtilityVMFromManifest_MatchesSaved(t *testing.T) {
+	saved := newCheckpointTestLCOW(t)
+	m, err := saved.checkpointManifest()
+	if err != nil {
+		t.Fatalf("failed to create manifest: %v", err)
+	}
+	// Round trip through JSON as if read from disk.
+	b, _ := json.Marshal(m)
+	m = &CheckpointManifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.validate(); err != nil {
+		t.Fatalf("manifest should be valid, got: %v", err)
+	}
+
+	restored, err := newUtilityVMFromManifest(m)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if restored.id != saved.id || restored.processorCount != saved.processorCount || restored.containerCounter != saved.containerCounter {
+		t.Fatalf("unexpected restored header: %+v", restored)
+	}
+	if !reflect.DeepEqual(restored.vpmemDevices.Entries(), saved.vpmemDevices.Entries()) {
+		t.Fatalf("restored VPMem devices do not match: %+v", restored.vpmemDevices.Entries())
+	}
+	if !reflect.DeepEqual(restored.scsiLocations.Entries(), saved.scsiLocations.Entries()) {
+		t.Fatalf("restored SCSI locations do not match: %+v", restored.scsiLocations.Entries())
+	}
+	if !reflect.DeepEqual(restored.plan9Shares.Entries(), saved.plan9Shares.Entries()) {
+		t.Fatalf("restored Plan9 shares do not match: %+v", restored.plan9Shares.Entries())
+	}
+	nic := restored.namespaces["ns1"].nics["ep1"]
+	if nic == nil || nic.ID != saved.namespaces["ns1"].nics["ep1"].ID || nic.Endpoint.MacAddress != "00-11-22-33-44-55" {
+		t.Fatalf("unexpected restored nic: %+v", nic)
+	}
+}
+
+func Test_CheckpointManifest_validate_OutOfRange_Error(t *testing.T) {
+	m := &CheckpointManifest{
+		OperatingSystem:     "linux",
+		SCSIControllerCount: 1,
+		SCSI:                []CheckpointSCSI{{Controller: 1, LUN: 0, HostPath: "a"}},
+	}
+	if err := m.validate(); err == nil {
+		t.Fatal("should have failed with SCSI controller out of range")
+	}
+
+	m = &CheckpointManifest{
+		OperatingSystem: "linux",
+		VPMemMaxCount:   1,
+		VPMem:           []CheckpointVPMem{{DeviceNumber: 1, HostPath: "a"}},
+	}
+	if err := m.validate(); err == nil {
+		t.Fatal("should have failed with VPMem device out of range")
+	}
+
+	m = &CheckpointManifest{
+		OperatingSystem: "linux",
+		VSMB:            []CheckpointVSMB{{HostPath: "a", Name: "s1"}},
+	}
+	if err := m.validate(); err == nil {
+		t.Fatal("should have failed with VSMB on linux")
+	}
+}
+
+func Test_newUtilityVMFromManifest_DuplicateDevice_Error(t *testing.T) {
+	m := &CheckpointManifest{
+		OperatingSystem:     "linux",
+		SCSIControllerCount: 1,
+		SCSI: []CheckpointSCSI{
+			{Controller: 0, LUN: 0, HostPath: "a"},
+			{Controller: 0, LUN: 1, HostPath: "a"},
+		},
+	}
+	if _, err := newUtilityVMFromManifest(m); err == nil {
+		t.Fatal("should have failed with a duplicate SCSI attachment")
+	}
+}
+
+func Test_newUtilityVMFromManifest_NoRefCount_RestoresOne(t *testing.T) {
+	m := &CheckpointManifest{
+		OperatingSystem:     "linux",
+		SCSIControllerCount: 1,
+		SCSI:                []CheckpointSCSI{{Controller: 0, LUN: 1, HostPath: "a"}},
+	}
+	uvm, err := newUtilityVMFromManifest(m)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	e, ok := uvm.scsiLocations.Get("a")
+	if !ok || e.RefCount != 1 || e.Slot != 1 {
+		t.Fatalf("unexpected restored attachment: %+v", e)
+	}
+}
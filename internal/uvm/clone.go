@@ -0,0 +1,238 @@
+package uvm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/devicealloc"
+	"github.com/Microsoft/hcsshim/internal/guid"
+	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
+	"github.com/Microsoft/hcsshim/internal/trace"
+	"github.com/Microsoft/hcsshim/internal/wcow"
+	"github.com/sirupsen/logrus"
+)
+
+// OptionsCloneWCOW are the set of options passed to CloneWCOW() to create a
+// utility VM from a template. The memory, processors and devices of the clone
+// are those of the template.
+type OptionsCloneWCOW struct {
+	ID    string // Identifier for the uvm. Defaults to generated GUID.
+	Owner string // Specifies the owner. Defaults to executable name.
+
+	// TemplatePath is the directory the template was saved to with
+	// `SaveAsTemplate`.
+	TemplatePath string
+
+	// ScratchFolder is the folder that the scratch of the clone is created in
+	// as a differencing disk of the template scratch.
+	ScratchFolder string
+
+	// Backend creates the compute system of the UVM. If `nil` the compute
+	// system is created through HCS.
+	Backend cow.Backend
+}
+
+// templateSystem is the subset of compute system operations used to save a
+// utility VM as a template. It is implemented by `cow.ComputeSystem`.
+type templateSystem interface {
+	checkpointSystem
+	Pause() error
+	Resume() error
+}
+
+// SaveAsTemplate pauses the Windows utility VM and saves it to the directory
+// `path` as a template that new utility VMs can be cloned from with
+// `CloneWCOW`. The template is a checkpoint of the utility VM.
+//
+// The utility VM MUST have been started and MUST NOT have any resources
+// attached other than its scratch, so that clones do not share its network
+// identity. The utility VM cannot be resumed once saved and the caller MUST
+// close it. Its scratch MUST NOT be modified or removed while the template is
+// in use.
+func (uvm *UtilityVM) SaveAsTemplate(path string) (err error) {
+	op := "uvm::SaveAsTemplate"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+		"path":          path,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	return uvm.saveAsTemplate(uvm.hcsSystem, path)
+}
+
+func (uvm *UtilityVM) saveAsTemplate(ts templateSystem, path string) error {
+	if err := uvm.validateTemplate(); err != nil {
+		return err
+	}
+	if err := ts.Pause(); err != nil {
+		return err
+	}
+	if err := uvm.saveCheckpoint(ts, path); err != nil {
+		if rerr := ts.Resume(); rerr != nil {
+			logrus.WithFields(logrus.Fields{
+				logfields.UVMID: uvm.id,
+				logrus.ErrorKey: rerr,
+			}).Warning("failed to resume utility VM after failed save")
+		}
+		return err
+	}
+	return nil
+}
+
+// validateTemplate returns an error if the utility VM cannot be saved as a
+// template.
+func (uvm *UtilityVM) validateTemplate() error {
+	if uvm.operatingSystem != "windows" {
+		return errNotSupported
+	}
+
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+
+	if len(uvm.namespaces) > 0 {
+		return fmt.Errorf("utility VM %s cannot be saved as a template with network namespaces attached", uvm.id)
+	}
+	if uvm.vsmbShares.Len() > 0 {
+		return fmt.Errorf("utility VM %s cannot be saved as a template with VSMB shares attached", uvm.id)
+	}
+	if _, ok := uvm.scsiLocations.At(devicealloc.Location{}); !ok || uvm.scsiLocations.Len() != 1 {
+		return fmt.Errorf("utility VM %s cannot be saved as a template with SCSI attachments other than its scratch", uvm.id)
+	}
+	return nil
+}
+
+// newCloneManifest returns the manifest of the clone `id` of the template `m`
+// with its scratch at `scratchPath`.
+//
+// The clone has the devices of the template except for its scratch, which
+// replaces the template scratch in the create document, and the network
+// adapters, which are added to the clone with its own endpoints.
+func newCloneManifest(m *CheckpointManifest, id, owner, scratchPath string) (*CheckpointManifest, error) {
+	if m.OperatingSystem != "windows" {
+		return nil, fmt.Errorf("template has unsupported operating system '%s'", m.OperatingSystem)
+	}
+	if len(m.SCSI) != 1 || m.SCSI[0].Controller != 0 || m.SCSI[0].LUN != 0 || len(m.VSMB) > 0 || len(m.Namespaces) > 0 {
+		return nil, fmt.Errorf("template has resources attached other than its scratch")
+	}
+	doc := &hcsschema.ComputeSystem{}
+	if err := json.Unmarshal(m.Document, doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal template create document: %s", err)
+	}
+	if doc.VirtualMachine == nil || doc.VirtualMachine.Devices == nil {
+		return nil, fmt.Errorf("template create document is not a virtual machine")
+	}
+	c, ok := doc.VirtualMachine.Devices.Scsi["0"]
+	if !ok || c.Attachments == nil {
+		return nil, fmt.Errorf("template create document has no scratch")
+	}
+	a := c.Attachments["0"]
+	a.Path = scratchPath
+	c.Attachments["0"] = a
+	doc.Owner = owner
+	doc.VirtualMachine.Devices.NetworkAdapters = nil
+
+	clone := *m
+	clone.ID = id
+	clone.Owner = owner
+	clone.SCSI = []CheckpointSCSI{m.SCSI[0]}
+	clone.SCSI[0].HostPath = scratchPath
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	clone.Document = json.RawMessage(b)
+	return &clone, nil
+}
+
+// CloneWCOW creates a Windows utility VM from the template at
+// `opts.TemplatePath` saved by `SaveAsTemplate`. The caller MUST call `Start`
+// to resume the clone from the template saved state.
+//
+// The clone has its own ID and a scratch that is a differencing disk of the
+// template scratch. It has no network adapters of the template.
+func CloneWCOW(ctx context.Context, opts *OptionsCloneWCOW) (_ *UtilityVM, err error) {
+	if opts.ID == "" {
+		opts.ID = guid.New().String()
+	}
+	if opts.Owner == "" {
+		opts.Owner = filepath.Base(os.Args[0])
+	}
+
+	op := "uvm::CloneWCOW"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: opts.ID,
+		"template":      opts.TemplatePath,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	ctx, span := trace.StartSpan(ctx, op,
+		trace.StringAttribute(logfields.UVMID, opts.ID),
+		trace.StringAttribute("template", opts.TemplatePath))
+	defer func() { span.SetError(err); span.End() }()
+
+	m, err := ReadCheckpointManifest(opts.TemplatePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	scratchPath := filepath.Join(opts.ScratchFolder, "sandbox.vhdx")
+	clone, err := newCloneManifest(m, opts.ID, opts.Owner, scratchPath)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := clone.restoreDocument(filepath.Join(opts.TemplatePath, CheckpointStateFile))
+	if err != nil {
+		return nil, err
+	}
+	uvm, err := newUtilityVMFromManifest(clone)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(opts.ScratchFolder, 0777); err != nil {
+		return nil, fmt.Errorf("failed to create utility VM scratch folder: %s", err)
+	}
+	if err := wcow.CreateUVMDifferencingScratch(m.SCSI[0].HostPath, opts.ScratchFolder, uvm.id); err != nil {
+		return nil, fmt.Errorf("failed to create scratch: %s", err)
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(scratchPath)
+		}
+	}()
+
+	backend := opts.Backend
+	if backend == nil {
+		backend = hcs.Backend()
+	}
+	hcsSystem, err := backend.CreateComputeSystem(ctx, uvm.id, doc)
+	if err != nil {
+		return nil, err
+	}
+	uvm.hcsSystem = hcsSystem
+	return uvm, nil
+}
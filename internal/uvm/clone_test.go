@@ -0,0 +1,239 @@
+package uvm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/devicealloc"
+	"github.com/Microsoft/hcsshim/internal/hns"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// compareGolden compares the JSON of `v` with the golden file `name` in
+// testdata. If `-update` is set the golden file is rewritten instead.
+func compareGolden(t *testing.T, name string, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	b = append(b, '\n')
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := ioutil.WriteFile(path, b, 0644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(bytes.Replace(expected, []byte("\r\n"), []byte("\n"), -1), b) {
+		t.Fatalf("document does not match %s, rerun with -update if the change is expected. got:\n%s", path, b)
+	}
+}
+
+type fakeTemplateSystem struct {
+	fakeCheckpointSystem
+	paused  bool
+	resumed bool
+}
+
+func (f *fakeTemplateSystem) Pause() error {
+	f.paused = true
+	return nil
+}
+
+func (f *fakeTemplateSystem) Resume() error {
+	f.resumed = true
+	return nil
+}
+
+const (
+	templateTestUVMFolder = `C:\layers\base`
+	templateTestScratch   = `C:\templates\0\sandbox.vhdx`
+	cloneTestScratch      = `C:\pods\clone\vm\sandbox.vhdx`
+)
+
+func newTemplateTestDocument() *hcsschema.ComputeSystem {
+	opts := NewDefaultOptionsWCOW("template", "test")
+	opts.StorageQoSIopsMaximum = 1000
+	return prepareWCOWDocument(opts, 2, 1, templateTestUVMFolder, templateTestScratch)
+}
+
+// newTemplateTestWCOW returns a started WCOW utility VM that can be saved as a
+// template.
+func newTemplateTestWCOW(t *testing.T) *UtilityVM {
+	b, err := json.Marshal(newTemplateTestDocument())
+	if err != nil {
+		t.Fatalf("failed to marshal document: %v", err)
+	}
+	uvm := &UtilityVM{
+		id:                  "template",
+		owner:               "test",
+		operatingSystem:     "windows",
+		processorCount:      2,
+		memorySizeInMB:      1024,
+		scsiControllerCount: 1,
+		createDocument:      b,
+	}
+	uvm.initDevices()
+	if err := uvm.scsiLocations.Insert(devicealloc.Entry{
+		Key:      templateTestScratch,
+		RefCount: 1,
+		Value:    &scsiInfo{AttachmentType: "VirtualDisk"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return uvm
+}
+
+// saveTestTemplate saves `uvm` as a template and returns its manifest.
+func saveTestTemplate(t *testing.T, uvm *UtilityVM) *CheckpointManifest {
+	dir := newCheckpointTestDir(t)
+	defer os.RemoveAll(dir)
+	if err := uvm.saveAsTemplate(&fakeTemplateSystem{}, dir); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	m, err := ReadCheckpointManifest(dir)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	return m
+}
+
+func Test_prepareWCOWDocument_Template_Golden(t *testing.T) {
+	compareGolden(t, "template_wcow.json", newTemplateTestDocument())
+}
+
+func Test_CloneDocument_Golden(t *testing.T) {
+	m := saveTestTemplate(t, newTemplateTestWCOW(t))
+	clone, err := newCloneManifest(m, "clone", "test", cloneTestScratch)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	doc, err := clone.restoreDocument(`C:\templates\0\uvm.vmrs`)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	compareGolden(t, "clone_wcow.json", doc)
+}
+
+func Test_SaveAsTemplate_PausesAndSaves(t *testing.T) {
+	dir := newCheckpointTestDir(t)
+	defer os.RemoveAll(dir)
+
+	ts := &fakeTemplateSystem{}
+	if err := newTemplateTestWCOW(t).saveAsTemplate(ts, dir); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if !ts.paused || ts.resumed {
+		t.Fatalf("expected paused and not resumed, got: paused=%v resumed=%v", ts.paused, ts.resumed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, CheckpointStateFile)); err != nil {
+		t.Fatalf("expected saved state, got: %v", err)
+	}
+}
+
+func Test_SaveAsTemplate_SaveFailure_Resumes(t *testing.T) {
+	dir := newCheckpointTestDir(t)
+	defer os.RemoveAll(dir)
+
+	expected := errors.New("save failed")
+	ts := &fakeTemplateSystem{fakeCheckpointSystem: fakeCheckpointSystem{err: expected}}
+	if err := newTemplateTestWCOW(t).saveAsTemplate(ts, dir); err != expected {
+		t.Fatalf("expected %v, got: %v", expected, err)
+	}
+	if !ts.resumed {
+		t.Fatal("expected the utility VM to be resumed")
+	}
+}
+
+func Test_SaveAsTemplate_Invalid_Error(t *testing.T) {
+	tests := map[string]func(uvm *UtilityVM){
+		"linux": func(uvm *UtilityVM) {
+			uvm.operatingSystem = "linux"
+		},
+		"namespace": func(uvm *UtilityVM) {
+			uvm.namespaces = map[string]*namespaceInfo{
+				"ns1": {nics: map[string]*nicInfo{"ep1": {Endpoint: &hns.HNSEndpoint{Id: "ep1"}}}},
+			}
+		},
+		"vsmb": func(uvm *UtilityVM) {
+			uvm.vsmbShares.Insert(devicealloc.Entry{
+				Key:      `C:\data`,
+				RefCount: 1,
+				Value:    &vsmbShare{Name: "s1"},
+			})
+		},
+		"scsi": func(uvm *UtilityVM) {
+			uvm.scsiLocations.Insert(devicealloc.Entry{
+				Key:      `C:\data.vhdx`,
+				Location: devicealloc.Location{Slot: 1},
+				RefCount: 1,
+				Value:    &scsiInfo{AttachmentType: "VirtualDisk"},
+			})
+		},
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := newCheckpointTestDir(t)
+			defer os.RemoveAll(dir)
+
+			uvm := newTemplateTestWCOW(t)
+			modify(uvm)
+			ts := &fakeTemplateSystem{}
+			if err := uvm.saveAsTemplate(ts, dir); err == nil {
+				t.Fatal("expected an error")
+			}
+			if ts.paused {
+				t.Fatal("expected the utility VM not to be paused")
+			}
+		})
+	}
+}
+
+func Test_newCloneManifest_FreshIdentity(t *testing.T) {
+	m := saveTestTemplate(t, newTemplateTestWCOW(t))
+	clone, err := newCloneManifest(m, "clone", "owner", cloneTestScratch)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if clone.ID != "clone" || clone.Owner != "owner" {
+		t.Fatalf("unexpected clone identity: %s %s", clone.ID, clone.Owner)
+	}
+	if len(clone.SCSI) != 1 || clone.SCSI[0].HostPath != cloneTestScratch {
+		t.Fatalf("unexpected clone SCSI: %+v", clone.SCSI)
+	}
+	// The template manifest is not modified.
+	if m.ID != "template" || m.SCSI[0].HostPath != templateTestScratch {
+		t.Fatalf("template manifest was modified: %+v", m)
+	}
+	if bytes.Contains(clone.Document, []byte(`templates`)) {
+		t.Fatalf("clone document references the template scratch: %s", clone.Document)
+	}
+
+	uvm, err := newUtilityVMFromManifest(clone)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if _, ok := uvm.scsiLocations.Get(cloneTestScratch); !ok {
+		t.Fatal("expected the clone scratch to be tracked")
+	}
+}
+
+func Test_newCloneManifest_Linux_Error(t *testing.T) {
+	m := saveTestTemplate(t, newTemplateTestWCOW(t))
+	m.OperatingSystem = "linux"
+	if _, err := newCloneManifest(m, "clone", "test", cloneTestScratch); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,104 @@
+package uvm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	winio "github.com/Microsoft/go-winio"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// consoleDialTimeout is the time to wait for the serial console pipe of a
+// utility VM to be available once its start returned.
+const consoleDialTimeout = 5 * time.Second
+
+// consoleDialRetryInterval is the interval in which connecting to the serial
+// console pipe is retried while the pipe does not exist.
+const consoleDialRetryInterval = 10 * time.Millisecond
+
+// guestConsolePipe returns the named pipe the serial console of the utility
+// VM `id` is connected to when it is captured by the UVM.
+func guestConsolePipe(id string) string {
+	return `\\.\pipe\uvm-console-` + id
+}
+
+// openConsoleLog opens the rotating file at `path` that the serial console of
+// the utility VM is captured to.
+func (uvm *UtilityVM) openConsoleLog(path string, maxSize int64, maxFiles int) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return fmt.Errorf("failed to create console log directory: %s", err)
+	}
+	rf, err := logging.OpenRotatingFile(path, maxSize, maxFiles)
+	if err != nil {
+		return fmt.Errorf("failed to open console log: %s", err)
+	}
+	uvm.consoleLog = logging.NewConsoleWriter(rf)
+	return nil
+}
+
+// dialConsole connects to the serial console pipe `pipe`. The VM worker
+// process creates the pipe while the utility VM starts so connecting is
+// retried until the pipe exists or `ctx` is done.
+func dialConsole(ctx context.Context, pipe string) (net.Conn, error) {
+	for {
+		c, err := winio.DialPipeContext(ctx, pipe)
+		if err == nil || !os.IsNotExist(err) {
+			return c, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(consoleDialRetryInterval):
+		}
+	}
+}
+
+// startConsoleCapture connects to the serial console pipe of the utility VM
+// and copies the console output to `uvm.consoleTail` and `uvm.consoleLog` in
+// the background until the utility VM exits.
+//
+// This MUST be called before the UVM is started so that the output of its boot
+// is captured. The returned function MUST be called once the start returned,
+// successful or not. Connecting gives up `consoleDialTimeout` later.
+func (uvm *UtilityVM) startConsoleCapture() (started func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	uvm.consoleDone = make(chan struct{})
+	go func() {
+		defer close(uvm.consoleDone)
+		log := logrus.WithField(logfields.UVMID, uvm.id)
+		if uvm.consoleLog != nil {
+			defer uvm.consoleLog.Close()
+		}
+		c, err := dialConsole(ctx, uvm.consolePipe)
+		if err != nil {
+			log.WithError(err).Warning("failed to connect to utility VM console")
+			return
+		}
+		defer c.Close()
+		var w io.Writer = uvm.consoleTail
+		if uvm.consoleLog != nil {
+			w = io.MultiWriter(uvm.consoleTail, uvm.consoleLog)
+		}
+		if _, err := io.Copy(w, c); err != nil {
+			log.WithError(err).Warning("utility VM console capture failed")
+		}
+	}()
+	return func() {
+		time.AfterFunc(consoleDialTimeout, cancel)
+	}
+}
+
+// closeConsoleLog closes the console log of a utility VM that was never
+// started. Once `Start` was called the console capture closes the log.
+func (uvm *UtilityVM) closeConsoleLog() {
+	if uvm.consoleLog != nil && uvm.consoleDone == nil {
+		uvm.consoleLog.Close()
+	}
+}
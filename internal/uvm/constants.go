@@ -14,6 +14,14 @@ const (
 	// DefaultVPMemSizeBytes is the default size of a VPMem device if the create request
 	// doesn't specify.
 	DefaultVPMemSizeBytes = 4 * 1024 * 1024 * 1024 // 4GB
+
+	// DefaultConsoleLogMaxSize is the default size a captured console log of an
+	// LCOW utility VM is rotated at.
+	DefaultConsoleLogMaxSize = 1024 * 1024 // 1MB
+
+	// DefaultConsoleLogMaxFiles is the default number of rotated console logs
+	// of an LCOW utility VM retained.
+	DefaultConsoleLogMaxFiles = 1
 )
 
 var errNotSupported = fmt.Errorf("not supported")
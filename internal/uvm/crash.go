@@ -0,0 +1,124 @@
+package uvm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/crashdump"
+	"github.com/Microsoft/hcsshim/internal/wclayer"
+)
+
+// consoleDrainTimeout is the time `CollectCrash` waits for the console output
+// of an exited utility VM to be captured.
+const consoleDrainTimeout = 5 * time.Second
+
+// windowsCrashDumpFile returns the path of the dump of the Windows utility VM
+// `id` in the crash dump directory `dir`.
+func windowsCrashDumpFile(dir, id string) string {
+	return filepath.Join(dir, id+".dmp")
+}
+
+// createCrashDumpPath creates the crash dump directory of the utility VM if
+// crash collection is enabled.
+func (uvm *UtilityVM) createCrashDumpPath() error {
+	if uvm.crashDumpPath == "" {
+		return nil
+	}
+	if err := os.MkdirAll(uvm.crashDumpPath, 0777); err != nil {
+		return fmt.Errorf("failed to create crash dump directory: %s", err)
+	}
+	if uvm.operatingSystem == "windows" {
+		// The dump is written by the VM worker process.
+		if err := wclayer.GrantVmAccess(uvm.id, uvm.crashDumpPath); err != nil {
+			return fmt.Errorf("failed to grant access to crash dump directory: %s", err)
+		}
+	}
+	return nil
+}
+
+// removeCrashDumpPath removes the crash dump directory of the utility VM if
+// no crash was collected in it.
+func (uvm *UtilityVM) removeCrashDumpPath() {
+	if uvm.crashDumpPath != "" {
+		// Fails if the directory is not empty.
+		os.Remove(uvm.crashDumpPath)
+	}
+}
+
+// crashed returns `true` if `reason` is an abnormal exit of the guest rather
+// than one requested by the host.
+func crashed(reason cow.ExitReason) bool {
+	switch reason {
+	case cow.ExitReasonNone, cow.ExitReasonTerminated, cow.ExitReasonHostShutdown:
+		return false
+	}
+	return true
+}
+
+// CollectCrash collects the evidence of a guest crash of the utility VM into
+// its crash dump directory. It MUST only be called once `Wait` has returned.
+//
+// For a Windows utility VM the dump is the memory dump written by the guest.
+// The Linux kernel does not write a dump so the tail of its console output is
+// saved instead.
+//
+// The crash is collected only once. `Close` collects it before it removes the
+// crash dump directory, so a call after `Close` returns the same report.
+//
+// Returns `nil` if crash collection is disabled or the utility VM did not exit
+// abnormally.
+func (uvm *UtilityVM) CollectCrash() (*crashdump.Report, error) {
+	uvm.crashOnce.Do(func() {
+		if uvm.crashDumpPath == "" {
+			return
+		}
+		// `Close` calls this after `Terminate`, which may return before the
+		// exit and with it the exit reason is known.
+		uvm.hcsSystem.WaitTimeout(consoleDrainTimeout)
+		reason := uvm.ExitReason()
+		if !crashed(reason) {
+			return
+		}
+		uvm.crashReport, uvm.crashErr = uvm.collectCrash(reason, time.Now())
+	})
+	return uvm.crashReport, uvm.crashErr
+}
+
+func (uvm *UtilityVM) collectCrash(reason cow.ExitReason, now time.Time) (*crashdump.Report, error) {
+	r := &crashdump.Report{
+		UVMID:     uvm.id,
+		Reason:    string(reason),
+		CrashedAt: now,
+	}
+	if uvm.consoleTail != nil {
+		if uvm.consoleDone != nil {
+			select {
+			case <-uvm.consoleDone:
+			case <-time.After(consoleDrainTimeout):
+			}
+		}
+		r.ConsoleTail = uvm.consoleTail.String()
+	}
+
+	switch uvm.operatingSystem {
+	case "windows":
+		path := windowsCrashDumpFile(uvm.crashDumpPath, uvm.id)
+		if _, err := os.Stat(path); err == nil {
+			r.DumpPath = path
+		}
+	case "linux":
+		if r.ConsoleTail == "" {
+			break
+		}
+		path := filepath.Join(uvm.crashDumpPath, fmt.Sprintf("%s-%s-console.log", uvm.id, now.UTC().Format("20060102T150405Z")))
+		if err := ioutil.WriteFile(path, []byte(r.ConsoleTail), 0644); err != nil {
+			return r, fmt.Errorf("failed to save guest console: %s", err)
+		}
+		r.DumpPath = path
+	}
+	return r, nil
+}
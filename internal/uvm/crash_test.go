@@ -0,0 +1,125 @@
+package uvm
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/cow/fake"
+	"github.com/Microsoft/hcsshim/internal/crashdump"
+)
+
+func Test_crashed(t *testing.T) {
+	tests := map[cow.ExitReason]bool{
+		cow.ExitReasonNone:         false,
+		cow.ExitReasonTerminated:   false,
+		cow.ExitReasonHostShutdown: false,
+		cow.ExitReasonGuestCrash:   true,
+		cow.ExitReasonUnexpected:   true,
+		cow.ExitReasonOutOfMemory:  true,
+	}
+	for reason, expected := range tests {
+		if actual := crashed(reason); actual != expected {
+			t.Errorf("crashed(%q): expected %v, got %v", reason, expected, actual)
+		}
+	}
+}
+
+func newCrashTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "crash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func Test_collectCrash_Linux_SavesConsole(t *testing.T) {
+	dir := newCrashTestDir(t)
+	defer os.RemoveAll(dir)
+
+	uvm := &UtilityVM{
+		id:              "uvm",
+		operatingSystem: "linux",
+		crashDumpPath:   dir,
+		consoleTail:     crashdump.NewTailBuffer(crashdump.DefaultConsoleTailSize),
+		consoleDone:     make(chan struct{}),
+	}
+	uvm.consoleTail.Write([]byte("Kernel panic - not syncing\n"))
+	close(uvm.consoleDone)
+
+	r, err := uvm.collectCrash(cow.ExitReasonGuestCrash, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if r.Reason != string(cow.ExitReasonGuestCrash) || r.ConsoleTail != "Kernel panic - not syncing\n" {
+		t.Fatalf("unexpected report: %+v", r)
+	}
+	b, err := ioutil.ReadFile(r.DumpPath)
+	if err != nil {
+		t.Fatalf("failed to read console log: %v", err)
+	}
+	if string(b) != r.ConsoleTail {
+		t.Fatalf("expected console log %q, got %q", r.ConsoleTail, b)
+	}
+}
+
+func Test_collectCrash_Windows_NoDump(t *testing.T) {
+	dir := newCrashTestDir(t)
+	defer os.RemoveAll(dir)
+
+	uvm := &UtilityVM{id: "uvm", operatingSystem: "windows", crashDumpPath: dir}
+	r, err := uvm.collectCrash(cow.ExitReasonGuestCrash, time.Now())
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if r.DumpPath != "" {
+		t.Fatalf("expected no dump path, got: %s", r.DumpPath)
+	}
+
+	if err := ioutil.WriteFile(windowsCrashDumpFile(dir, "uvm"), []byte("dump"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err = uvm.collectCrash(cow.ExitReasonGuestCrash, time.Now())
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if r.DumpPath != windowsCrashDumpFile(dir, "uvm") {
+		t.Fatalf("expected dump path, got: %s", r.DumpPath)
+	}
+}
+
+func Test_Close_CollectsCrash_BeforeRemovingDir(t *testing.T) {
+	dir := newCrashTestDir(t)
+	defer os.RemoveAll(dir)
+
+	cs, err := (&fake.Backend{}).CreateComputeSystem(context.TODO(), t.Name(), nil)
+	if err != nil {
+		t.Fatalf("failed to create compute system: %v", err)
+	}
+	uvm := &UtilityVM{
+		id:              "uvm",
+		operatingSystem: "linux",
+		hcsSystem:       cs,
+		crashDumpPath:   dir,
+		consoleTail:     crashdump.NewTailBuffer(crashdump.DefaultConsoleTailSize),
+	}
+	uvm.consoleTail.Write([]byte("Kernel panic - not syncing\n"))
+	cs.(*fake.ComputeSystem).ExitWithReason(cow.ExitReasonGuestCrash, nil)
+
+	if err := uvm.Close(); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	r, err := uvm.CollectCrash()
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if r == nil || r.DumpPath == "" {
+		t.Fatalf("expected crash report with dump path, got: %+v", r)
+	}
+	if _, err := os.Stat(r.DumpPath); err != nil {
+		t.Fatalf("expected console log to be kept, got: %v", err)
+	}
+}
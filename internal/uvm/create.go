@@ -1,13 +1,17 @@
 package uvm
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
 
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/guid"
 	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/logfields"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
 	"github.com/sirupsen/logrus"
 )
 
@@ -29,6 +33,19 @@ type Options struct {
 	// commit, set to true.
 	EnableDeferredCommit bool
 
+	// EnableLargePages backs the UVM memory with large pages. Defaults to
+	// false. Requires physically backed memory, `AllowOvercommit` false.
+	EnableLargePages bool
+
+	// NumaNodeCount sets the number of virtual NUMA nodes the UVM memory and
+	// vCPU's are split across. If `0` will default to platform default. MUST
+	// NOT exceed the number of vCPU's.
+	NumaNodeCount uint32
+
+	// NumaPreferredPhysicalNodes lists the host NUMA nodes that the virtual
+	// NUMA nodes are preferably placed on. Requires `NumaNodeCount`.
+	NumaPreferredPhysicalNodes []uint32
+
 	// ProcessorCount sets the number of vCPU's. If `0` will default to platform
 	// default.
 	ProcessorCount int32
@@ -41,6 +58,19 @@ type Options struct {
 	// when scheduling. If `0` will default to platform default.
 	ProcessorWeight int32
 
+	// CPUGroupID restricts the vCPU's to the processors of the host CPU group
+	// with this ID. The CPU group MUST already exist on the host. If empty
+	// the vCPU's can run on any processor.
+	CPUGroupID string
+
+	// ExposeVirtualizationExtensions exposes the virtualization extensions
+	// of the host processors to the UVM to allow nested virtualization.
+	ExposeVirtualizationExtensions bool
+
+	// MemoryMaximumInMB is the size that the UVM memory can be grown to with
+	// `UpdateMemory` once it is running. If `0` the memory cannot be grown.
+	MemoryMaximumInMB int32
+
 	// StorageQoSIopsMaximum sets the maximum number of Iops. If `0` will
 	// default to the platform default.
 	StorageQoSIopsMaximum int32
@@ -48,6 +78,30 @@ type Options struct {
 	// StorageQoSIopsMaximum sets the maximum number of bytes per second. If `0`
 	// will default to the platform default.
 	StorageQoSBandwidthMaximum int32
+
+	// HvSocketServices registers additional HvSocket services of the UVM by
+	// service ID, for example to connect to a daemon in the guest. The bind
+	// and connect security descriptors are SDDL strings. A Linux guest
+	// connects to the service `xxxxxxxx-facb-11e6-bd58-64006a7986d3` of its
+	// vsock port `xxxxxxxx`.
+	HvSocketServices map[string]hcsschema.HvSocketServiceConfig
+
+	// CrashDumpPath is the host directory that the evidence of a guest crash
+	// is collected in, see `UtilityVM.CollectCrash`. If empty guest crashes
+	// are not collected.
+	CrashDumpPath string
+
+	// Backend creates the compute system of the UVM. If `nil` the compute
+	// system is created through HCS.
+	Backend cow.Backend
+}
+
+// backend returns `opts.Backend` or the HCS backend if not set.
+func (opts *Options) backend() cow.Backend {
+	if opts.Backend == nil {
+		return hcs.Backend()
+	}
+	return opts.Backend
 }
 
 // newDefaultOptions returns the default base options for WCOW and LCOW.
@@ -104,6 +158,11 @@ func (uvm *UtilityVM) Close() (err error) {
 	if err := uvm.hcsSystem.Terminate(); hcs.IsPending(err) {
 		uvm.Wait()
 	}
+	// Collect a guest crash while its evidence is still available. The crash
+	// dump directory is removed below if it is empty.
+	if _, err := uvm.CollectCrash(); err != nil {
+		log.WithError(err).Warning(op + " - failed to collect guest crash")
+	}
 
 	// outputListener will only be nil for a Create -> Stop without a Start. In
 	// this case we have no goroutine processing output so its safe to close the
@@ -113,7 +172,10 @@ func (uvm *UtilityVM) Close() (err error) {
 		uvm.outputListener.Close()
 		uvm.outputListener = nil
 	}
-	return uvm.hcsSystem.Close()
+	err = uvm.hcsSystem.Close()
+	uvm.closeConsoleLog()
+	uvm.removeCrashDumpPath()
+	return err
 }
 
 func defaultProcessorCount() int32 {
@@ -139,7 +201,83 @@ func (uvm *UtilityVM) normalizeProcessorCount(requested int32) {
 	}
 }
 
+// maxNumaNodeCount is the maximum number of virtual NUMA nodes of a VM.
+const maxNumaNodeCount = 64
+
+// normalizeTopology clears the memory and processor options of `opts` that
+// have no effect on a UVM created with the other options.
+//
+// Deferred commit only applies to virtual memory backing. It is cleared for
+// physically backed memory with a warning rather than failing the create, so
+// that existing pods that set both keep starting.
+func normalizeTopology(opts *Options) {
+	if opts.EnableDeferredCommit && !opts.AllowOvercommit {
+		logrus.WithField(logfields.UVMID, opts.ID).Warning("deferred commit requires virtual memory backing and is ignored")
+		opts.EnableDeferredCommit = false
+	}
+}
+
+// validateTopology returns an error if the memory and processor options of
+// `opts` cannot be combined for a UVM with `processorCount` vCPU's.
+func validateTopology(opts *Options, processorCount int32) error {
+	if opts.EnableLargePages && opts.AllowOvercommit {
+		return errors.New("large pages require physically backed memory")
+	}
+	if opts.NumaNodeCount > maxNumaNodeCount {
+		return fmt.Errorf("NUMA node count cannot be greater than %d", maxNumaNodeCount)
+	}
+	if opts.NumaNodeCount > uint32(processorCount) {
+		return fmt.Errorf("NUMA node count %d cannot be greater than the processor count %d", opts.NumaNodeCount, processorCount)
+	}
+	if len(opts.NumaPreferredPhysicalNodes) > 0 && opts.NumaNodeCount == 0 {
+		return errors.New("NUMA preferred physical nodes require a NUMA node count")
+	}
+	return nil
+}
+
+// computeTopology returns the memory and processor topology of the HCS
+// document of a UVM created with `opts` with `processorCount` vCPU's.
+func computeTopology(opts *Options, processorCount int32) *hcsschema.Topology {
+	topology := &hcsschema.Topology{
+		Memory: &hcsschema.Memory2{
+			SizeInMB:             opts.MemorySizeInMB,
+			AllowOvercommit:      opts.AllowOvercommit,
+			EnableDeferredCommit: opts.EnableDeferredCommit,
+		},
+		Processor: &hcsschema.Processor2{
+			Count:                          processorCount,
+			Limit:                          opts.ProcessorLimit,
+			Weight:                         opts.ProcessorWeight,
+			ExposeVirtualizationExtensions: opts.ExposeVirtualizationExtensions,
+		},
+	}
+	if opts.EnableLargePages {
+		topology.Memory.BackingPageSize = "Large"
+	}
+	if opts.CPUGroupID != "" {
+		topology.Processor.CpuGroup = &hcsschema.CpuGroup{Id: opts.CPUGroupID}
+	}
+	if opts.NumaNodeCount > 0 {
+		topology.Numa = &hcsschema.Numa{
+			VirtualNodeCount: uint8(opts.NumaNodeCount),
+		}
+		for _, node := range opts.NumaPreferredPhysicalNodes {
+			topology.Numa.PreferredPhysicalNodes = append(topology.Numa.PreferredPhysicalNodes, int64(node))
+		}
+	}
+	return topology
+}
+
 // ProcessorCount returns the number of processors actually assigned to the UVM.
 func (uvm *UtilityVM) ProcessorCount() int32 {
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
 	return uvm.processorCount
 }
+
+// MemorySizeInMB returns the amount of memory assigned to the UVM.
+func (uvm *UtilityVM) MemorySizeInMB() int32 {
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+	return uvm.memorySizeInMB
+}
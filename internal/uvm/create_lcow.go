@@ -1,21 +1,22 @@
 package uvm
 
 import (
-	"encoding/binary"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"net"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/crashdump"
+	"github.com/Microsoft/hcsshim/internal/devicealloc"
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/mergemaps"
 	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
 	"github.com/Microsoft/hcsshim/internal/schemaversion"
+	"github.com/Microsoft/hcsshim/internal/trace"
 	"github.com/Microsoft/hcsshim/osversion"
-	"github.com/linuxkit/virtsock/pkg/hvsock"
 	"github.com/sirupsen/logrus"
 )
 
@@ -52,7 +53,10 @@ type OptionsLCOW struct {
 	KernelBootOptions     string              // Additional boot options for the kernel
 	EnableGraphicsConsole bool                // If true, enable a graphics console for the utility VM
 	ConsolePipe           string              // The named pipe path to use for the serial console.  eg \\.\pipe\vmpipe
-	SCSIControllerCount   uint32              // The number of SCSI controllers. Defaults to 1. Currently we only support 0 or 1.
+	ConsoleLogFile        string              // If set and `ConsolePipe` is not, the serial console output is captured to this host file
+	ConsoleLogMaxSize     int64               // Size in bytes the console log file is rotated at. If `0` it is never rotated. Defaults to `DefaultConsoleLogMaxSize`
+	ConsoleLogMaxFiles    int                 // Number of rotated console log files retained. Defaults to `DefaultConsoleLogMaxFiles`
+	SCSIControllerCount   uint32              // The number of SCSI controllers. Defaults to 1. Limit at 4.
 	UseGuestConnection    bool                // Whether the HCS should connect to the UVM's GCS. Defaults to true
 	ExecCommandLine       string              // The command line to exec from init. Defaults to GCS
 	ForwardStdout         bool                // Whether stdout will be forwarded from the executed program. Defaults to false
@@ -60,6 +64,7 @@ type OptionsLCOW struct {
 	OutputHandler         OutputHandler       `json:"-"` // Controls how output received over HVSocket from the UVM is handled. Defaults to parsing output as logrus messages
 	VPMemDeviceCount      uint32              // Number of VPMem devices. Defaults to `DefaultVPMEMCount`. Limit at 128. If booting UVM from VHD, device 0 is taken.
 	VPMemSizeBytes        uint64              // Size of the VPMem devices. Defaults to `DefaultVPMemSizeBytes`.
+	VPMemNoMultiMapping   bool                // If true, each read-only layer is given its own VPMem device rather than being packed with other layers. Multi-mapping requires build 18362 or later.
 	PreferredRootFSType   PreferredRootFSType // If `KernelFile` is `InitrdFile` use `PreferredRootFSTypeInitRd`. If `KernelFile` is `VhdFile` use `PreferredRootFSTypeVHD`
 }
 
@@ -94,6 +99,8 @@ func NewDefaultOptionsLCOW(id, owner string) *OptionsLCOW {
 		KernelBootOptions:     "",
 		EnableGraphicsConsole: false,
 		ConsolePipe:           "",
+		ConsoleLogMaxSize:     DefaultConsoleLogMaxSize,
+		ConsoleLogMaxFiles:    DefaultConsoleLogMaxFiles,
 		SCSIControllerCount:   1,
 		UseGuestConnection:    true,
 		ExecCommandLine:       fmt.Sprintf("/bin/gcs -log-format json -loglevel %s", logrus.StandardLogger().Level.String()),
@@ -126,7 +133,7 @@ func NewDefaultOptionsLCOW(id, owner string) *OptionsLCOW {
 const linuxLogVsockPort = 109
 
 // CreateLCOW creates an HCS compute system representing a utility VM.
-func CreateLCOW(opts *OptionsLCOW) (_ *UtilityVM, err error) {
+func CreateLCOW(ctx context.Context, opts *OptionsLCOW) (_ *UtilityVM, err error) {
 	op := "uvm::CreateLCOW"
 	log := logrus.WithFields(logrus.Fields{
 		logfields.UVMID: opts.ID,
@@ -141,6 +148,9 @@ func CreateLCOW(opts *OptionsLCOW) (_ *UtilityVM, err error) {
 		}
 	}()
 
+	ctx, span := trace.StartSpan(ctx, op, trace.StringAttribute(logfields.UVMID, opts.ID))
+	defer func() { span.SetError(err); span.End() }()
+
 	// We dont serialize OutputHandler so if it is missing we need to put it back to the default.
 	if opts.OutputHandler == nil {
 		opts.OutputHandler = parseLogrus(opts.ID)
@@ -150,9 +160,16 @@ func CreateLCOW(opts *OptionsLCOW) (_ *UtilityVM, err error) {
 		id:                  opts.ID,
 		owner:               opts.Owner,
 		operatingSystem:     "linux",
+		memorySizeInMB:      opts.MemorySizeInMB,
 		scsiControllerCount: opts.SCSIControllerCount,
 		vpmemMaxCount:       opts.VPMemDeviceCount,
 		vpmemMaxSizeBytes:   opts.VPMemSizeBytes,
+		vpmemMultiMapping:   !opts.VPMemNoMultiMapping && osversion.Get().Build >= osversion.V19H1,
+		crashDumpPath:       opts.CrashDumpPath,
+		allowOvercommit:     opts.AllowOvercommit,
+		memoryMaximumInMB:   opts.MemoryMaximumInMB,
+		processorLimit:      opts.ProcessorLimit,
+		processorWeight:     opts.ProcessorWeight,
 	}
 
 	// To maintain compatability with Docker we need to automatically downgrade
@@ -168,8 +185,8 @@ func CreateLCOW(opts *OptionsLCOW) (_ *UtilityVM, err error) {
 		return nil, fmt.Errorf("boot file: '%s' not found", rootfsFullPath)
 	}
 
-	if opts.SCSIControllerCount > 1 {
-		return nil, fmt.Errorf("SCSI controller count must be 0 or 1") // Future extension here for up to 4
+	if opts.SCSIControllerCount > maxSCSIControllers {
+		return nil, fmt.Errorf("SCSI controller count cannot be greater than %d", maxSCSIControllers)
 	}
 	if opts.VPMemDeviceCount > MaxVPMEMCount {
 		return nil, fmt.Errorf("vpmem device count cannot be greater than %d", MaxVPMEMCount)
@@ -187,31 +204,116 @@ func CreateLCOW(opts *OptionsLCOW) (_ *UtilityVM, err error) {
 		return nil, fmt.Errorf("KernelDirectBoot is not support on builds older than 18286")
 	}
 
+	normalizeTopology(opts.Options)
+	if err := validateTopology(opts.Options, uvm.processorCount); err != nil {
+		return nil, err
+	}
+	doc, err := prepareLCOWDocument(opts, uvm.processorCount)
+	if err != nil {
+		return nil, err
+	}
+
+	uvm.initDevices()
+	if err := uvm.createCrashDumpPath(); err != nil {
+		return nil, err
+	}
+
+	if opts.PreferredRootFSType == PreferredRootFSTypeVHD {
+		// Add to our internal structure
+		if err := uvm.vpmemDevices.Insert(devicealloc.Entry{
+			Key:      opts.RootFSFile,
+			RefCount: 1,
+			Value:    &vpmemInfo{UVMPath: "/"},
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if capturesConsole(opts) {
+		// Capture the console so that the kernel output before a panic can be
+		// collected. The VM still terminates on panic.
+		uvm.consolePipe = guestConsolePipe(uvm.id)
+		uvm.consoleTail = crashdump.NewTailBuffer(crashdump.DefaultConsoleTailSize)
+		if opts.ConsoleLogFile != "" {
+			if err := uvm.openConsoleLog(opts.ConsoleLogFile, opts.ConsoleLogMaxSize, opts.ConsoleLogMaxFiles); err != nil {
+				return nil, err
+			}
+			defer func() {
+				// Once the compute system is created `Close` closes the log.
+				if err != nil && uvm.hcsSystem == nil {
+					uvm.consoleLog.Close()
+				}
+			}()
+		}
+	}
+
+	fullDoc, err := mergemaps.MergeJSON(doc, ([]byte)(opts.AdditionHCSDocumentJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge additional JSON '%s': %s", opts.AdditionHCSDocumentJSON, err)
+	}
+
+	uvm.createDocument, err = json.Marshal(fullDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	hcsSystem, err := opts.backend().CreateComputeSystem(ctx, uvm.id, fullDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	uvm.hcsSystem = hcsSystem
+	defer func() {
+		if err != nil {
+			uvm.Close()
+		}
+	}()
+
+	// Create a socket that the executed program can send to. This is usually
+	// used by GCS to send log data.
+	if opts.ForwardStdout || opts.ForwardStderr {
+		uvm.outputHandler = opts.OutputHandler
+		uvm.outputProcessingDone = make(chan struct{})
+		uvm.outputListener, err = uvm.listenVsock(linuxLogVsockPort)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return uvm, nil
+}
+
+// capturesConsole returns `true` if the serial console of a Linux utility VM
+// created with `opts` is captured by the UVM, see `startConsoleCapture`.
+func capturesConsole(opts *OptionsLCOW) bool {
+	return opts.ConsolePipe == "" && (opts.CrashDumpPath != "" || opts.ConsoleLogFile != "")
+}
+
+// prepareLCOWDocument returns the HCS document of a Linux utility VM created
+// with `opts` with `processorCount` vCPU's.
+func prepareLCOWDocument(opts *OptionsLCOW, processorCount int32) (*hcsschema.ComputeSystem, error) {
+	serviceTable, err := hvSocketServiceTable(opts.HvSocketServices)
+	if err != nil {
+		return nil, err
+	}
+	kernelFullPath := filepath.Join(opts.BootFilesPath, opts.KernelFile)
+	rootfsFullPath := filepath.Join(opts.BootFilesPath, opts.RootFSFile)
+
 	doc := &hcsschema.ComputeSystem{
-		Owner:                             uvm.owner,
+		Owner:                             opts.Owner,
 		SchemaVersion:                     schemaversion.SchemaV21(),
 		ShouldTerminateOnLastHandleClosed: true,
 		VirtualMachine: &hcsschema.VirtualMachine{
-			StopOnReset: true,
-			Chipset:     &hcsschema.Chipset{},
-			ComputeTopology: &hcsschema.Topology{
-				Memory: &hcsschema.Memory2{
-					SizeInMB:             opts.MemorySizeInMB,
-					AllowOvercommit:      opts.AllowOvercommit,
-					EnableDeferredCommit: opts.EnableDeferredCommit,
-				},
-				Processor: &hcsschema.Processor2{
-					Count:  uvm.processorCount,
-					Limit:  opts.ProcessorLimit,
-					Weight: opts.ProcessorWeight,
-				},
-			},
+			StopOnReset:     true,
+			Chipset:         &hcsschema.Chipset{},
+			ComputeTopology: computeTopology(opts.Options, processorCount),
 			Devices: &hcsschema.Devices{
 				HvSocket: &hcsschema.HvSocket2{
 					HvSocketConfig: &hcsschema.HvSocketSystemConfig{
 						// Allow administrators and SYSTEM to bind to vsock sockets
 						// so that we can create a GCS log socket.
 						DefaultBindSecurityDescriptor: "D:P(A;;FA;;;SY)(A;;FA;;;BA)",
+						ServiceTable:                  serviceTable,
 					},
 				},
 				Plan9: &hcsschema.Plan9{},
@@ -234,18 +336,13 @@ func CreateLCOW(opts *OptionsLCOW) (_ *UtilityVM, err error) {
 		}
 	}
 
-	if uvm.scsiControllerCount > 0 {
-		// TODO: JTERRY75 - this should enumerate scsicount and add an entry per value.
-		doc.VirtualMachine.Devices.Scsi = map[string]hcsschema.Scsi{
-			"0": {
-				Attachments: make(map[string]hcsschema.Attachment),
-			},
-		}
+	if opts.SCSIControllerCount > 0 {
+		doc.VirtualMachine.Devices.Scsi = scsiControllers(opts.SCSIControllerCount)
 	}
-	if uvm.vpmemMaxCount > 0 {
+	if opts.VPMemDeviceCount > 0 {
 		doc.VirtualMachine.Devices.VirtualPMem = &hcsschema.VirtualPMemController{
-			MaximumCount:     uvm.vpmemMaxCount,
-			MaximumSizeBytes: uvm.vpmemMaxSizeBytes,
+			MaximumCount:     opts.VPMemDeviceCount,
+			MaximumSizeBytes: opts.VPMemSizeBytes,
 		}
 	}
 
@@ -269,12 +366,6 @@ func CreateLCOW(opts *OptionsLCOW) (_ *UtilityVM, err error) {
 				ImageFormat: imageFormat,
 			},
 		}
-		// Add to our internal structure
-		uvm.vpmemDevices[0] = vpmemInfo{
-			hostPath: opts.RootFSFile,
-			uvmPath:  "/",
-			refCount: 1,
-		}
 	}
 
 	vmDebugging := false
@@ -286,6 +377,13 @@ func CreateLCOW(opts *OptionsLCOW) (_ *UtilityVM, err error) {
 				NamedPipe: opts.ConsolePipe,
 			},
 		}
+	} else if capturesConsole(opts) {
+		kernelArgs += " 8250_core.nr_uarts=1 8250_core.skip_txen_test=1 console=ttyS0,115200"
+		doc.VirtualMachine.Devices.ComPorts = map[string]hcsschema.ComPort{
+			"0": {
+				NamedPipe: guestConsolePipe(opts.ID),
+			},
+		}
 	} else {
 		kernelArgs += " 8250_core.nr_uarts=0"
 	}
@@ -347,47 +445,5 @@ func CreateLCOW(opts *OptionsLCOW) (_ *UtilityVM, err error) {
 		}
 	}
 
-	fullDoc, err := mergemaps.MergeJSON(doc, ([]byte)(opts.AdditionHCSDocumentJSON))
-	if err != nil {
-		return nil, fmt.Errorf("failed to merge additional JSON '%s': %s", opts.AdditionHCSDocumentJSON, err)
-	}
-
-	hcsSystem, err := hcs.CreateComputeSystem(uvm.id, fullDoc)
-	if err != nil {
-		return nil, err
-	}
-
-	uvm.hcsSystem = hcsSystem
-	defer func() {
-		if err != nil {
-			uvm.Close()
-		}
-	}()
-
-	// Create a socket that the executed program can send to. This is usually
-	// used by GCS to send log data.
-	if opts.ForwardStdout || opts.ForwardStderr {
-		uvm.outputHandler = opts.OutputHandler
-		uvm.outputProcessingDone = make(chan struct{})
-		uvm.outputListener, err = uvm.listenVsock(linuxLogVsockPort)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	return uvm, nil
-}
-
-func (uvm *UtilityVM) listenVsock(port uint32) (net.Listener, error) {
-	properties, err := uvm.hcsSystem.Properties()
-	if err != nil {
-		return nil, err
-	}
-	vmID, err := hvsock.GUIDFromString(properties.RuntimeID)
-	if err != nil {
-		return nil, err
-	}
-	serviceID, _ := hvsock.GUIDFromString("00000000-facb-11e6-bd58-64006a7986d3")
-	binary.LittleEndian.PutUint32(serviceID[0:4], port)
-	return hvsock.Listen(hvsock.Addr{VMID: vmID, ServiceID: serviceID})
+	return doc, nil
 }
@@ -1,6 +1,7 @@
 package uvm
 
 import (
+	"context"
 	"testing"
 )
 
@@ -10,7 +11,7 @@ func TestCreateBadBootFilesPath(t *testing.T) {
 	opts := NewDefaultOptionsLCOW(t.Name(), "")
 	opts.BootFilesPath = `c:\does\not\exist\I\hope`
 
-	_, err := CreateLCOW(opts)
+	_, err := CreateLCOW(context.Background(), opts)
 	if err == nil || err.Error() != `kernel: 'c:\does\not\exist\I\hope\kernel' not found` {
 		t.Fatal(err)
 	}
@@ -18,8 +19,95 @@ func TestCreateBadBootFilesPath(t *testing.T) {
 
 func TestCreateWCOWBadLayerFolders(t *testing.T) {
 	opts := NewDefaultOptionsWCOW(t.Name(), "")
-	_, err := CreateWCOW(opts)
+	_, err := CreateWCOW(context.Background(), opts)
 	if err == nil || (err != nil && err.Error() != `at least 2 LayerFolders must be supplied`) {
 		t.Fatal(err)
 	}
 }
+
+func Test_prepareWCOWDocument_Topology_Golden(t *testing.T) {
+	opts := NewDefaultOptionsWCOW("template", "test")
+	opts.StorageQoSIopsMaximum = 1000
+	opts.AllowOvercommit = false
+	opts.EnableLargePages = true
+	opts.NumaNodeCount = 2
+	opts.NumaPreferredPhysicalNodes = []uint32{0, 1}
+	opts.CPUGroupID = "a1d1ba0c-7e14-4c14-9d0c-8a7e4f1b2c3d"
+	opts.ExposeVirtualizationExtensions = true
+	if err := validateTopology(opts.Options, 4); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	compareGolden(t, "topology_wcow.json", prepareWCOWDocument(opts, 4, 1, templateTestUVMFolder, templateTestScratch))
+}
+
+func Test_validateTopology_Invalid_Error(t *testing.T) {
+	tests := map[string]func(opts *Options){
+		"large pages overcommit": func(opts *Options) {
+			opts.AllowOvercommit = true
+			opts.EnableLargePages = true
+		},
+		"numa nodes over processors": func(opts *Options) {
+			opts.NumaNodeCount = 3
+		},
+		"numa nodes over maximum": func(opts *Options) {
+			opts.NumaNodeCount = maxNumaNodeCount + 1
+		},
+		"preferred nodes without numa": func(opts *Options) {
+			opts.NumaPreferredPhysicalNodes = []uint32{0}
+		},
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			opts := newDefaultOptions(t.Name(), "test")
+			modify(opts)
+			if err := validateTopology(opts, 2); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func Test_normalizeTopology_DeferredCommitPhysical_Cleared(t *testing.T) {
+	opts := newDefaultOptions(t.Name(), "test")
+	opts.AllowOvercommit = false
+	opts.EnableDeferredCommit = true
+	normalizeTopology(opts)
+	if opts.EnableDeferredCommit {
+		t.Fatal("expected deferred commit to be cleared")
+	}
+	if err := validateTopology(opts, 2); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+
+	opts.AllowOvercommit = true
+	opts.EnableDeferredCommit = true
+	normalizeTopology(opts)
+	if !opts.EnableDeferredCommit {
+		t.Fatal("expected deferred commit to be kept for virtual memory backing")
+	}
+}
+
+func Test_prepareLCOWDocument_Topology_Golden(t *testing.T) {
+	opts := NewDefaultOptionsLCOW("uvm", "test")
+	opts.BootFilesPath = `C:\ContainerPlatform\LinuxBootFiles`
+	opts.KernelFile = KernelFile
+	opts.KernelDirect = true
+	opts.RootFSFile = InitrdFile
+	opts.PreferredRootFSType = PreferredRootFSTypeInitRd
+	opts.ExecCommandLine = "/bin/gcs -log-format json -loglevel info"
+	opts.CrashDumpPath = `C:\dumps\uvm`
+	opts.StorageQoSIopsMaximum = 1000
+	opts.EnableDeferredCommit = true
+	opts.NumaNodeCount = 2
+	opts.NumaPreferredPhysicalNodes = []uint32{0, 1}
+	opts.CPUGroupID = "a1d1ba0c-7e14-4c14-9d0c-8a7e4f1b2c3d"
+	opts.ExposeVirtualizationExtensions = true
+	if err := validateTopology(opts.Options, 4); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	doc, err := prepareLCOWDocument(opts, 4)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	compareGolden(t, "topology_lcow.json", doc)
+}
@@ -1,15 +1,18 @@
 package uvm
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 
-	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/devicealloc"
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/mergemaps"
 	"github.com/Microsoft/hcsshim/internal/schema2"
 	"github.com/Microsoft/hcsshim/internal/schemaversion"
+	"github.com/Microsoft/hcsshim/internal/trace"
 	"github.com/Microsoft/hcsshim/internal/uvmfolder"
 	"github.com/Microsoft/hcsshim/internal/wcow"
 	"github.com/sirupsen/logrus"
@@ -19,7 +22,8 @@ import (
 type OptionsWCOW struct {
 	*Options
 
-	LayerFolders []string // Set of folders for base layers and scratch. Ordered from top most read-only through base read-only layer, followed by scratch
+	LayerFolders        []string // Set of folders for base layers and scratch. Ordered from top most read-only through base read-only layer, followed by scratch
+	SCSIControllerCount uint32   // The number of SCSI controllers. Defaults to 1, which is also used if 0. Limit at 1, because the Windows GCS only maps disks on controller 0.
 }
 
 // NewDefaultOptionsWCOW creates the default options for a bootable version of
@@ -31,7 +35,8 @@ type OptionsWCOW struct {
 // executable files name.
 func NewDefaultOptionsWCOW(id, owner string) *OptionsWCOW {
 	return &OptionsWCOW{
-		Options: newDefaultOptions(id, owner),
+		Options:             newDefaultOptions(id, owner),
+		SCSIControllerCount: 1,
 	}
 }
 
@@ -40,7 +45,7 @@ func NewDefaultOptionsWCOW(id, owner string) *OptionsWCOW {
 // WCOW Notes:
 //   - The scratch is always attached to SCSI 0:0
 //
-func CreateWCOW(opts *OptionsWCOW) (_ *UtilityVM, err error) {
+func CreateWCOW(ctx context.Context, opts *OptionsWCOW) (_ *UtilityVM, err error) {
 	op := "uvm::CreateWCOW"
 	log := logrus.WithFields(logrus.Fields{
 		logfields.UVMID: opts.ID,
@@ -55,12 +60,20 @@ func CreateWCOW(opts *OptionsWCOW) (_ *UtilityVM, err error) {
 		}
 	}()
 
+	ctx, span := trace.StartSpan(ctx, op, trace.StringAttribute(logfields.UVMID, opts.ID))
+	defer func() { span.SetError(err); span.End() }()
+
 	uvm := &UtilityVM{
 		id:                  opts.ID,
 		owner:               opts.Owner,
 		operatingSystem:     "windows",
-		scsiControllerCount: 1,
-		vsmbShares:          make(map[string]*vsmbShare),
+		memorySizeInMB:      opts.MemorySizeInMB,
+		scsiControllerCount: opts.SCSIControllerCount,
+		crashDumpPath:       opts.CrashDumpPath,
+		allowOvercommit:     opts.AllowOvercommit,
+		memoryMaximumInMB:   opts.MemoryMaximumInMB,
+		processorLimit:      opts.ProcessorLimit,
+		processorWeight:     opts.ProcessorWeight,
 	}
 
 	// To maintain compatability with Docker we need to automatically downgrade
@@ -70,6 +83,24 @@ func CreateWCOW(opts *OptionsWCOW) (_ *UtilityVM, err error) {
 	if len(opts.LayerFolders) < 2 {
 		return nil, fmt.Errorf("at least 2 LayerFolders must be supplied")
 	}
+	if opts.SCSIControllerCount > 1 {
+		// The Windows GCS has no notion of a controller in its mapped
+		// virtual disk requests and always uses controller 0.
+		return nil, fmt.Errorf("SCSI controller count cannot be greater than 1 for WCOW")
+	}
+	normalizeTopology(opts.Options)
+	if err := validateTopology(opts.Options, uvm.processorCount); err != nil {
+		return nil, err
+	}
+	serviceTable, err := hvSocketServiceTable(opts.HvSocketServices)
+	if err != nil {
+		return nil, err
+	}
+	if uvm.scsiControllerCount == 0 {
+		// The scratch is always attached so there is at least one controller.
+		uvm.scsiControllerCount = 1
+	}
+	uvm.initDevices()
 	uvmFolder, err := uvmfolder.LocateUVMFolder(opts.LayerFolders)
 	if err != nil {
 		return nil, fmt.Errorf("failed to locate utility VM folder from layer folders: %s", err)
@@ -100,8 +131,45 @@ func CreateWCOW(opts *OptionsWCOW) (_ *UtilityVM, err error) {
 		}
 	}
 
+	if err := uvm.createCrashDumpPath(); err != nil {
+		return nil, err
+	}
+
+	doc := prepareWCOWDocument(opts, uvm.processorCount, uvm.scsiControllerCount, uvmFolder, scratchPath)
+	doc.VirtualMachine.Devices.HvSocket.HvSocketConfig.ServiceTable = serviceTable
+	if err := uvm.scsiLocations.Insert(devicealloc.Entry{
+		Key:      scratchPath,
+		RefCount: 1,
+		Value:    &scsiInfo{AttachmentType: "VirtualDisk"},
+	}); err != nil {
+		return nil, err
+	}
+
+	fullDoc, err := mergemaps.MergeJSON(doc, ([]byte)(opts.AdditionHCSDocumentJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge additional JSON '%s': %s", opts.AdditionHCSDocumentJSON, err)
+	}
+
+	uvm.createDocument, err = json.Marshal(fullDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	hcsSystem, err := opts.backend().CreateComputeSystem(ctx, uvm.id, fullDoc)
+	if err != nil {
+		logrus.Debugln("failed to create UVM: ", err)
+		return nil, err
+	}
+	uvm.hcsSystem = hcsSystem
+	return uvm, nil
+}
+
+// prepareWCOWDocument returns the HCS document of a Windows utility VM created
+// with `opts` that boots from the utility VM image in `uvmFolder` with the
+// scratch `scratchPath` attached at SCSI 0:0.
+func prepareWCOWDocument(opts *OptionsWCOW, processorCount int32, scsiControllerCount uint32, uvmFolder, scratchPath string) *hcsschema.ComputeSystem {
 	doc := &hcsschema.ComputeSystem{
-		Owner:                             uvm.owner,
+		Owner:                             opts.Owner,
 		SchemaVersion:                     schemaversion.SchemaV21(),
 		ShouldTerminateOnLastHandleClosed: true,
 		VirtualMachine: &hcsschema.VirtualMachine{
@@ -114,32 +182,10 @@ func CreateWCOW(opts *OptionsWCOW) (_ *UtilityVM, err error) {
 					},
 				},
 			},
-			ComputeTopology: &hcsschema.Topology{
-				Memory: &hcsschema.Memory2{
-					SizeInMB:        opts.MemorySizeInMB,
-					AllowOvercommit: opts.AllowOvercommit,
-					// EnableHotHint is not compatible with physical.
-					EnableHotHint:        opts.AllowOvercommit,
-					EnableDeferredCommit: opts.EnableDeferredCommit,
-				},
-				Processor: &hcsschema.Processor2{
-					Count:  uvm.processorCount,
-					Limit:  opts.ProcessorLimit,
-					Weight: opts.ProcessorWeight,
-				},
-			},
+			ComputeTopology: computeTopology(opts.Options, processorCount),
 			GuestConnection: &hcsschema.GuestConnection{},
 			Devices: &hcsschema.Devices{
-				Scsi: map[string]hcsschema.Scsi{
-					"0": {
-						Attachments: map[string]hcsschema.Attachment{
-							"0": {
-								Path:  scratchPath,
-								Type_: "VirtualDisk",
-							},
-						},
-					},
-				},
+				Scsi: scsiControllers(scsiControllerCount),
 				HvSocket: &hcsschema.HvSocket2{
 					HvSocketConfig: &hcsschema.HvSocketSystemConfig{
 						// Allow administrators and SYSTEM to bind to vsock sockets
@@ -167,6 +213,9 @@ func CreateWCOW(opts *OptionsWCOW) (_ *UtilityVM, err error) {
 		},
 	}
 
+	// EnableHotHint is not compatible with physical.
+	doc.VirtualMachine.ComputeTopology.Memory.EnableHotHint = opts.AllowOvercommit
+
 	// Handle StorageQoS if set
 	if opts.StorageQoSBandwidthMaximum > 0 || opts.StorageQoSIopsMaximum > 0 {
 		doc.VirtualMachine.StorageQoS = &hcsschema.StorageQoS{
@@ -175,18 +224,17 @@ func CreateWCOW(opts *OptionsWCOW) (_ *UtilityVM, err error) {
 		}
 	}
 
-	uvm.scsiLocations[0][0].hostPath = doc.VirtualMachine.Devices.Scsi["0"].Attachments["0"].Path
-
-	fullDoc, err := mergemaps.MergeJSON(doc, ([]byte)(opts.AdditionHCSDocumentJSON))
-	if err != nil {
-		return nil, fmt.Errorf("failed to merge additional JSON '%s': %s", opts.AdditionHCSDocumentJSON, err)
+	if opts.CrashDumpPath != "" {
+		doc.VirtualMachine.Devices.GuestCrashReporting = &hcsschema.GuestCrashReporting{
+			WindowsCrashSettings: &hcsschema.WindowsCrashReporting{
+				DumpFileName: windowsCrashDumpFile(opts.CrashDumpPath, opts.ID),
+			},
+		}
 	}
 
-	hcsSystem, err := hcs.CreateComputeSystem(uvm.id, fullDoc)
-	if err != nil {
-		logrus.Debugln("failed to create UVM: ", err)
-		return nil, err
+	doc.VirtualMachine.Devices.Scsi["0"].Attachments["0"] = hcsschema.Attachment{
+		Path:  scratchPath,
+		Type_: "VirtualDisk",
 	}
-	uvm.hcsSystem = hcsSystem
-	return uvm, nil
+	return doc
 }
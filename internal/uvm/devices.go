@@ -0,0 +1,59 @@
+package uvm
+
+import (
+	"strconv"
+
+	"github.com/Microsoft/hcsshim/internal/devicealloc"
+	"github.com/Microsoft/hcsshim/internal/regionalloc"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
+)
+
+const (
+	// maxSCSIControllers is the number of SCSI controllers supported by Hyper-V.
+	maxSCSIControllers = 4
+	// scsiLUNsPerController is the number of LUNs of each SCSI controller.
+	scsiLUNsPerController = 64
+)
+
+// initDevices creates the device allocators of the utility VM.
+// `operatingSystem`, `scsiControllerCount`, `vpmemMaxCount` and
+// `vpmemMaxSizeBytes` MUST be set before calling this function.
+func (uvm *UtilityVM) initDevices() {
+	uvm.scsiLocations = devicealloc.New(int(uvm.scsiControllerCount), scsiLUNsPerController, func() interface{} { return &scsiInfo{} })
+
+	// A controller with `0` slots is unlimited so a utility VM without VPMem
+	// devices has no VPMem controller.
+	vpmemControllers := 0
+	if uvm.vpmemMaxCount > 0 {
+		vpmemControllers = 1
+	}
+	uvm.vpmemDevices = devicealloc.New(vpmemControllers, int(uvm.vpmemMaxCount), func() interface{} { return &vpmemInfo{} })
+	uvm.vpmemMappings = regionalloc.NewPool(uvm.vpmemMaxSizeBytes, vpmemMappingAlignment)
+
+	// VSMB shares are named by `vsmbCounter` rather than by their slot so
+	// there is no limit to the number of shares.
+	vsmbControllers := 0
+	if uvm.operatingSystem == "windows" {
+		vsmbControllers = 1
+	}
+	uvm.vsmbShares = devicealloc.New(vsmbControllers, 0, func() interface{} { return &vsmbShare{} })
+
+	// Plan9 shares are named by `plan9Counter` in the same way.
+	plan9Controllers := 0
+	if uvm.operatingSystem == "linux" {
+		plan9Controllers = 1
+	}
+	uvm.plan9Shares = devicealloc.New(plan9Controllers, 0, func() interface{} { return &plan9Info{} })
+}
+
+// scsiControllers returns the HCS document settings for `count` SCSI
+// controllers without any attachments.
+func scsiControllers(count uint32) map[string]hcsschema.Scsi {
+	controllers := make(map[string]hcsschema.Scsi, count)
+	for i := 0; i < int(count); i++ {
+		controllers[strconv.Itoa(i)] = hcsschema.Scsi{
+			Attachments: make(map[string]hcsschema.Attachment),
+		}
+	}
+	return controllers
+}
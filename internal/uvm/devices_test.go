@@ -0,0 +1,39 @@
+package uvm
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func Test_scsiControllers(t *testing.T) {
+	for count := uint32(0); count <= maxSCSIControllers; count++ {
+		controllers := scsiControllers(count)
+		if len(controllers) != int(count) {
+			t.Fatalf("expected %d controllers, got: %d", count, len(controllers))
+		}
+		for i := 0; i < int(count); i++ {
+			c, ok := controllers[strconv.Itoa(i)]
+			if !ok || c.Attachments == nil {
+				t.Fatalf("expected controller %d with attachments, got: %+v", i, controllers)
+			}
+		}
+	}
+}
+
+func Test_initDevices_SCSIAllocatesAcrossControllers(t *testing.T) {
+	uvm := &UtilityVM{operatingSystem: "linux", scsiControllerCount: 2}
+	uvm.initDevices()
+	for i := 0; i < 2*scsiLUNsPerController; i++ {
+		e, err := uvm.scsiLocations.Allocate(fmt.Sprintf(`c:\disk%d.vhdx`, i), &scsiInfo{})
+		if err != nil {
+			t.Fatalf("allocation %d should not have failed, got: %v", i, err)
+		}
+		if e.Controller != i/scsiLUNsPerController || e.Slot != i%scsiLUNsPerController {
+			t.Fatalf("allocation %d at unexpected location %s", i, e.Location)
+		}
+	}
+	if _, err := uvm.scsiLocations.Allocate("full", &scsiInfo{}); err == nil {
+		t.Fatal("should have failed with every LUN in use")
+	}
+}
@@ -0,0 +1,90 @@
+package uvm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	winio "github.com/Microsoft/go-winio"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
+	"github.com/linuxkit/virtsock/pkg/hvsock"
+)
+
+// vsockServiceID returns the HvSocket service ID of the vsock `port` of a
+// Linux utility VM.
+func vsockServiceID(port uint32) hvsock.GUID {
+	serviceID, _ := hvsock.GUIDFromString("00000000-facb-11e6-bd58-64006a7986d3")
+	binary.LittleEndian.PutUint32(serviceID[0:4], port)
+	return serviceID
+}
+
+// parseServiceID parses the HvSocket service ID `s`.
+func parseServiceID(s string) (hvsock.GUID, error) {
+	g, err := hvsock.GUIDFromString(s)
+	if err != nil || len(s) != 36 {
+		return hvsock.GUID{}, fmt.Errorf("hvsocket service ID '%s' is not a GUID", s)
+	}
+	return g, nil
+}
+
+// hvSocketServiceTable validates the HvSocket services `services` of
+// `Options.HvSocketServices` and returns the service table of the HCS
+// document. The service IDs of the table are normalized to lower case.
+func hvSocketServiceTable(services map[string]hcsschema.HvSocketServiceConfig) (map[string]hcsschema.HvSocketServiceConfig, error) {
+	if len(services) == 0 {
+		return nil, nil
+	}
+	outputServiceID := vsockServiceID(linuxLogVsockPort)
+	table := make(map[string]hcsschema.HvSocketServiceConfig, len(services))
+	for id, config := range services {
+		g, err := parseServiceID(id)
+		if err != nil {
+			return nil, err
+		}
+		if g == outputServiceID {
+			return nil, fmt.Errorf("hvsocket service %s is reserved for the utility VM output", id)
+		}
+		for _, sddl := range []string{config.BindSecurityDescriptor, config.ConnectSecurityDescriptor} {
+			if sddl == "" {
+				continue
+			}
+			if _, err := winio.SddlToSecurityDescriptor(sddl); err != nil {
+				return nil, fmt.Errorf("hvsocket service %s has an invalid security descriptor '%s': %s", id, sddl, err)
+			}
+		}
+		key := g.String()
+		if _, ok := table[key]; ok {
+			return nil, fmt.Errorf("hvsocket service %s is registered more than once", id)
+		}
+		table[key] = config
+	}
+	return table, nil
+}
+
+// ListenHvSocket listens for connections from the utility VM to the HvSocket
+// service `serviceID`. The service is usually registered with
+// `Options.HvSocketServices`, otherwise only SYSTEM and administrators can
+// bind to it. The UVM MUST have been created.
+func (uvm *UtilityVM) ListenHvSocket(serviceID string) (net.Listener, error) {
+	g, err := parseServiceID(serviceID)
+	if err != nil {
+		return nil, err
+	}
+	return uvm.listenHvSocket(g)
+}
+
+func (uvm *UtilityVM) listenHvSocket(serviceID hvsock.GUID) (net.Listener, error) {
+	properties, err := uvm.hcsSystem.Properties()
+	if err != nil {
+		return nil, err
+	}
+	vmID, err := hvsock.GUIDFromString(properties.RuntimeID)
+	if err != nil {
+		return nil, err
+	}
+	return hvsock.Listen(hvsock.Addr{VMID: vmID, ServiceID: serviceID})
+}
+
+func (uvm *UtilityVM) listenVsock(port uint32) (net.Listener, error) {
+	return uvm.listenHvSocket(vsockServiceID(port))
+}
@@ -0,0 +1,51 @@
+package uvm
+
+import (
+	"testing"
+
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
+)
+
+func Test_hvSocketServiceTable_Normalized(t *testing.T) {
+	config := hcsschema.HvSocketServiceConfig{
+		BindSecurityDescriptor:    "D:P(A;;FA;;;SY)(A;;FA;;;BA)",
+		ConnectSecurityDescriptor: "D:P(A;;FA;;;SY)",
+	}
+	table, err := hvSocketServiceTable(map[string]hcsschema.HvSocketServiceConfig{
+		"0000040A-FACB-11E6-BD58-64006A7986D3": config,
+	})
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if len(table) != 1 || table["0000040a-facb-11e6-bd58-64006a7986d3"] != config {
+		t.Fatalf("unexpected service table: %+v", table)
+	}
+}
+
+func Test_hvSocketServiceTable_Empty_Nil(t *testing.T) {
+	table, err := hvSocketServiceTable(nil)
+	if err != nil || table != nil {
+		t.Fatalf("expected no service table, got: %+v, %v", table, err)
+	}
+}
+
+func Test_hvSocketServiceTable_Invalid_Error(t *testing.T) {
+	tests := map[string]map[string]hcsschema.HvSocketServiceConfig{
+		"guid":     {"not-a-guid": {}},
+		"short":    {"0000040a-facb-11e6-bd58-64006a79": {}},
+		"reserved": {"0000006d-facb-11e6-bd58-64006a7986d3": {}},
+		"bind":     {"0000040a-facb-11e6-bd58-64006a7986d3": {BindSecurityDescriptor: "not sddl"}},
+		"connect":  {"0000040a-facb-11e6-bd58-64006a7986d3": {ConnectSecurityDescriptor: "not sddl"}},
+		"duplicate": {
+			"0000040a-facb-11e6-bd58-64006a7986d3": {},
+			"0000040A-FACB-11E6-BD58-64006A7986D3": {},
+		},
+	}
+	for name, services := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := hvSocketServiceTable(services); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
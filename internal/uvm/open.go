@@ -0,0 +1,93 @@
+package uvm
+
+import (
+	"io"
+
+	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/sirupsen/logrus"
+)
+
+// Manifest returns a manifest of the utility VM and all of its currently
+// attached resources. It can be persisted and later passed to `Open` to manage
+// the running utility VM from another process.
+func (uvm *UtilityVM) Manifest() (*CheckpointManifest, error) {
+	return uvm.checkpointManifest()
+}
+
+// Open opens the already running utility VM described by `m`. The resource
+// tracking of the returned utility VM matches `m` so that resources attached
+// by the process that created the utility VM can be released.
+//
+// Output forwarding from the guest is not re-established.
+func Open(m *CheckpointManifest) (_ *UtilityVM, err error) {
+	op := "uvm::Open"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: m.ID,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	uvm, err := newUtilityVMFromManifest(m)
+	if err != nil {
+		return nil, err
+	}
+	hcsSystem, err := hcs.Backend().OpenComputeSystem(uvm.id)
+	if err != nil {
+		return nil, err
+	}
+	uvm.hcsSystem = hcsSystem
+	return uvm, nil
+}
+
+// Release closes the handle of this process to the utility VM without
+// terminating it. It is used to hand the utility VM off to another process
+// that has opened it with `Open`.
+//
+// The guest connects its output only once. If it already connected, the output
+// handler of this process keeps handling the output until the utility VM exits.
+// To hand the output off as well create the utility VM with the `Handle` of a
+// `gcsoutput.Relay` as its output handler.
+func (uvm *UtilityVM) Release() error {
+	logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+	}).Debug("uvm::Release")
+
+	if uvm.outputProcessingCancel != nil {
+		uvm.outputProcessingCancel()
+	}
+	if uvm.outputListener != nil {
+		close(uvm.outputProcessingDone)
+		uvm.outputListener.Close()
+		uvm.outputListener = nil
+	}
+	return uvm.hcsSystem.Close()
+}
+
+// ForwardOutput handles the output of the guest read from `r` with `handler`
+// until it ends and then closes `r`. It is used for a utility VM opened with
+// `Open` whose output is relayed to this process by the process that created
+// it. `Wait` waits for the output to be handled.
+func (uvm *UtilityVM) ForwardOutput(r io.ReadCloser, handler OutputHandler) {
+	if handler == nil {
+		handler = parseLogrus(uvm.id)
+	}
+	done := make(chan struct{})
+	uvm.outputHandler = handler
+	uvm.outputProcessingDone = done
+	go func() {
+		defer close(done)
+		defer r.Close()
+		handler(r)
+	}()
+}
@@ -0,0 +1,44 @@
+package uvm
+
+import (
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/sirupsen/logrus"
+)
+
+// Pause synchronously pauses the utility VM and all containers hosted in it.
+func (uvm *UtilityVM) Pause() (err error) {
+	op := "uvm::Pause"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	return uvm.hcsSystem.Pause()
+}
+
+// Resume synchronously resumes a previously paused utility VM.
+func (uvm *UtilityVM) Resume() (err error) {
+	op := "uvm::Resume"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	return uvm.hcsSystem.Resume()
+}
@@ -1,10 +1,13 @@
 package uvm
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 
+	"github.com/Microsoft/hcsshim/internal/devicealloc"
 	"github.com/Microsoft/hcsshim/internal/guestrequest"
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/requesttype"
@@ -13,14 +16,110 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// Plan9Share is a reference to a Plan9 share of a utility VM returned by
+// `AddPlan9`. Every reference MUST be removed with `RemovePlan9`.
 type Plan9Share struct {
 	name, uvmPath string
 }
 
+// plan9ShareJSON is the serialized form of a Plan9Share.
+type plan9ShareJSON struct {
+	Name    string `json:"Name"`
+	UVMPath string `json:"UVMPath"`
+}
+
+// MarshalJSON allows a Plan9Share to be persisted and later used to remove the
+// share from a utility VM opened with `Open`.
+func (s *Plan9Share) MarshalJSON() ([]byte, error) {
+	return json.Marshal(plan9ShareJSON{Name: s.name, UVMPath: s.uvmPath})
+}
+
+// UnmarshalJSON restores a Plan9Share previously serialized with MarshalJSON.
+func (s *Plan9Share) UnmarshalJSON(b []byte) error {
+	var j plan9ShareJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	s.name, s.uvmPath = j.Name, j.UVMPath
+	return nil
+}
+
+// UVMPath returns the path the share is mounted at in the utility VM.
+func (s *Plan9Share) UVMPath() string {
+	return s.uvmPath
+}
+
+// plan9Info is the value of a Plan9 share mapped to a Linux utility VM in
+// `UtilityVM.plan9Shares`.
+type plan9Info struct {
+	Settings  hcsschema.Plan9Share `json:"Settings"`
+	UVMPath   string               `json:"UVMPath"`
+	Exclusive bool                 `json:"Exclusive,omitempty"`
+}
+
 const plan9Port = 564
 
-// AddPlan9 adds a Plan9 share to a utility VM.
-func (uvm *UtilityVM) AddPlan9(hostPath string, uvmPath string, readOnly bool, restrict bool, allowedNames []string) (_ *Plan9Share, err error) {
+// TODO: JTERRY75 - These are marked private in the schema. For now use them
+// but when there are public variants we need to switch to them.
+const (
+	plan9ShareFlagsReadOnly           int32 = 0x00000001
+	plan9ShareFlagsLinuxMetadata      int32 = 0x00000004
+	plan9ShareFlagsCaseSensitive      int32 = 0x00000008
+	plan9ShareFlagsRestrictFileAccess int32 = 0x00000080
+)
+
+// plan9Key returns the key of the Plan9 share of `hostPath` with the given
+// options in `UtilityVM.plan9Shares`. Shares with the same key are the same
+// share.
+func plan9Key(hostPath string, readOnly, restrict bool, allowedNames []string) string {
+	names := append([]string(nil), allowedNames...)
+	sort.Strings(names)
+	b, _ := json.Marshal(struct {
+		Path         string
+		ReadOnly     bool
+		Restrict     bool
+		AllowedNames []string
+	}{hostPath, readOnly, restrict, names})
+	return string(b)
+}
+
+// plan9ExclusiveKey returns the key of the exclusive Plan9 share `name` in
+// `UtilityVM.plan9Shares`. It never matches a `plan9Key` so the share is never
+// reused.
+func plan9ExclusiveKey(name string) string {
+	return "exclusive:" + name
+}
+
+// plan9SettingsKey returns the `plan9Key` of the share with `settings`.
+func plan9SettingsKey(settings hcsschema.Plan9Share) string {
+	return plan9Key(
+		settings.Path,
+		settings.Flags&plan9ShareFlagsReadOnly != 0,
+		settings.Flags&plan9ShareFlagsRestrictFileAccess != 0,
+		settings.AllowedFiles)
+}
+
+// AddPlan9 adds a Plan9 share to a utility VM. Each Plan9 share is ref-counted
+// by its host path, read-only, restrict and allowed names and only added if it
+// isn't already.
+//
+// The share is mounted at `uvmPath` when it is added. If `uvmPath` is empty a
+// path is generated. A share that is already added is not mounted again, so
+// the caller MUST use `Plan9Share.UVMPath` rather than `uvmPath` and bind the
+// mount into each container that uses it.
+func (uvm *UtilityVM) AddPlan9(hostPath string, uvmPath string, readOnly bool, restrict bool, allowedNames []string) (*Plan9Share, error) {
+	return uvm.addPlan9(hostPath, uvmPath, readOnly, restrict, allowedNames, false)
+}
+
+// AddPlan9Exclusive adds a Plan9 share of `hostPath` to a utility VM that is
+// never reused by `AddPlan9` or `AddPlan9Exclusive`, even for the same host
+// path. This is used for a directory in the utility VM that belongs to a
+// single container, such as its root filesystem.
+func (uvm *UtilityVM) AddPlan9Exclusive(hostPath string, uvmPath string, readOnly bool) (*Plan9Share, error) {
+	return uvm.addPlan9(hostPath, uvmPath, readOnly, false, nil, true)
+}
+
+func (uvm *UtilityVM) addPlan9(hostPath string, uvmPath string, readOnly bool, restrict bool, allowedNames []string, exclusive bool) (_ *Plan9Share, err error) {
 	op := "uvm::AddPlan9"
 	log := logrus.WithFields(logrus.Fields{
 		logfields.UVMID: uvm.id,
@@ -29,6 +128,7 @@ func (uvm *UtilityVM) AddPlan9(hostPath string, uvmPath string, readOnly bool, r
 		"readOnly":      readOnly,
 		"restrict":      restrict,
 		"allowedNames":  allowedNames,
+		"exclusive":     exclusive,
 	})
 	log.Debug(op + " - Begin Operation")
 	defer func() {
@@ -46,38 +146,27 @@ func (uvm *UtilityVM) AddPlan9(hostPath string, uvmPath string, readOnly bool, r
 	if restrict && osversion.Get().Build < 18328 {
 		return nil, errors.New("single-file mappings are not supported on this build of Windows")
 	}
-	if uvmPath == "" {
-		return nil, fmt.Errorf("uvmPath must be passed to AddPlan9")
-	}
-
-	// TODO: JTERRY75 - These are marked private in the schema. For now use them
-	// but when there are public variants we need to switch to them.
-	const (
-		shareFlagsReadOnly           int32 = 0x00000001
-		shareFlagsLinuxMetadata      int32 = 0x00000004
-		shareFlagsCaseSensitive      int32 = 0x00000008
-		shareFlagsRestrictFileAccess int32 = 0x00000080
-	)
-
-	// TODO: JTERRY75 - `shareFlagsCaseSensitive` only works if the Windows
-	// `hostPath` supports case sensitivity. We need to detect this case before
-	// forwarding this flag in all cases.
-	flags := shareFlagsLinuxMetadata // | shareFlagsCaseSensitive
+
+	// TODO: JTERRY75 - `plan9ShareFlagsCaseSensitive` only works if the
+	// Windows `hostPath` supports case sensitivity. We need to detect this
+	// case before forwarding this flag in all cases.
+	flags := plan9ShareFlagsLinuxMetadata // | plan9ShareFlagsCaseSensitive
 	if readOnly {
-		flags |= shareFlagsReadOnly
+		flags |= plan9ShareFlagsReadOnly
 	}
 	if restrict {
-		flags |= shareFlagsRestrictFileAccess
+		flags |= plan9ShareFlagsRestrictFileAccess
 	}
 
 	uvm.m.Lock()
-	index := uvm.plan9Counter
-	uvm.plan9Counter++
-	uvm.m.Unlock()
-	name := strconv.FormatUint(index, 10)
-
-	modification := &hcsschema.ModifySettingRequest{
-		RequestType: requesttype.Add,
+	defer uvm.m.Unlock()
+	r := uvm.plan9Shares.Reserve()
+	defer r.Rollback()
+	name := strconv.FormatUint(uvm.plan9Counter, 10)
+	if uvmPath == "" {
+		uvmPath = "/tmp/plan9/" + name
+	}
+	pi := &plan9Info{
 		Settings: hcsschema.Plan9Share{
 			Name:         name,
 			AccessName:   name,
@@ -86,25 +175,52 @@ func (uvm *UtilityVM) AddPlan9(hostPath string, uvmPath string, readOnly bool, r
 			Flags:        flags,
 			AllowedFiles: allowedNames,
 		},
-		ResourcePath: fmt.Sprintf("VirtualMachine/Devices/Plan9/Shares"),
-		GuestRequest: guestrequest.GuestRequest{
-			ResourceType: guestrequest.ResourceTypeMappedDirectory,
+		UVMPath:   uvmPath,
+		Exclusive: exclusive,
+	}
+	key := plan9Key(hostPath, readOnly, restrict, allowedNames)
+	if exclusive {
+		key = plan9ExclusiveKey(name)
+	}
+	e, added, err := r.Acquire(key, pi)
+	if err != nil {
+		return nil, err
+	}
+	if added {
+		uvm.plan9Counter++
+		modification := &hcsschema.ModifySettingRequest{
 			RequestType:  requesttype.Add,
-			Settings: guestrequest.LCOWMappedDirectory{
-				MountPath: uvmPath,
-				ShareName: name,
-				Port:      plan9Port,
-				ReadOnly:  readOnly,
+			Settings:     pi.Settings,
+			ResourcePath: "VirtualMachine/Devices/Plan9/Shares",
+			GuestRequest: guestrequest.GuestRequest{
+				ResourceType: guestrequest.ResourceTypeMappedDirectory,
+				RequestType:  requesttype.Add,
+				Settings: guestrequest.LCOWMappedDirectory{
+					MountPath: uvmPath,
+					ShareName: name,
+					Port:      plan9Port,
+					ReadOnly:  readOnly,
+				},
 			},
-		},
+		}
+		if err := uvm.Modify(modification); err != nil {
+			return nil, err
+		}
 	}
+	r.Commit()
+	pi = e.Value.(*plan9Info)
+	return &Plan9Share{name: pi.Settings.Name, uvmPath: pi.UVMPath}, nil
+}
 
-	if err := uvm.Modify(modification); err != nil {
-		return nil, err
+// plan9EntryL returns the entry of the Plan9 share `name`. The mutex MUST be
+// held.
+func (uvm *UtilityVM) plan9EntryL(name string) (devicealloc.Entry, bool) {
+	for _, e := range uvm.plan9Shares.Entries() {
+		if e.Value.(*plan9Info).Settings.Name == name {
+			return e, true
+		}
 	}
-
-	share := &Plan9Share{name: name, uvmPath: uvmPath}
-	return share, nil
+	return devicealloc.Entry{}, false
 }
 
 // RemovePlan9 removes a Plan9 share from a utility VM. Each Plan9 share is ref-counted
@@ -130,20 +246,36 @@ func (uvm *UtilityVM) RemovePlan9(share *Plan9Share) (err error) {
 		return errNotSupported
 	}
 
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+	e, ok := uvm.plan9EntryL(share.name)
+	if !ok {
+		return fmt.Errorf("plan9 share %s is not present in %s, cannot remove", share.name, uvm.id)
+	}
+	if _, last, err := uvm.plan9Shares.Release(e.Key); err != nil || !last {
+		return err
+	}
+
+	pi := e.Value.(*plan9Info)
+	uvmPath := pi.UVMPath
+	if uvmPath == "" {
+		// Restored from a manifest written before the mount path was recorded.
+		uvmPath = share.uvmPath
+	}
 	modification := &hcsschema.ModifySettingRequest{
 		RequestType: requesttype.Remove,
 		Settings: hcsschema.Plan9Share{
-			Name:       share.name,
-			AccessName: share.name,
+			Name:       pi.Settings.Name,
+			AccessName: pi.Settings.Name,
 			Port:       plan9Port,
 		},
-		ResourcePath: fmt.Sprintf("VirtualMachine/Devices/Plan9/Shares"),
+		ResourcePath: "VirtualMachine/Devices/Plan9/Shares",
 		GuestRequest: guestrequest.GuestRequest{
 			ResourceType: guestrequest.ResourceTypeMappedDirectory,
 			RequestType:  requesttype.Remove,
 			Settings: guestrequest.LCOWMappedDirectory{
-				MountPath: share.uvmPath,
-				ShareName: share.name,
+				MountPath: uvmPath,
+				ShareName: pi.Settings.Name,
 				Port:      plan9Port,
 			},
 		},
@@ -151,5 +283,7 @@ func (uvm *UtilityVM) RemovePlan9(share *Plan9Share) (err error) {
 	if err := uvm.Modify(modification); err != nil {
 		return fmt.Errorf("failed to remove plan9 share %s from %s: %+v: %s", share.name, uvm.id, modification, err)
 	}
-	return nil
+
+	_, err = uvm.plan9Shares.Free(e.Key)
+	return err
 }
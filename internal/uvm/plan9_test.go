@@ -0,0 +1,133 @@
+package uvm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/cow/fake"
+)
+
+// newPlan9TestUVM returns a running Linux utility VM that Plan9 shares can be
+// added to.
+func newPlan9TestUVM(t *testing.T) (*UtilityVM, *fake.ComputeSystem) {
+	cs, err := (&fake.Backend{}).CreateComputeSystem(context.TODO(), t.Name(), nil)
+	if err != nil {
+		t.Fatalf("failed to create compute system: %v", err)
+	}
+	if err := cs.Start(); err != nil {
+		t.Fatalf("failed to start compute system: %v", err)
+	}
+	uvm := &UtilityVM{
+		id:              t.Name(),
+		operatingSystem: "linux",
+		hcsSystem:       cs,
+	}
+	uvm.initDevices()
+	return uvm, cs.(*fake.ComputeSystem)
+}
+
+func Test_AddPlan9_SameShare_RefCounted(t *testing.T) {
+	uvm, cs := newPlan9TestUVM(t)
+
+	s1, err := uvm.AddPlan9(`C:\data`, "", false, false, nil)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	s2, err := uvm.AddPlan9(`C:\data`, "/run/other", false, false, nil)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if s1.name != s2.name || s2.UVMPath() != "/tmp/plan9/0" {
+		t.Fatalf("expected the same share, got: %+v %+v", s1, s2)
+	}
+	if len(cs.Modifications()) != 1 {
+		t.Fatalf("expected 1 modification, got: %d", len(cs.Modifications()))
+	}
+	if r := uvm.AttachedResources(); r.Plan9 != 1 {
+		t.Fatalf("expected 1 plan9 share, got: %d", r.Plan9)
+	}
+}
+
+func Test_AddPlan9_DifferentOptions_NewShare(t *testing.T) {
+	uvm, _ := newPlan9TestUVM(t)
+
+	s1, err := uvm.AddPlan9(`C:\data`, "", false, false, nil)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	s2, err := uvm.AddPlan9(`C:\data`, "", true, false, nil)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if s1.name == s2.name || s1.UVMPath() == s2.UVMPath() {
+		t.Fatalf("expected different shares, got: %+v %+v", s1, s2)
+	}
+	if r := uvm.AttachedResources(); r.Plan9 != 2 {
+		t.Fatalf("expected 2 plan9 shares, got: %d", r.Plan9)
+	}
+}
+
+func Test_RemovePlan9_LastReference_Removes(t *testing.T) {
+	uvm, cs := newPlan9TestUVM(t)
+
+	s1, err := uvm.AddPlan9(`C:\data`, "/run/data", false, false, nil)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	s2, err := uvm.AddPlan9(`C:\data`, "", false, false, nil)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+
+	if err := uvm.RemovePlan9(s1); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if len(cs.Modifications()) != 1 {
+		t.Fatalf("expected only the add modification, got: %d", len(cs.Modifications()))
+	}
+	if err := uvm.RemovePlan9(s2); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	verifyModifications(t, cs,
+		`{"ResourcePath":"VirtualMachine/Devices/Plan9/Shares","RequestType":"Add","Settings":{"Name":"0","AccessName":"0","Path":"C:\\data","Port":564,"Flags":4},"GuestRequest":{"RequestType":"Add","ResourceType":"MappedDirectory","Settings":{"MountPath":"/run/data","Port":564,"ShareName":"0"}}}`,
+		`{"ResourcePath":"VirtualMachine/Devices/Plan9/Shares","RequestType":"Remove","Settings":{"Name":"0","AccessName":"0","Port":564},"GuestRequest":{"RequestType":"Remove","ResourceType":"MappedDirectory","Settings":{"MountPath":"/run/data","Port":564,"ShareName":"0"}}}`)
+	if r := uvm.AttachedResources(); r.Plan9 != 0 {
+		t.Fatalf("expected no plan9 shares, got: %d", r.Plan9)
+	}
+	if err := uvm.RemovePlan9(s2); err == nil {
+		t.Fatal("expected an error removing a removed share")
+	}
+}
+
+func Test_AddPlan9Exclusive_SameHostPath_NewShare(t *testing.T) {
+	uvm, cs := newPlan9TestUVM(t)
+
+	s1, err := uvm.AddPlan9Exclusive(`C:\rootfs`, "/run/c1/rootfs", false)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	s2, err := uvm.AddPlan9Exclusive(`C:\rootfs`, "/run/c2/rootfs", false)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	s3, err := uvm.AddPlan9(`C:\rootfs`, "", false, false, nil)
+	if err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if s1.name == s2.name || s1.name == s3.name || s2.name == s3.name {
+		t.Fatalf("expected different shares, got: %+v %+v %+v", s1, s2, s3)
+	}
+	if s1.UVMPath() != "/run/c1/rootfs" || s2.UVMPath() != "/run/c2/rootfs" {
+		t.Fatalf("expected the requested paths, got: %s %s", s1.UVMPath(), s2.UVMPath())
+	}
+	if len(cs.Modifications()) != 3 {
+		t.Fatalf("expected 3 modifications, got: %d", len(cs.Modifications()))
+	}
+
+	if err := uvm.RemovePlan9(s1); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if r := uvm.AttachedResources(); r.Plan9 != 2 {
+		t.Fatalf("expected 2 plan9 shares, got: %d", r.Plan9)
+	}
+}
@@ -0,0 +1,24 @@
+package uvm
+
+// AttachedResources is the number of each type of device currently attached to
+// a utility VM.
+type AttachedResources struct {
+	VPMem int
+	SCSI  int
+	VSMB  int
+	Plan9 int
+}
+
+// AttachedResources returns the number of each type of device currently
+// attached to the utility VM.
+func (uvm *UtilityVM) AttachedResources() AttachedResources {
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+
+	var r AttachedResources
+	r.VPMem = uvm.vpmemDevices.Len()
+	r.SCSI = uvm.scsiLocations.Len()
+	r.VSMB = uvm.vsmbShares.Len()
+	r.Plan9 = uvm.plan9Shares.Len()
+	return r
+}
@@ -1,12 +1,15 @@
 package uvm
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/Microsoft/hcsshim/internal/devicealloc"
 	"github.com/Microsoft/hcsshim/internal/guestrequest"
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/requesttype"
 	"github.com/Microsoft/hcsshim/internal/schema2"
+	"github.com/Microsoft/hcsshim/internal/trace"
 	"github.com/Microsoft/hcsshim/internal/wclayer"
 	"github.com/sirupsen/logrus"
 )
@@ -20,75 +23,6 @@ var (
 	ErrSCSILayerWCOWUnsupported = fmt.Errorf("SCSI attached layers are not supported for WCOW")
 )
 
-// allocateSCSI finds the next available slot on the
-// SCSI controllers associated with a utility VM to use.
-// Lock must be held when calling this function
-func (uvm *UtilityVM) allocateSCSI(hostPath string, uvmPath string, isLayer bool) (int, int32, error) {
-	for controller, luns := range uvm.scsiLocations {
-		for lun, si := range luns {
-			if si.hostPath == "" {
-				uvm.scsiLocations[controller][lun].hostPath = hostPath
-				uvm.scsiLocations[controller][lun].uvmPath = uvmPath
-				uvm.scsiLocations[controller][lun].isLayer = isLayer
-				if isLayer {
-					uvm.scsiLocations[controller][lun].refCount = 1
-				}
-				logrus.WithFields(logrus.Fields{
-					logfields.UVMID: uvm.id,
-					"host-path":     hostPath,
-					"uvm-path":      uvmPath,
-					"isLayer":       isLayer,
-					"refCount":      uvm.scsiLocations[controller][lun].refCount,
-					"controller":    controller,
-					"lun":           int32(lun),
-				}).Debug("uvm::allocateSCSI")
-				return controller, int32(lun), nil
-
-			}
-		}
-	}
-	return -1, -1, ErrNoAvailableLocation
-}
-
-func (uvm *UtilityVM) deallocateSCSI(controller int, lun int32) {
-	uvm.m.Lock()
-	defer uvm.m.Unlock()
-	si := uvm.scsiLocations[controller][lun]
-	if si.hostPath != "" {
-		uvm.scsiLocations[controller][lun] = scsiInfo{}
-		logrus.WithFields(logrus.Fields{
-			logfields.UVMID: uvm.id,
-			"host-path":     si.hostPath,
-			"uvm-path":      si.uvmPath,
-			"isLayer":       si.isLayer,
-			"refCount":      si.refCount,
-			"controller":    controller,
-			"lun":           lun,
-		}).Debug("uvm::deallocateSCSI")
-	}
-}
-
-// Lock must be held when calling this function.
-func (uvm *UtilityVM) findSCSIAttachment(findThisHostPath string) (int, int32, string, error) {
-	for controller, luns := range uvm.scsiLocations {
-		for lun, si := range luns {
-			if si.hostPath == findThisHostPath {
-				logrus.WithFields(logrus.Fields{
-					logfields.UVMID: uvm.id,
-					"host-path":     findThisHostPath,
-					"uvm-path":      si.uvmPath,
-					"isLayer":       si.isLayer,
-					"refCount":      si.refCount,
-					"controller":    controller,
-					"lun":           int32(lun),
-				}).Debug("uvm::findSCSIAttachment")
-				return controller, int32(lun), si.uvmPath, nil
-			}
-		}
-	}
-	return -1, -1, "", ErrNotAttached
-}
-
 // AddSCSI adds a SCSI disk to a utility VM at the next available location. This
 // function should be called for a RW/scratch layer or a passthrough vhd/vhdx.
 // For read-only layers on LCOW as an alternate to PMEM for large layers, use
@@ -99,7 +33,7 @@ func (uvm *UtilityVM) findSCSIAttachment(findThisHostPath string) (int, int32, s
 // `uvmPath` is optional.
 //
 // `readOnly` set to `true` if the vhd/vhdx should be attached read only.
-func (uvm *UtilityVM) AddSCSI(hostPath string, uvmPath string, readOnly bool) (_ int, _ int32, err error) {
+func (uvm *UtilityVM) AddSCSI(ctx context.Context, hostPath string, uvmPath string, readOnly bool) (_ int, _ int32, err error) {
 	op := "uvm::AddSCSI"
 	log := logrus.WithFields(logrus.Fields{
 		logfields.UVMID: uvm.id,
@@ -117,6 +51,13 @@ func (uvm *UtilityVM) AddSCSI(hostPath string, uvmPath string, readOnly bool) (_
 		}
 	}()
 
+	_, span := trace.StartSpan(ctx, op,
+		trace.StringAttribute(logfields.UVMID, uvm.id),
+		trace.StringAttribute("host-path", hostPath),
+		trace.StringAttribute("uvm-path", uvmPath),
+		trace.BoolAttribute("readOnly", readOnly))
+	defer func() { span.SetError(err); span.End() }()
+
 	return uvm.addSCSIActual(hostPath, uvmPath, "VirtualDisk", false, readOnly)
 }
 
@@ -128,7 +69,7 @@ func (uvm *UtilityVM) AddSCSI(hostPath string, uvmPath string, readOnly bool) (_
 // `uvmPath` is optional if a guest mount is not requested.
 //
 // `readOnly` set to `true` if the physical disk should be attached read only.
-func (uvm *UtilityVM) AddSCSIPhysicalDisk(hostPath, uvmPath string, readOnly bool) (_ int, _ int32, err error) {
+func (uvm *UtilityVM) AddSCSIPhysicalDisk(ctx context.Context, hostPath, uvmPath string, readOnly bool) (_ int, _ int32, err error) {
 	op := "uvm::AddSCSIPhysicalDisk"
 	log := logrus.WithFields(logrus.Fields{
 		logfields.UVMID: uvm.id,
@@ -146,13 +87,20 @@ func (uvm *UtilityVM) AddSCSIPhysicalDisk(hostPath, uvmPath string, readOnly boo
 		}
 	}()
 
+	_, span := trace.StartSpan(ctx, op,
+		trace.StringAttribute(logfields.UVMID, uvm.id),
+		trace.StringAttribute("host-path", hostPath),
+		trace.StringAttribute("uvm-path", uvmPath),
+		trace.BoolAttribute("readOnly", readOnly))
+	defer func() { span.SetError(err); span.End() }()
+
 	return uvm.addSCSIActual(hostPath, uvmPath, "PassThru", false, readOnly)
 }
 
 // AddSCSILayer adds a read-only layer disk to a utility VM at the next available
 // location. This function is used by LCOW as an alternate to PMEM for large layers.
 // The UVMPath will always be /tmp/S<controller>/<lun>.
-func (uvm *UtilityVM) AddSCSILayer(hostPath string) (_ int, _ int32, err error) {
+func (uvm *UtilityVM) AddSCSILayer(ctx context.Context, hostPath string) (_ int, _ int32, err error) {
 	op := "uvm::AddSCSILayer"
 	log := logrus.WithFields(logrus.Fields{
 		logfields.UVMID: uvm.id,
@@ -168,6 +116,11 @@ func (uvm *UtilityVM) AddSCSILayer(hostPath string) (_ int, _ int32, err error)
 		}
 	}()
 
+	_, span := trace.StartSpan(ctx, op,
+		trace.StringAttribute(logfields.UVMID, uvm.id),
+		trace.StringAttribute("host-path", hostPath))
+	defer func() { span.SetError(err); span.End() }()
+
 	if uvm.operatingSystem == "windows" {
 		return -1, -1, ErrSCSILayerWCOWUnsupported
 	}
@@ -207,47 +160,58 @@ func (uvm *UtilityVM) addSCSIActual(hostPath, uvmPath, attachmentType string, is
 		}
 	}
 
-	// We must hold the lock throughout the lookup (findSCSIAttachment) until
-	// after the possible allocation (allocateSCSI) has been completed to ensure
-	// there isn't a race condition for it being attached by another thread between
-	// these two operations. All failure paths between these two must release
-	// the lock.
-	uvm.m.Lock()
-	if controller, lun, _, err := uvm.findSCSIAttachment(hostPath); err == nil {
-		// So is attached
-		if isLayer {
-			// Increment the refcount
-			uvm.scsiLocations[controller][lun].refCount++
-			uvm.m.Unlock()
-			return controller, lun, nil
+	// The lookup and allocation are a single operation on the allocator so
+	// there is no race with another thread attaching the same disk. The slot
+	// is released on any failure to attach it.
+	r := uvm.scsiLocations.Reserve()
+	defer r.Rollback()
+
+	si := &scsiInfo{
+		UVMPath:        uvmPath,
+		IsLayer:        isLayer,
+		AttachmentType: attachmentType,
+		ReadOnly:       readOnly,
+	}
+	var (
+		e   devicealloc.Entry
+		err error
+	)
+	if isLayer {
+		var added bool
+		e, added, err = r.Acquire(hostPath, si)
+		if err == nil && !added {
+			// Already attached, the refcount was incremented.
+			r.Commit()
+			return e.Controller, int32(e.Slot), nil
 		}
-
-		uvm.m.Unlock()
-		return -1, -1, ErrAlreadyAttached
+	} else {
+		e, err = r.Allocate(hostPath, si)
 	}
-
-	// At this point, we know it's not attached, regardless of whether it's a
-	// ref-counted layer VHD, or not.
-	controller, lun, err := uvm.allocateSCSI(hostPath, uvmPath, isLayer)
-	if err != nil {
-		uvm.m.Unlock()
+	switch err {
+	case nil:
+	case devicealloc.ErrAlreadyAllocated:
+		return -1, -1, ErrAlreadyAttached
+	case devicealloc.ErrNoAvailableSlot:
+		// Every LUN of the utility VM's controllers is in use.
+		return -1, -1, ErrTooManyAttachments
+	default:
 		return -1, -1, err
 	}
+	controller, lun := e.Controller, int32(e.Slot)
+	logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+		"host-path":     hostPath,
+		"uvm-path":      uvmPath,
+		"isLayer":       isLayer,
+		"controller":    controller,
+		"lun":           lun,
+	}).Debug("uvm::allocateSCSI")
 
 	// Auto-generate the UVM path for LCOW layers
 	if isLayer {
 		uvmPath = fmt.Sprintf("/tmp/S%d/%d", controller, lun)
 	}
 
-	// See comment higher up. Now safe to release the lock.
-	uvm.m.Unlock()
-
-	// Note: Can remove this check post-RS5 if multiple controllers are supported
-	if controller > 0 {
-		uvm.deallocateSCSI(controller, lun)
-		return -1, -1, ErrTooManyAttachments
-	}
-
 	SCSIModification := &hcsschema.ModifySettingRequest{
 		RequestType: requesttype.Add,
 		Settings: hcsschema.Attachment{
@@ -283,9 +247,9 @@ func (uvm *UtilityVM) addSCSIActual(hostPath, uvmPath, attachmentType string, is
 	}
 
 	if err := uvm.Modify(SCSIModification); err != nil {
-		uvm.deallocateSCSI(controller, lun)
 		return -1, -1, fmt.Errorf("uvm::AddSCSI: failed to modify utility VM configuration: %s", err)
 	}
+	r.Commit()
 	return controller, lun, nil
 
 }
@@ -315,20 +279,17 @@ func (uvm *UtilityVM) RemoveSCSI(hostPath string) (err error) {
 		return ErrNoSCSIControllers
 	}
 
-	// Make sure is actually attached
-	controller, lun, uvmPath, err := uvm.findSCSIAttachment(hostPath)
+	// Make sure is actually attached. Only ref-counted layers can have more
+	// than one reference.
+	e, last, err := uvm.scsiLocations.Release(hostPath)
 	if err != nil {
-		return err
+		return ErrNotAttached
 	}
-
-	if uvm.scsiLocations[controller][lun].isLayer {
-		uvm.scsiLocations[controller][lun].refCount--
-		if uvm.scsiLocations[controller][lun].refCount > 0 {
-			return nil
-		}
+	if !last {
+		return nil
 	}
 
-	if err := uvm.removeSCSI(hostPath, uvmPath, controller, lun); err != nil {
+	if err := uvm.removeSCSI(hostPath, e.Value.(*scsiInfo).UVMPath, e.Controller, int32(e.Slot)); err != nil {
 		return fmt.Errorf("failed to remove SCSI disk %s from container %s: %s", hostPath, uvm.id, err)
 
 	}
@@ -373,8 +334,8 @@ func (uvm *UtilityVM) removeSCSI(hostPath string, uvmPath string, controller int
 	if err := uvm.Modify(scsiModification); err != nil {
 		return err
 	}
-	uvm.scsiLocations[controller][lun] = scsiInfo{}
-	return nil
+	_, err := uvm.scsiLocations.Free(hostPath)
+	return err
 }
 
 // GetScsiUvmPath returns the guest mounted path of a SCSI drive.
@@ -396,9 +357,9 @@ func (uvm *UtilityVM) GetScsiUvmPath(hostPath string) (_ string, err error) {
 		}
 	}()
 
-	uvm.m.Lock()
-	defer uvm.m.Unlock()
-
-	_, _, uvmPath, err := uvm.findSCSIAttachment(hostPath)
-	return uvmPath, err
+	e, ok := uvm.scsiLocations.Get(hostPath)
+	if !ok {
+		return "", ErrNotAttached
+	}
+	return e.Value.(*scsiInfo).UVMPath, nil
 }
@@ -1,95 +1,48 @@
 package uvm
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"io"
-	"io/ioutil"
 	"net"
 	"syscall"
-	"time"
 
+	"github.com/Microsoft/hcsshim/internal/gcsoutput"
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/sirupsen/logrus"
 )
 
 const _ERROR_CONNECTION_ABORTED syscall.Errno = 1236
 
-type gcsLogEntryStandard struct {
-	Time    time.Time    `json:"time"`
-	Level   logrus.Level `json:"level"`
-	Message string       `json:"msg"`
+// isOutputClosed returns `true` if `err` means that the guest closed the
+// output connection.
+func isOutputClosed(err error) bool {
+	return err == _ERROR_CONNECTION_ABORTED || err == syscall.WSAECONNRESET
 }
 
-type gcsLogEntry struct {
-	gcsLogEntryStandard
-	Fields map[string]interface{}
+// parseLogrus returns the default `OutputHandler`, which logs the JSON logrus
+// entries of the GCS in the utility VM `vmid`.
+func parseLogrus(vmid string) OutputHandler {
+	return OutputHandler(gcsoutput.JSON(gcsoutput.Config{
+		VMID:     vmid,
+		IsClosed: isOutputClosed,
+	}))
 }
 
-// FUTURE-jstarks: Change the GCS log format to include type information
-//                 (e.g. by using a different encoding such as protobuf).
-func (e *gcsLogEntry) UnmarshalJSON(b []byte) error {
-	// Default the log level to info.
-	e.Level = logrus.InfoLevel
-	if err := json.Unmarshal(b, &e.gcsLogEntryStandard); err != nil {
-		return err
-	}
-	if err := json.Unmarshal(b, &e.Fields); err != nil {
-		return err
-	}
-	// Do not allow fatal or panic level errors to propagate.
-	if e.Level < logrus.ErrorLevel {
-		e.Level = logrus.ErrorLevel
-	}
-	// Clear special fields.
-	delete(e.Fields, "time")
-	delete(e.Fields, "level")
-	delete(e.Fields, "msg")
-	// Normalize floats to integers.
-	for k, v := range e.Fields {
-		if d, ok := v.(float64); ok && float64(int64(d)) == d {
-			e.Fields[k] = int64(d)
-		}
-	}
-	return nil
-}
-
-func parseLogrus(vmid string) func(r io.Reader) {
-	return func(r io.Reader) {
-		j := json.NewDecoder(r)
-		e := logrus.NewEntry(logrus.StandardLogger())
-		fields := e.Data
-		for {
-			for k := range fields {
-				delete(fields, k)
-			}
-			gcsEntry := gcsLogEntry{Fields: e.Data}
-			err := j.Decode(&gcsEntry)
-			if err != nil {
-				// Something went wrong. Read the rest of the data as a single
-				// string and log it at once -- it's probably a GCS panic stack.
-				if err != io.EOF && err != _ERROR_CONNECTION_ABORTED && err != syscall.WSAECONNRESET {
-					logrus.WithFields(logrus.Fields{
-						logfields.UVMID: vmid,
-						logrus.ErrorKey: err,
-					}).Error("gcs log read")
-				}
-				rest, _ := ioutil.ReadAll(io.MultiReader(j.Buffered(), r))
-				rest = bytes.TrimSpace(rest)
-				if len(rest) != 0 {
-					logrus.WithFields(logrus.Fields{
-						logfields.UVMID: vmid,
-						"stderr":        string(rest),
-					}).Error("gcs terminated")
-				}
-				break
-			}
-			fields[logfields.UVMID] = vmid
-			fields["vm.time"] = gcsEntry.Time
-			e.Log(gcsEntry.Level, gcsEntry.Message)
-		}
+// NewOutputHandler returns the `OutputHandler` described by `spec` for the
+// utility VM `vmid`, see `gcsoutput.Parse`. `levels` and `dropFields`
+// configure the handlers that log the output. `fileRoot` is the directory the
+// `file:` handlers write in, if empty they are not allowed.
+func NewOutputHandler(vmid, spec string, levels map[logrus.Level]logrus.Level, dropFields []string, fileRoot string) (OutputHandler, error) {
+	h, err := gcsoutput.Parse(spec, gcsoutput.Config{
+		VMID:       vmid,
+		Levels:     levels,
+		DropFields: dropFields,
+		IsClosed:   isOutputClosed,
+		FileRoot:   fileRoot,
+	})
+	if err != nil {
+		return nil, err
 	}
+	return OutputHandler(h), nil
 }
 
 type acceptResult struct {
@@ -145,5 +98,11 @@ func (uvm *UtilityVM) Start() (err error) {
 		uvm.outputProcessingCancel = cancel
 		uvm.outputListener = nil
 	}
+	if uvm.consolePipe != "" {
+		// The capture keeps running if the start fails so that the console
+		// output explaining the failure is collected.
+		started := uvm.startConsoleCapture()
+		defer started()
+	}
 	return uvm.hcsSystem.Start()
 }
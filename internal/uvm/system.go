@@ -1,7 +1,7 @@
 package uvm
 
-import "github.com/Microsoft/hcsshim/internal/hcs"
+import "github.com/Microsoft/hcsshim/internal/cow"
 
-func (uvm *UtilityVM) ComputeSystem() *hcs.System {
+func (uvm *UtilityVM) ComputeSystem() cow.ComputeSystem {
 	return uvm.hcsSystem
 }
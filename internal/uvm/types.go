@@ -4,12 +4,17 @@ package uvm
 
 import (
 	"context"
+	"io"
 	"net"
 	"sync"
 
+	"github.com/Microsoft/hcsshim/internal/cow"
+	"github.com/Microsoft/hcsshim/internal/crashdump"
+	"github.com/Microsoft/hcsshim/internal/devicealloc"
 	"github.com/Microsoft/hcsshim/internal/guid"
-	"github.com/Microsoft/hcsshim/internal/hcs"
 	"github.com/Microsoft/hcsshim/internal/hns"
+	"github.com/Microsoft/hcsshim/internal/regionalloc"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
 )
 
 //                    | WCOW | LCOW
@@ -18,32 +23,40 @@ import (
 // Read-Only Layer    | VSMB | VPMEM
 // Mapped Directory   | VSMB | PLAN9
 
-// vsmbShare is an internal structure used for ref-counting VSMB shares mapped to a Windows utility VM.
+// vsmbShare is the value of a VSMB share mapped to a Windows utility VM in
+// `UtilityVM.vsmbShares`.
 type vsmbShare struct {
-	refCount     uint32
-	name         string
+	Name         string                            `json:"Name"`
+	Options      *hcsschema.VirtualSmbShareOptions `json:"Options,omitempty"`
 	guestRequest interface{}
 }
 
-// scsiInfo is an internal structure used for determining what is mapped to a utility VM.
-// hostPath is required. uvmPath may be blank.
+// scsiInfo is the value of a disk attached to a utility VM in
+// `UtilityVM.scsiLocations`. UVMPath may be blank.
 type scsiInfo struct {
-	hostPath string
-	uvmPath  string
+	UVMPath string `json:"UVMPath,omitempty"`
 
 	// While most VHDs attached to SCSI are scratch spaces, in the case of LCOW
 	// when the size is over the size possible to attach to PMEM, we use SCSI for
 	// read-only layers. As RO layers are shared, we perform ref-counting.
-	isLayer  bool
-	refCount uint32
+	IsLayer bool `json:"IsLayer,omitempty"`
+
+	// AttachmentType and ReadOnly are the settings the disk was attached
+	// with. They are required to describe the attachment when restoring from
+	// a checkpoint.
+	AttachmentType string `json:"AttachmentType,omitempty"`
+	ReadOnly       bool   `json:"ReadOnly,omitempty"`
 }
 
-// vpmemInfo is an internal structure used for determining VPMem devices mapped to
-// a Linux utility VM.
+// vpmemInfo is the value of a VPMem device mapped to a Linux utility VM in
+// `UtilityVM.vpmemDevices`.
 type vpmemInfo struct {
-	hostPath string
-	uvmPath  string
-	refCount uint32
+	UVMPath string `json:"UVMPath,omitempty"`
+
+	// Packed is true for a VPMem device shared by several layers mapped at
+	// offsets of the device. The layers are tracked in
+	// `UtilityVM.vpmemMappings`.
+	Packed bool `json:"Packed,omitempty"`
 }
 
 type nicInfo struct {
@@ -57,39 +70,80 @@ type namespaceInfo struct {
 
 // UtilityVM is the object used by clients representing a utility VM
 type UtilityVM struct {
-	id              string      // Identifier for the utility VM (user supplied or generated)
-	owner           string      // Owner for the utility VM (user supplied or generated)
-	operatingSystem string      // "windows" or "linux"
-	hcsSystem       *hcs.System // The handle to the compute system
+	id              string            // Identifier for the utility VM (user supplied or generated)
+	owner           string            // Owner for the utility VM (user supplied or generated)
+	operatingSystem string            // "windows" or "linux"
+	hcsSystem       cow.ComputeSystem // The handle to the compute system
 	processorCount  int32
+	memorySizeInMB  int32
 	m               sync.Mutex // Lock for adding/removing devices
 
+	// The settings the processors and memory of the utility VM can be updated
+	// within after create, see `UpdateMemory` and `UpdateProcessorLimits`.
+	allowOvercommit   bool
+	memoryMaximumInMB int32
+	processorLimit    int32
+	processorWeight   int32
+
 	// containerCounter is the current number of containers that have been
 	// created. This is never decremented in the life of the UVM.
 	//
 	// NOTE: All accesses to this MUST be done atomically.
 	containerCounter uint64
 
-	// VSMB shares that are mapped into a Windows UVM. These are used for read-only
-	// layers and mapped directories
-	vsmbShares  map[string]*vsmbShare
+	// VSMB shares that are mapped into a Windows UVM keyed by host path with
+	// `*vsmbShare` values. These are used for read-only layers and mapped
+	// directories.
+	vsmbShares  *devicealloc.Allocator
 	vsmbCounter uint64 // Counter to generate a unique share name for each VSMB share.
 
-	// VPMEM devices that are mapped into a Linux UVM. These are used for read-only layers, or for
-	// booting from VHD.
-	vpmemDevices      [MaxVPMEMCount]vpmemInfo // Limited by ACPI size.
-	vpmemMaxCount     uint32                   // Actual number of VPMem devices
-	vpmemMaxSizeBytes uint64                   // Actual size of VPMem devices
-
-	// SCSI devices that are mapped into a Windows or Linux utility VM
-	scsiLocations       [4][64]scsiInfo // Hyper-V supports 4 controllers, 64 slots per controller. Limited to 1 controller for now though.
-	scsiControllerCount uint32          // Number of SCSI controllers in the utility VM
-
-	// Plan9 are directories mapped into a Linux utility VM
+	// VPMEM devices that are mapped into a Linux UVM keyed by host path with
+	// `*vpmemInfo` values. These are used for read-only layers, or for booting
+	// from VHD.
+	vpmemDevices      *devicealloc.Allocator // One controller of vpmemMaxCount devices.
+	vpmemMaxCount     uint32                 // Actual number of VPMem devices
+	vpmemMaxSizeBytes uint64                 // Actual size of VPMem devices
+	vpmemMultiMapping bool                   // Whether read-only layers are packed into shared VPMem devices
+
+	// Read-only layers mapped into shared VPMem devices keyed by host path with
+	// `*vpmemInfo` values. The devices of the pool are the numbers of the
+	// shared devices in `vpmemDevices`.
+	vpmemMappings *regionalloc.Pool
+
+	// SCSI devices that are mapped into a Windows or Linux utility VM keyed by
+	// host path with `*scsiInfo` values.
+	scsiLocations       *devicealloc.Allocator // Hyper-V supports 4 controllers, 64 slots per controller.
+	scsiControllerCount uint32                 // Number of SCSI controllers in the utility VM
+
+	// Plan9 shares of directories that are mapped into a Linux utility VM
+	// keyed by `plan9Key`, or `plan9ExclusiveKey` for exclusive shares, with
+	// `*plan9Info` values.
+	plan9Shares  *devicealloc.Allocator
 	plan9Counter uint64 // Each newly-added plan9 share has a counter used as its ID in the ResourceURI and for the name
 
 	namespaces map[string]*namespaceInfo
 
+	// createDocument is the final HCS document the compute system was created
+	// with. It is the base document used to restore from a checkpoint.
+	createDocument []byte
+
+	// crashDumpPath is the host directory guest crashes are collected in.
+	crashDumpPath string
+	// crashOnce guards the collection of a guest crash by `CollectCrash` and
+	// `Close`. `crashReport` and `crashErr` are its result.
+	crashOnce   sync.Once
+	crashReport *crashdump.Report
+	crashErr    error
+
+	// consolePipe is the named pipe of the serial console of a Linux utility
+	// VM that is captured to `consoleTail` for crash collection and to
+	// `consoleLog` if set. The capture closes `consoleDone` once the console
+	// disconnects.
+	consolePipe string
+	consoleTail *crashdump.TailBuffer
+	consoleLog  io.WriteCloser
+	consoleDone chan struct{}
+
 	outputListener         net.Listener
 	outputProcessingDone   chan struct{}
 	outputHandler          OutputHandler
@@ -0,0 +1,177 @@
+package uvm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/requesttype"
+	hcsschema "github.com/Microsoft/hcsshim/internal/schema2"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	memorySizeResourcePath      = "VirtualMachine/ComputeTopology/Memory/SizeInMB"
+	processorLimitsResourcePath = "VirtualMachine/ComputeTopology/Processor/Limits"
+
+	// maxProcessorLimit is the processor limit of 100% of every vCPU.
+	maxProcessorLimit = 10000
+	// maxProcessorWeight is the highest relative processor weight.
+	maxProcessorWeight = 10000
+)
+
+// MemoryMaximumInMB returns the size that the memory of the UVM can be grown
+// to with `UpdateMemory`. If `0` the memory cannot be grown.
+func (uvm *UtilityVM) MemoryMaximumInMB() int32 {
+	return uvm.memoryMaximumInMB
+}
+
+// UpdateMemory grows the memory of the running UVM to `sizeInMB`.
+//
+// Memory can only be hot added, so `sizeInMB` MUST NOT be less than the
+// current size. The UVM MUST have been created with virtual memory backing
+// (`Options.AllowOvercommit`) and `sizeInMB` MUST NOT exceed
+// `Options.MemoryMaximumInMB`.
+func (uvm *UtilityVM) UpdateMemory(sizeInMB int32) (err error) {
+	op := "uvm::UpdateMemory"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+		"size-mb":       sizeInMB,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+
+	if sizeInMB == uvm.memorySizeInMB {
+		return nil
+	}
+	if err := uvm.validateMemoryL(sizeInMB); err != nil {
+		return err
+	}
+	if err := uvm.Modify(memoryUpdateRequest(sizeInMB)); err != nil {
+		return err
+	}
+	uvm.memorySizeInMB = sizeInMB
+	return nil
+}
+
+// validateMemoryL returns an error if the memory of the UVM cannot be grown to
+// `sizeInMB`. The mutex MUST be held.
+func (uvm *UtilityVM) validateMemoryL(sizeInMB int32) error {
+	if !uvm.allowOvercommit {
+		return fmt.Errorf("memory of utility VM %s is physically backed and cannot be hot added", uvm.id)
+	}
+	if sizeInMB < uvm.memorySizeInMB {
+		return fmt.Errorf("memory of utility VM %s cannot be shrunk from %d MB to %d MB", uvm.id, uvm.memorySizeInMB, sizeInMB)
+	}
+	if sizeInMB > uvm.memoryMaximumInMB {
+		return fmt.Errorf("memory of utility VM %s cannot be grown to %d MB over its maximum of %d MB", uvm.id, sizeInMB, uvm.memoryMaximumInMB)
+	}
+	return nil
+}
+
+func memoryUpdateRequest(sizeInMB int32) *hcsschema.ModifySettingRequest {
+	return &hcsschema.ModifySettingRequest{
+		RequestType:  requesttype.Update,
+		Settings:     sizeInMB,
+		ResourcePath: memorySizeResourcePath,
+	}
+}
+
+// ErrProcessorHotAddNotSupported is returned by `UpdateProcessors`. Hyper-V
+// does not support adding vCPUs to a running utility VM. The processor share of
+// a running UVM is changed with `UpdateProcessorLimits` instead.
+var ErrProcessorHotAddNotSupported = errors.New("vCPUs cannot be hot added to a running utility VM")
+
+// UpdateProcessors changes the number of processors of the running UVM to
+// `count`. The vCPUs of a running UVM cannot be changed so this always fails
+// with `ErrProcessorHotAddNotSupported` unless `count` is the current count.
+func (uvm *UtilityVM) UpdateProcessors(count int32) (err error) {
+	op := "uvm::UpdateProcessors"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+		"count":         count,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+
+	if count == uvm.processorCount {
+		return nil
+	}
+	return ErrProcessorHotAddNotSupported
+}
+
+// UpdateProcessorLimits updates the limit and relative weight of the
+// processors of the running UVM. `limit` allows values 1 - 10,000 where 10,000
+// means 100% of every processor. `weight` allows values 0 - 10,000. A value of
+// `0` keeps the current setting.
+func (uvm *UtilityVM) UpdateProcessorLimits(limit, weight int32) (err error) {
+	op := "uvm::UpdateProcessorLimits"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+		"limit":         limit,
+		"weight":        weight,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	if limit < 0 || limit > maxProcessorLimit {
+		return fmt.Errorf("processor limit %d of utility VM %s is not within 1 - %d", limit, uvm.id, maxProcessorLimit)
+	}
+	if weight < 0 || weight > maxProcessorWeight {
+		return fmt.Errorf("processor weight %d of utility VM %s is not within 0 - %d", weight, uvm.id, maxProcessorWeight)
+	}
+
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+
+	if limit == 0 {
+		limit = uvm.processorLimit
+	}
+	if weight == 0 {
+		weight = uvm.processorWeight
+	}
+	if err := uvm.Modify(processorLimitsUpdateRequest(limit, weight)); err != nil {
+		return err
+	}
+	uvm.processorLimit = limit
+	uvm.processorWeight = weight
+	return nil
+}
+
+func processorLimitsUpdateRequest(limit, weight int32) *hcsschema.ModifySettingRequest {
+	return &hcsschema.ModifySettingRequest{
+		RequestType: requesttype.Update,
+		Settings: hcsschema.ProcessorLimits{
+			Limit:  uint64(limit),
+			Weight: uint64(weight),
+		},
+		ResourcePath: processorLimitsResourcePath,
+	}
+}
@@ -0,0 +1,162 @@
+package uvm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Microsoft/hcsshim/internal/cow/fake"
+)
+
+// newUpdateTestUVM returns a running utility VM of 1024 MB and 2 processors
+// that can be grown to 4096 MB and 4 processors.
+func newUpdateTestUVM(t *testing.T) (*UtilityVM, *fake.ComputeSystem) {
+	cs, err := (&fake.Backend{}).CreateComputeSystem(context.TODO(), t.Name(), nil)
+	if err != nil {
+		t.Fatalf("failed to create compute system: %v", err)
+	}
+	if err := cs.Start(); err != nil {
+		t.Fatalf("failed to start compute system: %v", err)
+	}
+	uvm := &UtilityVM{
+		id:                t.Name(),
+		operatingSystem:   "linux",
+		hcsSystem:         cs,
+		processorCount:    2,
+		memorySizeInMB:    1024,
+		allowOvercommit:   true,
+		memoryMaximumInMB: 4096,
+		processorLimit:    5000,
+		processorWeight:   100,
+	}
+	return uvm, cs.(*fake.ComputeSystem)
+}
+
+// verifyModifications verifies that the JSON of the requests sent to `cs` are
+// `expected`.
+func verifyModifications(t *testing.T, cs *fake.ComputeSystem, expected ...string) {
+	mods := cs.Modifications()
+	if len(mods) != len(expected) {
+		t.Fatalf("expected %d modifications, got: %d", len(expected), len(mods))
+	}
+	for i, m := range mods {
+		b, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("failed to marshal modification: %v", err)
+		}
+		if string(b) != expected[i] {
+			t.Fatalf("expected modification:\n%s\ngot:\n%s", expected[i], b)
+		}
+	}
+}
+
+func Test_UpdateMemory(t *testing.T) {
+	uvm, cs := newUpdateTestUVM(t)
+
+	if err := uvm.UpdateMemory(2048); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	verifyModifications(t, cs,
+		`{"ResourcePath":"VirtualMachine/ComputeTopology/Memory/SizeInMB","RequestType":"Update","Settings":2048}`)
+	if uvm.MemorySizeInMB() != 2048 {
+		t.Fatalf("expected memory size: 2048, got: %d", uvm.MemorySizeInMB())
+	}
+}
+
+func Test_UpdateMemory_Unchanged_NoRequest(t *testing.T) {
+	uvm, cs := newUpdateTestUVM(t)
+
+	if err := uvm.UpdateMemory(1024); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	verifyModifications(t, cs)
+}
+
+func Test_UpdateMemory_Invalid_Error(t *testing.T) {
+	tests := map[string]struct {
+		sizeInMB int32
+		modify   func(uvm *UtilityVM)
+	}{
+		"shrink":            {sizeInMB: 512},
+		"over maximum":      {sizeInMB: 8192},
+		"no maximum":        {sizeInMB: 2048, modify: func(uvm *UtilityVM) { uvm.memoryMaximumInMB = 0 }},
+		"physically backed": {sizeInMB: 2048, modify: func(uvm *UtilityVM) { uvm.allowOvercommit = false }},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			uvm, cs := newUpdateTestUVM(t)
+			if test.modify != nil {
+				test.modify(uvm)
+			}
+			if err := uvm.UpdateMemory(test.sizeInMB); err == nil {
+				t.Fatal("expected an error")
+			}
+			verifyModifications(t, cs)
+			if uvm.MemorySizeInMB() != 1024 {
+				t.Fatalf("expected memory size: 1024, got: %d", uvm.MemorySizeInMB())
+			}
+		})
+	}
+}
+
+func Test_UpdateMemory_ModifyFailure_Unchanged(t *testing.T) {
+	uvm, cs := newUpdateTestUVM(t)
+	expected := errors.New("modify failed")
+	cs.SetError("Modify", expected)
+
+	if err := uvm.UpdateMemory(2048); err != expected {
+		t.Fatalf("expected %v, got: %v", expected, err)
+	}
+	if uvm.MemorySizeInMB() != 1024 {
+		t.Fatalf("expected memory size: 1024, got: %d", uvm.MemorySizeInMB())
+	}
+}
+
+func Test_UpdateProcessors_Unchanged(t *testing.T) {
+	uvm, cs := newUpdateTestUVM(t)
+
+	if err := uvm.UpdateProcessors(2); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	verifyModifications(t, cs)
+}
+
+func Test_UpdateProcessors_NotSupported_Error(t *testing.T) {
+	for _, count := range []int32{1, 3} {
+		uvm, cs := newUpdateTestUVM(t)
+		if err := uvm.UpdateProcessors(count); err != ErrProcessorHotAddNotSupported {
+			t.Fatalf("expected %v for %d processors, got: %v", ErrProcessorHotAddNotSupported, count, err)
+		}
+		verifyModifications(t, cs)
+		if uvm.ProcessorCount() != 2 {
+			t.Fatalf("expected processor count: 2, got: %d", uvm.ProcessorCount())
+		}
+	}
+}
+
+func Test_UpdateProcessorLimits(t *testing.T) {
+	uvm, cs := newUpdateTestUVM(t)
+
+	if err := uvm.UpdateProcessorLimits(10000, 0); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	if err := uvm.UpdateProcessorLimits(0, 200); err != nil {
+		t.Fatalf("should not have failed, got: %v", err)
+	}
+	verifyModifications(t, cs,
+		`{"ResourcePath":"VirtualMachine/ComputeTopology/Processor/Limits","RequestType":"Update","Settings":{"Limit":10000,"Weight":100}}`,
+		`{"ResourcePath":"VirtualMachine/ComputeTopology/Processor/Limits","RequestType":"Update","Settings":{"Limit":10000,"Weight":200}}`)
+}
+
+func Test_UpdateProcessorLimits_Invalid_Error(t *testing.T) {
+	uvm, cs := newUpdateTestUVM(t)
+
+	if err := uvm.UpdateProcessorLimits(10001, 0); err == nil {
+		t.Fatal("expected an error for the limit")
+	}
+	if err := uvm.UpdateProcessorLimits(0, -1); err == nil {
+		t.Fatal("expected an error for the weight")
+	}
+	verifyModifications(t, cs)
+}
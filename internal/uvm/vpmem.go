@@ -1,74 +1,23 @@
 package uvm
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/Microsoft/hcsshim/internal/devicealloc"
 	"github.com/Microsoft/hcsshim/internal/guestrequest"
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/requesttype"
 	"github.com/Microsoft/hcsshim/internal/schema2"
+	"github.com/Microsoft/hcsshim/internal/trace"
 	"github.com/sirupsen/logrus"
 )
 
-// allocateVPMEM finds the next available VPMem slot. The lock MUST be held
-// when calling this function.
-func (uvm *UtilityVM) allocateVPMEM(hostPath string) (uint32, error) {
-	for index, vi := range uvm.vpmemDevices {
-		if vi.hostPath == "" {
-			vi.hostPath = hostPath
-			logrus.WithFields(logrus.Fields{
-				logfields.UVMID: uvm.id,
-				"host-path":     vi.hostPath,
-				"uvm-path":      vi.uvmPath,
-				"refCount":      vi.refCount,
-				"deviceNumber":  uint32(index),
-			}).Debug("uvm::allocateVPMEM")
-			return uint32(index), nil
-		}
-	}
-	return 0, fmt.Errorf("no free VPMEM locations")
-}
-
-func (uvm *UtilityVM) deallocateVPMEM(deviceNumber uint32) error {
-	uvm.m.Lock()
-	defer uvm.m.Unlock()
-	vi := uvm.vpmemDevices[deviceNumber]
-	if vi.hostPath != "" {
-		logrus.WithFields(logrus.Fields{
-			logfields.UVMID: uvm.id,
-			"host-path":     vi.hostPath,
-			"uvm-path":      vi.uvmPath,
-			"refCount":      vi.refCount,
-			"deviceNumber":  deviceNumber,
-		}).Debug("uvm::deallocateVPMEM")
-		uvm.vpmemDevices[deviceNumber] = vpmemInfo{}
-	}
-
-	return nil
-}
-
-// Lock must be held when calling this function
-func (uvm *UtilityVM) findVPMEMDevice(findThisHostPath string) (uint32, string, error) {
-	for deviceNumber, vi := range uvm.vpmemDevices {
-		if vi.hostPath == findThisHostPath {
-			logrus.WithFields(logrus.Fields{
-				logfields.UVMID: uvm.id,
-				"host-path":     findThisHostPath,
-				"uvm-path":      vi.uvmPath,
-				"refCount":      vi.refCount,
-				"deviceNumber":  uint32(deviceNumber),
-			}).Debug("uvm::findVPMEMDevice")
-			return uint32(deviceNumber), vi.uvmPath, nil
-		}
-	}
-	return 0, "", fmt.Errorf("%s is not attached to VPMEM", findThisHostPath)
-}
-
 // AddVPMEM adds a VPMEM disk to a utility VM at the next available location.
 //
 // Returns the location(0..MaxVPMEM-1) where the device is attached, and if exposed,
 // the utility VM path which will be /tmp/p<location>//
-func (uvm *UtilityVM) AddVPMEM(hostPath string, expose bool) (_ uint32, _ string, err error) {
+func (uvm *UtilityVM) AddVPMEM(ctx context.Context, hostPath string, expose bool) (_ uint32, _ string, err error) {
 	op := "uvm::AddVPMEM"
 	log := logrus.WithFields(logrus.Fields{
 		logfields.UVMID: uvm.id,
@@ -85,6 +34,12 @@ func (uvm *UtilityVM) AddVPMEM(hostPath string, expose bool) (_ uint32, _ string
 		}
 	}()
 
+	_, span := trace.StartSpan(ctx, op,
+		trace.StringAttribute(logfields.UVMID, uvm.id),
+		trace.StringAttribute("host-path", hostPath),
+		trace.BoolAttribute("expose", expose))
+	defer func() { span.SetError(err); span.End() }()
+
 	if uvm.operatingSystem != "linux" {
 		return 0, "", errNotSupported
 	}
@@ -92,58 +47,55 @@ func (uvm *UtilityVM) AddVPMEM(hostPath string, expose bool) (_ uint32, _ string
 	uvm.m.Lock()
 	defer uvm.m.Unlock()
 
-	var deviceNumber uint32
-	uvmPath := ""
-
-	deviceNumber, uvmPath, err = uvm.findVPMEMDevice(hostPath)
+	r := uvm.vpmemDevices.Reserve()
+	defer r.Rollback()
+	e, added, err := r.Acquire(hostPath, &vpmemInfo{})
 	if err != nil {
-		// It doesn't exist, so we're going to allocate and hot-add it
-		deviceNumber, err = uvm.allocateVPMEM(hostPath)
-		if err != nil {
-			return 0, "", err
+		if err == devicealloc.ErrNoAvailableSlot {
+			return 0, "", fmt.Errorf("no free VPMEM locations")
 		}
+		return 0, "", err
+	}
+	deviceNumber := uint32(e.Slot)
+	if !added {
+		r.Commit()
+		logrus.Debugf("hcsshim::AddVPMEM id:%s Success %+v", uvm.id, e)
+		return deviceNumber, e.Value.(*vpmemInfo).UVMPath, nil
+	}
 
-		modification := &hcsschema.ModifySettingRequest{
-			RequestType: requesttype.Add,
-			Settings: hcsschema.VirtualPMemDevice{
-				HostPath:    hostPath,
-				ReadOnly:    true,
-				ImageFormat: "Vhd1",
-			},
-			ResourcePath: fmt.Sprintf("VirtualMachine/Devices/VirtualPMem/Devices/%d", deviceNumber),
-		}
+	// It didn't exist, so we're going to hot-add it
+	uvmPath := ""
+	modification := &hcsschema.ModifySettingRequest{
+		RequestType: requesttype.Add,
+		Settings: hcsschema.VirtualPMemDevice{
+			HostPath:    hostPath,
+			ReadOnly:    true,
+			ImageFormat: "Vhd1",
+		},
+		ResourcePath: fmt.Sprintf("VirtualMachine/Devices/VirtualPMem/Devices/%d", deviceNumber),
+	}
 
-		if expose {
-			uvmPath = fmt.Sprintf("/tmp/p%d", deviceNumber)
-			modification.GuestRequest = guestrequest.GuestRequest{
-				ResourceType: guestrequest.ResourceTypeVPMemDevice,
-				RequestType:  requesttype.Add,
-				Settings: guestrequest.LCOWMappedVPMemDevice{
-					DeviceNumber: deviceNumber,
-					MountPath:    uvmPath,
-				},
-			}
+	if expose {
+		uvmPath = fmt.Sprintf("/tmp/p%d", deviceNumber)
+		if err := uvm.vpmemDevices.SetValue(hostPath, &vpmemInfo{UVMPath: uvmPath}); err != nil {
+			return 0, "", err
 		}
-
-		if err := uvm.Modify(modification); err != nil {
-			uvm.vpmemDevices[deviceNumber] = vpmemInfo{}
-			return 0, "", fmt.Errorf("uvm::AddVPMEM: failed to modify utility VM configuration: %s", err)
+		modification.GuestRequest = guestrequest.GuestRequest{
+			ResourceType: guestrequest.ResourceTypeVPMemDevice,
+			RequestType:  requesttype.Add,
+			Settings: guestrequest.LCOWMappedVPMemDevice{
+				DeviceNumber: deviceNumber,
+				MountPath:    uvmPath,
+			},
 		}
+	}
 
-		uvm.vpmemDevices[deviceNumber] = vpmemInfo{
-			hostPath: hostPath,
-			refCount: 1,
-			uvmPath:  uvmPath}
-	} else {
-		pmemi := vpmemInfo{
-			hostPath: hostPath,
-			refCount: uvm.vpmemDevices[deviceNumber].refCount + 1,
-			uvmPath:  uvmPath}
-		uvm.vpmemDevices[deviceNumber] = pmemi
+	if err := uvm.Modify(modification); err != nil {
+		return 0, "", fmt.Errorf("uvm::AddVPMEM: failed to modify utility VM configuration: %s", err)
 	}
-	logrus.Debugf("hcsshim::AddVPMEM id:%s Success %+v", uvm.id, uvm.vpmemDevices[deviceNumber])
+	r.Commit()
+	logrus.Debugf("hcsshim::AddVPMEM id:%s Success %+v", uvm.id, e)
 	return deviceNumber, uvmPath, nil
-
 }
 
 // RemoveVPMEM removes a VPMEM disk from a utility VM. As an external API, it
@@ -172,12 +124,11 @@ func (uvm *UtilityVM) RemoveVPMEM(hostPath string) (err error) {
 	defer uvm.m.Unlock()
 
 	// Make sure is actually attached
-	deviceNumber, uvmPath, err := uvm.findVPMEMDevice(hostPath)
-	if err != nil {
-		return fmt.Errorf("cannot remove VPMEM %s as it is not attached to utility VM %s: %s", hostPath, uvm.id, err)
+	if _, ok := uvm.vpmemDevices.Get(hostPath); !ok {
+		return fmt.Errorf("cannot remove VPMEM %s as it is not attached to utility VM %s", hostPath, uvm.id)
 	}
 
-	if err := uvm.removeVPMEM(hostPath, uvmPath, deviceNumber); err != nil {
+	if err := uvm.removeVPMEM(hostPath); err != nil {
 		return fmt.Errorf("failed to remove VPMEM %s from utility VM %s: %s", hostPath, uvm.id, err)
 	}
 	return nil
@@ -185,30 +136,30 @@ func (uvm *UtilityVM) RemoveVPMEM(hostPath string) (err error) {
 
 // removeVPMEM is the internally callable "unsafe" version of RemoveVPMEM. The mutex
 // MUST be held when calling this function.
-func (uvm *UtilityVM) removeVPMEM(hostPath string, uvmPath string, deviceNumber uint32) error {
-	if uvm.vpmemDevices[deviceNumber].refCount == 1 {
-		modification := &hcsschema.ModifySettingRequest{
+func (uvm *UtilityVM) removeVPMEM(hostPath string) error {
+	e, last, err := uvm.vpmemDevices.Release(hostPath)
+	if err != nil || !last {
+		return err
+	}
+	deviceNumber := uint32(e.Slot)
+	modification := &hcsschema.ModifySettingRequest{
+		RequestType:  requesttype.Remove,
+		ResourcePath: fmt.Sprintf("VirtualMachine/Devices/VirtualPMem/Devices/%d", deviceNumber),
+		GuestRequest: guestrequest.GuestRequest{
+			ResourceType: guestrequest.ResourceTypeVPMemDevice,
 			RequestType:  requesttype.Remove,
-			ResourcePath: fmt.Sprintf("VirtualMachine/Devices/VirtualPMem/Devices/%d", deviceNumber),
-			GuestRequest: guestrequest.GuestRequest{
-				ResourceType: guestrequest.ResourceTypeVPMemDevice,
-				RequestType:  requesttype.Remove,
-				Settings: guestrequest.LCOWMappedVPMemDevice{
-					DeviceNumber: deviceNumber,
-					MountPath:    uvmPath,
-				},
+			Settings: guestrequest.LCOWMappedVPMemDevice{
+				DeviceNumber: deviceNumber,
+				MountPath:    e.Value.(*vpmemInfo).UVMPath,
 			},
-		}
-
-		if err := uvm.Modify(modification); err != nil {
-			return err
-		}
-		uvm.vpmemDevices[deviceNumber] = vpmemInfo{}
-		return nil
+		},
 	}
-	uvm.vpmemDevices[deviceNumber].refCount--
-	return nil
 
+	if err := uvm.Modify(modification); err != nil {
+		return err
+	}
+	_, err = uvm.vpmemDevices.Free(hostPath)
+	return err
 }
 
 // PMemMaxSizeBytes returns the maximum size of a PMEM layer (LCOW)
@@ -0,0 +1,245 @@
+package uvm
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/Microsoft/hcsshim/internal/devicealloc"
+	"github.com/Microsoft/hcsshim/internal/guestrequest"
+	"github.com/Microsoft/hcsshim/internal/guid"
+	"github.com/Microsoft/hcsshim/internal/logfields"
+	"github.com/Microsoft/hcsshim/internal/regionalloc"
+	"github.com/Microsoft/hcsshim/internal/requesttype"
+	"github.com/Microsoft/hcsshim/internal/schema2"
+	"github.com/Microsoft/hcsshim/internal/trace"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// vpmemMappingAlignment is the alignment of the offset and size of a layer
+	// mapped into a shared VPMem device.
+	vpmemMappingAlignment = 4096
+	// vpmemPackedKeyPrefix prefixes the `vpmemDevices` key of a shared VPMem
+	// device. The keys of all other devices are host paths.
+	vpmemPackedKeyPrefix = "packed-"
+)
+
+// vpmemMappingResourcePath returns the HCS resource path of the layer mapped
+// at `m`.
+func vpmemMappingResourcePath(m regionalloc.Mapping) string {
+	return fmt.Sprintf("VirtualMachine/Devices/VirtualPMem/Devices/%d/Mappings/%d", m.Device, m.Offset)
+}
+
+// AddVPMEMLayer adds the read-only layer `hostPath` to a Linux utility VM and
+// returns the path it is mounted at in the utility VM.
+//
+// If the utility VM supports multi-mapping the layer is mapped at an offset of
+// a VPMem device shared with other layers and mounted at
+// /tmp/p<device>-<offset>. A shared device is added when no existing one has
+// room for the layer. Otherwise the layer is given its own device with
+// `AddVPMEM`.
+func (uvm *UtilityVM) AddVPMEMLayer(ctx context.Context, hostPath string) (_ string, err error) {
+	op := "uvm::AddVPMEMLayer"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+		"host-path":     hostPath,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	ctx, span := trace.StartSpan(ctx, op,
+		trace.StringAttribute(logfields.UVMID, uvm.id),
+		trace.StringAttribute("host-path", hostPath))
+	defer func() { span.SetError(err); span.End() }()
+
+	if uvm.operatingSystem != "linux" {
+		return "", errNotSupported
+	}
+	if !uvm.vpmemMultiMapping {
+		_, uvmPath, err := uvm.AddVPMEM(ctx, hostPath, true)
+		return uvmPath, err
+	}
+
+	fi, err := os.Stat(hostPath)
+	if err != nil {
+		return "", err
+	}
+	size := uint64(fi.Size())
+	if size > uvm.vpmemMappings.DeviceSize() {
+		return "", fmt.Errorf("layer '%s' of %d bytes does not fit in a VPMem device of %d bytes", hostPath, size, uvm.vpmemMappings.DeviceSize())
+	}
+
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+
+	m, added, err := uvm.vpmemMappings.Acquire(hostPath, size, &vpmemInfo{})
+	if err == regionalloc.ErrNoSpace {
+		if err := uvm.addPackedVPMEM(); err != nil {
+			return "", err
+		}
+		m, added, err = uvm.vpmemMappings.Acquire(hostPath, size, &vpmemInfo{})
+	}
+	if err != nil {
+		return "", err
+	}
+	vi := m.Value.(*vpmemInfo)
+	if !added {
+		return vi.UVMPath, nil
+	}
+
+	vi.UVMPath = fmt.Sprintf("/tmp/p%d-%d", m.Device, m.Offset)
+	modification := &hcsschema.ModifySettingRequest{
+		RequestType: requesttype.Add,
+		Settings: hcsschema.VirtualPMemMapping{
+			HostPath:    hostPath,
+			ImageFormat: "Vhd1",
+		},
+		ResourcePath: vpmemMappingResourcePath(m),
+		GuestRequest: guestrequest.GuestRequest{
+			ResourceType: guestrequest.ResourceTypeVPMemDevice,
+			RequestType:  requesttype.Add,
+			Settings: guestrequest.LCOWMappedVPMemDevice{
+				DeviceNumber: m.Device,
+				MountPath:    vi.UVMPath,
+				MappingInfo: &guestrequest.LCOWVPMemMappingInfo{
+					DeviceOffsetInBytes: m.Offset,
+					DeviceSizeInBytes:   m.Size,
+				},
+			},
+		},
+	}
+	if err := uvm.Modify(modification); err != nil {
+		if _, err := uvm.vpmemMappings.Unmap(hostPath); err != nil {
+			log.WithError(err).Warning("failed to release VPMem mapping")
+		} else if err := uvm.removePackedVPMEM(m.Device); err != nil {
+			log.WithError(err).Warning("failed to remove empty VPMem device")
+		}
+		return "", fmt.Errorf("uvm::AddVPMEMLayer: failed to modify utility VM configuration: %s", err)
+	}
+	return vi.UVMPath, nil
+}
+
+// RemoveVPMEMLayer removes a read-only layer added with `AddVPMEMLayer`. A
+// shared VPMem device is removed once its last layer is removed.
+func (uvm *UtilityVM) RemoveVPMEMLayer(hostPath string) (err error) {
+	op := "uvm::RemoveVPMEMLayer"
+	log := logrus.WithFields(logrus.Fields{
+		logfields.UVMID: uvm.id,
+		"host-path":     hostPath,
+	})
+	log.Debug(op + " - Begin Operation")
+	defer func() {
+		if err != nil {
+			log.Data[logrus.ErrorKey] = err
+			log.Error(op + " - End Operation - Error")
+		} else {
+			log.Debug(op + " - End Operation - Success")
+		}
+	}()
+
+	if uvm.operatingSystem != "linux" {
+		return errNotSupported
+	}
+
+	uvm.m.Lock()
+	defer uvm.m.Unlock()
+
+	if _, ok := uvm.vpmemMappings.Get(hostPath); !ok {
+		// The layer has its own device.
+		if _, ok := uvm.vpmemDevices.Get(hostPath); !ok {
+			return fmt.Errorf("cannot remove VPMEM layer %s as it is not attached to utility VM %s", hostPath, uvm.id)
+		}
+		if err := uvm.removeVPMEM(hostPath); err != nil {
+			return fmt.Errorf("failed to remove VPMEM %s from utility VM %s: %s", hostPath, uvm.id, err)
+		}
+		return nil
+	}
+
+	m, last, err := uvm.vpmemMappings.Release(hostPath)
+	if err != nil || !last {
+		return err
+	}
+	modification := &hcsschema.ModifySettingRequest{
+		RequestType:  requesttype.Remove,
+		ResourcePath: vpmemMappingResourcePath(m),
+		GuestRequest: guestrequest.GuestRequest{
+			ResourceType: guestrequest.ResourceTypeVPMemDevice,
+			RequestType:  requesttype.Remove,
+			Settings: guestrequest.LCOWMappedVPMemDevice{
+				DeviceNumber: m.Device,
+				MountPath:    m.Value.(*vpmemInfo).UVMPath,
+				MappingInfo: &guestrequest.LCOWVPMemMappingInfo{
+					DeviceOffsetInBytes: m.Offset,
+					DeviceSizeInBytes:   m.Size,
+				},
+			},
+		},
+	}
+	if err := uvm.Modify(modification); err != nil {
+		return fmt.Errorf("failed to remove VPMEM layer %s from utility VM %s: %s", hostPath, uvm.id, err)
+	}
+	if _, err := uvm.vpmemMappings.Unmap(hostPath); err != nil {
+		return err
+	}
+	return uvm.removePackedVPMEM(m.Device)
+}
+
+// addPackedVPMEM hot-adds an empty VPMem device that layers can be mapped
+// into. The mutex MUST be held when calling this function.
+func (uvm *UtilityVM) addPackedVPMEM() error {
+	r := uvm.vpmemDevices.Reserve()
+	defer r.Rollback()
+	e, err := r.Allocate(vpmemPackedKeyPrefix+guid.New().String(), &vpmemInfo{Packed: true})
+	if err != nil {
+		if err == devicealloc.ErrNoAvailableSlot {
+			return fmt.Errorf("no free VPMEM locations")
+		}
+		return err
+	}
+	modification := &hcsschema.ModifySettingRequest{
+		RequestType: requesttype.Add,
+		Settings: hcsschema.VirtualPMemDevice{
+			ReadOnly:    true,
+			ImageFormat: "Vhd1",
+			SizeBytes:   uvm.vpmemMaxSizeBytes,
+		},
+		ResourcePath: fmt.Sprintf("VirtualMachine/Devices/VirtualPMem/Devices/%d", e.Slot),
+	}
+	if err := uvm.Modify(modification); err != nil {
+		return fmt.Errorf("failed to add shared VPMEM device to utility VM %s: %s", uvm.id, err)
+	}
+	r.Commit()
+	uvm.vpmemMappings.AddDevice(uint32(e.Slot))
+	return nil
+}
+
+// removePackedVPMEM removes the shared VPMem device `deviceNumber` if no layer
+// is mapped into it. The mutex MUST be held when calling this function.
+func (uvm *UtilityVM) removePackedVPMEM(deviceNumber uint32) error {
+	if !uvm.vpmemMappings.Empty(deviceNumber) {
+		return nil
+	}
+	e, ok := uvm.vpmemDevices.At(devicealloc.Location{Slot: int(deviceNumber)})
+	if !ok || !e.Value.(*vpmemInfo).Packed {
+		return fmt.Errorf("VPMEM device %d of utility VM %s is not a shared device", deviceNumber, uvm.id)
+	}
+	modification := &hcsschema.ModifySettingRequest{
+		RequestType:  requesttype.Remove,
+		ResourcePath: fmt.Sprintf("VirtualMachine/Devices/VirtualPMem/Devices/%d", deviceNumber),
+	}
+	if err := uvm.Modify(modification); err != nil {
+		return fmt.Errorf("failed to remove shared VPMEM device %d from utility VM %s: %s", deviceNumber, uvm.id, err)
+	}
+	if _, err := uvm.vpmemDevices.Free(e.Key); err != nil {
+		return err
+	}
+	return uvm.vpmemMappings.RemoveDevice(deviceNumber)
+}
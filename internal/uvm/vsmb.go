@@ -1,38 +1,26 @@
 package uvm
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/Microsoft/hcsshim/internal/requesttype"
 	"github.com/Microsoft/hcsshim/internal/schema2"
+	"github.com/Microsoft/hcsshim/internal/trace"
 	"github.com/sirupsen/logrus"
 )
 
-// findVSMBShare finds a share by `hostPath`. If not found returns `ErrNotAttached`.
-func (uvm *UtilityVM) findVSMBShare(hostPath string) (*vsmbShare, error) {
-	share, ok := uvm.vsmbShares[hostPath]
-	if !ok {
-		return nil, ErrNotAttached
-	}
-	logrus.WithFields(logrus.Fields{
-		logfields.UVMID: uvm.id,
-		"host-path":     hostPath,
-		"name":          share.name,
-		"refCount":      share.refCount,
-	}).Debug("uvm::findVSMBShare")
-	return share, nil
-}
-
+// GuestPath returns the guest path of the share.
 func (share *vsmbShare) GuestPath() string {
-	return `\\?\VMSMB\VSMB-{dcc079ae-60ba-4d07-847c-3493609c0870}\` + share.name
+	return `\\?\VMSMB\VSMB-{dcc079ae-60ba-4d07-847c-3493609c0870}\` + share.Name
 }
 
 // AddVSMB adds a VSMB share to a Windows utility VM. Each VSMB share is ref-counted and
 // only added if it isn't already. This is used for read-only layers, mapped directories
 // to a container, and for mapped pipes.
-func (uvm *UtilityVM) AddVSMB(hostPath string, guestRequest interface{}, options *hcsschema.VirtualSmbShareOptions) (err error) {
+func (uvm *UtilityVM) AddVSMB(ctx context.Context, hostPath string, guestRequest interface{}, options *hcsschema.VirtualSmbShareOptions) (err error) {
 	op := "uvm::AddVSMB"
 	log := logrus.WithFields(logrus.Fields{
 		logfields.UVMID: uvm.id,
@@ -48,21 +36,34 @@ func (uvm *UtilityVM) AddVSMB(hostPath string, guestRequest interface{}, options
 		}
 	}()
 
+	_, span := trace.StartSpan(ctx, op,
+		trace.StringAttribute(logfields.UVMID, uvm.id),
+		trace.StringAttribute("host-path", hostPath))
+	defer func() { span.SetError(err); span.End() }()
+
 	if uvm.operatingSystem != "windows" {
 		return errNotSupported
 	}
 
 	uvm.m.Lock()
 	defer uvm.m.Unlock()
-	share, err := uvm.findVSMBShare(hostPath)
-	if err == ErrNotAttached {
+	r := uvm.vsmbShares.Reserve()
+	defer r.Rollback()
+	share := &vsmbShare{
+		Name:         "s" + strconv.FormatUint(uvm.vsmbCounter+1, 16),
+		Options:      options,
+		guestRequest: guestRequest,
+	}
+	_, added, err := r.Acquire(hostPath, share)
+	if err != nil {
+		return err
+	}
+	if added {
 		uvm.vsmbCounter++
-		shareName := "s" + strconv.FormatUint(uvm.vsmbCounter, 16)
-
 		modification := &hcsschema.ModifySettingRequest{
 			RequestType: requesttype.Add,
 			Settings: hcsschema.VirtualSmbShare{
-				Name:    shareName,
+				Name:    share.Name,
 				Options: options,
 				Path:    hostPath,
 			},
@@ -72,13 +73,8 @@ func (uvm *UtilityVM) AddVSMB(hostPath string, guestRequest interface{}, options
 		if err := uvm.Modify(modification); err != nil {
 			return err
 		}
-		share = &vsmbShare{
-			name:         shareName,
-			guestRequest: guestRequest,
-		}
-		uvm.vsmbShares[hostPath] = share
 	}
-	share.refCount++
+	r.Commit()
 	return nil
 }
 
@@ -106,27 +102,25 @@ func (uvm *UtilityVM) RemoveVSMB(hostPath string) (err error) {
 
 	uvm.m.Lock()
 	defer uvm.m.Unlock()
-	share, err := uvm.findVSMBShare(hostPath)
+	e, last, err := uvm.vsmbShares.Release(hostPath)
 	if err != nil {
 		return fmt.Errorf("%s is not present as a VSMB share in %s, cannot remove", hostPath, uvm.id)
 	}
-
-	share.refCount--
-	if share.refCount > 0 {
+	if !last {
 		return nil
 	}
 
 	modification := &hcsschema.ModifySettingRequest{
 		RequestType:  requesttype.Remove,
-		Settings:     hcsschema.VirtualSmbShare{Name: share.name},
+		Settings:     hcsschema.VirtualSmbShare{Name: e.Value.(*vsmbShare).Name},
 		ResourcePath: "VirtualMachine/Devices/VirtualSmb/Shares",
 	}
 	if err := uvm.Modify(modification); err != nil {
 		return fmt.Errorf("failed to remove vsmb share %s from %s: %+v: %s", hostPath, uvm.id, modification, err)
 	}
 
-	delete(uvm.vsmbShares, hostPath)
-	return nil
+	_, err = uvm.vsmbShares.Free(hostPath)
+	return err
 }
 
 // GetVSMBUvmPath returns the guest path of a VSMB mount.
@@ -149,12 +143,9 @@ func (uvm *UtilityVM) GetVSMBUvmPath(hostPath string) (_ string, err error) {
 	if hostPath == "" {
 		return "", fmt.Errorf("no hostPath passed to GetVSMBUvmPath")
 	}
-	uvm.m.Lock()
-	defer uvm.m.Unlock()
-	share, err := uvm.findVSMBShare(hostPath)
-	if err != nil {
-		return "", err
+	e, ok := uvm.vsmbShares.Get(hostPath)
+	if !ok {
+		return "", ErrNotAttached
 	}
-	path := share.GuestPath()
-	return path, nil
+	return e.Value.(*vsmbShare).GuestPath(), nil
 }
@@ -1,6 +1,7 @@
 package uvm
 
 import (
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/logfields"
 	"github.com/sirupsen/logrus"
 )
@@ -44,3 +45,9 @@ func (uvm *UtilityVM) WaitExpectedError(expected error) error {
 
 	return err
 }
+
+// ExitReason returns why the utility VM exited. It is only valid once `Wait`
+// or `WaitExpectedError` has returned.
+func (uvm *UtilityVM) ExitReason() cow.ExitReason {
+	return uvm.hcsSystem.ExitReason()
+}
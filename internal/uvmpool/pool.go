@@ -0,0 +1,408 @@
+// Package uvmpool keeps pools of pre-booted utility VMs so that creating a
+// hypervisor isolated pod does not have to wait for a utility VM to boot.
+//
+// VMs are pooled per `Profile`. Taking a VM from the pool refills it in the
+// background. Ready VMs are health checked periodically and the VMs of a
+// profile that has not been used for a while are evicted.
+//
+// The package has no platform dependencies. VMs are created through a
+// `Factory` so that the pool can be tested with fakes.
+package uvmpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrClosed is returned when the pool is closed.
+	ErrClosed = errors.New("utility VM pool is closed")
+	// ErrEmpty is returned when no VM is ready for a profile.
+	ErrEmpty = errors.New("no utility VM is ready for the profile")
+)
+
+// DefaultCheckInterval is the default `Config.CheckInterval`.
+const DefaultCheckInterval = 30 * time.Second
+
+// Profile is the configuration of the utility VMs in a pool. Only VMs of the
+// same profile are interchangeable.
+type Profile struct {
+	// OS is "windows" or "linux".
+	OS             string `json:"OS"`
+	MemorySizeInMB int32  `json:"MemorySizeInMB"`
+	ProcessorCount int32  `json:"ProcessorCount"`
+	BootFilesPath  string `json:"BootFilesPath,omitempty"`
+}
+
+func (p Profile) String() string {
+	return fmt.Sprintf("%s/%dMB/%dCPU/%s", p.OS, p.MemorySizeInMB, p.ProcessorCount, p.BootFilesPath)
+}
+
+// VM is a pooled utility VM.
+type VM interface {
+	// ID returns the ID of the VM.
+	ID() string
+	// Check returns an error if the VM can no longer be used.
+	Check(ctx context.Context) error
+	// Close terminates the VM.
+	Close() error
+}
+
+// Factory creates the VMs of a pool.
+type Factory interface {
+	// Create creates and boots a VM of profile `p`.
+	Create(ctx context.Context, p Profile) (VM, error)
+}
+
+// Config is the configuration of a `Pool`.
+type Config struct {
+	// Profiles is the number of ready VMs to keep for each profile that is
+	// filled as soon as the pool is started.
+	Profiles map[Profile]int
+	// Size is the number of ready VMs to keep for a profile that is not in
+	// `Profiles` once a VM has been requested for it. If `0` only the profiles
+	// in `Profiles` are pooled.
+	Size int
+	// MaxVMs is the maximum number of VMs that are ready or being created
+	// across all profiles. If `0` there is no limit.
+	MaxVMs int
+	// IdleTimeout is the time after which the ready VMs of a profile that no
+	// VM was requested for are closed. The profile is not refilled until the
+	// next request for it. If `0` VMs are never evicted.
+	IdleTimeout time.Duration
+	// CheckInterval is the interval at which ready VMs are health checked,
+	// idle profiles are evicted and failed creations are retried. Defaults to
+	// `DefaultCheckInterval`.
+	CheckInterval time.Duration
+}
+
+type profile struct {
+	size     int
+	ready    []VM
+	creating int
+	lastUsed time.Time
+	// idle is true once the ready VMs of the profile were evicted.
+	idle bool
+}
+
+// Pool is a pool of pre-booted VMs. It is safe for concurrent use.
+type Pool struct {
+	factory Factory
+	config  Config
+	now     func() time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	refill chan struct{}
+
+	m        sync.Mutex
+	profiles map[Profile]*profile
+	closed   bool
+
+	loop      sync.WaitGroup
+	creations sync.WaitGroup
+}
+
+// New creates a `Pool` that creates VMs with `factory`. The pool is not filled
+// until `Start` is called.
+func New(factory Factory, config Config) (*Pool, error) {
+	if config.Size < 0 || config.MaxVMs < 0 || config.IdleTimeout < 0 || config.CheckInterval < 0 {
+		return nil, errors.New("utility VM pool sizes and intervals must not be negative")
+	}
+	if config.CheckInterval == 0 {
+		config.CheckInterval = DefaultCheckInterval
+	}
+	p := &Pool{
+		factory:  factory,
+		config:   config,
+		now:      time.Now,
+		refill:   make(chan struct{}, 1),
+		profiles: make(map[Profile]*profile),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	for pr, size := range config.Profiles {
+		if size <= 0 {
+			return nil, fmt.Errorf("utility VM pool size of profile '%s' must be positive", pr)
+		}
+		p.profiles[pr] = &profile{size: size, lastUsed: p.now()}
+	}
+	return p, nil
+}
+
+// Start starts filling the pool and maintaining it in the background.
+func (p *Pool) Start() {
+	p.loop.Add(1)
+	go p.run()
+	p.signal()
+}
+
+func (p *Pool) run() {
+	defer p.loop.Done()
+	t := time.NewTicker(p.config.CheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.refill:
+			p.fill()
+		case <-t.C:
+			p.evictIdle()
+			p.check()
+			p.fill()
+		}
+	}
+}
+
+// signal requests a refill of the pool by the background loop.
+func (p *Pool) signal() {
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+}
+
+// Get takes a ready VM of profile `pr` out of the pool. The caller owns the
+// returned VM. The VM is health checked before it is returned.
+//
+// If no VM is ready returns `ErrEmpty`. The request still marks `pr` as in use
+// so that it is refilled, or pooled if `Config.Size` is set.
+func (p *Pool) Get(ctx context.Context, pr Profile) (VM, error) {
+	for {
+		p.m.Lock()
+		if p.closed {
+			p.m.Unlock()
+			return nil, ErrClosed
+		}
+		ps, ok := p.profiles[pr]
+		if !ok {
+			if p.config.Size == 0 {
+				p.m.Unlock()
+				return nil, ErrEmpty
+			}
+			ps = &profile{size: p.config.Size}
+			p.profiles[pr] = ps
+		}
+		ps.lastUsed = p.now()
+		ps.idle = false
+		if len(ps.ready) == 0 {
+			p.m.Unlock()
+			p.signal()
+			return nil, ErrEmpty
+		}
+		vm := ps.ready[0]
+		ps.ready = ps.ready[1:]
+		p.m.Unlock()
+		p.signal()
+
+		if err := vm.Check(ctx); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"profile":       pr.String(),
+				"vm-id":         vm.ID(),
+				logrus.ErrorKey: err,
+			}).Warning("uvmpool::Get - discarding unhealthy utility VM")
+			closeVMs([]VM{vm})
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		return vm, nil
+	}
+}
+
+// Len returns the number of ready VMs of profile `pr`.
+func (p *Pool) Len(pr Profile) int {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if ps, ok := p.profiles[pr]; ok {
+		return len(ps.ready)
+	}
+	return 0
+}
+
+// Idle returns `true` if no VM is ready or being created and every profile
+// has been evicted for being idle.
+func (p *Pool) Idle() bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+	for _, ps := range p.profiles {
+		if !ps.idle || len(ps.ready) > 0 || ps.creating > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Close stops maintaining the pool and closes all ready VMs. VMs that finish
+// booting after `Close` are closed as well.
+func (p *Pool) Close() error {
+	p.m.Lock()
+	if p.closed {
+		p.m.Unlock()
+		return nil
+	}
+	p.closed = true
+	var vms []VM
+	for _, ps := range p.profiles {
+		vms = append(vms, ps.ready...)
+		ps.ready = nil
+	}
+	p.m.Unlock()
+
+	p.cancel()
+	p.loop.Wait()
+	closeVMs(vms)
+	p.creations.Wait()
+	return nil
+}
+
+// sortedProfilesL returns the profiles ordered so that they are filled in a
+// consistent order. The mutex MUST be held.
+func (p *Pool) sortedProfilesL() []Profile {
+	profiles := make([]Profile, 0, len(p.profiles))
+	for pr := range p.profiles {
+		profiles = append(profiles, pr)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].String() < profiles[j].String() })
+	return profiles
+}
+
+// fill starts creating VMs for every profile that is not idle until it has
+// its size of ready VMs, within `Config.MaxVMs`.
+func (p *Pool) fill() {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.closed {
+		return
+	}
+	total := 0
+	for _, ps := range p.profiles {
+		total += len(ps.ready) + ps.creating
+	}
+	for _, pr := range p.sortedProfilesL() {
+		ps := p.profiles[pr]
+		if ps.idle {
+			continue
+		}
+		for len(ps.ready)+ps.creating < ps.size {
+			if p.config.MaxVMs > 0 && total >= p.config.MaxVMs {
+				return
+			}
+			ps.creating++
+			total++
+			p.creations.Add(1)
+			go p.create(pr)
+		}
+	}
+}
+
+// create creates a VM of profile `pr` and adds it to the ready VMs. Failures
+// are retried by the next periodic fill.
+func (p *Pool) create(pr Profile) {
+	defer p.creations.Done()
+	vm, err := p.factory.Create(p.ctx, pr)
+
+	p.m.Lock()
+	ps := p.profiles[pr]
+	ps.creating--
+	if err != nil {
+		p.m.Unlock()
+		logrus.WithFields(logrus.Fields{
+			"profile":       pr.String(),
+			logrus.ErrorKey: err,
+		}).Error("uvmpool::create - failed to create utility VM")
+		return
+	}
+	if p.closed || ps.idle || len(ps.ready) >= ps.size {
+		p.m.Unlock()
+		closeVMs([]VM{vm})
+		return
+	}
+	ps.ready = append(ps.ready, vm)
+	p.m.Unlock()
+}
+
+// evictIdle closes the ready VMs of every profile that no VM was requested for
+// within `Config.IdleTimeout`.
+func (p *Pool) evictIdle() {
+	if p.config.IdleTimeout == 0 {
+		return
+	}
+	var evicted []VM
+	p.m.Lock()
+	now := p.now()
+	for pr, ps := range p.profiles {
+		if !ps.idle && now.Sub(ps.lastUsed) >= p.config.IdleTimeout {
+			logrus.WithFields(logrus.Fields{
+				"profile": pr.String(),
+				"count":   len(ps.ready),
+			}).Debug("uvmpool::evictIdle - evicting idle profile")
+			ps.idle = true
+			evicted = append(evicted, ps.ready...)
+			ps.ready = nil
+		}
+	}
+	p.m.Unlock()
+	closeVMs(evicted)
+}
+
+// check health checks every ready VM and closes the VMs that fail.
+func (p *Pool) check() {
+	type pooledVM struct {
+		pr Profile
+		vm VM
+	}
+	var vms []pooledVM
+	p.m.Lock()
+	for pr, ps := range p.profiles {
+		for _, vm := range ps.ready {
+			vms = append(vms, pooledVM{pr, vm})
+		}
+	}
+	p.m.Unlock()
+
+	for _, v := range vms {
+		err := v.vm.Check(p.ctx)
+		if err == nil {
+			continue
+		}
+		p.m.Lock()
+		removed := false
+		ps := p.profiles[v.pr]
+		for i, vm := range ps.ready {
+			if vm == v.vm {
+				ps.ready = append(ps.ready[:i], ps.ready[i+1:]...)
+				removed = true
+				break
+			}
+		}
+		p.m.Unlock()
+		// A VM that is no longer ready was taken or closed concurrently.
+		if removed {
+			logrus.WithFields(logrus.Fields{
+				"profile":       v.pr.String(),
+				"vm-id":         v.vm.ID(),
+				logrus.ErrorKey: err,
+			}).Warning("uvmpool::check - closing unhealthy utility VM")
+			closeVMs([]VM{v.vm})
+		}
+	}
+}
+
+func closeVMs(vms []VM) {
+	for _, vm := range vms {
+		if err := vm.Close(); err != nil {
+			logrus.WithFields(logrus.Fields{
+				"vm-id":         vm.ID(),
+				logrus.ErrorKey: err,
+			}).Warning("uvmpool - failed to close utility VM")
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package uvmpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	lcowProfile = Profile{OS: "linux", MemorySizeInMB: 1024, ProcessorCount: 2, BootFilesPath: `C:\boot`}
+	bigProfile  = Profile{OS: "linux", MemorySizeInMB: 4096, ProcessorCount: 4, BootFilesPath: `C:\boot`}
+)
+
+type fakeVM struct {
+	id      string
+	profile Profile
+
+	m        sync.Mutex
+	checkErr error
+	closed   bool
+}
+
+func (vm *fakeVM) ID() string { return vm.id }
+
+func (vm *fakeVM) Check(ctx context.Context) error {
+	vm.m.Lock()
+	defer vm.m.Unlock()
+	return vm.checkErr
+}
+
+func (vm *fakeVM) Close() error {
+	vm.m.Lock()
+	defer vm.m.Unlock()
+	vm.closed = true
+	return nil
+}
+
+func (vm *fakeVM) setCheckErr(err error) {
+	vm.m.Lock()
+	defer vm.m.Unlock()
+	vm.checkErr = err
+}
+
+func (vm *fakeVM) isClosed() bool {
+	vm.m.Lock()
+	defer vm.m.Unlock()
+	return vm.closed
+}
+
+type fakeFactory struct {
+	m       sync.Mutex
+	err     error
+	created []*fakeVM
+	// block, if set, is received from before each VM is created.
+	block chan struct{}
+}
+
+func (f *fakeFactory) Create(ctx context.Context, p Profile) (VM, error) {
+	if f.block != nil {
+		<-f.block
+	}
+	f.m.Lock()
+	defer f.m.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	vm := &fakeVM{id: fmt.Sprintf("vm%d", len(f.created)), profile: p}
+	f.created = append(f.created, vm)
+	return vm, nil
+}
+
+// closed returns the number of closed VMs of profile `p`.
+func (f *fakeFactory) closed(p Profile) int {
+	f.m.Lock()
+	defer f.m.Unlock()
+	n := 0
+	for _, vm := range f.created {
+		if vm.profile == p && vm.isClosed() {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeFactory) count() int {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return len(f.created)
+}
+
+// fillAndWait synchronously fills `p` without the background loop.
+func fillAndWait(p *Pool) {
+	p.fill()
+	p.creations.Wait()
+}
+
+func newTestPool(t *testing.T, f Factory, config Config) *Pool {
+	p, err := New(f, config)
+	if err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+	return p
+}
+
+func Test_New_InvalidConfig_Error(t *testing.T) {
+	configs := []Config{
+		{Size: -1},
+		{MaxVMs: -1},
+		{IdleTimeout: -time.Second},
+		{Profiles: map[Profile]int{lcowProfile: 0}},
+	}
+	for _, c := range configs {
+		if _, err := New(&fakeFactory{}, c); err == nil {
+			t.Errorf("expected config %+v to be invalid", c)
+		}
+	}
+}
+
+func Test_Fill_CreatesProfileSize(t *testing.T) {
+	f := &fakeFactory{}
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 3, bigProfile: 1}})
+	fillAndWait(p)
+	if p.Len(lcowProfile) != 3 || p.Len(bigProfile) != 1 {
+		t.Fatalf("expected 3 and 1 ready VMs, got: %d and %d", p.Len(lcowProfile), p.Len(bigProfile))
+	}
+	// Filling a full pool creates nothing.
+	fillAndWait(p)
+	if f.count() != 4 {
+		t.Fatalf("expected 4 VMs created, got: %d", f.count())
+	}
+}
+
+func Test_Fill_MaxVMs(t *testing.T) {
+	f := &fakeFactory{}
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 3, bigProfile: 3}, MaxVMs: 4})
+	fillAndWait(p)
+	if total := p.Len(lcowProfile) + p.Len(bigProfile); total != 4 {
+		t.Fatalf("expected 4 ready VMs, got: %d", total)
+	}
+}
+
+func Test_Fill_CountsCreatingVMs(t *testing.T) {
+	f := &fakeFactory{block: make(chan struct{})}
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 2}})
+	p.fill()
+	// The VMs being created count towards the profile size.
+	p.fill()
+	close(f.block)
+	p.creations.Wait()
+	if f.count() != 2 || p.Len(lcowProfile) != 2 {
+		t.Fatalf("expected 2 VMs created and ready, got: %d and %d", f.count(), p.Len(lcowProfile))
+	}
+}
+
+func Test_Fill_CreateFailure(t *testing.T) {
+	f := &fakeFactory{err: errors.New("boot failed")}
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 2}})
+	fillAndWait(p)
+	if p.Len(lcowProfile) != 0 {
+		t.Fatalf("expected no ready VMs, got: %d", p.Len(lcowProfile))
+	}
+	// The next fill retries.
+	f.err = nil
+	fillAndWait(p)
+	if p.Len(lcowProfile) != 2 {
+		t.Fatalf("expected 2 ready VMs after retry, got: %d", p.Len(lcowProfile))
+	}
+}
+
+func Test_Get_TakesOldestVM(t *testing.T) {
+	f := &fakeFactory{}
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 1}})
+	fillAndWait(p)
+	vm, err := p.Get(context.Background(), lcowProfile)
+	if err != nil {
+		t.Fatalf("get should not have failed, got: %v", err)
+	}
+	if vm != f.created[0] {
+		t.Fatalf("expected the first VM, got: %s", vm.ID())
+	}
+	if p.Len(lcowProfile) != 0 {
+		t.Fatalf("expected the VM to be taken out of the pool, got: %d ready", p.Len(lcowProfile))
+	}
+	if _, err := p.Get(context.Background(), lcowProfile); err != ErrEmpty {
+		t.Fatalf("expected ErrEmpty, got: %v", err)
+	}
+}
+
+func Test_Get_SignalsRefill(t *testing.T) {
+	f := &fakeFactory{}
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 1}})
+	fillAndWait(p)
+	if _, err := p.Get(context.Background(), lcowProfile); err != nil {
+		t.Fatalf("get should not have failed, got: %v", err)
+	}
+	select {
+	case <-p.refill:
+	default:
+		t.Fatal("expected a refill to be requested")
+	}
+	fillAndWait(p)
+	if p.Len(lcowProfile) != 1 {
+		t.Fatalf("expected the pool to be refilled, got: %d ready", p.Len(lcowProfile))
+	}
+}
+
+func Test_Get_UnknownProfile(t *testing.T) {
+	f := &fakeFactory{}
+	p := newTestPool(t, f, Config{})
+	if _, err := p.Get(context.Background(), lcowProfile); err != ErrEmpty {
+		t.Fatalf("expected ErrEmpty, got: %v", err)
+	}
+	fillAndWait(p)
+	if f.count() != 0 {
+		t.Fatalf("expected an unknown profile not to be pooled without Size, got: %d created", f.count())
+	}
+}
+
+func Test_Get_UnknownProfile_LearnedWithSize(t *testing.T) {
+	f := &fakeFactory{}
+	p := newTestPool(t, f, Config{Size: 2})
+	if _, err := p.Get(context.Background(), lcowProfile); err != ErrEmpty {
+		t.Fatalf("expected ErrEmpty, got: %v", err)
+	}
+	fillAndWait(p)
+	if p.Len(lcowProfile) != 2 {
+		t.Fatalf("expected the requested profile to be pooled, got: %d ready", p.Len(lcowProfile))
+	}
+}
+
+func Test_Get_SkipsUnhealthyVM(t *testing.T) {
+	f := &fakeFactory{}
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 2}})
+	fillAndWait(p)
+	ready := p.profiles[lcowProfile].ready
+	unhealthy, healthy := ready[0].(*fakeVM), ready[1]
+	unhealthy.setCheckErr(errors.New("gcs disconnected"))
+	vm, err := p.Get(context.Background(), lcowProfile)
+	if err != nil {
+		t.Fatalf("get should not have failed, got: %v", err)
+	}
+	if vm != healthy {
+		t.Fatalf("expected the healthy VM, got: %s", vm.ID())
+	}
+	if !unhealthy.isClosed() {
+		t.Fatal("expected the unhealthy VM to be closed")
+	}
+}
+
+func Test_Check_ClosesUnhealthyVMs(t *testing.T) {
+	f := &fakeFactory{}
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 2}})
+	fillAndWait(p)
+	f.created[1].setCheckErr(errors.New("gcs disconnected"))
+	p.check()
+	if p.Len(lcowProfile) != 1 || !f.created[1].isClosed() || f.created[0].isClosed() {
+		t.Fatal("expected only the unhealthy VM to be closed and removed")
+	}
+	fillAndWait(p)
+	if p.Len(lcowProfile) != 2 || f.count() != 3 {
+		t.Fatalf("expected the unhealthy VM to be replaced, got: %d ready, %d created", p.Len(lcowProfile), f.count())
+	}
+}
+
+func Test_EvictIdle(t *testing.T) {
+	f := &fakeFactory{}
+	now := time.Unix(0, 0)
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 1, bigProfile: 1}, IdleTimeout: time.Minute})
+	p.now = func() time.Time { return now }
+	for pr := range p.profiles {
+		p.profiles[pr].lastUsed = now
+	}
+	fillAndWait(p)
+
+	now = now.Add(30 * time.Second)
+	if _, err := p.Get(context.Background(), lcowProfile); err != nil {
+		t.Fatalf("get should not have failed, got: %v", err)
+	}
+	fillAndWait(p)
+
+	now = now.Add(40 * time.Second)
+	p.evictIdle()
+	if p.Len(bigProfile) != 0 || f.closed(bigProfile) != 1 {
+		t.Fatal("expected the idle profile to be evicted")
+	}
+	if p.Len(lcowProfile) != 1 || f.closed(lcowProfile) != 0 {
+		t.Fatal("expected the recently used profile to be kept")
+	}
+	// An evicted profile is not refilled.
+	fillAndWait(p)
+	if p.Len(bigProfile) != 0 {
+		t.Fatal("expected the evicted profile not to be refilled")
+	}
+	if p.Idle() {
+		t.Fatal("expected the pool not to be idle")
+	}
+
+	// A request for the evicted profile re-arms it.
+	if _, err := p.Get(context.Background(), bigProfile); err != ErrEmpty {
+		t.Fatalf("expected ErrEmpty, got: %v", err)
+	}
+	fillAndWait(p)
+	if p.Len(bigProfile) != 1 {
+		t.Fatal("expected the requested profile to be refilled")
+	}
+}
+
+func Test_Idle(t *testing.T) {
+	f := &fakeFactory{}
+	now := time.Unix(0, 0)
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 1}, IdleTimeout: time.Minute})
+	p.now = func() time.Time { return now }
+	p.profiles[lcowProfile].lastUsed = now
+	fillAndWait(p)
+	if p.Idle() {
+		t.Fatal("expected a filled pool not to be idle")
+	}
+	now = now.Add(time.Minute)
+	p.evictIdle()
+	if !p.Idle() {
+		t.Fatal("expected the pool to be idle after eviction")
+	}
+}
+
+func Test_Close_ClosesVMs(t *testing.T) {
+	f := &fakeFactory{}
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 2}})
+	fillAndWait(p)
+	if err := p.Close(); err != nil {
+		t.Fatalf("close should not have failed, got: %v", err)
+	}
+	for _, vm := range f.created {
+		if !vm.isClosed() {
+			t.Fatalf("expected VM %s to be closed", vm.id)
+		}
+	}
+	if _, err := p.Get(context.Background(), lcowProfile); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got: %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("second close should not have failed, got: %v", err)
+	}
+}
+
+func Test_Close_ClosesVMsCreatedAfter(t *testing.T) {
+	f := &fakeFactory{block: make(chan struct{})}
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 1}})
+	p.fill()
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+	// Let the creation finish only once the pool is closing.
+	for {
+		p.m.Lock()
+		isClosed := p.closed
+		p.m.Unlock()
+		if isClosed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(f.block)
+	<-closed
+	if f.count() != 1 || !f.created[0].isClosed() {
+		t.Fatal("expected the VM created after close to be closed")
+	}
+}
+
+func Test_Start_FillsInBackground(t *testing.T) {
+	f := &fakeFactory{}
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 2}, CheckInterval: time.Hour})
+	p.Start()
+	defer p.Close()
+	deadline := time.Now().Add(10 * time.Second)
+	for p.Len(lcowProfile) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for the pool to fill, got: %d ready", p.Len(lcowProfile))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func Test_Get_Concurrent(t *testing.T) {
+	f := &fakeFactory{}
+	p := newTestPool(t, f, Config{Profiles: map[Profile]int{lcowProfile: 8}})
+	fillAndWait(p)
+	var (
+		wg    sync.WaitGroup
+		m     sync.Mutex
+		taken = make(map[VM]bool)
+	)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vm, err := p.Get(context.Background(), lcowProfile)
+			if err == ErrEmpty {
+				return
+			}
+			if err != nil {
+				t.Errorf("get should not have failed, got: %v", err)
+				return
+			}
+			m.Lock()
+			defer m.Unlock()
+			if taken[vm] {
+				t.Errorf("VM %s was taken twice", vm.ID())
+			}
+			taken[vm] = true
+		}()
+	}
+	wg.Wait()
+	if len(taken) != 8 {
+		t.Fatalf("expected 8 VMs to be taken, got: %d", len(taken))
+	}
+}
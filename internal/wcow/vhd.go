@@ -0,0 +1,86 @@
+package wcow
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/Microsoft/hcsshim/internal/wclayer"
+	"github.com/sirupsen/logrus"
+)
+
+//go:generate go run ../../mksyscall_windows.go -output zsyscall_windows.go vhd.go
+
+//sys createVirtualDisk(virtualStorageType *virtualStorageType, path string, virtualDiskAccessMask uint32, securityDescriptor *uintptr, flags uint32, providerSpecificFlags uint32, parameters *createVirtualDiskParameters, o *syscall.Overlapped, handle *syscall.Handle) (err error) [failretval != 0] = VirtDisk.CreateVirtualDisk
+
+type virtualStorageType struct {
+	DeviceID uint32
+	VendorID [16]byte
+}
+
+type createVersion2 struct {
+	UniqueID                 [16]byte // GUID
+	MaximumSize              uint64
+	BlockSizeInBytes         uint32
+	SectorSizeInBytes        uint32
+	ParentPath               *uint16 // string
+	SourcePath               *uint16 // string
+	OpenFlags                uint32
+	ParentVirtualStorageType virtualStorageType
+	SourceVirtualStorageType virtualStorageType
+	ResiliencyGUID           [16]byte // GUID
+}
+
+type createVirtualDiskParameters struct {
+	Version  uint32 // Must always be set to 2
+	Version2 createVersion2
+}
+
+// CreateUVMDifferencingScratch is a helper to create a scratch for a Windows
+// utility VM in a specified directory as a differencing disk of `parentPath`,
+// with permissions to the specified VM ID. The parent MUST NOT be modified
+// while the differencing disk exists.
+func CreateUVMDifferencingScratch(parentPath, destDirectory, vmID string) error {
+	targetScratch := filepath.Join(destDirectory, "sandbox.vhdx")
+	logrus.Debugf("uvm::CreateUVMDifferencingScratch %s from %s", targetScratch, parentPath)
+
+	parent, err := syscall.UTF16PtrFromString(parentPath)
+	if err != nil {
+		return err
+	}
+	var (
+		defaultType virtualStorageType
+		handle      syscall.Handle
+	)
+	parameters := createVirtualDiskParameters{
+		Version: 2,
+		Version2: createVersion2{
+			ParentPath: parent,
+		},
+	}
+	// The size and block size are inherited from the parent.
+	if err := createVirtualDisk(
+		&defaultType,
+		targetScratch,
+		0,
+		nil,
+		0,
+		0,
+		&parameters,
+		nil,
+		&handle); err != nil {
+		return &os.PathError{Op: "CreateVirtualDisk", Path: targetScratch, Err: err}
+	}
+	if err := syscall.CloseHandle(handle); err != nil {
+		os.Remove(targetScratch)
+		return err
+	}
+	// The VM opens the parent as well as the differencing disk.
+	for _, path := range []string{parentPath, targetScratch} {
+		if err := wclayer.GrantVmAccess(vmID, path); err != nil {
+			os.Remove(targetScratch)
+			return err
+		}
+	}
+	return nil
+}
@@ -1,12 +1,13 @@
 package functional
 
 import (
+	"context"
 	"os"
 	"os/exec"
 	"strconv"
 	"time"
 
-	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/hcsoci"
 	"github.com/sirupsen/logrus"
 )
@@ -33,11 +34,11 @@ func init() {
 
 }
 
-func CreateContainerTestWrapper(options *hcsoci.CreateOptions) (*hcs.System, *hcsoci.Resources, error) {
+func CreateContainerTestWrapper(options *hcsoci.CreateOptions) (cow.ComputeSystem, *hcsoci.Resources, error) {
 	if pauseDurationOnCreateContainerFailure != 0 {
 		options.DoNotReleaseResourcesOnFailure = true
 	}
-	s, r, err := hcsoci.CreateContainer(options)
+	s, r, err := hcsoci.CreateContainer(context.Background(), options)
 	if err != nil {
 		logrus.Warnf("Test is pausing for %s for debugging CreateContainer failure", pauseDurationOnCreateContainerFailure)
 		time.Sleep(pauseDurationOnCreateContainerFailure)
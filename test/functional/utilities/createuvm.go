@@ -1,6 +1,7 @@
 package testutilities
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -20,7 +21,7 @@ func CreateWCOWUVMFromOpts(t *testing.T, opts *uvm.OptionsWCOW) *uvm.UtilityVM {
 		t.Fatalf("opts must bet set with LayerFolders")
 	}
 
-	uvm, err := uvm.CreateWCOW(opts)
+	uvm, err := uvm.CreateWCOW(context.Background(), opts)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -64,7 +65,7 @@ func CreateLCOWUVMFromOpts(t *testing.T, opts *uvm.OptionsLCOW) *uvm.UtilityVM {
 		t.Fatal("opts must be set")
 	}
 
-	uvm, err := uvm.CreateLCOW(opts)
+	uvm, err := uvm.CreateLCOW(context.Background(), opts)
 	if err != nil {
 		t.Fatal(err)
 	}
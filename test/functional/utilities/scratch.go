@@ -1,6 +1,7 @@
 package testutilities
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
 
@@ -52,7 +53,7 @@ func CreateLCOWBlankRWLayer(t *testing.T, vmID string) string {
 	}
 	tempDir := CreateTempDir(t)
 
-	if err := lcow.CreateScratch(lcowGlobalSVM, filepath.Join(tempDir, "sandbox.vhdx"), lcow.DefaultScratchSizeGB, lcowCacheScratchFile, vmID); err != nil {
+	if err := lcow.CreateScratch(context.Background(), lcowGlobalSVM, filepath.Join(tempDir, "sandbox.vhdx"), lcow.DefaultScratchSizeGB, lcowCacheScratchFile, vmID); err != nil {
 		t.Fatalf("failed to create EXT4 scratch for LCOW test cases: %s", err)
 	}
 	return tempDir
@@ -3,6 +3,7 @@
 package functional
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -65,7 +66,7 @@ func TestMemBackingTypeLCOW(t *testing.T) {
 
 func runBenchMemStartTest(b *testing.B, opts *uvm.OptionsLCOW) {
 	// Cant use testutilities here because its `testing.B` not `testing.T`
-	u, err := uvm.CreateLCOW(opts)
+	u, err := uvm.CreateLCOW(context.Background(), opts)
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -3,6 +3,7 @@
 package functional
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -32,7 +33,7 @@ func TestVPMEM(t *testing.T) {
 	defer os.RemoveAll(tempDir)
 
 	for i := 0; i < int(iterations); i++ {
-		deviceNumber, uvmPath, err := u.AddVPMEM(filepath.Join(tempDir, "layer.vhd"), true)
+		deviceNumber, uvmPath, err := u.AddVPMEM(context.Background(), filepath.Join(tempDir, "layer.vhd"), true)
 		if err != nil {
 			t.Fatalf("AddVPMEM failed: %s", err)
 		}
@@ -3,6 +3,7 @@
 package functional
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -29,7 +30,7 @@ func TestVSMB(t *testing.T) {
 		ShareRead:           true,
 	}
 	for i := 0; i < int(iterations); i++ {
-		if err := uvm.AddVSMB(dir, "", options); err != nil {
+		if err := uvm.AddVSMB(context.Background(), dir, "", options); err != nil {
 			t.Fatalf("AddVSMB failed: %s", err)
 		}
 	}
@@ -4,13 +4,14 @@ package functional
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/Microsoft/hcsshim"
-	"github.com/Microsoft/hcsshim/internal/hcs"
+	"github.com/Microsoft/hcsshim/internal/cow"
 	"github.com/Microsoft/hcsshim/internal/hcsoci"
 	"github.com/Microsoft/hcsshim/internal/schema1"
 	"github.com/Microsoft/hcsshim/internal/schemaversion"
@@ -172,13 +173,13 @@ import (
 //}
 
 // Helper to start a container.
-// Ones created through hcsoci methods will be of type *hcs.System.
+// Ones created through hcsoci methods will be of type cow.ComputeSystem.
 // Ones created through hcsshim methods will be of type hcsshim.Container
 func startContainer(t *testing.T, c interface{}) {
 	var err error
 	switch c.(type) {
-	case *hcs.System:
-		err = c.(*hcs.System).Start()
+	case cow.ComputeSystem:
+		err = c.(cow.ComputeSystem).Start()
 	case hcsshim.Container:
 		err = c.(hcsshim.Container).Start()
 	default:
@@ -190,22 +191,22 @@ func startContainer(t *testing.T, c interface{}) {
 }
 
 // Helper to stop a container.
-// Ones created through hcsoci methods will be of type *hcs.System.
+// Ones created through hcsoci methods will be of type cow.ComputeSystem.
 // Ones created through hcsshim methods will be of type hcsshim.Container
 func stopContainer(t *testing.T, c interface{}) {
 
 	switch c.(type) {
-	case *hcs.System:
-		if err := c.(*hcs.System).Shutdown(); err != nil {
+	case cow.ComputeSystem:
+		if err := c.(cow.ComputeSystem).Shutdown(); err != nil {
 			if hcsshim.IsPending(err) {
-				if err := c.(*hcs.System).Wait(); err != nil {
+				if err := c.(cow.ComputeSystem).Wait(); err != nil {
 					t.Fatalf("Failed Wait shutdown: %s", err)
 				}
 			} else {
 				t.Fatalf("Failed shutdown: %s", err)
 			}
 		}
-		c.(*hcs.System).Terminate()
+		c.(cow.ComputeSystem).Terminate()
 
 	case hcsshim.Container:
 		if err := c.(hcsshim.Container).Shutdown(); err != nil {
@@ -288,7 +289,7 @@ func runShimCommands(t *testing.T, c hcsshim.Container) {
 	runShimCommand(t, c, `ls`, `c:\mappedrw`, 0, `readwrite`)
 }
 
-func runHcsCommands(t *testing.T, c *hcs.System) {
+func runHcsCommands(t *testing.T, c cow.ComputeSystem) {
 	runHcsCommand(t, c, `echo Hello`, `c:\`, 0, "Hello")
 
 	// Check that read-only doesn't allow deletion or creation
@@ -308,7 +309,7 @@ func runHcsCommands(t *testing.T, c *hcs.System) {
 // Helper to launch a process in a container created through the hcsshim methods.
 // At the point of calling, the container must have been successfully created.
 func runHcsCommand(t *testing.T,
-	c *hcs.System,
+	c cow.ComputeSystem,
 	command string,
 	workdir string,
 	expectedExitCode int,
@@ -404,7 +405,7 @@ func TestWCOWArgonShim(t *testing.T) {
 	}()
 
 	// This is a cheat but stops us re-writing exactly the same code just for test
-	argonShimLocalMountPath, err := hcsoci.MountContainerLayers(append(imageLayers, argonShimScratchDir), "", nil)
+	argonShimLocalMountPath, err := hcsoci.MountContainerLayers(context.Background(), append(imageLayers, argonShimScratchDir), "", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -524,7 +525,7 @@ func TestWCOWArgonOciV1(t *testing.T) {
 
 	// For cleanup on failure
 	var argonOci1Resources *hcsoci.Resources
-	var argonOci1 *hcs.System
+	var argonOci1 cow.ComputeSystem
 	defer func() {
 		if argonOci1Mounted {
 			hcsoci.ReleaseResources(argonOci1Resources, nil, true)
@@ -533,7 +534,7 @@ func TestWCOWArgonOciV1(t *testing.T) {
 
 	var err error
 	spec := generateWCOWOciTestSpec(t, imageLayers, argonOci1ScratchDir, hostRWSharedDirectory, hostROSharedDirectory)
-	argonOci1, argonOci1Resources, err = hcsoci.CreateContainer(
+	argonOci1, argonOci1Resources, err = hcsoci.CreateContainer(context.Background(),
 		&hcsoci.CreateOptions{
 			ID:            "argonOci1",
 			SchemaVersion: schemaversion.SchemaV10(),
@@ -575,7 +576,7 @@ func TestWCOWXenonOciV1(t *testing.T) {
 
 	// For cleanup on failure
 	var xenonOci1Resources *hcsoci.Resources
-	var xenonOci1 *hcs.System
+	var xenonOci1 cow.ComputeSystem
 	defer func() {
 		if xenonOci1Mounted {
 			hcsoci.ReleaseResources(xenonOci1Resources, nil, true)
@@ -585,7 +586,7 @@ func TestWCOWXenonOciV1(t *testing.T) {
 	var err error
 	spec := generateWCOWOciTestSpec(t, imageLayers, xenonOci1ScratchDir, hostRWSharedDirectory, hostROSharedDirectory)
 	spec.Windows.HyperV = &specs.WindowsHyperV{}
-	xenonOci1, xenonOci1Resources, err = hcsoci.CreateContainer(
+	xenonOci1, xenonOci1Resources, err = hcsoci.CreateContainer(context.Background(),
 		&hcsoci.CreateOptions{
 			ID:            "xenonOci1",
 			SchemaVersion: schemaversion.SchemaV10(),
@@ -622,7 +623,7 @@ func TestWCOWArgonOciV2(t *testing.T) {
 
 	// For cleanup on failure
 	var argonOci2Resources *hcsoci.Resources
-	var argonOci2 *hcs.System
+	var argonOci2 cow.ComputeSystem
 	defer func() {
 		if argonOci2Mounted {
 			hcsoci.ReleaseResources(argonOci2Resources, nil, true)
@@ -631,7 +632,7 @@ func TestWCOWArgonOciV2(t *testing.T) {
 
 	var err error
 	spec := generateWCOWOciTestSpec(t, imageLayers, argonOci2ScratchDir, hostRWSharedDirectory, hostROSharedDirectory)
-	argonOci2, argonOci2Resources, err = hcsoci.CreateContainer(
+	argonOci2, argonOci2Resources, err = hcsoci.CreateContainer(context.Background(),
 		&hcsoci.CreateOptions{
 			ID:            "argonOci2",
 			SchemaVersion: schemaversion.SchemaV21(),
@@ -674,7 +675,7 @@ func TestWCOWXenonOciV2(t *testing.T) {
 	}
 
 	var xenonOci2Resources *hcsoci.Resources
-	var xenonOci2 *hcs.System
+	var xenonOci2 cow.ComputeSystem
 	var xenonOci2UVM *uvm.UtilityVM
 	defer func() {
 		if xenonOci2Mounted {
@@ -694,7 +695,7 @@ func TestWCOWXenonOciV2(t *testing.T) {
 
 	xenonOciOpts := uvm.NewDefaultOptionsWCOW(xenonOci2UVMId, "")
 	xenonOciOpts.LayerFolders = append(imageLayers, xenonOci2UVMScratchDir)
-	xenonOci2UVM, err = uvm.CreateWCOW(xenonOciOpts)
+	xenonOci2UVM, err = uvm.CreateWCOW(context.Background(), xenonOciOpts)
 	if err != nil {
 		t.Fatalf("Failed create UVM: %s", err)
 	}
@@ -706,7 +707,7 @@ func TestWCOWXenonOciV2(t *testing.T) {
 	}
 
 	spec := generateWCOWOciTestSpec(t, imageLayers, xenonOci2ScratchDir, hostRWSharedDirectory, hostROSharedDirectory)
-	xenonOci2, xenonOci2Resources, err = hcsoci.CreateContainer(
+	xenonOci2, xenonOci2Resources, err = hcsoci.CreateContainer(context.Background(),
 		&hcsoci.CreateOptions{
 			ID:            "xenonOci2",
 			HostingSystem: xenonOci2UVM,